@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"strings"
 
 	"cloud.google.com/go/civil"
 	"github.com/apache/arrow/go/v15/arrow"
@@ -213,6 +214,15 @@ func convertArrowValue(col arrow.Array, i int, ft arrow.DataType, fs *FieldSchem
 		return convertBasicType(fmt.Sprintf("%v", v), fs.Type)
 	case *arrow.BinaryType:
 		v := col.(*array.Binary).Value(i)
+		if fs.Type == GeographyFieldType {
+			// GEOGRAPHY values in WKB are converted to WKT, the
+			// representation of GEOGRAPHY values elsewhere.
+			g, err := ParseGeographyWKB(v)
+			if err != nil {
+				return nil, err
+			}
+			return g.WKT(), nil
+		}
 		encoded := base64.StdEncoding.EncodeToString(v)
 		return convertBasicType(encoded, fs.Type)
 	case *arrow.StringType:
@@ -295,3 +305,175 @@ func convertArrowValue(col arrow.Array, i int, ft arrow.DataType, fs *FieldSchem
 		return nil, fmt.Errorf("unknown arrow type: %v", ft)
 	}
 }
+
+// ValidateArrowSchema reports whether Arrow records with the schema as can be
+// written to a table with the given schema, such as with the Arrow appends of
+// the Storage Write API.
+//
+// Arrow fields are matched to columns by name, ignoring case, and their types
+// must be the ones BigQuery itself uses to represent the columns' types,
+// which are converted back by the Arrow decoding of read sessions. Columns
+// without an Arrow field are written as NULL, or with their default value,
+// so REQUIRED columns must have one.
+//
+// Experimental: this interface is experimental and may be modified or removed in future versions,
+// regardless of any other documented package stability guarantees.
+func ValidateArrowSchema(as *arrow.Schema, schema Schema) error {
+	return validateArrowFields(as.Fields(), schema, "")
+}
+
+func validateArrowFields(afs []arrow.Field, schema Schema, prefix string) error {
+	matched := make(map[*FieldSchema]bool)
+	for _, af := range afs {
+		var fs *FieldSchema
+		for _, f := range schema {
+			if strings.EqualFold(f.Name, af.Name) {
+				fs = f
+				break
+			}
+		}
+		if fs == nil {
+			return fmt.Errorf("bigquery: arrow field %q has no column in the table schema", prefix+af.Name)
+		}
+		if matched[fs] {
+			return fmt.Errorf("bigquery: arrow fields match column %q more than once", prefix+fs.Name)
+		}
+		matched[fs] = true
+		if err := validateArrowField(af.Type, fs, prefix+fs.Name); err != nil {
+			return err
+		}
+	}
+	for _, fs := range schema {
+		if fs.Required && !matched[fs] {
+			return fmt.Errorf("bigquery: REQUIRED column %q has no arrow field", prefix+fs.Name)
+		}
+	}
+	return nil
+}
+
+func validateArrowField(ft arrow.DataType, fs *FieldSchema, name string) error {
+	if fs.Repeated {
+		lt, ok := ft.(*arrow.ListType)
+		if !ok {
+			return fmt.Errorf("bigquery: REPEATED column %q requires an arrow list, got %s", name, ft)
+		}
+		ft = lt.Elem()
+	}
+	ok := false
+	switch fs.Type {
+	case RecordFieldType:
+		st, isStruct := ft.(*arrow.StructType)
+		if !isStruct {
+			return fmt.Errorf("bigquery: RECORD column %q requires an arrow struct, got %s", name, ft)
+		}
+		return validateArrowFields(st.Fields(), fs.Schema, name+".")
+	case RangeFieldType:
+		st, isStruct := ft.(*arrow.StructType)
+		if !isStruct || st.NumFields() != 2 || fs.RangeElementType == nil {
+			return fmt.Errorf("bigquery: RANGE column %q requires an arrow struct of its start and end, got %s", name, ft)
+		}
+		for _, f := range st.Fields() {
+			if !arrowTypeMatches(f.Type, fs.RangeElementType.Type) {
+				return fmt.Errorf("bigquery: RANGE column %q of %s cannot be written from arrow type %s", name, fs.RangeElementType.Type, ft)
+			}
+		}
+		ok = true
+	default:
+		ok = arrowTypeMatches(ft, fs.Type)
+	}
+	if !ok {
+		return fmt.Errorf("bigquery: column %q of type %s cannot be written from arrow type %s", name, fs.Type, ft)
+	}
+	return nil
+}
+
+// arrowTypeMatches reports whether values of the arrow type represent values
+// of the BigQuery type, following the conversions of convertArrowValue.
+func arrowTypeMatches(ft arrow.DataType, typ FieldType) bool {
+	switch ft := ft.(type) {
+	case *arrow.BooleanType:
+		return typ == BooleanFieldType
+	case *arrow.Int8Type, *arrow.Int16Type, *arrow.Int32Type, *arrow.Int64Type:
+		return typ == IntegerFieldType
+	case *arrow.Float16Type, *arrow.Float32Type, *arrow.Float64Type:
+		return typ == FloatFieldType
+	case *arrow.BinaryType:
+		return typ == BytesFieldType
+	case *arrow.StringType:
+		return typ == StringFieldType || typ == GeographyFieldType || typ == JSONFieldType
+	case *arrow.Date32Type, *arrow.Date64Type:
+		return typ == DateFieldType
+	case *arrow.TimestampType:
+		if ft.TimeZone == "" {
+			return typ == DateTimeFieldType
+		}
+		return typ == TimestampFieldType
+	case *arrow.Time32Type, *arrow.Time64Type:
+		return typ == TimeFieldType
+	case *arrow.Decimal128Type:
+		return typ == NumericFieldType || typ == BigNumericFieldType
+	case *arrow.Decimal256Type:
+		return typ == BigNumericFieldType
+	}
+	return false
+}
+
+// arrowSchema returns the Arrow schema BigQuery uses for rows of the schema,
+// the types that convertArrowValue converts back to values of the columns.
+// REQUIRED columns are not nullable, and REPEATED columns are lists of values
+// that are not nullable, as BigQuery arrays are never NULL and cannot hold
+// NULL elements.
+func arrowSchema(schema Schema) *arrow.Schema {
+	return arrow.NewSchema(arrowFields(schema), nil)
+}
+
+func arrowFields(schema Schema) []arrow.Field {
+	fields := make([]arrow.Field, len(schema))
+	for i, fs := range schema {
+		fields[i] = arrow.Field{Name: fs.Name, Type: arrowFieldType(fs), Nullable: !fs.Required && !fs.Repeated}
+	}
+	return fields
+}
+
+func arrowFieldType(fs *FieldSchema) arrow.DataType {
+	if fs.Repeated {
+		elem := *fs
+		elem.Repeated = false
+		return arrow.ListOfNonNullable(arrowFieldType(&elem))
+	}
+	switch fs.Type {
+	case IntegerFieldType:
+		return arrow.PrimitiveTypes.Int64
+	case FloatFieldType:
+		return arrow.PrimitiveTypes.Float64
+	case BooleanFieldType:
+		return arrow.FixedWidthTypes.Boolean
+	case BytesFieldType:
+		return arrow.BinaryTypes.Binary
+	case NumericFieldType:
+		return &arrow.Decimal128Type{Precision: 38, Scale: NumericScaleDigits}
+	case BigNumericFieldType:
+		return &arrow.Decimal256Type{Precision: 76, Scale: BigNumericScaleDigits}
+	case DateFieldType:
+		return arrow.FixedWidthTypes.Date32
+	case TimeFieldType:
+		return arrow.FixedWidthTypes.Time64us
+	case DateTimeFieldType:
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case TimestampFieldType:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case RecordFieldType:
+		return arrow.StructOf(arrowFields(fs.Schema)...)
+	case RangeFieldType:
+		elem := &FieldSchema{Type: DateFieldType}
+		if fs.RangeElementType != nil {
+			elem.Type = fs.RangeElementType.Type
+		}
+		return arrow.StructOf(
+			arrow.Field{Name: "start", Type: arrowFieldType(elem), Nullable: true},
+			arrow.Field{Name: "end", Type: arrowFieldType(elem), Nullable: true},
+		)
+	}
+	// STRING, JSON, GEOGRAPHY and INTERVAL values are strings.
+	return arrow.BinaryTypes.String
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+)
+
+func TestValidateArrowSchema(t *testing.T) {
+	schema := Schema{
+		{Name: "name", Type: StringFieldType, Required: true},
+		{Name: "count", Type: IntegerFieldType},
+		{Name: "ts", Type: TimestampFieldType},
+		{Name: "dt", Type: DateTimeFieldType},
+		{Name: "price", Type: NumericFieldType},
+		{Name: "tags", Type: StringFieldType, Repeated: true},
+		{Name: "period", Type: RangeFieldType, RangeElementType: &RangeElementType{Type: DateFieldType}},
+		{Name: "address", Type: RecordFieldType, Schema: Schema{
+			{Name: "city", Type: StringFieldType},
+			{Name: "zip", Type: IntegerFieldType, Required: true},
+		}},
+	}
+	address := arrow.StructOf(
+		arrow.Field{Name: "city", Type: arrow.BinaryTypes.String},
+		arrow.Field{Name: "zip", Type: arrow.PrimitiveTypes.Int32},
+	)
+	period := arrow.StructOf(
+		arrow.Field{Name: "start", Type: arrow.FixedWidthTypes.Date32},
+		arrow.Field{Name: "end", Type: arrow.FixedWidthTypes.Date32},
+	)
+	valid := []arrow.Field{
+		{Name: "NAME", Type: arrow.BinaryTypes.String},
+		{Name: "count", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "ts", Type: arrow.FixedWidthTypes.Timestamp_us},
+		{Name: "dt", Type: &arrow.TimestampType{Unit: arrow.Microsecond}},
+		{Name: "price", Type: &arrow.Decimal128Type{Precision: 38, Scale: 9}},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "period", Type: period},
+		{Name: "address", Type: address},
+	}
+	if err := ValidateArrowSchema(arrow.NewSchema(valid, nil), schema); err != nil {
+		t.Errorf("valid schema: %v", err)
+	}
+	// Optional columns may be omitted.
+	if err := ValidateArrowSchema(arrow.NewSchema(valid[:1], nil), schema); err != nil {
+		t.Errorf("subset of columns: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc   string
+		fields []arrow.Field
+	}{
+		{"missing required column", valid[1:2]},
+		{"unknown column", append([]arrow.Field{{Name: "other", Type: arrow.BinaryTypes.String}}, valid[:1]...)},
+		{"duplicate column", append([]arrow.Field{{Name: "Name", Type: arrow.BinaryTypes.String}}, valid[:1]...)},
+		{"wrong type", append([]arrow.Field{{Name: "count", Type: arrow.BinaryTypes.String}}, valid[:1]...)},
+		{"timestamp without time zone", append([]arrow.Field{{Name: "ts", Type: &arrow.TimestampType{Unit: arrow.Microsecond}}}, valid[:1]...)},
+		{"repeated column without list", append([]arrow.Field{{Name: "tags", Type: arrow.BinaryTypes.String}}, valid[:1]...)},
+		{"bad range element", append([]arrow.Field{{Name: "period", Type: address}}, valid[:1]...)},
+		{"missing nested required column", append([]arrow.Field{{Name: "address", Type: arrow.StructOf(arrow.Field{Name: "city", Type: arrow.BinaryTypes.String})}}, valid[:1]...)},
+	} {
+		if err := ValidateArrowSchema(arrow.NewSchema(tc.fields, nil), schema); err == nil {
+			t.Errorf("%s: got nil, want error", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/decimal256"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+// ipcEOSLength is the length of the end-of-stream marker of the Arrow IPC
+// stream format.
+const ipcEOSLength = 8
+
+// arrowSchema returns the Arrow schema that the Storage Read API uses for
+// rows of the schema.
+func arrowSchema(schema bigquery.Schema) *arrow.Schema {
+	return arrow.NewSchema(arrowFields(schema), nil)
+}
+
+func arrowFields(schema bigquery.Schema) []arrow.Field {
+	fields := make([]arrow.Field, len(schema))
+	for i, fs := range schema {
+		fields[i] = arrow.Field{Name: fs.Name, Type: arrowType(fs), Nullable: !fs.Required}
+	}
+	return fields
+}
+
+func arrowType(fs *bigquery.FieldSchema) arrow.DataType {
+	if fs.Repeated {
+		elem := *fs
+		elem.Repeated = false
+		return arrow.ListOf(arrowType(&elem))
+	}
+	switch fs.Type {
+	case bigquery.IntegerFieldType:
+		return arrow.PrimitiveTypes.Int64
+	case bigquery.FloatFieldType:
+		return arrow.PrimitiveTypes.Float64
+	case bigquery.BooleanFieldType:
+		return arrow.FixedWidthTypes.Boolean
+	case bigquery.BytesFieldType:
+		return arrow.BinaryTypes.Binary
+	case bigquery.NumericFieldType:
+		return &arrow.Decimal128Type{Precision: 38, Scale: int32(bigquery.NumericScaleDigits)}
+	case bigquery.BigNumericFieldType:
+		return &arrow.Decimal256Type{Precision: 76, Scale: int32(bigquery.BigNumericScaleDigits)}
+	case bigquery.DateFieldType:
+		return arrow.FixedWidthTypes.Date32
+	case bigquery.TimeFieldType:
+		return arrow.FixedWidthTypes.Time64us
+	case bigquery.DateTimeFieldType:
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case bigquery.TimestampFieldType:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case bigquery.RecordFieldType:
+		return arrow.StructOf(arrowFields(fs.Schema)...)
+	case bigquery.RangeFieldType:
+		elem := &bigquery.FieldSchema{Type: bigquery.DateFieldType}
+		if fs.RangeElementType != nil {
+			elem.Type = fs.RangeElementType.Type
+		}
+		return arrow.StructOf(
+			arrow.Field{Name: "start", Type: arrowType(elem), Nullable: true},
+			arrow.Field{Name: "end", Type: arrowType(elem), Nullable: true},
+		)
+	}
+	// STRING, JSON, GEOGRAPHY and INTERVAL values are sent as strings.
+	return arrow.BinaryTypes.String
+}
+
+// serializeArrowSchema returns the IPC serialized schema, as sent in read
+// sessions.
+func serializeArrowSchema(as *arrow.Schema) ([]byte, error) {
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(as))
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes()[:buf.Len()-ipcEOSLength], nil
+}
+
+// serializeArrowRows returns the IPC serialized record batch of rows, without
+// the schema that precedes it in a stream.
+func serializeArrowRows(as *arrow.Schema, schema bigquery.Schema, rows [][]bigquery.Value) ([]byte, error) {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, as)
+	defer b.Release()
+	for _, row := range rows {
+		for i, fs := range schema {
+			appendArrow(b.Field(i), row[i], fs)
+		}
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+	header, err := serializeArrowSchema(as)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(as))
+	if err := w.Write(rec); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes()[len(header) : buf.Len()-ipcEOSLength], nil
+}
+
+func appendArrow(b array.Builder, v bigquery.Value, fs *bigquery.FieldSchema) {
+	if v == nil {
+		b.AppendNull()
+		return
+	}
+	if fs.Repeated {
+		lb := b.(*array.ListBuilder)
+		lb.Append(true)
+		elem := *fs
+		elem.Repeated = false
+		for _, e := range v.([]bigquery.Value) {
+			appendArrow(lb.ValueBuilder(), e, &elem)
+		}
+		return
+	}
+	switch b := b.(type) {
+	case *array.Int64Builder:
+		b.Append(v.(int64))
+	case *array.Float64Builder:
+		b.Append(v.(float64))
+	case *array.BooleanBuilder:
+		b.Append(v.(bool))
+	case *array.BinaryBuilder:
+		b.Append(v.([]byte))
+	case *array.StringBuilder:
+		b.Append(formatScalar(v, fs.Type, false))
+	case *array.Decimal128Builder:
+		b.Append(decimal128.FromBigInt(scaledInt(v.(*big.Rat), bigquery.NumericScaleDigits)))
+	case *array.Decimal256Builder:
+		b.Append(decimal256.FromBigInt(scaledInt(v.(*big.Rat), bigquery.BigNumericScaleDigits)))
+	case *array.Date32Builder:
+		b.Append(arrow.Date32(v.(civil.Date).DaysSince(epochDate)))
+	case *array.Time64Builder:
+		t := v.(civil.Time)
+		b.Append(arrow.Time64((int64(t.Hour)*3600+int64(t.Minute)*60+int64(t.Second))*1e6 + int64(t.Nanosecond)/1e3))
+	case *array.TimestampBuilder:
+		var t time.Time
+		switch v := v.(type) {
+		case time.Time:
+			t = v
+		case civil.DateTime:
+			t = v.In(time.UTC)
+		}
+		b.Append(arrow.Timestamp(t.UnixMicro()))
+	case *array.StructBuilder:
+		b.Append(true)
+		var fields []bigquery.Value
+		var schema bigquery.Schema
+		if rv, ok := v.(*bigquery.RangeValue); ok {
+			elem := &bigquery.FieldSchema{Type: fs.RangeElementType.Type}
+			fields, schema = []bigquery.Value{rv.Start, rv.End}, bigquery.Schema{elem, elem}
+		} else {
+			fields, schema = v.([]bigquery.Value), fs.Schema
+		}
+		for i, f := range schema {
+			appendArrow(b.FieldBuilder(i), fields[i], f)
+		}
+	}
+}
+
+var epochDate = civil.Date{Year: 1970, Month: 1, Day: 1}
+
+// scaledInt returns r multiplied by 10^scale, as an integer.
+func scaledInt(r *big.Rat, scale int64) *big.Int {
+	m := new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)
+	x := new(big.Rat).Mul(r, new(big.Rat).SetInt(m))
+	return new(big.Int).Quo(x.Num(), x.Denom())
+}
+
+// decodeArrowRows decodes the rows of a serialized record batch, as sent in
+// AppendRows requests, for a table with the schema.
+func decodeArrowRows(serializedSchema, batch []byte, schema bigquery.Schema) ([][]bigquery.Value, error) {
+	data := append(slices.Clone(serializedSchema), batch...)
+	r, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid arrow schema: %v", err)
+	}
+	defer r.Release()
+	if err := bigquery.ValidateArrowSchema(r.Schema(), schema); err != nil {
+		return nil, err
+	}
+	var rows [][]bigquery.Value
+	for r.Next() {
+		rec := r.Record()
+		idx := make([]int, rec.NumCols())
+		for c, f := range rec.Schema().Fields() {
+			idx[c] = fieldIndex(schema, f.Name)
+		}
+		for i := 0; i < int(rec.NumRows()); i++ {
+			row := make([]bigquery.Value, len(schema))
+			for c, col := range rec.Columns() {
+				v, err := arrowValue(col, i, schema[idx[c]])
+				if err != nil {
+					return nil, err
+				}
+				row[idx[c]] = v
+			}
+			if err := completeRow(row, schema); err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := r.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid arrow record batch: %v", err)
+	}
+	return rows, nil
+}
+
+// completeRow sets missing REPEATED values to empty arrays and checks that
+// REQUIRED values are present.
+func completeRow(row []bigquery.Value, schema bigquery.Schema) error {
+	for i, fs := range schema {
+		switch {
+		case row[i] != nil:
+		case fs.Repeated:
+			row[i] = []bigquery.Value{}
+		case fs.Required:
+			return fmt.Errorf("missing required field %s", fs.Name)
+		}
+	}
+	return nil
+}
+
+func arrowValue(col arrow.Array, i int, fs *bigquery.FieldSchema) (bigquery.Value, error) {
+	if fs.Repeated {
+		out := []bigquery.Value{}
+		l, ok := col.(*array.List)
+		if !ok {
+			return nil, fmt.Errorf("field %s: got arrow type %s, want a list", fs.Name, col.DataType())
+		}
+		if l.IsNull(i) {
+			return out, nil
+		}
+		elem := *fs
+		elem.Repeated = false
+		start, end := l.ValueOffsets(i)
+		for k := start; k < end; k++ {
+			v, err := arrowValue(l.ListValues(), int(k), &elem)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, fmt.Errorf("field %s: arrays cannot have NULL elements", fs.Name)
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	if col.IsNull(i) {
+		return nil, nil
+	}
+	switch a := col.(type) {
+	case *array.Boolean:
+		return a.Value(i), nil
+	case *array.Int8:
+		return int64(a.Value(i)), nil
+	case *array.Int16:
+		return int64(a.Value(i)), nil
+	case *array.Int32:
+		return int64(a.Value(i)), nil
+	case *array.Int64:
+		return a.Value(i), nil
+	case *array.Float16:
+		return float64(a.Value(i).Float32()), nil
+	case *array.Float32:
+		return float64(a.Value(i)), nil
+	case *array.Float64:
+		return a.Value(i), nil
+	case *array.Binary:
+		return slices.Clone(a.Value(i)), nil
+	case *array.String:
+		s := a.Value(i)
+		switch fs.Type {
+		case bigquery.StringFieldType, bigquery.JSONFieldType, bigquery.GeographyFieldType:
+			return s, nil
+		}
+		return parseText(s, fs)
+	case *array.Date32:
+		return civil.DateOf(a.Value(i).ToTime()), nil
+	case *array.Date64:
+		return civil.DateOf(a.Value(i).ToTime()), nil
+	case *array.Timestamp:
+		t := a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit)
+		if fs.Type == bigquery.DateTimeFieldType {
+			return civil.DateTimeOf(t), nil
+		}
+		return t.UTC().Truncate(time.Microsecond), nil
+	case *array.Time32:
+		return civil.TimeOf(a.Value(i).ToTime(a.DataType().(*arrow.Time32Type).Unit)), nil
+	case *array.Time64:
+		return civil.TimeOf(a.Value(i).ToTime(a.DataType().(*arrow.Time64Type).Unit)), nil
+	case *array.Decimal128:
+		r, _ := new(big.Rat).SetString(a.Value(i).ToString(a.DataType().(*arrow.Decimal128Type).Scale))
+		return r, nil
+	case *array.Decimal256:
+		r, _ := new(big.Rat).SetString(a.Value(i).ToString(a.DataType().(*arrow.Decimal256Type).Scale))
+		return r, nil
+	case *array.Struct:
+		st := a.DataType().(*arrow.StructType)
+		if fs.Type == bigquery.RangeFieldType {
+			elem := &bigquery.FieldSchema{Name: fs.Name, Type: fs.RangeElementType.Type}
+			start, err := arrowValue(a.Field(0), i, elem)
+			if err != nil {
+				return nil, err
+			}
+			end, err := arrowValue(a.Field(1), i, elem)
+			if err != nil {
+				return nil, err
+			}
+			return &bigquery.RangeValue{Start: start, End: end}, nil
+		}
+		row := make([]bigquery.Value, len(fs.Schema))
+		for k, f := range st.Fields() {
+			j := fieldIndex(fs.Schema, f.Name)
+			v, err := arrowValue(a.Field(k), i, fs.Schema[j])
+			if err != nil {
+				return nil, err
+			}
+			row[j] = v
+		}
+		if err := completeRow(row, fs.Schema); err != nil {
+			return nil, err
+		}
+		return row, nil
+	}
+	return nil, fmt.Errorf("field %s: unsupported arrow type %s", fs.Name, col.DataType())
+}
+
+// decodeArrowSchema decodes an IPC serialized schema.
+func decodeArrowSchema(serialized []byte) (*arrow.Schema, error) {
+	r, err := ipc.NewReader(bytes.NewReader(serialized))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Release()
+	return r.Schema(), nil
+}
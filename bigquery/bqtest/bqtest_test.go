@@ -0,0 +1,595 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+type person struct {
+	Name string
+	Age  int64
+}
+
+func newTestClient(t *testing.T, opts ...ServerOption) (*Server, *bigquery.Client) {
+	t.Helper()
+	srv := NewServer(opts...)
+	t.Cleanup(func() { srv.Close() })
+	client, err := srv.NewClient(context.Background(), "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return srv, client
+}
+
+// newTable creates the dataset d and a table in it.
+func newTable(t *testing.T, client *bigquery.Client, name string, schema bigquery.Schema) *bigquery.Table {
+	t.Helper()
+	ctx := context.Background()
+	ds := client.Dataset("d")
+	if _, err := ds.Metadata(ctx); err != nil {
+		if err := ds.Create(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tbl := ds.Table(name)
+	if err := tbl.Create(ctx, &bigquery.TableMetadata{Schema: schema}); err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func readAll(t *testing.T, it *bigquery.RowIterator) [][]bigquery.Value {
+	t.Helper()
+	var rows [][]bigquery.Value
+	for {
+		var row []bigquery.Value
+		err := it.Next(&row)
+		if err == iterator.Done {
+			return rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func errorCode(err error) int {
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return 0
+}
+
+func TestDatasetsAndTables(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	ds := client.Dataset("d")
+	if err := ds.Create(ctx, &bigquery.DatasetMetadata{Description: "desc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Create(ctx, nil); errorCode(err) != http.StatusConflict {
+		t.Errorf("creating an existing dataset: got %v, want a 409 error", err)
+	}
+	md, err := ds.Update(ctx, bigquery.DatasetMetadataToUpdate{Description: "new"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Description != "new" {
+		t.Errorf("got description %q, want %q", md.Description, "new")
+	}
+
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType, Required: true},
+		{Name: "age", Type: bigquery.IntegerFieldType},
+	}
+	tbl := ds.Table("t")
+	if err := tbl.Create(ctx, &bigquery.TableMetadata{Schema: schema}); err != nil {
+		t.Fatal(err)
+	}
+	tmd, err := tbl.Update(ctx, bigquery.TableMetadataToUpdate{
+		Schema: append(schema, &bigquery.FieldSchema{Name: "city", Type: bigquery.StringFieldType}),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tmd.Schema); got != 3 {
+		t.Errorf("got %d columns after update, want 3", got)
+	}
+	if _, err := tbl.Update(ctx, bigquery.TableMetadataToUpdate{Schema: schema[:1]}, ""); err == nil {
+		t.Error("removing a column: got nil, want error")
+	}
+
+	var tables []string
+	it := ds.Tables(ctx)
+	for {
+		tb, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, tb.TableID)
+	}
+	if diff := cmp.Diff([]string{"t"}, tables); diff != "" {
+		t.Errorf("tables differ (-want +got):\n%s", diff)
+	}
+
+	if err := ds.Delete(ctx); err == nil {
+		t.Error("deleting a non-empty dataset: got nil, want error")
+	}
+	if err := tbl.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tbl.Metadata(ctx); errorCode(err) != http.StatusNotFound {
+		t.Errorf("getting a deleted table: got %v, want a 404 error", err)
+	}
+	if err := ds.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInsertAndRead(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType, Required: true},
+		{Name: "age", Type: bigquery.IntegerFieldType},
+	}
+	tbl := newTable(t, client, "t", schema)
+	rows := []*bigquery.ValuesSaver{
+		{Schema: schema, Row: []bigquery.Value{"alice", 30}},
+		{Schema: schema, Row: []bigquery.Value{"bob", nil}},
+	}
+	if err := tbl.Inserter().Put(ctx, rows); err != nil {
+		t.Fatal(err)
+	}
+	err := tbl.Inserter().Put(ctx, &bigquery.ValuesSaver{Schema: schema, Row: []bigquery.Value{nil, 1}})
+	var multi bigquery.PutMultiError
+	if !errors.As(err, &multi) || len(multi) != 1 {
+		t.Errorf("inserting a NULL in a REQUIRED column: got %v, want a PutMultiError", err)
+	}
+
+	want := [][]bigquery.Value{{"alice", int64(30)}, {"bob", nil}}
+	if diff := cmp.Diff(want, readAll(t, tbl.Read(ctx))); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+	it := tbl.Read(ctx)
+	var p person
+	if err := it.Next(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p != (person{"alice", 30}) {
+		t.Errorf("got %+v, want alice", p)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "age", Type: bigquery.IntegerFieldType},
+	}
+	tbl := newTable(t, client, "people", schema)
+	var rows []*bigquery.ValuesSaver
+	for i, name := range []string{"alice", "bob", "carol"} {
+		rows = append(rows, &bigquery.ValuesSaver{Schema: schema, Row: []bigquery.Value{name, 20 + 5*i}})
+	}
+	if err := tbl.Inserter().Put(ctx, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	q := client.Query("SELECT name, age FROM d.people WHERE age > @min ORDER BY age DESC")
+	q.Parameters = []bigquery.QueryParameter{{Name: "min", Value: 22}}
+	it, err := q.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bigquery.Value{{"carol", int64(30)}, {"bob", int64(25)}}
+	if diff := cmp.Diff(want, readAll(t, it)); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+	if it.Schema[1].Type != bigquery.IntegerFieldType {
+		t.Errorf("got schema %v, want an INTEGER column", it.Schema)
+	}
+
+	// A query with a destination table.
+	q = client.Query("SELECT COUNT(*) AS n FROM people")
+	q.DefaultDatasetID = "d"
+	q.Dst = client.Dataset("d").Table("counts")
+	job, err := q.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := status.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([][]bigquery.Value{{int64(3)}}, readAll(t, q.Dst.Read(ctx))); diff != "" {
+		t.Errorf("destination rows differ (-want +got):\n%s", diff)
+	}
+
+	if _, err := client.Query("SELECT nope FROM d.people").Read(ctx); err == nil {
+		t.Error("query with an unknown column: got nil, want error")
+	}
+}
+
+func TestLoadAndCopy(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "n", Type: bigquery.IntegerFieldType},
+	}
+	src := newTable(t, client, "src", schema)
+
+	csv := bigquery.NewReaderSource(strings.NewReader("name,n\na,1\nb,2\n"))
+	csv.SkipLeadingRows = 1
+	run := func(j interface {
+		Run(context.Context) (*bigquery.Job, error)
+	}) {
+		t.Helper()
+		job, err := j.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := job.Wait(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := status.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(src.LoaderFrom(csv))
+	json := bigquery.NewReaderSource(strings.NewReader(`{"name": "c", "n": 3}` + "\n"))
+	json.SourceFormat = bigquery.JSON
+	run(src.LoaderFrom(json))
+
+	want := [][]bigquery.Value{{"a", int64(1)}, {"b", int64(2)}, {"c", int64(3)}}
+	if diff := cmp.Diff(want, readAll(t, src.Read(ctx))); diff != "" {
+		t.Errorf("loaded rows differ (-want +got):\n%s", diff)
+	}
+
+	dst := client.Dataset("d").Table("dst")
+	run(dst.CopierFrom(src))
+	if diff := cmp.Diff(want, readAll(t, dst.Read(ctx))); diff != "" {
+		t.Errorf("copied rows differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestStorageRead(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "ts", Type: bigquery.TimestampFieldType},
+		{Name: "rec", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "x", Type: bigquery.FloatFieldType},
+		}},
+	}
+	tbl := newTable(t, client, "t", schema)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	var rows []*bigquery.ValuesSaver
+	var want [][]bigquery.Value
+	for i := range 2500 {
+		row := []bigquery.Value{"r", []bigquery.Value{"a"}, ts, []bigquery.Value{float64(i)}}
+		rows = append(rows, &bigquery.ValuesSaver{Schema: schema, Row: row})
+		want = append(want, row)
+	}
+	if err := tbl.Inserter().Put(ctx, rows); err != nil {
+		t.Fatal(err)
+	}
+	it := tbl.Read(ctx)
+	got := readAll(t, it)
+	if !it.IsAccelerated() {
+		t.Error("read did not use the Storage Read API")
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestManagedWriter(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestClient(t)
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "age", Type: bigquery.IntegerFieldType},
+	}
+	tbl := newTable(t, client, "t", schema)
+	mw, err := managedwriter.NewClient(ctx, "p", srv.StorageClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close()
+	dest := managedwriter.TableParentFromParts("p", "d", "t")
+
+	// The default stream commits rows immediately.
+	w, err := managedwriter.NewStructWriter[person](ctx, mw, schema, managedwriter.WithDestinationTable(dest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := w.Append(ctx, []person{{"alice", 30}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.GetResult(ctx); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	// A pending stream commits rows when it is finalized and committed.
+	w, err = managedwriter.NewStructWriter[person](ctx, mw, schema,
+		managedwriter.WithDestinationTable(dest), managedwriter.WithType(managedwriter.PendingStream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = w.Append(ctx, []person{{"bob", 25}, {"carol", 35}}, managedwriter.WithOffset(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.GetResult(ctx); err != nil {
+		t.Fatal(err)
+	}
+	res, err = w.Append(ctx, []person{{"dave", 40}}, managedwriter.WithOffset(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.GetResult(ctx); err == nil {
+		t.Error("append past the end of the stream: got nil, want error")
+	}
+	n := len(readAll(t, tbl.Read(ctx)))
+	if n != 1 {
+		t.Errorf("before commit: got %d rows, want 1", n)
+	}
+	if _, err := w.Stream().Finalize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := mw.BatchCommitWriteStreams(ctx, &storagepb.BatchCommitWriteStreamsRequest{
+		Parent:       dest,
+		WriteStreams: []string{w.Stream().StreamName()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetStreamErrors()) > 0 {
+		t.Fatalf("commit errors: %v", resp.GetStreamErrors())
+	}
+	w.Close()
+
+	q := client.Query("SELECT name FROM d.t ORDER BY age")
+	it, err := q.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bigquery.Value{{"bob"}, {"alice"}, {"carol"}}
+	if diff := cmp.Diff(want, readAll(t, it)); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestJSONWriterSchemaUpdate(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newTestClient(t)
+	schema := bigquery.Schema{{Name: "name", Type: bigquery.StringFieldType}}
+	tbl := newTable(t, client, "t", schema)
+	mw, err := managedwriter.NewClient(ctx, "p", srv.StorageClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close()
+	w, err := managedwriter.NewJSONWriter(ctx, mw, managedwriter.WithDestinationTable(managedwriter.TableParentFromParts("p", "d", "t")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := tbl.Update(ctx, bigquery.TableMetadataToUpdate{
+		Schema: append(schema, &bigquery.FieldSchema{Name: "n", Type: bigquery.IntegerFieldType}),
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+	res, err := w.Append(ctx, []any{`{"name": "a"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.GetResult(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := res.UpdatedSchema(ctx); err != nil || s == nil {
+		t.Fatalf("got updated schema %v, %v; want the new schema", s, err)
+	}
+	// Wait for the writer to pick up the new schema.
+	for len(w.Schema()) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	res, err = w.Append(ctx, []any{`{"name": "b", "n": 2}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.GetResult(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]bigquery.Value{{"a", nil}, {"b", int64(2)}}
+	if diff := cmp.Diff(want, readAll(t, tbl.Read(ctx))); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanSchemaUpdate(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	tbl := newTable(t, client, "t", bigquery.Schema{
+		{Name: "Name", Type: bigquery.StringFieldType, Required: true},
+	})
+	schema, err := bigquery.InferSchema(struct {
+		Name string
+		Age  bigquery.NullInt64
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := tbl.PlanSchemaUpdate(ctx, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(plan.Breaking()); n != 0 {
+		t.Fatalf("got %d breaking changes, want 0", n)
+	}
+	tm, ok := plan.Update()
+	if !ok {
+		t.Fatal("got no update, want one")
+	}
+	md, err := tbl.Update(ctx, tm, plan.ETag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Schema) != 2 {
+		t.Errorf("got schema %v, want 2 columns", md.Schema)
+	}
+	// The plan is stale once the table is updated.
+	if _, err := tbl.Update(ctx, tm, plan.ETag); errorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("update with a stale ETag: got %v, want a 412 error", err)
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+	}
+	tbl := newTable(t, client, "t", schema)
+	if err := tbl.Inserter().Put(ctx, []*bigquery.ValuesSaver{
+		{Schema: schema, Row: []bigquery.Value{"a", []bigquery.Value{"x"}}},
+		{Schema: schema, Row: []bigquery.Value{"b", nil}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"a","tags":["x"]}` + "\n" + `{"name":"b","tags":[]}` + "\n"
+
+	var buf strings.Builder
+	fw, err := bigquery.NewFileWriter(&buf, bigquery.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := client.Query("SELECT * FROM d.t ORDER BY name").Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.WriteIterator(it); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("from rows: got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if fw, err = bigquery.NewFileWriter(&buf, bigquery.JSON); err != nil {
+		t.Fatal(err)
+	}
+	ai, err := tbl.Read(ctx).ArrowIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.WriteArrowIterator(ai); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("from arrow: got %q, want %q", got, want)
+	}
+}
+
+func TestGeography(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	type place struct {
+		Name string
+		Loc  *bigquery.Geography `bigquery:",nullable"`
+	}
+	schema, err := bigquery.InferSchema(place{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := newTable(t, client, "places", schema)
+	if err := tbl.Inserter().Put(ctx, []place{
+		{Name: "a", Loc: &bigquery.Geography{Geometry: bigquery.Point{Lng: -122.35, Lat: 47.62}}},
+		{Name: "b"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	q := client.Query("SELECT name, loc FROM d.places WHERE name = 'a' UNION ALL SELECT 'c', @g ORDER BY name")
+	q.Parameters = []bigquery.QueryParameter{{
+		Name:  "g",
+		Value: &bigquery.Geography{Geometry: bigquery.LineString{{Lng: 0, Lat: 0}, {Lng: 1, Lat: 1}}},
+	}}
+	it, err := q.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		var p place
+		err := it.Next(&p)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p.Name+" "+p.Loc.WKT())
+	}
+	want := []string{"a POINT(-122.35 47.62)", "c LINESTRING(0 0, 1 1)"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+
+	// Read as []Value, GEOGRAPHY values remain WKT strings.
+	wantRows := [][]bigquery.Value{{"a", "POINT(-122.35 47.62)"}, {"b", nil}}
+	if diff := cmp.Diff(wantRows, readAll(t, tbl.Read(ctx))); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	bq "google.golang.org/api/bigquery/v2"
+)
+
+// An Engine evaluates the queries run against a Server.
+//
+// Values in parameters, tables and results use the Go types that the
+// bigquery package reads, except that a RECORD is a []bigquery.Value and a
+// REPEATED value is a non-nil []bigquery.Value.
+type Engine interface {
+	// Query evaluates a query. It is called while the server's state is
+	// locked, so it must read tables only through req.Table.
+	Query(req *QueryRequest) (*QueryResult, error)
+}
+
+// QueryRequest is a query to be evaluated by an Engine.
+type QueryRequest struct {
+	// SQL is the text of the query.
+	SQL string
+
+	// DefaultProjectID and DefaultDatasetID qualify table names that omit
+	// them.
+	DefaultProjectID string
+	DefaultDatasetID string
+
+	// Params are the query parameters. Positional parameters have no name.
+	Params []QueryParam
+
+	// Now is the time at which the query runs.
+	Now time.Time
+
+	// Table returns the schema and rows of a table or view. The rows must
+	// not be modified.
+	Table func(projectID, datasetID, tableID string) (bigquery.Schema, [][]bigquery.Value, error)
+}
+
+// QueryParam is a query parameter.
+type QueryParam struct {
+	// Name is the name of a named parameter, without the @ sign.
+	Name string
+	// Type is the type of the parameter. Its name is ignored.
+	Type *bigquery.FieldSchema
+	// Value is the parameter's value.
+	Value bigquery.Value
+}
+
+// QueryResult is the result of a query.
+type QueryResult struct {
+	Schema bigquery.Schema
+	Rows   [][]bigquery.Value
+}
+
+// maxViewDepth bounds the nesting of views, which would otherwise recurse
+// forever on a view that refers to itself.
+const maxViewDepth = 16
+
+// runQueryLocked evaluates a query over the server's tables. s.mu must be
+// held.
+func (s *Server) runQueryLocked(req *QueryRequest) (*QueryResult, error) {
+	return s.runQueryDepth(req, 0)
+}
+
+func (s *Server) runQueryDepth(req *QueryRequest, depth int) (*QueryResult, error) {
+	if depth > maxViewDepth {
+		return nil, invalidf("Views are nested too deeply")
+	}
+	if req.Now.IsZero() {
+		req.Now = s.now()
+	}
+	req.Table = func(projectID, datasetID, tableID string) (bigquery.Schema, [][]bigquery.Value, error) {
+		t, err := s.lookupTable(projectID, datasetID, tableID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !t.isView() {
+			return t.schema, t.rows, nil
+		}
+		res, err := s.runQueryDepth(&QueryRequest{
+			SQL:              t.md.View.Query,
+			DefaultProjectID: projectID,
+			DefaultDatasetID: datasetID,
+			Now:              req.Now,
+		}, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Schema, res.Rows, nil
+	}
+	res, err := s.engine.Query(req)
+	if err != nil {
+		if _, ok := err.(*apiError); ok {
+			return nil, err
+		}
+		return nil, &apiError{code: http.StatusBadRequest, reason: "invalidQuery", message: err.Error()}
+	}
+	return res, nil
+}
+
+// paramsFromBQ converts REST query parameters.
+func paramsFromBQ(ps []*bq.QueryParameter) ([]QueryParam, error) {
+	var out []QueryParam
+	for _, p := range ps {
+		if p.ParameterType == nil {
+			return nil, invalidf("Query parameter %q has no type", p.Name)
+		}
+		typ, err := paramTypeFromBQ(p.ParameterType)
+		if err != nil {
+			return nil, err
+		}
+		v, err := paramValueFromBQ(p.ParameterValue, typ)
+		if err != nil {
+			return nil, invalidf("Query parameter %q: %v", p.Name, err)
+		}
+		out = append(out, QueryParam{Name: p.Name, Type: typ, Value: v})
+	}
+	return out, nil
+}
+
+func paramTypeFromBQ(pt *bq.QueryParameterType) (*bigquery.FieldSchema, error) {
+	switch strings.ToUpper(pt.Type) {
+	case "ARRAY":
+		if pt.ArrayType == nil {
+			return nil, invalidf("Array parameter type has no element type")
+		}
+		elem, err := paramTypeFromBQ(pt.ArrayType)
+		if err != nil {
+			return nil, err
+		}
+		if elem.Repeated {
+			return nil, invalidf("Arrays of arrays are not supported")
+		}
+		elem.Repeated = true
+		return elem, nil
+	case "STRUCT":
+		fs := &bigquery.FieldSchema{Type: bigquery.RecordFieldType}
+		for _, st := range pt.StructTypes {
+			f, err := paramTypeFromBQ(st.Type)
+			if err != nil {
+				return nil, err
+			}
+			f.Name = st.Name
+			fs.Schema = append(fs.Schema, f)
+		}
+		return fs, nil
+	case "RANGE":
+		if pt.RangeElementType == nil {
+			return nil, invalidf("Range parameter type has no element type")
+		}
+		elem, err := typeFromName(pt.RangeElementType.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &bigquery.FieldSchema{Type: bigquery.RangeFieldType, RangeElementType: &bigquery.RangeElementType{Type: elem}}, nil
+	}
+	t, err := typeFromName(pt.Type)
+	if err != nil {
+		return nil, err
+	}
+	return &bigquery.FieldSchema{Type: t}, nil
+}
+
+// typeNames maps the names of scalar types, including their aliases, to
+// field types.
+var typeNames = map[string]bigquery.FieldType{
+	"STRING":     bigquery.StringFieldType,
+	"BYTES":      bigquery.BytesFieldType,
+	"INT64":      bigquery.IntegerFieldType,
+	"INTEGER":    bigquery.IntegerFieldType,
+	"INT":        bigquery.IntegerFieldType,
+	"SMALLINT":   bigquery.IntegerFieldType,
+	"BIGINT":     bigquery.IntegerFieldType,
+	"TINYINT":    bigquery.IntegerFieldType,
+	"BYTEINT":    bigquery.IntegerFieldType,
+	"FLOAT64":    bigquery.FloatFieldType,
+	"FLOAT":      bigquery.FloatFieldType,
+	"BOOL":       bigquery.BooleanFieldType,
+	"BOOLEAN":    bigquery.BooleanFieldType,
+	"TIMESTAMP":  bigquery.TimestampFieldType,
+	"DATE":       bigquery.DateFieldType,
+	"TIME":       bigquery.TimeFieldType,
+	"DATETIME":   bigquery.DateTimeFieldType,
+	"NUMERIC":    bigquery.NumericFieldType,
+	"DECIMAL":    bigquery.NumericFieldType,
+	"BIGNUMERIC": bigquery.BigNumericFieldType,
+	"BIGDECIMAL": bigquery.BigNumericFieldType,
+	"GEOGRAPHY":  bigquery.GeographyFieldType,
+	"JSON":       bigquery.JSONFieldType,
+	"INTERVAL":   bigquery.IntervalFieldType,
+}
+
+func typeFromName(name string) (bigquery.FieldType, error) {
+	t, ok := typeNames[strings.ToUpper(name)]
+	if !ok {
+		return "", invalidf("Unsupported type %s", name)
+	}
+	return t, nil
+}
+
+func paramValueFromBQ(pv *bq.QueryParameterValue, fs *bigquery.FieldSchema) (bigquery.Value, error) {
+	if fs.Repeated {
+		vs := []bigquery.Value{}
+		if pv == nil {
+			return vs, nil
+		}
+		elem := *fs
+		elem.Repeated = false
+		for _, e := range pv.ArrayValues {
+			v, err := paramValueFromBQ(e, &elem)
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+		return vs, nil
+	}
+	if pv == nil {
+		return nil, nil
+	}
+	switch fs.Type {
+	case bigquery.RecordFieldType:
+		if pv.StructValues == nil {
+			return nil, nil
+		}
+		row := make([]bigquery.Value, len(fs.Schema))
+		for i, f := range fs.Schema {
+			if sv, ok := pv.StructValues[f.Name]; ok {
+				v, err := paramValueFromBQ(&sv, f)
+				if err != nil {
+					return nil, err
+				}
+				row[i] = v
+			}
+		}
+		return row, nil
+	case bigquery.RangeFieldType:
+		if pv.RangeValue == nil {
+			return nil, nil
+		}
+		elem := &bigquery.FieldSchema{Type: fs.RangeElementType.Type}
+		rv := &bigquery.RangeValue{}
+		var err error
+		if rv.Start, err = paramValueFromBQ(pv.RangeValue.Start, elem); err != nil {
+			return nil, err
+		}
+		if rv.End, err = paramValueFromBQ(pv.RangeValue.End, elem); err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+	// The API sends a NULL value as an absent one, which is indistinguishable
+	// from an empty string.
+	if pv.Value == "" && fs.Type != bigquery.StringFieldType && fs.Type != bigquery.BytesFieldType {
+		return nil, nil
+	}
+	v, err := parseText(pv.Value, fs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q", fs.Type, pv.Value)
+	}
+	return v, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest_test
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/bqtest"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+)
+
+func ExampleNewServer() {
+	ctx := context.Background()
+	// Start a fake server running locally.
+	srv := bqtest.NewServer()
+	defer srv.Close()
+	// Create a client connected to the server.
+	client, err := bigquery.NewClient(ctx, "project", srv.ClientOptions()...)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer client.Close()
+	// Optionally, read tables with the Storage Read API.
+	if err := client.EnableStorageReadClient(ctx, srv.StorageClientOptions()...); err != nil {
+		// TODO: Handle error.
+	}
+	_ = client // TODO: Use the client.
+}
+
+func ExampleServer_StorageClientOptions() {
+	ctx := context.Background()
+	srv := bqtest.NewServer()
+	defer srv.Close()
+	// Use the options when creating a managedwriter client.
+	client, err := managedwriter.NewClient(ctx, "project", srv.StorageClientOptions()...)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer client.Close()
+	_ = client // TODO: Use the client.
+}
@@ -0,0 +1,912 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/bigquery"
+	bq "google.golang.org/api/bigquery/v2"
+)
+
+// anonymousDataset holds the results of queries without a destination table.
+// Its name begins with an underscore, so it is hidden from dataset lists.
+const anonymousDataset = "_bqtest_anonymous"
+
+type jobKey struct {
+	projectID, jobID string
+}
+
+// job is a completed job. Jobs run synchronously when they are inserted.
+type job struct {
+	md  *bq.Job
+	seq int
+}
+
+// upload is a resumable upload of the data of a load job.
+type upload struct {
+	projectID string
+	md        *bq.Job
+	data      []byte
+}
+
+func (s *Server) insertJob(r *http.Request) (any, error) {
+	md := &bq.Job{}
+	if err := readBody(r, md); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runJob(r.PathValue("project"), md, nil)
+}
+
+// uploadJob inserts a load job with media, sent as a multipart upload or in
+// the chunks of a resumable upload.
+func (s *Server) uploadJob(r *http.Request) (any, error) {
+	projectID := r.PathValue("project")
+	q := r.URL.Query()
+	if id := q.Get("upload_id"); id != "" {
+		return s.uploadChunk(r, id)
+	}
+	switch q.Get("uploadType") {
+	case "multipart":
+		md, data, err := readMultipart(r)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.runJob(projectID, md, data)
+	case "resumable":
+		md := &bq.Job{}
+		if err := readBody(r, md); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := s.newID("upload")
+		s.uploads[id] = &upload{projectID: projectID, md: md}
+		loc := fmt.Sprintf("%s%sprojects/%s/jobs?uploadType=resumable&upload_id=%s", s.URL, uploadPrefix, projectID, id)
+		return &rawResponse{code: http.StatusOK, header: http.Header{"Location": {loc}}}, nil
+	}
+	return nil, invalidf("unsupported upload type %q", q.Get("uploadType"))
+}
+
+func readMultipart(r *http.Request) (*bq.Job, []byte, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, invalidf("invalid multipart upload: %v", err)
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	var parts [][]byte
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, invalidf("invalid multipart upload: %v", err)
+		}
+		b, err := io.ReadAll(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, b)
+	}
+	if len(parts) != 2 {
+		return nil, nil, invalidf("invalid multipart upload: got %d parts, want 2", len(parts))
+	}
+	md := &bq.Job{}
+	if err := json.Unmarshal(parts[0], md); err != nil {
+		return nil, nil, invalidf("invalid job: %v", err)
+	}
+	return md, parts[1], nil
+}
+
+// uploadChunk appends a chunk to a resumable upload, and runs the job once
+// all of the data has arrived.
+func (s *Server) uploadChunk(r *http.Request, id string) (any, error) {
+	chunk, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[id]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not found: upload %s", id)
+	}
+	// The Content-Range header has the form "bytes first-last/total", where
+	// the range is "*" for a request without data and the total is "*" until
+	// it is known.
+	total := -1
+	if cr := r.Header.Get("Content-Range"); cr != "" {
+		_, size, _ := strings.Cut(cr, "/")
+		if size != "*" {
+			if total, err = strconv.Atoi(size); err != nil {
+				return nil, invalidf("invalid Content-Range %q", cr)
+			}
+		}
+	} else {
+		total = len(u.data) + len(chunk)
+	}
+	u.data = append(u.data, chunk...)
+	if total < 0 || len(u.data) < total {
+		h := http.Header{}
+		if len(u.data) > 0 {
+			h.Set("Range", fmt.Sprintf("bytes=0-%d", len(u.data)-1))
+		}
+		return &rawResponse{code: http.StatusPermanentRedirect, header: h}, nil
+	}
+	delete(s.uploads, id)
+	return s.runJob(u.projectID, u.md, u.data)
+}
+
+// runJob runs a job to completion. A job that fails is still created, with
+// the error in its status. s.mu must be held.
+func (s *Server) runJob(projectID string, md *bq.Job, media []byte) (*bq.Job, error) {
+	if md.Configuration == nil {
+		return nil, invalidf("Job configuration is required")
+	}
+	if md.JobReference == nil {
+		md.JobReference = &bq.JobReference{}
+	}
+	ref := md.JobReference
+	if ref.ProjectId == "" {
+		ref.ProjectId = projectID
+	}
+	if ref.JobId == "" {
+		ref.JobId = s.newID("bqtest_job_")
+	}
+	if ref.Location == "" {
+		ref.Location = "US"
+	}
+	key := jobKey{ref.ProjectId, ref.JobId}
+	if _, ok := s.jobs[key]; ok {
+		return nil, errorf(http.StatusConflict, "duplicate", "Already Exists: Job %s:%s.%s", ref.ProjectId, ref.Location, ref.JobId)
+	}
+	now := s.nowMillis()
+	md.Kind = "bigquery#job"
+	md.Id = fmt.Sprintf("%s:%s.%s", ref.ProjectId, ref.Location, ref.JobId)
+	md.Statistics = &bq.JobStatistics{CreationTime: now, StartTime: now}
+	md.Status = &bq.JobStatus{State: "DONE"}
+
+	cfg := md.Configuration
+	var err error
+	switch {
+	case cfg.Query != nil:
+		err = s.runQueryJob(md)
+	case cfg.Load != nil:
+		err = s.runLoadJob(md, media)
+	case cfg.Copy != nil:
+		err = s.runCopyJob(md)
+	default:
+		err = invalidf("bqtest: unsupported job configuration")
+	}
+	md.Statistics.EndTime = s.nowMillis()
+	if err != nil {
+		md.Status.ErrorResult = errorProto(err)
+		md.Status.Errors = []*bq.ErrorProto{md.Status.ErrorResult}
+	}
+	if cfg.DryRun {
+		// Dry runs only validate the job.
+		return md, nil
+	}
+	s.nextID++
+	s.jobs[key] = &job{md: md, seq: s.nextID}
+	return md, nil
+}
+
+func (s *Server) getJob(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, err := s.lookupJob(r.PathValue("project"), r.PathValue("job"))
+	if err != nil {
+		return nil, err
+	}
+	return j.md, nil
+}
+
+// lookupJob returns the job, or a not found error. s.mu must be held.
+func (s *Server) lookupJob(projectID, jobID string) (*job, error) {
+	j, ok := s.jobs[jobKey{projectID, jobID}]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not found: Job %s:%s", projectID, jobID)
+	}
+	return j, nil
+}
+
+func (s *Server) listJobs(r *http.Request) (any, error) {
+	projectID := r.PathValue("project")
+	q := r.URL.Query()
+	states := q["stateFilter"]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*job
+	for key, j := range s.jobs {
+		if key.projectID != projectID || q.Get("parentJobId") != "" {
+			continue
+		}
+		if len(states) > 0 && !slices.Contains(states, strings.ToLower(j.md.Status.State)) {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	// The most recent jobs are listed first.
+	slices.SortFunc(jobs, func(a, b *job) int { return b.seq - a.seq })
+	start, end, next, err := page(q, len(jobs))
+	if err != nil {
+		return nil, err
+	}
+	list := &bq.JobList{Kind: "bigquery#jobList", NextPageToken: next}
+	for _, j := range jobs[start:end] {
+		list.Jobs = append(list.Jobs, &bq.JobListJobs{
+			Configuration: j.md.Configuration,
+			ErrorResult:   j.md.Status.ErrorResult,
+			Id:            j.md.Id,
+			JobReference:  j.md.JobReference,
+			Kind:          j.md.Kind,
+			State:         j.md.Status.State,
+			Statistics:    j.md.Statistics,
+			Status:        j.md.Status,
+			UserEmail:     j.md.UserEmail,
+		})
+	}
+	return list, nil
+}
+
+func (s *Server) cancelJob(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, err := s.lookupJob(r.PathValue("project"), r.PathValue("job"))
+	if err != nil {
+		return nil, err
+	}
+	// Jobs are done as soon as they are created, so there is nothing to
+	// cancel.
+	return &bq.JobCancelResponse{Kind: "bigquery#jobCancelResponse", Job: j.md}, nil
+}
+
+func (s *Server) deleteJob(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projectID, jobID := r.PathValue("project"), r.PathValue("job")
+	if _, err := s.lookupJob(projectID, jobID); err != nil {
+		return nil, err
+	}
+	delete(s.jobs, jobKey{projectID, jobID})
+	return nil, nil
+}
+
+// runQueryJob evaluates a query and writes its results to the destination
+// table, which is an anonymous table unless one is configured. s.mu must be
+// held.
+func (s *Server) runQueryJob(md *bq.Job) error {
+	cfg := md.Configuration.Query
+	if cfg.UseLegacySql != nil && *cfg.UseLegacySql {
+		return invalidf("bqtest: legacy SQL is not supported")
+	}
+	req := &QueryRequest{SQL: cfg.Query, DefaultProjectID: md.JobReference.ProjectId}
+	if dd := cfg.DefaultDataset; dd != nil {
+		req.DefaultDatasetID = dd.DatasetId
+		if dd.ProjectId != "" {
+			req.DefaultProjectID = dd.ProjectId
+		}
+	}
+	params, err := paramsFromBQ(cfg.QueryParameters)
+	if err != nil {
+		return err
+	}
+	req.Params = params
+	res, err := s.runQueryLocked(req)
+	if err != nil {
+		return err
+	}
+	md.Statistics.Query = &bq.JobStatistics2{
+		StatementType:       "SELECT",
+		Schema:              schemaToBQ(res.Schema),
+		TotalBytesProcessed: 0,
+		ForceSendFields:     []string{"TotalBytesProcessed"},
+	}
+	if md.Configuration.DryRun {
+		return nil
+	}
+	if cfg.DestinationTable == nil {
+		key := datasetKey{md.JobReference.ProjectId, anonymousDataset}
+		if _, ok := s.datasets[key]; !ok {
+			s.addDataset(&bq.Dataset{DatasetReference: &bq.DatasetReference{ProjectId: key.projectID, DatasetId: key.datasetID}})
+		}
+		cfg.DestinationTable = &bq.TableReference{ProjectId: key.projectID, DatasetId: key.datasetID, TableId: "anon_" + md.JobReference.JobId}
+		cfg.WriteDisposition = "WRITE_TRUNCATE"
+	}
+	_, err = s.writeTable(cfg.DestinationTable, res.Schema, res.Rows, cfg.CreateDisposition, orDefault(cfg.WriteDisposition, "WRITE_EMPTY"), cfg.SchemaUpdateOptions)
+	return err
+}
+
+// writeTable writes rows with the schema to a table, following the job's
+// create and write dispositions. A nil schema means that the rows have the
+// schema of the existing table. s.mu must be held.
+func (s *Server) writeTable(ref *bq.TableReference, schema bigquery.Schema, rows [][]bigquery.Value, createDisp, writeDisp string, updateOpts []string) (*table, error) {
+	if ref == nil || ref.TableId == "" {
+		return nil, invalidf("Destination table is required")
+	}
+	now := s.nowMillis()
+	t, err := s.lookupTable(ref.ProjectId, ref.DatasetId, ref.TableId)
+	if err != nil {
+		if createDisp == "CREATE_NEVER" {
+			return nil, err
+		}
+		if schema == nil {
+			return nil, invalidf("No schema specified on job or table.")
+		}
+		md := &bq.Table{TableReference: &bq.TableReference{ProjectId: ref.ProjectId, DatasetId: ref.DatasetId, TableId: ref.TableId}, Schema: schemaToBQ(schema)}
+		if t, err = s.createTable(md); err != nil {
+			return nil, err
+		}
+		t.rows = rows
+		t.touch(now)
+		return t, nil
+	}
+	if t.isView() {
+		return nil, invalidf("Cannot write to view %s", tableName(ref))
+	}
+	switch writeDisp {
+	case "WRITE_TRUNCATE":
+		if schema != nil {
+			t.schema = schema
+		}
+		t.rows = rows
+	case "WRITE_APPEND":
+		if schema != nil {
+			if rows, err = s.conformToTable(t, schema, rows, updateOpts); err != nil {
+				return nil, err
+			}
+		}
+		t.rows = append(t.rows, rows...)
+	default:
+		if len(t.rows) > 0 {
+			return nil, errorf(http.StatusConflict, "duplicate", "Already Exists: Table %s", tableName(ref))
+		}
+		if schema != nil {
+			t.schema = schema
+		}
+		t.rows = rows
+	}
+	t.touch(now)
+	return t, nil
+}
+
+// conformToTable converts rows appended to a table to the table's schema,
+// first adding columns or relaxing the table's columns if the schema update
+// options allow it. Columns are matched by name. s.mu must be held.
+func (s *Server) conformToTable(t *table, schema bigquery.Schema, rows [][]bigquery.Value, updateOpts []string) ([][]bigquery.Value, error) {
+	target := t.schema
+	if slices.Contains(updateOpts, "ALLOW_FIELD_ADDITION") || slices.Contains(updateOpts, "ALLOW_FIELD_RELAXATION") {
+		target = slices.Clone(target)
+		for _, fs := range schema {
+			i := fieldIndex(target, fs.Name)
+			switch {
+			case i < 0 && slices.Contains(updateOpts, "ALLOW_FIELD_ADDITION"):
+				added := *fs
+				added.Required = false
+				target = append(target, &added)
+			case i >= 0 && target[i].Required && !fs.Required && slices.Contains(updateOpts, "ALLOW_FIELD_RELAXATION"):
+				relaxed := *target[i]
+				relaxed.Required = false
+				target[i] = &relaxed
+			}
+		}
+		if err := t.setSchema(target); err != nil {
+			return nil, err
+		}
+	}
+	// Order the source columns as in the table.
+	from := make(bigquery.Schema, len(target))
+	idx := make([]int, len(target))
+	for i, fs := range target {
+		j := fieldIndex(schema, fs.Name)
+		idx[i] = j
+		if j < 0 {
+			if fs.Required {
+				return nil, invalidf("Provided Schema does not match Table %s. Field %s is missing in new schema", tableName(t.md.TableReference), fs.Name)
+			}
+			from[i] = fs
+			continue
+		}
+		from[i] = schema[j]
+	}
+	for _, fs := range schema {
+		if fieldIndex(target, fs.Name) < 0 {
+			return nil, invalidf("Provided Schema does not match Table %s. Cannot add fields (field: %s)", tableName(t.md.TableReference), fs.Name)
+		}
+	}
+	out := make([][]bigquery.Value, len(rows))
+	for r, row := range rows {
+		ordered := make([]bigquery.Value, len(target))
+		for i, j := range idx {
+			if j >= 0 {
+				ordered[i] = row[j]
+			} else if target[i].Repeated {
+				ordered[i] = []bigquery.Value{}
+			}
+		}
+		c, err := conformRow(ordered, from, target)
+		if err != nil {
+			return nil, invalidf("Provided Schema does not match Table %s. %v", tableName(t.md.TableReference), err)
+		}
+		out[r] = c
+	}
+	return out, nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// runCopyJob copies the rows of the source tables to the destination.
+// s.mu must be held.
+func (s *Server) runCopyJob(md *bq.Job) error {
+	cfg := md.Configuration.Copy
+	srcs := cfg.SourceTables
+	if cfg.SourceTable != nil {
+		srcs = append(srcs, cfg.SourceTable)
+	}
+	if len(srcs) == 0 {
+		return invalidf("Source table is required")
+	}
+	var schema bigquery.Schema
+	var rows [][]bigquery.Value
+	for i, ref := range srcs {
+		t, err := s.lookupTable(ref.ProjectId, ref.DatasetId, ref.TableId)
+		if err != nil {
+			return err
+		}
+		if t.isView() {
+			return invalidf("Cannot copy view %s", tableName(ref))
+		}
+		if i == 0 {
+			schema = t.schema
+		} else if _, err := conformRow(make([]bigquery.Value, len(schema)), t.schema, schema); len(t.schema) != len(schema) || err != nil {
+			return invalidf("Source tables must have the same schema")
+		}
+		rows = append(rows, t.rows...)
+	}
+	dst, err := s.writeTable(cfg.DestinationTable, schema, slices.Clone(rows), cfg.CreateDisposition, orDefault(cfg.WriteDisposition, "WRITE_EMPTY"), nil)
+	if err != nil {
+		return err
+	}
+	if cfg.DestinationExpirationTime != "" {
+		dst.md.ExpirationTime, _ = strconv.ParseInt(cfg.DestinationExpirationTime, 10, 64)
+	}
+	md.Statistics.Copy = &bq.JobStatistics5{CopiedRows: int64(len(rows)), ForceSendFields: []string{"CopiedRows"}}
+	return nil
+}
+
+// runLoadJob loads the uploaded data into the destination table.
+// s.mu must be held.
+func (s *Server) runLoadJob(md *bq.Job, data []byte) error {
+	cfg := md.Configuration.Load
+	if data == nil {
+		return invalidf("bqtest: only loads from local data are supported, not from %v", cfg.SourceUris)
+	}
+	schema, err := schemaFromBQ(cfg.Schema)
+	if err != nil {
+		return invalidf("%v", err)
+	}
+	ref := cfg.DestinationTable
+	if ref == nil {
+		return invalidf("Destination table is required")
+	}
+	writeDisp := orDefault(cfg.WriteDisposition, "WRITE_APPEND")
+	var target bigquery.Schema
+	if t, err := s.lookupTable(ref.ProjectId, ref.DatasetId, ref.TableId); err == nil && writeDisp != "WRITE_TRUNCATE" {
+		target = t.schema
+	}
+	if schema == nil {
+		schema = target
+	}
+	format := orDefault(cfg.SourceFormat, "CSV")
+	if schema == nil && cfg.Autodetect {
+		if schema, err = detectSchema(format, data, cfg); err != nil {
+			return err
+		}
+	}
+	if schema == nil {
+		return invalidf("No schema specified on job or table.")
+	}
+	var rows [][]bigquery.Value
+	var bad []error
+	switch format {
+	case "CSV":
+		rows, bad, err = readCSV(data, schema, cfg)
+	case "NEWLINE_DELIMITED_JSON":
+		rows, bad, err = readNDJSON(data, schema, cfg)
+	default:
+		return invalidf("bqtest: unsupported source format %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	if int64(len(bad)) > cfg.MaxBadRecords {
+		md.Status.Errors = nil
+		for _, e := range bad {
+			md.Status.Errors = append(md.Status.Errors, errorProto(e))
+		}
+		return invalidf("Error while reading data, error message: %v", bad[0])
+	}
+	if _, err := s.writeTable(ref, schema, rows, cfg.CreateDisposition, writeDisp, cfg.SchemaUpdateOptions); err != nil {
+		return err
+	}
+	md.Statistics.Load = &bq.JobStatistics3{
+		InputFiles:      1,
+		InputFileBytes:  int64(len(data)),
+		OutputRows:      int64(len(rows)),
+		BadRecords:      int64(len(bad)),
+		ForceSendFields: []string{"OutputRows"},
+	}
+	return nil
+}
+
+func csvReader(data []byte, cfg *bq.JobConfigurationLoad) (*csv.Reader, error) {
+	if cfg.Encoding != "" && cfg.Encoding != "UTF-8" {
+		return nil, invalidf("bqtest: unsupported encoding %s", cfg.Encoding)
+	}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = cfg.Quote != nil && *cfg.Quote == ""
+	switch d := cfg.FieldDelimiter; d {
+	case "", ",":
+	case `\t`, "tab":
+		cr.Comma = '\t'
+	default:
+		r, n := utf8.DecodeRuneInString(d)
+		if n != len(d) {
+			return nil, invalidf("bqtest: unsupported field delimiter %q", d)
+		}
+		cr.Comma = r
+	}
+	return cr, nil
+}
+
+// readCSV parses CSV data. Rows that cannot be parsed are returned as errors.
+func readCSV(data []byte, schema bigquery.Schema, cfg *bq.JobConfigurationLoad) ([][]bigquery.Value, []error, error) {
+	for _, fs := range schema {
+		if fs.Repeated || fs.Type == bigquery.RecordFieldType {
+			return nil, nil, invalidf("CSV cannot load field %s of mode %s and type %s", fs.Name, fieldMode(fs), fs.Type)
+		}
+	}
+	cr, err := csvReader(data, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	nulls := cfg.NullMarkers
+	if len(nulls) == 0 {
+		nulls = []string{cfg.NullMarker}
+	}
+	var rows [][]bigquery.Value
+	var bad []error
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, invalidf("Error while reading data: %v", err)
+		}
+		if int64(line) <= cfg.SkipLeadingRows {
+			continue
+		}
+		row, err := csvRow(rec, schema, nulls, cfg)
+		if err != nil {
+			bad = append(bad, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, bad, nil
+}
+
+func csvRow(rec []string, schema bigquery.Schema, nulls []string, cfg *bq.JobConfigurationLoad) ([]bigquery.Value, error) {
+	switch {
+	case len(rec) > len(schema) && !cfg.IgnoreUnknownValues:
+		return nil, fmt.Errorf("too many values: got %d, want %d", len(rec), len(schema))
+	case len(rec) < len(schema) && !cfg.AllowJaggedRows:
+		return nil, fmt.Errorf("too few values: got %d, want %d", len(rec), len(schema))
+	}
+	row := make([]bigquery.Value, len(schema))
+	for i, fs := range schema {
+		if i >= len(rec) || slices.Contains(nulls, rec[i]) {
+			if fs.Required {
+				return nil, fmt.Errorf("missing required field: %s", fs.Name)
+			}
+			continue
+		}
+		v, err := parseScalar(rec[i], fs)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = v
+	}
+	return row, nil
+}
+
+// readNDJSON parses newline-delimited JSON. Rows that cannot be parsed are
+// returned as errors.
+func readNDJSON(data []byte, schema bigquery.Schema, cfg *bq.JobConfigurationLoad) ([][]bigquery.Value, []error, error) {
+	var rows [][]bigquery.Value
+	var bad []error
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := decodeJSON(line, &m); err != nil {
+			bad = append(bad, fmt.Errorf("line %d: %v", i+1, err))
+			continue
+		}
+		row, err := jsonToRow(m, schema, cfg.IgnoreUnknownValues)
+		if err != nil {
+			bad = append(bad, fmt.Errorf("line %d: %v", i+1, err))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, bad, nil
+}
+
+// detectSchema infers a schema from CSV or newline-delimited JSON data.
+// CSV columns are named by the last skipped leading row, if any.
+func detectSchema(format string, data []byte, cfg *bq.JobConfigurationLoad) (bigquery.Schema, error) {
+	var schema bigquery.Schema
+	switch format {
+	case "CSV":
+		cr, err := csvReader(data, cfg)
+		if err != nil {
+			return nil, err
+		}
+		recs, err := cr.ReadAll()
+		if err != nil {
+			return nil, invalidf("Error while reading data: %v", err)
+		}
+		var header []string
+		if n := cfg.SkipLeadingRows; n > 0 && int64(len(recs)) >= n {
+			header = recs[n-1]
+			recs = recs[n:]
+		}
+		for _, rec := range recs {
+			for i, v := range rec {
+				for len(schema) <= i {
+					name := fmt.Sprintf("string_field_%d", len(schema))
+					if len(schema) < len(header) {
+						name = header[len(schema)]
+					}
+					schema = append(schema, &bigquery.FieldSchema{Name: name})
+				}
+				if v != cfg.NullMarker {
+					schema[i].Type = widenType(schema[i].Type, detectText(v))
+				}
+			}
+		}
+	case "NEWLINE_DELIMITED_JSON":
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var m map[string]any
+			if err := decodeJSON(line, &m); err != nil {
+				return nil, invalidf("Error while reading data: %v", err)
+			}
+			schema = detectObject(schema, m)
+		}
+	default:
+		return nil, invalidf("bqtest: cannot detect the schema of %s data", format)
+	}
+	finishDetected(schema)
+	return schema, nil
+}
+
+// detectText returns the narrowest type that the text parses as.
+func detectText(s string) bigquery.FieldType {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return bigquery.IntegerFieldType
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) {
+		return bigquery.FloatFieldType
+	}
+	if s == "true" || s == "false" || s == "TRUE" || s == "FALSE" {
+		return bigquery.BooleanFieldType
+	}
+	return bigquery.StringFieldType
+}
+
+// widenType returns a type that holds values of both types.
+func widenType(a, b bigquery.FieldType) bigquery.FieldType {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	case (a == bigquery.IntegerFieldType && b == bigquery.FloatFieldType) || (a == bigquery.FloatFieldType && b == bigquery.IntegerFieldType):
+		return bigquery.FloatFieldType
+	}
+	return bigquery.StringFieldType
+}
+
+func detectObject(schema bigquery.Schema, m map[string]any) bigquery.Schema {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		i := fieldIndex(schema, k)
+		if i < 0 {
+			schema = append(schema, &bigquery.FieldSchema{Name: k})
+			i = len(schema) - 1
+		}
+		detectValue(schema[i], m[k])
+	}
+	return schema
+}
+
+func detectValue(fs *bigquery.FieldSchema, v any) {
+	switch v := v.(type) {
+	case []any:
+		fs.Repeated = true
+		for _, e := range v {
+			detectValue(fs, e)
+		}
+	case map[string]any:
+		fs.Type = bigquery.RecordFieldType
+		fs.Schema = detectObject(fs.Schema, v)
+	case json.Number:
+		t := bigquery.FloatFieldType
+		if _, err := v.Int64(); err == nil {
+			t = bigquery.IntegerFieldType
+		}
+		fs.Type = widenType(fs.Type, t)
+	case bool:
+		fs.Type = widenType(fs.Type, bigquery.BooleanFieldType)
+	case string:
+		fs.Type = widenType(fs.Type, bigquery.StringFieldType)
+	}
+}
+
+// finishDetected makes columns whose type could not be detected STRING.
+func finishDetected(schema bigquery.Schema) {
+	for _, fs := range schema {
+		if fs.Type == "" {
+			fs.Type = bigquery.StringFieldType
+		}
+		finishDetected(fs.Schema)
+	}
+}
+
+func (s *Server) query(r *http.Request) (any, error) {
+	req := &bq.QueryRequest{}
+	if err := readBody(r, req); err != nil {
+		return nil, err
+	}
+	projectID := r.PathValue("project")
+	md := &bq.Job{
+		JobReference: &bq.JobReference{ProjectId: projectID, Location: req.Location},
+		Configuration: &bq.JobConfiguration{
+			DryRun: req.DryRun,
+			Labels: req.Labels,
+			Query: &bq.JobConfigurationQuery{
+				Query:           req.Query,
+				DefaultDataset:  req.DefaultDataset,
+				QueryParameters: req.QueryParameters,
+				ParameterMode:   req.ParameterMode,
+				UseLegacySql:    req.UseLegacySql,
+			},
+		},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, err := s.runJob(projectID, md, nil)
+	if err != nil {
+		return nil, err
+	}
+	if e := md.Status.ErrorResult; e != nil {
+		return nil, &apiError{code: http.StatusBadRequest, reason: e.Reason, message: e.Message}
+	}
+	resp := &bq.QueryResponse{
+		Kind:                "bigquery#queryResponse",
+		JobReference:        md.JobReference,
+		JobComplete:         true,
+		Schema:              md.Statistics.Query.Schema,
+		TotalBytesProcessed: 0,
+		CacheHit:            false,
+		ForceSendFields:     []string{"TotalBytesProcessed"},
+	}
+	if req.DryRun {
+		return resp, nil
+	}
+	int64TS := req.FormatOptions != nil && req.FormatOptions.UseInt64Timestamp
+	q := r.URL.Query()
+	if req.MaxResults > 0 {
+		q.Set("maxResults", strconv.FormatInt(req.MaxResults, 10))
+	}
+	rows, next, total, err := s.resultPage(md, q, int64TS)
+	if err != nil {
+		return nil, err
+	}
+	resp.Rows, resp.PageToken, resp.TotalRows = rows, next, total
+	resp.ForceSendFields = append(resp.ForceSendFields, "TotalRows")
+	return resp, nil
+}
+
+func (s *Server) getQueryResults(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, err := s.lookupJob(r.PathValue("project"), r.PathValue("job"))
+	if err != nil {
+		return nil, err
+	}
+	md := j.md
+	if md.Configuration.Query == nil {
+		return nil, invalidf("Job %s is not a query", md.Id)
+	}
+	if e := md.Status.ErrorResult; e != nil {
+		return nil, &apiError{code: http.StatusBadRequest, reason: e.Reason, message: e.Message}
+	}
+	q := r.URL.Query()
+	rows, next, total, err := s.resultPage(md, q, q.Get("formatOptions.useInt64Timestamp") == "true")
+	if err != nil {
+		return nil, err
+	}
+	return &bq.GetQueryResultsResponse{
+		Kind:            "bigquery#getQueryResultsResponse",
+		JobReference:    md.JobReference,
+		JobComplete:     true,
+		Schema:          md.Statistics.Query.Schema,
+		Rows:            rows,
+		PageToken:       next,
+		TotalRows:       total,
+		ForceSendFields: []string{"TotalRows"},
+	}, nil
+}
+
+// resultPage returns a page of the results of a query job, which are read
+// from its destination table. s.mu must be held.
+func (s *Server) resultPage(md *bq.Job, q url.Values, int64TS bool) ([]*bq.TableRow, string, uint64, error) {
+	ref := md.Configuration.Query.DestinationTable
+	t, err := s.lookupTable(ref.ProjectId, ref.DatasetId, ref.TableId)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	start, end, next, err := page(q, len(t.rows))
+	if err != nil {
+		return nil, "", 0, err
+	}
+	var rows []*bq.TableRow
+	for _, row := range t.rows[start:end] {
+		rows = append(rows, rowToBQ(row, t.schema, int64TS))
+	}
+	return rows, next, uint64(len(t.rows)), nil
+}
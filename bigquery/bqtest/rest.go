@@ -0,0 +1,677 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	bq "google.golang.org/api/bigquery/v2"
+)
+
+const (
+	restPrefix   = "/bigquery/v2/"
+	uploadPrefix = "/upload/bigquery/v2/"
+)
+
+// apiError is an error with the status code and reason reported by the REST
+// API.
+type apiError struct {
+	code    int
+	reason  string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func errorf(code int, reason, format string, args ...any) error {
+	return &apiError{code: code, reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+// invalidf returns an error for an invalid request.
+func invalidf(format string, args ...any) error {
+	return errorf(http.StatusBadRequest, "invalid", format, args...)
+}
+
+// toAPIError converts err to an apiError, treating unknown errors as invalid
+// requests so that clients do not retry them.
+func toAPIError(err error) *apiError {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return ae
+	}
+	return &apiError{code: http.StatusBadRequest, reason: "invalid", message: err.Error()}
+}
+
+// errorProto converts err to the form used in job statuses and insert
+// errors.
+func errorProto(err error) *bq.ErrorProto {
+	ae := toAPIError(err)
+	return &bq.ErrorProto{Reason: ae.reason, Message: ae.message}
+}
+
+func (s *Server) restHandler() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, h func(*http.Request) (any, error)) {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.HandleFunc(method+" "+restPrefix+path, serveJSON(h))
+	}
+	handle("POST projects/{project}/datasets", s.insertDataset)
+	handle("GET projects/{project}/datasets", s.listDatasets)
+	handle("GET projects/{project}/datasets/{dataset}", s.getDataset)
+	handle("PATCH projects/{project}/datasets/{dataset}", s.patchDataset)
+	handle("DELETE projects/{project}/datasets/{dataset}", s.deleteDataset)
+	handle("POST projects/{project}/datasets/{dataset}/tables", s.insertTable)
+	handle("GET projects/{project}/datasets/{dataset}/tables", s.listTables)
+	handle("GET projects/{project}/datasets/{dataset}/tables/{table}", s.getTable)
+	handle("PATCH projects/{project}/datasets/{dataset}/tables/{table}", s.patchTable)
+	handle("DELETE projects/{project}/datasets/{dataset}/tables/{table}", s.deleteTable)
+	handle("POST projects/{project}/datasets/{dataset}/tables/{table}/insertAll", s.insertAll)
+	handle("GET projects/{project}/datasets/{dataset}/tables/{table}/data", s.listTableData)
+	handle("POST projects/{project}/jobs", s.insertJob)
+	handle("GET projects/{project}/jobs", s.listJobs)
+	handle("GET projects/{project}/jobs/{job}", s.getJob)
+	handle("POST projects/{project}/jobs/{job}/cancel", s.cancelJob)
+	handle("DELETE projects/{project}/jobs/{job}/delete", s.deleteJob)
+	handle("POST projects/{project}/queries", s.query)
+	handle("GET projects/{project}/queries/{job}", s.getQueryResults)
+	mux.HandleFunc("POST "+uploadPrefix+"projects/{project}/jobs", serveJSON(s.uploadJob))
+	mux.HandleFunc("PUT "+uploadPrefix+"projects/{project}/jobs", serveJSON(s.uploadJob))
+	mux.HandleFunc("/", serveJSON(func(r *http.Request) (any, error) {
+		return nil, errorf(http.StatusNotImplemented, "notImplemented", "bqtest: %s %s is not supported", r.Method, r.URL.Path)
+	}))
+	return mux
+}
+
+// serveJSON adapts a handler that returns a REST resource. A nil resource is
+// an empty response.
+func serveJSON(h func(*http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if res == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if resp, ok := res.(*rawResponse); ok {
+			for k, v := range resp.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(resp.code)
+			return
+		}
+		b, err := json.Marshal(res)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write(b)
+	}
+}
+
+// rawResponse is an empty response with a status code and headers, as used
+// by resumable uploads.
+type rawResponse struct {
+	code   int
+	header http.Header
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	ae := toAPIError(err)
+	body := map[string]any{
+		"error": map[string]any{
+			"code":    ae.code,
+			"message": ae.message,
+			"errors": []map[string]string{{
+				"domain":  "global",
+				"reason":  ae.reason,
+				"message": ae.message,
+			}},
+		},
+	}
+	b, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(ae.code)
+	w.Write(b)
+}
+
+// readBody decodes the JSON request body into v.
+func readBody(r *http.Request, v any) error {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return invalidf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// page returns the bounds of the page of n items selected by the request's
+// pageToken, or startIndex, and maxResults parameters, and the token of the
+// next page.
+func page(q url.Values, n int) (start, end int, next string, err error) {
+	if tok := q.Get("pageToken"); tok != "" {
+		if start, err = strconv.Atoi(tok); err != nil {
+			return 0, 0, "", invalidf("invalid page token %q", tok)
+		}
+	} else if si := q.Get("startIndex"); si != "" {
+		if start, err = strconv.Atoi(si); err != nil {
+			return 0, 0, "", invalidf("invalid start index %q", si)
+		}
+	}
+	start = min(start, n)
+	end = n
+	if mr := q.Get("maxResults"); mr != "" {
+		m, err := strconv.Atoi(mr)
+		if err != nil {
+			return 0, 0, "", invalidf("invalid max results %q", mr)
+		}
+		end = min(start+m, n)
+	}
+	if end < n {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next, nil
+}
+
+// mergePatch applies a JSON merge patch to the resource in v: objects are
+// merged, and null values remove fields.
+func mergePatch(v any, patch []byte) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var cur, p map[string]any
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return invalidf("invalid request body: %v", err)
+	}
+	b, err = json.Marshal(mergeObjects(cur, p))
+	if err != nil {
+		return err
+	}
+	// Fields removed by the patch must not survive from v.
+	reflect.ValueOf(v).Elem().SetZero()
+	return json.Unmarshal(b, v)
+}
+
+func mergeObjects(cur, patch map[string]any) map[string]any {
+	if cur == nil {
+		cur = map[string]any{}
+	}
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(cur, k)
+		case map[string]any:
+			c, _ := cur[k].(map[string]any)
+			cur[k] = mergeObjects(c, v)
+		default:
+			cur[k] = v
+		}
+	}
+	return cur
+}
+
+// checkEtag fails the request if it is conditional on a different etag.
+func checkEtag(r *http.Request, etag string) error {
+	if want := r.Header.Get("If-Match"); want != "" && want != etag {
+		return errorf(http.StatusPreconditionFailed, "conditionNotMet", "Precondition check failed.")
+	}
+	return nil
+}
+
+func (s *Server) insertDataset(r *http.Request) (any, error) {
+	md := &bq.Dataset{}
+	if err := readBody(r, md); err != nil {
+		return nil, err
+	}
+	ref := md.DatasetReference
+	if ref == nil || ref.DatasetId == "" {
+		return nil, invalidf("Dataset reference is required")
+	}
+	if ref.ProjectId == "" {
+		ref.ProjectId = r.PathValue("project")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := datasetKey{ref.ProjectId, ref.DatasetId}
+	if _, ok := s.datasets[key]; ok {
+		return nil, errorf(http.StatusConflict, "duplicate", "Already Exists: Dataset %s:%s", ref.ProjectId, ref.DatasetId)
+	}
+	s.addDataset(md)
+	return md, nil
+}
+
+// addDataset adds a dataset with the reference in md. s.mu must be held.
+func (s *Server) addDataset(md *bq.Dataset) *dataset {
+	ref := md.DatasetReference
+	now := s.nowMillis()
+	md.Kind = "bigquery#dataset"
+	md.Id = ref.ProjectId + ":" + ref.DatasetId
+	md.CreationTime = now
+	md.LastModifiedTime = now
+	md.Etag = strconv.FormatInt(now, 36)
+	if md.Location == "" {
+		md.Location = "US"
+	}
+	if md.Type == "" {
+		md.Type = "DEFAULT"
+	}
+	ds := &dataset{md: md, tables: map[string]*table{}}
+	s.datasets[datasetKey{ref.ProjectId, ref.DatasetId}] = ds
+	return ds
+}
+
+func (s *Server) getDataset(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, err := s.lookupDataset(r.PathValue("project"), r.PathValue("dataset"))
+	if err != nil {
+		return nil, err
+	}
+	return ds.md, nil
+}
+
+func (s *Server) listDatasets(r *http.Request) (any, error) {
+	projectID := r.PathValue("project")
+	all := r.URL.Query().Get("all") == "true"
+	filter := strings.Fields(r.URL.Query().Get("filter"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matches []*bq.Dataset
+	for key, ds := range s.datasets {
+		// Datasets whose names begin with an underscore are hidden.
+		if key.projectID != projectID || (!all && strings.HasPrefix(key.datasetID, "_")) {
+			continue
+		}
+		if matchLabels(ds.md.Labels, filter) {
+			matches = append(matches, ds.md)
+		}
+	}
+	slices.SortFunc(matches, func(a, b *bq.Dataset) int { return strings.Compare(a.Id, b.Id) })
+	start, end, next, err := page(r.URL.Query(), len(matches))
+	if err != nil {
+		return nil, err
+	}
+	list := &bq.DatasetList{Kind: "bigquery#datasetList", NextPageToken: next}
+	for _, md := range matches[start:end] {
+		list.Datasets = append(list.Datasets, &bq.DatasetListDatasets{
+			DatasetReference: md.DatasetReference,
+			FriendlyName:     md.FriendlyName,
+			Id:               md.Id,
+			Kind:             md.Kind,
+			Labels:           md.Labels,
+			Location:         md.Location,
+			Type:             md.Type,
+		})
+	}
+	return list, nil
+}
+
+// matchLabels reports whether labels match a dataset list filter, whose
+// terms have the form labels.key or labels.key:value.
+func matchLabels(labels map[string]string, filter []string) bool {
+	for _, term := range filter {
+		k, v, hasValue := strings.Cut(strings.TrimPrefix(term, "labels."), ":")
+		got, ok := labels[k]
+		if !ok || (hasValue && got != v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) patchDataset(r *http.Request) (any, error) {
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, err := s.lookupDataset(r.PathValue("project"), r.PathValue("dataset"))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEtag(r, ds.md.Etag); err != nil {
+		return nil, err
+	}
+	md := &bq.Dataset{}
+	*md = *ds.md
+	if err := mergePatch(md, patch); err != nil {
+		return nil, err
+	}
+	// Identity and creation time are immutable.
+	md.DatasetReference, md.Id, md.Kind, md.CreationTime = ds.md.DatasetReference, ds.md.Id, ds.md.Kind, ds.md.CreationTime
+	now := s.nowMillis()
+	md.LastModifiedTime = now
+	md.Etag = strconv.FormatInt(now, 36) + "." + s.newID("")
+	ds.md = md
+	return md, nil
+}
+
+func (s *Server) deleteDataset(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projectID, datasetID := r.PathValue("project"), r.PathValue("dataset")
+	ds, err := s.lookupDataset(projectID, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ds.tables) > 0 && r.URL.Query().Get("deleteContents") != "true" {
+		return nil, errorf(http.StatusBadRequest, "resourceInUse", "Dataset %s:%s is still in use", projectID, datasetID)
+	}
+	delete(s.datasets, datasetKey{projectID, datasetID})
+	return nil, nil
+}
+
+func (s *Server) insertTable(r *http.Request) (any, error) {
+	md := &bq.Table{}
+	if err := readBody(r, md); err != nil {
+		return nil, err
+	}
+	if md.TableReference == nil {
+		return nil, invalidf("Table reference is required")
+	}
+	if md.TableReference.ProjectId == "" {
+		md.TableReference.ProjectId = r.PathValue("project")
+	}
+	if md.TableReference.DatasetId == "" {
+		md.TableReference.DatasetId = r.PathValue("dataset")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.createTable(md)
+	if err != nil {
+		return nil, err
+	}
+	return t.metadata(), nil
+}
+
+func (s *Server) getTable(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookupTable(r.PathValue("project"), r.PathValue("dataset"), r.PathValue("table"))
+	if err != nil {
+		return nil, err
+	}
+	return t.metadata(), nil
+}
+
+func (s *Server) listTables(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, err := s.lookupDataset(r.PathValue("project"), r.PathValue("dataset"))
+	if err != nil {
+		return nil, err
+	}
+	ids := slices.Sorted(maps.Keys(ds.tables))
+	start, end, next, err := page(r.URL.Query(), len(ids))
+	if err != nil {
+		return nil, err
+	}
+	list := &bq.TableList{Kind: "bigquery#tableList", NextPageToken: next, TotalItems: int64(len(ids))}
+	for _, id := range ids[start:end] {
+		md := ds.tables[id].md
+		lt := &bq.TableListTables{
+			CreationTime:   md.CreationTime,
+			ExpirationTime: md.ExpirationTime,
+			FriendlyName:   md.FriendlyName,
+			Id:             md.Id,
+			Kind:           md.Kind,
+			Labels:         md.Labels,
+			TableReference: md.TableReference,
+			Type:           md.Type,
+		}
+		if md.View != nil {
+			lt.View = &bq.TableListTablesView{UseLegacySql: md.View.UseLegacySql}
+		}
+		list.Tables = append(list.Tables, lt)
+	}
+	return list, nil
+}
+
+func (s *Server) patchTable(r *http.Request) (any, error) {
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookupTable(r.PathValue("project"), r.PathValue("dataset"), r.PathValue("table"))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEtag(r, t.md.Etag); err != nil {
+		return nil, err
+	}
+	md := t.metadata()
+	if err := mergePatch(md, patch); err != nil {
+		return nil, err
+	}
+	md.TableReference, md.Id, md.Kind, md.CreationTime, md.Type = t.md.TableReference, t.md.Id, t.md.Kind, t.md.CreationTime, t.md.Type
+	schema, err := schemaFromBQ(md.Schema)
+	if err != nil {
+		return nil, invalidf("%v", err)
+	}
+	if t.isView() {
+		ref := md.TableReference
+		res, err := s.runQueryLocked(&QueryRequest{SQL: md.View.Query, DefaultProjectID: ref.ProjectId, DefaultDatasetID: ref.DatasetId})
+		if err != nil {
+			return nil, err
+		}
+		schema = res.Schema
+	} else if err := t.setSchema(schema); err != nil {
+		return nil, err
+	}
+	t.md = md
+	t.schema = schema
+	t.touch(s.nowMillis())
+	return t.metadata(), nil
+}
+
+// setSchema changes the schema of the table, converting its rows.
+func (t *table) setSchema(schema bigquery.Schema) error {
+	rows, err := evolveRows(t.rows, t.schema, schema)
+	if err != nil {
+		return invalidf("Provided Schema does not match Table %s. %v", tableName(t.md.TableReference), err)
+	}
+	t.rows = rows
+	t.schema = schema
+	return nil
+}
+
+func (s *Server) deleteTable(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projectID, datasetID, tableID := r.PathValue("project"), r.PathValue("dataset"), r.PathValue("table")
+	if _, err := s.lookupTable(projectID, datasetID, tableID); err != nil {
+		return nil, err
+	}
+	delete(s.datasets[datasetKey{projectID, datasetID}].tables, tableID)
+	return nil, nil
+}
+
+// insertAllRequest is a tabledata.insertAll request whose row values are
+// decoded with their numbers intact.
+type insertAllRequest struct {
+	SkipInvalidRows     bool   `json:"skipInvalidRows"`
+	IgnoreUnknownValues bool   `json:"ignoreUnknownValues"`
+	TemplateSuffix      string `json:"templateSuffix"`
+	Rows                []struct {
+		InsertID string         `json:"insertId"`
+		JSON     map[string]any `json:"json"`
+	} `json:"rows"`
+}
+
+func (s *Server) insertAll(r *http.Request) (any, error) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var req insertAllRequest
+	if err := decodeJSON(b, &req); err != nil {
+		return nil, invalidf("invalid request body: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projectID, datasetID, tableID := r.PathValue("project"), r.PathValue("dataset"), r.PathValue("table")
+	t, err := s.lookupTable(projectID, datasetID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	if req.TemplateSuffix != "" {
+		// Rows go to a table named with the suffix, which is created with
+		// the template's schema.
+		if t, err = s.templateTable(t, tableID+req.TemplateSuffix); err != nil {
+			return nil, err
+		}
+	}
+	if t.isView() {
+		return nil, invalidf("Cannot insert rows into view %s", tableName(t.md.TableReference))
+	}
+	resp := &bq.TableDataInsertAllResponse{Kind: "bigquery#tableDataInsertAllResponse"}
+	var rows [][]bigquery.Value
+	var ids []string
+	for i, in := range req.Rows {
+		row, err := jsonToRow(in.JSON, t.schema, req.IgnoreUnknownValues)
+		if err != nil {
+			resp.InsertErrors = append(resp.InsertErrors, &bq.TableDataInsertAllResponseInsertErrors{
+				Index:  int64(i),
+				Errors: []*bq.ErrorProto{{Reason: "invalid", Message: err.Error()}},
+			})
+			continue
+		}
+		rows = append(rows, row)
+		ids = append(ids, in.InsertID)
+	}
+	if len(resp.InsertErrors) > 0 && !req.SkipInvalidRows {
+		// No rows are inserted, and the valid ones are reported as stopped.
+		failed := map[int64]bool{}
+		for _, e := range resp.InsertErrors {
+			failed[e.Index] = true
+		}
+		for i := range req.Rows {
+			if !failed[int64(i)] {
+				resp.InsertErrors = append(resp.InsertErrors, &bq.TableDataInsertAllResponseInsertErrors{
+					Index:  int64(i),
+					Errors: []*bq.ErrorProto{{Reason: "stopped"}},
+				})
+			}
+		}
+		slices.SortFunc(resp.InsertErrors, func(a, b *bq.TableDataInsertAllResponseInsertErrors) int { return int(a.Index - b.Index) })
+		return resp, nil
+	}
+	for i, row := range rows {
+		// Insert IDs deduplicate retried rows.
+		if id := ids[i]; id != "" {
+			if t.seenIDs[id] {
+				continue
+			}
+			t.seenIDs[id] = true
+		}
+		t.rows = append(t.rows, row)
+	}
+	t.touch(s.nowMillis())
+	return resp, nil
+}
+
+// templateTable returns the table with the ID in the template's dataset,
+// creating it with the template's schema if necessary. s.mu must be held.
+func (s *Server) templateTable(tmpl *table, tableID string) (*table, error) {
+	ref := *tmpl.md.TableReference
+	ref.TableId = tableID
+	if t, err := s.lookupTable(ref.ProjectId, ref.DatasetId, ref.TableId); err == nil {
+		return t, nil
+	}
+	return s.createTable(&bq.Table{TableReference: &ref, Schema: schemaToBQ(tmpl.schema)})
+}
+
+func (s *Server) listTableData(r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projectID, datasetID, tableID := r.PathValue("project"), r.PathValue("dataset"), r.PathValue("table")
+	t, err := s.lookupTable(projectID, datasetID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	if t.isView() {
+		return nil, invalidf("Cannot list a table of type VIEW.")
+	}
+	schema, rows, err := selectFields(t.schema, t.rows, r.URL.Query().Get("selectedFields"))
+	if err != nil {
+		return nil, err
+	}
+	start, end, next, err := page(r.URL.Query(), len(rows))
+	if err != nil {
+		return nil, err
+	}
+	int64TS := r.URL.Query().Get("formatOptions.useInt64Timestamp") == "true"
+	list := &bq.TableDataList{
+		Kind:      "bigquery#tableDataList",
+		Etag:      t.md.Etag,
+		PageToken: next,
+		TotalRows: int64(len(rows)),
+	}
+	for _, row := range rows[start:end] {
+		list.Rows = append(list.Rows, rowToBQ(row, schema, int64TS))
+	}
+	return list, nil
+}
+
+// selectFields projects rows onto a comma-separated list of top-level
+// columns. An empty list selects all columns.
+func selectFields(schema bigquery.Schema, rows [][]bigquery.Value, fields string) (bigquery.Schema, [][]bigquery.Value, error) {
+	if fields == "" {
+		return schema, rows, nil
+	}
+	var sel bigquery.Schema
+	var idx []int
+	for _, name := range strings.Split(fields, ",") {
+		i := fieldIndex(schema, strings.TrimSpace(name))
+		if i < 0 {
+			return nil, nil, invalidf("no such field: %s", name)
+		}
+		sel = append(sel, schema[i])
+		idx = append(idx, i)
+	}
+	out := make([][]bigquery.Value, len(rows))
+	for r, row := range rows {
+		out[r] = make([]bigquery.Value, len(idx))
+		for j, i := range idx {
+			out[r][j] = row[i]
+		}
+	}
+	return sel, out, nil
+}
@@ -0,0 +1,327 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bqtest provides a fake BigQuery service for testing. It keeps
+// datasets, tables and jobs in memory and implements a simplified form of the
+// REST API used by cloud.google.com/go/bigquery, along with a minimal Storage
+// Read and Write API for EnableStorageReadClient and the managedwriter
+// package.
+//
+// The fake supports dataset and table management, streaming inserts, table
+// reads, load jobs from local sources in CSV and newline-delimited JSON, copy
+// jobs, and query jobs. Queries are evaluated by an Engine; the default one
+// understands a practical subset of GoogleSQL. The fake may behave
+// differently from the actual service in ways in which the service is
+// unspecified: ordering of unordered results, error messages, statistics,
+// etc.
+//
+// This package is EXPERIMENTAL and is subject to change without notice.
+//
+// See the example for usage.
+package bqtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/internal/testutil"
+	bq "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Server is a fake BigQuery server. It serves the REST API over HTTP at URL
+// and the Storage Read and Write APIs over gRPC at Addr.
+type Server struct {
+	URL  string // The base URL of the REST endpoint.
+	Addr string // The address of the Storage API endpoint.
+
+	hs     *httptest.Server
+	gs     *testutil.Server
+	engine Engine
+	now    func() time.Time
+
+	mu           sync.Mutex
+	datasets     map[datasetKey]*dataset
+	jobs         map[jobKey]*job
+	uploads      map[string]*upload
+	readStreams  map[string]*readStream
+	writeStreams map[string]*writeStream
+	nextID       int
+}
+
+// A ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithEngine sets the Engine that evaluates queries. By default, queries are
+// evaluated by NewEngine.
+func WithEngine(e Engine) ServerOption {
+	return func(s *Server) { s.engine = e }
+}
+
+// WithTimeNowFunc registers f as a function to be used instead of time.Now
+// for creation times, job statistics and CURRENT_TIMESTAMP.
+func WithTimeNowFunc(f func() time.Time) ServerOption {
+	return func(s *Server) { s.now = f }
+}
+
+// NewServer creates a new fake server running in the current process.
+//
+// NewServer panics if the server cannot be started, which is appropriate for
+// testing.
+func NewServer(opts ...ServerOption) *Server {
+	gs, err := testutil.NewServer()
+	if err != nil {
+		panic(fmt.Sprintf("bqtest.NewServer: %v", err))
+	}
+	s := &Server{
+		gs:           gs,
+		Addr:         gs.Addr,
+		engine:       NewEngine(),
+		now:          time.Now,
+		datasets:     map[datasetKey]*dataset{},
+		jobs:         map[jobKey]*job{},
+		uploads:      map[string]*upload{},
+		readStreams:  map[string]*readStream{},
+		writeStreams: map[string]*writeStream{},
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	storagepb.RegisterBigQueryReadServer(gs.Gsrv, &readServer{s: s})
+	storagepb.RegisterBigQueryWriteServer(gs.Gsrv, &writeServer{s: s})
+	gs.Start()
+	s.hs = httptest.NewServer(s.restHandler())
+	s.URL = s.hs.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() error {
+	s.hs.Close()
+	s.gs.Close()
+	return nil
+}
+
+// ClientOptions returns the options that connect a bigquery.Client to the
+// server's REST endpoint.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.URL + restPrefix),
+		option.WithoutAuthentication(),
+	}
+}
+
+// StorageClientOptions returns the options that connect a Storage API client
+// to the server, as passed to bigquery.Client.EnableStorageReadClient or
+// managedwriter.NewClient.
+func (s *Server) StorageClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// NewClient returns a bigquery.Client for the project that is connected to
+// the server, with the Storage Read API enabled.
+func (s *Server) NewClient(ctx context.Context, projectID string) (*bigquery.Client, error) {
+	c, err := bigquery.NewClient(ctx, projectID, s.ClientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.EnableStorageReadClient(ctx, s.StorageClientOptions()...); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newID returns a unique identifier with the prefix. s.mu must be held.
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return prefix + strconv.Itoa(s.nextID)
+}
+
+// nowMillis returns the current time in milliseconds since the epoch.
+func (s *Server) nowMillis() int64 {
+	return s.now().UnixMilli()
+}
+
+type datasetKey struct {
+	projectID, datasetID string
+}
+
+type dataset struct {
+	md     *bq.Dataset
+	tables map[string]*table
+}
+
+// table is a table or view. Rows hold the values of each column in schema
+// order, using the Go types that the bigquery package reads, except that a
+// RECORD is a []bigquery.Value and a REPEATED column is never nil.
+type table struct {
+	md      *bq.Table
+	schema  bigquery.Schema
+	rows    [][]bigquery.Value
+	seenIDs map[string]bool
+	// gen counts modifications, so that etags differ after each one.
+	gen int
+}
+
+func (t *table) isView() bool {
+	return t.md.View != nil
+}
+
+// touch records a modification of the table.
+func (t *table) touch(now int64) {
+	t.gen++
+	t.md.LastModifiedTime = uint64(now)
+	t.md.Etag = strconv.FormatInt(now, 36) + "." + strconv.Itoa(t.gen)
+}
+
+// metadata returns a copy of the table's metadata with its current schema
+// and size.
+func (t *table) metadata() *bq.Table {
+	md := *t.md
+	if !t.isView() {
+		md.NumRows = uint64(len(t.rows))
+		md.NumBytes = int64(len(t.rows)) * 8 * int64(max(len(t.schema), 1))
+	}
+	md.Schema = schemaToBQ(t.schema)
+	return &md
+}
+
+// lookupDataset returns the dataset, or a not found error. s.mu must be held.
+func (s *Server) lookupDataset(projectID, datasetID string) (*dataset, error) {
+	ds, ok := s.datasets[datasetKey{projectID, datasetID}]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not found: Dataset %s:%s", projectID, datasetID)
+	}
+	return ds, nil
+}
+
+// lookupTable returns the table, or a not found error. s.mu must be held.
+func (s *Server) lookupTable(projectID, datasetID, tableID string) (*table, error) {
+	ds, err := s.lookupDataset(projectID, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := ds.tables[tableID]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not found: Table %s:%s.%s", projectID, datasetID, tableID)
+	}
+	return t, nil
+}
+
+// createTable adds a table to an existing dataset. s.mu must be held.
+func (s *Server) createTable(md *bq.Table) (*table, error) {
+	ref := md.TableReference
+	if ref == nil || ref.TableId == "" {
+		return nil, errorf(http.StatusBadRequest, "invalid", "Table reference is required")
+	}
+	ds, err := s.lookupDataset(ref.ProjectId, ref.DatasetId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ds.tables[ref.TableId]; ok {
+		return nil, errorf(http.StatusConflict, "duplicate", "Already Exists: Table %s:%s.%s", ref.ProjectId, ref.DatasetId, ref.TableId)
+	}
+	schema, err := schemaFromBQ(md.Schema)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalid", "%v", err)
+	}
+	now := s.nowMillis()
+	md.Kind = "bigquery#table"
+	md.Id = fmt.Sprintf("%s:%s.%s", ref.ProjectId, ref.DatasetId, ref.TableId)
+	md.CreationTime = now
+	md.Type = "TABLE"
+	if md.View != nil {
+		md.Type = "VIEW"
+	}
+	if md.Location == "" {
+		md.Location = ds.md.Location
+	}
+	t := &table{md: md, schema: schema, seenIDs: map[string]bool{}}
+	if t.isView() {
+		// A view's schema is that of its query.
+		res, err := s.runQueryLocked(&QueryRequest{SQL: md.View.Query, DefaultProjectID: ref.ProjectId, DefaultDatasetID: ref.DatasetId})
+		if err != nil {
+			return nil, err
+		}
+		t.schema = res.Schema
+	}
+	t.touch(now)
+	ds.tables[ref.TableId] = t
+	return t, nil
+}
+
+// tableName formats a table reference as it appears in error messages.
+func tableName(ref *bq.TableReference) string {
+	return fmt.Sprintf("%s:%s.%s", ref.ProjectId, ref.DatasetId, ref.TableId)
+}
+
+// splitTablePath splits a Storage API table path of the form
+// projects/p/datasets/d/tables/t.
+func splitTablePath(path string) (projectID, datasetID, tableID string, ok bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 6 || parts[0] != "projects" || parts[2] != "datasets" || parts[4] != "tables" {
+		return "", "", "", false
+	}
+	return parts[1], parts[3], parts[5], true
+}
+
+// schemaFromBQ converts a REST table schema.
+func schemaFromBQ(ts *bq.TableSchema) (bigquery.Schema, error) {
+	if ts == nil || len(ts.Fields) == 0 {
+		return nil, nil
+	}
+	b, err := ts.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields struct {
+		Fields json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return bigquery.SchemaFromJSON(fields.Fields)
+}
+
+// schemaToBQ converts a schema to its REST form.
+func schemaToBQ(schema bigquery.Schema) *bq.TableSchema {
+	if len(schema) == 0 {
+		return nil
+	}
+	b, err := schema.ToJSONFields()
+	if err != nil {
+		return nil
+	}
+	ts := &bq.TableSchema{}
+	if err := json.Unmarshal(b, &ts.Fields); err != nil {
+		return nil
+	}
+	return ts
+}
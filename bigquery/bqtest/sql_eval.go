@@ -0,0 +1,2368 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+)
+
+type engine struct{}
+
+// NewEngine returns the default Engine. It evaluates SELECT statements with
+// WITH clauses, joins, UNNEST, grouping, set operations, ordering and limits,
+// over the common GoogleSQL operators, scalar functions and aggregate
+// functions. It does not support DML, DDL, scripting, analytic functions or
+// subqueries in expressions.
+func NewEngine() Engine {
+	return engine{}
+}
+
+func (engine) Query(req *QueryRequest) (*QueryResult, error) {
+	q, err := parseQuery(req.SQL)
+	if err != nil {
+		return nil, err
+	}
+	ev := &evaluator{req: req, ctes: map[string]*relation{}}
+	rel, err := ev.query(q)
+	if err != nil {
+		return nil, err
+	}
+	schema := make(bigquery.Schema, len(rel.cols))
+	seen := map[string]bool{}
+	for i, c := range rel.cols {
+		key := strings.ToLower(c.name)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate column names in the result are not supported: %s", c.name)
+		}
+		seen[key] = true
+		fs := *c.fs
+		fs.Name = c.name
+		schema[i] = &fs
+	}
+	return &QueryResult{Schema: schema, Rows: rel.rows}, nil
+}
+
+// A relation is the intermediate result of a FROM clause or query.
+type relation struct {
+	cols []column
+	rows [][]bigquery.Value
+}
+
+type column struct {
+	qual string // The table alias, if any.
+	name string // Empty for an anonymous column.
+	fs   *bigquery.FieldSchema
+
+	// hidden marks the right-hand columns of a JOIN USING, which can be
+	// referred to only with a qualifier.
+	hidden bool
+	// untyped marks a column of NULLs whose type is not yet known.
+	untyped bool
+}
+
+// withQual returns the relation with all columns qualified by q.
+func (r *relation) withQual(q string) *relation {
+	out := &relation{rows: r.rows, cols: slices.Clone(r.cols)}
+	for i := range out.cols {
+		out.cols[i].qual = q
+	}
+	return out
+}
+
+func (r *relation) hasQual(q string) bool {
+	for _, c := range r.cols {
+		if c.qual != "" && strings.EqualFold(c.qual, q) {
+			return true
+		}
+	}
+	return false
+}
+
+type evaluator struct {
+	req   *QueryRequest
+	ctes  map[string]*relation
+	outer *scope // The enclosing query of a subquery.
+}
+
+// A scope holds the columns of a query that encloses a subquery, and the
+// row for which the subquery is being evaluated. A nil row stands for a row
+// of NULLs.
+type scope struct {
+	ev   *evaluator
+	cols []column
+	row  []bigquery.Value
+	// used records whether the subquery refers to the scope, in which case
+	// it is evaluated again for each row.
+	used bool
+}
+
+func (ev *evaluator) query(q *queryStmt) (*relation, error) {
+	if len(q.with) > 0 {
+		saved := ev.ctes
+		ev.ctes = maps.Clone(saved)
+		defer func() { ev.ctes = saved }()
+		for _, c := range q.with {
+			rel, err := ev.query(c.query)
+			if err != nil {
+				return nil, err
+			}
+			ev.ctes[strings.ToLower(c.name)] = rel
+		}
+	}
+	var rel *relation
+	var err error
+	if s, ok := q.body.(*selectStmt); ok {
+		rel, err = ev.selectStmt(s, q.orderBy)
+	} else if rel, err = ev.setExpr(q.body); err == nil && len(q.orderBy) > 0 {
+		err = ev.orderRelation(rel, q.orderBy)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if q.limit != nil {
+		n, err := ev.constInt(q.limit, "LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		var off int64
+		if q.offset != nil {
+			if off, err = ev.constInt(q.offset, "OFFSET"); err != nil {
+				return nil, err
+			}
+		}
+		start := int(min(off, int64(len(rel.rows))))
+		end := int(min(off+n, int64(len(rel.rows))))
+		rel = &relation{cols: rel.cols, rows: rel.rows[start:end]}
+	}
+	return rel, nil
+}
+
+// constInt evaluates a non-negative integer constant, like a LIMIT.
+func (ev *evaluator) constInt(e expr, what string) (int64, error) {
+	c := &compiler{ev: ev}
+	x, err := c.compile(e)
+	if err != nil {
+		return 0, err
+	}
+	v, err := x.eval(nil)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("%s expects a non-negative integer", what)
+	}
+	return n, nil
+}
+
+func (ev *evaluator) setExpr(e setExpr) (*relation, error) {
+	switch e := e.(type) {
+	case *selectStmt:
+		return ev.selectStmt(e, nil)
+	case *queryStmt:
+		return ev.query(e)
+	case *setOp:
+		return ev.setOp(e)
+	}
+	return nil, fmt.Errorf("unsupported query %T", e)
+}
+
+func (ev *evaluator) setOp(op *setOp) (*relation, error) {
+	l, err := ev.setExpr(op.l)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ev.setExpr(op.r)
+	if err != nil {
+		return nil, err
+	}
+	if len(l.cols) != len(r.cols) {
+		return nil, fmt.Errorf("queries in %s have mismatched column count: %d and %d", op.op, len(l.cols), len(r.cols))
+	}
+	cols := make([]column, len(l.cols))
+	for i := range cols {
+		lc, rc := l.cols[i], r.cols[i]
+		t := lc.fs
+		switch {
+		case lc.untyped:
+			t = rc.fs
+		case !rc.untyped:
+			if t, err = commonType(lc.fs, rc.fs); err != nil {
+				return nil, fmt.Errorf("column %d in %s has incompatible types: %v", i+1, op.op, err)
+			}
+		}
+		cols[i] = column{name: lc.name, fs: t, untyped: lc.untyped && rc.untyped}
+	}
+	lrows, err := coerceRows(l.rows, l.cols, cols)
+	if err != nil {
+		return nil, err
+	}
+	rrows, err := coerceRows(r.rows, r.cols, cols)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]bigquery.Value
+	switch op.op {
+	case "UNION":
+		rows = slices.Concat(lrows, rrows)
+	case "INTERSECT", "EXCEPT":
+		counts := map[string]int{}
+		for _, row := range rrows {
+			counts[valueKey(row)]++
+		}
+		for _, row := range lrows {
+			k := valueKey(row)
+			present := counts[k] > 0
+			if present && !op.distinct {
+				counts[k]--
+			}
+			if present == (op.op == "INTERSECT") {
+				rows = append(rows, row)
+			}
+		}
+	}
+	if op.distinct {
+		rows = distinctRows(rows)
+	}
+	return &relation{cols: cols, rows: rows}, nil
+}
+
+func coerceRows(rows [][]bigquery.Value, from, to []column) ([][]bigquery.Value, error) {
+	same := true
+	for i := range from {
+		if from[i].fs.Type != to[i].fs.Type && !from[i].untyped {
+			same = false
+		}
+	}
+	if same {
+		return rows, nil
+	}
+	out := make([][]bigquery.Value, len(rows))
+	for i, row := range rows {
+		out[i] = make([]bigquery.Value, len(row))
+		for j, v := range row {
+			x, err := coerceValue(v, from[j].fs, to[j].fs)
+			if err != nil {
+				return nil, err
+			}
+			out[i][j] = x
+		}
+	}
+	return out, nil
+}
+
+func distinctRows(rows [][]bigquery.Value) [][]bigquery.Value {
+	seen := map[string]bool{}
+	var out [][]bigquery.Value
+	for _, row := range rows {
+		k := valueKey(row)
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// orderRelation sorts the result of a set operation, whose ORDER BY can refer
+// only to its output columns.
+func (ev *evaluator) orderRelation(rel *relation, orderBy []orderItem) error {
+	c := &compiler{ev: ev, cols: rel.cols}
+	keys := make([]orderKey, len(orderBy))
+	for i, item := range orderBy {
+		k, err := c.orderKey(item, nil, rel.cols)
+		if err != nil {
+			return err
+		}
+		keys[i] = k
+	}
+	sorted := make([]sortRow, len(rel.rows))
+	for i, row := range rel.rows {
+		sorted[i] = sortRow{out: row}
+		for _, k := range keys {
+			v, err := k.value(row, row)
+			if err != nil {
+				return err
+			}
+			sorted[i].keys = append(sorted[i].keys, v)
+		}
+	}
+	if err := sortRows(sorted, keys); err != nil {
+		return err
+	}
+	for i := range sorted {
+		rel.rows[i] = sorted[i].out
+	}
+	return nil
+}
+
+// An orderKey is an ORDER BY item, which is either an output column or an
+// expression over the input row.
+type orderKey struct {
+	out        int // The index of an output column, or -1.
+	x          *compiled
+	desc       bool
+	nullsFirst bool
+}
+
+func (k orderKey) value(in, out []bigquery.Value) (bigquery.Value, error) {
+	if k.out >= 0 {
+		return out[k.out], nil
+	}
+	return k.x.eval(in)
+}
+
+type sortRow struct {
+	out  []bigquery.Value
+	keys []bigquery.Value
+}
+
+func sortRows(rows []sortRow, keys []orderKey) error {
+	var err error
+	sort.SliceStable(rows, func(i, j int) bool {
+		for n, k := range keys {
+			a, b := rows[i].keys[n], rows[j].keys[n]
+			var c int
+			switch {
+			case a == nil && b == nil:
+				continue
+			case a == nil:
+				c = -1
+				if !k.nullsFirst {
+					c = 1
+				}
+			case b == nil:
+				c = 1
+				if !k.nullsFirst {
+					c = -1
+				}
+			default:
+				var cerr error
+				if c, cerr = compareValues(a, b); cerr != nil && err == nil {
+					err = cerr
+				}
+				if k.desc {
+					c = -c
+				}
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return err
+}
+
+// orderKey compiles an ORDER BY item. Aliases and ordinals refer to the
+// output columns in outCols; other expressions are compiled by c over the
+// input. For a set operation, items is nil and the input is the output.
+func (c *compiler) orderKey(item orderItem, items []namedItem, outCols []column) (orderKey, error) {
+	k := orderKey{out: -1, desc: item.desc, nullsFirst: !item.desc}
+	if item.nullsFirst != nil {
+		k.nullsFirst = *item.nullsFirst
+	}
+	if lit, ok := item.expr.(*literal); ok {
+		n, ok := lit.v.(int64)
+		if !ok || n < 1 || int(n) > len(outCols) {
+			return k, fmt.Errorf("ORDER BY column number %v is out of range", lit.v)
+		}
+		k.out = int(n - 1)
+		return k, nil
+	}
+	if p, ok := item.expr.(*pathExpr); ok && len(p.parts) == 1 {
+		for i, col := range outCols {
+			if col.name != "" && strings.EqualFold(col.name, p.parts[0]) && (items == nil || items[i].alias) {
+				k.out = i
+				return k, nil
+			}
+		}
+	}
+	x, err := c.compile(item.expr)
+	if err != nil {
+		return k, err
+	}
+	k.x = x
+	return k, nil
+}
+
+// A namedItem is a select list item after star expansion.
+type namedItem struct {
+	expr  expr
+	name  string
+	alias bool // Whether the name is an explicit alias.
+}
+
+func (ev *evaluator) selectStmt(s *selectStmt, orderBy []orderItem) (*relation, error) {
+	in := &relation{rows: [][]bigquery.Value{{}}}
+	if s.from != nil {
+		var err error
+		if in, err = ev.from(s.from); err != nil {
+			return nil, err
+		}
+	}
+	base := &compiler{ev: ev, cols: in.cols}
+	rows := in.rows
+	if s.where != nil {
+		if containsAgg(s.where) {
+			return nil, errors.New("aggregate functions are not allowed in WHERE")
+		}
+		cond, err := base.compileBool(s.where)
+		if err != nil {
+			return nil, err
+		}
+		if rows, err = filterRows(rows, cond); err != nil {
+			return nil, err
+		}
+	}
+	items, err := expandItems(s.items, in.cols)
+	if err != nil {
+		return nil, err
+	}
+	aggregate := len(s.groupBy) > 0 || s.having != nil
+	for _, item := range items {
+		aggregate = aggregate || containsAgg(item.expr)
+	}
+	for _, item := range orderBy {
+		aggregate = aggregate || containsAgg(item.expr)
+	}
+
+	// Compile the select list, HAVING and ORDER BY. In an aggregation, they
+	// are evaluated over the group's first row followed by the values of the
+	// aggregate functions they contain.
+	c := base
+	var specs []*aggSpec
+	var groupBy []expr
+	if aggregate {
+		if groupBy, err = groupExprs(s, base, items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if err := checkGrouped(item.expr, groupBy, base); err != nil {
+				return nil, err
+			}
+		}
+		c = &compiler{ev: ev, cols: in.cols, aggs: &specs, base: len(in.cols)}
+	}
+	exprs := make([]*compiled, len(items))
+	cols := make([]column, len(items))
+	for i, item := range items {
+		x, err := c.compile(item.expr)
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = x
+		cols[i] = column{name: item.name, fs: x.typ, untyped: x.null}
+	}
+	nameAnonymous(cols)
+	var having *compiled
+	if s.having != nil {
+		if having, err = c.compileBool(s.having); err != nil {
+			return nil, err
+		}
+	}
+	keys := make([]orderKey, len(orderBy))
+	for i, item := range orderBy {
+		if keys[i], err = c.orderKey(item, items, cols); err != nil {
+			return nil, err
+		}
+	}
+
+	if aggregate {
+		if rows, err = ev.aggregate(groupBy, base, rows, len(in.cols), specs); err != nil {
+			return nil, err
+		}
+		if having != nil {
+			if rows, err = filterRows(rows, having); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make([]sortRow, 0, len(rows))
+	for _, row := range rows {
+		o := make([]bigquery.Value, len(exprs))
+		for i, x := range exprs {
+			v, err := x.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			o[i] = v
+		}
+		sr := sortRow{out: o}
+		for _, k := range keys {
+			v, err := k.value(row, o)
+			if err != nil {
+				return nil, err
+			}
+			sr.keys = append(sr.keys, v)
+		}
+		out = append(out, sr)
+	}
+	if s.distinct {
+		seen := map[string]bool{}
+		uniq := out[:0]
+		for _, sr := range out {
+			if k := valueKey(sr.out); !seen[k] {
+				seen[k] = true
+				uniq = append(uniq, sr)
+			}
+		}
+		out = uniq
+	}
+	if len(keys) > 0 {
+		if err := sortRows(out, keys); err != nil {
+			return nil, err
+		}
+	}
+	rel := &relation{cols: cols, rows: make([][]bigquery.Value, len(out))}
+	for i, sr := range out {
+		rel.rows[i] = sr.out
+	}
+	return rel, nil
+}
+
+// aggregate groups rows and returns one row per group: the group's first row,
+// followed by the values of the aggregate functions.
+func (ev *evaluator) aggregate(groupBy []expr, base *compiler, rows [][]bigquery.Value, width int, specs []*aggSpec) ([][]bigquery.Value, error) {
+	var groupKeys []*compiled
+	for _, e := range groupBy {
+		x, err := base.compile(e)
+		if err != nil {
+			return nil, err
+		}
+		groupKeys = append(groupKeys, x)
+	}
+	var groups [][][]bigquery.Value
+	if len(groupKeys) == 0 {
+		groups = [][][]bigquery.Value{rows}
+	} else {
+		index := map[string]int{}
+		for _, row := range rows {
+			key := make([]bigquery.Value, len(groupKeys))
+			for i, x := range groupKeys {
+				v, err := x.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				key[i] = v
+			}
+			k := valueKey(key)
+			i, ok := index[k]
+			if !ok {
+				i = len(groups)
+				index[k] = i
+				groups = append(groups, nil)
+			}
+			groups[i] = append(groups[i], row)
+		}
+	}
+	out := make([][]bigquery.Value, len(groups))
+	for i, g := range groups {
+		row := make([]bigquery.Value, width, width+len(specs))
+		if len(g) > 0 {
+			copy(row, g[0])
+		}
+		for _, spec := range specs {
+			v, err := spec.compute(g)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, v)
+		}
+		out[i] = row
+	}
+	return out, nil
+}
+
+// groupExprs returns the expressions of a GROUP BY clause, with column
+// numbers and select list aliases replaced by the expressions they refer to.
+func groupExprs(s *selectStmt, base *compiler, items []namedItem) ([]expr, error) {
+	var out []expr
+	for _, g := range s.groupBy {
+		e := g
+		if lit, ok := g.(*literal); ok {
+			n, ok := lit.v.(int64)
+			if !ok || n < 1 || int(n) > len(items) {
+				return nil, fmt.Errorf("GROUP BY column number %v is out of range", lit.v)
+			}
+			e = items[n-1].expr
+		} else if p, ok := g.(*pathExpr); ok && len(p.parts) == 1 {
+			// An alias of the select list, unless it names an input column.
+			if _, err := base.resolve(p.parts); err != nil {
+				for _, item := range items {
+					if item.alias && strings.EqualFold(item.name, p.parts[0]) {
+						e = item.expr
+					}
+				}
+			}
+		}
+		if containsAgg(e) {
+			return nil, errors.New("aggregate functions are not allowed in GROUP BY")
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// checkGrouped reports an error if an expression of an aggregating select
+// list refers to a column outside an aggregate function that isn't grouped.
+func checkGrouped(e expr, groupBy []expr, base *compiler) error {
+	if e == nil || slices.ContainsFunc(groupBy, func(g expr) bool { return reflect.DeepEqual(e, g) }) {
+		return nil
+	}
+	switch e := e.(type) {
+	case *callExpr:
+		if isAggregate(e.name) {
+			return nil
+		}
+	case *subqueryExpr:
+		return nil
+	case *pathExpr:
+		for n := 1; n < len(e.parts); n++ {
+			if slices.ContainsFunc(groupBy, func(g expr) bool { return reflect.DeepEqual(&pathExpr{parts: e.parts[:n]}, g) }) {
+				return nil
+			}
+		}
+		// Names that aren't input columns are reported when compiled.
+		if _, err := (&compiler{ev: &evaluator{req: base.ev.req}, cols: base.cols}).resolve(e.parts); err != nil {
+			return nil
+		}
+		return fmt.Errorf("SELECT list expression references column %s which is neither grouped nor aggregated", strings.Join(e.parts, "."))
+	}
+	for _, x := range children(e) {
+		if err := checkGrouped(x, groupBy, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func filterRows(rows [][]bigquery.Value, cond *compiled) ([][]bigquery.Value, error) {
+	var out [][]bigquery.Value
+	for _, row := range rows {
+		v, err := cond.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if v == true {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+// expandItems expands the stars of a select list.
+func expandItems(items []selectItem, cols []column) ([]namedItem, error) {
+	var out []namedItem
+	for _, item := range items {
+		if !item.star {
+			name, alias := item.alias, item.alias != ""
+			if !alias {
+				name = implicitName(item.expr)
+			}
+			out = append(out, namedItem{expr: item.expr, name: name, alias: alias})
+			continue
+		}
+		var expanded []namedItem
+		switch {
+		case item.qual == nil:
+			for i, col := range cols {
+				if !col.hidden {
+					expanded = append(expanded, namedItem{expr: &colExpr{idx: i, field: -1}, name: col.name})
+				}
+			}
+		case len(item.qual) == 1 && hasQual(cols, item.qual[0]):
+			for i, col := range cols {
+				if strings.EqualFold(col.qual, item.qual[0]) {
+					expanded = append(expanded, namedItem{expr: &colExpr{idx: i, field: -1}, name: col.name})
+				}
+			}
+		default:
+			// The fields of a struct.
+			c := &compiler{cols: cols}
+			x, err := c.resolve(item.qual)
+			if err != nil {
+				return nil, err
+			}
+			if x.typ.Type != bigquery.RecordFieldType || x.typ.Repeated {
+				return nil, fmt.Errorf("dot-star is not supported for type %s", typeString(x.typ))
+			}
+			for i, f := range x.typ.Schema {
+				expanded = append(expanded, namedItem{expr: &binaryExpr{op: ".", l: &pathExpr{parts: item.qual}, r: &literal{v: f.Name}}, name: x.typ.Schema[i].Name})
+			}
+		}
+		for _, name := range item.except {
+			i := slices.IndexFunc(expanded, func(n namedItem) bool { return strings.EqualFold(n.name, name) })
+			if i < 0 {
+				return nil, fmt.Errorf("column %s in SELECT * EXCEPT list does not exist", name)
+			}
+			expanded = slices.Delete(expanded, i, i+1)
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+func hasQual(cols []column, q string) bool {
+	return (&relation{cols: cols}).hasQual(q)
+}
+
+// implicitName returns the name of a select list item without an alias.
+func implicitName(e expr) string {
+	switch e := e.(type) {
+	case *pathExpr:
+		return e.parts[len(e.parts)-1]
+	case *binaryExpr:
+		if e.op == "." {
+			return e.r.(*literal).v.(string)
+		}
+	}
+	return ""
+}
+
+// nameAnonymous names the anonymous columns f0_, f1_ and so on.
+func nameAnonymous(cols []column) {
+	n := 0
+	for i := range cols {
+		if cols[i].name == "" {
+			cols[i].name = fmt.Sprintf("f%d_", n)
+			n++
+		}
+	}
+}
+
+func (ev *evaluator) from(f fromItem) (*relation, error) {
+	switch f := f.(type) {
+	case *tableRef:
+		return ev.tableRef(f)
+	case *subqueryRef:
+		rel, err := ev.query(f.query)
+		if err != nil {
+			return nil, err
+		}
+		return rel.withQual(f.alias), nil
+	case *unnestRef:
+		return ev.lateral(&join{kind: "CROSS"}, &relation{rows: [][]bigquery.Value{{}}}, f)
+	case *join:
+		return ev.join(f)
+	}
+	return nil, fmt.Errorf("unsupported FROM item %T", f)
+}
+
+func (ev *evaluator) tableRef(t *tableRef) (*relation, error) {
+	name := t.path[len(t.path)-1]
+	qual := t.alias
+	if qual == "" {
+		qual = name
+	}
+	if len(t.path) == 1 {
+		if rel, ok := ev.ctes[strings.ToLower(name)]; ok {
+			return rel.withQual(qual), nil
+		}
+	}
+	projectID, datasetID := ev.req.DefaultProjectID, ev.req.DefaultDatasetID
+	switch len(t.path) {
+	case 1:
+	case 2:
+		datasetID = t.path[0]
+	case 3:
+		projectID, datasetID = t.path[0], t.path[1]
+	default:
+		return nil, fmt.Errorf("invalid table name %s", strings.Join(t.path, "."))
+	}
+	if datasetID == "" {
+		return nil, fmt.Errorf("table %q must be qualified with a dataset (e.g. dataset.table)", name)
+	}
+	schema, rows, err := ev.req.Table(projectID, datasetID, name)
+	if err != nil {
+		return nil, err
+	}
+	rel := &relation{rows: rows}
+	for _, fs := range schema {
+		rel.cols = append(rel.cols, column{qual: qual, name: fs.Name, fs: fs})
+	}
+	return rel, nil
+}
+
+func (ev *evaluator) join(j *join) (*relation, error) {
+	l, err := ev.from(j.l)
+	if err != nil {
+		return nil, err
+	}
+	// UNNEST and paths to arrays on the right can refer to the left.
+	switch r := j.r.(type) {
+	case *unnestRef:
+		return ev.lateral(j, l, r)
+	case *tableRef:
+		if len(r.path) >= 2 && (l.hasQual(r.path[0]) || slices.ContainsFunc(l.cols, func(c column) bool { return strings.EqualFold(c.name, r.path[0]) })) {
+			alias := r.alias
+			if alias == "" {
+				alias = r.path[len(r.path)-1]
+			}
+			return ev.lateral(j, l, &unnestRef{array: &pathExpr{parts: r.path}, alias: alias})
+		}
+	}
+	r, err := ev.from(j.r)
+	if err != nil {
+		return nil, err
+	}
+	nl := len(l.cols)
+	cols := slices.Concat(l.cols, r.cols)
+	var cond *compiled
+	var using [][2]int
+	switch {
+	case j.on != nil:
+		c := &compiler{ev: ev, cols: cols}
+		if cond, err = c.compileBool(j.on); err != nil {
+			return nil, err
+		}
+	case j.using != nil:
+		for _, name := range j.using {
+			li := slices.IndexFunc(l.cols, func(c column) bool { return !c.hidden && strings.EqualFold(c.name, name) })
+			ri := slices.IndexFunc(r.cols, func(c column) bool { return !c.hidden && strings.EqualFold(c.name, name) })
+			if li < 0 || ri < 0 {
+				return nil, fmt.Errorf("column %s in USING clause not found on both sides of join", name)
+			}
+			cols[nl+ri].hidden = true
+			using = append(using, [2]int{li, nl + ri})
+		}
+	}
+	match := func(row []bigquery.Value) (bool, error) {
+		for _, u := range using {
+			a, b := row[u[0]], row[u[1]]
+			if a == nil || b == nil {
+				return false, nil
+			}
+			if c, err := compareValues(a, b); err != nil || c != 0 {
+				return false, err
+			}
+		}
+		if cond == nil {
+			return true, nil
+		}
+		v, err := cond.eval(row)
+		return v == true, err
+	}
+	var rows [][]bigquery.Value
+	matchedR := make([]bool, len(r.rows))
+	for _, lrow := range l.rows {
+		matched := false
+		for i, rrow := range r.rows {
+			row := slices.Concat(lrow, rrow)
+			ok, err := match(row)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rows = append(rows, row)
+				matched = true
+				matchedR[i] = true
+			}
+		}
+		if !matched && (j.kind == "LEFT" || j.kind == "FULL") {
+			rows = append(rows, slices.Concat(lrow, make([]bigquery.Value, len(r.cols))))
+		}
+	}
+	if j.kind == "RIGHT" || j.kind == "FULL" {
+		for i, rrow := range r.rows {
+			if !matchedR[i] {
+				row := slices.Concat(make([]bigquery.Value, nl), rrow)
+				// The columns of USING take their values from either side.
+				for _, u := range using {
+					row[u[0]] = row[u[1]]
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return &relation{cols: cols, rows: rows}, nil
+}
+
+// lateral joins l with an UNNEST, evaluated for each row of l.
+func (ev *evaluator) lateral(j *join, l *relation, u *unnestRef) (*relation, error) {
+	if j.kind == "RIGHT" || j.kind == "FULL" || j.using != nil {
+		return nil, fmt.Errorf("%s JOIN with UNNEST is not supported", j.kind)
+	}
+	c := &compiler{ev: ev, cols: l.cols}
+	arr, err := c.compile(u.array)
+	if err != nil {
+		return nil, err
+	}
+	if !arr.typ.Repeated && !arr.null {
+		return nil, fmt.Errorf("UNNEST requires an array, got %s", typeString(arr.typ))
+	}
+	elem := *arr.typ
+	elem.Repeated = false
+	var ucols []column
+	switch {
+	case u.alias != "":
+		ucols = append(ucols, column{name: u.alias, fs: &elem})
+	case elem.Type == bigquery.RecordFieldType:
+		// The fields of anonymous structs are columns themselves.
+		for _, f := range elem.Schema {
+			ucols = append(ucols, column{name: f.Name, fs: f})
+		}
+	default:
+		ucols = append(ucols, column{fs: &elem})
+	}
+	if u.withOffset {
+		name := u.offsetAlias
+		if name == "" {
+			name = "offset"
+		}
+		ucols = append(ucols, column{name: name, fs: &bigquery.FieldSchema{Type: bigquery.IntegerFieldType}})
+	}
+	cols := slices.Concat(l.cols, ucols)
+	var cond *compiled
+	if j.on != nil {
+		cc := &compiler{ev: ev, cols: cols}
+		if cond, err = cc.compileBool(j.on); err != nil {
+			return nil, err
+		}
+	}
+	var rows [][]bigquery.Value
+	for _, lrow := range l.rows {
+		v, err := arr.eval(lrow)
+		if err != nil {
+			return nil, err
+		}
+		elems, _ := v.([]bigquery.Value)
+		matched := false
+		for i, e := range elems {
+			row := slices.Clone(lrow)
+			if u.alias == "" && elem.Type == bigquery.RecordFieldType {
+				fields, _ := e.([]bigquery.Value)
+				if fields == nil {
+					fields = make([]bigquery.Value, len(elem.Schema))
+				}
+				row = append(row, fields...)
+			} else {
+				row = append(row, e)
+			}
+			if u.withOffset {
+				row = append(row, int64(i))
+			}
+			if cond != nil {
+				ok, err := cond.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				if ok != true {
+					continue
+				}
+			}
+			rows = append(rows, row)
+			matched = true
+		}
+		if !matched && j.kind == "LEFT" {
+			rows = append(rows, slices.Concat(lrow, make([]bigquery.Value, len(ucols))))
+		}
+	}
+	return &relation{cols: cols, rows: rows}, nil
+}
+
+// colExpr refers to a column by position, as expanded from a star.
+type colExpr struct {
+	idx   int
+	field int // Unused; -1.
+}
+
+// A compiled expression evaluates over a row of its compiler's columns.
+type compiled struct {
+	eval func(row []bigquery.Value) (bigquery.Value, error)
+	typ  *bigquery.FieldSchema
+
+	null bool           // An untyped NULL literal.
+	lit  bool           // A constant, whose value is v.
+	v    bigquery.Value // The value of a constant.
+}
+
+func constant(v bigquery.Value, typ *bigquery.FieldSchema) *compiled {
+	return &compiled{
+		eval: func([]bigquery.Value) (bigquery.Value, error) { return v, nil },
+		typ:  typ,
+		lit:  true,
+		v:    v,
+	}
+}
+
+type compiler struct {
+	ev   *evaluator
+	cols []column
+
+	// aggs collects the aggregate functions of an aggregating select. It is
+	// nil when aggregate functions are not allowed.
+	aggs *[]*aggSpec
+	// base is the index of the first aggregate value in a row.
+	base int
+}
+
+func (c *compiler) compileBool(e expr) (*compiled, error) {
+	x, err := c.compile(e)
+	if err != nil {
+		return nil, err
+	}
+	if x.typ.Type != bigquery.BooleanFieldType && !x.null || x.typ.Repeated {
+		return nil, fmt.Errorf("expected BOOL, got %s", typeString(x.typ))
+	}
+	return x, nil
+}
+
+func (c *compiler) compile(e expr) (*compiled, error) {
+	switch e := e.(type) {
+	case *literal:
+		switch v := e.v.(type) {
+		case nil:
+			x := constant(nil, &bigquery.FieldSchema{Type: bigquery.IntegerFieldType})
+			x.null = true
+			return x, nil
+		case uint64Literal:
+			return nil, fmt.Errorf("integer literal %d is out of range", uint64(v))
+		}
+		return constant(e.v, &bigquery.FieldSchema{Type: e.typ}), nil
+	case *paramRef:
+		return c.param(e)
+	case *pathExpr:
+		return c.resolve(e.parts)
+	case *colExpr:
+		col := c.cols[e.idx]
+		return &compiled{
+			eval: func(row []bigquery.Value) (bigquery.Value, error) { return row[e.idx], nil },
+			typ:  col.fs,
+			null: col.untyped,
+		}, nil
+	case *unaryExpr:
+		return c.unary(e)
+	case *binaryExpr:
+		return c.binary(e)
+	case *isExpr:
+		return c.isExpr(e)
+	case *inExpr:
+		return c.inExpr(e)
+	case *subqueryExpr:
+		return c.subqueryExpr(e)
+	case *betweenExpr:
+		return c.between(e)
+	case *likeExpr:
+		return c.like(e)
+	case *caseExpr:
+		return c.caseExpr(e)
+	case *castExpr:
+		x, err := c.compile(e.x)
+		if err != nil {
+			return nil, err
+		}
+		return castCompiled(x, e.typ, e.safe)
+	case *callExpr:
+		if isAggregate(e.name) {
+			return c.aggregate(e)
+		}
+		return c.call(e)
+	case *arrayExpr:
+		return c.array(e)
+	case *structExpr:
+		return c.structExpr(e)
+	case *indexExpr:
+		return c.index(e)
+	case *intervalExpr:
+		return c.interval(e)
+	case *extractExpr:
+		x, err := c.compile(e.x)
+		if err != nil {
+			return nil, err
+		}
+		return extract(e.part, x)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", e)
+}
+
+func (c *compiler) param(p *paramRef) (*compiled, error) {
+	pos := 0
+	for _, qp := range c.ev.req.Params {
+		var ok bool
+		if p.name != "" {
+			ok = strings.EqualFold(qp.Name, p.name)
+		} else if qp.Name == "" {
+			ok = pos == p.pos
+			pos++
+		}
+		if ok {
+			typ := *qp.Type
+			typ.Name = ""
+			return constant(qp.Value, &typ), nil
+		}
+	}
+	if p.name != "" {
+		return nil, fmt.Errorf("query parameter '%s' not found", p.name)
+	}
+	return nil, fmt.Errorf("positional query parameter %d not found", p.pos+1)
+}
+
+// currentFuncs are the functions that can be called without parentheses.
+var currentFuncs = map[string]bool{"CURRENT_DATE": true, "CURRENT_DATETIME": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true}
+
+// resolve compiles a path: a column, optionally qualified, followed by
+// struct field names.
+func (c *compiler) resolve(parts []string) (*compiled, error) {
+	idx, rest := -1, parts
+	if len(parts) >= 2 {
+		for i, col := range c.cols {
+			if col.qual != "" && strings.EqualFold(col.qual, parts[0]) && strings.EqualFold(col.name, parts[1]) {
+				idx, rest = i, parts[2:]
+				break
+			}
+		}
+	}
+	if idx < 0 {
+		for i, col := range c.cols {
+			if !col.hidden && col.name != "" && strings.EqualFold(col.name, parts[0]) {
+				if idx >= 0 {
+					return nil, fmt.Errorf("column name %s is ambiguous", parts[0])
+				}
+				idx, rest = i, parts[1:]
+			}
+		}
+	}
+	var x *compiled
+	if idx >= 0 {
+		col := c.cols[idx]
+		x = &compiled{
+			eval: func(row []bigquery.Value) (bigquery.Value, error) { return row[idx], nil },
+			typ:  col.fs,
+			null: col.untyped,
+		}
+	} else if hasQual(c.cols, parts[0]) {
+		if len(parts) > 1 {
+			return nil, fmt.Errorf("name %s not found inside %s", parts[1], parts[0])
+		}
+		// A table alias alone is its row as a struct.
+		var idxs []int
+		typ := &bigquery.FieldSchema{Type: bigquery.RecordFieldType}
+		for i, col := range c.cols {
+			if strings.EqualFold(col.qual, parts[0]) {
+				idxs = append(idxs, i)
+				f := *col.fs
+				f.Name = col.name
+				typ.Schema = append(typ.Schema, &f)
+			}
+		}
+		x = &compiled{
+			eval: func(row []bigquery.Value) (bigquery.Value, error) {
+				s := make([]bigquery.Value, len(idxs))
+				for i, j := range idxs {
+					s[i] = row[j]
+				}
+				return s, nil
+			},
+			typ: typ,
+		}
+		rest = nil
+	} else if y, err := c.resolveOuter(parts); y != nil || err != nil {
+		return y, err
+	} else if len(parts) == 1 && currentFuncs[strings.ToUpper(parts[0])] {
+		return c.call(&callExpr{name: strings.ToUpper(parts[0])})
+	} else {
+		return nil, fmt.Errorf("unrecognized name: %s", parts[0])
+	}
+	for _, name := range rest {
+		var err error
+		if x, err = fieldAccess(x, name); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// resolveOuter resolves a name in the queries enclosing a subquery. It
+// returns nil if the name is not found.
+func (c *compiler) resolveOuter(parts []string) (*compiled, error) {
+	sc := c.ev.outer
+	if sc == nil {
+		return nil, nil
+	}
+	oc := &compiler{ev: sc.ev, cols: sc.cols}
+	y, err := oc.resolve(parts)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "unrecognized name") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sc.used = true
+	return &compiled{
+		typ:  y.typ,
+		null: y.null,
+		eval: func([]bigquery.Value) (bigquery.Value, error) {
+			if sc.row == nil {
+				return nil, nil
+			}
+			return y.eval(sc.row)
+		},
+	}, nil
+}
+
+// subquery compiles a subquery, and returns the columns of its result and a
+// function that evaluates it for a row of the enclosing query. The columns
+// are found by evaluating the subquery for a row of NULLs; a subquery that
+// doesn't refer to the enclosing query is evaluated only once.
+func (c *compiler) subquery(q *queryStmt) ([]column, func(row []bigquery.Value) (*relation, error), error) {
+	sc := &scope{ev: c.ev, cols: c.cols}
+	ev := &evaluator{req: c.ev.req, ctes: c.ev.ctes, outer: sc}
+	probe, err := ev.query(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !sc.used {
+		return probe.cols, func([]bigquery.Value) (*relation, error) { return probe, nil }, nil
+	}
+	return probe.cols, func(row []bigquery.Value) (*relation, error) {
+		saved := sc.row
+		sc.row = row
+		defer func() { sc.row = saved }()
+		return ev.query(q)
+	}, nil
+}
+
+// subqueryExpr compiles a scalar, ARRAY or EXISTS subquery.
+func (c *compiler) subqueryExpr(e *subqueryExpr) (*compiled, error) {
+	cols, sub, err := c.subquery(e.query)
+	if err != nil {
+		return nil, err
+	}
+	if e.kind == "EXISTS" {
+		return &compiled{
+			typ: boolType,
+			eval: func(row []bigquery.Value) (bigquery.Value, error) {
+				rel, err := sub(row)
+				if err != nil {
+					return nil, err
+				}
+				return len(rel.rows) > 0, nil
+			},
+		}, nil
+	}
+	if len(cols) != 1 {
+		return nil, fmt.Errorf("%s subquery must return exactly one column, got %d", strings.ToLower(e.kind), len(cols))
+	}
+	typ := cols[0].fs
+	if e.kind == "ARRAY" {
+		if typ.Repeated {
+			return nil, errors.New("cannot use array subquery with an ARRAY column")
+		}
+		elem := *typ
+		elem.Name = ""
+		elem.Repeated = true
+		typ = &elem
+	}
+	kind := e.kind
+	return &compiled{
+		typ:  typ,
+		null: cols[0].untyped,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			rel, err := sub(row)
+			if err != nil {
+				return nil, err
+			}
+			if kind == "ARRAY" {
+				out := make([]bigquery.Value, len(rel.rows))
+				for i, r := range rel.rows {
+					if r[0] == nil {
+						return nil, errors.New("array cannot have a NULL element")
+					}
+					out[i] = r[0]
+				}
+				return out, nil
+			}
+			switch len(rel.rows) {
+			case 0:
+				return nil, nil
+			case 1:
+				return rel.rows[0][0], nil
+			}
+			return nil, errors.New("scalar subquery produced more than one element")
+		},
+	}, nil
+}
+
+func fieldAccess(x *compiled, name string) (*compiled, error) {
+	if x.typ.Type != bigquery.RecordFieldType || x.typ.Repeated {
+		return nil, fmt.Errorf("cannot access field %s on a value with type %s", name, typeString(x.typ))
+	}
+	i := fieldIndex(x.typ.Schema, name)
+	if i < 0 {
+		return nil, fmt.Errorf("field name %s does not exist in %s", name, typeString(x.typ))
+	}
+	return &compiled{
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			v, err := x.eval(row)
+			if v == nil || err != nil {
+				return nil, err
+			}
+			return v.([]bigquery.Value)[i], nil
+		},
+		typ: x.typ.Schema[i],
+	}, nil
+}
+
+func (c *compiler) unary(e *unaryExpr) (*compiled, error) {
+	x, err := c.compile(e.x)
+	if err != nil {
+		return nil, err
+	}
+	var typ *bigquery.FieldSchema
+	var fn func(bigquery.Value) (bigquery.Value, error)
+	switch e.op {
+	case "NOT":
+		if x.typ.Type != bigquery.BooleanFieldType && !x.null {
+			return nil, fmt.Errorf("NOT expects BOOL, got %s", typeString(x.typ))
+		}
+		typ = boolType
+		fn = func(v bigquery.Value) (bigquery.Value, error) { return !v.(bool), nil }
+	case "-":
+		typ = x.typ
+		switch x.typ.Type {
+		case bigquery.IntegerFieldType:
+			fn = func(v bigquery.Value) (bigquery.Value, error) {
+				if v.(int64) == math.MinInt64 {
+					return nil, errors.New("int64 overflow: -(-9223372036854775808)")
+				}
+				return -v.(int64), nil
+			}
+		case bigquery.FloatFieldType:
+			fn = func(v bigquery.Value) (bigquery.Value, error) { return -v.(float64), nil }
+		case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+			fn = func(v bigquery.Value) (bigquery.Value, error) { return new(big.Rat).Neg(v.(*big.Rat)), nil }
+		case bigquery.IntervalFieldType:
+			fn = func(v bigquery.Value) (bigquery.Value, error) {
+				return negateInterval(v.(*bigquery.IntervalValue)), nil
+			}
+		default:
+			return nil, fmt.Errorf("unary minus expects a number, got %s", typeString(x.typ))
+		}
+	case "~":
+		if x.typ.Type != bigquery.IntegerFieldType {
+			return nil, fmt.Errorf("bitwise not expects INT64, got %s", typeString(x.typ))
+		}
+		typ = x.typ
+		fn = func(v bigquery.Value) (bigquery.Value, error) { return ^v.(int64), nil }
+	}
+	if x.typ.Repeated {
+		return nil, fmt.Errorf("operator %s does not accept arrays", e.op)
+	}
+	return strict(typ, fn, x), nil
+}
+
+var (
+	boolType   = &bigquery.FieldSchema{Type: bigquery.BooleanFieldType}
+	intType    = &bigquery.FieldSchema{Type: bigquery.IntegerFieldType}
+	floatType  = &bigquery.FieldSchema{Type: bigquery.FloatFieldType}
+	stringType = &bigquery.FieldSchema{Type: bigquery.StringFieldType}
+)
+
+// strict returns an expression that applies fn to the values of args, or is
+// NULL if any of them is NULL.
+func strict[F func(bigquery.Value) (bigquery.Value, error) | func(bigquery.Value, bigquery.Value) (bigquery.Value, error) | func([]bigquery.Value) (bigquery.Value, error)](typ *bigquery.FieldSchema, fn F, args ...*compiled) *compiled {
+	return &compiled{
+		typ: typ,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			vs := make([]bigquery.Value, len(args))
+			for i, a := range args {
+				v, err := a.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				if v == nil {
+					return nil, nil
+				}
+				vs[i] = v
+			}
+			switch f := any(fn).(type) {
+			case func(bigquery.Value) (bigquery.Value, error):
+				return f(vs[0])
+			case func(bigquery.Value, bigquery.Value) (bigquery.Value, error):
+				return f(vs[0], vs[1])
+			case func([]bigquery.Value) (bigquery.Value, error):
+				return f(vs)
+			}
+			panic("unreachable")
+		},
+	}
+}
+
+func (c *compiler) binary(e *binaryExpr) (*compiled, error) {
+	l, err := c.compile(e.l)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "." {
+		return fieldAccess(l, e.r.(*literal).v.(string))
+	}
+	r, err := c.compile(e.r)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "AND", "OR":
+		for _, x := range []*compiled{l, r} {
+			if x.typ.Type != bigquery.BooleanFieldType && !x.null || x.typ.Repeated {
+				return nil, fmt.Errorf("%s expects BOOL, got %s", e.op, typeString(x.typ))
+			}
+		}
+		short := e.op == "OR" // The value that decides the result.
+		return &compiled{
+			typ: boolType,
+			eval: func(row []bigquery.Value) (bigquery.Value, error) {
+				a, err := l.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				if a == short {
+					return short, nil
+				}
+				b, err := r.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				if b == short {
+					return short, nil
+				}
+				if a == nil || b == nil {
+					return nil, nil
+				}
+				return !short, nil
+			},
+		}, nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		if l, r, err = comparable(l, r); err != nil {
+			return nil, err
+		}
+		op := e.op
+		return strict(boolType, func(a, b bigquery.Value) (bigquery.Value, error) {
+			if isNaN(a) || isNaN(b) {
+				return op == "!=", nil
+			}
+			n, err := compareValues(a, b)
+			if err != nil {
+				return nil, err
+			}
+			switch op {
+			case "=":
+				return n == 0, nil
+			case "!=":
+				return n != 0, nil
+			case "<":
+				return n < 0, nil
+			case "<=":
+				return n <= 0, nil
+			case ">":
+				return n > 0, nil
+			}
+			return n >= 0, nil
+		}, l, r), nil
+	case "||":
+		if l.typ.Repeated || r.typ.Repeated {
+			return c.call(&callExpr{name: "ARRAY_CONCAT", args: []expr{e.l, e.r}})
+		}
+		return c.call(&callExpr{name: "CONCAT", args: []expr{e.l, e.r}})
+	case "&", "|", "^", "<<", ">>":
+		if l.typ.Type != bigquery.IntegerFieldType && !l.null || r.typ.Type != bigquery.IntegerFieldType && !r.null {
+			return nil, fmt.Errorf("operator %s expects INT64, got %s and %s", e.op, typeString(l.typ), typeString(r.typ))
+		}
+		op := e.op
+		return strict(intType, func(a, b bigquery.Value) (bigquery.Value, error) {
+			x, y := a.(int64), b.(int64)
+			switch op {
+			case "&":
+				return x & y, nil
+			case "|":
+				return x | y, nil
+			case "^":
+				return x ^ y, nil
+			case "<<":
+				if y < 0 {
+					return nil, errors.New("bit shift by a negative amount")
+				}
+				return int64(uint64(x) << min(uint64(y), 64)), nil
+			}
+			if y < 0 {
+				return nil, errors.New("bit shift by a negative amount")
+			}
+			return int64(uint64(x) >> min(uint64(y), 64)), nil
+		}, l, r), nil
+	}
+	return arithmetic(e.op, l, r)
+}
+
+func isNaN(v bigquery.Value) bool {
+	f, ok := v.(float64)
+	return ok && math.IsNaN(f)
+}
+
+// comparable checks that two expressions can be compared, coercing string
+// literals to the type of the other side.
+func comparable(l, r *compiled) (*compiled, *compiled, error) {
+	var err error
+	if l, err = coerceLiteral(l, r.typ); err != nil {
+		return nil, nil, err
+	}
+	if r, err = coerceLiteral(r, l.typ); err != nil {
+		return nil, nil, err
+	}
+	if l.null || r.null {
+		return l, r, nil
+	}
+	if l.typ.Repeated || r.typ.Repeated {
+		return nil, nil, fmt.Errorf("cannot compare arrays: %s and %s", typeString(l.typ), typeString(r.typ))
+	}
+	if l.typ.Type != r.typ.Type && !(isNumeric(l.typ.Type) && isNumeric(r.typ.Type)) {
+		return nil, nil, fmt.Errorf("cannot compare %s with %s", typeString(l.typ), typeString(r.typ))
+	}
+	return l, r, nil
+}
+
+// coerceLiteral converts a STRING literal to the date and time type typ,
+// as GoogleSQL does for comparisons like d > '2024-01-01', and gives an
+// untyped NULL the type typ.
+func coerceLiteral(x *compiled, typ *bigquery.FieldSchema) (*compiled, error) {
+	if x.null {
+		y := constant(nil, typ)
+		y.null = true
+		return y, nil
+	}
+	if !x.lit || x.typ.Type != bigquery.StringFieldType || x.typ.Repeated || typ.Repeated {
+		return x, nil
+	}
+	switch typ.Type {
+	case bigquery.DateFieldType, bigquery.DateTimeFieldType, bigquery.TimeFieldType, bigquery.TimestampFieldType, bigquery.RangeFieldType:
+		return castCompiled(x, typ, false)
+	}
+	return x, nil
+}
+
+func isNumeric(t bigquery.FieldType) bool {
+	switch t {
+	case bigquery.IntegerFieldType, bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return true
+	}
+	return false
+}
+
+// commonType returns the type that values of types a and b can be coerced
+// to.
+func commonType(a, b *bigquery.FieldSchema) (*bigquery.FieldSchema, error) {
+	if a.Repeated != b.Repeated {
+		return nil, fmt.Errorf("%s and %s", typeString(a), typeString(b))
+	}
+	if a.Type == b.Type {
+		if a.Type == bigquery.RecordFieldType && len(a.Schema) != len(b.Schema) {
+			return nil, fmt.Errorf("%s and %s", typeString(a), typeString(b))
+		}
+		return a, nil
+	}
+	if !isNumeric(a.Type) || !isNumeric(b.Type) {
+		return nil, fmt.Errorf("%s and %s", typeString(a), typeString(b))
+	}
+	rank := map[bigquery.FieldType]int{
+		bigquery.IntegerFieldType:    0,
+		bigquery.NumericFieldType:    1,
+		bigquery.BigNumericFieldType: 2,
+		bigquery.FloatFieldType:      3,
+	}
+	t := a.Type
+	if rank[b.Type] > rank[t] {
+		t = b.Type
+	}
+	return &bigquery.FieldSchema{Type: t, Repeated: a.Repeated}, nil
+}
+
+// commonTypeOf returns the common type of expressions, ignoring untyped
+// NULLs.
+func commonTypeOf(xs []*compiled) (*bigquery.FieldSchema, bool, error) {
+	var typ *bigquery.FieldSchema
+	for _, x := range xs {
+		if x.null {
+			continue
+		}
+		if typ == nil {
+			typ = x.typ
+			continue
+		}
+		t, err := commonType(typ, x.typ)
+		if err != nil {
+			return nil, false, fmt.Errorf("no common supertype of %v", err)
+		}
+		typ = t
+	}
+	if typ == nil {
+		return intType, true, nil
+	}
+	return typ, false, nil
+}
+
+// coerceTo converts the values of x to the numeric type typ if needed.
+func coerceTo(x *compiled, typ *bigquery.FieldSchema) *compiled {
+	if x.null || x.typ.Type == typ.Type {
+		return x
+	}
+	from := x.typ
+	return &compiled{
+		typ: typ,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			v, err := x.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			return coerceValue(v, from, typ)
+		},
+	}
+}
+
+// coerceValue converts a value to a wider type.
+func coerceValue(v bigquery.Value, from, to *bigquery.FieldSchema) (bigquery.Value, error) {
+	if v == nil || from.Type == to.Type {
+		return v, nil
+	}
+	if from.Repeated {
+		vs := v.([]bigquery.Value)
+		out := make([]bigquery.Value, len(vs))
+		f, t := *from, *to
+		f.Repeated, t.Repeated = false, false
+		for i, e := range vs {
+			x, err := coerceValue(e, &f, &t)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	}
+	switch to.Type {
+	case bigquery.FloatFieldType:
+		return toFloat(v), nil
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return toRat(v), nil
+	}
+	return nil, fmt.Errorf("cannot coerce %s to %s", typeString(from), typeString(to))
+}
+
+func toFloat(v bigquery.Value) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	}
+	return v.(float64)
+}
+
+func toRat(v bigquery.Value) *big.Rat {
+	switch v := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v)
+	case float64:
+		r, _ := new(big.Rat).SetString(fmt.Sprint(v))
+		return r
+	}
+	return v.(*big.Rat)
+}
+
+func arithmetic(op string, l, r *compiled) (*compiled, error) {
+	if l.typ.Repeated || r.typ.Repeated {
+		return nil, fmt.Errorf("operator %s does not accept arrays", op)
+	}
+	lt, rt := l.typ.Type, r.typ.Type
+	if l.null {
+		lt = rt
+	}
+	if r.null {
+		rt = lt
+	}
+	// Date and time arithmetic.
+	switch {
+	case (op == "+" || op == "-") && rt == bigquery.IntervalFieldType && isDateTime(lt):
+		return dateArith(op, l, r)
+	case op == "+" && lt == bigquery.IntervalFieldType && isDateTime(rt):
+		return dateArith(op, r, l)
+	case (op == "+" || op == "-") && lt == bigquery.DateFieldType && rt == bigquery.IntegerFieldType:
+		sign := int64(1)
+		if op == "-" {
+			sign = -1
+		}
+		return strict(&bigquery.FieldSchema{Type: bigquery.DateFieldType}, func(a, b bigquery.Value) (bigquery.Value, error) {
+			return a.(civil.Date).AddDays(int(sign * b.(int64))), nil
+		}, l, r), nil
+	case lt == bigquery.IntervalFieldType && rt == bigquery.IntervalFieldType && (op == "+" || op == "-"):
+		return strict(&bigquery.FieldSchema{Type: bigquery.IntervalFieldType}, func(a, b bigquery.Value) (bigquery.Value, error) {
+			y := b.(*bigquery.IntervalValue)
+			if op == "-" {
+				y = negateInterval(y)
+			}
+			return addIntervals(a.(*bigquery.IntervalValue), y), nil
+		}, l, r), nil
+	}
+	if !isNumeric(lt) || !isNumeric(rt) {
+		return nil, fmt.Errorf("no matching signature for operator %s for argument types: %s, %s", op, typeString(l.typ), typeString(r.typ))
+	}
+	typ, err := commonType(&bigquery.FieldSchema{Type: lt}, &bigquery.FieldSchema{Type: rt})
+	if err != nil {
+		return nil, err
+	}
+	if op == "/" && typ.Type == bigquery.IntegerFieldType {
+		typ = floatType
+	}
+	l, r = coerceTo(l, typ), coerceTo(r, typ)
+	return strict(typ, func(a, b bigquery.Value) (bigquery.Value, error) {
+		return arith(op, a, b)
+	}, l, r), nil
+}
+
+// arith applies an arithmetic operator to two values of the same type.
+func arith(op string, a, b bigquery.Value) (bigquery.Value, error) {
+	switch x := a.(type) {
+	case int64:
+		y := b.(int64)
+		var z int64
+		switch op {
+		case "+":
+			z = x + y
+			if (z > x) != (y > 0) {
+				return nil, fmt.Errorf("int64 overflow: %d + %d", x, y)
+			}
+		case "-":
+			z = x - y
+			if (z < x) != (y > 0) {
+				return nil, fmt.Errorf("int64 overflow: %d - %d", x, y)
+			}
+		case "*":
+			z = x * y
+			if x != 0 && (z/x != y || x == -1 && y == math.MinInt64 || y == -1 && x == math.MinInt64) {
+				return nil, fmt.Errorf("int64 overflow: %d * %d", x, y)
+			}
+		}
+		return z, nil
+	case float64:
+		y := b.(float64)
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		}
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero: %v / %v", x, y)
+		}
+		return x / y, nil
+	case *big.Rat:
+		y := b.(*big.Rat)
+		switch op {
+		case "+":
+			return new(big.Rat).Add(x, y), nil
+		case "-":
+			return new(big.Rat).Sub(x, y), nil
+		case "*":
+			return new(big.Rat).Mul(x, y), nil
+		}
+		if y.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero: %s / 0", x.RatString())
+		}
+		return new(big.Rat).Quo(x, y), nil
+	}
+	return nil, fmt.Errorf("unsupported operand %T", a)
+}
+
+func isDateTime(t bigquery.FieldType) bool {
+	switch t {
+	case bigquery.DateFieldType, bigquery.DateTimeFieldType, bigquery.TimestampFieldType, bigquery.TimeFieldType:
+		return true
+	}
+	return false
+}
+
+// dateArith adds or subtracts an interval from a date or time.
+func dateArith(op string, d, iv *compiled) (*compiled, error) {
+	typ := d.typ
+	if typ.Type == bigquery.DateFieldType {
+		typ = &bigquery.FieldSchema{Type: bigquery.DateTimeFieldType}
+	}
+	return strict(typ, func(a, b bigquery.Value) (bigquery.Value, error) {
+		y := b.(*bigquery.IntervalValue)
+		if op == "-" {
+			y = negateInterval(y)
+		}
+		if dt, ok := a.(civil.Date); ok {
+			a = civil.DateTime{Date: dt}
+		}
+		return addInterval(a, y)
+	}, d, iv), nil
+}
+
+func (c *compiler) isExpr(e *isExpr) (*compiled, error) {
+	x, err := c.compile(e.x)
+	if err != nil {
+		return nil, err
+	}
+	if e.what != "NULL" && x.typ.Type != bigquery.BooleanFieldType && !x.null {
+		return nil, fmt.Errorf("IS %s expects BOOL, got %s", e.what, typeString(x.typ))
+	}
+	var want bigquery.Value
+	switch e.what {
+	case "TRUE":
+		want = true
+	case "FALSE":
+		want = false
+	}
+	not := e.not
+	return &compiled{
+		typ: boolType,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			v, err := x.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			return (v == want) != not, nil
+		},
+	}, nil
+}
+
+func (c *compiler) inExpr(e *inExpr) (*compiled, error) {
+	x, err := c.compile(e.x)
+	if err != nil {
+		return nil, err
+	}
+	var list []*compiled
+	var arr *compiled
+	var sub func([]bigquery.Value) (*relation, error)
+	if e.query != nil {
+		var cols []column
+		if cols, sub, err = c.subquery(e.query); err != nil {
+			return nil, err
+		}
+		if len(cols) != 1 {
+			return nil, fmt.Errorf("IN subquery must return exactly one column, got %d", len(cols))
+		}
+		if _, _, err := comparable(x, &compiled{typ: cols[0].fs, null: cols[0].untyped}); err != nil {
+			return nil, err
+		}
+	}
+	if e.unnest != nil {
+		if arr, err = c.compile(e.unnest); err != nil {
+			return nil, err
+		}
+		if !arr.typ.Repeated && !arr.null {
+			return nil, fmt.Errorf("IN UNNEST expects an array, got %s", typeString(arr.typ))
+		}
+		elem := *arr.typ
+		elem.Repeated = false
+		if _, _, err := comparable(x, &compiled{typ: &elem}); err != nil {
+			return nil, err
+		}
+	}
+	for _, le := range e.list {
+		y, err := c.compile(le)
+		if err != nil {
+			return nil, err
+		}
+		var xx *compiled
+		if xx, y, err = comparable(x, y); err != nil {
+			return nil, err
+		}
+		if x.null {
+			x = xx
+		}
+		list = append(list, y)
+	}
+	not := e.not
+	return &compiled{
+		typ: boolType,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			v, err := x.eval(row)
+			if v == nil || err != nil {
+				return nil, err
+			}
+			var candidates []bigquery.Value
+			if sub != nil {
+				rel, err := sub(row)
+				if err != nil {
+					return nil, err
+				}
+				for _, r := range rel.rows {
+					candidates = append(candidates, r[0])
+				}
+			} else if arr != nil {
+				a, err := arr.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				candidates, _ = a.([]bigquery.Value)
+			} else {
+				for _, y := range list {
+					w, err := y.eval(row)
+					if err != nil {
+						return nil, err
+					}
+					candidates = append(candidates, w)
+				}
+			}
+			sawNull := false
+			for _, w := range candidates {
+				if w == nil {
+					sawNull = true
+					continue
+				}
+				if isNaN(v) || isNaN(w) {
+					continue
+				}
+				if n, err := compareValues(v, w); err != nil {
+					return nil, err
+				} else if n == 0 {
+					return !not, nil
+				}
+			}
+			if sawNull {
+				return nil, nil
+			}
+			return not, nil
+		},
+	}, nil
+}
+
+func (c *compiler) between(e *betweenExpr) (*compiled, error) {
+	ge, err := c.compile(&binaryExpr{op: ">=", l: e.x, r: e.lo})
+	if err != nil {
+		return nil, err
+	}
+	le, err := c.compile(&binaryExpr{op: "<=", l: e.x, r: e.hi})
+	if err != nil {
+		return nil, err
+	}
+	not := e.not
+	return &compiled{
+		typ: boolType,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			a, err := ge.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			b, err := le.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			var v bigquery.Value
+			switch {
+			case a == false || b == false:
+				v = false
+			case a == nil || b == nil:
+				return nil, nil
+			default:
+				v = true
+			}
+			return v != not, nil
+		},
+	}, nil
+}
+
+func (c *compiler) like(e *likeExpr) (*compiled, error) {
+	x, err := c.compile(e.x)
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.compile(e.pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, y := range []*compiled{x, p} {
+		if y.typ.Type != bigquery.StringFieldType && y.typ.Type != bigquery.BytesFieldType && !y.null || y.typ.Repeated {
+			return nil, fmt.Errorf("LIKE expects STRING or BYTES, got %s", typeString(y.typ))
+		}
+	}
+	cache := map[string]*regexp.Regexp{}
+	not := e.not
+	return strict(boolType, func(a, b bigquery.Value) (bigquery.Value, error) {
+		pat := asString(b)
+		re, ok := cache[pat]
+		if !ok {
+			var err error
+			if re, err = likeRegexp(pat); err != nil {
+				return nil, err
+			}
+			cache[pat] = re
+		}
+		return re.MatchString(asString(a)) != not, nil
+	}, x, p), nil
+}
+
+func asString(v bigquery.Value) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v.(string)
+}
+
+// likeRegexp converts a LIKE pattern to a regular expression.
+func likeRegexp(pat string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	for i := 0; i < len(pat); i++ {
+		switch c := pat[i]; c {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 == len(pat) {
+				return nil, errors.New("LIKE pattern ends with a backslash")
+			}
+			i++
+			sb.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+func (c *compiler) caseExpr(e *caseExpr) (*compiled, error) {
+	var operand *compiled
+	var err error
+	if e.operand != nil {
+		if operand, err = c.compile(e.operand); err != nil {
+			return nil, err
+		}
+	}
+	conds := make([]*compiled, len(e.whens))
+	results := make([]*compiled, 0, len(e.whens)+1)
+	for i, w := range e.whens {
+		if operand != nil {
+			if conds[i], err = c.compile(&binaryExpr{op: "=", l: e.operand, r: w.cond}); err != nil {
+				return nil, err
+			}
+		} else if conds[i], err = c.compileBool(w.cond); err != nil {
+			return nil, err
+		}
+		r, err := c.compile(w.result)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	els := constant(nil, intType)
+	els.null = true
+	if e.els != nil {
+		if els, err = c.compile(e.els); err != nil {
+			return nil, err
+		}
+	}
+	results = append(results, els)
+	typ, null, err := commonTypeOf(results)
+	if err != nil {
+		return nil, fmt.Errorf("CASE results have %v", err)
+	}
+	for i := range results {
+		results[i] = coerceTo(results[i], typ)
+	}
+	return &compiled{
+		typ:  typ,
+		null: null,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			for i, cond := range conds {
+				v, err := cond.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				if v == true {
+					return results[i].eval(row)
+				}
+			}
+			return results[len(results)-1].eval(row)
+		},
+	}, nil
+}
+
+func (c *compiler) array(e *arrayExpr) (*compiled, error) {
+	elems := make([]*compiled, len(e.elems))
+	for i, x := range e.elems {
+		y, err := c.compile(x)
+		if err != nil {
+			return nil, err
+		}
+		if y.typ.Repeated {
+			return nil, errors.New("arrays of arrays are not supported")
+		}
+		elems[i] = y
+	}
+	elem, _, err := commonTypeOf(elems)
+	if err != nil {
+		return nil, fmt.Errorf("array elements have %v", err)
+	}
+	for i := range elems {
+		elems[i] = coerceTo(elems[i], elem)
+	}
+	typ := *elem
+	typ.Repeated = true
+	return &compiled{
+		typ: &typ,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			out := make([]bigquery.Value, len(elems))
+			for i, x := range elems {
+				v, err := x.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				if v == nil {
+					return nil, errors.New("array cannot have a null element")
+				}
+				out[i] = v
+			}
+			return out, nil
+		},
+	}, nil
+}
+
+func (c *compiler) structExpr(e *structExpr) (*compiled, error) {
+	fields := make([]*compiled, len(e.fields))
+	typ := &bigquery.FieldSchema{Type: bigquery.RecordFieldType}
+	for i, x := range e.fields {
+		y, err := c.compile(x)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = y
+		f := *y.typ
+		f.Name = e.names[i]
+		if f.Name == "" {
+			f.Name = implicitName(x)
+		}
+		typ.Schema = append(typ.Schema, &f)
+	}
+	return &compiled{
+		typ: typ,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) {
+			out := make([]bigquery.Value, len(fields))
+			for i, x := range fields {
+				v, err := x.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = v
+			}
+			return out, nil
+		},
+	}, nil
+}
+
+func (c *compiler) index(e *indexExpr) (*compiled, error) {
+	x, err := c.compile(e.x)
+	if err != nil {
+		return nil, err
+	}
+	i, err := c.compile(e.index)
+	if err != nil {
+		return nil, err
+	}
+	if !x.typ.Repeated {
+		return nil, fmt.Errorf("element access is not supported for %s", typeString(x.typ))
+	}
+	if i.typ.Type != bigquery.IntegerFieldType && !i.null {
+		return nil, fmt.Errorf("array index must be INT64, got %s", typeString(i.typ))
+	}
+	elem := *x.typ
+	elem.Repeated = false
+	mode := e.mode
+	return strict(&elem, func(a, b bigquery.Value) (bigquery.Value, error) {
+		arr, n := a.([]bigquery.Value), b.(int64)
+		if strings.HasSuffix(mode, "ORDINAL") {
+			n--
+		}
+		if n < 0 || n >= int64(len(arr)) {
+			if strings.HasPrefix(mode, "SAFE_") {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("array index %d is out of bounds (array length %d)", b.(int64), len(arr))
+		}
+		return arr[n], nil
+	}, x, i), nil
+}
+
+func (c *compiler) interval(e *intervalExpr) (*compiled, error) {
+	n, err := c.compile(e.n)
+	if err != nil {
+		return nil, err
+	}
+	if n.typ.Type != bigquery.IntegerFieldType && !n.null {
+		return nil, fmt.Errorf("INTERVAL expects INT64, got %s", typeString(n.typ))
+	}
+	unit := e.unit
+	return strict(&bigquery.FieldSchema{Type: bigquery.IntervalFieldType}, func(v bigquery.Value) (bigquery.Value, error) {
+		return intervalOf(v.(int64), unit)
+	}, n), nil
+}
+
+// intervalOf returns an interval of n units.
+func intervalOf(n int64, unit string) (*bigquery.IntervalValue, error) {
+	iv := &bigquery.IntervalValue{}
+	if n > math.MaxInt32 || n < math.MinInt32 {
+		if d, ok := unitDuration(unit); ok && n <= math.MaxInt64/int64(d) && n >= math.MinInt64/int64(d) {
+			return bigquery.IntervalValueFromDuration(time.Duration(n) * d), nil
+		}
+		return nil, fmt.Errorf("interval of %d %s is out of range", n, unit)
+	}
+	switch unit {
+	case "YEAR":
+		iv.Years = int32(n)
+	case "QUARTER":
+		iv.Months = int32(n * 3)
+	case "MONTH":
+		iv.Months = int32(n)
+	case "WEEK":
+		iv.Days = int32(n * 7)
+	case "DAY":
+		iv.Days = int32(n)
+	case "HOUR":
+		iv.Hours = int32(n)
+	case "MINUTE":
+		iv.Minutes = int32(n)
+	case "SECOND":
+		iv.Seconds = int32(n)
+	case "MILLISECOND", "MICROSECOND":
+		d, _ := unitDuration(unit)
+		return bigquery.IntervalValueFromDuration(time.Duration(n) * d), nil
+	default:
+		return nil, fmt.Errorf("unsupported INTERVAL unit %s", unit)
+	}
+	return iv, nil
+}
+
+func unitDuration(unit string) (time.Duration, bool) {
+	d, ok := map[string]time.Duration{
+		"MICROSECOND": time.Microsecond,
+		"MILLISECOND": time.Millisecond,
+		"SECOND":      time.Second,
+		"MINUTE":      time.Minute,
+		"HOUR":        time.Hour,
+		"DAY":         24 * time.Hour,
+	}[unit]
+	return d, ok
+}
+
+func negateInterval(iv *bigquery.IntervalValue) *bigquery.IntervalValue {
+	return &bigquery.IntervalValue{
+		Years: -iv.Years, Months: -iv.Months, Days: -iv.Days,
+		Hours: -iv.Hours, Minutes: -iv.Minutes, Seconds: -iv.Seconds, SubSecondNanos: -iv.SubSecondNanos,
+	}
+}
+
+func addIntervals(a, b *bigquery.IntervalValue) *bigquery.IntervalValue {
+	return (&bigquery.IntervalValue{
+		Years: a.Years + b.Years, Months: a.Months + b.Months, Days: a.Days + b.Days,
+		Hours: a.Hours + b.Hours, Minutes: a.Minutes + b.Minutes, Seconds: a.Seconds + b.Seconds,
+		SubSecondNanos: a.SubSecondNanos + b.SubSecondNanos,
+	}).Canonicalize()
+}
+
+// typeString returns the SQL name of a type.
+func typeString(fs *bigquery.FieldSchema) string {
+	var s string
+	switch fs.Type {
+	case bigquery.IntegerFieldType:
+		s = "INT64"
+	case bigquery.FloatFieldType:
+		s = "FLOAT64"
+	case bigquery.BooleanFieldType:
+		s = "BOOL"
+	case bigquery.RecordFieldType:
+		var fields []string
+		for _, f := range fs.Schema {
+			fields = append(fields, strings.TrimSpace(f.Name+" "+typeString(f)))
+		}
+		s = "STRUCT<" + strings.Join(fields, ", ") + ">"
+	case bigquery.RangeFieldType:
+		s = "RANGE<" + string(fs.RangeElementType.Type) + ">"
+	default:
+		s = string(fs.Type)
+	}
+	if fs.Repeated {
+		return "ARRAY<" + s + ">"
+	}
+	return s
+}
+
+// children returns the subexpressions of an expression, excluding those of
+// nested queries.
+func children(e expr) []expr {
+	switch e := e.(type) {
+	case *unaryExpr:
+		return []expr{e.x}
+	case *binaryExpr:
+		return []expr{e.l, e.r}
+	case *isExpr:
+		return []expr{e.x}
+	case *inExpr:
+		return append([]expr{e.x, e.unnest}, e.list...)
+	case *betweenExpr:
+		return []expr{e.x, e.lo, e.hi}
+	case *likeExpr:
+		return []expr{e.x, e.pattern}
+	case *caseExpr:
+		out := []expr{e.operand, e.els}
+		for _, w := range e.whens {
+			out = append(out, w.cond, w.result)
+		}
+		return out
+	case *castExpr:
+		return []expr{e.x}
+	case *callExpr:
+		return e.args
+	case *arrayExpr:
+		return e.elems
+	case *structExpr:
+		return e.fields
+	case *indexExpr:
+		return []expr{e.x, e.index}
+	case *intervalExpr:
+		return []expr{e.n}
+	case *extractExpr:
+		return []expr{e.x}
+	}
+	return nil
+}
+
+// containsAgg reports whether an expression calls an aggregate function.
+func containsAgg(e expr) bool {
+	if e == nil {
+		return false
+	}
+	if c, ok := e.(*callExpr); ok && isAggregate(c.name) {
+		return true
+	}
+	return slices.ContainsFunc(children(e), containsAgg)
+}
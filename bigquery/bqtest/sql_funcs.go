@@ -0,0 +1,2479 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+)
+
+// compareValues orders two non-NULL values of comparable types. NaN sorts
+// before all other numbers.
+func compareValues(a, b bigquery.Value) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, y), nil
+		case float64:
+			return compareFloats(float64(x), y), nil
+		case *big.Rat:
+			return new(big.Rat).SetInt64(x).Cmp(y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64, float64, *big.Rat:
+			return compareFloats(x, toFloat(y)), nil
+		}
+	case *big.Rat:
+		switch y := b.(type) {
+		case int64:
+			return x.Cmp(new(big.Rat).SetInt64(y)), nil
+		case float64:
+			return compareFloats(toFloat(x), y), nil
+		case *big.Rat:
+			return x.Cmp(y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case y:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	case civil.Date:
+		if y, ok := b.(civil.Date); ok {
+			return x.Compare(y), nil
+		}
+	case civil.DateTime:
+		if y, ok := b.(civil.DateTime); ok {
+			return x.Compare(y), nil
+		}
+	case civil.Time:
+		if y, ok := b.(civil.Time); ok {
+			return x.Compare(y), nil
+		}
+	case *bigquery.IntervalValue:
+		if y, ok := b.(*bigquery.IntervalValue); ok {
+			return cmpOrdered(intervalMicros(x), intervalMicros(y)), nil
+		}
+	case *bigquery.RangeValue:
+		if y, ok := b.(*bigquery.RangeValue); ok {
+			if c, err := compareBounds(x.Start, y.Start, -1); c != 0 || err != nil {
+				return c, err
+			}
+			return compareBounds(x.End, y.End, 1)
+		}
+	case []bigquery.Value:
+		if y, ok := b.([]bigquery.Value); ok {
+			for i := 0; i < len(x) && i < len(y); i++ {
+				switch {
+				case x[i] == nil && y[i] == nil:
+					continue
+				case x[i] == nil:
+					return -1, nil
+				case y[i] == nil:
+					return 1, nil
+				}
+				if c, err := compareValues(x[i], y[i]); c != 0 || err != nil {
+					return c, err
+				}
+			}
+			return cmpOrdered(len(x), len(y)), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func cmpOrdered[T int | int64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareBounds compares range bounds, where NULL is unbounded: before all
+// values for a start (side -1) and after them for an end (side 1).
+func compareBounds(a, b bigquery.Value, side int) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return side, nil
+	case b == nil:
+		return -side, nil
+	}
+	return compareValues(a, b)
+}
+
+// intervalMicros approximates an interval in microseconds, with months of 30
+// days, for ordering.
+func intervalMicros(iv *bigquery.IntervalValue) int64 {
+	days := (int64(iv.Years)*12+int64(iv.Months))*30 + int64(iv.Days)
+	return days*86400e6 + int64(iv.Hours)*3600e6 + int64(iv.Minutes)*60e6 + int64(iv.Seconds)*1e6 + int64(iv.SubSecondNanos)/1e3
+}
+
+// valueKey returns a string that is equal for equal values, for grouping.
+func valueKey(v bigquery.Value) string {
+	var sb strings.Builder
+	writeKey(&sb, v)
+	return sb.String()
+}
+
+func writeKey(sb *strings.Builder, v bigquery.Value) {
+	switch v := v.(type) {
+	case nil:
+		sb.WriteString("N")
+	case []bigquery.Value:
+		sb.WriteString("[")
+		for _, e := range v {
+			writeKey(sb, e)
+			sb.WriteString(",")
+		}
+		sb.WriteString("]")
+	case string:
+		sb.WriteString(strconv.Quote(v))
+	case []byte:
+		sb.WriteString("b" + strconv.Quote(string(v)))
+	case int64:
+		sb.WriteString("i" + strconv.FormatInt(v, 10))
+	case float64:
+		if v == 0 {
+			v = 0 // Normalize -0.
+		}
+		sb.WriteString("f" + strconv.FormatFloat(v, 'g', -1, 64))
+	case *big.Rat:
+		sb.WriteString("n" + v.RatString())
+	case time.Time:
+		sb.WriteString("t" + strconv.FormatInt(v.UnixMicro(), 10))
+	case *bigquery.IntervalValue:
+		sb.WriteString("v" + v.Canonicalize().String())
+	case *bigquery.RangeValue:
+		sb.WriteString("r(")
+		writeKey(sb, v.Start)
+		sb.WriteString(",")
+		writeKey(sb, v.End)
+		sb.WriteString(")")
+	default:
+		fmt.Fprintf(sb, "%T:%v", v, v)
+	}
+}
+
+// castCompiled returns x cast to typ. A SAFE_CAST returns NULL instead of
+// failing.
+func castCompiled(x *compiled, typ *bigquery.FieldSchema, safe bool) (*compiled, error) {
+	from := x.typ
+	if x.null {
+		y := constant(nil, typ)
+		return y, nil
+	}
+	if err := checkCast(from, typ); err != nil {
+		return nil, err
+	}
+	cast := func(row []bigquery.Value) (bigquery.Value, error) {
+		v, err := x.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		v, err = castValue(v, from, typ)
+		if err != nil && safe {
+			return nil, nil
+		}
+		return v, err
+	}
+	if x.lit {
+		// Constants are cast once, so that errors are reported eagerly.
+		v, err := cast(nil)
+		if err != nil {
+			return nil, err
+		}
+		return constant(v, typ), nil
+	}
+	return &compiled{typ: typ, eval: cast}, nil
+}
+
+// casts lists the permitted casts between different scalar types.
+var casts = map[bigquery.FieldType][]bigquery.FieldType{
+	bigquery.IntegerFieldType:    {bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType, bigquery.BooleanFieldType, bigquery.StringFieldType},
+	bigquery.FloatFieldType:      {bigquery.IntegerFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType, bigquery.StringFieldType},
+	bigquery.NumericFieldType:    {bigquery.IntegerFieldType, bigquery.FloatFieldType, bigquery.BigNumericFieldType, bigquery.StringFieldType},
+	bigquery.BigNumericFieldType: {bigquery.IntegerFieldType, bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.StringFieldType},
+	bigquery.BooleanFieldType:    {bigquery.IntegerFieldType, bigquery.StringFieldType},
+	bigquery.StringFieldType: {bigquery.IntegerFieldType, bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType,
+		bigquery.BooleanFieldType, bigquery.BytesFieldType, bigquery.DateFieldType, bigquery.DateTimeFieldType, bigquery.TimeFieldType,
+		bigquery.TimestampFieldType, bigquery.IntervalFieldType, bigquery.JSONFieldType, bigquery.RangeFieldType, bigquery.GeographyFieldType},
+	bigquery.BytesFieldType:     {bigquery.StringFieldType},
+	bigquery.DateFieldType:      {bigquery.StringFieldType, bigquery.DateTimeFieldType, bigquery.TimestampFieldType},
+	bigquery.DateTimeFieldType:  {bigquery.StringFieldType, bigquery.DateFieldType, bigquery.TimeFieldType, bigquery.TimestampFieldType},
+	bigquery.TimeFieldType:      {bigquery.StringFieldType},
+	bigquery.TimestampFieldType: {bigquery.StringFieldType, bigquery.DateFieldType, bigquery.DateTimeFieldType, bigquery.TimeFieldType},
+	bigquery.IntervalFieldType:  {bigquery.StringFieldType},
+	bigquery.JSONFieldType:      {bigquery.StringFieldType},
+	bigquery.RangeFieldType:     {bigquery.StringFieldType},
+	bigquery.GeographyFieldType: {bigquery.StringFieldType},
+}
+
+func checkCast(from, to *bigquery.FieldSchema) error {
+	if from.Repeated != to.Repeated {
+		return fmt.Errorf("invalid cast from %s to %s", typeString(from), typeString(to))
+	}
+	if from.Type == bigquery.RecordFieldType && to.Type == bigquery.RecordFieldType {
+		if len(from.Schema) != len(to.Schema) {
+			return fmt.Errorf("invalid cast from %s to %s", typeString(from), typeString(to))
+		}
+		for i := range from.Schema {
+			if err := checkCast(from.Schema[i], to.Schema[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if from.Type == to.Type || slices.Contains(casts[from.Type], to.Type) {
+		return nil
+	}
+	return fmt.Errorf("invalid cast from %s to %s", typeString(from), typeString(to))
+}
+
+func castValue(v bigquery.Value, from, to *bigquery.FieldSchema) (bigquery.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if from.Repeated {
+		vs := v.([]bigquery.Value)
+		out := make([]bigquery.Value, len(vs))
+		f, t := *from, *to
+		f.Repeated, t.Repeated = false, false
+		for i, e := range vs {
+			x, err := castValue(e, &f, &t)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	}
+	if from.Type == bigquery.RecordFieldType {
+		fields := v.([]bigquery.Value)
+		out := make([]bigquery.Value, len(fields))
+		for i, e := range fields {
+			x, err := castValue(e, from.Schema[i], to.Schema[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	}
+	if from.Type == to.Type {
+		return v, nil
+	}
+	if to.Type == bigquery.StringFieldType {
+		return castToString(v, from.Type)
+	}
+	switch x := v.(type) {
+	case string:
+		return castFromString(x, to)
+	case int64:
+		switch to.Type {
+		case bigquery.BooleanFieldType:
+			return x != 0, nil
+		case bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+			return coerceValue(x, from, to)
+		}
+	case float64:
+		switch to.Type {
+		case bigquery.IntegerFieldType:
+			return floatToInt(x)
+		case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return nil, fmt.Errorf("invalid NUMERIC value: %v", x)
+			}
+			return roundRat(toRat(x), to.Type), nil
+		}
+	case *big.Rat:
+		switch to.Type {
+		case bigquery.IntegerFieldType:
+			r := roundRat(x, "")
+			if !r.IsInt() || !r.Num().IsInt64() {
+				return nil, fmt.Errorf("int64 overflow: %s", x.RatString())
+			}
+			return r.Num().Int64(), nil
+		case bigquery.FloatFieldType:
+			return toFloat(x), nil
+		case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+			return roundRat(x, to.Type), nil
+		}
+	case bool:
+		if x {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case []byte:
+		return nil, nil // Unreachable: only casts to STRING.
+	case time.Time:
+		x = x.UTC()
+		switch to.Type {
+		case bigquery.DateFieldType:
+			return civil.DateOf(x), nil
+		case bigquery.DateTimeFieldType:
+			return civil.DateTimeOf(x), nil
+		case bigquery.TimeFieldType:
+			return civil.TimeOf(x), nil
+		}
+	case civil.Date:
+		switch to.Type {
+		case bigquery.DateTimeFieldType:
+			return civil.DateTime{Date: x}, nil
+		case bigquery.TimestampFieldType:
+			return x.In(time.UTC), nil
+		}
+	case civil.DateTime:
+		switch to.Type {
+		case bigquery.DateFieldType:
+			return x.Date, nil
+		case bigquery.TimeFieldType:
+			return x.Time, nil
+		case bigquery.TimestampFieldType:
+			return x.In(time.UTC), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid cast from %s to %s", from.Type, to.Type)
+}
+
+// roundRat rounds half away from zero to the scale of a NUMERIC or
+// BIGNUMERIC type, or to an integer.
+func roundRat(r *big.Rat, t bigquery.FieldType) *big.Rat {
+	scale := int64(0)
+	switch t {
+	case bigquery.NumericFieldType:
+		scale = bigquery.NumericScaleDigits
+	case bigquery.BigNumericFieldType:
+		scale = bigquery.BigNumericScaleDigits
+	}
+	m := new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)
+	x := new(big.Rat).Mul(r, new(big.Rat).SetInt(m))
+	num, den := x.Num(), x.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, m)
+}
+
+func floatToInt(f float64) (int64, error) {
+	r := math.Round(f)
+	if math.IsNaN(r) || r >= 1<<63 || r < -(1<<63) {
+		return 0, fmt.Errorf("int64 overflow: %v", f)
+	}
+	return int64(r), nil
+}
+
+func castToString(v bigquery.Value, from bigquery.FieldType) (bigquery.Value, error) {
+	switch x := v.(type) {
+	case []byte:
+		if !utf8.Valid(x) {
+			return nil, errors.New("invalid UTF-8 in cast from BYTES to STRING")
+		}
+		return string(x), nil
+	case float64:
+		switch {
+		case math.IsInf(x, 1):
+			return "inf", nil
+		case math.IsInf(x, -1):
+			return "-inf", nil
+		case math.IsNaN(x):
+			return "nan", nil
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case *big.Rat:
+		return trimDecimal(x.FloatString(int(bigquery.BigNumericScaleDigits))), nil
+	case time.Time:
+		return x.UTC().Format("2006-01-02 15:04:05.999999") + "+00", nil
+	case civil.DateTime:
+		return x.Date.String() + " " + formatCivilTime(x.Time), nil
+	case civil.Time:
+		return formatCivilTime(x), nil
+	case *bigquery.RangeValue:
+		elem := &bigquery.FieldSchema{}
+		bound := func(b bigquery.Value) (string, error) {
+			if b == nil {
+				return "UNBOUNDED", nil
+			}
+			s, err := castToString(b, elem.Type)
+			return fmt.Sprint(s), err
+		}
+		s, err := bound(x.Start)
+		if err != nil {
+			return nil, err
+		}
+		e, err := bound(x.End)
+		if err != nil {
+			return nil, err
+		}
+		return "[" + s + ", " + e + ")", nil
+	case string:
+		return x, nil
+	}
+	return formatScalar(v, from, false), nil
+}
+
+func trimDecimal(s string) string {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func formatCivilTime(t civil.Time) string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if us := t.Nanosecond / 1000; us != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", us), "0")
+	}
+	return s
+}
+
+func castFromString(s string, to *bigquery.FieldSchema) (bigquery.Value, error) {
+	t := strings.TrimSpace(s)
+	switch to.Type {
+	case bigquery.BytesFieldType:
+		return []byte(s), nil
+	case bigquery.IntegerFieldType:
+		neg := strings.HasPrefix(t, "-")
+		u := strings.TrimLeft(t, "+-")
+		if strings.HasPrefix(strings.ToLower(u), "0x") {
+			n, err := strconv.ParseInt(u[2:], 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad int64 value: %s", s)
+			}
+			if neg {
+				n = -n
+			}
+			return n, nil
+		}
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad int64 value: %s", s)
+		}
+		return n, nil
+	case bigquery.FloatFieldType:
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("bad double value: %s", s)
+		}
+		return f, nil
+	case bigquery.BooleanFieldType:
+		switch strings.ToLower(t) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("bad bool value: %s", s)
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		r, ok := new(big.Rat).SetString(t)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value: %s", to.Type, s)
+		}
+		return roundRat(r, to.Type), nil
+	case bigquery.TimestampFieldType:
+		if _, err := strconv.ParseFloat(t, 64); err == nil {
+			return nil, fmt.Errorf("invalid timestamp: %s", s)
+		}
+	}
+	v, err := parseText(t, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value: %s", typeString(to), s)
+	}
+	return v, nil
+}
+
+// extract returns EXTRACT(part FROM x).
+func extract(part string, x *compiled) (*compiled, error) {
+	t := x.typ.Type
+	if !isDateTime(t) || x.typ.Repeated {
+		return nil, fmt.Errorf("EXTRACT does not support %s", typeString(x.typ))
+	}
+	typ := intType
+	switch part {
+	case "DATE":
+		typ = &bigquery.FieldSchema{Type: bigquery.DateFieldType}
+	case "TIME":
+		typ = &bigquery.FieldSchema{Type: bigquery.TimeFieldType}
+	case "DATETIME":
+		typ = &bigquery.FieldSchema{Type: bigquery.DateTimeFieldType}
+	}
+	return strict(typ, func(v bigquery.Value) (bigquery.Value, error) {
+		dt := toDateTime(v)
+		if t == bigquery.TimeFieldType && !isTimePart(part) || t == bigquery.DateFieldType && isTimePart(part) {
+			return nil, fmt.Errorf("EXTRACT from %s does not support %s", t, part)
+		}
+		switch part {
+		case "DATE":
+			return dt.Date, nil
+		case "TIME":
+			return dt.Time, nil
+		case "DATETIME":
+			return dt, nil
+		}
+		return datePartOf(dt, part)
+	}, x), nil
+}
+
+func isTimePart(part string) bool {
+	switch part {
+	case "HOUR", "MINUTE", "SECOND", "MILLISECOND", "MICROSECOND", "TIME":
+		return true
+	}
+	return false
+}
+
+// toDateTime converts a DATE, DATETIME, TIME or TIMESTAMP (in UTC) to a
+// civil.DateTime.
+func toDateTime(v bigquery.Value) civil.DateTime {
+	switch v := v.(type) {
+	case time.Time:
+		return civil.DateTimeOf(v.UTC())
+	case civil.Date:
+		return civil.DateTime{Date: v}
+	case civil.Time:
+		return civil.DateTime{Time: v}
+	}
+	return v.(civil.DateTime)
+}
+
+func datePartOf(dt civil.DateTime, part string) (int64, error) {
+	t := dt.In(time.UTC)
+	switch part {
+	case "MICROSECOND":
+		return int64(dt.Time.Second)*1e6 + int64(dt.Time.Nanosecond/1e3), nil
+	case "MILLISECOND":
+		return int64(dt.Time.Second)*1e3 + int64(dt.Time.Nanosecond/1e6), nil
+	case "SECOND":
+		return int64(dt.Time.Second), nil
+	case "MINUTE":
+		return int64(dt.Time.Minute), nil
+	case "HOUR":
+		return int64(dt.Time.Hour), nil
+	case "DAYOFWEEK":
+		return int64(t.Weekday()) + 1, nil
+	case "DAY":
+		return int64(dt.Date.Day), nil
+	case "DAYOFYEAR":
+		return int64(t.YearDay()), nil
+	case "WEEK":
+		// Weeks start on Sunday; days before the first Sunday are in week 0.
+		return int64((t.YearDay() + 6 - int(t.Weekday())) / 7), nil
+	case "ISOWEEK":
+		_, w := t.ISOWeek()
+		return int64(w), nil
+	case "MONTH":
+		return int64(dt.Date.Month), nil
+	case "QUARTER":
+		return int64(dt.Date.Month-1)/3 + 1, nil
+	case "YEAR":
+		return int64(dt.Date.Year), nil
+	case "ISOYEAR":
+		y, _ := t.ISOWeek()
+		return int64(y), nil
+	}
+	return 0, fmt.Errorf("unsupported date part %s", part)
+}
+
+// addInterval adds an interval to a DATETIME, TIMESTAMP or TIME. Adding
+// months clamps the day to the end of the month, as GoogleSQL does.
+func addInterval(v bigquery.Value, iv *bigquery.IntervalValue) (bigquery.Value, error) {
+	d := time.Duration(iv.Hours)*time.Hour + time.Duration(iv.Minutes)*time.Minute +
+		time.Duration(iv.Seconds)*time.Second + time.Duration(iv.SubSecondNanos)
+	months := int(iv.Years)*12 + int(iv.Months)
+	switch x := v.(type) {
+	case time.Time:
+		x = x.UTC()
+		dt := civil.DateTimeOf(x)
+		dt.Date = addMonths(dt.Date, months).AddDays(int(iv.Days))
+		return dt.In(time.UTC).Add(d), nil
+	case civil.DateTime:
+		x.Date = addMonths(x.Date, months).AddDays(int(iv.Days))
+		return civil.DateTimeOf(x.In(time.UTC).Add(d)), nil
+	case civil.Time:
+		if months != 0 || iv.Days != 0 {
+			return nil, errors.New("cannot add date parts to a TIME")
+		}
+		return civil.TimeOf(civil.DateTime{Date: civil.Date{Year: 2000, Month: 1, Day: 1}, Time: x}.In(time.UTC).Add(d)), nil
+	}
+	return nil, fmt.Errorf("cannot add an interval to %T", v)
+}
+
+func addMonths(d civil.Date, n int) civil.Date {
+	if n == 0 {
+		return d
+	}
+	m := int(d.Month) - 1 + n
+	y := d.Year + m/12
+	m %= 12
+	if m < 0 {
+		m += 12
+		y--
+	}
+	out := civil.Date{Year: y, Month: time.Month(m + 1), Day: d.Day}
+	if last := daysIn(out.Year, out.Month); out.Day > last {
+		out.Day = last
+	}
+	return out
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// truncDateTime truncates to the start of a date part.
+func truncDateTime(dt civil.DateTime, part string) (civil.DateTime, error) {
+	d := dt.Date
+	switch part {
+	case "MICROSECOND":
+		dt.Time.Nanosecond -= dt.Time.Nanosecond % 1000
+		return dt, nil
+	case "MILLISECOND":
+		dt.Time.Nanosecond -= dt.Time.Nanosecond % 1e6
+		return dt, nil
+	case "SECOND":
+		dt.Time.Nanosecond = 0
+		return dt, nil
+	case "MINUTE":
+		dt.Time = civil.Time{Hour: dt.Time.Hour, Minute: dt.Time.Minute}
+		return dt, nil
+	case "HOUR":
+		dt.Time = civil.Time{Hour: dt.Time.Hour}
+		return dt, nil
+	case "DAY":
+	case "MONTH":
+		d.Day = 1
+	case "QUARTER":
+		d.Day, d.Month = 1, (d.Month-1)/3*3+1
+	case "YEAR":
+		d.Day, d.Month = 1, 1
+	case "ISOWEEK":
+		d = d.AddDays(-((int(d.Weekday()) + 6) % 7))
+	case "ISOYEAR":
+		y, _ := d.In(time.UTC).ISOWeek()
+		d = civil.Date{Year: y, Month: 1, Day: 4}
+		d = d.AddDays(-((int(d.Weekday()) + 6) % 7))
+	default:
+		start := time.Sunday
+		if strings.HasPrefix(part, "WEEK(") {
+			day, ok := weekdays[strings.TrimSuffix(strings.TrimPrefix(part, "WEEK("), ")")]
+			if !ok {
+				return dt, fmt.Errorf("unsupported date part %s", part)
+			}
+			start = day
+		} else if part != "WEEK" {
+			return dt, fmt.Errorf("unsupported date part %s", part)
+		}
+		d = d.AddDays(-((int(d.Weekday()) - int(start) + 7) % 7))
+	}
+	return civil.DateTime{Date: d}, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"SUNDAY": time.Sunday, "MONDAY": time.Monday, "TUESDAY": time.Tuesday, "WEDNESDAY": time.Wednesday,
+	"THURSDAY": time.Thursday, "FRIDAY": time.Friday, "SATURDAY": time.Saturday,
+}
+
+// diffDateTime returns the number of part boundaries between b and a.
+func diffDateTime(a, b civil.DateTime, part string) (int64, error) {
+	switch part {
+	case "YEAR":
+		return int64(a.Date.Year - b.Date.Year), nil
+	case "QUARTER":
+		return int64((a.Date.Year*12+int(a.Date.Month)-1)/3 - (b.Date.Year*12+int(b.Date.Month)-1)/3), nil
+	case "MONTH":
+		return int64((a.Date.Year*12 + int(a.Date.Month)) - (b.Date.Year*12 + int(b.Date.Month))), nil
+	case "ISOYEAR":
+		ya, _ := a.In(time.UTC).ISOWeek()
+		yb, _ := b.In(time.UTC).ISOWeek()
+		return int64(ya - yb), nil
+	}
+	ta, err := truncDateTime(a, part)
+	if err != nil {
+		return 0, err
+	}
+	tb, err := truncDateTime(b, part)
+	if err != nil {
+		return 0, err
+	}
+	d := ta.In(time.UTC).Sub(tb.In(time.UTC))
+	switch {
+	case part == "DAY":
+		return int64(d / (24 * time.Hour)), nil
+	case part == "ISOWEEK" || strings.HasPrefix(part, "WEEK"):
+		return int64(d / (7 * 24 * time.Hour)), nil
+	}
+	unit, _ := unitDuration(part)
+	return int64(d / unit), nil
+}
+
+// isAggregate reports whether a function is an aggregate function.
+func isAggregate(name string) bool {
+	switch name {
+	case "COUNT", "COUNTIF", "SUM", "AVG", "MIN", "MAX", "ANY_VALUE", "ARRAY_AGG", "STRING_AGG",
+		"LOGICAL_AND", "LOGICAL_OR", "APPROX_COUNT_DISTINCT", "BIT_AND", "BIT_OR", "BIT_XOR":
+		return true
+	}
+	return false
+}
+
+// An aggSpec is a call of an aggregate function in an aggregating select.
+type aggSpec struct {
+	call    *callExpr
+	args    []*compiled
+	orderBy []orderKey
+	limit   int64 // -1 for no limit.
+	typ     *bigquery.FieldSchema
+}
+
+func (c *compiler) aggregate(e *callExpr) (*compiled, error) {
+	if c.aggs == nil {
+		return nil, fmt.Errorf("aggregate function %s is not allowed here", e.name)
+	}
+	in := &compiler{ev: c.ev, cols: c.cols}
+	spec := &aggSpec{call: e, limit: -1}
+	for _, a := range e.args {
+		if containsAgg(a) {
+			return nil, fmt.Errorf("aggregate function calls cannot be nested")
+		}
+		x, err := in.compile(a)
+		if err != nil {
+			return nil, err
+		}
+		spec.args = append(spec.args, x)
+	}
+	for _, item := range e.orderBy {
+		k, err := in.orderKey(item, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		spec.orderBy = append(spec.orderBy, k)
+	}
+	if e.limit != nil {
+		n, err := c.ev.constInt(e.limit, "LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		spec.limit = n
+	}
+	typ, err := spec.resultType()
+	if err != nil {
+		return nil, err
+	}
+	spec.typ = typ
+	idx := c.base + len(*c.aggs)
+	*c.aggs = append(*c.aggs, spec)
+	return &compiled{
+		typ:  typ,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) { return row[idx], nil },
+	}, nil
+}
+
+func (a *aggSpec) resultType() (*bigquery.FieldSchema, error) {
+	name := a.call.name
+	if a.call.star {
+		if name != "COUNT" {
+			return nil, fmt.Errorf("%s(*) is not supported", name)
+		}
+		return intType, nil
+	}
+	want := map[string][2]int{"STRING_AGG": {1, 2}}[name]
+	if want == [2]int{} {
+		want = [2]int{1, 1}
+	}
+	if len(a.args) < want[0] || len(a.args) > want[1] {
+		return nil, fmt.Errorf("wrong number of arguments to %s", name)
+	}
+	if a.call.distinct && name != "COUNT" && name != "SUM" && name != "AVG" && name != "ARRAY_AGG" && name != "STRING_AGG" && name != "MIN" && name != "MAX" {
+		return nil, fmt.Errorf("%s does not support DISTINCT", name)
+	}
+	arg := a.args[0].typ
+	bad := fmt.Errorf("no matching signature for aggregate function %s for argument type %s", name, typeString(arg))
+	switch name {
+	case "COUNT", "APPROX_COUNT_DISTINCT":
+		return intType, nil
+	case "COUNTIF":
+		if arg.Type != bigquery.BooleanFieldType {
+			return nil, bad
+		}
+		return intType, nil
+	case "SUM":
+		if !isNumeric(arg.Type) && arg.Type != bigquery.IntervalFieldType || arg.Repeated {
+			return nil, bad
+		}
+		return &bigquery.FieldSchema{Type: arg.Type}, nil
+	case "AVG":
+		if !isNumeric(arg.Type) || arg.Repeated {
+			return nil, bad
+		}
+		if arg.Type == bigquery.IntegerFieldType {
+			return floatType, nil
+		}
+		return &bigquery.FieldSchema{Type: arg.Type}, nil
+	case "MIN", "MAX":
+		if arg.Repeated || arg.Type == bigquery.RecordFieldType || arg.Type == bigquery.JSONFieldType || arg.Type == bigquery.GeographyFieldType {
+			return nil, bad
+		}
+		return arg, nil
+	case "ANY_VALUE":
+		return arg, nil
+	case "ARRAY_AGG":
+		if arg.Repeated {
+			return nil, errors.New("ARRAY_AGG cannot aggregate arrays")
+		}
+		t := *arg
+		t.Repeated = true
+		return &t, nil
+	case "STRING_AGG":
+		if arg.Type != bigquery.StringFieldType && arg.Type != bigquery.BytesFieldType || arg.Repeated {
+			return nil, bad
+		}
+		return &bigquery.FieldSchema{Type: arg.Type}, nil
+	case "LOGICAL_AND", "LOGICAL_OR":
+		if arg.Type != bigquery.BooleanFieldType {
+			return nil, bad
+		}
+		return boolType, nil
+	case "BIT_AND", "BIT_OR", "BIT_XOR":
+		if arg.Type != bigquery.IntegerFieldType {
+			return nil, bad
+		}
+		return intType, nil
+	}
+	return nil, fmt.Errorf("unsupported aggregate function %s", name)
+}
+
+// compute evaluates the aggregate over the rows of a group.
+func (a *aggSpec) compute(rows [][]bigquery.Value) (bigquery.Value, error) {
+	name := a.call.name
+	if a.call.star {
+		return int64(len(rows)), nil
+	}
+	if len(a.orderBy) > 0 {
+		sorted := make([]sortRow, len(rows))
+		for i, row := range rows {
+			sorted[i] = sortRow{out: row}
+			for _, k := range a.orderBy {
+				v, err := k.value(row, nil)
+				if err != nil {
+					return nil, err
+				}
+				sorted[i].keys = append(sorted[i].keys, v)
+			}
+		}
+		if err := sortRows(sorted, a.orderBy); err != nil {
+			return nil, err
+		}
+		rows = make([][]bigquery.Value, len(sorted))
+		for i := range sorted {
+			rows[i] = sorted[i].out
+		}
+	}
+	var vals []bigquery.Value
+	seen := map[string]bool{}
+	for _, row := range rows {
+		v, err := a.args[0].eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil && (name != "ARRAY_AGG" || a.call.ignoreNulls) {
+			continue
+		}
+		if a.call.distinct {
+			k := valueKey(v)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		vals = append(vals, v)
+	}
+	if a.limit >= 0 && int64(len(vals)) > a.limit {
+		vals = vals[:a.limit]
+	}
+	switch name {
+	case "COUNT", "APPROX_COUNT_DISTINCT":
+		if name == "APPROX_COUNT_DISTINCT" && !a.call.distinct {
+			return int64(len(distinctRows(wrapValues(vals)))), nil
+		}
+		return int64(len(vals)), nil
+	case "COUNTIF":
+		n := int64(0)
+		for _, v := range vals {
+			if v == true {
+				n++
+			}
+		}
+		return n, nil
+	case "ARRAY_AGG":
+		if slices.Contains(vals, nil) {
+			return nil, errors.New("array cannot have a null element; use IGNORE NULLS")
+		}
+		if vals == nil {
+			return []bigquery.Value{}, nil
+		}
+		return vals, nil
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	switch name {
+	case "SUM":
+		sum := vals[0]
+		for _, v := range vals[1:] {
+			var err error
+			if iv, ok := sum.(*bigquery.IntervalValue); ok {
+				sum = addIntervals(iv, v.(*bigquery.IntervalValue))
+			} else if sum, err = arith("+", sum, v); err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	case "AVG":
+		if _, ok := vals[0].(*big.Rat); ok {
+			sum := new(big.Rat)
+			for _, v := range vals {
+				sum.Add(sum, v.(*big.Rat))
+			}
+			return sum.Quo(sum, new(big.Rat).SetInt64(int64(len(vals)))), nil
+		}
+		sum := 0.0
+		for _, v := range vals {
+			sum += toFloat(v)
+		}
+		return sum / float64(len(vals)), nil
+	case "MIN", "MAX":
+		best := vals[0]
+		for _, v := range vals[1:] {
+			c, err := compareValues(v, best)
+			if err != nil {
+				return nil, err
+			}
+			if name == "MIN" && c < 0 || name == "MAX" && c > 0 {
+				best = v
+			}
+		}
+		return best, nil
+	case "ANY_VALUE":
+		return vals[0], nil
+	case "STRING_AGG":
+		sep := bigquery.Value(",")
+		if len(a.args) > 1 {
+			var err error
+			if sep, err = a.args[1].eval(rows[0]); err != nil {
+				return nil, err
+			}
+		}
+		parts := make([]string, len(vals))
+		for i, v := range vals {
+			parts[i] = asString(v)
+		}
+		s := strings.Join(parts, asString(sep))
+		if _, ok := vals[0].([]byte); ok {
+			return []byte(s), nil
+		}
+		return s, nil
+	case "LOGICAL_AND", "LOGICAL_OR":
+		want := name == "LOGICAL_OR"
+		for _, v := range vals {
+			if v == want {
+				return want, nil
+			}
+		}
+		return !want, nil
+	case "BIT_AND", "BIT_OR", "BIT_XOR":
+		acc := vals[0].(int64)
+		for _, v := range vals[1:] {
+			switch name {
+			case "BIT_AND":
+				acc &= v.(int64)
+			case "BIT_OR":
+				acc |= v.(int64)
+			default:
+				acc ^= v.(int64)
+			}
+		}
+		return acc, nil
+	}
+	return nil, fmt.Errorf("unsupported aggregate function %s", name)
+}
+
+func wrapValues(vs []bigquery.Value) [][]bigquery.Value {
+	out := make([][]bigquery.Value, len(vs))
+	for i, v := range vs {
+		out[i] = []bigquery.Value{v}
+	}
+	return out
+}
+
+// A scalarFunc is a scalar function that returns NULL if any argument is
+// NULL.
+type scalarFunc struct {
+	min, max int // The number of arguments; max is -1 for variadic functions.
+	typ      func(args []*compiled) (*bigquery.FieldSchema, error)
+	fn       func(ctx *funcContext, args []bigquery.Value) (bigquery.Value, error)
+}
+
+// funcContext holds the state shared by the calls of a function.
+type funcContext struct {
+	now     time.Time
+	regexps map[string]*regexp.Regexp
+}
+
+func (fc *funcContext) regexp(pat string) (*regexp.Regexp, error) {
+	if re, ok := fc.regexps[pat]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse regular expression: %v", err)
+	}
+	fc.regexps[pat] = re
+	return re, nil
+}
+
+func (c *compiler) call(e *callExpr) (*compiled, error) {
+	if e.distinct || e.star || e.orderBy != nil || e.limit != nil || e.ignoreNulls {
+		return nil, fmt.Errorf("%s is not an aggregate function", e.name)
+	}
+	args := make([]*compiled, len(e.args))
+	for i, a := range e.args {
+		x, err := c.compile(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = x
+	}
+	x, err := c.callArgs(e.name, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.safe {
+		eval := x.eval
+		x.eval = func(row []bigquery.Value) (bigquery.Value, error) {
+			v, err := eval(row)
+			if err != nil {
+				return nil, nil
+			}
+			return v, nil
+		}
+	}
+	return x, nil
+}
+
+func (c *compiler) callArgs(name string, args []*compiled) (*compiled, error) {
+	switch name {
+	case "IF":
+		if len(args) != 3 {
+			return nil, errors.New("IF expects 3 arguments")
+		}
+		return c.conditional(args[1:], func(row []bigquery.Value, vals []*compiled) (bigquery.Value, error) {
+			cond, err := args[0].eval(row)
+			if err != nil {
+				return nil, err
+			}
+			if cond == true {
+				return vals[0].eval(row)
+			}
+			return vals[1].eval(row)
+		})
+	case "COALESCE", "IFNULL":
+		if len(args) == 0 || name == "IFNULL" && len(args) != 2 {
+			return nil, fmt.Errorf("wrong number of arguments to %s", name)
+		}
+		return c.conditional(args, func(row []bigquery.Value, vals []*compiled) (bigquery.Value, error) {
+			for _, x := range vals {
+				v, err := x.eval(row)
+				if v != nil || err != nil {
+					return v, err
+				}
+			}
+			return nil, nil
+		})
+	case "NULLIF":
+		if len(args) != 2 {
+			return nil, errors.New("NULLIF expects 2 arguments")
+		}
+		l, r, err := comparable(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return &compiled{
+			typ:  l.typ,
+			null: l.null,
+			eval: func(row []bigquery.Value) (bigquery.Value, error) {
+				a, err := l.eval(row)
+				if a == nil || err != nil {
+					return nil, err
+				}
+				b, err := r.eval(row)
+				if b == nil || err != nil {
+					return a, err
+				}
+				if n, err := compareValues(a, b); err != nil || n == 0 {
+					return nil, err
+				}
+				return a, nil
+			},
+		}, nil
+	case "TO_JSON_STRING":
+		if len(args) != 1 {
+			return nil, errors.New("TO_JSON_STRING expects 1 argument")
+		}
+		return c.toJSONString(args[0]), nil
+	case "ARRAY_TO_STRING":
+		if len(args) < 2 || len(args) > 3 {
+			return nil, errors.New("ARRAY_TO_STRING expects 2 or 3 arguments")
+		}
+		if !args[0].typ.Repeated || args[0].typ.Type != bigquery.StringFieldType && args[0].typ.Type != bigquery.BytesFieldType {
+			return nil, fmt.Errorf("ARRAY_TO_STRING expects an array of STRING or BYTES, got %s", typeString(args[0].typ))
+		}
+		isBytes := args[0].typ.Type == bigquery.BytesFieldType
+		return &compiled{
+			typ: &bigquery.FieldSchema{Type: args[0].typ.Type},
+			eval: func(row []bigquery.Value) (bigquery.Value, error) {
+				vs := make([]bigquery.Value, len(args))
+				for i, a := range args {
+					v, err := a.eval(row)
+					if err != nil || v == nil && i < 2 {
+						return nil, err
+					}
+					vs[i] = v
+				}
+				var parts []string
+				for _, e := range vs[0].([]bigquery.Value) {
+					if e != nil {
+						parts = append(parts, asString(e))
+					} else if vs[2] != nil {
+						parts = append(parts, asString(vs[2]))
+					}
+				}
+				s := strings.Join(parts, asString(vs[1]))
+				if isBytes {
+					return []byte(s), nil
+				}
+				return s, nil
+			},
+		}, nil
+	}
+	f, ok := scalarFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("function not found: %s", name)
+	}
+	if len(args) < f.min || f.max >= 0 && len(args) > f.max {
+		return nil, fmt.Errorf("wrong number of arguments to %s: %d", name, len(args))
+	}
+	typ, err := f.typ(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	ctx := &funcContext{now: c.ev.req.Now, regexps: map[string]*regexp.Regexp{}}
+	fn := f.fn
+	x := strict(typ, func(vs []bigquery.Value) (bigquery.Value, error) {
+		v, err := fn(ctx, vs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return v, nil
+	}, args...)
+	if len(args) == 0 {
+		// Functions without arguments, like CURRENT_DATE, are constant.
+		v, err := x.eval(nil)
+		if err != nil {
+			return nil, err
+		}
+		return constant(v, typ), nil
+	}
+	return x, nil
+}
+
+// conditional compiles a function that chooses among vals, which are
+// coerced to a common type.
+func (c *compiler) conditional(vals []*compiled, eval func(row []bigquery.Value, vals []*compiled) (bigquery.Value, error)) (*compiled, error) {
+	typ, null, err := commonTypeOf(vals)
+	if err != nil {
+		return nil, err
+	}
+	vals = slices.Clone(vals)
+	for i := range vals {
+		vals[i] = coerceTo(vals[i], typ)
+	}
+	return &compiled{
+		typ:  typ,
+		null: null,
+		eval: func(row []bigquery.Value) (bigquery.Value, error) { return eval(row, vals) },
+	}, nil
+}
+
+// Type functions for scalarFuncs.
+
+func returns(t bigquery.FieldType) func([]*compiled) (*bigquery.FieldSchema, error) {
+	typ := &bigquery.FieldSchema{Type: t}
+	return func([]*compiled) (*bigquery.FieldSchema, error) { return typ, nil }
+}
+
+func returnsArray(t bigquery.FieldType) func([]*compiled) (*bigquery.FieldSchema, error) {
+	typ := &bigquery.FieldSchema{Type: t, Repeated: true}
+	return func([]*compiled) (*bigquery.FieldSchema, error) { return typ, nil }
+}
+
+// sameAsFirst returns the type of the first argument, which must be one of
+// types.
+func sameAsFirst(types ...bigquery.FieldType) func([]*compiled) (*bigquery.FieldSchema, error) {
+	return func(args []*compiled) (*bigquery.FieldSchema, error) {
+		t := args[0].typ
+		if args[0].null && len(types) > 0 {
+			return &bigquery.FieldSchema{Type: types[0]}, nil
+		}
+		if t.Repeated || len(types) > 0 && !slices.Contains(types, t.Type) {
+			return nil, fmt.Errorf("unsupported argument type %s", typeString(t))
+		}
+		return t, nil
+	}
+}
+
+// floatOrNumeric is the type of math functions: FLOAT64, unless the
+// argument is NUMERIC or BIGNUMERIC.
+func floatOrNumeric(args []*compiled) (*bigquery.FieldSchema, error) {
+	for _, a := range args {
+		if !isNumeric(a.typ.Type) && !a.null || a.typ.Repeated {
+			return nil, fmt.Errorf("expected a number, got %s", typeString(a.typ))
+		}
+	}
+	switch t := args[0].typ.Type; t {
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return &bigquery.FieldSchema{Type: t}, nil
+	}
+	return floatType, nil
+}
+
+func numericCommon(args []*compiled) (*bigquery.FieldSchema, error) {
+	for _, a := range args {
+		if !isNumeric(a.typ.Type) && !a.null || a.typ.Repeated {
+			return nil, fmt.Errorf("expected a number, got %s", typeString(a.typ))
+		}
+	}
+	t, _, err := commonTypeOf(args)
+	return t, err
+}
+
+func anyCommon(args []*compiled) (*bigquery.FieldSchema, error) {
+	t, _, err := commonTypeOf(args)
+	return t, err
+}
+
+// scalarFuncs holds the strict scalar functions.
+var scalarFuncs map[string]*scalarFunc
+
+func init() {
+	str := bigquery.StringFieldType
+	byt := bigquery.BytesFieldType
+	scalarFuncs = map[string]*scalarFunc{
+		// String functions.
+		"CONCAT": {1, -1, sameAsFirst(str, byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			var sb strings.Builder
+			for _, v := range a {
+				sb.WriteString(asString(v))
+			}
+			if _, ok := a[0].([]byte); ok {
+				return []byte(sb.String()), nil
+			}
+			return sb.String(), nil
+		}},
+		"UPPER": {1, 1, sameAsFirst(str, byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return mapString(a[0], strings.ToUpper), nil
+		}},
+		"LOWER": {1, 1, sameAsFirst(str, byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return mapString(a[0], strings.ToLower), nil
+		}},
+		"LENGTH": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			if b, ok := a[0].([]byte); ok {
+				return int64(len(b)), nil
+			}
+			return int64(utf8.RuneCountInString(a[0].(string))), nil
+		}},
+		"BYTE_LENGTH": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return int64(len(asString(a[0]))), nil
+		}},
+		"SUBSTR": {2, 3, sameAsFirst(str, byt), funcSubstr},
+		"TRIM":   {1, 2, sameAsFirst(str, byt), trimFunc(strings.Trim)},
+		"LTRIM":  {1, 2, sameAsFirst(str, byt), trimFunc(strings.TrimLeft)},
+		"RTRIM":  {1, 2, sameAsFirst(str, byt), trimFunc(strings.TrimRight)},
+		"REPLACE": {3, 3, sameAsFirst(str, byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			from := asString(a[1])
+			if from == "" {
+				return a[0], nil
+			}
+			return mapString(a[0], func(s string) string { return strings.ReplaceAll(s, from, asString(a[2])) }), nil
+		}},
+		"STARTS_WITH": {2, 2, returns(bigquery.BooleanFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return strings.HasPrefix(asString(a[0]), asString(a[1])), nil
+		}},
+		"ENDS_WITH": {2, 2, returns(bigquery.BooleanFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return strings.HasSuffix(asString(a[0]), asString(a[1])), nil
+		}},
+		"STRPOS": {2, 2, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			s := asString(a[0])
+			i := strings.Index(s, asString(a[1]))
+			if i < 0 {
+				return int64(0), nil
+			}
+			if _, ok := a[0].([]byte); ok {
+				return int64(i + 1), nil
+			}
+			return int64(utf8.RuneCountInString(s[:i]) + 1), nil
+		}},
+		"SPLIT": {1, 2, func(args []*compiled) (*bigquery.FieldSchema, error) {
+			t, err := sameAsFirst(str, byt)(args)
+			if err != nil {
+				return nil, err
+			}
+			return &bigquery.FieldSchema{Type: t.Type, Repeated: true}, nil
+		}, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			sep := ","
+			if len(a) > 1 {
+				sep = asString(a[1])
+			}
+			_, isBytes := a[0].([]byte)
+			var out []bigquery.Value
+			for _, p := range strings.Split(asString(a[0]), sep) {
+				if isBytes {
+					out = append(out, []byte(p))
+				} else {
+					out = append(out, p)
+				}
+			}
+			return out, nil
+		}},
+		"REPEAT": {2, 2, sameAsFirst(str, byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			n := a[1].(int64)
+			if n < 0 {
+				return nil, errors.New("negative repetitions")
+			}
+			return mapString(a[0], func(s string) string { return strings.Repeat(s, int(n)) }), nil
+		}},
+		"REVERSE": {1, 1, sameAsFirst(str, byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			if b, ok := a[0].([]byte); ok {
+				out := slices.Clone(b)
+				slices.Reverse(out)
+				return out, nil
+			}
+			r := []rune(a[0].(string))
+			slices.Reverse(r)
+			return string(r), nil
+		}},
+		"LEFT":  {2, 2, sameAsFirst(str, byt), leftRight(true)},
+		"RIGHT": {2, 2, sameAsFirst(str, byt), leftRight(false)},
+		"LPAD":  {2, 3, sameAsFirst(str, byt), padFunc(true)},
+		"RPAD":  {2, 3, sameAsFirst(str, byt), padFunc(false)},
+		"REGEXP_CONTAINS": {2, 2, returns(bigquery.BooleanFieldType), func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			re, err := fc.regexp(asString(a[1]))
+			if err != nil {
+				return nil, err
+			}
+			return re.MatchString(asString(a[0])), nil
+		}},
+		"REGEXP_EXTRACT": {2, 2, sameAsFirst(str, byt), func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			re, err := fc.regexp(asString(a[1]))
+			if err != nil {
+				return nil, err
+			}
+			if re.NumSubexp() > 1 {
+				return nil, errors.New("regular expressions can have at most one capturing group")
+			}
+			m := re.FindStringSubmatch(asString(a[0]))
+			if m == nil {
+				return nil, nil
+			}
+			return sameKind(a[0], m[len(m)-1]), nil
+		}},
+		"REGEXP_EXTRACT_ALL": {2, 2, func(args []*compiled) (*bigquery.FieldSchema, error) {
+			t, err := sameAsFirst(str, byt)(args)
+			if err != nil {
+				return nil, err
+			}
+			return &bigquery.FieldSchema{Type: t.Type, Repeated: true}, nil
+		}, func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			re, err := fc.regexp(asString(a[1]))
+			if err != nil {
+				return nil, err
+			}
+			if re.NumSubexp() > 1 {
+				return nil, errors.New("regular expressions can have at most one capturing group")
+			}
+			out := []bigquery.Value{}
+			for _, m := range re.FindAllStringSubmatch(asString(a[0]), -1) {
+				out = append(out, sameKind(a[0], m[len(m)-1]))
+			}
+			return out, nil
+		}},
+		"REGEXP_REPLACE": {3, 3, sameAsFirst(str, byt), func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			re, err := fc.regexp(asString(a[1]))
+			if err != nil {
+				return nil, err
+			}
+			// GoogleSQL refers to groups as \1; Go as ${1}.
+			repl := regexp.MustCompile(`\\(\d)`).ReplaceAllString(strings.ReplaceAll(asString(a[2]), "$", "$$"), "$${$1}")
+			return sameKind(a[0], re.ReplaceAllString(asString(a[0]), repl)), nil
+		}},
+		"TO_BASE64": {1, 1, returns(str), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return base64.StdEncoding.EncodeToString(a[0].([]byte)), nil
+		}},
+		"FROM_BASE64": {1, 1, returns(byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return base64.StdEncoding.DecodeString(a[0].(string))
+		}},
+		"TO_HEX": {1, 1, returns(str), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return hex.EncodeToString(a[0].([]byte)), nil
+		}},
+		"FROM_HEX": {1, 1, returns(byt), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			s := a[0].(string)
+			if len(s)%2 == 1 {
+				s = "0" + s
+			}
+			return hex.DecodeString(s)
+		}},
+		"MD5":    {1, 1, returns(byt), hashFunc(func(b []byte) []byte { h := md5.Sum(b); return h[:] })},
+		"SHA1":   {1, 1, returns(byt), hashFunc(func(b []byte) []byte { h := sha1.Sum(b); return h[:] })},
+		"SHA256": {1, 1, returns(byt), hashFunc(func(b []byte) []byte { h := sha256.Sum256(b); return h[:] })},
+		"SHA512": {1, 1, returns(byt), hashFunc(func(b []byte) []byte { h := sha512.Sum512(b); return h[:] })},
+		"GENERATE_UUID": {0, 0, returns(str), func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+			return uuid.NewString(), nil
+		}},
+
+		// Math functions.
+		"ABS": {1, 1, numericCommon, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			switch x := a[0].(type) {
+			case int64:
+				if x == math.MinInt64 {
+					return nil, errors.New("int64 overflow")
+				}
+				if x < 0 {
+					return -x, nil
+				}
+				return x, nil
+			case float64:
+				return math.Abs(x), nil
+			}
+			return new(big.Rat).Abs(a[0].(*big.Rat)), nil
+		}},
+		"SIGN": {1, 1, numericCommon, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			switch x := a[0].(type) {
+			case int64:
+				return int64(cmpOrdered(x, 0)), nil
+			case float64:
+				if math.IsNaN(x) {
+					return x, nil
+				}
+				return float64(compareFloats(x, 0)), nil
+			}
+			return new(big.Rat).SetInt64(int64(a[0].(*big.Rat).Sign())), nil
+		}},
+		"ROUND": {1, 2, floatOrNumeric, roundFunc(func(f float64) float64 {
+			return math.Round(f)
+		})},
+		"TRUNC": {1, 2, floatOrNumeric, roundFunc(math.Trunc)},
+		"FLOOR": {1, 1, floatOrNumeric, roundFunc(math.Floor)},
+		"CEIL":  {1, 1, floatOrNumeric, roundFunc(math.Ceil)},
+		"MOD": {2, 2, numericCommon, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			switch x := a[0].(type) {
+			case int64:
+				y := a[1].(int64)
+				if y == 0 {
+					return nil, fmt.Errorf("division by zero: MOD(%d, 0)", x)
+				}
+				if y == -1 {
+					return int64(0), nil
+				}
+				return x % y, nil
+			case *big.Rat:
+				y := toRat(a[1])
+				if y.Sign() == 0 {
+					return nil, errors.New("division by zero")
+				}
+				q := new(big.Rat).Quo(x, y)
+				t := new(big.Int).Quo(q.Num(), q.Denom())
+				return new(big.Rat).Sub(x, new(big.Rat).Mul(y, new(big.Rat).SetInt(t))), nil
+			}
+			return nil, errors.New("MOD expects INT64 or NUMERIC arguments")
+		}},
+		"DIV": {2, 2, numericCommon, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			switch x := a[0].(type) {
+			case int64:
+				y := a[1].(int64)
+				if y == 0 {
+					return nil, fmt.Errorf("division by zero: DIV(%d, 0)", x)
+				}
+				if x == math.MinInt64 && y == -1 {
+					return nil, errors.New("int64 overflow")
+				}
+				return x / y, nil
+			case *big.Rat:
+				y := toRat(a[1])
+				if y.Sign() == 0 {
+					return nil, errors.New("division by zero")
+				}
+				q := new(big.Rat).Quo(x, y)
+				return new(big.Rat).SetInt(new(big.Int).Quo(q.Num(), q.Denom())), nil
+			}
+			return nil, errors.New("DIV expects INT64 or NUMERIC arguments")
+		}},
+		"SAFE_DIVIDE": {2, 2, floatOrNumeric, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			if _, ok := a[0].(*big.Rat); ok {
+				y := toRat(a[1])
+				if y.Sign() == 0 {
+					return nil, nil
+				}
+				return new(big.Rat).Quo(a[0].(*big.Rat), y), nil
+			}
+			y := toFloat(a[1])
+			if y == 0 {
+				return nil, nil
+			}
+			return toFloat(a[0]) / y, nil
+		}},
+		"IEEE_DIVIDE": {2, 2, returns(bigquery.FloatFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return toFloat(a[0]) / toFloat(a[1]), nil
+		}},
+		"SQRT": {1, 1, returns(bigquery.FloatFieldType), mathFunc(func(x float64) (float64, error) {
+			if x < 0 {
+				return 0, fmt.Errorf("argument to SQRT cannot be negative: %v", x)
+			}
+			return math.Sqrt(x), nil
+		})},
+		"POW": {2, 2, returns(bigquery.FloatFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return math.Pow(toFloat(a[0]), toFloat(a[1])), nil
+		}},
+		"EXP": {1, 1, returns(bigquery.FloatFieldType), mathFunc(func(x float64) (float64, error) { return math.Exp(x), nil })},
+		"LN": {1, 1, returns(bigquery.FloatFieldType), mathFunc(func(x float64) (float64, error) {
+			if x <= 0 {
+				return 0, fmt.Errorf("argument to LN must be positive: %v", x)
+			}
+			return math.Log(x), nil
+		})},
+		"LOG10": {1, 1, returns(bigquery.FloatFieldType), mathFunc(func(x float64) (float64, error) {
+			if x <= 0 {
+				return 0, fmt.Errorf("argument to LOG10 must be positive: %v", x)
+			}
+			return math.Log10(x), nil
+		})},
+		"LOG": {1, 2, returns(bigquery.FloatFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			x, base := toFloat(a[0]), math.E
+			if len(a) > 1 {
+				base = toFloat(a[1])
+			}
+			if x <= 0 || base <= 0 || base == 1 {
+				return nil, errors.New("invalid argument to LOG")
+			}
+			return math.Log(x) / math.Log(base), nil
+		}},
+		"GREATEST": {1, -1, anyCommon, extremeFunc(1)},
+		"LEAST":    {1, -1, anyCommon, extremeFunc(-1)},
+		"IS_NAN": {1, 1, returns(bigquery.BooleanFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return math.IsNaN(toFloat(a[0])), nil
+		}},
+		"IS_INF": {1, 1, returns(bigquery.BooleanFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return math.IsInf(toFloat(a[0]), 0), nil
+		}},
+
+		// Date and time functions.
+		"CURRENT_TIMESTAMP": {0, 0, returns(bigquery.TimestampFieldType), func(fc *funcContext, _ []bigquery.Value) (bigquery.Value, error) {
+			return fc.now.UTC().Truncate(time.Microsecond), nil
+		}},
+		"CURRENT_DATE": {0, 1, returns(bigquery.DateFieldType), func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			t, err := inZone(fc.now, a, 0)
+			return civil.DateOf(t), err
+		}},
+		"CURRENT_DATETIME": {0, 1, returns(bigquery.DateTimeFieldType), func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			t, err := inZone(fc.now.Truncate(time.Microsecond), a, 0)
+			return civil.DateTimeOf(t), err
+		}},
+		"CURRENT_TIME": {0, 1, returns(bigquery.TimeFieldType), func(fc *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			t, err := inZone(fc.now.Truncate(time.Microsecond), a, 0)
+			return civil.TimeOf(t), err
+		}},
+		"DATE":          {1, 3, returns(bigquery.DateFieldType), funcDate},
+		"DATETIME":      {1, 6, returns(bigquery.DateTimeFieldType), funcDateTime},
+		"TIME":          {1, 3, returns(bigquery.TimeFieldType), funcTime},
+		"TIMESTAMP":     {1, 2, returns(bigquery.TimestampFieldType), funcTimestamp},
+		"DATE_ADD":      {2, 2, returns(bigquery.DateFieldType), addFunc(1)},
+		"DATE_SUB":      {2, 2, returns(bigquery.DateFieldType), addFunc(-1)},
+		"DATETIME_ADD":  {2, 2, returns(bigquery.DateTimeFieldType), addFunc(1)},
+		"DATETIME_SUB":  {2, 2, returns(bigquery.DateTimeFieldType), addFunc(-1)},
+		"TIMESTAMP_ADD": {2, 2, returns(bigquery.TimestampFieldType), addFunc(1)},
+		"TIMESTAMP_SUB": {2, 2, returns(bigquery.TimestampFieldType), addFunc(-1)},
+		"TIME_ADD":      {2, 2, returns(bigquery.TimeFieldType), addFunc(1)},
+		"TIME_SUB":      {2, 2, returns(bigquery.TimeFieldType), addFunc(-1)},
+		"DATE_DIFF":     {3, 3, returns(bigquery.IntegerFieldType), diffFunc},
+		"DATETIME_DIFF": {3, 3, returns(bigquery.IntegerFieldType), diffFunc},
+		"TIME_DIFF":     {3, 3, returns(bigquery.IntegerFieldType), diffFunc},
+		"TIMESTAMP_DIFF": {3, 3, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			d := a[0].(time.Time).Sub(a[1].(time.Time))
+			unit, ok := unitDuration(a[2].(string))
+			if !ok {
+				return nil, fmt.Errorf("unsupported date part %s", a[2])
+			}
+			return int64(d / unit), nil
+		}},
+		"DATE_TRUNC":      {2, 2, sameAsFirst(bigquery.DateFieldType), truncFunc},
+		"DATETIME_TRUNC":  {2, 2, sameAsFirst(bigquery.DateTimeFieldType), truncFunc},
+		"TIMESTAMP_TRUNC": {2, 2, sameAsFirst(bigquery.TimestampFieldType), truncFunc},
+		"TIME_TRUNC":      {2, 2, sameAsFirst(bigquery.TimeFieldType), truncFunc},
+		"LAST_DAY": {1, 2, returns(bigquery.DateFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			part := "MONTH"
+			if len(a) > 1 {
+				part = a[1].(string)
+			}
+			start, err := truncDateTime(toDateTime(a[0]), part)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case part == "YEAR":
+				return addMonths(start.Date, 12).AddDays(-1), nil
+			case part == "QUARTER":
+				return addMonths(start.Date, 3).AddDays(-1), nil
+			case part == "MONTH":
+				return addMonths(start.Date, 1).AddDays(-1), nil
+			case strings.HasPrefix(part, "WEEK") || part == "ISOWEEK":
+				return start.Date.AddDays(6), nil
+			}
+			return nil, fmt.Errorf("unsupported date part %s", part)
+		}},
+		"UNIX_SECONDS": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return a[0].(time.Time).Unix(), nil
+		}},
+		"UNIX_MILLIS": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return a[0].(time.Time).UnixMilli(), nil
+		}},
+		"UNIX_MICROS": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return a[0].(time.Time).UnixMicro(), nil
+		}},
+		"UNIX_DATE": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return int64(a[0].(civil.Date).DaysSince(civil.Date{Year: 1970, Month: 1, Day: 1})), nil
+		}},
+		"TIMESTAMP_SECONDS": {1, 1, returns(bigquery.TimestampFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return time.Unix(a[0].(int64), 0).UTC(), nil
+		}},
+		"TIMESTAMP_MILLIS": {1, 1, returns(bigquery.TimestampFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return time.UnixMilli(a[0].(int64)).UTC(), nil
+		}},
+		"TIMESTAMP_MICROS": {1, 1, returns(bigquery.TimestampFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return time.UnixMicro(a[0].(int64)).UTC(), nil
+		}},
+		"DATE_FROM_UNIX_DATE": {1, 1, returns(bigquery.DateFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return civil.Date{Year: 1970, Month: 1, Day: 1}.AddDays(int(a[0].(int64))), nil
+		}},
+		"FORMAT_DATE":      {2, 2, returns(str), formatFunc},
+		"FORMAT_DATETIME":  {2, 2, returns(str), formatFunc},
+		"FORMAT_TIME":      {2, 2, returns(str), formatFunc},
+		"FORMAT_TIMESTAMP": {2, 3, returns(str), formatFunc},
+		"PARSE_DATE": {2, 2, returns(bigquery.DateFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			t, err := parseWithFormat(a[0].(string), a[1].(string))
+			return civil.DateOf(t), err
+		}},
+		"PARSE_DATETIME": {2, 2, returns(bigquery.DateTimeFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			t, err := parseWithFormat(a[0].(string), a[1].(string))
+			return civil.DateTimeOf(t), err
+		}},
+		"PARSE_TIMESTAMP": {2, 2, returns(bigquery.TimestampFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			t, err := parseWithFormat(a[0].(string), a[1].(string))
+			return t.UTC(), err
+		}},
+
+		// Array functions.
+		"ARRAY_LENGTH": {1, 1, returns(bigquery.IntegerFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			return int64(len(a[0].([]bigquery.Value))), nil
+		}},
+		"ARRAY_CONCAT": {1, -1, func(args []*compiled) (*bigquery.FieldSchema, error) {
+			t, err := anyCommon(args)
+			if err == nil && !t.Repeated {
+				err = errors.New("expected arrays")
+			}
+			return t, err
+		}, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			out := []bigquery.Value{}
+			for _, v := range a {
+				out = append(out, v.([]bigquery.Value)...)
+			}
+			return out, nil
+		}},
+		"ARRAY_REVERSE": {1, 1, func(args []*compiled) (*bigquery.FieldSchema, error) {
+			if !args[0].typ.Repeated {
+				return nil, errors.New("expected an array")
+			}
+			return args[0].typ, nil
+		}, func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			out := slices.Clone(a[0].([]bigquery.Value))
+			slices.Reverse(out)
+			return out, nil
+		}},
+		"GENERATE_ARRAY": {2, 3, func(args []*compiled) (*bigquery.FieldSchema, error) {
+			t, err := numericCommon(args)
+			if err != nil {
+				return nil, err
+			}
+			return &bigquery.FieldSchema{Type: t.Type, Repeated: true}, nil
+		}, generateArray},
+		"GENERATE_DATE_ARRAY": {2, 3, returnsArray(bigquery.DateFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			step := &bigquery.IntervalValue{Days: 1}
+			if len(a) > 2 {
+				step = a[2].(*bigquery.IntervalValue)
+			}
+			if intervalMicros(step) == 0 {
+				return nil, errors.New("step cannot be zero")
+			}
+			start, end := a[0].(civil.Date), a[1].(civil.Date)
+			out := []bigquery.Value{}
+			for i := 0; ; i++ {
+				n := i
+				iv := &bigquery.IntervalValue{Years: step.Years * int32(n), Months: step.Months * int32(n), Days: step.Days * int32(n)}
+				v, err := addInterval(civil.DateTime{Date: start}, iv)
+				if err != nil {
+					return nil, err
+				}
+				d := v.(civil.DateTime).Date
+				if intervalMicros(step) > 0 && d.After(end) || intervalMicros(step) < 0 && d.Before(end) {
+					return out, nil
+				}
+				if len(out) >= maxGeneratedArray {
+					return nil, errors.New("generated array is too large")
+				}
+				out = append(out, d)
+			}
+		}},
+
+		// JSON functions.
+		"JSON_VALUE": {1, 2, returns(str), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			v, ok, err := jsonPath(a, "$")
+			if !ok || err != nil {
+				return nil, err
+			}
+			switch v := v.(type) {
+			case map[string]any, []any, nil:
+				return nil, nil
+			case string:
+				return v, nil
+			default:
+				return fmt.Sprint(v), nil
+			}
+		}},
+		"JSON_QUERY": {2, 2, sameAsFirst(str, bigquery.JSONFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			v, ok, err := jsonPath(a, "")
+			if !ok || err != nil {
+				return nil, err
+			}
+			b, err := json.Marshal(v)
+			return string(b), err
+		}},
+		"PARSE_JSON": {1, 1, returns(bigquery.JSONFieldType), func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+			if !json.Valid([]byte(a[0].(string))) {
+				return nil, fmt.Errorf("invalid JSON: %s", a[0])
+			}
+			return a[0], nil
+		}},
+	}
+	for alias, name := range map[string]string{
+		"SUBSTRING": "SUBSTR", "CHAR_LENGTH": "LENGTH", "CHARACTER_LENGTH": "LENGTH",
+		"CEILING": "CEIL", "POWER": "POW", "INSTR": "STRPOS",
+	} {
+		scalarFuncs[alias] = scalarFuncs[name]
+	}
+}
+
+// maxGeneratedArray bounds the arrays made by GENERATE_ARRAY.
+const maxGeneratedArray = 1 << 20
+
+func (c *compiler) toJSONString(x *compiled) *compiled {
+	typ := x.typ
+	return strict(stringType, func(v bigquery.Value) (bigquery.Value, error) {
+		b, err := json.Marshal(toJSON(v, typ))
+		return string(b), err
+	}, x)
+}
+
+// toJSON converts a value to its JSON representation, as in TO_JSON_STRING.
+func toJSON(v bigquery.Value, fs *bigquery.FieldSchema) any {
+	if v == nil {
+		return nil
+	}
+	if fs.Repeated {
+		elem := *fs
+		elem.Repeated = false
+		var out []any
+		for _, e := range v.([]bigquery.Value) {
+			out = append(out, toJSON(e, &elem))
+		}
+		if out == nil {
+			out = []any{}
+		}
+		return out
+	}
+	switch fs.Type {
+	case bigquery.RecordFieldType:
+		fields := v.([]bigquery.Value)
+		out := orderedObject{}
+		for i, f := range fs.Schema {
+			out = append(out, orderedField{f.Name, toJSON(fields[i], f)})
+		}
+		return out
+	case bigquery.IntegerFieldType, bigquery.BooleanFieldType:
+		return v
+	case bigquery.FloatFieldType:
+		if f := v.(float64); math.IsNaN(f) || math.IsInf(f, 0) {
+			return formatFloat(f)
+		}
+		return v
+	case bigquery.JSONFieldType:
+		return json.RawMessage(v.(string))
+	}
+	s, _ := castToString(v, fs.Type)
+	return s
+}
+
+// orderedObject is a JSON object that keeps the order of its fields.
+type orderedObject []orderedField
+
+type orderedField struct {
+	name  string
+	value any
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func mapString(v bigquery.Value, f func(string) string) bigquery.Value {
+	return sameKind(v, f(asString(v)))
+}
+
+// sameKind returns s as a STRING or BYTES value, like v.
+func sameKind(v bigquery.Value, s string) bigquery.Value {
+	if _, ok := v.([]byte); ok {
+		return []byte(s)
+	}
+	return s
+}
+
+func funcSubstr(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	var units []string
+	if b, ok := a[0].([]byte); ok {
+		for _, c := range b {
+			units = append(units, string(c))
+		}
+	} else {
+		for _, r := range a[0].(string) {
+			units = append(units, string(r))
+		}
+	}
+	n := int64(len(units))
+	pos := a[1].(int64)
+	switch {
+	case pos > 0:
+		pos--
+	case pos < 0:
+		pos = max(n+pos, 0)
+	}
+	pos = min(pos, n)
+	end := n
+	if len(a) > 2 {
+		l := a[2].(int64)
+		if l < 0 {
+			return nil, fmt.Errorf("third argument must not be negative: %d", l)
+		}
+		end = min(pos+l, n)
+	}
+	return sameKind(a[0], strings.Join(units[pos:end], "")), nil
+}
+
+func trimFunc(trim func(string, string) string) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		cut := " \t\n\r\v\f"
+		if len(a) > 1 {
+			cut = asString(a[1])
+		}
+		return mapString(a[0], func(s string) string { return trim(s, cut) }), nil
+	}
+}
+
+func leftRight(left bool) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		n := a[1].(int64)
+		if n < 0 {
+			return nil, fmt.Errorf("second argument must not be negative: %d", n)
+		}
+		var units []string
+		if b, ok := a[0].([]byte); ok {
+			for _, c := range b {
+				units = append(units, string(c))
+			}
+		} else {
+			for _, r := range a[0].(string) {
+				units = append(units, string(r))
+			}
+		}
+		n = min(n, int64(len(units)))
+		if left {
+			return sameKind(a[0], strings.Join(units[:n], "")), nil
+		}
+		return sameKind(a[0], strings.Join(units[int64(len(units))-n:], "")), nil
+	}
+}
+
+func padFunc(left bool) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		s, n := []rune(asString(a[0])), a[1].(int64)
+		pad := []rune(" ")
+		if len(a) > 2 {
+			pad = []rune(asString(a[2]))
+		}
+		if n < 0 || n > 1<<20 {
+			return nil, fmt.Errorf("invalid length %d", n)
+		}
+		if int64(len(s)) >= n {
+			return sameKind(a[0], string(s[:n])), nil
+		}
+		if len(pad) == 0 {
+			return nil, errors.New("pattern must not be empty")
+		}
+		var fill []rune
+		for int64(len(fill)+len(s)) < n {
+			fill = append(fill, pad[len(fill)%len(pad)])
+		}
+		if left {
+			return sameKind(a[0], string(fill)+string(s)), nil
+		}
+		return sameKind(a[0], string(s)+string(fill)), nil
+	}
+}
+
+func hashFunc(h func([]byte) []byte) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		return h([]byte(asString(a[0]))), nil
+	}
+}
+
+func mathFunc(f func(float64) (float64, error)) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		return f(toFloat(a[0]))
+	}
+}
+
+// roundFunc returns a rounding function that takes an optional number of
+// digits.
+func roundFunc(round func(float64) float64) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		digits := int64(0)
+		if len(a) > 1 {
+			digits = a[1].(int64)
+		}
+		if r, ok := a[0].(*big.Rat); ok {
+			scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(abs(digits)), nil))
+			if digits < 0 {
+				scale.Inv(scale)
+			}
+			x, _ := new(big.Rat).Mul(r, scale).Float64()
+			whole := round(x)
+			// Round exactly when the result is representable.
+			if f, _ := new(big.Rat).Mul(r, scale).Float64(); math.Abs(f) < 1<<52 {
+				q := new(big.Rat).Mul(r, scale)
+				n := new(big.Int).Quo(q.Num(), q.Denom())
+				frac := new(big.Rat).Sub(q, new(big.Rat).SetInt(n))
+				whole = float64(n.Int64()) + round(toFloat(frac))
+			}
+			return new(big.Rat).Quo(new(big.Rat).SetFloat64(whole), scale), nil
+		}
+		x := toFloat(a[0])
+		p := math.Pow(10, float64(digits))
+		return round(x*p) / p, nil
+	}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func extremeFunc(sign int) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		best := a[0]
+		for _, v := range a[1:] {
+			if isNaN(v) {
+				return v, nil
+			}
+			c, err := compareValues(v, best)
+			if err != nil {
+				return nil, err
+			}
+			if c*sign > 0 {
+				best = v
+			}
+		}
+		return best, nil
+	}
+}
+
+func generateArray(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	if _, ok := a[0].(int64); ok {
+		start, end, step := a[0].(int64), a[1].(int64), int64(1)
+		if len(a) > 2 {
+			step = a[2].(int64)
+		}
+		if step == 0 {
+			return nil, errors.New("step cannot be zero")
+		}
+		out := []bigquery.Value{}
+		for v := start; step > 0 && v <= end || step < 0 && v >= end; v += step {
+			if len(out) >= maxGeneratedArray {
+				return nil, errors.New("generated array is too large")
+			}
+			out = append(out, v)
+			if step > 0 && v > math.MaxInt64-step || step < 0 && v < math.MinInt64-step {
+				break
+			}
+		}
+		return out, nil
+	}
+	start, end, step := toFloat(a[0]), toFloat(a[1]), 1.0
+	if len(a) > 2 {
+		step = toFloat(a[2])
+	}
+	if step == 0 || math.IsNaN(step) {
+		return nil, errors.New("invalid step")
+	}
+	out := []bigquery.Value{}
+	for i := 0; ; i++ {
+		v := start + float64(i)*step
+		if step > 0 && v > end || step < 0 && v < end {
+			return out, nil
+		}
+		if len(out) >= maxGeneratedArray {
+			return nil, errors.New("generated array is too large")
+		}
+		out = append(out, v)
+	}
+}
+
+// inZone returns t in the time zone given by the argument at index i, or
+// in UTC.
+func inZone(t time.Time, a []bigquery.Value, i int) (time.Time, error) {
+	if len(a) <= i {
+		return t.UTC(), nil
+	}
+	loc, err := loadZone(a[i].(string))
+	if err != nil {
+		return t, err
+	}
+	return t.In(loc), nil
+}
+
+// loadZone loads a time zone by name, or by an offset like +05:30.
+func loadZone(name string) (*time.Location, error) {
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		t, err := time.Parse("-07:00", name)
+		if err != nil {
+			if t, err = time.Parse("-07", name); err != nil {
+				return nil, fmt.Errorf("invalid time zone: %s", name)
+			}
+		}
+		_, off := t.Zone()
+		return time.FixedZone(name, off), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %s", name)
+	}
+	return loc, nil
+}
+
+func funcDate(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	switch x := a[0].(type) {
+	case int64:
+		if len(a) != 3 {
+			return nil, errors.New("expected year, month and day")
+		}
+		d := civil.Date{Year: int(x), Month: time.Month(a[1].(int64)), Day: int(a[2].(int64))}
+		if !d.IsValid() {
+			return nil, fmt.Errorf("invalid date %v", d)
+		}
+		return d, nil
+	case time.Time:
+		t, err := inZone(x, a, 1)
+		return civil.DateOf(t), err
+	case civil.DateTime:
+		return x.Date, nil
+	case civil.Date:
+		return x, nil
+	case string:
+		return civil.ParseDate(x)
+	}
+	return nil, fmt.Errorf("unsupported argument %T", a[0])
+}
+
+func funcDateTime(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	switch x := a[0].(type) {
+	case int64:
+		if len(a) != 6 {
+			return nil, errors.New("expected year, month, day, hour, minute and second")
+		}
+		dt := civil.DateTime{
+			Date: civil.Date{Year: int(x), Month: time.Month(a[1].(int64)), Day: int(a[2].(int64))},
+			Time: civil.Time{Hour: int(a[3].(int64)), Minute: int(a[4].(int64)), Second: int(a[5].(int64))},
+		}
+		if !dt.IsValid() {
+			return nil, errors.New("invalid datetime")
+		}
+		return dt, nil
+	case civil.Date:
+		dt := civil.DateTime{Date: x}
+		if len(a) > 1 {
+			t, ok := a[1].(civil.Time)
+			if !ok {
+				return nil, errors.New("expected a TIME")
+			}
+			dt.Time = t
+		}
+		return dt, nil
+	case time.Time:
+		t, err := inZone(x, a, 1)
+		return civil.DateTimeOf(t), err
+	case civil.DateTime:
+		return x, nil
+	case string:
+		return parseDateTime(x)
+	}
+	return nil, fmt.Errorf("unsupported argument %T", a[0])
+}
+
+func funcTime(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	switch x := a[0].(type) {
+	case int64:
+		if len(a) != 3 {
+			return nil, errors.New("expected hour, minute and second")
+		}
+		t := civil.Time{Hour: int(x), Minute: int(a[1].(int64)), Second: int(a[2].(int64))}
+		if !t.IsValid() {
+			return nil, errors.New("invalid time")
+		}
+		return t, nil
+	case time.Time:
+		t, err := inZone(x, a, 1)
+		return civil.TimeOf(t), err
+	case civil.DateTime:
+		return x.Time, nil
+	case civil.Time:
+		return x, nil
+	}
+	return nil, fmt.Errorf("unsupported argument %T", a[0])
+}
+
+func funcTimestamp(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	loc := time.UTC
+	if len(a) > 1 {
+		var err error
+		if loc, err = loadZone(a[1].(string)); err != nil {
+			return nil, err
+		}
+	}
+	switch x := a[0].(type) {
+	case string:
+		t, err := parseTimestamp(x)
+		if err != nil {
+			return nil, err
+		}
+		if loc != time.UTC && !hasZone(x) {
+			t = civil.DateTimeOf(t).In(loc).UTC()
+		}
+		return t, nil
+	case civil.Date:
+		return x.In(loc).UTC(), nil
+	case civil.DateTime:
+		return x.In(loc).UTC(), nil
+	case time.Time:
+		return x, nil
+	}
+	return nil, fmt.Errorf("unsupported argument %T", a[0])
+}
+
+// hasZone reports whether a timestamp string has a time zone.
+func hasZone(s string) bool {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "UTC") || strings.HasSuffix(s, "Z") || strings.HasSuffix(s, "z") {
+		return true
+	}
+	if len(s) > 10 {
+		return strings.ContainsAny(s[10:], "+-")
+	}
+	return false
+}
+
+func addFunc(sign int32) func(*funcContext, []bigquery.Value) (bigquery.Value, error) {
+	return func(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+		iv, ok := a[1].(*bigquery.IntervalValue)
+		if !ok {
+			return nil, errors.New("expected an INTERVAL")
+		}
+		if sign < 0 {
+			iv = negateInterval(iv)
+		}
+		if d, ok := a[0].(civil.Date); ok {
+			if iv.Hours != 0 || iv.Minutes != 0 || iv.Seconds != 0 || iv.SubSecondNanos != 0 {
+				return nil, errors.New("DATE arithmetic supports only date parts")
+			}
+			v, err := addInterval(civil.DateTime{Date: d}, iv)
+			if err != nil {
+				return nil, err
+			}
+			return v.(civil.DateTime).Date, nil
+		}
+		return addInterval(a[0], iv)
+	}
+}
+
+func diffFunc(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	return diffDateTime(toDateTime(a[0]), toDateTime(a[1]), a[2].(string))
+}
+
+func truncFunc(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	part := a[1].(string)
+	dt, err := truncDateTime(toDateTime(a[0]), part)
+	if err != nil {
+		return nil, err
+	}
+	switch a[0].(type) {
+	case civil.Date:
+		return dt.Date, nil
+	case civil.Time:
+		if !isTimePart(part) {
+			return nil, fmt.Errorf("unsupported date part %s for TIME", part)
+		}
+		return dt.Time, nil
+	case time.Time:
+		return dt.In(time.UTC), nil
+	}
+	return dt, nil
+}
+
+// strftime maps format elements to Go layouts.
+var strftime = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'H': "15", 'I': "03", 'M': "04", 'S': "05",
+	'F': "2006-01-02", 'T': "15:04:05", 'D': "01/02/06", 'R': "15:04", 'b': "Jan", 'h': "Jan", 'B': "January",
+	'a': "Mon", 'A': "Monday", 'p': "PM", 'Z': "MST", 'z': "-0700", 'j': "002", '%': "%",
+}
+
+// layoutOf converts a format string, like %Y-%m-%d, to a Go layout.
+func layoutOf(format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
+			continue
+		}
+		if i+1 == len(format) {
+			return "", errors.New("format ends with %")
+		}
+		i++
+		switch {
+		case strings.HasPrefix(format[i:], "E6S"):
+			sb.WriteString("05.000000")
+			i += 2
+		case strings.HasPrefix(format[i:], "E3S"):
+			sb.WriteString("05.000")
+			i += 2
+		case strings.HasPrefix(format[i:], "E*S"):
+			sb.WriteString("05.999999")
+			i += 2
+		default:
+			l, ok := strftime[format[i]]
+			if !ok {
+				return "", fmt.Errorf("unsupported format element %%%c", format[i])
+			}
+			sb.WriteString(l)
+		}
+	}
+	return sb.String(), nil
+}
+
+func formatFunc(_ *funcContext, a []bigquery.Value) (bigquery.Value, error) {
+	layout, err := layoutOf(a[0].(string))
+	if err != nil {
+		return nil, err
+	}
+	var t time.Time
+	switch x := a[1].(type) {
+	case time.Time:
+		if t, err = inZone(x, a, 2); err != nil {
+			return nil, err
+		}
+	default:
+		t = toDateTime(x).In(time.UTC)
+	}
+	return t.Format(layout), nil
+}
+
+func parseWithFormat(format, s string) (time.Time, error) {
+	layout, err := layoutOf(format)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse input %q", s)
+	}
+	return t, nil
+}
+
+// jsonPath evaluates a JSONPath, like $.a.b[0], given as the second argument
+// or defaulting to def.
+func jsonPath(a []bigquery.Value, def string) (any, bool, error) {
+	path := def
+	if len(a) > 1 {
+		path = a[1].(string)
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, false, fmt.Errorf("JSONPath must start with '$': %s", path)
+	}
+	var v any
+	if err := decodeJSON([]byte(a[0].(string)), &v); err != nil {
+		return nil, false, nil
+	}
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := strings.Trim(rest[:end], `"`)
+			rest = rest[end:]
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false, nil
+			}
+			if v, ok = m[key]; !ok {
+				return nil, false, nil
+			}
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, false, fmt.Errorf("invalid JSONPath: %s", path)
+			}
+			key := strings.Trim(rest[1:end], `'"`)
+			rest = rest[end+1:]
+			switch x := v.(type) {
+			case []any:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(x) {
+					return nil, false, nil
+				}
+				v = x[i]
+			case map[string]any:
+				var ok bool
+				if v, ok = x[key]; !ok {
+					return nil, false, nil
+				}
+			default:
+				return nil, false, nil
+			}
+		default:
+			return nil, false, fmt.Errorf("invalid JSONPath: %s", path)
+		}
+	}
+	return v, true, nil
+}
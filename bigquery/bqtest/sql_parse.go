@@ -0,0 +1,1650 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+)
+
+// This file parses the subset of GoogleSQL understood by the default
+// engine: SELECT statements with WITH, joins, UNNEST, grouping, set
+// operations, ordering and limits, over expressions with the common
+// operators and functions.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokNumber
+	tokString
+	tokBytes
+	tokParam
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string // For strings and bytes, the unescaped value.
+	pos  int
+	end  int
+}
+
+// reserved holds the reserved keywords, which are not identifiers unless
+// quoted.
+var reserved = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`ALL AND ANY ARRAY AS ASC BETWEEN BY CASE CAST CROSS
+		DESC DISTINCT ELSE END EXCEPT EXISTS EXTRACT FALSE FOR FROM FULL GROUP HAVING
+		IN INNER INTERSECT INTERVAL IS JOIN LEFT LIKE LIMIT NOT NULL NULLS ON OR ORDER
+		OUTER OVER RIGHT SELECT STRUCT THEN TRUE UNION UNNEST USING WHEN WHERE WINDOW WITH`) {
+		reserved[k] = true
+	}
+}
+
+func lex(sql string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at %d", i)
+			}
+			i += end + 4
+		case c == '`':
+			end := strings.IndexByte(sql[i+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted identifier at %d", i)
+			}
+			toks = append(toks, token{kind: tokQuotedIdent, text: sql[i+1 : i+1+end], pos: i, end: i + end + 2})
+			i += end + 2
+		case c == '\'' || c == '"' || isStringPrefix(sql[i:]):
+			tok, err := lexString(sql, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = tok.end
+		case c >= '0' && c <= '9' || (c == '.' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9' && !afterIdent(toks, i)):
+			j := i
+			if strings.HasPrefix(strings.ToLower(sql[i:]), "0x") {
+				j += 2
+				for j < len(sql) && strings.IndexByte("0123456789abcdefABCDEF", sql[j]) >= 0 {
+					j++
+				}
+			} else {
+				for j < len(sql) && (sql[j] >= '0' && sql[j] <= '9' || sql[j] == '.') {
+					j++
+				}
+				if j < len(sql) && (sql[j] == 'e' || sql[j] == 'E') {
+					j++
+					if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+						j++
+					}
+					for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+						j++
+					}
+				}
+			}
+			toks = append(toks, token{kind: tokNumber, text: sql[i:j], pos: i, end: j})
+			i = j
+		case c == '@' || c == '?':
+			j := i + 1
+			if c == '@' {
+				for j < len(sql) && isIdentChar(sql[j]) {
+					j++
+				}
+				if j == i+1 {
+					return nil, fmt.Errorf("invalid parameter at %d", i)
+				}
+			}
+			toks = append(toks, token{kind: tokParam, text: sql[i+1 : j], pos: i, end: j})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: sql[i:j], pos: i, end: j})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"<=", ">=", "<>", "!=", "||", "<<", ">>"} {
+				if strings.HasPrefix(sql[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				if strings.IndexByte("(),.*+-/=<>[];&|^~", c) < 0 {
+					return nil, fmt.Errorf("syntax error: unexpected character %q at %d", c, i)
+				}
+				op = string(c)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i, end: i + len(op)})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(sql), end: len(sql)}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// afterIdent reports whether the character at i immediately follows an
+// identifier, in which case a dot is a path separator.
+func afterIdent(toks []token, i int) bool {
+	if len(toks) == 0 {
+		return false
+	}
+	last := toks[len(toks)-1]
+	return last.end == i && (last.kind == tokIdent || last.kind == tokQuotedIdent || last.text == ")" || last.text == "]")
+}
+
+func isStringPrefix(s string) bool {
+	for _, p := range []string{"r", "b", "rb", "br"} {
+		if len(s) > len(p) && strings.EqualFold(s[:len(p)], p) && (s[len(p)] == '\'' || s[len(p)] == '"') {
+			return true
+		}
+	}
+	return false
+}
+
+// lexString lexes a string or bytes literal starting at i, with an optional
+// r (raw) or b (bytes) prefix and single, double or triple quotes.
+func lexString(sql string, i int) (token, error) {
+	start := i
+	raw, isBytes := false, false
+	for sql[i] != '\'' && sql[i] != '"' {
+		switch sql[i] {
+		case 'r', 'R':
+			raw = true
+		case 'b', 'B':
+			isBytes = true
+		}
+		i++
+	}
+	quote := sql[i : i+1]
+	if strings.HasPrefix(sql[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	i += len(quote)
+	var sb strings.Builder
+	for {
+		if i >= len(sql) {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		if strings.HasPrefix(sql[i:], quote) {
+			i += len(quote)
+			break
+		}
+		c := sql[i]
+		if len(quote) == 1 && c == '\n' {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 >= len(sql) {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		if raw {
+			sb.WriteString(sql[i : i+2])
+			i += 2
+			continue
+		}
+		e := sql[i+1]
+		i += 2
+		switch e {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			if i+2 > len(sql) {
+				return token{}, fmt.Errorf("invalid escape at %d", i-2)
+			}
+			n, err := strconv.ParseUint(sql[i-1:i+2], 8, 8)
+			if err != nil {
+				return token{}, fmt.Errorf("invalid escape at %d", i-2)
+			}
+			sb.WriteByte(byte(n))
+			i += 2
+		case 'x', 'X', 'u', 'U':
+			n := map[byte]int{'x': 2, 'X': 2, 'u': 4, 'U': 8}[e]
+			if i+n > len(sql) {
+				return token{}, fmt.Errorf("invalid escape at %d", i-2)
+			}
+			v, err := strconv.ParseUint(sql[i:i+n], 16, 32)
+			if err != nil {
+				return token{}, fmt.Errorf("invalid escape at %d", i-2)
+			}
+			if n == 2 {
+				sb.WriteByte(byte(v))
+			} else {
+				sb.WriteRune(rune(v))
+			}
+			i += n
+		default:
+			sb.WriteByte(e)
+		}
+	}
+	kind := tokString
+	if isBytes {
+		kind = tokBytes
+	} else if !utf8.ValidString(sb.String()) {
+		return token{}, fmt.Errorf("invalid UTF-8 in string at %d", start)
+	}
+	return token{kind: kind, text: sb.String(), pos: start, end: i}, nil
+}
+
+// The statement and expression trees.
+
+type queryStmt struct {
+	with    []cte
+	body    setExpr
+	orderBy []orderItem
+	limit   expr
+	offset  expr
+}
+
+type cte struct {
+	name  string
+	query *queryStmt
+}
+
+// setExpr is a *selectStmt, *setOp or *queryStmt.
+type setExpr any
+
+type setOp struct {
+	op       string // UNION, INTERSECT or EXCEPT
+	distinct bool
+	l, r     setExpr
+}
+
+type selectStmt struct {
+	distinct bool
+	items    []selectItem
+	from     fromItem
+	where    expr
+	groupBy  []expr
+	having   expr
+}
+
+type selectItem struct {
+	expr   expr
+	alias  string
+	star   bool
+	qual   []string // The qualifier of a star, as in t.*.
+	except []string
+}
+
+type orderItem struct {
+	expr       expr
+	desc       bool
+	nullsFirst *bool
+}
+
+// fromItem is a *tableRef, *subqueryRef, *unnestRef or *join.
+type fromItem any
+
+type tableRef struct {
+	path  []string
+	alias string
+}
+
+type subqueryRef struct {
+	query *queryStmt
+	alias string
+}
+
+type unnestRef struct {
+	array       expr
+	alias       string
+	offsetAlias string // Set WITH OFFSET.
+	withOffset  bool
+}
+
+type join struct {
+	kind  string // INNER, LEFT, RIGHT, FULL or CROSS
+	l, r  fromItem
+	on    expr
+	using []string
+}
+
+// expr is an expression node.
+type expr any
+
+type literal struct {
+	v   bigquery.Value
+	typ bigquery.FieldType
+}
+
+type paramRef struct {
+	name string
+	pos  int // For positional parameters, the 0-based position.
+}
+
+type pathExpr struct {
+	parts []string
+}
+
+type unaryExpr struct {
+	op string
+	x  expr
+}
+
+type binaryExpr struct {
+	op   string
+	l, r expr
+}
+
+type isExpr struct {
+	x    expr
+	not  bool
+	what string // NULL, TRUE or FALSE
+}
+
+type inExpr struct {
+	x      expr
+	list   []expr
+	unnest expr
+	query  *queryStmt
+	not    bool
+}
+
+// subqueryExpr is a scalar, ARRAY or EXISTS subquery.
+type subqueryExpr struct {
+	kind  string // SCALAR, ARRAY or EXISTS
+	query *queryStmt
+}
+
+type betweenExpr struct {
+	x, lo, hi expr
+	not       bool
+}
+
+type likeExpr struct {
+	x, pattern expr
+	not        bool
+}
+
+type caseExpr struct {
+	operand expr
+	whens   []whenClause
+	els     expr
+}
+
+type whenClause struct {
+	cond, result expr
+}
+
+type castExpr struct {
+	x    expr
+	typ  *bigquery.FieldSchema
+	safe bool
+}
+
+type callExpr struct {
+	name     string // Upper case, without a SAFE. prefix.
+	safe     bool
+	args     []expr
+	star     bool
+	distinct bool
+
+	// The modifiers of aggregate functions.
+	ignoreNulls bool
+	orderBy     []orderItem
+	limit       expr
+}
+
+type arrayExpr struct {
+	elems []expr
+}
+
+type structExpr struct {
+	fields []expr
+	names  []string
+}
+
+type indexExpr struct {
+	x, index expr
+	mode     string // OFFSET, ORDINAL, SAFE_OFFSET or SAFE_ORDINAL
+}
+
+type intervalExpr struct {
+	n    expr
+	unit string
+}
+
+type extractExpr struct {
+	part string
+	x    expr
+}
+
+type parser struct {
+	toks      []token
+	i         int
+	positions int
+}
+
+func parseQuery(sql string) (*queryStmt, error) {
+	toks, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	q, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptOp(";")
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", t.text)
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) peekN(n int) token {
+	if p.i+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.i+n]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+// isOp reports whether the token is the operator op.
+func isOp(t token, op string) bool {
+	return t.kind == tokOp && t.text == op
+}
+
+// isKeyword reports whether the token is the unquoted keyword kw.
+func isKeyword(t token, kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) acceptKeyword(kws ...string) bool {
+	for i, kw := range kws {
+		if !isKeyword(p.peekN(i), kw) {
+			return false
+		}
+	}
+	p.i += len(kws)
+	return true
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.errorf("expected %s, got %q", kw, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf("expected %q, got %q", op, p.peek().text)
+	}
+	return nil
+}
+
+// ident parses an identifier, which may be quoted.
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokQuotedIdent:
+		p.i++
+		return t.text, nil
+	case t.kind == tokIdent && !reserved[strings.ToUpper(t.text)]:
+		p.i++
+		return t.text, nil
+	}
+	return "", p.errorf("expected identifier, got %q", t.text)
+}
+
+// alias parses an optional alias, with or without AS.
+func (p *parser) alias() (string, error) {
+	if p.acceptKeyword("AS") {
+		return p.ident()
+	}
+	if t := p.peek(); t.kind == tokQuotedIdent || (t.kind == tokIdent && !reserved[strings.ToUpper(t.text)]) {
+		return p.ident()
+	}
+	return "", nil
+}
+
+func (p *parser) query() (*queryStmt, error) {
+	q := &queryStmt{}
+	if p.acceptKeyword("WITH") {
+		p.acceptKeyword("RECURSIVE")
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("AS"); err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			sub, err := p.query()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			q.with = append(q.with, cte{name: name, query: sub})
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	body, err := p.setExpr()
+	if err != nil {
+		return nil, err
+	}
+	q.body = body
+	if p.acceptKeyword("ORDER", "BY") {
+		if q.orderBy, err = p.orderItems(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		if q.limit, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if p.acceptKeyword("OFFSET") {
+			if q.offset, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return q, nil
+}
+
+func (p *parser) orderItems() ([]orderItem, error) {
+	var items []orderItem
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		item := orderItem{expr: e}
+		if p.acceptKeyword("DESC") {
+			item.desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		if p.acceptKeyword("NULLS") {
+			first := p.acceptKeyword("FIRST")
+			if !first {
+				if err := p.expectKeyword("LAST"); err != nil {
+					return nil, err
+				}
+			}
+			item.nullsFirst = &first
+		}
+		items = append(items, item)
+		if !p.acceptOp(",") {
+			return items, nil
+		}
+	}
+}
+
+func (p *parser) setExpr() (setExpr, error) {
+	l, err := p.setTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.acceptKeyword("UNION"):
+			op = "UNION"
+		case p.acceptKeyword("INTERSECT"):
+			op = "INTERSECT"
+		case p.acceptKeyword("EXCEPT"):
+			op = "EXCEPT"
+		default:
+			return l, nil
+		}
+		distinct := p.acceptKeyword("DISTINCT")
+		if !distinct {
+			if err := p.expectKeyword("ALL"); err != nil {
+				return nil, err
+			}
+		}
+		r, err := p.setTerm()
+		if err != nil {
+			return nil, err
+		}
+		l = &setOp{op: op, distinct: distinct, l: l, r: r}
+	}
+}
+
+func (p *parser) setTerm() (setExpr, error) {
+	if p.acceptOp("(") {
+		q, err := p.query()
+		if err != nil {
+			return nil, err
+		}
+		return q, p.expectOp(")")
+	}
+	return p.selectStmt()
+}
+
+func (p *parser) selectStmt() (*selectStmt, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	s := &selectStmt{}
+	if p.acceptKeyword("DISTINCT") {
+		s.distinct = true
+	} else {
+		p.acceptKeyword("ALL")
+	}
+	for {
+		item, err := p.selectItem()
+		if err != nil {
+			return nil, err
+		}
+		s.items = append(s.items, item)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	var err error
+	if p.acceptKeyword("FROM") {
+		if s.from, err = p.from(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("WHERE") {
+		if s.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("GROUP", "BY") {
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			s.groupBy = append(s.groupBy, e)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("HAVING") {
+		if s.having, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (p *parser) selectItem() (selectItem, error) {
+	var item selectItem
+	if p.acceptOp("*") {
+		item.star = true
+	} else {
+		// A qualified star has the form a.b.*.
+		j := p.i
+		var qual []string
+		for {
+			t := p.toks[j]
+			if t.kind != tokIdent && t.kind != tokQuotedIdent {
+				break
+			}
+			if !isOp(p.toks[j+1], ".") {
+				break
+			}
+			qual = append(qual, t.text)
+			if p.toks[j+2].kind == tokOp && p.toks[j+2].text == "*" {
+				p.i = j + 3
+				item.star = true
+				item.qual = qual
+				break
+			}
+			j += 2
+		}
+	}
+	if item.star {
+		if p.acceptKeyword("EXCEPT") {
+			if err := p.expectOp("("); err != nil {
+				return item, err
+			}
+			for {
+				name, err := p.ident()
+				if err != nil {
+					return item, err
+				}
+				item.except = append(item.except, name)
+				if !p.acceptOp(",") {
+					break
+				}
+			}
+			if err := p.expectOp(")"); err != nil {
+				return item, err
+			}
+		}
+		return item, nil
+	}
+	e, err := p.expr()
+	if err != nil {
+		return item, err
+	}
+	item.expr = e
+	item.alias, err = p.alias()
+	return item, err
+}
+
+func (p *parser) from() (fromItem, error) {
+	l, err := p.fromItem()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		kind := ""
+		switch {
+		case p.acceptOp(","):
+			kind = "CROSS"
+		case p.acceptKeyword("CROSS", "JOIN"):
+			kind = "CROSS"
+		case p.acceptKeyword("JOIN"), p.acceptKeyword("INNER", "JOIN"):
+			kind = "INNER"
+		case p.acceptKeyword("LEFT", "JOIN"), p.acceptKeyword("LEFT", "OUTER", "JOIN"):
+			kind = "LEFT"
+		case p.acceptKeyword("RIGHT", "JOIN"), p.acceptKeyword("RIGHT", "OUTER", "JOIN"):
+			kind = "RIGHT"
+		case p.acceptKeyword("FULL", "JOIN"), p.acceptKeyword("FULL", "OUTER", "JOIN"):
+			kind = "FULL"
+		default:
+			return l, nil
+		}
+		r, err := p.fromItem()
+		if err != nil {
+			return nil, err
+		}
+		j := &join{kind: kind, l: l, r: r}
+		if kind != "CROSS" {
+			switch {
+			case p.acceptKeyword("ON"):
+				if j.on, err = p.expr(); err != nil {
+					return nil, err
+				}
+			case p.acceptKeyword("USING"):
+				if err := p.expectOp("("); err != nil {
+					return nil, err
+				}
+				for {
+					name, err := p.ident()
+					if err != nil {
+						return nil, err
+					}
+					j.using = append(j.using, name)
+					if !p.acceptOp(",") {
+						break
+					}
+				}
+				if err := p.expectOp(")"); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, p.errorf("expected ON or USING")
+			}
+		}
+		l = j
+	}
+}
+
+func (p *parser) fromItem() (fromItem, error) {
+	if p.acceptOp("(") {
+		q, err := p.query()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		alias, err := p.alias()
+		return &subqueryRef{query: q, alias: alias}, err
+	}
+	if p.acceptKeyword("UNNEST") {
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		u := &unnestRef{array: e}
+		if u.alias, err = p.alias(); err != nil {
+			return nil, err
+		}
+		if p.acceptKeyword("WITH", "OFFSET") {
+			u.withOffset = true
+			if u.offsetAlias, err = p.alias(); err != nil {
+				return nil, err
+			}
+		}
+		return u, nil
+	}
+	path, err := p.tablePath()
+	if err != nil {
+		return nil, err
+	}
+	alias, err := p.alias()
+	return &tableRef{path: path, alias: alias}, err
+}
+
+// tablePath parses a table name, whose parts may be quoted together, as in
+// `project.dataset.table`, and whose project may contain dashes.
+func (p *parser) tablePath() ([]string, error) {
+	var path []string
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokQuotedIdent:
+			p.i++
+			path = append(path, strings.Split(t.text, ".")...)
+		case t.kind == tokIdent:
+			p.i++
+			name := t.text
+			// Join adjacent tokens of a dashed name such as my-project.
+			for end := t.end; isOp(p.peek(), "-") && p.peek().pos == end && p.peekN(1).pos == end+1 && (p.peekN(1).kind == tokIdent || p.peekN(1).kind == tokNumber); {
+				part := p.peekN(1)
+				name += "-" + part.text
+				end = part.end
+				p.i += 2
+			}
+			path = append(path, name)
+		default:
+			return nil, p.errorf("expected table name, got %q", t.text)
+		}
+		if !p.acceptOp(".") {
+			return path, nil
+		}
+	}
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.orExpr()
+}
+
+func (p *parser) orExpr() (expr, error) {
+	l, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		r, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "OR", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) andExpr() (expr, error) {
+	l, err := p.notExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		r, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "AND", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) notExpr() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", x: x}, nil
+	}
+	return p.cmpExpr()
+}
+
+func (p *parser) cmpExpr() (expr, error) {
+	l, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokOp {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.i++
+			r, err := p.binary(0)
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			if op == "<>" {
+				op = "!="
+			}
+			return &binaryExpr{op: op, l: l, r: r}, nil
+		}
+	}
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		for _, what := range []string{"NULL", "TRUE", "FALSE"} {
+			if p.acceptKeyword(what) {
+				return &isExpr{x: l, not: not, what: what}, nil
+			}
+		}
+		return nil, p.errorf("expected NULL, TRUE or FALSE after IS")
+	}
+	not := false
+	if isKeyword(p.peek(), "NOT") && (isKeyword(p.peekN(1), "IN") || isKeyword(p.peekN(1), "BETWEEN") || isKeyword(p.peekN(1), "LIKE")) {
+		p.i++
+		not = true
+	}
+	switch {
+	case p.acceptKeyword("IN"):
+		in := &inExpr{x: l, not: not}
+		if p.acceptKeyword("UNNEST") {
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			if in.unnest, err = p.expr(); err != nil {
+				return nil, err
+			}
+			return in, p.expectOp(")")
+		}
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		if isKeyword(p.peek(), "SELECT") || isKeyword(p.peek(), "WITH") {
+			q, err := p.query()
+			if err != nil {
+				return nil, err
+			}
+			in.query = q
+			return in, p.expectOp(")")
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		return in, p.expectOp(")")
+	case p.acceptKeyword("BETWEEN"):
+		lo, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		hi, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{x: l, lo: lo, hi: hi, not: not}, nil
+	case p.acceptKeyword("LIKE"):
+		pat, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return &likeExpr{x: l, pattern: pat, not: not}, nil
+	}
+	return l, nil
+}
+
+// binaryLevels lists the binary operators from the loosest binding to the
+// tightest.
+var binaryLevels = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "||"},
+}
+
+func (p *parser) binary(level int) (expr, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	l, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp || !containsString(binaryLevels[level], t.text) {
+			return l, nil
+		}
+		p.i++
+		r, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: t.text, l: l, r: r}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unary() (expr, error) {
+	if t := p.peek(); t.kind == tokOp && (t.text == "-" || t.text == "+" || t.text == "~") {
+		p.i++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		// Fold negative numeric literals, so that the smallest INT64 parses.
+		if lit, ok := x.(*literal); ok && t.text == "-" && p.toks[p.i-1].kind == tokNumber {
+			switch v := lit.v.(type) {
+			case int64:
+				return &literal{v: -v, typ: lit.typ}, nil
+			case float64:
+				return &literal{v: -v, typ: lit.typ}, nil
+			case uint64Literal:
+				if v == 1<<63 {
+					return &literal{v: int64(-1 << 63), typ: bigquery.IntegerFieldType}, nil
+				}
+			}
+		}
+		if t.text == "+" {
+			return x, nil
+		}
+		return &unaryExpr{op: t.text, x: x}, nil
+	}
+	return p.postfix()
+}
+
+// uint64Literal is an integer literal that is too large for INT64 unless it
+// is negated.
+type uint64Literal uint64
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.acceptOp("."):
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if pe, ok := x.(*pathExpr); ok {
+				pe.parts = append(pe.parts, name)
+			} else {
+				x = &binaryExpr{op: ".", l: x, r: &literal{v: name, typ: bigquery.StringFieldType}}
+			}
+		case p.acceptOp("["):
+			idx, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			ie := &indexExpr{x: x, index: idx, mode: "OFFSET"}
+			if c, ok := idx.(*callExpr); ok && len(c.args) == 1 {
+				switch name := map[bool]string{true: "SAFE_", false: ""}[c.safe] + c.name; name {
+				case "OFFSET", "ORDINAL", "SAFE_OFFSET", "SAFE_ORDINAL":
+					ie.index, ie.mode = c.args[0], name
+				}
+			}
+			x = ie
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.i++
+		return numberLiteral(t.text)
+	case tokString:
+		p.i++
+		return &literal{v: t.text, typ: bigquery.StringFieldType}, nil
+	case tokBytes:
+		p.i++
+		return &literal{v: []byte(t.text), typ: bigquery.BytesFieldType}, nil
+	case tokParam:
+		p.i++
+		if t.text == "" {
+			p.positions++
+			return &paramRef{pos: p.positions - 1}, nil
+		}
+		return &paramRef{name: t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			p.i++
+			if isKeyword(p.peek(), "SELECT") || isKeyword(p.peek(), "WITH") {
+				return p.subquery("SCALAR")
+			}
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if p.acceptOp(",") {
+				// A parenthesized list is a struct.
+				s := &structExpr{fields: []expr{e}, names: []string{""}}
+				for {
+					f, err := p.expr()
+					if err != nil {
+						return nil, err
+					}
+					s.fields = append(s.fields, f)
+					s.names = append(s.names, "")
+					if !p.acceptOp(",") {
+						break
+					}
+				}
+				return s, p.expectOp(")")
+			}
+			return e, p.expectOp(")")
+		case "[":
+			p.i++
+			return p.arrayElems()
+		}
+	case tokQuotedIdent:
+		p.i++
+		return &pathExpr{parts: []string{t.text}}, nil
+	case tokIdent:
+		return p.identExpr()
+	}
+	return nil, p.errorf("unexpected %q", t.text)
+}
+
+func numberLiteral(text string) (expr, error) {
+	if strings.HasPrefix(strings.ToLower(text), "0x") {
+		v, err := strconv.ParseInt(text[2:], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer literal %s", text)
+		}
+		return &literal{v: v, typ: bigquery.IntegerFieldType}, nil
+	}
+	if !strings.ContainsAny(text, ".eE") {
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			if u, uerr := strconv.ParseUint(text, 10, 64); uerr == nil && u == 1<<63 {
+				return &literal{v: uint64Literal(u), typ: bigquery.IntegerFieldType}, nil
+			}
+			return nil, fmt.Errorf("invalid integer literal %s", text)
+		}
+		return &literal{v: v, typ: bigquery.IntegerFieldType}, nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid floating point literal %s", text)
+	}
+	return &literal{v: v, typ: bigquery.FloatFieldType}, nil
+}
+
+// arrayElems parses the elements of an array literal after its opening
+// bracket.
+// subquery parses a subquery after its opening parenthesis.
+func (p *parser) subquery(kind string) (expr, error) {
+	q, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	return &subqueryExpr{kind: kind, query: q}, p.expectOp(")")
+}
+
+func (p *parser) arrayElems() (expr, error) {
+	a := &arrayExpr{}
+	if p.acceptOp("]") {
+		return a, nil
+	}
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		a.elems = append(a.elems, e)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return a, p.expectOp("]")
+}
+
+func (p *parser) identExpr() (expr, error) {
+	t := p.peek()
+	upper := strings.ToUpper(t.text)
+	switch upper {
+	case "NULL":
+		p.i++
+		return &literal{typ: bigquery.IntegerFieldType}, nil
+	case "TRUE", "FALSE":
+		p.i++
+		return &literal{v: upper == "TRUE", typ: bigquery.BooleanFieldType}, nil
+	case "CASE":
+		p.i++
+		return p.caseExpr()
+	case "EXISTS":
+		p.i++
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		return p.subquery("EXISTS")
+	case "CAST", "SAFE_CAST":
+		if !isOp(p.peekN(1), "(") {
+			break
+		}
+		p.i += 2
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AS"); err != nil {
+			return nil, err
+		}
+		typ, err := p.typeName()
+		if err != nil {
+			return nil, err
+		}
+		return &castExpr{x: x, typ: typ, safe: upper == "SAFE_CAST"}, p.expectOp(")")
+	case "EXTRACT":
+		p.i++
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		part, err := p.datePart()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("FROM"); err != nil {
+			return nil, err
+		}
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &extractExpr{part: part, x: x}, p.expectOp(")")
+	case "INTERVAL":
+		p.i++
+		n, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		unit, err := p.datePart()
+		if err != nil {
+			return nil, err
+		}
+		return &intervalExpr{n: n, unit: unit}, nil
+	case "ARRAY":
+		p.i++
+		if p.acceptOp("<") {
+			if _, err := p.typeName(); err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(">"); err != nil {
+				return nil, err
+			}
+		}
+		if p.acceptOp("(") {
+			return p.subquery("ARRAY")
+		}
+		if err := p.expectOp("["); err != nil {
+			return nil, err
+		}
+		return p.arrayElems()
+	case "STRUCT":
+		p.i++
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		s := &structExpr{}
+		for !p.acceptOp(")") {
+			f, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			name, err := p.alias()
+			if err != nil {
+				return nil, err
+			}
+			s.fields = append(s.fields, f)
+			s.names = append(s.names, name)
+			if !p.acceptOp(",") {
+				if err := p.expectOp(")"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return s, nil
+	case "DATE", "DATETIME", "TIME", "TIMESTAMP", "NUMERIC", "BIGNUMERIC", "JSON", "DECIMAL", "BIGDECIMAL":
+		// A typed literal, as opposed to a function call.
+		if s := p.peekN(1); s.kind == tokString {
+			p.i += 2
+			return typedLiteral(upper, s.text)
+		}
+	case "RANGE":
+		if isOp(p.peekN(1), "<") {
+			p.i += 2
+			elem, err := p.typeName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(">"); err != nil {
+				return nil, err
+			}
+			s := p.next()
+			if s.kind != tokString {
+				return nil, p.errorf("expected RANGE literal")
+			}
+			fs := &bigquery.FieldSchema{Type: bigquery.RangeFieldType, RangeElementType: &bigquery.RangeElementType{Type: elem.Type}}
+			v, err := parseRange(s.text, fs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RANGE literal %q", s.text)
+			}
+			return &literal{v: v, typ: bigquery.RangeFieldType}, nil
+		}
+	}
+	// A function call, possibly prefixed by SAFE.
+	safe := false
+	if upper == "SAFE" && isOp(p.peekN(1), ".") && p.peekN(2).kind == tokIdent && isOp(p.peekN(3), "(") {
+		safe = true
+		p.i += 2
+		t = p.peek()
+		upper = strings.ToUpper(t.text)
+	}
+	if isOp(p.peekN(1), "(") && t.kind == tokIdent && (!reserved[upper] || upper == "IF" || upper == "LEFT" || upper == "RIGHT") {
+		p.i += 2
+		return p.call(upper, safe)
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	return &pathExpr{parts: []string{name}}, nil
+}
+
+func (p *parser) call(name string, safe bool) (expr, error) {
+	c := &callExpr{name: name, safe: safe}
+	if p.acceptOp("*") {
+		c.star = true
+		return c, p.expectOp(")")
+	}
+	if p.acceptOp(")") {
+		return c, p.checkOver()
+	}
+	if p.acceptKeyword("DISTINCT") {
+		c.distinct = true
+	}
+	for {
+		var arg expr
+		var err error
+		// Date parts are arguments of some date and time functions.
+		if t := p.peek(); t.kind == tokIdent && isDatePart(t.text) && (isOp(p.peekN(1), ")") || isOp(p.peekN(1), "(")) && len(c.args) > 0 && takesDatePart(name) {
+			part, err := p.datePart()
+			if err != nil {
+				return nil, err
+			}
+			arg = &literal{v: part, typ: bigquery.StringFieldType}
+		} else if arg, err = p.expr(); err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if p.acceptKeyword("IGNORE", "NULLS") {
+		c.ignoreNulls = true
+	} else {
+		p.acceptKeyword("RESPECT", "NULLS")
+	}
+	if p.acceptKeyword("ORDER", "BY") {
+		var err error
+		if c.orderBy, err = p.orderItems(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		var err error
+		if c.limit, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return c, p.checkOver()
+}
+
+func (p *parser) checkOver() error {
+	if isKeyword(p.peek(), "OVER") {
+		return p.errorf("analytic functions are not supported")
+	}
+	return nil
+}
+
+func takesDatePart(fn string) bool {
+	switch fn {
+	case "DATE_DIFF", "DATE_TRUNC", "DATETIME_DIFF", "DATETIME_TRUNC", "TIMESTAMP_DIFF", "TIMESTAMP_TRUNC", "TIME_DIFF", "TIME_TRUNC", "LAST_DAY":
+		return true
+	}
+	return false
+}
+
+var dateParts = map[string]bool{
+	"MICROSECOND": true, "MILLISECOND": true, "SECOND": true, "MINUTE": true, "HOUR": true,
+	"DAYOFWEEK": true, "DAY": true, "DAYOFYEAR": true, "WEEK": true, "ISOWEEK": true,
+	"MONTH": true, "QUARTER": true, "YEAR": true, "ISOYEAR": true, "DATE": true, "TIME": true, "DATETIME": true,
+}
+
+func isDatePart(s string) bool {
+	return dateParts[strings.ToUpper(s)]
+}
+
+// datePart parses a date part, such as DAY or WEEK(MONDAY).
+func (p *parser) datePart() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent || !isDatePart(t.text) {
+		return "", p.errorf("expected date part, got %q", t.text)
+	}
+	part := strings.ToUpper(t.text)
+	if part == "WEEK" && p.acceptOp("(") {
+		day := p.next()
+		if err := p.expectOp(")"); err != nil {
+			return "", err
+		}
+		part += "(" + strings.ToUpper(day.text) + ")"
+	}
+	return part, nil
+}
+
+func (p *parser) caseExpr() (expr, error) {
+	c := &caseExpr{}
+	var err error
+	if !isKeyword(p.peek(), "WHEN") {
+		if c.operand, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptKeyword("WHEN") {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("THEN"); err != nil {
+			return nil, err
+		}
+		res, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.whens = append(c.whens, whenClause{cond: cond, result: res})
+	}
+	if len(c.whens) == 0 {
+		return nil, p.errorf("expected WHEN")
+	}
+	if p.acceptKeyword("ELSE") {
+		if c.els, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	return c, p.expectKeyword("END")
+}
+
+// typeName parses a type in a CAST or a RANGE or ARRAY literal.
+func (p *parser) typeName() (*bigquery.FieldSchema, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, p.errorf("expected type name, got %q", t.text)
+	}
+	upper := strings.ToUpper(t.text)
+	if upper == "ARRAY" {
+		if err := p.expectOp("<"); err != nil {
+			return nil, err
+		}
+		elem, err := p.typeName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(">"); err != nil {
+			return nil, err
+		}
+		elem.Repeated = true
+		return elem, nil
+	}
+	typ, err := typeFromName(upper)
+	if err != nil {
+		return nil, err
+	}
+	// Parameterized types, like STRING(10), are accepted but not enforced.
+	if p.acceptOp("(") {
+		for !p.acceptOp(")") {
+			if p.next().kind == tokEOF {
+				return nil, p.errorf("unterminated type parameters")
+			}
+		}
+	}
+	return &bigquery.FieldSchema{Type: typ}, nil
+}
+
+func typedLiteral(typ, s string) (expr, error) {
+	ft, err := typeFromName(typ)
+	if err != nil {
+		return nil, err
+	}
+	var v bigquery.Value
+	switch ft {
+	case bigquery.TimestampFieldType:
+		v, err = parseTimestamp(s)
+	case bigquery.DateFieldType:
+		v, err = civil.ParseDate(s)
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+		if !ok {
+			err = fmt.Errorf("invalid %s literal %q", typ, s)
+		}
+		v = r
+	default:
+		v, err = parseText(s, &bigquery.FieldSchema{Type: ft})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s literal %q", typ, s)
+	}
+	return &literal{v: v, typ: ft}, nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	testNow = time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	peopleSchema = bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "age", Type: bigquery.IntegerFieldType},
+		{Name: "city", Type: bigquery.StringFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "street", Type: bigquery.StringFieldType},
+			{Name: "zip", Type: bigquery.IntegerFieldType},
+		}},
+	}
+	peopleRows = [][]bigquery.Value{
+		{"alice", int64(30), "paris", []bigquery.Value{"a", "b"}, []bigquery.Value{"rue 1", int64(75001)}},
+		{"bob", int64(25), "london", []bigquery.Value{}, nil},
+		{"carol", int64(35), "paris", []bigquery.Value{"c"}, []bigquery.Value{"rue 2", int64(75002)}},
+		{"dave", nil, "berlin", []bigquery.Value{}, nil},
+	}
+	citySchema = bigquery.Schema{
+		{Name: "city", Type: bigquery.StringFieldType},
+		{Name: "country", Type: bigquery.StringFieldType},
+	}
+	cityRows = [][]bigquery.Value{
+		{"paris", "FR"},
+		{"london", "UK"},
+		{"rome", "IT"},
+	}
+)
+
+func testQuery(sql string, params ...QueryParam) (*QueryResult, error) {
+	return NewEngine().Query(&QueryRequest{
+		SQL:              sql,
+		DefaultProjectID: "p",
+		DefaultDatasetID: "d",
+		Params:           params,
+		Now:              testNow,
+		Table: func(projectID, datasetID, tableID string) (bigquery.Schema, [][]bigquery.Value, error) {
+			if projectID != "p" || datasetID != "d" {
+				return nil, nil, fmt.Errorf("not found: %s.%s.%s", projectID, datasetID, tableID)
+			}
+			switch tableID {
+			case "people":
+				return peopleSchema, peopleRows, nil
+			case "cities":
+				return citySchema, cityRows, nil
+			}
+			return nil, nil, fmt.Errorf("not found: %s", tableID)
+		},
+	})
+}
+
+func rat(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+func TestQueryRows(t *testing.T) {
+	for _, test := range []struct {
+		sql  string
+		want [][]bigquery.Value
+	}{
+		{"SELECT 1 + 2, 'a' || 'b', 7 / 2, DIV(7, 2), MOD(-5, 3)",
+			[][]bigquery.Value{{int64(3), "ab", 3.5, int64(3), int64(-2)}}},
+		{"SELECT name FROM people WHERE age > 26 ORDER BY name DESC",
+			[][]bigquery.Value{{"carol"}, {"alice"}}},
+		{"SELECT name FROM people WHERE age IS NULL",
+			[][]bigquery.Value{{"dave"}}},
+		{"SELECT city, COUNT(*) AS n, SUM(age), AVG(age), MAX(name) FROM people GROUP BY city ORDER BY n DESC, city",
+			[][]bigquery.Value{
+				{"paris", int64(2), int64(65), 32.5, "carol"},
+				{"berlin", int64(1), nil, nil, "dave"},
+				{"london", int64(1), int64(25), 25.0, "bob"},
+			}},
+		{"SELECT city FROM people GROUP BY 1 HAVING COUNT(*) > 1",
+			[][]bigquery.Value{{"paris"}}},
+		{"SELECT COUNT(DISTINCT city), COUNTIF(age > 26), STRING_AGG(name, ',' ORDER BY name DESC LIMIT 2) FROM people",
+			[][]bigquery.Value{{int64(3), int64(2), "dave,carol"}}},
+		{"SELECT ARRAY_AGG(name ORDER BY age DESC) FROM people WHERE age IS NOT NULL",
+			[][]bigquery.Value{{[]bigquery.Value{"carol", "alice", "bob"}}}},
+		{"SELECT p.name, c.country FROM people AS p JOIN cities AS c ON p.city = c.city ORDER BY 1",
+			[][]bigquery.Value{{"alice", "FR"}, {"bob", "UK"}, {"carol", "FR"}}},
+		{"SELECT name, country FROM people LEFT JOIN cities USING (city) ORDER BY name",
+			[][]bigquery.Value{{"alice", "FR"}, {"bob", "UK"}, {"carol", "FR"}, {"dave", nil}}},
+		{"SELECT c.city FROM people p RIGHT JOIN cities c ON p.city = c.city WHERE p.name IS NULL",
+			[][]bigquery.Value{{"rome"}}},
+		{"SELECT name, tag FROM people, UNNEST(tags) AS tag ORDER BY name, tag",
+			[][]bigquery.Value{{"alice", "a"}, {"alice", "b"}, {"carol", "c"}}},
+		{"SELECT x, i FROM UNNEST([10, 20, 30]) AS x WITH OFFSET AS i WHERE x > 10",
+			[][]bigquery.Value{{int64(20), int64(1)}, {int64(30), int64(2)}}},
+		{"SELECT address.zip FROM people WHERE address.street = 'rue 2'",
+			[][]bigquery.Value{{int64(75002)}}},
+		{"SELECT * EXCEPT (tags, address) FROM people WHERE name = 'bob'",
+			[][]bigquery.Value{{"bob", int64(25), "london"}}},
+		{"WITH t AS (SELECT city, COUNT(*) AS n FROM people GROUP BY city) SELECT MAX(n) FROM t",
+			[][]bigquery.Value{{int64(2)}}},
+		{"SELECT name FROM (SELECT * FROM people ORDER BY age LIMIT 2 OFFSET 1)",
+			[][]bigquery.Value{{"bob"}, {"alice"}}},
+		{"SELECT 1 UNION ALL SELECT 2 UNION DISTINCT SELECT 1 ORDER BY 1",
+			[][]bigquery.Value{{int64(1)}, {int64(2)}}},
+		{"SELECT city FROM cities EXCEPT DISTINCT SELECT city FROM people",
+			[][]bigquery.Value{{"rome"}}},
+		{"SELECT CASE WHEN age < 30 THEN 'young' WHEN age >= 30 THEN 'old' ELSE 'unknown' END FROM people ORDER BY name",
+			[][]bigquery.Value{{"old"}, {"young"}, {"old"}, {"unknown"}}},
+		{"SELECT IF(age IS NULL, -1, age), COALESCE(age, 0), IFNULL(NULL, 'x') FROM people WHERE name = 'dave'",
+			[][]bigquery.Value{{int64(-1), int64(0), "x"}}},
+		{"SELECT name FROM people WHERE name LIKE '%a%' AND city IN ('paris', 'berlin') AND age BETWEEN 20 AND 40",
+			[][]bigquery.Value{{"alice"}, {"carol"}}},
+		{"SELECT p.name, (SELECT country FROM cities AS c WHERE c.city = p.city) FROM people AS p WHERE EXISTS(SELECT 1 FROM UNNEST(p.tags)) ORDER BY 1",
+			[][]bigquery.Value{{"alice", "FR"}, {"carol", "FR"}}},
+		{"SELECT city, ARRAY(SELECT name FROM people WHERE people.city = cities.city ORDER BY name) FROM cities ORDER BY city",
+			[][]bigquery.Value{{"london", []bigquery.Value{"bob"}}, {"paris", []bigquery.Value{"alice", "carol"}}, {"rome", []bigquery.Value{}}}},
+		{"SELECT UPPER('abc'), LENGTH('héllo'), SUBSTR('hello', 2, 3), REPLACE('aXbX', 'X', '-'), SPLIT('a,b')",
+			[][]bigquery.Value{{"ABC", int64(5), "ell", "a-b-", []bigquery.Value{"a", "b"}}}},
+		{"SELECT REGEXP_EXTRACT('foo123', r'\\d+'), REGEXP_REPLACE('a1b2', r'(\\d)', r'<\\1>')",
+			[][]bigquery.Value{{"123", "a<1>b<2>"}}},
+		{"SELECT CAST('12' AS INT64) + 1, SAFE_CAST('x' AS INT64), CAST(2.5 AS INT64), CAST(1 AS STRING), CAST('1.5' AS NUMERIC)",
+			[][]bigquery.Value{{int64(13), nil, int64(3), "1", rat("1.5")}}},
+		{"SELECT DATE '2024-01-31' + INTERVAL 1 MONTH, DATE_ADD(DATE '2024-01-31', INTERVAL 1 MONTH), DATE_DIFF(DATE '2024-03-01', DATE '2024-02-01', DAY)",
+			[][]bigquery.Value{{civil.DateTime{Date: civil.Date{Year: 2024, Month: 2, Day: 29}}, civil.Date{Year: 2024, Month: 2, Day: 29}, int64(29)}}},
+		{"SELECT EXTRACT(YEAR FROM DATE '2024-05-06'), DATE_TRUNC(DATE '2024-05-06', MONTH), FORMAT_DATE('%Y/%m/%d', DATE '2024-05-06')",
+			[][]bigquery.Value{{int64(2024), civil.Date{Year: 2024, Month: 5, Day: 1}, "2024/05/06"}}},
+		{"SELECT CURRENT_DATE(), TIMESTAMP_ADD(CURRENT_TIMESTAMP(), INTERVAL 1 HOUR), UNIX_SECONDS(TIMESTAMP '1970-01-01 00:01:00 UTC')",
+			[][]bigquery.Value{{civil.DateOf(testNow), testNow.Add(time.Hour), int64(60)}}},
+		{"SELECT ARRAY_LENGTH([1, 2]), ARRAY_TO_STRING(['a', 'b'], '-'), GENERATE_ARRAY(1, 5, 2), [1, 2, 3][OFFSET(1)], [1, 2][SAFE_ORDINAL(3)]",
+			[][]bigquery.Value{{int64(2), "a-b", []bigquery.Value{int64(1), int64(3), int64(5)}, int64(2), nil}}},
+		{"SELECT STRUCT(1 AS a, 'x' AS b).b, (SELECT COUNT(*) FROM cities), EXISTS(SELECT 1 FROM cities WHERE city = 'rome')",
+			[][]bigquery.Value{{"x", int64(3), true}}},
+		{"SELECT name FROM people WHERE city IN (SELECT city FROM cities WHERE country = 'FR') ORDER BY name",
+			[][]bigquery.Value{{"alice"}, {"carol"}}},
+		{`SELECT JSON_VALUE('{"a": {"b": 1}}', '$.a.b'), TO_JSON_STRING(STRUCT(1 AS x, [2] AS y))`,
+			[][]bigquery.Value{{"1", `{"x":1,"y":[2]}`}}},
+		{"SELECT NULL AND FALSE, NULL OR TRUE, NOT NULL, 1 = NULL",
+			[][]bigquery.Value{{false, true, nil, nil}}},
+		{"SELECT DISTINCT city FROM people ORDER BY city LIMIT 2",
+			[][]bigquery.Value{{"berlin"}, {"london"}}},
+		{"SELECT cities.country FROM `p.d.cities` WHERE city = 'rome'",
+			[][]bigquery.Value{{"IT"}}},
+	} {
+		res, err := testQuery(test.sql)
+		if err != nil {
+			t.Errorf("%s: %v", test.sql, err)
+			continue
+		}
+		if diff := cmp.Diff(test.want, res.Rows, cmp.Comparer(func(a, b *big.Rat) bool { return a.Cmp(b) == 0 })); diff != "" {
+			t.Errorf("%s: rows differ (-want +got):\n%s", test.sql, diff)
+		}
+	}
+}
+
+func TestQuerySchema(t *testing.T) {
+	res, err := testQuery("SELECT name, age * 1.5 AS score, [age] AS ages, STRUCT(city, age) AS s, COUNT(*) OVER () FROM people")
+	if err == nil {
+		t.Fatalf("analytic functions: got schema %v, want error", res.Schema)
+	}
+	res, err = testQuery("SELECT name, age * 1.5 AS score, ARRAY(SELECT x FROM UNNEST(tags) AS x) AS t, STRUCT(city, age) AS s, 1 FROM people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "score", Type: bigquery.FloatFieldType},
+		{Name: "t", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "s", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "city", Type: bigquery.StringFieldType},
+			{Name: "age", Type: bigquery.IntegerFieldType},
+		}},
+		{Name: "f0_", Type: bigquery.IntegerFieldType},
+	}
+	if diff := cmp.Diff(want, res.Schema); diff != "" {
+		t.Errorf("schema differs (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	res, err := testQuery("SELECT name FROM people WHERE age > @min AND city = ?",
+		QueryParam{Name: "min", Type: &bigquery.FieldSchema{Type: bigquery.IntegerFieldType}, Value: int64(26)},
+		QueryParam{Type: &bigquery.FieldSchema{Type: bigquery.StringFieldType}, Value: "paris"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([][]bigquery.Value{{"alice"}, {"carol"}}, res.Rows); diff != "" {
+		t.Errorf("rows differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, test := range []struct {
+		sql, want string
+	}{
+		{"SELECT nope FROM people", "Unrecognized name: nope"},
+		{"SELECT * FROM missing", "not found"},
+		{"SELECT 1 +", "Syntax error"},
+		{"SELECT name, COUNT(*) FROM people", "neither grouped nor aggregated"},
+		{"SELECT 1 / 0", "division by zero"},
+		{"SELECT CAST('x' AS INT64)", "bad int64 value"},
+		{"SELECT 'a' + 1", "No matching signature"},
+		{"SELECT city FROM people, cities", "ambiguous"},
+		{"SELECT [1, NULL]", "NULL element"},
+		{"SELECT @x", "parameter"},
+	} {
+		_, err := testQuery(test.sql)
+		if err == nil || !strings.Contains(strings.ToLower(err.Error()), strings.ToLower(test.want)) {
+			t.Errorf("%s: got error %v, want one containing %q", test.sql, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,781 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// readBatchSize is the number of rows in each ReadRows response.
+const readBatchSize = 1000
+
+// defaultStreamID is the ID of the default write stream of every table.
+const defaultStreamID = "_default"
+
+// grpcError converts an error of the REST implementation to a gRPC status.
+func grpcError(err error) error {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	code := codes.InvalidArgument
+	switch ae.code {
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	}
+	return status.Error(code, ae.message)
+}
+
+// A readStream is the single stream of a read session. It holds a snapshot
+// of the rows at the time the session was created.
+type readStream struct {
+	schema      bigquery.Schema
+	arrowSchema *arrow.Schema
+	rows        [][]bigquery.Value
+}
+
+type readServer struct {
+	storagepb.UnimplementedBigQueryReadServer
+	s *Server
+}
+
+func (r *readServer) CreateReadSession(_ context.Context, req *storagepb.CreateReadSessionRequest) (*storagepb.ReadSession, error) {
+	rs := req.GetReadSession()
+	if rs == nil {
+		return nil, status.Error(codes.InvalidArgument, "read session is required")
+	}
+	if rs.DataFormat != storagepb.DataFormat_ARROW {
+		return nil, status.Errorf(codes.Unimplemented, "data format %s is not supported", rs.DataFormat)
+	}
+	if rs.GetReadOptions().GetRowRestriction() != "" {
+		return nil, status.Error(codes.Unimplemented, "row restrictions are not supported")
+	}
+	projectID, datasetID, tableID, ok := splitTablePath(rs.Table)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid table %q", rs.Table)
+	}
+	s := r.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookupTable(projectID, datasetID, tableID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if t.isView() {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot read view %s", tableName(t.md.TableReference))
+	}
+	schema, rows, err := selectFields(t.schema, t.rows, strings.Join(rs.GetReadOptions().GetSelectedFields(), ","))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	as := arrowSchema(schema)
+	serialized, err := serializeArrowSchema(as)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	name := fmt.Sprintf("%s/locations/%s/sessions/%s", req.Parent, orDefault(t.md.Location, "US"), s.newID("session"))
+	stream := &storagepb.ReadStream{Name: name + "/streams/0"}
+	s.readStreams[stream.Name] = &readStream{schema: schema, arrowSchema: as, rows: slices.Clone(rows)}
+	return &storagepb.ReadSession{
+		Name:       name,
+		ExpireTime: timestamppb.New(s.now().Add(6 * time.Hour)),
+		DataFormat: storagepb.DataFormat_ARROW,
+		Schema: &storagepb.ReadSession_ArrowSchema{
+			ArrowSchema: &storagepb.ArrowSchema{SerializedSchema: serialized},
+		},
+		Table:             rs.Table,
+		ReadOptions:       rs.ReadOptions,
+		Streams:           []*storagepb.ReadStream{stream},
+		EstimatedRowCount: int64(len(rows)),
+	}, nil
+}
+
+func (r *readServer) ReadRows(req *storagepb.ReadRowsRequest, srv storagepb.BigQueryRead_ReadRowsServer) error {
+	r.s.mu.Lock()
+	st, ok := r.s.readStreams[req.ReadStream]
+	r.s.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "read stream %s not found", req.ReadStream)
+	}
+	if req.Offset < 0 || req.Offset > int64(len(st.rows)) {
+		return status.Errorf(codes.OutOfRange, "offset %d is out of range", req.Offset)
+	}
+	for start := int(req.Offset); start < len(st.rows); start += readBatchSize {
+		rows := st.rows[start:min(start+readBatchSize, len(st.rows))]
+		batch, err := serializeArrowRows(st.arrowSchema, st.schema, rows)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		err = srv.Send(&storagepb.ReadRowsResponse{
+			RowCount: int64(len(rows)),
+			Rows: &storagepb.ReadRowsResponse_ArrowRecordBatch{
+				ArrowRecordBatch: &storagepb.ArrowRecordBatch{SerializedRecordBatch: batch, RowCount: int64(len(rows))},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A writeStream is an explicitly created write stream.
+type writeStream struct {
+	md        *storagepb.WriteStream
+	finalized bool
+	next      int64        // The offset of the next appended row.
+	flushed   int64        // The number of rows of a BUFFERED stream that have been flushed.
+	pending   []pendingRow // Rows that are not yet in the table.
+}
+
+// A pendingRow is a row that was decoded for a table with the schema, which
+// may change before it is committed.
+type pendingRow struct {
+	schema bigquery.Schema
+	row    []bigquery.Value
+}
+
+type writeServer struct {
+	storagepb.UnimplementedBigQueryWriteServer
+	s *Server
+}
+
+// splitStreamPath splits a write stream path of the form
+// projects/p/datasets/d/tables/t/streams/s.
+func splitStreamPath(path string) (projectID, datasetID, tableID, streamID string, ok bool) {
+	projectID, datasetID, tableID, ok = splitTablePath(path)
+	parts := strings.Split(path, "/")
+	if !ok || len(parts) != 8 || parts[6] != "streams" {
+		return "", "", "", "", false
+	}
+	return projectID, datasetID, tableID, parts[7], true
+}
+
+func (w *writeServer) CreateWriteStream(_ context.Context, req *storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error) {
+	typ := req.GetWriteStream().GetType()
+	switch typ {
+	case storagepb.WriteStream_COMMITTED, storagepb.WriteStream_PENDING, storagepb.WriteStream_BUFFERED:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid write stream type %s", typ)
+	}
+	projectID, datasetID, tableID, ok := splitTablePath(req.Parent)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid table %q", req.Parent)
+	}
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookupTable(projectID, datasetID, tableID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	st := &writeStream{
+		md: &storagepb.WriteStream{
+			Name:       req.Parent + "/streams/" + s.newID("stream"),
+			Type:       typ,
+			CreateTime: timestamppb.New(s.now()),
+			WriteMode:  storagepb.WriteStream_INSERT,
+			Location:   t.md.Location,
+		},
+	}
+	s.writeStreams[st.md.Name] = st
+	return streamView(st.md, t, storagepb.WriteStreamView_FULL)
+}
+
+// streamView returns a copy of the stream's metadata, including the table
+// schema for the FULL view.
+func streamView(md *storagepb.WriteStream, t *table, view storagepb.WriteStreamView) (*storagepb.WriteStream, error) {
+	md = proto.Clone(md).(*storagepb.WriteStream)
+	if view == storagepb.WriteStreamView_FULL {
+		ts, err := adapt.BQSchemaToStorageTableSchema(t.schema)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		md.TableSchema = ts
+	}
+	return md, nil
+}
+
+func (w *writeServer) GetWriteStream(_ context.Context, req *storagepb.GetWriteStreamRequest) (*storagepb.WriteStream, error) {
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, st, err := s.lookupWriteStream(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	md := &storagepb.WriteStream{
+		Name:       req.Name,
+		Type:       storagepb.WriteStream_COMMITTED,
+		CreateTime: timestamppb.New(time.UnixMilli(t.md.CreationTime)),
+		WriteMode:  storagepb.WriteStream_INSERT,
+		Location:   t.md.Location,
+	}
+	if st != nil {
+		md = st.md
+	}
+	return streamView(md, t, req.View)
+}
+
+// lookupWriteStream returns the table of a write stream, and the stream
+// unless it is the default stream. s.mu must be held.
+func (s *Server) lookupWriteStream(name string) (*table, *writeStream, error) {
+	projectID, datasetID, tableID, streamID, ok := splitStreamPath(name)
+	if !ok {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid write stream %q", name)
+	}
+	t, err := s.lookupTable(projectID, datasetID, tableID)
+	if err != nil {
+		return nil, nil, grpcError(err)
+	}
+	if t.isView() {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "cannot write to view %s", tableName(t.md.TableReference))
+	}
+	if streamID == defaultStreamID {
+		return t, nil, nil
+	}
+	st, ok := s.writeStreams[name]
+	if !ok {
+		return nil, nil, status.Errorf(codes.NotFound, "write stream %s not found", name)
+	}
+	return t, st, nil
+}
+
+// appendToTable adds rows, decoded for the schema, to the table. s.mu must
+// be held.
+func (s *Server) appendToTable(t *table, rows []pendingRow) error {
+	for _, r := range rows {
+		idx, err := evolveSchema(r.schema, t.schema)
+		if err != nil {
+			return fmt.Errorf("the table schema has changed incompatibly: %v", err)
+		}
+		t.rows = append(t.rows, evolveRow(r.row, r.schema, t.schema, idx))
+	}
+	t.touch(s.nowMillis())
+	return nil
+}
+
+// An appendConn holds the state of an AppendRows connection, which may
+// write to several streams.
+type appendConn struct {
+	s            *Server
+	descriptors  map[string]protoreflect.MessageDescriptor
+	arrowSchemas map[string][]byte
+	// reported holds the table schema last reported to each stream as an
+	// updated schema.
+	reported map[string]string
+}
+
+func (w *writeServer) AppendRows(srv storagepb.BigQueryWrite_AppendRowsServer) error {
+	c := &appendConn{
+		s:            w.s,
+		descriptors:  map[string]protoreflect.MessageDescriptor{},
+		arrowSchemas: map[string][]byte{},
+		reported:     map[string]string{},
+	}
+	var name string
+	for {
+		req, err := srv.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.WriteStream != "" {
+			name = req.WriteStream
+		}
+		if name == "" {
+			return status.Error(codes.InvalidArgument, "write stream is required")
+		}
+		resp := c.append(name, req)
+		resp.WriteStream = name
+		if err := srv.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func errorResponse(code codes.Code, format string, args ...any) *storagepb.AppendRowsResponse {
+	return &storagepb.AppendRowsResponse{
+		Response: &storagepb.AppendRowsResponse_Error{Error: status.Newf(code, format, args...).Proto()},
+	}
+}
+
+func (c *appendConn) append(name string, req *storagepb.AppendRowsRequest) *storagepb.AppendRowsResponse {
+	// Remember the writer schema, which is only sent when it changes.
+	var decode func(bigquery.Schema) ([][]bigquery.Value, []*storagepb.RowError, error)
+	var columns []string
+	switch rows := req.Rows.(type) {
+	case *storagepb.AppendRowsRequest_ProtoRows:
+		if ws := rows.ProtoRows.GetWriterSchema(); ws != nil {
+			md, err := messageDescriptor(ws.GetProtoDescriptor())
+			if err != nil {
+				return errorResponse(codes.InvalidArgument, "invalid proto descriptor: %v", err)
+			}
+			c.descriptors[name] = md
+		}
+		md := c.descriptors[name]
+		if md == nil {
+			return errorResponse(codes.InvalidArgument, "the first request for a stream must have a writer schema")
+		}
+		for i := 0; i < md.Fields().Len(); i++ {
+			columns = append(columns, columnName(md.Fields().Get(i)))
+		}
+		serialized := rows.ProtoRows.GetRows().GetSerializedRows()
+		decode = func(schema bigquery.Schema) ([][]bigquery.Value, []*storagepb.RowError, error) {
+			var out [][]bigquery.Value
+			var rowErrs []*storagepb.RowError
+			for i, b := range serialized {
+				row, err := decodeProtoRow(b, md, schema)
+				if err != nil {
+					rowErrs = append(rowErrs, &storagepb.RowError{Index: int64(i), Code: storagepb.RowError_FIELDS_ERROR, Message: err.Error()})
+				}
+				out = append(out, row)
+			}
+			return out, rowErrs, nil
+		}
+	case *storagepb.AppendRowsRequest_ArrowRows:
+		if ws := rows.ArrowRows.GetWriterSchema(); ws != nil {
+			c.arrowSchemas[name] = ws.GetSerializedSchema()
+		}
+		as := c.arrowSchemas[name]
+		if as == nil {
+			return errorResponse(codes.InvalidArgument, "the first request for a stream must have a writer schema")
+		}
+		batch := rows.ArrowRows.GetRows().GetSerializedRecordBatch()
+		decode = func(schema bigquery.Schema) ([][]bigquery.Value, []*storagepb.RowError, error) {
+			out, err := decodeArrowRows(as, batch, schema)
+			return out, nil, err
+		}
+		if fields, err := arrowFieldNames(as); err == nil {
+			columns = fields
+		}
+	default:
+		return errorResponse(codes.InvalidArgument, "request has no rows")
+	}
+
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, st, err := s.lookupWriteStream(name)
+	if err != nil {
+		return &storagepb.AppendRowsResponse{Response: &storagepb.AppendRowsResponse_Error{Error: status.Convert(err).Proto()}}
+	}
+	rows, rowErrs, err := decode(t.schema)
+	if err != nil {
+		return errorResponse(codes.InvalidArgument, "%v", err)
+	}
+	if len(rowErrs) > 0 {
+		resp := errorResponse(codes.InvalidArgument, "%d rows could not be decoded; see row errors", len(rowErrs))
+		resp.RowErrors = rowErrs
+		return resp
+	}
+	pending := make([]pendingRow, len(rows))
+	for i, row := range rows {
+		pending[i] = pendingRow{schema: t.schema, row: row}
+	}
+	resp := &storagepb.AppendRowsResponse{}
+	if st == nil {
+		if req.Offset != nil {
+			return errorResponse(codes.InvalidArgument, "offsets are not supported on the default stream")
+		}
+		if err := s.appendToTable(t, pending); err != nil {
+			return errorResponse(codes.FailedPrecondition, "%v", err)
+		}
+		resp.Response = &storagepb.AppendRowsResponse_AppendResult_{AppendResult: &storagepb.AppendRowsResponse_AppendResult{}}
+	} else {
+		if st.finalized {
+			return errorResponse(codes.InvalidArgument, "write stream %s is finalized", name)
+		}
+		if off := req.GetOffset(); off != nil {
+			switch {
+			case off.Value > st.next:
+				return errorResponse(codes.OutOfRange, "offset %d is beyond the end of the stream at %d", off.Value, st.next)
+			case off.Value < st.next:
+				return errorResponse(codes.AlreadyExists, "offset %d has already been written; the stream is at %d", off.Value, st.next)
+			}
+		}
+		offset := st.next
+		if st.md.Type == storagepb.WriteStream_COMMITTED {
+			if err := s.appendToTable(t, pending); err != nil {
+				return errorResponse(codes.FailedPrecondition, "%v", err)
+			}
+		} else {
+			st.pending = append(st.pending, pending...)
+		}
+		st.next += int64(len(rows))
+		resp.Response = &storagepb.AppendRowsResponse_AppendResult_{
+			AppendResult: &storagepb.AppendRowsResponse_AppendResult{Offset: wrapperspb.Int64(offset)},
+		}
+	}
+	c.reportSchema(name, t, columns, resp)
+	return resp
+}
+
+// reportSchema adds the table schema to the response as an updated schema if
+// the table has columns that the writer schema lacks, and it hasn't already
+// been reported on the connection.
+func (c *appendConn) reportSchema(name string, t *table, columns []string, resp *storagepb.AppendRowsResponse) {
+	missing := false
+	for _, fs := range t.schema {
+		if !slices.ContainsFunc(columns, func(c string) bool { return strings.EqualFold(c, fs.Name) }) {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return
+	}
+	b, err := t.schema.ToJSONFields()
+	if err != nil || c.reported[name] == string(b) {
+		return
+	}
+	ts, err := adapt.BQSchemaToStorageTableSchema(t.schema)
+	if err != nil {
+		return
+	}
+	c.reported[name] = string(b)
+	resp.UpdatedSchema = ts
+}
+
+func (w *writeServer) FinalizeWriteStream(_ context.Context, req *storagepb.FinalizeWriteStreamRequest) (*storagepb.FinalizeWriteStreamResponse, error) {
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, st, err := s.lookupWriteStream(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, status.Error(codes.InvalidArgument, "the default stream cannot be finalized")
+	}
+	st.finalized = true
+	return &storagepb.FinalizeWriteStreamResponse{RowCount: st.next}, nil
+}
+
+func (w *writeServer) BatchCommitWriteStreams(_ context.Context, req *storagepb.BatchCommitWriteStreamsRequest) (*storagepb.BatchCommitWriteStreamsResponse, error) {
+	projectID, datasetID, tableID, ok := splitTablePath(req.Parent)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid table %q", req.Parent)
+	}
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookupTable(projectID, datasetID, tableID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &storagepb.BatchCommitWriteStreamsResponse{}
+	var streams []*writeStream
+	for _, name := range req.WriteStreams {
+		st, ok := s.writeStreams[name]
+		storageErr := func(code storagepb.StorageError_StorageErrorCode, msg string) {
+			resp.StreamErrors = append(resp.StreamErrors, &storagepb.StorageError{Code: code, Entity: name, ErrorMessage: msg})
+		}
+		switch {
+		case !ok || !strings.HasPrefix(name, req.Parent+"/"):
+			storageErr(storagepb.StorageError_STREAM_NOT_FOUND, "stream not found")
+		case st.md.Type != storagepb.WriteStream_PENDING:
+			storageErr(storagepb.StorageError_INVALID_STREAM_TYPE, "only PENDING streams can be committed")
+		case st.md.CommitTime != nil:
+			storageErr(storagepb.StorageError_STREAM_ALREADY_COMMITTED, "stream is already committed")
+		case !st.finalized:
+			storageErr(storagepb.StorageError_INVALID_STREAM_STATE, "stream is not finalized")
+		default:
+			streams = append(streams, st)
+		}
+	}
+	if len(resp.StreamErrors) > 0 {
+		return resp, nil
+	}
+	resp.CommitTime = timestamppb.New(s.now())
+	for _, st := range streams {
+		if err := s.appendToTable(t, st.pending); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		st.pending = nil
+		st.md.CommitTime = resp.CommitTime
+	}
+	return resp, nil
+}
+
+func (w *writeServer) FlushRows(_ context.Context, req *storagepb.FlushRowsRequest) (*storagepb.FlushRowsResponse, error) {
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, st, err := s.lookupWriteStream(req.WriteStream)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil || st.md.Type != storagepb.WriteStream_BUFFERED {
+		return nil, status.Error(codes.InvalidArgument, "only BUFFERED streams can be flushed")
+	}
+	offset := req.GetOffset().GetValue()
+	if offset >= st.next {
+		return nil, status.Errorf(codes.OutOfRange, "offset %d is beyond the end of the stream at %d", offset, st.next)
+	}
+	if n := offset + 1 - st.flushed; n > 0 {
+		if err := s.appendToTable(t, st.pending[:n]); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		st.pending = st.pending[n:]
+		st.flushed = offset + 1
+	}
+	return &storagepb.FlushRowsResponse{Offset: offset}, nil
+}
+
+// messageDescriptor builds the descriptor of the self-contained message
+// description sent as a writer schema.
+func messageDescriptor(dp *descriptorpb.DescriptorProto) (protoreflect.MessageDescriptor, error) {
+	if dp == nil {
+		return nil, errors.New("no descriptor")
+	}
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("bqtest_writer_schema.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{dp},
+	}
+	fd, err := protodesc.NewFile(fdp, new(protoregistry.Files))
+	if err != nil {
+		return nil, err
+	}
+	return fd.Messages().Get(0), nil
+}
+
+// columnName returns the column that a field is written to: its name, or the
+// name given by its column_name annotation.
+func columnName(fd protoreflect.FieldDescriptor) string {
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && proto.HasExtension(opts, storagepb.E_ColumnName) {
+		return proto.GetExtension(opts, storagepb.E_ColumnName).(string)
+	}
+	return string(fd.Name())
+}
+
+func arrowFieldNames(serializedSchema []byte) ([]string, error) {
+	as, err := decodeArrowSchema(serializedSchema)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range as.Fields() {
+		names = append(names, f.Name)
+	}
+	return names, nil
+}
+
+func decodeProtoRow(b []byte, md protoreflect.MessageDescriptor, schema bigquery.Schema) ([]bigquery.Value, error) {
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+	return messageToRow(msg, schema)
+}
+
+func messageToRow(msg protoreflect.Message, schema bigquery.Schema) ([]bigquery.Value, error) {
+	row := make([]bigquery.Value, len(schema))
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := columnName(fd)
+		j := fieldIndex(schema, name)
+		if j < 0 {
+			if msg.Has(fd) {
+				return nil, fmt.Errorf("field %s is not in the table schema", name)
+			}
+			continue
+		}
+		if !msg.Has(fd) {
+			continue
+		}
+		fs := schema[j]
+		if fd.IsList() != fs.Repeated {
+			return nil, fmt.Errorf("field %s: the repetition of the proto field does not match the column mode %s", name, fieldMode(fs))
+		}
+		if !fs.Repeated {
+			v, err := protoValue(msg.Get(fd), fd, fs)
+			if err != nil {
+				return nil, err
+			}
+			row[j] = v
+			continue
+		}
+		list := msg.Get(fd).List()
+		vs := make([]bigquery.Value, list.Len())
+		elem := *fs
+		elem.Repeated = false
+		for k := range vs {
+			v, err := protoValue(list.Get(k), fd, &elem)
+			if err != nil {
+				return nil, err
+			}
+			vs[k] = v
+		}
+		row[j] = vs
+	}
+	if err := completeRow(row, schema); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// protoValue converts a proto field value to a value of the column, following
+// the encodings that the Storage Write API accepts.
+func protoValue(v protoreflect.Value, fd protoreflect.FieldDescriptor, fs *bigquery.FieldSchema) (bigquery.Value, error) {
+	mismatch := fmt.Errorf("field %s: cannot write a proto %s to a %s column", fs.Name, fd.Kind(), fs.Type)
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		m := v.Message()
+		switch fs.Type {
+		case bigquery.RecordFieldType:
+			return messageToRow(m, fs.Schema)
+		case bigquery.RangeFieldType:
+			if fs.RangeElementType == nil {
+				return nil, mismatch
+			}
+			elem := &bigquery.FieldSchema{Name: fs.Name, Type: fs.RangeElementType.Type}
+			rv := &bigquery.RangeValue{}
+			for _, bound := range []struct {
+				name string
+				dst  *bigquery.Value
+			}{{"start", &rv.Start}, {"end", &rv.End}} {
+				bfd := m.Descriptor().Fields().ByName(protoreflect.Name(bound.name))
+				if bfd == nil || !m.Has(bfd) {
+					continue
+				}
+				x, err := protoValue(m.Get(bfd), bfd, elem)
+				if err != nil {
+					return nil, err
+				}
+				*bound.dst = x
+			}
+			return rv, nil
+		}
+		// A wrapper message holds the value in its only field.
+		if m.Descriptor().Fields().Len() != 1 {
+			return nil, mismatch
+		}
+		vfd := m.Descriptor().Fields().Get(0)
+		return protoValue(m.Get(vfd), vfd, fs)
+	case protoreflect.BoolKind:
+		if fs.Type == bigquery.BooleanFieldType {
+			return v.Bool(), nil
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if fs.Type == bigquery.FloatFieldType {
+			return v.Float(), nil
+		}
+	case protoreflect.StringKind:
+		switch fs.Type {
+		case bigquery.StringFieldType, bigquery.JSONFieldType, bigquery.GeographyFieldType:
+			return v.String(), nil
+		case bigquery.BytesFieldType:
+			return nil, mismatch
+		}
+		x, err := parseText(v.String(), fs)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", fs.Name, err)
+		}
+		return x, nil
+	case protoreflect.BytesKind:
+		switch fs.Type {
+		case bigquery.BytesFieldType:
+			return slices.Clone(v.Bytes()), nil
+		case bigquery.NumericFieldType:
+			return decodeNumericBytes(v.Bytes(), bigquery.NumericScaleDigits), nil
+		case bigquery.BigNumericFieldType:
+			return decodeNumericBytes(v.Bytes(), bigquery.BigNumericScaleDigits), nil
+		}
+	case protoreflect.EnumKind:
+		if fs.Type == bigquery.IntegerFieldType {
+			return int64(v.Enum()), nil
+		}
+	default:
+		var n int64
+		switch fd.Kind() {
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			n = int64(v.Uint())
+		default:
+			n = v.Int()
+		}
+		switch fs.Type {
+		case bigquery.IntegerFieldType:
+			return n, nil
+		case bigquery.TimestampFieldType:
+			return time.UnixMicro(n).UTC(), nil
+		case bigquery.DateFieldType:
+			return epochDate.AddDays(int(n)), nil
+		case bigquery.TimeFieldType:
+			return unpackTime(n), nil
+		case bigquery.DateTimeFieldType:
+			return civil.DateTime{
+				Date: civil.Date{Year: int(n >> 46), Month: time.Month(n >> 42 & 0xf), Day: int(n >> 37 & 0x1f)},
+				Time: unpackTime(n),
+			}, nil
+		case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+			return new(big.Rat).SetInt64(n), nil
+		}
+	}
+	return nil, mismatch
+}
+
+// unpackTime decodes the time of day of the packed 64-bit encoding of TIME
+// and DATETIME values.
+func unpackTime(n int64) civil.Time {
+	return civil.Time{
+		Hour:       int(n >> 32 & 0x1f),
+		Minute:     int(n >> 26 & 0x3f),
+		Second:     int(n >> 20 & 0x3f),
+		Nanosecond: int(n&0xfffff) * 1000,
+	}
+}
+
+// decodeNumericBytes decodes the little-endian two's complement encoding of
+// NUMERIC and BIGNUMERIC values, scaled by 10^scale.
+func decodeNumericBytes(b []byte, scale int64) *big.Rat {
+	be := slices.Clone(b)
+	slices.Reverse(be)
+	n := new(big.Int).SetBytes(be)
+	if len(be) > 0 && be[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(be))))
+	}
+	m := new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)
+	return new(big.Rat).SetFrac(n, m)
+}
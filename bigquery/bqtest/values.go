@@ -0,0 +1,553 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bqtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	bq "google.golang.org/api/bigquery/v2"
+)
+
+// rowToBQ converts a row to the f/v form of tabledata.list and
+// jobs.getQueryResults. Timestamps are integer microseconds if int64TS is
+// set, and floating-point seconds otherwise.
+func rowToBQ(row []bigquery.Value, schema bigquery.Schema, int64TS bool) *bq.TableRow {
+	r := &bq.TableRow{F: make([]*bq.TableCell, len(schema))}
+	for i, fs := range schema {
+		r.F[i] = &bq.TableCell{V: cellValue(row[i], fs, int64TS)}
+	}
+	return r
+}
+
+func cellValue(v bigquery.Value, fs *bigquery.FieldSchema, int64TS bool) any {
+	if fs.Repeated {
+		vs, _ := v.([]bigquery.Value)
+		elem := *fs
+		elem.Repeated = false
+		cells := make([]any, len(vs))
+		for i, e := range vs {
+			cells[i] = map[string]any{"v": cellValue(e, &elem, int64TS)}
+		}
+		return cells
+	}
+	if v == nil {
+		return nil
+	}
+	switch fs.Type {
+	case bigquery.RecordFieldType:
+		vs := v.([]bigquery.Value)
+		cells := make([]any, len(fs.Schema))
+		for i, f := range fs.Schema {
+			cells[i] = map[string]any{"v": cellValue(vs[i], f, int64TS)}
+		}
+		return map[string]any{"f": cells}
+	case bigquery.RangeFieldType:
+		rv := v.(*bigquery.RangeValue)
+		bound := func(b bigquery.Value) string {
+			if b == nil {
+				return "UNBOUNDED"
+			}
+			return formatScalar(b, fs.RangeElementType.Type, int64TS)
+		}
+		return "[" + bound(rv.Start) + ", " + bound(rv.End) + ")"
+	}
+	return formatScalar(v, fs.Type, int64TS)
+}
+
+// formatScalar formats a non-NULL scalar value as the REST API does.
+func formatScalar(v bigquery.Value, typ bigquery.FieldType, int64TS bool) string {
+	switch typ {
+	case bigquery.BytesFieldType:
+		return base64.StdEncoding.EncodeToString(v.([]byte))
+	case bigquery.IntegerFieldType:
+		return strconv.FormatInt(v.(int64), 10)
+	case bigquery.FloatFieldType:
+		return formatFloat(v.(float64))
+	case bigquery.BooleanFieldType:
+		return strconv.FormatBool(v.(bool))
+	case bigquery.TimestampFieldType:
+		us := v.(time.Time).UnixMicro()
+		if int64TS {
+			return strconv.FormatInt(us, 10)
+		}
+		return strconv.FormatFloat(float64(us)/1e6, 'E', -1, 64)
+	case bigquery.DateFieldType:
+		return v.(civil.Date).String()
+	case bigquery.TimeFieldType:
+		return bigquery.CivilTimeString(v.(civil.Time))
+	case bigquery.DateTimeFieldType:
+		dt := v.(civil.DateTime)
+		return dt.Date.String() + "T" + bigquery.CivilTimeString(dt.Time)
+	case bigquery.NumericFieldType:
+		return bigquery.NumericString(v.(*big.Rat))
+	case bigquery.BigNumericFieldType:
+		return bigquery.BigNumericString(v.(*big.Rat))
+	case bigquery.IntervalFieldType:
+		return v.(*bigquery.IntervalValue).String()
+	}
+	return v.(string)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// decodeJSON decodes JSON, keeping numbers as json.Number so that integers
+// keep their precision.
+func decodeJSON(b []byte, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// jsonToRow converts a JSON object, as sent to tabledata.insertAll or read
+// from newline-delimited JSON, to a row of the schema.
+func jsonToRow(m map[string]any, schema bigquery.Schema, ignoreUnknown bool) ([]bigquery.Value, error) {
+	row := make([]bigquery.Value, len(schema))
+	set := make([]bool, len(schema))
+	for name, v := range m {
+		i := fieldIndex(schema, name)
+		if i < 0 {
+			if ignoreUnknown {
+				continue
+			}
+			return nil, fmt.Errorf("no such field: %s", name)
+		}
+		if set[i] {
+			return nil, fmt.Errorf("duplicate field: %s", name)
+		}
+		set[i] = true
+		x, err := jsonToValue(v, schema[i], ignoreUnknown)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = x
+	}
+	for i, fs := range schema {
+		if set[i] {
+			continue
+		}
+		switch {
+		case fs.Repeated:
+			row[i] = []bigquery.Value{}
+		case fs.Required:
+			return nil, fmt.Errorf("missing required field: %s", fs.Name)
+		}
+	}
+	return row, nil
+}
+
+func jsonToValue(v any, fs *bigquery.FieldSchema, ignoreUnknown bool) (bigquery.Value, error) {
+	if fs.Repeated {
+		if v == nil {
+			return []bigquery.Value{}, nil
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected an array, got %v", fs.Name, v)
+		}
+		elem := *fs
+		elem.Repeated = false
+		elem.Required = true
+		vs := make([]bigquery.Value, len(arr))
+		for i, e := range arr {
+			x, err := jsonToValue(e, &elem, ignoreUnknown)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = x
+		}
+		return vs, nil
+	}
+	if v == nil {
+		if fs.Required {
+			return nil, fmt.Errorf("field %s: NULL value for a REQUIRED field", fs.Name)
+		}
+		return nil, nil
+	}
+	switch fs.Type {
+	case bigquery.RecordFieldType:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected an object, got %v", fs.Name, v)
+		}
+		return jsonToRow(m, fs.Schema, ignoreUnknown)
+	case bigquery.JSONFieldType:
+		if s, ok := v.(string); ok {
+			return parseScalar(s, fs)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case bigquery.RangeFieldType:
+		if m, ok := v.(map[string]any); ok {
+			elem := &bigquery.FieldSchema{Name: fs.Name, Type: fs.RangeElementType.Type}
+			rv := &bigquery.RangeValue{}
+			for k, b := range m {
+				x, err := jsonToValue(b, elem, ignoreUnknown)
+				if err != nil {
+					return nil, err
+				}
+				switch strings.ToLower(k) {
+				case "start":
+					rv.Start = x
+				case "end":
+					rv.End = x
+				default:
+					return nil, fmt.Errorf("field %s: unknown RANGE bound %q", fs.Name, k)
+				}
+			}
+			return rv, nil
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return parseScalar(v, fs)
+	case json.Number:
+		if fs.Type == bigquery.StringFieldType || fs.Type == bigquery.BooleanFieldType {
+			break
+		}
+		return parseScalar(v.String(), fs)
+	case bool:
+		switch fs.Type {
+		case bigquery.BooleanFieldType:
+			return v, nil
+		case bigquery.StringFieldType:
+			return strconv.FormatBool(v), nil
+		}
+	}
+	return nil, fmt.Errorf("field %s: cannot convert %v to %s", fs.Name, v, fs.Type)
+}
+
+// parseScalar parses the text form of a non-repeated value, as accepted by
+// the API in JSON strings, CSV files and query parameters.
+func parseScalar(s string, fs *bigquery.FieldSchema) (bigquery.Value, error) {
+	v, err := parseText(s, fs)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: invalid %s value %q", fs.Name, fs.Type, s)
+	}
+	return v, nil
+}
+
+func parseText(s string, fs *bigquery.FieldSchema) (bigquery.Value, error) {
+	switch fs.Type {
+	case bigquery.StringFieldType, bigquery.GeographyFieldType:
+		return s, nil
+	case bigquery.JSONFieldType:
+		if !json.Valid([]byte(s)) {
+			return nil, errors.New("invalid JSON")
+		}
+		return s, nil
+	case bigquery.BytesFieldType:
+		return base64.StdEncoding.DecodeString(s)
+	case bigquery.IntegerFieldType:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			// Integral numbers in other notations, like 1e3 or 2.0, are
+			// accepted too.
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil || f != math.Trunc(f) || math.Abs(f) >= 1<<63 {
+				return nil, err
+			}
+			i = int64(f)
+		}
+		return i, nil
+	case bigquery.FloatFieldType:
+		return strconv.ParseFloat(s, 64)
+	case bigquery.BooleanFieldType:
+		return strconv.ParseBool(strings.ToLower(s))
+	case bigquery.TimestampFieldType:
+		return parseTimestamp(s)
+	case bigquery.DateFieldType:
+		return civil.ParseDate(s)
+	case bigquery.TimeFieldType:
+		return parseTime(s)
+	case bigquery.DateTimeFieldType:
+		return parseDateTime(s)
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, errors.New("invalid number")
+		}
+		return r, nil
+	case bigquery.IntervalFieldType:
+		return bigquery.ParseInterval(s)
+	case bigquery.RangeFieldType:
+		return parseRange(s, fs)
+	}
+	return nil, fmt.Errorf("unsupported type %s", fs.Type)
+}
+
+// parseTimestamp parses the canonical timestamp formats, with an optional
+// time zone offset or UTC suffix. A number is seconds since the epoch.
+func parseTimestamp(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMicro(int64(math.Round(f * 1e6))).UTC(), nil
+	}
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "UTC"), " ")
+	if len(s) > 10 && (s[10] == 'T' || s[10] == 't') {
+		s = s[:10] + " " + s[11:]
+	}
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999 Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02 15:04Z07:00",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Truncate(time.Microsecond), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+func parseTime(s string) (civil.Time, error) {
+	if t, err := civil.ParseTime(s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return civil.Time{}, err
+	}
+	return civil.TimeOf(t), nil
+}
+
+func parseDateTime(s string) (civil.DateTime, error) {
+	if len(s) > 10 && s[10] == ' ' {
+		s = s[:10] + "T" + s[11:]
+	}
+	if dt, err := civil.ParseDateTime(s); err == nil {
+		return dt, nil
+	}
+	if len(s) > 10 {
+		if t, err := time.Parse("2006-01-02T15:04", s); err == nil {
+			return civil.DateTimeOf(t), nil
+		}
+	}
+	d, err := civil.ParseDate(s)
+	if err != nil {
+		return civil.DateTime{}, err
+	}
+	return civil.DateTime{Date: d}, nil
+}
+
+// parseRange parses a RANGE in its text form, "[start, end)", where either
+// bound may be UNBOUNDED or NULL.
+func parseRange(s string, fs *bigquery.FieldSchema) (*bigquery.RangeValue, error) {
+	if fs.RangeElementType == nil || !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, ")") {
+		return nil, errors.New("invalid range")
+	}
+	parts := strings.Split(s[1:len(s)-1], ",")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid range")
+	}
+	elem := &bigquery.FieldSchema{Name: fs.Name, Type: fs.RangeElementType.Type}
+	rv := &bigquery.RangeValue{}
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if strings.EqualFold(p, "UNBOUNDED") || strings.EqualFold(p, "NULL") {
+			continue
+		}
+		v, err := parseText(p, elem)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			rv.Start = v
+		} else {
+			rv.End = v
+		}
+	}
+	return rv, nil
+}
+
+// fieldIndex returns the index of the named field, which is matched without
+// regard to case, or -1.
+func fieldIndex(schema bigquery.Schema, name string) int {
+	for i, fs := range schema {
+		if strings.EqualFold(fs.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// conformRow converts the values of a row, as produced by a query or a copy,
+// to the types of a destination schema with the same shape. It is used when
+// results are appended to an existing table.
+func conformRow(row []bigquery.Value, from, to bigquery.Schema) ([]bigquery.Value, error) {
+	if len(from) != len(to) {
+		return nil, fmt.Errorf("got %d columns, the destination table has %d", len(from), len(to))
+	}
+	out := make([]bigquery.Value, len(row))
+	for i := range row {
+		v, err := conformValue(row[i], from[i], to[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func conformValue(v bigquery.Value, from, to *bigquery.FieldSchema) (bigquery.Value, error) {
+	if from.Repeated != to.Repeated {
+		return nil, fmt.Errorf("field %s: cannot convert mode %s", to.Name, fieldMode(from))
+	}
+	if from.Repeated {
+		vs := v.([]bigquery.Value)
+		out := make([]bigquery.Value, len(vs))
+		f, t := *from, *to
+		f.Repeated, t.Repeated = false, false
+		for i, e := range vs {
+			x, err := conformValue(e, &f, &t)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	}
+	if v == nil {
+		if to.Required {
+			return nil, fmt.Errorf("field %s: NULL value for a REQUIRED field", to.Name)
+		}
+		return nil, nil
+	}
+	if from.Type == to.Type {
+		if from.Type == bigquery.RecordFieldType {
+			return conformRow(v.([]bigquery.Value), from.Schema, to.Schema)
+		}
+		return v, nil
+	}
+	switch {
+	case from.Type == bigquery.IntegerFieldType && to.Type == bigquery.FloatFieldType:
+		return float64(v.(int64)), nil
+	case from.Type == bigquery.IntegerFieldType && (to.Type == bigquery.NumericFieldType || to.Type == bigquery.BigNumericFieldType):
+		return new(big.Rat).SetInt64(v.(int64)), nil
+	case from.Type == bigquery.NumericFieldType && to.Type == bigquery.BigNumericFieldType:
+		return v, nil
+	}
+	return nil, fmt.Errorf("field %s: cannot convert %s to %s", to.Name, from.Type, to.Type)
+}
+
+func fieldMode(fs *bigquery.FieldSchema) string {
+	switch {
+	case fs.Repeated:
+		return "REPEATED"
+	case fs.Required:
+		return "REQUIRED"
+	}
+	return "NULLABLE"
+}
+
+// evolveRows rewrites the rows of a table whose schema changes from old to
+// new, matching columns by name. Columns may be added, and REQUIRED columns
+// relaxed to NULLABLE.
+func evolveRows(rows [][]bigquery.Value, old, new bigquery.Schema) ([][]bigquery.Value, error) {
+	idx, err := evolveSchema(old, new)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]bigquery.Value, len(rows))
+	for i, row := range rows {
+		out[i] = evolveRow(row, old, new, idx)
+	}
+	return out, nil
+}
+
+// evolveSchema checks that old can change to new and returns, for each
+// column of new, the index of the column in old or -1.
+func evolveSchema(old, new bigquery.Schema) ([]int, error) {
+	idx := make([]int, len(new))
+	used := 0
+	for i, fs := range new {
+		j := fieldIndex(old, fs.Name)
+		idx[i] = j
+		if j < 0 {
+			if fs.Required {
+				return nil, fmt.Errorf("cannot add REQUIRED field %s", fs.Name)
+			}
+			continue
+		}
+		used++
+		ofs := old[j]
+		if ofs.Type != fs.Type || ofs.Repeated != fs.Repeated || (fs.Required && !ofs.Required) {
+			return nil, fmt.Errorf("field %s has changed type or mode from %s %s to %s %s", fs.Name, fieldMode(ofs), ofs.Type, fieldMode(fs), fs.Type)
+		}
+		if fs.Type == bigquery.RecordFieldType {
+			if _, err := evolveSchema(ofs.Schema, fs.Schema); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if used != len(old) {
+		return nil, errors.New("fields cannot be removed from a schema")
+	}
+	return idx, nil
+}
+
+func evolveRow(row []bigquery.Value, old, new bigquery.Schema, idx []int) []bigquery.Value {
+	out := make([]bigquery.Value, len(new))
+	for i, fs := range new {
+		j := idx[i]
+		if j < 0 {
+			if fs.Repeated {
+				out[i] = []bigquery.Value{}
+			}
+			continue
+		}
+		v := row[j]
+		if fs.Type == bigquery.RecordFieldType && v != nil {
+			sub, _ := evolveSchema(old[j].Schema, fs.Schema)
+			if fs.Repeated {
+				vs := v.([]bigquery.Value)
+				evolved := make([]bigquery.Value, len(vs))
+				for k, e := range vs {
+					evolved[k] = evolveRow(e.([]bigquery.Value), old[j].Schema, fs.Schema, sub)
+				}
+				v = evolved
+			} else {
+				v = evolveRow(v.([]bigquery.Value), old[j].Schema, fs.Schema, sub)
+			}
+		}
+		out[i] = v
+	}
+	return out
+}
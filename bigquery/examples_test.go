@@ -239,6 +239,33 @@ func ExampleRowIterator_Next_struct() {
 	}
 }
 
+func ExampleFileWriter() {
+	ctx := context.Background()
+	client, err := bigquery.NewClient(ctx, "project-id")
+	if err != nil {
+		// TODO: Handle error.
+	}
+	it, err := client.Query("select name, num from t1").Read(ctx)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	f, err := os.Create("results.parquet")
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer f.Close()
+	fw, err := bigquery.NewFileWriter(f, bigquery.Parquet)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	if err := fw.WriteIterator(it); err != nil {
+		// TODO: Handle error.
+	}
+	if err := fw.Close(); err != nil {
+		// TODO: Handle error.
+	}
+}
+
 func ExampleJob_Read() {
 	ctx := context.Background()
 	client, err := bigquery.NewClient(ctx, "project-id")
@@ -726,6 +753,37 @@ func ExampleTable_Update_blindWrite() {
 	fmt.Println(tm)
 }
 
+func ExampleTable_PlanSchemaUpdate() {
+	ctx := context.Background()
+	client, err := bigquery.NewClient(ctx, "project-id")
+	if err != nil {
+		// TODO: Handle error.
+	}
+	type Item struct {
+		Name  string
+		Size  bigquery.NullFloat64
+		Count int
+	}
+	schema, err := bigquery.InferSchema(Item{})
+	if err != nil {
+		// TODO: Handle error.
+	}
+	t := client.Dataset("my_dataset").Table("my_table")
+	plan, err := t.PlanSchemaUpdate(ctx, schema)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	for _, c := range plan.Breaking() {
+		fmt.Println("cannot apply:", c)
+	}
+	if tm, ok := plan.Update(); ok {
+		// The update fails if the table was modified after the plan was made.
+		if _, err := t.Update(ctx, tm, plan.ETag); err != nil {
+			// TODO: Handle error.
+		}
+	}
+}
+
 func ExampleTableIterator_Next() {
 	ctx := context.Background()
 	client, err := bigquery.NewClient(ctx, "project-id")
@@ -850,3 +908,17 @@ func ExampleInserter_Put_valuesSaver() {
 		// TODO: Handle error.
 	}
 }
+
+func ExampleParseGeography() {
+	g, err := bigquery.ParseGeography(`{"type": "LineString", "coordinates": [[-122.35, 47.62], [-122.33, 47.6]]}`)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	fmt.Println(g.WKT())
+	if line, ok := g.Geometry.(bigquery.LineString); ok {
+		fmt.Println(len(line), "points")
+	}
+	// Output:
+	// LINESTRING(-122.35 47.62, -122.33 47.6)
+	// 2 points
+}
@@ -0,0 +1,769 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/decimal256"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+	"google.golang.org/api/iterator"
+)
+
+// FileWriter writes rows to an io.Writer in a file format, such as to save
+// the results of a query or the contents of a table locally, without an
+// extract job. Rows are encoded as they are written, so memory use does not
+// grow with the number of rows, except that a Parquet row group is held in
+// memory until it is complete.
+//
+// The supported formats are:
+//
+//   - CSV. NULL values are written as empty fields. RECORD and REPEATED
+//     columns, which CSV cannot represent, are written as JSON text, and
+//     RANGE columns in the "[start, end)" format.
+//   - JSON, for newline-delimited JSON, with one object per row. RECORD
+//     columns are written as objects, REPEATED columns as arrays, RANGE
+//     columns as objects with "start" and "end" keys, and JSON columns as
+//     the JSON values they hold.
+//   - Parquet, with Snappy compression. Columns are written with the Arrow
+//     Parquet writer, from the Arrow types BigQuery uses for read sessions:
+//     NUMERIC and BIGNUMERIC as DECIMAL(38, 9) and DECIMAL(76, 38),
+//     TIMESTAMP and DATETIME as TIMESTAMP in microseconds, DATE as DATE,
+//     TIME as TIME in microseconds, RECORD columns as groups, REPEATED
+//     columns as LIST groups, and RANGE columns as groups of their start and
+//     end. GEOGRAPHY and INTERVAL values are written as strings, in the WKT
+//     and canonical formats. The Arrow writer marks DATETIME columns as
+//     adjusted to UTC, like TIMESTAMP columns, so readers may read their
+//     values as times in UTC.
+//
+// In the text formats, TIMESTAMP, DATE, TIME, DATETIME, NUMERIC and
+// BIGNUMERIC values are written in the formats BigQuery uses, such as
+// "2006-01-02 15:04:05.999999 UTC", and BYTES values are base64 encoded.
+//
+// A FileWriter must be closed to complete the output. It doesn't close the
+// underlying io.Writer.
+type FileWriter struct {
+	// Schema describes the rows to write. If it is nil, it is taken from the
+	// first iterator passed to WriteIterator or WriteArrowIterator. It must
+	// not be changed once a row has been written.
+	Schema Schema
+
+	// FieldDelimiter is the separator for fields in CSV output. The default
+	// is ",".
+	FieldDelimiter string
+
+	// DisableHeader disables the header row of CSV output, which holds the
+	// names of the columns.
+	DisableHeader bool
+
+	// RowGroupSize is the number of rows in each row group of Parquet output,
+	// except the last. It bounds the number of rows held in memory. The
+	// default is 65536.
+	RowGroupSize int
+
+	w      io.Writer
+	format DataFormat
+	enc    fileEncoder
+	closed bool
+}
+
+// fileEncoder encodes rows in a file format.
+type fileEncoder interface {
+	write(row []Value) error
+	close() error
+}
+
+const defaultRowGroupSize = 65536
+
+// NewFileWriter returns a FileWriter that writes rows to w in the given
+// format, which is CSV, JSON or Parquet.
+func NewFileWriter(w io.Writer, format DataFormat) (*FileWriter, error) {
+	switch format {
+	case CSV, JSON, Parquet:
+	default:
+		return nil, fmt.Errorf("bigquery: FileWriter does not support the %s format", format)
+	}
+	return &FileWriter{w: w, format: format}, nil
+}
+
+// start creates the encoder when the first row is written.
+func (fw *FileWriter) start() error {
+	if fw.closed {
+		return errors.New("bigquery: FileWriter is closed")
+	}
+	if fw.enc != nil {
+		return nil
+	}
+	if fw.Schema == nil {
+		return errors.New("bigquery: FileWriter has no schema")
+	}
+	var err error
+	switch fw.format {
+	case CSV:
+		fw.enc, err = newCSVEncoder(fw.w, fw.Schema, fw.FieldDelimiter, !fw.DisableHeader)
+	case JSON:
+		fw.enc = &jsonEncoder{w: bufio.NewWriter(fw.w), schema: fw.Schema}
+	case Parquet:
+		size := fw.RowGroupSize
+		if size <= 0 {
+			size = defaultRowGroupSize
+		}
+		fw.enc, err = newParquetEncoder(fw.w, fw.Schema, size)
+	}
+	return err
+}
+
+// Write writes a row, whose values are of the Go types that RowIterator
+// returns for the columns of the schema.
+func (fw *FileWriter) Write(row []Value) error {
+	if err := fw.start(); err != nil {
+		return err
+	}
+	if len(row) != len(fw.Schema) {
+		return fmt.Errorf("bigquery: row has %d values, but the schema has %d columns", len(row), len(fw.Schema))
+	}
+	return fw.enc.write(row)
+}
+
+// WriteIterator writes the rows of it, until the iterator is done.
+func (fw *FileWriter) WriteIterator(it *RowIterator) error {
+	for {
+		var row []Value
+		err := it.Next(&row)
+		if fw.Schema == nil {
+			fw.Schema = it.Schema
+		}
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fw.Write(row); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteArrowIterator writes the rows of the record batches of it, such as
+// the one returned by RowIterator.ArrowIterator, until the iterator is done.
+func (fw *FileWriter) WriteArrowIterator(it ArrowIterator) error {
+	if fw.Schema == nil {
+		fw.Schema = it.Schema()
+	}
+	dec, err := newArrowDecoder(it.SerializedArrowSchema(), it.Schema())
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rows, err := dec.decodeArrowRecords(batch)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close completes the output, writing a CSV header and Parquet metadata
+// even if no rows were written, and flushes it to the underlying writer.
+func (fw *FileWriter) Close() error {
+	if fw.closed {
+		return nil
+	}
+	if fw.enc == nil && (fw.Schema != nil || fw.format == Parquet) {
+		if err := fw.start(); err != nil {
+			return err
+		}
+	}
+	fw.closed = true
+	if fw.enc == nil {
+		return nil
+	}
+	return fw.enc.close()
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	schema Schema
+	header bool
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, schema Schema, delim string, header bool) (*csvEncoder, error) {
+	cw := csv.NewWriter(w)
+	if delim != "" {
+		r, n := utf8.DecodeRuneInString(delim)
+		if n != len(delim) {
+			return nil, fmt.Errorf("bigquery: CSV field delimiter %q is not a single character", delim)
+		}
+		cw.Comma = r
+	}
+	return &csvEncoder{w: cw, schema: schema, header: header, record: make([]string, len(schema))}, nil
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if !e.header {
+		return nil
+	}
+	e.header = false
+	for i, fs := range e.schema {
+		e.record[i] = fs.Name
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) write(row []Value) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	for i, fs := range e.schema {
+		s, err := csvField(row[i], fs)
+		if err != nil {
+			return fmt.Errorf("bigquery: column %s: %w", fs.Name, err)
+		}
+		e.record[i] = s
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func csvField(v Value, fs *FieldSchema) (string, error) {
+	switch {
+	case v == nil:
+		return "", nil
+	case fs.Repeated || fs.Type == RecordFieldType:
+		b, err := appendJSONValue(nil, v, fs)
+		return string(b), err
+	case fs.Type == RangeFieldType:
+		return rangeText(v, fs)
+	}
+	return scalarText(v, fs.Type)
+}
+
+type jsonEncoder struct {
+	w      *bufio.Writer
+	schema Schema
+	buf    []byte
+}
+
+func (e *jsonEncoder) write(row []Value) error {
+	b, err := appendJSONRecord(e.buf[:0], row, e.schema)
+	if err != nil {
+		return err
+	}
+	e.buf = append(b, '\n')
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+func (e *jsonEncoder) close() error {
+	return e.w.Flush()
+}
+
+// appendJSONRecord appends the JSON object of a row or RECORD value.
+func appendJSONRecord(b []byte, vals []Value, schema Schema) ([]byte, error) {
+	if len(vals) != len(schema) {
+		return nil, fmt.Errorf("bigquery: record has %d values, but its schema has %d fields", len(vals), len(schema))
+	}
+	b = append(b, '{')
+	for i, fs := range schema {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, fs.Name)
+		b = append(b, ':')
+		var err error
+		if b, err = appendJSONValue(b, vals[i], fs); err != nil {
+			return nil, fmt.Errorf("bigquery: field %s: %w", fs.Name, err)
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendJSONValue appends the JSON representation of a value of a field.
+func appendJSONValue(b []byte, v Value, fs *FieldSchema) ([]byte, error) {
+	if v == nil {
+		return append(b, "null"...), nil
+	}
+	if fs.Repeated {
+		vals, ok := v.([]Value)
+		if !ok {
+			return nil, fmt.Errorf("got %T for a REPEATED field, want []Value", v)
+		}
+		elem := *fs
+		elem.Repeated = false
+		b = append(b, '[')
+		for i, x := range vals {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendJSONValue(b, x, &elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	}
+	switch fs.Type {
+	case RecordFieldType:
+		vals, ok := v.([]Value)
+		if !ok {
+			return nil, fmt.Errorf("got %T for a RECORD field, want []Value", v)
+		}
+		return appendJSONRecord(b, vals, fs.Schema)
+	case RangeFieldType:
+		rv, ok := v.(*RangeValue)
+		if !ok || fs.RangeElementType == nil {
+			return nil, fmt.Errorf("got %T for a RANGE field, want *RangeValue", v)
+		}
+		elem := &FieldSchema{Type: fs.RangeElementType.Type}
+		b = append(b, `{"start":`...)
+		b, err := appendJSONValue(b, rv.Start, elem)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, `,"end":`...)
+		if b, err = appendJSONValue(b, rv.End, elem); err != nil {
+			return nil, err
+		}
+		return append(b, '}'), nil
+	case IntegerFieldType, BooleanFieldType:
+		s, err := scalarText(v, fs.Type)
+		return append(b, s...), err
+	case FloatFieldType:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("got %T for a FLOAT field, want float64", v)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			s, _ := scalarText(f, FloatFieldType)
+			return strconv.AppendQuote(b, s), nil
+		}
+		return strconv.AppendFloat(b, f, 'g', -1, 64), nil
+	case JSONFieldType:
+		if s, ok := v.(string); ok && json.Valid([]byte(s)) {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, []byte(s)); err == nil {
+				return append(b, buf.Bytes()...), nil
+			}
+		}
+	}
+	s, err := scalarText(v, fs.Type)
+	if err != nil {
+		return nil, err
+	}
+	q, err := json.Marshal(s)
+	return append(b, q...), err
+}
+
+// rangeText returns a RANGE value in the "[start, end)" format.
+func rangeText(v Value, fs *FieldSchema) (string, error) {
+	rv, ok := v.(*RangeValue)
+	if !ok || fs.RangeElementType == nil {
+		return "", fmt.Errorf("got %T for a RANGE field, want *RangeValue", v)
+	}
+	bound := func(x Value) (string, error) {
+		if x == nil {
+			return "UNBOUNDED", nil
+		}
+		return scalarText(x, fs.RangeElementType.Type)
+	}
+	start, err := bound(rv.Start)
+	if err != nil {
+		return "", err
+	}
+	end, err := bound(rv.End)
+	if err != nil {
+		return "", err
+	}
+	return "[" + start + ", " + end + ")", nil
+}
+
+// scalarText returns the text of a non-NULL scalar value in the format
+// BigQuery exports it.
+func scalarText(v Value, typ FieldType) (string, error) {
+	ok := false
+	var s string
+	switch typ {
+	case StringFieldType, JSONFieldType:
+		s, ok = v.(string)
+	case GeographyFieldType:
+		switch x := v.(type) {
+		case string:
+			s, ok = x, true
+		case *Geography:
+			s, ok = x.WKT(), x != nil
+		}
+	case BytesFieldType:
+		var b []byte
+		if b, ok = v.([]byte); ok {
+			s = base64.StdEncoding.EncodeToString(b)
+		}
+	case IntegerFieldType:
+		var n int64
+		if n, ok = v.(int64); ok {
+			s = strconv.FormatInt(n, 10)
+		}
+	case FloatFieldType:
+		var f float64
+		if f, ok = v.(float64); ok {
+			switch {
+			case math.IsNaN(f):
+				s = "NaN"
+			case math.IsInf(f, 1):
+				s = "Infinity"
+			case math.IsInf(f, -1):
+				s = "-Infinity"
+			default:
+				s = strconv.FormatFloat(f, 'g', -1, 64)
+			}
+		}
+	case BooleanFieldType:
+		var t bool
+		if t, ok = v.(bool); ok {
+			s = strconv.FormatBool(t)
+		}
+	case TimestampFieldType:
+		var t time.Time
+		if t, ok = v.(time.Time); ok {
+			s = t.UTC().Format("2006-01-02 15:04:05.999999") + " UTC"
+		}
+	case DateFieldType:
+		var d civil.Date
+		if d, ok = v.(civil.Date); ok {
+			s = d.String()
+		}
+	case TimeFieldType:
+		var t civil.Time
+		if t, ok = v.(civil.Time); ok {
+			s = CivilTimeString(t)
+		}
+	case DateTimeFieldType:
+		var dt civil.DateTime
+		if dt, ok = v.(civil.DateTime); ok {
+			s = CivilDateTimeString(dt)
+		}
+	case NumericFieldType, BigNumericFieldType:
+		var r *big.Rat
+		if r, ok = v.(*big.Rat); ok {
+			scale := NumericScaleDigits
+			if typ == BigNumericFieldType {
+				scale = BigNumericScaleDigits
+			}
+			s = r.FloatString(scale)
+			if strings.Contains(s, ".") {
+				s = strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+			}
+		}
+	case IntervalFieldType:
+		var iv *IntervalValue
+		if iv, ok = v.(*IntervalValue); ok {
+			s = iv.String()
+		}
+	default:
+		return "", fmt.Errorf("unsupported type %s", typ)
+	}
+	if !ok {
+		return "", fmt.Errorf("got %T for a %s value", v, typ)
+	}
+	return s, nil
+}
+
+// parquetEncoder writes rows with the Parquet writer of Arrow, building a
+// record of the rows of each row group.
+type parquetEncoder struct {
+	fw        *pqarrow.FileWriter
+	b         *array.RecordBuilder
+	schema    Schema
+	groupSize int
+	rows      int
+	vals      []any
+}
+
+func newParquetEncoder(w io.Writer, schema Schema, groupSize int) (*parquetEncoder, error) {
+	as := arrowSchema(schema)
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	// The writer closes its destination if it can, which FileWriter doesn't,
+	// so w is wrapped.
+	fw, err := pqarrow.NewFileWriter(as, struct{ io.Writer }{w}, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: %w", err)
+	}
+	return &parquetEncoder{
+		fw:        fw,
+		b:         array.NewRecordBuilder(memory.DefaultAllocator, as),
+		schema:    schema,
+		groupSize: groupSize,
+		vals:      make([]any, len(schema)),
+	}, nil
+}
+
+func (e *parquetEncoder) write(row []Value) error {
+	// The row is converted in full before any of it is appended, so that a
+	// row with an invalid value is not written in part.
+	for i, fs := range e.schema {
+		x, err := parquetValue(row[i], fs)
+		if err != nil {
+			return fmt.Errorf("bigquery: column %s: %w", fs.Name, err)
+		}
+		e.vals[i] = x
+	}
+	for i, x := range e.vals {
+		appendArrowValue(e.b.Field(i), x)
+	}
+	e.rows++
+	if e.rows >= e.groupSize {
+		return e.flush()
+	}
+	return nil
+}
+
+// flush writes the rows written since the last flush as a row group.
+func (e *parquetEncoder) flush() error {
+	rec := e.b.NewRecord()
+	defer rec.Release()
+	e.rows = 0
+	return e.fw.Write(rec)
+}
+
+func (e *parquetEncoder) close() error {
+	defer e.b.Release()
+	if e.rows > 0 {
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	return e.fw.Close()
+}
+
+// parquetValue converts a non-REPEATED value, or the array of a REPEATED
+// value, to the value appendArrowValue appends to a builder of the Arrow type
+// of the field: nil, []any for arrays, records and ranges, a string for
+// STRING, GEOGRAPHY, JSON and INTERVAL values, and otherwise the Go or Arrow
+// type of the builder's values.
+func parquetValue(v Value, fs *FieldSchema) (any, error) {
+	if fs.Repeated {
+		if v == nil {
+			return []any{}, nil
+		}
+		vals, ok := v.([]Value)
+		if !ok {
+			return nil, fmt.Errorf("got %T for a REPEATED field, want []Value", v)
+		}
+		elem := *fs
+		elem.Repeated = false
+		xs := make([]any, len(vals))
+		for i, x := range vals {
+			if x == nil {
+				return nil, fmt.Errorf("NULL element in the REPEATED field %s", fs.Name)
+			}
+			var err error
+			if xs[i], err = parquetValue(x, &elem); err != nil {
+				return nil, err
+			}
+		}
+		return xs, nil
+	}
+	if v == nil {
+		if fs.Required {
+			return nil, fmt.Errorf("NULL value for the REQUIRED field %s", fs.Name)
+		}
+		return nil, nil
+	}
+	ok := false
+	var x any
+	switch fs.Type {
+	case RecordFieldType:
+		vals, isRecord := v.([]Value)
+		if !isRecord || len(vals) != len(fs.Schema) {
+			return nil, fmt.Errorf("got %T for a RECORD field with %d fields", v, len(fs.Schema))
+		}
+		xs := make([]any, len(vals))
+		for i, c := range fs.Schema {
+			var err error
+			if xs[i], err = parquetValue(vals[i], c); err != nil {
+				return nil, err
+			}
+		}
+		return xs, nil
+	case RangeFieldType:
+		rv, isRange := v.(*RangeValue)
+		if !isRange {
+			return nil, fmt.Errorf("got %T for a RANGE field, want *RangeValue", v)
+		}
+		elem := &FieldSchema{Type: DateFieldType}
+		if fs.RangeElementType != nil {
+			elem.Type = fs.RangeElementType.Type
+		}
+		start, err := parquetValue(rv.Start, elem)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parquetValue(rv.End, elem)
+		if err != nil {
+			return nil, err
+		}
+		return []any{start, end}, nil
+	case StringFieldType, GeographyFieldType, JSONFieldType, IntervalFieldType:
+		return scalarText(v, fs.Type)
+	case NumericFieldType, BigNumericFieldType:
+		var r *big.Rat
+		if r, ok = v.(*big.Rat); ok {
+			return decimalValue(r, fs.Type)
+		}
+	case BytesFieldType:
+		_, ok = v.([]byte)
+		x = v
+	case BooleanFieldType:
+		_, ok = v.(bool)
+		x = v
+	case IntegerFieldType:
+		_, ok = v.(int64)
+		x = v
+	case FloatFieldType:
+		_, ok = v.(float64)
+		x = v
+	case DateFieldType:
+		var d civil.Date
+		if d, ok = v.(civil.Date); ok {
+			x = arrow.Date32(d.DaysSince(civil.Date{Year: 1970, Month: 1, Day: 1}))
+		}
+	case TimeFieldType:
+		var t civil.Time
+		if t, ok = v.(civil.Time); ok {
+			d := time.Duration(t.Hour)*time.Hour + time.Duration(t.Minute)*time.Minute +
+				time.Duration(t.Second)*time.Second + time.Duration(t.Nanosecond)
+			x = arrow.Time64(d.Microseconds())
+		}
+	case TimestampFieldType:
+		var t time.Time
+		if t, ok = v.(time.Time); ok {
+			x = arrow.Timestamp(t.UnixMicro())
+		}
+	case DateTimeFieldType:
+		var dt civil.DateTime
+		if dt, ok = v.(civil.DateTime); ok {
+			x = arrow.Timestamp(dt.In(time.UTC).UnixMicro())
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("got %T for a %s value", v, fs.Type)
+	}
+	return x, nil
+}
+
+// decimalValue returns r scaled by 10^9 as a NUMERIC value, or by 10^38 as a
+// BIGNUMERIC value, rounded half away from zero.
+func decimalValue(r *big.Rat, typ FieldType) (any, error) {
+	scale, bits := int64(NumericScaleDigits), 127
+	if typ == BigNumericFieldType {
+		scale, bits = BigNumericScaleDigits, 255
+	}
+	num := new(big.Int).Mul(r.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil))
+	den := r.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Sign() != 0 && new(big.Int).Mul(m.Abs(m), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if q.BitLen() > bits {
+		return nil, fmt.Errorf("value %s is out of range", r.RatString())
+	}
+	if typ == BigNumericFieldType {
+		return decimal256.FromBigInt(q), nil
+	}
+	return decimal128.FromBigInt(q), nil
+}
+
+// appendArrowValue appends a value converted by parquetValue to b.
+func appendArrowValue(b array.Builder, x any) {
+	if x == nil {
+		b.AppendNull()
+		return
+	}
+	switch b := b.(type) {
+	case *array.ListBuilder:
+		b.Append(true)
+		for _, e := range x.([]any) {
+			appendArrowValue(b.ValueBuilder(), e)
+		}
+	case *array.StructBuilder:
+		b.Append(true)
+		for i, e := range x.([]any) {
+			appendArrowValue(b.FieldBuilder(i), e)
+		}
+	case *array.StringBuilder:
+		b.Append(x.(string))
+	case *array.BinaryBuilder:
+		b.Append(x.([]byte))
+	case *array.BooleanBuilder:
+		b.Append(x.(bool))
+	case *array.Int64Builder:
+		b.Append(x.(int64))
+	case *array.Float64Builder:
+		b.Append(x.(float64))
+	case *array.Date32Builder:
+		b.Append(x.(arrow.Date32))
+	case *array.Time64Builder:
+		b.Append(x.(arrow.Time64))
+	case *array.TimestampBuilder:
+		b.Append(x.(arrow.Timestamp))
+	case *array.Decimal128Builder:
+		b.Append(x.(decimal128.Num))
+	case *array.Decimal256Builder:
+		b.Append(x.(decimal256.Num))
+	}
+}
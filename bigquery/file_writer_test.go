@@ -0,0 +1,228 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/internal/testutil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"google.golang.org/api/iterator"
+)
+
+var (
+	fileTestSchema = Schema{
+		{Name: "name", Type: StringFieldType, Required: true},
+		{Name: "n", Type: IntegerFieldType},
+		{Name: "f", Type: FloatFieldType},
+		{Name: "num", Type: NumericFieldType},
+		{Name: "ts", Type: TimestampFieldType},
+		{Name: "dt", Type: DateTimeFieldType},
+		{Name: "b", Type: BytesFieldType},
+		{Name: "j", Type: JSONFieldType},
+		{Name: "tags", Type: StringFieldType, Repeated: true},
+		{Name: "rec", Type: RecordFieldType, Schema: Schema{
+			{Name: "x", Type: IntegerFieldType},
+			{Name: "d", Type: DateFieldType},
+		}},
+		{Name: "r", Type: RangeFieldType, RangeElementType: &RangeElementType{Type: DateFieldType}},
+	}
+	fileTestRows = [][]Value{
+		{
+			"a, \"b\"", int64(1), 1.5, big.NewRat(3, 2),
+			time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC),
+			civil.DateTime{Date: civil.Date{Year: 2024, Month: 1, Day: 2}, Time: civil.Time{Hour: 3, Minute: 4, Second: 5}},
+			[]byte("hi"), `{"k": [1, 2]}`, []Value{"x", "y"},
+			[]Value{int64(7), civil.Date{Year: 2024, Month: 5, Day: 6}},
+			&RangeValue{Start: civil.Date{Year: 2024, Month: 1, Day: 1}},
+		},
+		{"c", nil, math.Inf(-1), nil, nil, nil, nil, nil, []Value{}, nil, nil},
+	}
+)
+
+func writeFile(t *testing.T, format DataFormat, configure func(*FileWriter), rows [][]Value) string {
+	t.Helper()
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Schema = fileTestSchema
+	if configure != nil {
+		configure(fw)
+	}
+	for _, row := range rows {
+		if err := fw.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestFileWriterCSV(t *testing.T) {
+	got := writeFile(t, CSV, nil, fileTestRows)
+	want := `name,n,f,num,ts,dt,b,j,tags,rec,r
+"a, ""b""",1,1.5,1.5,2024-01-02 03:04:05.000006 UTC,2024-01-02 03:04:05,aGk=,"{""k"": [1, 2]}","[""x"",""y""]","{""x"":7,""d"":""2024-05-06""}","[2024-01-01, UNBOUNDED)"
+c,,-Infinity,,,,,,[],,
+`
+	if diff := testutil.Diff(got, want); diff != "" {
+		t.Errorf("got=-, want=+:\n%s", diff)
+	}
+
+	got = writeFile(t, CSV, func(fw *FileWriter) {
+		fw.FieldDelimiter = "\t"
+		fw.DisableHeader = true
+	}, fileTestRows[1:])
+	if want := "c\t\t-Infinity\t\t\t\t\t\t[]\t\t\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The header is written even if there are no rows.
+	if got, want := writeFile(t, CSV, nil, nil), "name,n,f,num,ts,dt,b,j,tags,rec,r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFileWriterJSON(t *testing.T) {
+	got := writeFile(t, JSON, nil, fileTestRows)
+	want := `{"name":"a, \"b\"","n":1,"f":1.5,"num":"1.5","ts":"2024-01-02 03:04:05.000006 UTC","dt":"2024-01-02 03:04:05","b":"aGk=","j":{"k":[1,2]},"tags":["x","y"],"rec":{"x":7,"d":"2024-05-06"},"r":{"start":"2024-01-01","end":null}}
+{"name":"c","n":null,"f":"-Infinity","num":null,"ts":null,"dt":null,"b":null,"j":null,"tags":[],"rec":null,"r":null}
+`
+	if diff := testutil.Diff(got, want); diff != "" {
+		t.Errorf("got=-, want=+:\n%s", diff)
+	}
+}
+
+func TestFileWriterErrors(t *testing.T) {
+	if _, err := NewFileWriter(&bytes.Buffer{}, Avro); err == nil {
+		t.Error("Avro: got nil, want error")
+	}
+	fw, err := NewFileWriter(&bytes.Buffer{}, JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Write([]Value{1}); err == nil {
+		t.Error("write without a schema: got nil, want error")
+	}
+	fw.Schema = Schema{{Name: "n", Type: IntegerFieldType}}
+	if err := fw.Write([]Value{"x"}); err == nil || !strings.Contains(err.Error(), "string") {
+		t.Errorf("write of a string to an INTEGER column: got %v, want error", err)
+	}
+	if err := fw.Write([]Value{int64(1), int64(2)}); err == nil {
+		t.Error("write of a row that is too long: got nil, want error")
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Write([]Value{int64(1)}); err == nil {
+		t.Error("write after Close: got nil, want error")
+	}
+
+	fw, err = NewFileWriter(&bytes.Buffer{}, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Schema = Schema{{Name: "n", Type: IntegerFieldType}}
+	fw.FieldDelimiter = "ab"
+	if err := fw.Write([]Value{int64(1)}); err == nil {
+		t.Error("multi-character delimiter: got nil, want error")
+	}
+}
+
+// testArrowIterator serves record batches of serialized Arrow records.
+type testArrowIterator struct {
+	schema      Schema
+	arrowSchema []byte
+	batches     [][]byte
+}
+
+func (it *testArrowIterator) Next() (*ArrowRecordBatch, error) {
+	if len(it.batches) == 0 {
+		return nil, iterator.Done
+	}
+	b := it.batches[0]
+	it.batches = it.batches[1:]
+	return &ArrowRecordBatch{Schema: it.arrowSchema, Data: b}, nil
+}
+
+func (it *testArrowIterator) Schema() Schema                { return it.schema }
+func (it *testArrowIterator) SerializedArrowSchema() []byte { return it.arrowSchema }
+
+func TestFileWriterArrowIterator(t *testing.T) {
+	as := arrow.NewSchema([]arrow.Field{
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "n", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	}, nil)
+	var schemaOnly bytes.Buffer
+	if err := ipc.NewWriter(&schemaOnly, ipc.WithSchema(as)).Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The serialized schema, without the end-of-stream marker.
+	serialized := schemaOnly.Bytes()[:schemaOnly.Len()-8]
+	it := &testArrowIterator{
+		schema:      Schema{{Name: "name", Type: StringFieldType}, {Name: "n", Type: IntegerFieldType}},
+		arrowSchema: serialized,
+	}
+	for _, batch := range [][]string{{"a", "b"}, {"c"}} {
+		b := array.NewRecordBuilder(memory.DefaultAllocator, as)
+		for i, s := range batch {
+			b.Field(0).(*array.StringBuilder).Append(s)
+			if i == 0 {
+				b.Field(1).AppendNull()
+			} else {
+				b.Field(1).(*array.Int64Builder).Append(int64(i))
+			}
+		}
+		rec := b.NewRecord()
+		var buf bytes.Buffer
+		w := ipc.NewWriter(&buf, ipc.WithSchema(as))
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		rec.Release()
+		b.Release()
+		it.batches = append(it.batches, buf.Bytes()[len(serialized):])
+	}
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.WriteArrowIterator(it); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "name,n\na,\nb,1\nc,\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
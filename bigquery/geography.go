@@ -0,0 +1,889 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Geography is a BigQuery GEOGRAPHY value: a set of points on the surface of
+// the Earth, described by a Geometry whose coordinates are longitudes and
+// latitudes in degrees on the WGS84 reference ellipsoid. The zero Geography is
+// the empty geography.
+//
+// GEOGRAPHY values read into a []Value are strings in the WKT format; use
+// ParseGeography to convert them. A *Geography struct field is set from a
+// GEOGRAPHY column, and is NULL when nil. A *Geography may also be saved with
+// an Inserter, used as a query parameter, and is inferred as GEOGRAPHY by
+// InferSchema.
+//
+// Geography does not validate geometries beyond their syntax, which for
+// GeoJSON includes that polygon rings are closed and have at least four
+// positions: the service rejects invalid ones, such as polygons whose rings
+// intersect.
+type Geography struct {
+	Geometry Geometry
+}
+
+// Geometry is a geometric shape: a Point, LineString, Polygon, MultiPoint,
+// MultiLineString, MultiPolygon or GeometryCollection.
+type Geometry interface {
+	isGeometry()
+}
+
+// Point is a location, given by its longitude and latitude in degrees.
+type Point struct {
+	Lng, Lat float64
+}
+
+// LineString is a path along a sequence of points.
+type LineString []Point
+
+// Polygon is an area bounded by rings, which are LineStrings whose first and
+// last points are equal. The first ring is the outer boundary of the polygon,
+// and any others are holes in it.
+type Polygon []LineString
+
+// MultiPoint is a collection of points.
+type MultiPoint []Point
+
+// MultiLineString is a collection of line strings.
+type MultiLineString []LineString
+
+// MultiPolygon is a collection of polygons.
+type MultiPolygon []Polygon
+
+// GeometryCollection is a collection of geometries of any kind.
+type GeometryCollection []Geometry
+
+func (Point) isGeometry()              {}
+func (LineString) isGeometry()         {}
+func (Polygon) isGeometry()            {}
+func (MultiPoint) isGeometry()         {}
+func (MultiLineString) isGeometry()    {}
+func (MultiPolygon) isGeometry()       {}
+func (GeometryCollection) isGeometry() {}
+
+// geometry returns the geometry of g, or nil if g is nil or empty.
+func (g *Geography) geometry() Geometry {
+	if g == nil {
+		return nil
+	}
+	return g.Geometry
+}
+
+// ParseGeography parses a GEOGRAPHY value in the WKT format, such as
+// "POINT(-122.35 47.62)", or in the GeoJSON format, such as
+// `{"type": "Point", "coordinates": [-122.35, 47.62]}`.
+//
+// Only two-dimensional geometries are supported. Empty points are parsed as
+// empty geometry collections, as BigQuery represents them.
+func ParseGeography(s string) (*Geography, error) {
+	var g Geometry
+	var err error
+	if t := strings.TrimSpace(s); strings.HasPrefix(t, "{") {
+		g, err = parseGeoJSON([]byte(t))
+	} else {
+		p := &wktParser{s: s}
+		if g, err = p.geometry(); err == nil {
+			p.skipSpace()
+			if p.pos < len(p.s) {
+				err = fmt.Errorf("unexpected %q after geometry", p.s[p.pos:])
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: invalid GEOGRAPHY value %q: %v", s, err)
+	}
+	return &Geography{Geometry: g}, nil
+}
+
+// ParseGeographyWKB parses a GEOGRAPHY value in the Well-Known Binary format,
+// in either byte order.
+func ParseGeographyWKB(b []byte) (*Geography, error) {
+	r := &wkbReader{b: b}
+	g, err := r.geometry(true)
+	if err == nil && len(r.b) > 0 {
+		err = fmt.Errorf("%d unexpected bytes after geometry", len(r.b))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: invalid GEOGRAPHY WKB value: %v", err)
+	}
+	return &Geography{Geometry: g}, nil
+}
+
+// String returns the geography in the WKT format.
+func (g *Geography) String() string {
+	return g.WKT()
+}
+
+// WKT returns the geography in the Well-Known Text format, as BigQuery
+// formats it, such as "POINT(-122.35 47.62)". The empty geography is
+// "GEOMETRYCOLLECTION EMPTY".
+func (g *Geography) WKT() string {
+	var b strings.Builder
+	writeWKT(&b, g.geometry())
+	return b.String()
+}
+
+// WKB returns the geography in the little-endian Well-Known Binary format.
+func (g *Geography) WKB() []byte {
+	var buf bytes.Buffer
+	writeWKB(&buf, g.geometry())
+	return buf.Bytes()
+}
+
+// GeoJSON returns the geography as a GeoJSON geometry object. It returns an
+// error if a coordinate is not finite.
+func (g *Geography) GeoJSON() ([]byte, error) {
+	b, err := json.Marshal(geoJSONOf(g.geometry()))
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: cannot encode GEOGRAPHY as GeoJSON: %v", err)
+	}
+	return b, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, using the WKT format. As a
+// result, a Geography is written as a WKT string by encoding/json, which is
+// how the service accepts GEOGRAPHY values in streaming inserts.
+func (g *Geography) MarshalText() ([]byte, error) {
+	return []byte(g.WKT()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the formats
+// of ParseGeography.
+func (g *Geography) UnmarshalText(b []byte) error {
+	pg, err := ParseGeography(string(b))
+	if err != nil {
+		return err
+	}
+	*g = *pg
+	return nil
+}
+
+// WKT.
+
+func writeWKT(b *strings.Builder, g Geometry) {
+	switch g := g.(type) {
+	case Point:
+		b.WriteString("POINT(")
+		writeWKTPoint(b, g)
+		b.WriteByte(')')
+	case LineString:
+		b.WriteString("LINESTRING")
+		writeWKTPoints(b, g)
+	case Polygon:
+		b.WriteString("POLYGON")
+		writeWKTRings(b, g)
+	case MultiPoint:
+		b.WriteString("MULTIPOINT")
+		writeWKTPoints(b, g)
+	case MultiLineString:
+		b.WriteString("MULTILINESTRING")
+		writeWKTRings(b, g)
+	case MultiPolygon:
+		b.WriteString("MULTIPOLYGON")
+		if len(g) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		b.WriteByte('(')
+		for i, p := range g {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if len(p) == 0 {
+				b.WriteString("EMPTY")
+				continue
+			}
+			writeWKTRings(b, p)
+		}
+		b.WriteByte(')')
+	case GeometryCollection:
+		b.WriteString("GEOMETRYCOLLECTION")
+		if len(g) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		b.WriteByte('(')
+		for i, c := range g {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeWKT(b, c)
+		}
+		b.WriteByte(')')
+	default:
+		b.WriteString("GEOMETRYCOLLECTION EMPTY")
+	}
+}
+
+func writeWKTPoint(b *strings.Builder, p Point) {
+	b.WriteString(strconv.FormatFloat(p.Lng, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(p.Lat, 'f', -1, 64))
+}
+
+// writeWKTPoints writes a parenthesized list of points, or " EMPTY".
+func writeWKTPoints(b *strings.Builder, ps []Point) {
+	if len(ps) == 0 {
+		b.WriteString(" EMPTY")
+		return
+	}
+	b.WriteByte('(')
+	for i, p := range ps {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeWKTPoint(b, p)
+	}
+	b.WriteByte(')')
+}
+
+// writeWKTRings writes a parenthesized list of point lists, or " EMPTY".
+func writeWKTRings(b *strings.Builder, ls []LineString) {
+	if len(ls) == 0 {
+		b.WriteString(" EMPTY")
+		return
+	}
+	b.WriteByte('(')
+	for i, l := range ls {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if len(l) == 0 {
+			b.WriteString("EMPTY")
+			continue
+		}
+		writeWKTPoints(b, l)
+	}
+	b.WriteByte(')')
+}
+
+// wktParser parses geometries in the Well-Known Text format.
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// word returns the next word, in upper case, or "" if the next token is not
+// a word.
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			break
+		}
+		p.pos++
+	}
+	return strings.ToUpper(p.s[start:p.pos])
+}
+
+// consume reports whether the next token is c, and skips it if so.
+func (p *wktParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) expect(c byte) error {
+	if !p.consume(c) {
+		return p.errorf("expected %q", c)
+	}
+	return nil
+}
+
+func (p *wktParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// open consumes the start of a list: either "EMPTY", in which case it
+// returns true, or "(".
+func (p *wktParser) open() (empty bool, err error) {
+	save := p.pos
+	if p.word() == "EMPTY" {
+		return true, nil
+	}
+	p.pos = save
+	return false, p.expect('(')
+}
+
+func (p *wktParser) number() (float64, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("0123456789+-.eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		p.pos = start
+		return 0, p.errorf("expected a number")
+	}
+	return f, nil
+}
+
+func (p *wktParser) point() (Point, error) {
+	lng, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	lat, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{Lng: lng, Lat: lat}, nil
+}
+
+// points parses a list of points, with each point optionally in parentheses
+// as is common in MULTIPOINT values.
+func (p *wktParser) points(parenthesized bool) ([]Point, error) {
+	empty, err := p.open()
+	if err != nil || empty {
+		return nil, err
+	}
+	var ps []Point
+	for {
+		paren := parenthesized && p.consume('(')
+		pt, err := p.point()
+		if err != nil {
+			return nil, err
+		}
+		if paren {
+			if err := p.expect(')'); err != nil {
+				return nil, err
+			}
+		}
+		ps = append(ps, pt)
+		if !p.consume(',') {
+			break
+		}
+	}
+	return ps, p.expect(')')
+}
+
+func (p *wktParser) rings() ([]LineString, error) {
+	empty, err := p.open()
+	if err != nil || empty {
+		return nil, err
+	}
+	var ls []LineString
+	for {
+		l, err := p.points(false)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, LineString(l))
+		if !p.consume(',') {
+			break
+		}
+	}
+	return ls, p.expect(')')
+}
+
+func (p *wktParser) geometry() (Geometry, error) {
+	kind := p.word()
+	save := p.pos
+	switch p.word() {
+	case "Z", "M", "ZM":
+		return nil, fmt.Errorf("%s geometries are not supported", p.s[save:p.pos])
+	}
+	p.pos = save
+	switch kind {
+	case "POINT":
+		empty, err := p.open()
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			return GeometryCollection{}, nil
+		}
+		pt, err := p.point()
+		if err != nil {
+			return nil, err
+		}
+		return pt, p.expect(')')
+	case "LINESTRING":
+		ps, err := p.points(false)
+		return LineString(ps), err
+	case "POLYGON":
+		ls, err := p.rings()
+		return Polygon(ls), err
+	case "MULTIPOINT":
+		ps, err := p.points(true)
+		return MultiPoint(ps), err
+	case "MULTILINESTRING":
+		ls, err := p.rings()
+		return MultiLineString(ls), err
+	case "MULTIPOLYGON":
+		empty, err := p.open()
+		if err != nil || empty {
+			return MultiPolygon(nil), err
+		}
+		var mp MultiPolygon
+		for {
+			ls, err := p.rings()
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, Polygon(ls))
+			if !p.consume(',') {
+				break
+			}
+		}
+		return mp, p.expect(')')
+	case "GEOMETRYCOLLECTION":
+		empty, err := p.open()
+		if err != nil || empty {
+			return GeometryCollection(nil), err
+		}
+		var gc GeometryCollection
+		for {
+			g, err := p.geometry()
+			if err != nil {
+				return nil, err
+			}
+			gc = append(gc, g)
+			if !p.consume(',') {
+				break
+			}
+		}
+		return gc, p.expect(')')
+	case "":
+		return nil, p.errorf("expected a geometry type")
+	}
+	return nil, fmt.Errorf("unknown geometry type %s", kind)
+}
+
+// WKB.
+
+const (
+	wkbPoint = iota + 1
+	wkbLineString
+	wkbPolygon
+	wkbMultiPoint
+	wkbMultiLineString
+	wkbMultiPolygon
+	wkbGeometryCollection
+)
+
+func writeWKB(buf *bytes.Buffer, g Geometry) {
+	header := func(typ uint32, n int) {
+		buf.WriteByte(1) // little endian
+		buf.Write(binary.LittleEndian.AppendUint32(nil, typ))
+		if n >= 0 {
+			buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+		}
+	}
+	points := func(ps []Point) {
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(ps))))
+		for _, p := range ps {
+			buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(p.Lng)))
+			buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(p.Lat)))
+		}
+	}
+	rings := func(ls []LineString) {
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(ls))))
+		for _, l := range ls {
+			points(l)
+		}
+	}
+	switch g := g.(type) {
+	case Point:
+		header(wkbPoint, -1)
+		buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(g.Lng)))
+		buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(g.Lat)))
+	case LineString:
+		header(wkbLineString, -1)
+		points(g)
+	case Polygon:
+		header(wkbPolygon, -1)
+		rings(g)
+	case MultiPoint:
+		header(wkbMultiPoint, len(g))
+		for _, p := range g {
+			writeWKB(buf, p)
+		}
+	case MultiLineString:
+		header(wkbMultiLineString, len(g))
+		for _, l := range g {
+			writeWKB(buf, l)
+		}
+	case MultiPolygon:
+		header(wkbMultiPolygon, len(g))
+		for _, p := range g {
+			writeWKB(buf, p)
+		}
+	case GeometryCollection:
+		header(wkbGeometryCollection, len(g))
+		for _, c := range g {
+			writeWKB(buf, c)
+		}
+	default:
+		header(wkbGeometryCollection, 0)
+	}
+}
+
+var errShortWKB = errors.New("unexpected end of data")
+
+// wkbReader reads geometries in the Well-Known Binary format.
+type wkbReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, errShortWKB
+	}
+	v := r.order.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+// count reads the number of elements of a list whose elements take at least
+// size bytes each.
+func (r *wkbReader) count(size int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n)*uint64(size) > uint64(len(r.b)) {
+		return 0, errShortWKB
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) point() (Point, error) {
+	if len(r.b) < 16 {
+		return Point{}, errShortWKB
+	}
+	p := Point{
+		Lng: math.Float64frombits(r.order.Uint64(r.b)),
+		Lat: math.Float64frombits(r.order.Uint64(r.b[8:])),
+	}
+	r.b = r.b[16:]
+	return p, nil
+}
+
+func (r *wkbReader) points() ([]Point, error) {
+	n, err := r.count(16)
+	if err != nil {
+		return nil, err
+	}
+	ps := make([]Point, n)
+	for i := range ps {
+		if ps[i], err = r.point(); err != nil {
+			return nil, err
+		}
+		if math.IsNaN(ps[i].Lng) || math.IsNaN(ps[i].Lat) {
+			return nil, errors.New("NaN coordinate")
+		}
+	}
+	return ps, nil
+}
+
+func (r *wkbReader) rings() ([]LineString, error) {
+	n, err := r.count(4)
+	if err != nil {
+		return nil, err
+	}
+	ls := make([]LineString, n)
+	for i := range ls {
+		ps, err := r.points()
+		if err != nil {
+			return nil, err
+		}
+		ls[i] = ps
+	}
+	return ls, nil
+}
+
+// geometry reads a geometry. If emptyPoint is true, an empty point, which WKB
+// represents with NaN coordinates, is read as an empty geometry collection.
+func (r *wkbReader) geometry(emptyPoint bool) (Geometry, error) {
+	if len(r.b) < 1 {
+		return nil, errShortWKB
+	}
+	switch r.b[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("invalid byte order %d", r.b[0])
+	}
+	r.b = r.b[1:]
+	typ, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// collect reads the elements of a multi-geometry, which must be of type
+	// want, or of any type if want is zero.
+	collect := func(want uint32, add func(Geometry)) error {
+		n, err := r.count(5)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if want != 0 && (len(r.b) < 5 || r.elemType() != want) {
+				return fmt.Errorf("element %d of a geometry of type %d is not of type %d", i, typ, want)
+			}
+			g, err := r.geometry(want == 0)
+			if err != nil {
+				return err
+			}
+			add(g)
+		}
+		return nil
+	}
+	switch typ {
+	case wkbPoint:
+		p, err := r.point()
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(p.Lng) && math.IsNaN(p.Lat) && emptyPoint {
+			return GeometryCollection{}, nil
+		}
+		if math.IsNaN(p.Lng) || math.IsNaN(p.Lat) {
+			return nil, errors.New("NaN coordinate")
+		}
+		return p, nil
+	case wkbLineString:
+		ps, err := r.points()
+		return LineString(ps), err
+	case wkbPolygon:
+		ls, err := r.rings()
+		return Polygon(ls), err
+	case wkbMultiPoint:
+		var mp MultiPoint
+		err := collect(wkbPoint, func(g Geometry) { mp = append(mp, g.(Point)) })
+		return mp, err
+	case wkbMultiLineString:
+		var ml MultiLineString
+		err := collect(wkbLineString, func(g Geometry) { ml = append(ml, g.(LineString)) })
+		return ml, err
+	case wkbMultiPolygon:
+		var mp MultiPolygon
+		err := collect(wkbPolygon, func(g Geometry) { mp = append(mp, g.(Polygon)) })
+		return mp, err
+	case wkbGeometryCollection:
+		var gc GeometryCollection
+		err := collect(0, func(g Geometry) { gc = append(gc, g) })
+		return gc, err
+	}
+	return nil, fmt.Errorf("unsupported geometry type %d", typ)
+}
+
+// elemType returns the type of the geometry that starts at r.b, which must
+// hold at least five bytes.
+func (r *wkbReader) elemType() uint32 {
+	if r.b[0] == 0 {
+		return binary.BigEndian.Uint32(r.b[1:])
+	}
+	return binary.LittleEndian.Uint32(r.b[1:])
+}
+
+// GeoJSON.
+
+// geoJSONGeometry is a GeoJSON geometry object. Exactly one of Coordinates
+// and Geometries is set.
+type geoJSONGeometry struct {
+	Type        string             `json:"type"`
+	Coordinates interface{}        `json:"coordinates,omitempty"`
+	Geometries  *[]geoJSONGeometry `json:"geometries,omitempty"`
+}
+
+func geoJSONOf(g Geometry) geoJSONGeometry {
+	position := func(p Point) [2]float64 { return [2]float64{p.Lng, p.Lat} }
+	positions := func(ps []Point) [][2]float64 {
+		res := make([][2]float64, len(ps))
+		for i, p := range ps {
+			res[i] = position(p)
+		}
+		return res
+	}
+	lines := func(ls []LineString) [][][2]float64 {
+		res := make([][][2]float64, len(ls))
+		for i, l := range ls {
+			res[i] = positions(l)
+		}
+		return res
+	}
+	switch g := g.(type) {
+	case Point:
+		return geoJSONGeometry{Type: "Point", Coordinates: position(g)}
+	case LineString:
+		return geoJSONGeometry{Type: "LineString", Coordinates: positions(g)}
+	case Polygon:
+		return geoJSONGeometry{Type: "Polygon", Coordinates: lines(g)}
+	case MultiPoint:
+		return geoJSONGeometry{Type: "MultiPoint", Coordinates: positions(g)}
+	case MultiLineString:
+		return geoJSONGeometry{Type: "MultiLineString", Coordinates: lines(g)}
+	case MultiPolygon:
+		polys := make([][][][2]float64, len(g))
+		for i, p := range g {
+			polys[i] = lines(p)
+		}
+		return geoJSONGeometry{Type: "MultiPolygon", Coordinates: polys}
+	case GeometryCollection:
+		gs := make([]geoJSONGeometry, len(g))
+		for i, c := range g {
+			gs[i] = geoJSONOf(c)
+		}
+		return geoJSONGeometry{Type: "GeometryCollection", Geometries: &gs}
+	default:
+		return geoJSONGeometry{Type: "GeometryCollection", Geometries: &[]geoJSONGeometry{}}
+	}
+}
+
+func parseGeoJSON(b []byte) (Geometry, error) {
+	var obj struct {
+		Type        string
+		Coordinates json.RawMessage
+		Geometries  []json.RawMessage
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	position := func(c []float64) (Point, error) {
+		if len(c) != 2 {
+			return Point{}, fmt.Errorf("position %v does not have two coordinates", c)
+		}
+		return Point{Lng: c[0], Lat: c[1]}, nil
+	}
+	positions := func(cs [][]float64) ([]Point, error) {
+		ps := make([]Point, len(cs))
+		for i, c := range cs {
+			var err error
+			if ps[i], err = position(c); err != nil {
+				return nil, err
+			}
+		}
+		return ps, nil
+	}
+	lines := func(css [][][]float64) ([]LineString, error) {
+		ls := make([]LineString, len(css))
+		for i, cs := range css {
+			ps, err := positions(cs)
+			if err != nil {
+				return nil, err
+			}
+			ls[i] = ps
+		}
+		return ls, nil
+	}
+	// rings returns the rings of a polygon, which GeoJSON requires to be
+	// closed and to have at least four positions.
+	rings := func(css [][][]float64) ([]LineString, error) {
+		ls, err := lines(css)
+		if err != nil {
+			return nil, err
+		}
+		for i, l := range ls {
+			if len(l) < 4 {
+				return nil, fmt.Errorf("polygon ring %v has fewer than four positions", css[i])
+			}
+			if l[0] != l[len(l)-1] {
+				return nil, fmt.Errorf("polygon ring %v is not closed", css[i])
+			}
+		}
+		return ls, nil
+	}
+	coordinates := func(v interface{}) error {
+		if len(obj.Coordinates) == 0 {
+			return fmt.Errorf("%s has no coordinates", obj.Type)
+		}
+		return json.Unmarshal(obj.Coordinates, v)
+	}
+	switch obj.Type {
+	case "Point":
+		var c []float64
+		if err := coordinates(&c); err != nil {
+			return nil, err
+		}
+		if len(c) == 0 {
+			return GeometryCollection{}, nil
+		}
+		return position(c)
+	case "LineString", "MultiPoint":
+		var cs [][]float64
+		if err := coordinates(&cs); err != nil {
+			return nil, err
+		}
+		ps, err := positions(cs)
+		if obj.Type == "MultiPoint" {
+			return MultiPoint(ps), err
+		}
+		return LineString(ps), err
+	case "Polygon", "MultiLineString":
+		var css [][][]float64
+		if err := coordinates(&css); err != nil {
+			return nil, err
+		}
+		if obj.Type == "MultiLineString" {
+			ls, err := lines(css)
+			return MultiLineString(ls), err
+		}
+		ls, err := rings(css)
+		return Polygon(ls), err
+	case "MultiPolygon":
+		var csss [][][][]float64
+		if err := coordinates(&csss); err != nil {
+			return nil, err
+		}
+		mp := make(MultiPolygon, len(csss))
+		for i, css := range csss {
+			ls, err := rings(css)
+			if err != nil {
+				return nil, err
+			}
+			mp[i] = ls
+		}
+		return mp, nil
+	case "GeometryCollection":
+		var gc GeometryCollection
+		for _, raw := range obj.Geometries {
+			g, err := parseGeoJSON(raw)
+			if err != nil {
+				return nil, err
+			}
+			gc = append(gc, g)
+		}
+		return gc, nil
+	case "":
+		return nil, errors.New("GeoJSON object has no type")
+	}
+	return nil, fmt.Errorf("unsupported GeoJSON type %q", obj.Type)
+}
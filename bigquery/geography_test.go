@@ -0,0 +1,277 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"cloud.google.com/go/internal/testutil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+var geographyTests = []struct {
+	wkt     string
+	geom    Geometry
+	geoJSON string
+}{
+	{"POINT(-122.35 47.62)", Point{-122.35, 47.62}, `{"type":"Point","coordinates":[-122.35,47.62]}`},
+	{"LINESTRING(0 0, 1 1.5, 2 0)", LineString{{0, 0}, {1, 1.5}, {2, 0}},
+		`{"type":"LineString","coordinates":[[0,0],[1,1.5],[2,0]]}`},
+	{"LINESTRING EMPTY", LineString(nil), `{"type":"LineString","coordinates":[]}`},
+	{"POLYGON((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))", Polygon{{{0, 0}, {4, 0}, {4, 4}, {0, 0}}, {{1, 1}, {2, 1}, {2, 2}, {1, 1}}},
+		`{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]}`},
+	{"MULTIPOINT(1 2, 3 4)", MultiPoint{{1, 2}, {3, 4}}, `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`},
+	{"MULTILINESTRING((1 2, 3 4), (5 6, 7 8))", MultiLineString{{{1, 2}, {3, 4}}, {{5, 6}, {7, 8}}},
+		`{"type":"MultiLineString","coordinates":[[[1,2],[3,4]],[[5,6],[7,8]]]}`},
+	{"MULTIPOLYGON(((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", MultiPolygon{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, {{{5, 5}, {6, 5}, {6, 6}, {5, 5}}}},
+		`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`},
+	{"MULTIPOLYGON(((0 0, 1 0, 1 1, 0 0)), EMPTY)", MultiPolygon{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, nil},
+		`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[]]}`},
+	{"GEOMETRYCOLLECTION(POINT(1 2), LINESTRING(1 2, 3 4))", GeometryCollection{Point{1, 2}, LineString{{1, 2}, {3, 4}}},
+		`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[1,2],[3,4]]}]}`},
+	{"GEOMETRYCOLLECTION EMPTY", GeometryCollection(nil), `{"type":"GeometryCollection","geometries":[]}`},
+}
+
+func TestGeographyFormats(t *testing.T) {
+	for _, test := range geographyTests {
+		g, err := ParseGeography(test.wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := testutil.Diff(g.Geometry, test.geom); diff != "" {
+			t.Errorf("%s: got=-, want=+:\n%s", test.wkt, diff)
+		}
+		if got := g.WKT(); got != test.wkt {
+			t.Errorf("WKT: got %q, want %q", got, test.wkt)
+		}
+
+		gj, err := g.GeoJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(gj); got != test.geoJSON {
+			t.Errorf("GeoJSON: got %s, want %s", got, test.geoJSON)
+		}
+		g2, err := ParseGeography(test.geoJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g2.WKT(); got != test.wkt {
+			t.Errorf("from GeoJSON: got %q, want %q", got, test.wkt)
+		}
+
+		g3, err := ParseGeographyWKB(g.WKB())
+		if err != nil {
+			t.Fatalf("%s: %v", test.wkt, err)
+		}
+		if got := g3.WKT(); got != test.wkt {
+			t.Errorf("from WKB: got %q, want %q", got, test.wkt)
+		}
+	}
+}
+
+func TestParseGeographyVariants(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{" point ( 1  2 ) ", "POINT(1 2)"},
+		{"MULTIPOINT((1 2), (3 4))", "MULTIPOINT(1 2, 3 4)"},
+		{"POINT(1e2 -2.5E-1)", "POINT(100 -0.25)"},
+		{"POINT EMPTY", "GEOMETRYCOLLECTION EMPTY"},
+		{"GEOMETRYCOLLECTION(POINT EMPTY)", "GEOMETRYCOLLECTION(GEOMETRYCOLLECTION EMPTY)"},
+		{"MULTILINESTRING(EMPTY, (1 2, 3 4))", "MULTILINESTRING(EMPTY, (1 2, 3 4))"},
+		{`{"type": "Point", "coordinates": []}`, "GEOMETRYCOLLECTION EMPTY"},
+	} {
+		g, err := ParseGeography(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if got := g.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+
+	// The zero and nil geographies are empty.
+	var nilGeog *Geography
+	for _, g := range []*Geography{{}, nilGeog} {
+		if got, want := g.WKT(), "GEOMETRYCOLLECTION EMPTY"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestParseGeographyErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"POINT",
+		"POINT(1)",
+		"POINT(1 2",
+		"POINT(1 2) x",
+		"POINT Z (1 2 3)",
+		"CIRCLE(1 2)",
+		"POINT(NaN 1)",
+		"LINESTRING(1 2, )",
+		"MULTIPOINT(EMPTY)",
+		`{"type": "Point", "coordinates": [1, 2, 3]}`,
+		`{"type": "Feature"}`,
+		`{"coordinates": [1, 2]}`,
+		`{"type": "Point"}`,
+		`{"type": "Point", "coordinates": [1, 2]`,
+		`{"type": "Polygon", "coordinates": [[[1, 2]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1]]]}`,
+		`{"type": "MultiPolygon", "coordinates": [[[[0, 0], [1, 0], [1, 1], [0, 1]]]]}`,
+	} {
+		if g, err := ParseGeography(in); err == nil {
+			t.Errorf("%q: got %v, want error", in, g)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"0100000000",                 // truncated point
+		"02010000000000000000000000", // bad byte order
+		"01e9030000000000000000f03f000000000000f03f000000000000f03f", // POINT Z
+		"0104000000010000000102000000000000000000",                   // LINESTRING in a MULTIPOINT
+		"0102000000ffffff7f",                           // huge count
+		"0101000000000000000000f03f000000000000f03f00", // trailing byte
+	} {
+		b, err := hex.DecodeString(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, err := ParseGeographyWKB(b); err == nil {
+			t.Errorf("%s: got %v, want error", in, g)
+		}
+	}
+
+	g := &Geography{Geometry: Point{math.Inf(1), 0}}
+	if _, err := g.GeoJSON(); err == nil {
+		t.Error("GeoJSON of an infinite coordinate: got nil, want error")
+	}
+}
+
+func TestParseGeographyWKBBigEndian(t *testing.T) {
+	// A big-endian MULTIPOINT with a little-endian point and a big-endian
+	// point.
+	b, err := hex.DecodeString("000000000400000002" +
+		"0101000000000000000000f03f0000000000000040" +
+		"000000000140080000000000004010000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ParseGeographyWKB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.WKT(), "MULTIPOINT(1 2, 3 4)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGeographyValues(t *testing.T) {
+	type S struct {
+		G     *Geography
+		Null  *Geography
+		Geogs []*Geography
+	}
+	schema := Schema{
+		{Name: "g", Type: GeographyFieldType, Required: true},
+		{Name: "null", Type: GeographyFieldType},
+		{Name: "geogs", Type: GeographyFieldType, Repeated: true},
+	}
+
+	inferred, err := InferSchema(struct {
+		G    *Geography
+		Null *Geography `bigquery:",nullable"`
+		List []*Geography
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Schema{
+		{Name: "G", Type: GeographyFieldType, Required: true},
+		{Name: "Null", Type: GeographyFieldType},
+		{Name: "List", Type: GeographyFieldType, Repeated: true},
+	}
+	if diff := testutil.Diff(inferred, want); diff != "" {
+		t.Errorf("InferSchema: got=-, want=+:\n%s", diff)
+	}
+
+	var s S
+	if err := load(&s, schema, []Value{"POINT(1 2)", nil, []Value{"LINESTRING(1 2, 3 4)"}}); err != nil {
+		t.Fatal(err)
+	}
+	wantS := S{
+		G:     &Geography{Geometry: Point{1, 2}},
+		Geogs: []*Geography{{Geometry: LineString{{1, 2}, {3, 4}}}},
+	}
+	if diff := testutil.Diff(s, wantS); diff != "" {
+		t.Errorf("load: got=-, want=+:\n%s", diff)
+	}
+	if err := load(&s, schema, []Value{"POINT(1", nil, nil}); err == nil {
+		t.Error("load of invalid WKT: got nil, want error")
+	}
+
+	row, _, err := (&StructSaver{Schema: schema, Struct: wantS}).Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := row["null"]; ok {
+		t.Errorf("nil *Geography saved as %v, want it omitted", row["null"])
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"g":"POINT(1 2)","geogs":["LINESTRING(1 2, 3 4)"]}`; got != want {
+		t.Errorf("saved row: got %s, want %s", got, want)
+	}
+
+	n := NullGeography{GeographyVal: "POINT(3 4)", Valid: true}
+	if g, err := n.Geography(); err != nil || g.WKT() != "POINT(3 4)" {
+		t.Errorf("NullGeography.Geography: got (%v, %v)", g, err)
+	}
+	if g, err := (NullGeography{}).Geography(); g != nil || err != nil {
+		t.Errorf("NULL NullGeography.Geography: got (%v, %v), want (nil, nil)", g, err)
+	}
+}
+
+func TestGeographyArrowWKB(t *testing.T) {
+	b := array.NewBinaryBuilder(memory.DefaultAllocator, arrow.BinaryTypes.Binary)
+	defer b.Release()
+	b.Append((&Geography{Geometry: Point{1, 2}}).WKB())
+	b.AppendNull()
+	arr := b.NewArray()
+	defer arr.Release()
+
+	fs := &FieldSchema{Name: "g", Type: GeographyFieldType}
+	v, err := convertArrowValue(arr, 0, arr.DataType(), fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v, Value("POINT(1 2)"); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if v, err := convertArrowValue(arr, 1, arr.DataType(), fs); v != nil || err != nil {
+		t.Errorf("NULL: got (%v, %v), want (nil, nil)", v, err)
+	}
+}
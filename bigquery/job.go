@@ -675,6 +675,9 @@ type ExplainQueryStage struct {
 	// and spilled to disk.
 	ShuffleOutputBytesSpilled int64
 
+	// Slot-milliseconds used by the stage.
+	SlotMillis int64
+
 	// StartTime: Stage start time.
 	StartTime time.Time
 
@@ -1354,6 +1357,7 @@ func queryPlanFromProto(stages []*bq.ExplainQueryStage) []*ExplainQueryStage {
 			RecordsWritten:            s.RecordsWritten,
 			ShuffleOutputBytes:        s.ShuffleOutputBytes,
 			ShuffleOutputBytesSpilled: s.ShuffleOutputBytesSpilled,
+			SlotMillis:                s.SlotMs,
 			StartTime:                 time.Unix(0, s.StartMs*1e6),
 			Status:                    s.Status,
 			Steps:                     steps,
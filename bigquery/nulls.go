@@ -60,6 +60,15 @@ type NullGeography struct {
 
 func (n NullGeography) String() string { return nullstr(n.Valid, n.GeographyVal) }
 
+// Geography parses the value with ParseGeography. It returns nil if the value
+// is NULL.
+func (n NullGeography) Geography() (*Geography, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return ParseGeography(n.GeographyVal)
+}
+
 // NullJSON represents a BigQuery JSON string that may be NULL.
 type NullJSON struct {
 	JSONVal string
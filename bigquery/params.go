@@ -98,6 +98,7 @@ var (
 	typeOfRat                 = reflect.TypeOf(&big.Rat{})
 	typeOfIntervalValue       = reflect.TypeOf(&IntervalValue{})
 	typeOfRangeValue          = reflect.TypeOf(&RangeValue{})
+	typeOfGeography           = reflect.TypeOf(&Geography{})
 	typeOfQueryParameterValue = reflect.TypeOf(&QueryParameterValue{})
 )
 
@@ -345,7 +346,7 @@ func paramType(t reflect.Type, v reflect.Value) (*bq.QueryParameterType, error)
 		return int64ParamType, nil
 	case typeOfNullString:
 		return stringParamType, nil
-	case typeOfNullGeography:
+	case typeOfNullGeography, typeOfGeography:
 		return geographyParamType, nil
 	case typeOfNullJSON:
 		return jsonParamType, nil
@@ -516,6 +517,9 @@ func paramValue(v reflect.Value) (*bq.QueryParameterValue, error) {
 	case typeOfIntervalValue:
 		res.Value = IntervalString(v.Interface().(*IntervalValue))
 		return res, nil
+	case typeOfGeography:
+		res.Value = v.Interface().(*Geography).WKT()
+		return res, nil
 	case typeOfRangeValue:
 		// RangeValue is a compound type, and we must process the start/end to
 		// fully populate the value.
@@ -0,0 +1,147 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/internal/testutil"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/file"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+)
+
+func TestFileWriterParquet(t *testing.T) {
+	schema := Schema{
+		{Name: "name", Type: StringFieldType, Required: true},
+		{Name: "n", Type: IntegerFieldType},
+		{Name: "f", Type: FloatFieldType},
+		{Name: "ok", Type: BooleanFieldType},
+		{Name: "b", Type: BytesFieldType},
+		{Name: "d", Type: DateFieldType},
+		{Name: "tm", Type: TimeFieldType},
+		{Name: "ts", Type: TimestampFieldType},
+		{Name: "dt", Type: DateTimeFieldType},
+		{Name: "num", Type: NumericFieldType},
+		{Name: "bignum", Type: BigNumericFieldType},
+		{Name: "tags", Type: StringFieldType, Repeated: true},
+		{Name: "rec", Type: RecordFieldType, Schema: Schema{
+			{Name: "x", Type: IntegerFieldType},
+			{Name: "ys", Type: FloatFieldType, Repeated: true},
+		}},
+		{Name: "recs", Type: RecordFieldType, Repeated: true, Schema: Schema{
+			{Name: "s", Type: StringFieldType, Required: true},
+		}},
+		{Name: "r", Type: RangeFieldType, RangeElementType: &RangeElementType{Type: DateFieldType}},
+	}
+	bignum, _ := new(big.Rat).SetString("-123456789012345678901234567890.5")
+	rows := [][]Value{
+		{"a", int64(-7), 1.5, true, []byte{0, 1, 2}, civil.Date{Year: 2024, Month: 2, Day: 29},
+			civil.Time{Hour: 12, Minute: 30, Second: 1, Nanosecond: 5000},
+			time.Date(2024, 2, 29, 12, 30, 1, 5000, time.UTC),
+			civil.DateTime{Date: civil.Date{Year: 1969, Month: 12, Day: 31}, Time: civil.Time{Hour: 23}},
+			big.NewRat(3, 2), bignum, []Value{"x", "y"}, []Value{int64(1), []Value{1.5, 2.5}},
+			[]Value{[]Value{"p"}, []Value{"q"}},
+			&RangeValue{Start: civil.Date{Year: 1970, Month: 1, Day: 3}}},
+		{"b", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, []Value{}, nil, []Value{}, nil},
+		{"c", int64(0), math.Inf(-1), false, []byte{}, nil, nil, nil, nil, big.NewRat(-1, 2000000000), nil,
+			[]Value{"z"}, []Value{nil, []Value{}}, []Value{[]Value{""}}, nil},
+	}
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Schema = schema
+	fw.RowGroupSize = 2
+	for i, row := range rows {
+		if err := fw.Write(row); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			// A row with an invalid value is not written in part.
+			bad := append([]Value{}, row...)
+			bad[12] = []Value{int64(2), []Value{nil}}
+			if err := fw.Write(bad); err == nil {
+				t.Error("write of a NULL array element: got nil, want error")
+			}
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pf, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewParquetReader: %v", err)
+	}
+	defer pf.Close()
+	if got := pf.NumRowGroups(); got != 2 {
+		t.Errorf("got %d row groups, want 2", got)
+	}
+	for i := 0; i < pf.NumRowGroups(); i++ {
+		col, err := pf.MetaData().RowGroup(i).ColumnChunk(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := col.Compression(); got != compress.Codecs.Snappy {
+			t.Errorf("row group %d: got compression %s, want SNAPPY", i, got)
+		}
+	}
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		t.Fatalf("ReadTable: %v", err)
+	}
+	defer tbl.Release()
+
+	tr := array.NewTableReader(tbl, -1)
+	defer tr.Release()
+	var got [][]Value
+	for tr.Next() {
+		rec := tr.Record()
+		for i := 0; i < int(rec.NumRows()); i++ {
+			row := make([]Value, len(schema))
+			for j, col := range rec.Columns() {
+				if row[j], err = convertArrowValue(col, i, col.DataType(), schema[j]); err != nil {
+					t.Fatalf("row %d, column %s: %v", i, schema[j].Name, err)
+				}
+			}
+			got = append(got, row)
+		}
+	}
+	// The Arrow writer marks DATETIME columns as adjusted to UTC, so their
+	// values come back as times in UTC.
+	want := append([][]Value{}, rows...)
+	want[0] = append([]Value{}, rows[0]...)
+	want[0][8] = time.Date(1969, 12, 31, 23, 0, 0, 0, time.UTC)
+	// NUMERIC values are rounded half away from zero to 9 digits.
+	want[2] = append([]Value{}, rows[2]...)
+	want[2][9] = big.NewRat(-1, 1000000000)
+	if diff := testutil.Diff(got, want); diff != "" {
+		t.Errorf("got=-, want=+:\n%s", diff)
+	}
+}
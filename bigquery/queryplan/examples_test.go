@@ -0,0 +1,46 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queryplan_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/queryplan"
+)
+
+func ExampleAnalyzeJob() {
+	ctx := context.Background()
+	client, err := bigquery.NewClient(ctx, "project-id")
+	if err != nil {
+		// TODO: Handle error.
+	}
+	job, err := client.JobFromID(ctx, "job-id")
+	if err != nil {
+		// TODO: Handle error.
+	}
+	plan, err := queryplan.AnalyzeJob(ctx, job)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	if err := plan.WriteText(os.Stdout); err != nil {
+		// TODO: Handle error.
+	}
+	if len(plan.Findings()) > 0 {
+		fmt.Println("query needs attention")
+	}
+}
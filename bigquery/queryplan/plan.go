@@ -0,0 +1,481 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package queryplan analyzes the execution plan and statistics of BigQuery
+// query jobs.
+//
+// The plan reported in bigquery.QueryStatistics is a flat list of stages that
+// refer to their inputs by ID. Analyze links those stages into a graph and
+// derives the information that is useful when reviewing an expensive query:
+// the critical path through the plan, stages whose slowest shard spent much
+// longer computing than the average one, shuffles that spilled to disk, and
+// tables that were scanned in full more than once. A Plan can be rendered as
+// an indented text tree or as a Graphviz DOT graph.
+//
+// Dry-run jobs carry no plan; Analyze accepts them and reports only the
+// job-level statistics.
+//
+// This package is EXPERIMENTAL and is subject to change without notice.
+package queryplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+const (
+	// DefaultSkewRatio is the default value of Plan.SkewRatio.
+	DefaultSkewRatio = 5.0
+
+	// DefaultMinSkewCompute is the default value of Plan.MinSkewCompute.
+	DefaultMinSkewCompute = time.Second
+)
+
+// A Plan is the analyzed execution plan of a query job.
+type Plan struct {
+	// JobStatistics are the statistics of the job that the plan was built from.
+	JobStatistics *bigquery.JobStatistics
+
+	// QueryStatistics are the query-specific statistics of the job.
+	QueryStatistics *bigquery.QueryStatistics
+
+	// Stages holds the stages of the plan, in the order reported by the
+	// service.
+	Stages []*Stage
+
+	// SkewRatio is the ratio of ComputeMax to ComputeAvg at or above which a
+	// stage is reported as skewed by Findings. It is set to DefaultSkewRatio
+	// by Analyze.
+	SkewRatio float64
+
+	// MinSkewCompute is the compute time of the slowest shard below which a
+	// stage is never reported as skewed, however uneven its shards are. It is
+	// set to DefaultMinSkewCompute by Analyze.
+	MinSkewCompute time.Duration
+
+	byID  map[int64]*Stage
+	order []*Stage // topological: inputs before the stages that read them
+}
+
+// A Stage is a stage of a query plan, linked to the stages it reads from and
+// the stages that read from it.
+type Stage struct {
+	*bigquery.ExplainQueryStage
+
+	// Inputs are the stages whose output this stage reads.
+	Inputs []*Stage
+
+	// Outputs are the stages that read this stage's output.
+	Outputs []*Stage
+}
+
+// Analyze builds a Plan from the statistics of a query job. The job may be a
+// completed job, a running job, or a dry run, in which case the plan has no
+// stages.
+//
+// Analyze returns an error if the statistics are not those of a query job, or
+// if the stages do not form a directed acyclic graph.
+func Analyze(stats *bigquery.JobStatistics) (*Plan, error) {
+	if stats == nil {
+		return nil, errors.New("queryplan: no job statistics")
+	}
+	qs, ok := stats.Details.(*bigquery.QueryStatistics)
+	if !ok {
+		return nil, fmt.Errorf("queryplan: statistics are %T, not those of a query job", stats.Details)
+	}
+	p := &Plan{
+		JobStatistics:   stats,
+		QueryStatistics: qs,
+		SkewRatio:       DefaultSkewRatio,
+		MinSkewCompute:  DefaultMinSkewCompute,
+		byID:            map[int64]*Stage{},
+	}
+	for _, es := range qs.QueryPlan {
+		if es == nil {
+			continue
+		}
+		if _, ok := p.byID[es.ID]; ok {
+			return nil, fmt.Errorf("queryplan: duplicate stage ID %d", es.ID)
+		}
+		s := &Stage{ExplainQueryStage: es}
+		p.Stages = append(p.Stages, s)
+		p.byID[es.ID] = s
+	}
+	for _, s := range p.Stages {
+		for _, id := range s.InputStages {
+			in, ok := p.byID[id]
+			if !ok {
+				return nil, fmt.Errorf("queryplan: stage %d reads from unknown stage %d", s.ID, id)
+			}
+			s.Inputs = append(s.Inputs, in)
+			in.Outputs = append(in.Outputs, s)
+		}
+	}
+	if err := p.sort(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AnalyzeJob builds a Plan from the statistics of job. If the job's last known
+// status is not done, AnalyzeJob fetches its current status first. Statistics
+// of a dry run are taken from the job returned by Query.Run, without contacting
+// the service.
+func AnalyzeJob(ctx context.Context, job *bigquery.Job) (*Plan, error) {
+	st := job.LastStatus()
+	if st == nil || !st.Done() {
+		var err error
+		if st, err = job.Status(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return Analyze(st.Statistics)
+}
+
+// sort orders the stages so that every stage comes after its inputs, and
+// reports an error if that is impossible.
+func (p *Plan) sort() error {
+	pending := map[*Stage]int{}
+	var ready []*Stage
+	for _, s := range p.Stages {
+		pending[s] = len(s.Inputs)
+		if len(s.Inputs) == 0 {
+			ready = append(ready, s)
+		}
+	}
+	for len(ready) > 0 {
+		s := ready[0]
+		ready = ready[1:]
+		p.order = append(p.order, s)
+		for _, out := range s.Outputs {
+			pending[out]--
+			if pending[out] == 0 {
+				ready = append(ready, out)
+			}
+		}
+	}
+	if len(p.order) != len(p.Stages) {
+		return errors.New("queryplan: the stages of the plan form a cycle")
+	}
+	return nil
+}
+
+// Stage returns the stage with the given ID, or nil if there is none.
+func (p *Plan) Stage(id int64) *Stage {
+	return p.byID[id]
+}
+
+// Roots returns the stages whose output is not read by any other stage. In a
+// complete plan that is the single stage that produces the query result.
+func (p *Plan) Roots() []*Stage {
+	var roots []*Stage
+	for _, s := range p.Stages {
+		if len(s.Outputs) == 0 {
+			roots = append(roots, s)
+		}
+	}
+	return roots
+}
+
+// Duration returns the wall-clock time from the start of the stage to its end,
+// or zero if the stage has not both started and ended.
+func (s *Stage) Duration() time.Duration {
+	if !isSet(s.StartTime) || !isSet(s.EndTime) || s.EndTime.Before(s.StartTime) {
+		return 0
+	}
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// isSet reports whether t holds a time reported by the service. Missing times
+// are converted to the Unix epoch rather than the zero time.
+func isSet(t time.Time) bool {
+	return !t.IsZero() && t.UnixNano() > 0
+}
+
+// weight is the cost of the stage used to find the critical path: its
+// wall-clock duration if known, and otherwise the time its slowest shard
+// spent waiting, reading, computing and writing.
+func (s *Stage) weight() time.Duration {
+	if d := s.Duration(); d > 0 {
+		return d
+	}
+	return s.WaitMax + s.ReadMax + s.ComputeMax + s.WriteMax
+}
+
+// Skew returns the ratio of the compute time of the slowest shard of the stage
+// to that of the average shard, or zero if it is unknown. A stage whose work is
+// evenly distributed has a skew close to one.
+func (s *Stage) Skew() float64 {
+	if s.ComputeAvg > 0 {
+		return float64(s.ComputeMax) / float64(s.ComputeAvg)
+	}
+	if s.ComputeRatioAvg > 0 {
+		return s.ComputeRatioMax / s.ComputeRatioAvg
+	}
+	return 0
+}
+
+// Label returns the name of the stage, or a name derived from its ID if the
+// service did not report one.
+func (s *Stage) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("S%02d", s.ID)
+}
+
+// A Scan is a read of a table by a step of a stage.
+type Scan struct {
+	// Table is the table read, as reported in the plan; usually
+	// "project.dataset.table".
+	Table string
+
+	// Filtered reports whether the read step applies a filter. A scan that is
+	// not filtered reads the whole table, or every selected partition of it.
+	Filtered bool
+}
+
+// Scans returns the tables read by the READ steps of the stage. Reads of the
+// output of other stages are not included.
+func (s *Stage) Scans() []Scan {
+	var scans []Scan
+	for _, step := range s.Steps {
+		if step == nil || step.Kind != "READ" {
+			continue
+		}
+		var sc Scan
+		for _, sub := range step.Substeps {
+			switch {
+			case strings.HasPrefix(sub, "FROM "):
+				if f := strings.Fields(sub); len(f) > 1 {
+					sc.Table = f[1]
+				}
+			case strings.HasPrefix(sub, "WHERE "):
+				sc.Filtered = true
+			}
+		}
+		if sc.Table == "" || strings.HasPrefix(sc.Table, "__") || strings.HasPrefix(sc.Table, "$") {
+			continue
+		}
+		scans = append(scans, sc)
+	}
+	return scans
+}
+
+// CriticalPath returns the chain of dependent stages with the greatest total
+// cost, from an input stage to a root. The cost of a stage is its wall-clock
+// duration, or, for stages without timing information, the sum of the wait,
+// read, compute and write times of its slowest shard. CriticalPath returns nil
+// for a plan without stages.
+func (p *Plan) CriticalPath() []*Stage {
+	cost := map[*Stage]time.Duration{}
+	prev := map[*Stage]*Stage{}
+	var end *Stage
+	for _, s := range p.order {
+		var best *Stage
+		for _, in := range s.Inputs {
+			if best == nil || cost[in] > cost[best] {
+				best = in
+			}
+		}
+		cost[s] = s.weight()
+		if best != nil {
+			cost[s] += cost[best]
+			prev[s] = best
+		}
+		if end == nil || cost[s] >= cost[end] {
+			end = s
+		}
+	}
+	var path []*Stage
+	for s := end; s != nil; s = prev[s] {
+		path = append(path, s)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Skewed returns the stages whose skew is at least p.SkewRatio and whose
+// slowest shard computed for at least p.MinSkewCompute, in plan order.
+func (p *Plan) Skewed() []*Stage {
+	var res []*Stage
+	for _, s := range p.Stages {
+		if s.ComputeMax >= p.MinSkewCompute && s.Skew() >= p.SkewRatio {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// Spilled returns the stages that spilled shuffle output to disk, in plan
+// order.
+func (p *Plan) Spilled() []*Stage {
+	var res []*Stage
+	for _, s := range p.Stages {
+		if s.ShuffleOutputBytesSpilled > 0 {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// A RepeatedScan is a table that the plan reads in full more than once.
+type RepeatedScan struct {
+	// Table is the table read.
+	Table string
+
+	// Count is the number of unfiltered reads of the table.
+	Count int
+
+	// Stages are the stages that read the table, in plan order.
+	Stages []*Stage
+}
+
+// RepeatedScans returns the tables that are read without a filter by more than
+// one step of the plan, ordered by table name. Such scans can often be
+// replaced by a single scan in a common table expression that is materialized,
+// or by a temporary table.
+func (p *Plan) RepeatedScans() []*RepeatedScan {
+	byTable := map[string]*RepeatedScan{}
+	for _, s := range p.Stages {
+		for _, sc := range s.Scans() {
+			if sc.Filtered {
+				continue
+			}
+			rs := byTable[sc.Table]
+			if rs == nil {
+				rs = &RepeatedScan{Table: sc.Table}
+				byTable[sc.Table] = rs
+			}
+			rs.Count++
+			if n := len(rs.Stages); n == 0 || rs.Stages[n-1] != s {
+				rs.Stages = append(rs.Stages, s)
+			}
+		}
+	}
+	var res []*RepeatedScan
+	for _, rs := range byTable {
+		if rs.Count > 1 {
+			res = append(res, rs)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Table < res[j].Table })
+	return res
+}
+
+// FindingKind is the kind of a Finding.
+type FindingKind int
+
+const (
+	// SkewedStage means that the slowest shard of a stage computed for much
+	// longer than the average shard.
+	SkewedStage FindingKind = iota
+
+	// SpilledShuffle means that a stage spilled shuffle output to disk.
+	SpilledShuffle
+
+	// RepeatedFullScan means that a table was read without a filter more than
+	// once.
+	RepeatedFullScan
+)
+
+// String returns a short description of the kind.
+func (k FindingKind) String() string {
+	switch k {
+	case SkewedStage:
+		return "skewed stage"
+	case SpilledShuffle:
+		return "spilled shuffle"
+	case RepeatedFullScan:
+		return "repeated full scan"
+	}
+	return fmt.Sprintf("FindingKind(%d)", int(k))
+}
+
+// A Finding is a potential problem in a query plan.
+type Finding struct {
+	Kind FindingKind
+
+	// Stages are the stages concerned.
+	Stages []*Stage
+
+	// Table is the table scanned repeatedly, for a RepeatedFullScan.
+	Table string
+
+	// Message describes the finding.
+	Message string
+}
+
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+}
+
+// Findings returns the potential problems in the plan: skewed stages, spilled
+// shuffles and repeated full scans, in that order. An empty result means that
+// nothing was found, not that the query is cheap.
+func (p *Plan) Findings() []*Finding {
+	var fs []*Finding
+	for _, s := range p.Skewed() {
+		fs = append(fs, &Finding{
+			Kind:   SkewedStage,
+			Stages: []*Stage{s},
+			Message: fmt.Sprintf("%s: slowest shard computed for %s, %.1fx the average of %s",
+				s.Label(), s.ComputeMax, s.Skew(), s.ComputeAvg),
+		})
+	}
+	for _, s := range p.Spilled() {
+		fs = append(fs, &Finding{
+			Kind:   SpilledShuffle,
+			Stages: []*Stage{s},
+			Message: fmt.Sprintf("%s: spilled %s of %s shuffle output to disk",
+				s.Label(), formatBytes(s.ShuffleOutputBytesSpilled), formatBytes(s.ShuffleOutputBytes)),
+		})
+	}
+	for _, rs := range p.RepeatedScans() {
+		labels := make([]string, len(rs.Stages))
+		for i, s := range rs.Stages {
+			labels[i] = s.Label()
+		}
+		fs = append(fs, &Finding{
+			Kind:   RepeatedFullScan,
+			Stages: rs.Stages,
+			Table:  rs.Table,
+			Message: fmt.Sprintf("%s read without a filter %d times, by %s",
+				rs.Table, rs.Count, strings.Join(labels, ", ")),
+		})
+	}
+	return fs
+}
+
+// formatBytes formats n using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
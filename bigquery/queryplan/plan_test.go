@@ -0,0 +1,293 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queryplan
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/bqtest"
+	"cloud.google.com/go/internal/testutil"
+)
+
+var t0 = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func stage(id int64, name string, start, end time.Duration, inputs ...int64) *bigquery.ExplainQueryStage {
+	return &bigquery.ExplainQueryStage{
+		ID:          id,
+		Name:        name,
+		InputStages: inputs,
+		StartTime:   t0.Add(start),
+		EndTime:     t0.Add(end),
+		ComputeAvg:  100 * time.Millisecond,
+		ComputeMax:  120 * time.Millisecond,
+	}
+}
+
+func read(table string, filter bool) *bigquery.ExplainQueryStep {
+	step := &bigquery.ExplainQueryStep{Kind: "READ", Substeps: []string{"$1:id", "FROM " + table}}
+	if filter {
+		step.Substeps = append(step.Substeps, "WHERE greater($1, 10)")
+	}
+	return step
+}
+
+// testStats returns the statistics of a job that joins two scans of orders,
+// aggregates the result with a filtered scan of customers, and writes it out.
+func testStats() *bigquery.JobStatistics {
+	s0 := stage(0, "S00: Input", 0, 2*time.Second)
+	s0.ComputeMax = 1500 * time.Millisecond
+	s0.SlotMillis = 4000
+	s0.RecordsRead = 1000
+	s0.RecordsWritten = 1000
+	s0.ShuffleOutputBytes = 2048
+	s0.Steps = []*bigquery.ExplainQueryStep{read("p.d.orders", false)}
+	s1 := stage(1, "S01: Input", 0, time.Second)
+	s1.RecordsWritten = 10
+	s1.Steps = []*bigquery.ExplainQueryStep{read("p.d.orders", false)}
+	s2 := stage(2, "S02: Input", 0, 500*time.Millisecond)
+	s2.RecordsWritten = 5
+	s2.Steps = []*bigquery.ExplainQueryStep{read("p.d.customers", true)}
+	s3 := stage(3, "S03: Join+", 2*time.Second, 3*time.Second, 0, 1)
+	s3.ShuffleOutputBytes = 3 << 30
+	s3.ShuffleOutputBytesSpilled = 1 << 30
+	s3.RecordsWritten = 100
+	s3.Steps = []*bigquery.ExplainQueryStep{read("__stage00_output", false), read("__stage01_output", false)}
+	s4 := stage(4, "S04: Aggregate", 3*time.Second, 3500*time.Millisecond, 3, 2)
+	s4.RecordsWritten = 2
+	s5 := stage(5, "S05: Output", 3500*time.Millisecond, 3600*time.Millisecond, 4)
+	return &bigquery.JobStatistics{
+		TotalBytesProcessed: 10 << 20,
+		Details: &bigquery.QueryStatistics{
+			SlotMillis:       9000,
+			TotalBytesBilled: 10 << 20,
+			QueryPlan:        []*bigquery.ExplainQueryStage{s0, s1, s2, s3, s4, s5},
+			Timeline:         []*bigquery.QueryTimelineSample{{Elapsed: 4 * time.Second, SlotMillis: 9000}},
+		},
+	}
+}
+
+func labels(stages []*Stage) []string {
+	var res []string
+	for _, s := range stages {
+		res = append(res, s.Label())
+	}
+	return res
+}
+
+func TestAnalyze(t *testing.T) {
+	p, err := Analyze(testStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labels(p.Roots()), []string{"S05: Output"}; !testutil.Equal(got, want) {
+		t.Errorf("Roots: got %v, want %v", got, want)
+	}
+	if got, want := labels(p.Stage(4).Inputs), []string{"S03: Join+", "S02: Input"}; !testutil.Equal(got, want) {
+		t.Errorf("Inputs: got %v, want %v", got, want)
+	}
+	if got, want := labels(p.Stage(2).Outputs), []string{"S04: Aggregate"}; !testutil.Equal(got, want) {
+		t.Errorf("Outputs: got %v, want %v", got, want)
+	}
+	if got, want := labels(p.CriticalPath()), []string{"S00: Input", "S03: Join+", "S04: Aggregate", "S05: Output"}; !testutil.Equal(got, want) {
+		t.Errorf("CriticalPath: got %v, want %v", got, want)
+	}
+	if got, want := p.Stage(0).Skew(), 15.0; got != want {
+		t.Errorf("Skew: got %v, want %v", got, want)
+	}
+	if got, want := labels(p.Skewed()), []string{"S00: Input"}; !testutil.Equal(got, want) {
+		t.Errorf("Skewed: got %v, want %v", got, want)
+	}
+	p.MinSkewCompute = 2 * time.Second
+	if got := p.Skewed(); len(got) != 0 {
+		t.Errorf("Skewed with a higher MinSkewCompute: got %v, want none", labels(got))
+	}
+	p.MinSkewCompute = DefaultMinSkewCompute
+	if got, want := labels(p.Spilled()), []string{"S03: Join+"}; !testutil.Equal(got, want) {
+		t.Errorf("Spilled: got %v, want %v", got, want)
+	}
+	rs := p.RepeatedScans()
+	if len(rs) != 1 || rs[0].Table != "p.d.orders" || rs[0].Count != 2 {
+		t.Fatalf("RepeatedScans: got %+v, want one of p.d.orders", rs)
+	}
+	if got, want := labels(rs[0].Stages), []string{"S00: Input", "S01: Input"}; !testutil.Equal(got, want) {
+		t.Errorf("RepeatedScans stages: got %v, want %v", got, want)
+	}
+
+	var kinds []FindingKind
+	for _, f := range p.Findings() {
+		kinds = append(kinds, f.Kind)
+	}
+	if want := []FindingKind{SkewedStage, SpilledShuffle, RepeatedFullScan}; !testutil.Equal(kinds, want) {
+		t.Errorf("Findings: got %v, want %v", kinds, want)
+	}
+}
+
+func TestCriticalPathWithoutTimes(t *testing.T) {
+	// Stages of a running job have no end time; their shard timings are used.
+	a := &bigquery.ExplainQueryStage{ID: 0, ComputeMax: time.Second}
+	b := &bigquery.ExplainQueryStage{ID: 1, ReadMax: 3 * time.Second}
+	c := &bigquery.ExplainQueryStage{ID: 2, InputStages: []int64{0, 1}, WriteMax: time.Second}
+	p, err := Analyze(&bigquery.JobStatistics{Details: &bigquery.QueryStatistics{
+		QueryPlan: []*bigquery.ExplainQueryStage{a, b, c},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := labels(p.CriticalPath()), []string{"S01", "S02"}; !testutil.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	for _, test := range []struct {
+		desc  string
+		stats *bigquery.JobStatistics
+	}{
+		{"nil", nil},
+		{"load job", &bigquery.JobStatistics{Details: &bigquery.LoadStatistics{}}},
+		{"unknown input", &bigquery.JobStatistics{Details: &bigquery.QueryStatistics{
+			QueryPlan: []*bigquery.ExplainQueryStage{{ID: 1, InputStages: []int64{7}}},
+		}}},
+		{"duplicate ID", &bigquery.JobStatistics{Details: &bigquery.QueryStatistics{
+			QueryPlan: []*bigquery.ExplainQueryStage{{ID: 1}, {ID: 1}},
+		}}},
+		{"cycle", &bigquery.JobStatistics{Details: &bigquery.QueryStatistics{
+			QueryPlan: []*bigquery.ExplainQueryStage{
+				{ID: 1, InputStages: []int64{2}},
+				{ID: 2, InputStages: []int64{1}},
+			},
+		}}},
+	} {
+		if _, err := Analyze(test.stats); err == nil {
+			t.Errorf("%s: got nil, want error", test.desc)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	p, err := Analyze(testStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `query plan: 6 stages, 9000 slot-ms, 10.0 MiB processed, 10.0 MiB billed, 4s elapsed
+* S05: Output  [100ms, skew 1.2x]
+└── * S04: Aggregate  [500ms, 0 rows in, 2 out, skew 1.2x]
+    ├── * S03: Join+  [1s, 0 rows in, 100 out, skew 1.2x, 3.0 GiB shuffled, 1.0 GiB spilled]
+    │   ├── * S00: Input  [2s, 4000 slot-ms, 1000 rows in, 1000 out, skew 15.0x, 2.0 KiB shuffled, reads p.d.orders]
+    │   └── S01: Input  [1s, 0 rows in, 10 out, skew 1.2x, reads p.d.orders]
+    └── S02: Input  [500ms, 0 rows in, 5 out, skew 1.2x, reads p.d.customers (filtered)]
+critical path: S00: Input -> S03: Join+ -> S04: Aggregate -> S05: Output (3.6s)
+findings:
+  skewed stage: S00: Input: slowest shard computed for 1.5s, 15.0x the average of 100ms
+  spilled shuffle: S03: Join+: spilled 1.0 GiB of 3.0 GiB shuffle output to disk
+  repeated full scan: p.d.orders read without a filter 2 times, by S00: Input, S01: Input
+`
+	if diff := testutil.Diff(buf.String(), want); diff != "" {
+		t.Errorf("got=-, want=+:\n%s", diff)
+	}
+}
+
+func TestWriteTextSharedStage(t *testing.T) {
+	p, err := Analyze(&bigquery.JobStatistics{Details: &bigquery.QueryStatistics{
+		QueryPlan: []*bigquery.ExplainQueryStage{
+			{ID: 0, Name: "S00: Input"},
+			{ID: 1, Name: "S01: Join", InputStages: []int64{0, 0}},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `query plan: 2 stages
+* S01: Join
+├── * S00: Input
+└── * S00: Input (see above)
+critical path: S00: Input -> S01: Join (0s)
+`
+	if diff := testutil.Diff(buf.String(), want); diff != "" {
+		t.Errorf("got=-, want=+:\n%s", diff)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	p, err := Analyze(testStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"digraph queryplan {\n",
+		`"s0" [label="S00: Input\n2s\n4000 slot-ms\n1000 rows in, 1000 out\nskew 15.0x\n2.0 KiB shuffled\nreads p.d.orders", color=red, penwidth=2, style=filled, fillcolor="#fde0dd"];`,
+		`"s2" [label="S02: Input\n500ms\n0 rows in, 5 out\nskew 1.2x\nreads p.d.customers (filtered)"];`,
+		`"s0" -> "s3" [label="1000 rows", color=red, penwidth=2];`,
+		`"s1" -> "s3" [label="10 rows"];`,
+		`"s2" -> "s4" [label="5 rows"];`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "->") != 5 {
+		t.Errorf("got %d edges, want 5:\n%s", strings.Count(got, "->"), got)
+	}
+}
+
+func TestAnalyzeJobDryRun(t *testing.T) {
+	ctx := context.Background()
+	srv := bqtest.NewServer()
+	defer srv.Close()
+	client, err := srv.NewClient(ctx, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	q := client.Query("SELECT 1 AS x")
+	q.DryRun = true
+	job, err := q.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AnalyzeJob(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Stages) != 0 || p.CriticalPath() != nil || len(p.Findings()) != 0 {
+		t.Errorf("got %d stages, want none", len(p.Stages))
+	}
+	var buf bytes.Buffer
+	if err := p.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "query plan: no stages\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queryplan
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText writes a textual summary of the plan to w: the job-level
+// statistics, the stages as a tree rooted at the final stage with the critical
+// path marked by "*", the critical path itself and the findings.
+//
+// A stage that is read by several others is written in full only the first
+// time it appears in the tree.
+func (p *Plan) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString(p.summary())
+	buf.WriteByte('\n')
+
+	critical := p.criticalSet()
+	seen := map[*Stage]bool{}
+	var walk func(s *Stage, prefix, branch, indent string)
+	walk = func(s *Stage, prefix, branch, indent string) {
+		mark := ""
+		if critical[s] {
+			mark = "* "
+		}
+		if seen[s] {
+			fmt.Fprintf(&buf, "%s%s%s%s (see above)\n", prefix, branch, mark, s.Label())
+			return
+		}
+		seen[s] = true
+		fmt.Fprintf(&buf, "%s%s%s%s", prefix, branch, mark, s.Label())
+		if d := p.details(s); len(d) > 0 {
+			fmt.Fprintf(&buf, "  [%s]", strings.Join(d, ", "))
+		}
+		buf.WriteByte('\n')
+		for i, in := range s.Inputs {
+			if i == len(s.Inputs)-1 {
+				walk(in, prefix+indent, "└── ", "    ")
+			} else {
+				walk(in, prefix+indent, "├── ", "│   ")
+			}
+		}
+	}
+	for _, r := range p.Roots() {
+		walk(r, "", "", "")
+	}
+
+	if path := p.CriticalPath(); len(path) > 0 {
+		labels := make([]string, len(path))
+		var total time.Duration
+		for i, s := range path {
+			labels[i] = s.Label()
+			total += s.weight()
+		}
+		fmt.Fprintf(&buf, "critical path: %s (%s)\n", strings.Join(labels, " -> "), total)
+	}
+	if fs := p.Findings(); len(fs) > 0 {
+		buf.WriteString("findings:\n")
+		for _, f := range fs {
+			fmt.Fprintf(&buf, "  %s\n", f)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteDOT writes the plan to w as a Graphviz DOT digraph. Edges point in the
+// direction data flows, from a stage to the stages that read its output.
+// Stages and edges on the critical path are drawn in red, and stages with
+// findings are filled.
+func (p *Plan) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+	critical := p.criticalSet()
+	flagged := map[*Stage]bool{}
+	for _, f := range p.Findings() {
+		for _, s := range f.Stages {
+			flagged[s] = true
+		}
+	}
+
+	buf.WriteString("digraph queryplan {\n")
+	fmt.Fprintf(&buf, "\tlabel=%s;\n", dotQuote(p.summary()))
+	buf.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for _, s := range p.Stages {
+		lines := append([]string{s.Label()}, p.details(s)...)
+		attrs := []string{"label=" + dotQuote(strings.Join(lines, "\n"))}
+		if critical[s] {
+			attrs = append(attrs, "color=red", "penwidth=2")
+		}
+		if flagged[s] {
+			attrs = append(attrs, "style=filled", `fillcolor="#fde0dd"`)
+		}
+		fmt.Fprintf(&buf, "\t%s [%s];\n", dotID(s), strings.Join(attrs, ", "))
+	}
+	path := p.CriticalPath()
+	onPath := map[[2]*Stage]bool{}
+	for i := 1; i < len(path); i++ {
+		onPath[[2]*Stage{path[i-1], path[i]}] = true
+	}
+	for _, s := range p.Stages {
+		for _, in := range s.Inputs {
+			var attrs []string
+			if in.RecordsWritten > 0 {
+				attrs = append(attrs, "label="+dotQuote(fmt.Sprintf("%d rows", in.RecordsWritten)))
+			}
+			if onPath[[2]*Stage{in, s}] {
+				attrs = append(attrs, "color=red", "penwidth=2")
+			}
+			fmt.Fprintf(&buf, "\t%s -> %s", dotID(in), dotID(s))
+			if len(attrs) > 0 {
+				fmt.Fprintf(&buf, " [%s]", strings.Join(attrs, ", "))
+			}
+			buf.WriteString(";\n")
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// summary describes the job-level statistics in one line.
+func (p *Plan) summary() string {
+	parts := []string{fmt.Sprintf("%d stages", len(p.Stages))}
+	if len(p.Stages) == 0 {
+		parts[0] = "no stages"
+	}
+	qs := p.QueryStatistics
+	if qs.CacheHit {
+		parts = append(parts, "cache hit")
+	}
+	if qs.SlotMillis > 0 {
+		parts = append(parts, fmt.Sprintf("%d slot-ms", qs.SlotMillis))
+	}
+	if n := p.JobStatistics.TotalBytesProcessed; n > 0 {
+		parts = append(parts, formatBytes(n)+" processed")
+	}
+	if qs.TotalBytesBilled > 0 {
+		parts = append(parts, formatBytes(qs.TotalBytesBilled)+" billed")
+	}
+	if n := len(qs.Timeline); n > 0 && qs.Timeline[n-1] != nil {
+		parts = append(parts, fmt.Sprintf("%s elapsed", qs.Timeline[n-1].Elapsed))
+	}
+	return "query plan: " + strings.Join(parts, ", ")
+}
+
+// details describes the statistics and findings of a stage as short phrases.
+func (p *Plan) details(s *Stage) []string {
+	var d []string
+	if dur := s.Duration(); dur > 0 {
+		d = append(d, dur.String())
+	}
+	if s.SlotMillis > 0 {
+		d = append(d, fmt.Sprintf("%d slot-ms", s.SlotMillis))
+	}
+	if s.RecordsRead > 0 || s.RecordsWritten > 0 {
+		d = append(d, fmt.Sprintf("%d rows in, %d out", s.RecordsRead, s.RecordsWritten))
+	}
+	if sk := s.Skew(); sk > 0 {
+		d = append(d, fmt.Sprintf("skew %.1fx", sk))
+	}
+	if s.ShuffleOutputBytes > 0 {
+		d = append(d, formatBytes(s.ShuffleOutputBytes)+" shuffled")
+	}
+	if s.ShuffleOutputBytesSpilled > 0 {
+		d = append(d, formatBytes(s.ShuffleOutputBytesSpilled)+" spilled")
+	}
+	for _, sc := range s.Scans() {
+		if sc.Filtered {
+			d = append(d, "reads "+sc.Table+" (filtered)")
+		} else {
+			d = append(d, "reads "+sc.Table)
+		}
+	}
+	return d
+}
+
+func (p *Plan) criticalSet() map[*Stage]bool {
+	m := map[*Stage]bool{}
+	for _, s := range p.CriticalPath() {
+		m[s] = true
+	}
+	return m
+}
+
+func dotID(s *Stage) string {
+	return fmt.Sprintf(`"s%d"`, s.ID)
+}
+
+// dotQuote returns s as a DOT string, with newlines turned into centered line
+// breaks.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
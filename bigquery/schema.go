@@ -393,13 +393,11 @@ var typeOfByteSlice = reflect.TypeOf([]byte{})
 // A Go slice or array type is inferred to be a BigQuery repeated field of the
 // element type. The element type must be one of the above listed types.
 //
-// Due to lack of unique native Go type for GEOGRAPHY, there is no schema
-// inference to GEOGRAPHY at this time.
-//
 // This package also provides some value types for expressing the corresponding SQL types.
 //
 // INTERVAL		*IntervalValue
 // RANGE    	*RangeValue
+// GEOGRAPHY	*Geography
 //
 // In the case of RANGE types, a RANGE represents a continuous set of values of a given
 // element type (DATE, DATETIME, or TIMESTAMP).  InferSchema does not attempt to determine
@@ -419,6 +417,7 @@ var typeOfByteSlice = reflect.TypeOf([]byte{})
 //
 // For a nullable BYTES field, use the type []byte and tag the field "nullable" (see below).
 // For a nullable NUMERIC field, use the type *big.Rat and tag the field "nullable".
+// Likewise, tag a *Geography field "nullable" for a nullable GEOGRAPHY field.
 //
 // A struct field that is of struct type is inferred to be a required field of type
 // RECORD with a schema inferred recursively. For backwards compatibility, a field of
@@ -542,6 +541,8 @@ func inferFieldSchema(fieldName string, rt reflect.Type, nullable, json bool) (*
 		return &FieldSchema{Required: !nullable, Type: NumericFieldType}, nil
 	case typeOfIntervalValue:
 		return &FieldSchema{Required: !nullable, Type: IntervalFieldType}, nil
+	case typeOfGeography:
+		return &FieldSchema{Required: !nullable, Type: GeographyFieldType}, nil
 	case typeOfRangeValue:
 		// We can't fully infer the element type of a range without additional
 		// information, and don't set the RangeElementType when inferred.
@@ -0,0 +1,314 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SchemaChangeKind is the kind of a difference between the schema of a table
+// and a desired schema.
+type SchemaChangeKind int
+
+const (
+	// FieldAdded means that a field of the desired schema is missing from the
+	// table. It is an additive change. Since existing rows have no value for
+	// the field, it is added as NULLABLE if it is REQUIRED in the desired
+	// schema, and so are the REQUIRED fields nested in it.
+	FieldAdded SchemaChangeKind = iota
+	// FieldRelaxed means that a REQUIRED field of the table is NULLABLE in the
+	// desired schema. It is an additive change.
+	FieldRelaxed
+	// FieldRemoved means that a field of the table is missing from the desired
+	// schema. It is a breaking change.
+	FieldRemoved
+	// FieldTypeChanged means that a field has a different type in the desired
+	// schema, or that a RANGE field has a different element type. It is a
+	// breaking change.
+	FieldTypeChanged
+	// FieldModeChanged means that a field is REPEATED in only one of the
+	// schemas. It is a breaking change. A NULLABLE field of the table that is
+	// REQUIRED in the desired schema is not a change, since the table accepts
+	// all values of the desired field.
+	FieldModeChanged
+)
+
+var schemaChangeKindNames = map[SchemaChangeKind]string{
+	FieldAdded:       "FieldAdded",
+	FieldRelaxed:     "FieldRelaxed",
+	FieldRemoved:     "FieldRemoved",
+	FieldTypeChanged: "FieldTypeChanged",
+	FieldModeChanged: "FieldModeChanged",
+}
+
+func (k SchemaChangeKind) String() string {
+	if s, ok := schemaChangeKindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("SchemaChangeKind(%d)", int(k))
+}
+
+// Breaking reports whether changes of the kind cannot be applied to a table
+// with Table.Update.
+func (k SchemaChangeKind) Breaking() bool {
+	return k != FieldAdded && k != FieldRelaxed
+}
+
+// SchemaChange describes a difference between the schema of a table and a
+// desired schema.
+type SchemaChange struct {
+	Kind SchemaChangeKind
+
+	// Path is the name of the field, preceded by the names of the RECORD
+	// fields that contain it and dots, such as "address.zip". Names are those
+	// of the table, or of the desired schema for added fields.
+	Path string
+
+	// Old is the field of the table. It is nil for added fields.
+	Old *FieldSchema
+
+	// New is the field of the desired schema. It is nil for removed fields.
+	New *FieldSchema
+}
+
+func (c *SchemaChange) String() string {
+	switch c.Kind {
+	case FieldAdded:
+		return fmt.Sprintf("field %s added", c.Path)
+	case FieldRemoved:
+		return fmt.Sprintf("field %s removed", c.Path)
+	case FieldTypeChanged:
+		return fmt.Sprintf("field %s changed type from %s to %s", c.Path, fieldTypeString(c.Old), fieldTypeString(c.New))
+	default:
+		return fmt.Sprintf("field %s changed mode from %s to %s", c.Path, fieldMode(c.Old), fieldMode(c.New))
+	}
+}
+
+// SchemaPlan holds the differences between the schema of a table and a
+// desired schema, and the schema that results from applying those that are
+// additive.
+type SchemaPlan struct {
+	// Changes holds the differences between the schemas, in the order of the
+	// table's fields, with added fields following those of the table.
+	Changes []*SchemaChange
+
+	// Schema is the schema of the table with the additive changes applied:
+	// REQUIRED fields are relaxed, and new fields are appended to the
+	// top-level schema or to the schema of their RECORD, as NULLABLE if they
+	// are REQUIRED in the desired schema. Fields affected by breaking changes
+	// are left as they are.
+	Schema Schema
+
+	// ETag is the ETag of the table metadata the plan was made from, if it
+	// was made by Table.PlanSchemaUpdate.
+	ETag string
+}
+
+// PlanSchemaUpdate compares the schema of a table, current, with a desired
+// schema, such as one returned by InferSchema, and classifies their
+// differences as additive or breaking.
+//
+// Since InferSchema makes the fields of most Go types REQUIRED, REQUIRED
+// fields of the desired schema are compatible with NULLABLE fields of the
+// table, and are added to the table as NULLABLE fields.
+//
+// Fields are matched by name, ignoring case, and nested fields of RECORD
+// fields are compared recursively. Type aliases, such as INT64 and INTEGER,
+// are considered equal. Other attributes of fields, such as descriptions,
+// policy tags and default values, are not compared; the plan's schema keeps
+// those of the table.
+func PlanSchemaUpdate(current, desired Schema) *SchemaPlan {
+	p := &SchemaPlan{}
+	p.Schema = p.plan("", current, desired)
+	return p
+}
+
+func (p *SchemaPlan) plan(prefix string, current, desired Schema) Schema {
+	byName := make(map[string]*FieldSchema, len(desired))
+	for _, f := range desired {
+		if _, ok := byName[strings.ToLower(f.Name)]; !ok {
+			byName[strings.ToLower(f.Name)] = f
+		}
+	}
+	seen := make(map[string]bool, len(current))
+	out := make(Schema, 0, len(current))
+	for _, cur := range current {
+		key := strings.ToLower(cur.Name)
+		seen[key] = true
+		path := prefix + cur.Name
+		des, ok := byName[key]
+		if !ok {
+			p.add(FieldRemoved, path, cur, nil)
+			out = append(out, copyFieldSchema(cur))
+			continue
+		}
+		out = append(out, p.planField(path, cur, des))
+	}
+	for _, des := range desired {
+		key := strings.ToLower(des.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		p.add(FieldAdded, prefix+des.Name, nil, des)
+		out = append(out, relaxedFieldSchema(des))
+	}
+	return out
+}
+
+// planField compares a field of the table with the desired field of the same
+// name, and returns the field with the additive changes applied.
+func (p *SchemaPlan) planField(path string, cur, des *FieldSchema) *FieldSchema {
+	out := copyFieldSchema(cur)
+	if !sameFieldType(cur, des) {
+		p.add(FieldTypeChanged, path, cur, des)
+		return out
+	}
+	switch oldMode, newMode := fieldMode(cur), fieldMode(des); {
+	case oldMode == newMode, oldMode == "NULLABLE" && newMode == "REQUIRED":
+	case oldMode == "REQUIRED" && newMode == "NULLABLE":
+		p.add(FieldRelaxed, path, cur, des)
+		out.Required = false
+	default:
+		p.add(FieldModeChanged, path, cur, des)
+	}
+	if normalizeFieldType(out.Type) == RecordFieldType {
+		out.Schema = p.plan(path+".", cur.Schema, des.Schema)
+	}
+	return out
+}
+
+func (p *SchemaPlan) add(kind SchemaChangeKind, path string, from, to *FieldSchema) {
+	p.Changes = append(p.Changes, &SchemaChange{Kind: kind, Path: path, Old: from, New: to})
+}
+
+// Additive returns the changes that Update applies.
+func (p *SchemaPlan) Additive() []*SchemaChange {
+	var out []*SchemaChange
+	for _, c := range p.Changes {
+		if !c.Kind.Breaking() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Breaking returns the changes that cannot be applied to the table with
+// Table.Update, such as type changes and removed fields. They require the
+// table to be recreated or its data to be rewritten.
+func (p *SchemaPlan) Breaking() []*SchemaChange {
+	var out []*SchemaChange
+	for _, c := range p.Changes {
+		if c.Kind.Breaking() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Update returns the TableMetadataToUpdate that applies the additive changes
+// of the plan, and whether there are any. Pass it to Table.Update along with
+// the plan's ETag, so that the update fails if the table was modified after
+// the plan was made:
+//
+//	if tm, ok := plan.Update(); ok {
+//		_, err = table.Update(ctx, tm, plan.ETag)
+//	}
+func (p *SchemaPlan) Update() (TableMetadataToUpdate, bool) {
+	if len(p.Additive()) == 0 {
+		return TableMetadataToUpdate{}, false
+	}
+	return TableMetadataToUpdate{Schema: p.Schema}, true
+}
+
+// PlanSchemaUpdate fetches the table's metadata and compares its schema with
+// desired, as the PlanSchemaUpdate function does. The plan's ETag is set to
+// the ETag of the metadata.
+func (t *Table) PlanSchemaUpdate(ctx context.Context, desired Schema) (*SchemaPlan, error) {
+	md, err := t.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := PlanSchemaUpdate(md.Schema, desired)
+	p.ETag = md.ETag
+	return p, nil
+}
+
+// fieldMode returns the mode of a field: NULLABLE, REQUIRED or REPEATED.
+func fieldMode(fs *FieldSchema) string {
+	switch {
+	case fs.Repeated:
+		return "REPEATED"
+	case fs.Required:
+		return "REQUIRED"
+	}
+	return "NULLABLE"
+}
+
+func normalizeFieldType(t FieldType) FieldType {
+	t = FieldType(strings.ToUpper(string(t)))
+	if resolved, ok := fieldAliases[t]; ok {
+		return resolved
+	}
+	return t
+}
+
+func sameFieldType(a, b *FieldSchema) bool {
+	if normalizeFieldType(a.Type) != normalizeFieldType(b.Type) {
+		return false
+	}
+	if normalizeFieldType(a.Type) != RangeFieldType {
+		return true
+	}
+	if a.RangeElementType == nil || b.RangeElementType == nil {
+		return a.RangeElementType == b.RangeElementType
+	}
+	return normalizeFieldType(a.RangeElementType.Type) == normalizeFieldType(b.RangeElementType.Type)
+}
+
+func fieldTypeString(fs *FieldSchema) string {
+	if normalizeFieldType(fs.Type) == RangeFieldType && fs.RangeElementType != nil {
+		return fmt.Sprintf("RANGE<%s>", fs.RangeElementType.Type)
+	}
+	return string(fs.Type)
+}
+
+// relaxedFieldSchema returns a copy of fs in which fs and the fields nested in
+// it are NULLABLE rather than REQUIRED.
+func relaxedFieldSchema(fs *FieldSchema) *FieldSchema {
+	out := *fs
+	out.Required = false
+	if fs.Schema != nil {
+		out.Schema = make(Schema, len(fs.Schema))
+		for i, f := range fs.Schema {
+			out.Schema[i] = relaxedFieldSchema(f)
+		}
+	}
+	return &out
+}
+
+// copyFieldSchema returns a copy of fs that shares no nested schema with it.
+func copyFieldSchema(fs *FieldSchema) *FieldSchema {
+	out := *fs
+	if fs.Schema != nil {
+		out.Schema = make(Schema, len(fs.Schema))
+		for i, f := range fs.Schema {
+			out.Schema[i] = copyFieldSchema(f)
+		}
+	}
+	return &out
+}
@@ -0,0 +1,251 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"testing"
+
+	"cloud.google.com/go/internal/testutil"
+)
+
+func TestPlanSchemaUpdate(t *testing.T) {
+	type address struct {
+		Street string
+		Zip    NullInt64
+		Floor  NullInt64
+	}
+	type person struct {
+		Name    NullString
+		Age     NullInt64
+		Tags    []string
+		Address *address `bigquery:",nullable"`
+	}
+	desired, err := InferSchema(person{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := Schema{
+		{Name: "name", Type: StringFieldType, Required: true, Description: "kept"},
+		{Name: "Address", Type: "STRUCT", Schema: Schema{
+			{Name: "Street", Type: StringFieldType, Required: true},
+			{Name: "Zip", Type: "INT64"},
+		}},
+	}
+	p := PlanSchemaUpdate(current, desired)
+
+	var got []string
+	for _, c := range p.Changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"field name changed mode from REQUIRED to NULLABLE",
+		"field Address.Floor added",
+		"field Age added",
+		"field Tags added",
+	}
+	if diff := testutil.Diff(got, want); diff != "" {
+		t.Errorf("changes differ (-got +want):\n%s", diff)
+	}
+	if n := len(p.Breaking()); n != 0 {
+		t.Errorf("got %d breaking changes, want 0", n)
+	}
+	if p.Changes[0].Kind != FieldRelaxed {
+		t.Errorf("got kind %v, want FieldRelaxed", p.Changes[0].Kind)
+	}
+
+	wantSchema := Schema{
+		{Name: "name", Type: StringFieldType, Description: "kept"},
+		{Name: "Address", Type: "STRUCT", Schema: Schema{
+			{Name: "Street", Type: StringFieldType, Required: true},
+			{Name: "Zip", Type: "INT64"},
+			{Name: "Floor", Type: IntegerFieldType},
+		}},
+		{Name: "Age", Type: IntegerFieldType},
+		{Name: "Tags", Type: StringFieldType, Repeated: true},
+	}
+	tm, ok := p.Update()
+	if !ok {
+		t.Fatal("got no update, want one")
+	}
+	if diff := testutil.Diff(tm.Schema, wantSchema); diff != "" {
+		t.Errorf("schema differs (-got +want):\n%s", diff)
+	}
+	// The table's schema is not modified.
+	if current[0].Required != true || len(current[1].Schema) != 2 {
+		t.Errorf("current schema was modified: %v", current)
+	}
+
+	// No changes.
+	p = PlanSchemaUpdate(wantSchema, wantSchema)
+	if len(p.Changes) != 0 {
+		t.Errorf("got changes %v, want none", p.Changes)
+	}
+	if _, ok := p.Update(); ok {
+		t.Error("got an update for identical schemas, want none")
+	}
+}
+
+func TestPlanSchemaUpdateBreaking(t *testing.T) {
+	current := Schema{
+		{Name: "a", Type: StringFieldType},
+		{Name: "b", Type: IntegerFieldType},
+		{Name: "c", Type: IntegerFieldType, Repeated: true},
+		{Name: "r", Type: RecordFieldType, Schema: Schema{
+			{Name: "x", Type: StringFieldType},
+			{Name: "y", Type: StringFieldType},
+		}},
+		{Name: "rng", Type: RangeFieldType, RangeElementType: &RangeElementType{Type: DateFieldType}},
+	}
+	desired := Schema{
+		{Name: "A", Type: StringFieldType, Required: true},
+		{Name: "c", Type: IntegerFieldType},
+		{Name: "r", Type: RecordFieldType, Schema: Schema{
+			{Name: "x", Type: BytesFieldType},
+			{Name: "y", Type: StringFieldType},
+			{Name: "z", Type: StringFieldType, Required: true},
+		}},
+		{Name: "rng", Type: RangeFieldType, RangeElementType: &RangeElementType{Type: TimestampFieldType}},
+		{Name: "d", Type: StringFieldType},
+	}
+	p := PlanSchemaUpdate(current, desired)
+	type change struct {
+		Kind SchemaChangeKind
+		Path string
+	}
+	var got []change
+	for _, c := range p.Changes {
+		got = append(got, change{c.Kind, c.Path})
+	}
+	want := []change{
+		{FieldRemoved, "b"},
+		{FieldModeChanged, "c"},
+		{FieldTypeChanged, "r.x"},
+		{FieldAdded, "r.z"},
+		{FieldTypeChanged, "rng"},
+		{FieldAdded, "d"},
+	}
+	if diff := testutil.Diff(got, want); diff != "" {
+		t.Errorf("changes differ (-got +want):\n%s", diff)
+	}
+	if got, want := len(p.Breaking()), 4; got != want {
+		t.Errorf("got %d breaking changes, want %d", got, want)
+	}
+	if got, want := p.Changes[4].String(), "field rng changed type from RANGE<DATE> to RANGE<TIMESTAMP>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Only the new columns are applied.
+	tm, ok := p.Update()
+	if !ok {
+		t.Fatal("got no update, want one")
+	}
+	wantSchema := Schema{
+		current[0],
+		current[1],
+		current[2],
+		{Name: "r", Type: RecordFieldType, Schema: Schema{
+			{Name: "x", Type: StringFieldType},
+			{Name: "y", Type: StringFieldType},
+			{Name: "z", Type: StringFieldType},
+		}},
+		current[4],
+		{Name: "d", Type: StringFieldType},
+	}
+	if diff := testutil.Diff(tm.Schema, wantSchema); diff != "" {
+		t.Errorf("schema differs (-got +want):\n%s", diff)
+	}
+}
+
+func TestPlanSchemaUpdateInferredRequired(t *testing.T) {
+	// InferSchema makes fields of non-pointer Go types REQUIRED.
+	type point struct {
+		X, Y float64
+	}
+	type item struct {
+		Name  string
+		Count int
+		Size  NullFloat64
+		Loc   point
+	}
+	desired, err := InferSchema(item{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !desired[0].Required || !desired[3].Schema[0].Required {
+		t.Fatalf("InferSchema: got %v, want REQUIRED fields", desired)
+	}
+
+	// REQUIRED fields are compatible with the NULLABLE fields of a table.
+	current := Schema{
+		{Name: "Name", Type: StringFieldType},
+		{Name: "Count", Type: IntegerFieldType},
+		{Name: "Size", Type: FloatFieldType},
+		{Name: "Loc", Type: RecordFieldType, Schema: Schema{
+			{Name: "X", Type: FloatFieldType},
+			{Name: "Y", Type: FloatFieldType},
+		}},
+	}
+	p := PlanSchemaUpdate(current, desired)
+	if len(p.Changes) != 0 {
+		t.Errorf("got changes %v, want none", p.Changes)
+	}
+
+	// Missing REQUIRED fields, including nested ones, are added as NULLABLE.
+	current = Schema{
+		{Name: "Name", Type: StringFieldType},
+		{Name: "Loc", Type: RecordFieldType, Schema: Schema{
+			{Name: "X", Type: FloatFieldType},
+		}},
+	}
+	p = PlanSchemaUpdate(current, desired)
+	var got []string
+	for _, c := range p.Changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"field Loc.Y added",
+		"field Count added",
+		"field Size added",
+	}
+	if diff := testutil.Diff(got, want); diff != "" {
+		t.Errorf("changes differ (-got +want):\n%s", diff)
+	}
+	tm, ok := p.Update()
+	if !ok {
+		t.Fatal("got no update, want one")
+	}
+	wantSchema := Schema{
+		{Name: "Name", Type: StringFieldType},
+		{Name: "Loc", Type: RecordFieldType, Schema: Schema{
+			{Name: "X", Type: FloatFieldType},
+			{Name: "Y", Type: FloatFieldType},
+		}},
+		{Name: "Count", Type: IntegerFieldType},
+		{Name: "Size", Type: FloatFieldType},
+	}
+	if diff := testutil.Diff(tm.Schema, wantSchema); diff != "" {
+		t.Errorf("schema differs (-got +want):\n%s", diff)
+	}
+
+	// A new RECORD field is added with its REQUIRED fields relaxed.
+	p = PlanSchemaUpdate(current[:1], desired)
+	tm, _ = p.Update()
+	if loc := tm.Schema[len(tm.Schema)-1]; loc.Name != "Loc" || loc.Required || loc.Schema[0].Required || loc.Schema[1].Required {
+		t.Errorf("added RECORD: got %+v, want it and its fields NULLABLE", loc)
+	}
+	if !desired[3].Schema[0].Required {
+		t.Error("desired schema was modified")
+	}
+}
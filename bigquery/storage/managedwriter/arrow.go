@@ -0,0 +1,237 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+)
+
+// ipcEOSLength is the length of the end-of-stream marker of the Arrow IPC
+// stream format.
+const ipcEOSLength = 8
+
+// AppendArrowRecord sends the rows of an Arrow record batch to the service, and
+// returns an AppendResult for tracking them, like AppendRows does for
+// serialized protocol buffer messages. The request is subject to the same flow
+// control and retry behavior as AppendRows.
+//
+// The record is validated against the schema of the destination table, which
+// is fetched on the first call, with bigquery.ValidateArrowSchema. REQUIRED
+// columns must also hold no nulls. The record's schema is sent as the writer
+// schema of the stream, and no schema descriptor is needed; the
+// UpdateSchemaDescriptor AppendOption must not be used with this method.
+//
+// The size of the serialized record must be less than 10 MB.
+func (ms *ManagedStream) AppendArrowRecord(ctx context.Context, rec arrow.Record, opts ...AppendOption) (*AppendResult, error) {
+	// before we do anything, ensure the writer isn't closed.
+	ms.mu.Lock()
+	err := ms.err
+	ms.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.validateArrowRecord(ctx, rec); err != nil {
+		return nil, err
+	}
+	schema, batch, err := serializeArrowRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+	req := &storagepb.AppendRowsRequest{
+		Rows: &storagepb.AppendRowsRequest_ArrowRows{
+			ArrowRows: &storagepb.AppendRowsRequest_ArrowData{
+				Rows: &storagepb.ArrowRecordBatch{
+					SerializedRecordBatch: batch,
+				},
+			},
+		},
+	}
+	// The writer schema is part of the template, so that it's only sent when it changes.
+	tmpl := ms.curTemplate.revise(reviseArrowSchema(schema))
+	pw := newPendingWrite(ctx, ms, req, tmpl, ms.streamSettings.streamID, ms.streamSettings.TraceID)
+	for _, opt := range opts {
+		opt(pw)
+	}
+	return ms.appendPendingWrite(ctx, pw)
+}
+
+// validateArrowRecord checks rec against the destination table schema. If
+// the check fails with a previously fetched schema, the schema is fetched
+// again in case the table has evolved, but only once for each version of the
+// schema, so that invalid records don't each cost an RPC.
+func (ms *ManagedStream) validateArrowRecord(ctx context.Context, rec arrow.Record) error {
+	ms.mu.Lock()
+	schema, refetched := ms.tableSchema, ms.tableSchemaRefetched
+	ms.mu.Unlock()
+	cached := schema != nil
+	if !cached {
+		var err error
+		if schema, err = ms.fetchTableSchema(ctx); err != nil {
+			return err
+		}
+	}
+	err := checkArrowRecord(rec, schema)
+	if err != nil && cached && !refetched {
+		if schema, ferr := ms.fetchTableSchema(ctx); ferr == nil {
+			err = checkArrowRecord(rec, schema)
+		}
+	}
+	return err
+}
+
+// fetchTableSchema fetches and retains the destination table schema. If a
+// schema was already retained and is unchanged, it is marked as refetched.
+func (ms *ManagedStream) fetchTableSchema(ctx context.Context) (bigquery.Schema, error) {
+	info, err := ms.c.getWriteStream(ctx, ms.streamSettings.streamID, true)
+	if err != nil {
+		return nil, fmt.Errorf("fetching table schema for arrow validation: %w", err)
+	}
+	schema, err := adapt.StorageTableSchemaToBQSchema(info.GetTableSchema())
+	if err != nil {
+		return nil, err
+	}
+	ms.mu.Lock()
+	ms.tableSchemaRefetched = ms.tableSchema != nil && reflect.DeepEqual(ms.tableSchema, schema)
+	ms.tableSchema = schema
+	ms.mu.Unlock()
+	return schema, nil
+}
+
+// updateTableSchema replaces the retained table schema, if any, with an
+// updated schema reported in an append response.
+func (ms *ManagedStream) updateTableSchema(ts *storagepb.TableSchema) {
+	schema, err := adapt.StorageTableSchemaToBQSchema(ts)
+	if err != nil {
+		return
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.tableSchema != nil && !reflect.DeepEqual(ms.tableSchema, schema) {
+		ms.tableSchema = schema
+		ms.tableSchemaRefetched = false
+	}
+}
+
+// checkArrowRecord validates the schema of rec, and that REQUIRED columns
+// hold no nulls.
+func checkArrowRecord(rec arrow.Record, schema bigquery.Schema) error {
+	if err := bigquery.ValidateArrowSchema(rec.Schema(), schema); err != nil {
+		return err
+	}
+	return checkArrowNulls(rec.Schema().Fields(), rec.Columns(), nil, schema, "")
+}
+
+// checkArrowNulls checks that the columns of REQUIRED fields hold no nulls.
+// Only the rows for which checked is true are checked, or all rows if checked
+// is nil. The subfields of RECORD columns, including the elements of REPEATED
+// RECORD columns, are checked where the record is not null.
+func checkArrowNulls(fields []arrow.Field, cols []arrow.Array, checked []bool, schema bigquery.Schema, prefix string) error {
+	for i, f := range fields {
+		var fs *bigquery.FieldSchema
+		for _, s := range schema {
+			if strings.EqualFold(s.Name, f.Name) {
+				fs = s
+				break
+			}
+		}
+		if fs == nil {
+			continue
+		}
+		col := cols[i]
+		valid := make([]bool, col.Len())
+		nulls := 0
+		for r := range valid {
+			if checked == nil || checked[r] {
+				valid[r] = col.IsValid(r)
+				if !valid[r] {
+					nulls++
+				}
+			}
+		}
+		if fs.Required && nulls > 0 {
+			return fmt.Errorf("REQUIRED column %q has %d null values", prefix+fs.Name, nulls)
+		}
+		if fs.Type != bigquery.RecordFieldType {
+			continue
+		}
+		if l, ok := col.(*array.List); ok {
+			if l.Len() == 0 {
+				continue
+			}
+			// Check the elements of the lists in the valid rows.
+			first, _ := l.ValueOffsets(0)
+			_, last := l.ValueOffsets(l.Len() - 1)
+			elems := array.NewSlice(l.ListValues(), first, last)
+			defer elems.Release()
+			elemChecked := make([]bool, last-first)
+			for r := range valid {
+				start, end := l.ValueOffsets(r)
+				for k := start; k < end; k++ {
+					elemChecked[k-first] = valid[r]
+				}
+			}
+			col = elems
+			for r := range elemChecked {
+				elemChecked[r] = elemChecked[r] && col.IsValid(r)
+			}
+			valid = elemChecked
+		}
+		st, ok := col.(*array.Struct)
+		if !ok {
+			continue
+		}
+		nested := make([]arrow.Array, st.NumField())
+		for j := range nested {
+			nested[j] = st.Field(j)
+		}
+		if err := checkArrowNulls(st.DataType().(*arrow.StructType).Fields(), nested, valid, fs.Schema, prefix+fs.Name+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serializeArrowRecord returns the IPC serialized schema and record batch of
+// rec, as expected by the AppendRows API.
+func serializeArrowRecord(rec arrow.Record) (schema, batch []byte, err error) {
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(rec.Schema()))
+	if err := w.Close(); err != nil {
+		return nil, nil, fmt.Errorf("serializing arrow schema: %w", err)
+	}
+	// The stream holds the schema message, then the end-of-stream marker.
+	schemaLen := buf.Len() - ipcEOSLength
+	buf.Reset()
+	w = ipc.NewWriter(&buf, ipc.WithSchema(rec.Schema()))
+	if err := w.Write(rec); err != nil {
+		return nil, nil, fmt.Errorf("serializing arrow record: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, fmt.Errorf("serializing arrow record: %w", err)
+	}
+	b := buf.Bytes()
+	return b[:schemaLen], b[schemaLen : len(b)-ipcEOSLength], nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+var arrowTestSchema = bigquery.Schema{
+	{Name: "name", Type: bigquery.StringFieldType, Required: true},
+	{Name: "count", Type: bigquery.IntegerFieldType},
+}
+
+// buildArrowRecord builds a record of the names and counts, where a nil count
+// is null.
+func buildArrowRecord(t *testing.T, names []string, counts []*int64) arrow.Record {
+	t.Helper()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "count", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for _, n := range names {
+		if n == "" {
+			b.Field(0).AppendNull()
+		} else {
+			b.Field(0).(*array.StringBuilder).Append(n)
+		}
+	}
+	for _, c := range counts {
+		if c == nil {
+			b.Field(1).AppendNull()
+		} else {
+			b.Field(1).(*array.Int64Builder).Append(*c)
+		}
+	}
+	rec := b.NewRecord()
+	t.Cleanup(rec.Release)
+	return rec
+}
+
+func TestSerializeArrowRecord(t *testing.T) {
+	one := int64(1)
+	rec := buildArrowRecord(t, []string{"a", "b"}, []*int64{&one, nil})
+	schema, batch, err := serializeArrowRecord(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The schema and batch messages make up a stream, once terminated.
+	stream := append(append(append([]byte{}, schema...), batch...), 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0)
+	r, err := ipc.NewReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if !r.Next() {
+		t.Fatalf("no record read: %v", r.Err())
+	}
+	if got := r.Record(); !array.RecordEqual(got, rec) {
+		t.Errorf("got record %v, want %v", got, rec)
+	}
+	if r.Next() {
+		t.Error("read more than one record")
+	}
+}
+
+func TestCheckArrowRecord(t *testing.T) {
+	one := int64(1)
+	if err := checkArrowRecord(buildArrowRecord(t, []string{"a"}, []*int64{nil}), arrowTestSchema); err != nil {
+		t.Errorf("valid record: %v", err)
+	}
+	if err := checkArrowRecord(buildArrowRecord(t, []string{"a", ""}, []*int64{&one, &one}), arrowTestSchema); err == nil {
+		t.Error("null in REQUIRED column: got nil, want error")
+	}
+	if err := checkArrowRecord(buildArrowRecord(t, []string{"a"}, []*int64{&one}), bigquery.Schema{arrowTestSchema[0]}); err == nil {
+		t.Error("column missing from table: got nil, want error")
+	}
+}
+
+func TestCheckArrowNullsNested(t *testing.T) {
+	schema := bigquery.Schema{
+		{Name: "rec", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "x", Type: bigquery.IntegerFieldType, Required: true},
+		}},
+		{Name: "recs", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+			{Name: "x", Type: bigquery.IntegerFieldType, Required: true},
+		}},
+	}
+	elem := arrow.StructOf(arrow.Field{Name: "x", Type: arrow.PrimitiveTypes.Int64, Nullable: true})
+	as := arrow.NewSchema([]arrow.Field{
+		{Name: "rec", Type: elem, Nullable: true},
+		{Name: "recs", Type: arrow.ListOf(elem), Nullable: true},
+	}, nil)
+
+	// build builds a record of one row, where nil values are null.
+	build := func(rec *int64, recNull bool, recs []*int64) arrow.Record {
+		b := array.NewRecordBuilder(memory.DefaultAllocator, as)
+		defer b.Release()
+		appendStruct := func(sb *array.StructBuilder, x *int64, null bool) {
+			if null {
+				sb.AppendNull()
+				sb.FieldBuilder(0).(*array.Int64Builder).AppendNull()
+				return
+			}
+			sb.Append(true)
+			if x == nil {
+				sb.FieldBuilder(0).(*array.Int64Builder).AppendNull()
+			} else {
+				sb.FieldBuilder(0).(*array.Int64Builder).Append(*x)
+			}
+		}
+		appendStruct(b.Field(0).(*array.StructBuilder), rec, recNull)
+		lb := b.Field(1).(*array.ListBuilder)
+		lb.Append(true)
+		for _, x := range recs {
+			appendStruct(lb.ValueBuilder().(*array.StructBuilder), x, false)
+		}
+		r := b.NewRecord()
+		t.Cleanup(r.Release)
+		return r
+	}
+
+	one := int64(1)
+	for _, test := range []struct {
+		desc    string
+		rec     arrow.Record
+		wantErr bool
+	}{
+		{"valid", build(&one, false, []*int64{&one, &one}), false},
+		{"null record", build(nil, true, nil), false},
+		{"null subfield", build(nil, false, nil), true},
+		{"null subfield of repeated record", build(&one, false, []*int64{&one, nil}), true},
+	} {
+		if err := checkArrowRecord(test.rec, schema); (err != nil) != test.wantErr {
+			t.Errorf("%s: got %v, want error %t", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestManagedStream_ArrowSchemaRefetch(t *testing.T) {
+	// The schema was already fetched again after a failure, so validation
+	// fails without an RPC; ms has no client to make one.
+	ms := &ManagedStream{tableSchema: bigquery.Schema{arrowTestSchema[0]}, tableSchemaRefetched: true}
+	one := int64(1)
+	rec := buildArrowRecord(t, []string{"a"}, []*int64{&one})
+	if err := ms.validateArrowRecord(context.Background(), rec); err == nil {
+		t.Fatal("got nil, want error")
+	}
+
+	// An updated schema from an append response starts a new version.
+	ms.updateTableSchema(&storagepb.TableSchema{Fields: []*storagepb.TableFieldSchema{
+		{Name: "name", Type: storagepb.TableFieldSchema_STRING, Mode: storagepb.TableFieldSchema_REQUIRED},
+		{Name: "count", Type: storagepb.TableFieldSchema_INT64, Mode: storagepb.TableFieldSchema_NULLABLE},
+	}})
+	if ms.tableSchemaRefetched {
+		t.Error("tableSchemaRefetched is still set after a schema update")
+	}
+	if err := ms.validateArrowRecord(context.Background(), rec); err != nil {
+		t.Errorf("with the updated schema: %v", err)
+	}
+}
+
+func TestManagedStream_AppendArrowRecord(t *testing.T) {
+	ctx := context.Background()
+	testARC := &testAppendRowsClient{}
+	pool := &connectionPool{
+		ctx:                ctx,
+		open:               openTestArc(testARC, nil, nil),
+		baseFlowController: newFlowController(0, 0),
+	}
+	if err := pool.activateRouter(newSimpleRouter(simplexConnectionMode)); err != nil {
+		t.Fatalf("activateRouter: %v", err)
+	}
+	ms := &ManagedStream{
+		id:             "foo",
+		ctx:            ctx,
+		streamSettings: defaultStreamSettings(),
+		curTemplate:    newVersionedTemplate(),
+		tableSchema:    arrowTestSchema,
+	}
+	if err := pool.addWriter(ms); err != nil {
+		t.Fatalf("addWriter: %v", err)
+	}
+	ms.streamSettings.streamID = "FOO"
+
+	one := int64(1)
+	for i := 0; i < 2; i++ {
+		if _, err := ms.AppendArrowRecord(ctx, buildArrowRecord(t, []string{"a"}, []*int64{&one}), WithOffset(int64(i))); err != nil {
+			t.Fatalf("AppendArrowRecord: %v", err)
+		}
+	}
+	if len(testARC.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(testARC.requests))
+	}
+	first, second := testARC.requests[0], testARC.requests[1]
+	if first.GetWriteStream() != "FOO" || len(first.GetArrowRows().GetWriterSchema().GetSerializedSchema()) == 0 {
+		t.Errorf("first request lacks stream or writer schema: %v", first)
+	}
+	if len(first.GetArrowRows().GetRows().GetSerializedRecordBatch()) == 0 {
+		t.Errorf("first request lacks rows: %v", first)
+	}
+	// The writer schema is unchanged, so the second request omits it.
+	if second.GetArrowRows().GetWriterSchema() != nil || second.GetOffset().GetValue() != 1 {
+		t.Errorf("second request: got %v", second)
+	}
+}
@@ -586,6 +586,9 @@ func connRecvProcessor(ctx context.Context, co *connection, arc storagepb.BigQue
 				continue
 			}
 			// We had no error in the receive or in the response.  Mark the write done.
+			if ts := resp.GetUpdatedSchema(); ts != nil && nextWrite.writer != nil {
+				nextWrite.writer.updateTableSchema(ts)
+			}
 			nextWrite.markDone(resp, nil)
 		}
 	}
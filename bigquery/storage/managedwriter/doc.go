@@ -139,6 +139,53 @@ an error.
 			// TODO: Handle error.
 		}
 
+# Writing Go Structs
+
+As an alternative to defining protocol buffer messages, a StructWriter derives the
+protocol buffer schema from a Go struct type, using the same rules as InferSchema in
+cloud.google.com/go/bigquery, and encodes values of that type when appending them:
+
+	type Item struct {
+		Name  string
+		Price *big.Rat
+		Added civil.Date
+	}
+
+	w, err := managedwriter.NewStructWriter[Item](ctx, client, nil,
+		managedwriter.WithDestinationTable(tableName))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	result, err := w.Append(ctx, []Item{{Name: "widget", Price: big.NewRat(199, 100)}})
+	if err != nil {
+		// TODO: Handle error.
+	}
+	if _, err := result.GetResult(ctx); err != nil {
+		// TODO: Handle error.
+	}
+
+For semi-structured data, a JSONWriter converts JSON objects using the destination
+table's schema, reports rows that fail conversion on its Errors channel, and follows
+schema updates, such as added columns, that appends report:
+
+	w, err := managedwriter.NewJSONWriter(ctx, client,
+		managedwriter.WithDestinationTable(tableName))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	go func() {
+		for rowErr := range w.Errors() {
+			log.Printf("skipped row: %v", rowErr)
+		}
+	}()
+	result, err := w.Append(ctx, []any{`{"name": "widget", "price": 1.99}`})
+
+Data that is already in Apache Arrow format can be appended without conversion using
+AppendArrowRecord, which validates each record against the destination table's schema
+and sends it as serialized Arrow data:
+
+	result, err := managedStream.AppendArrowRecord(ctx, record)
+
 # Buffered Stream Management
 
 For Buffered streams, users control when data is made visible in the destination table/stream
@@ -0,0 +1,594 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// defaultJSONErrorBuffer is the capacity of the channel returned by
+// JSONWriter.Errors.
+const defaultJSONErrorBuffer = 100
+
+// JSONRowError reports a row passed to JSONWriter.Append that could not be
+// converted to the table schema, and was not sent.
+type JSONRowError struct {
+	// Index is the index of the row in the rows passed to Append.
+	Index int
+	// Row is the row as passed to Append.
+	Row any
+	// Err is the conversion error.
+	Err error
+}
+
+func (e *JSONRowError) Error() string {
+	return fmt.Sprintf("managedwriter: row %d: %v", e.Index, e.Err)
+}
+
+func (e *JSONRowError) Unwrap() error {
+	return e.Err
+}
+
+// JSONWriter appends JSON rows to a ManagedStream, converting them to
+// protocol buffer messages using the schema of the destination table.
+//
+// When an append reports that the table schema was updated, such as when
+// columns were added, the writer regenerates its descriptor, and sends it
+// with the following appends, so that rows can use the new columns.
+//
+// Object keys are matched to columns by name, ignoring case. JSON values are
+// converted according to the type of their column:
+//
+//	STRING, GEOGRAPHY    string
+//	JSON                 any value; strings are sent as is, as JSON text
+//	INTEGER              integral number, or string
+//	FLOAT                number, or string, including "NaN" and "Infinity"
+//	BOOLEAN              bool, or string
+//	BYTES                base64 encoded string
+//	NUMERIC, BIGNUMERIC  number, or string
+//	TIMESTAMP            RFC 3339 string, or number of seconds since the epoch
+//	DATE                 string in the YYYY-MM-DD format
+//	TIME, DATETIME       string in the BigQuery canonical format
+//	RECORD               object
+//	RANGE                object with "start" and "end" keys, or string in
+//	                     the "[start, end)" format
+//
+// REPEATED columns take arrays, and null values are written as NULL.
+type JSONWriter struct {
+	ms     *ManagedStream
+	errors chan *JSONRowError
+
+	// done is closed by Close, to abandon the errors being sent, and sending
+	// counts those sends so that Close closes errors after them.
+	done    chan struct{}
+	sending sync.WaitGroup
+
+	mu sync.RWMutex
+	// closed is set by Close, after which no errors are sent.
+	closed bool
+	schema bigquery.Schema
+	desc   protoreflect.MessageDescriptor
+	dp     *descriptorpb.DescriptorProto
+	// version counts descriptor changes, and sentVersion is the version last
+	// sent with an append.
+	version     int
+	sentVersion int
+}
+
+// NewJSONWriter creates a ManagedStream with the given options, and returns
+// a JSONWriter that appends to it. The schema of the destination table is
+// fetched when the writer is created.
+//
+// The WithSchemaDescriptor option is set by NewJSONWriter and must not be
+// passed.
+func NewJSONWriter(ctx context.Context, c *Client, opts ...WriterOption) (*JSONWriter, error) {
+	ms, err := c.NewManagedStream(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := ms.fetchTableSchema(ctx)
+	if err != nil {
+		ms.Close()
+		return nil, err
+	}
+	w, err := newJSONWriter(ms, schema)
+	if err != nil {
+		ms.Close()
+		return nil, err
+	}
+	// Set the descriptor before the first append, as WithSchemaDescriptor does.
+	ms.curTemplate = ms.curTemplate.revise(reviseProtoSchema(w.dp))
+	return w, nil
+}
+
+func newJSONWriter(ms *ManagedStream, schema bigquery.Schema) (*JSONWriter, error) {
+	w := &JSONWriter{
+		ms:     ms,
+		errors: make(chan *JSONRowError, defaultJSONErrorBuffer),
+		done:   make(chan struct{}),
+	}
+	if err := w.setSchema(schema); err != nil {
+		return nil, err
+	}
+	w.sentVersion = w.version
+	return w, nil
+}
+
+// setSchema regenerates the descriptor for schema, if it differs from the
+// current one.
+func (w *JSONWriter) setSchema(schema bigquery.Schema) error {
+	desc, err := schemaDescriptor(schema)
+	if err != nil {
+		return err
+	}
+	dp, err := adapt.NormalizeDescriptor(desc)
+	if err != nil {
+		return fmt.Errorf("managedwriter: normalizing descriptor: %w", err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dp != nil && proto.Equal(w.dp, dp) {
+		return nil
+	}
+	w.schema, w.desc, w.dp = schema, desc, dp
+	w.version++
+	return nil
+}
+
+// Stream returns the ManagedStream the writer appends to.
+func (w *JSONWriter) Stream() *ManagedStream {
+	return w.ms
+}
+
+// Schema returns the table schema rows are currently converted with.
+func (w *JSONWriter) Schema() bigquery.Schema {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.schema
+}
+
+// Errors returns the channel on which rows that fail conversion are
+// reported. The channel is buffered, and must be drained: once it is full,
+// Append blocks until an error is received or its context is done. The
+// channel is closed by Close.
+func (w *JSONWriter) Errors() <-chan *JSONRowError {
+	return w.errors
+}
+
+// Append converts rows and appends those that convert successfully to the
+// stream. Each row is a map[string]any, or JSON text as a json.RawMessage,
+// []byte or string, holding an object. Rows that fail conversion are
+// reported on the Errors channel, rather than failing the append; if no row
+// converts, the returned AppendResult is already complete and holds no
+// offset.
+func (w *JSONWriter) Append(ctx context.Context, rows []any, opts ...AppendOption) (*AppendResult, error) {
+	w.mu.RLock()
+	desc, schema, dp, version := w.desc, w.schema, w.dp, w.version
+	w.mu.RUnlock()
+
+	data := make([][]byte, 0, len(rows))
+	for i, row := range rows {
+		b, err := encodeJSONRow(row, schema, desc)
+		if err != nil {
+			if err := w.reportError(ctx, &JSONRowError{Index: i, Row: row, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data = append(data, b)
+	}
+	if len(data) == 0 {
+		ar := newAppendResult()
+		close(ar.ready)
+		return ar, nil
+	}
+
+	w.mu.RLock()
+	changed := version > w.sentVersion
+	w.mu.RUnlock()
+	if changed {
+		opts = append([]AppendOption{UpdateSchemaDescriptor(dp)}, opts...)
+	}
+	res, err := w.ms.AppendRows(ctx, data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		w.mu.Lock()
+		if version > w.sentVersion {
+			w.sentVersion = version
+		}
+		w.mu.Unlock()
+	}
+	go w.watchSchema(res)
+	return res, nil
+}
+
+func (w *JSONWriter) reportError(ctx context.Context, e *JSONRowError) error {
+	w.mu.RLock()
+	closed := w.closed
+	if !closed {
+		w.sending.Add(1)
+	}
+	w.mu.RUnlock()
+	if closed {
+		return e
+	}
+	defer w.sending.Done()
+	select {
+	case w.errors <- e:
+		return nil
+	case <-w.done:
+		return e
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watchSchema waits for the result of an append, and adopts the updated
+// table schema it reports, if any.
+func (w *JSONWriter) watchSchema(res *AppendResult) {
+	ts, err := res.UpdatedSchema(w.ms.ctx)
+	if err != nil || ts == nil {
+		return
+	}
+	schema, err := adapt.StorageTableSchemaToBQSchema(ts)
+	if err != nil {
+		return
+	}
+	w.setSchema(schema)
+}
+
+// Close closes the underlying ManagedStream and the Errors channel.
+func (w *JSONWriter) Close() error {
+	err := w.ms.Close()
+	w.mu.Lock()
+	closed := w.closed
+	if !closed {
+		w.closed = true
+		close(w.done)
+	}
+	w.mu.Unlock()
+	if !closed {
+		w.sending.Wait()
+		close(w.errors)
+	}
+	return err
+}
+
+// encodeJSONRow converts a row to a serialized message of desc.
+func encodeJSONRow(row any, schema bigquery.Schema, desc protoreflect.MessageDescriptor) ([]byte, error) {
+	var obj map[string]any
+	switch r := row.(type) {
+	case map[string]any:
+		obj = r
+	case json.RawMessage:
+		return encodeJSONRow([]byte(r), schema, desc)
+	case string:
+		return encodeJSONRow([]byte(r), schema, desc)
+	case []byte:
+		d := json.NewDecoder(bytes.NewReader(r))
+		d.UseNumber()
+		if err := d.Decode(&obj); err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			return nil, fmt.Errorf("row is not a JSON object")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported row type %T", row)
+	}
+	msg := dynamicpb.NewMessage(desc)
+	if err := setJSONFields(msg, obj, schema); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// setJSONFields sets the fields of msg, whose descriptor was generated from
+// schema, from the members of obj.
+func setJSONFields(msg protoreflect.Message, obj map[string]any, schema bigquery.Schema) error {
+	fields := msg.Descriptor().Fields()
+	set := make(map[int]bool, len(obj))
+	for key, val := range obj {
+		idx := -1
+		for i, fs := range schema {
+			if strings.EqualFold(fs.Name, key) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("no column %q in the table schema", key)
+		}
+		if set[idx] {
+			return fmt.Errorf("column %q is set more than once", schema[idx].Name)
+		}
+		set[idx] = true
+		fs := schema[idx]
+		// The descriptor numbers fields in schema order, starting at 1.
+		fd := fields.ByNumber(protoreflect.FieldNumber(idx + 1))
+		if val == nil {
+			continue
+		}
+		if !fs.Repeated {
+			v, err := convertJSONValue(val, fs, fd)
+			if err != nil {
+				return fmt.Errorf("column %q: %w", fs.Name, err)
+			}
+			msg.Set(fd, v)
+			continue
+		}
+		elems, ok := val.([]any)
+		if !ok {
+			return fmt.Errorf("column %q: REPEATED column requires an array, got %T", fs.Name, val)
+		}
+		list := msg.Mutable(fd).List()
+		for i, e := range elems {
+			if e == nil {
+				return fmt.Errorf("column %q: element %d is null", fs.Name, i)
+			}
+			v, err := convertJSONValue(e, fs, fd)
+			if err != nil {
+				return fmt.Errorf("column %q: element %d: %w", fs.Name, i, err)
+			}
+			list.Append(v)
+		}
+	}
+	for i, fs := range schema {
+		if fs.Required && !msg.Has(fields.ByNumber(protoreflect.FieldNumber(i+1))) {
+			return fmt.Errorf("REQUIRED column %q is missing or null", fs.Name)
+		}
+	}
+	return nil
+}
+
+// convertJSONValue converts a non-null JSON value of the column fs.
+func convertJSONValue(val any, fs *bigquery.FieldSchema, fd protoreflect.FieldDescriptor) (protoreflect.Value, error) {
+	switch fs.Type {
+	case bigquery.RecordFieldType:
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("RECORD column requires an object, got %T", val)
+		}
+		msg := dynamicpb.NewMessage(fd.Message())
+		if err := setJSONFields(msg, obj, fs.Schema); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(msg), nil
+	case bigquery.RangeFieldType:
+		if fs.RangeElementType == nil {
+			return protoreflect.Value{}, fmt.Errorf("RANGE column has no element type")
+		}
+		bounds, err := jsonRangeBounds(val)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		md := fd.Message()
+		msg := dynamicpb.NewMessage(md)
+		for i, b := range bounds {
+			if b == nil {
+				continue
+			}
+			v, err := convertJSONScalar(b, fs.RangeElementType.Type)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			msg.Set(md.Fields().Get(i), v)
+		}
+		return protoreflect.ValueOfMessage(msg), nil
+	case bigquery.JSONFieldType:
+		if s, ok := val.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+		b, err := json.Marshal(val)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfString(string(b)), nil
+	}
+	return convertJSONScalar(val, fs.Type)
+}
+
+// jsonRangeBounds returns the start and end of a RANGE value, which are nil
+// when unbounded.
+func jsonRangeBounds(val any) ([2]any, error) {
+	var bounds [2]any
+	switch v := val.(type) {
+	case map[string]any:
+		for key, b := range v {
+			switch strings.ToLower(key) {
+			case "start":
+				bounds[0] = b
+			case "end":
+				bounds[1] = b
+			default:
+				return bounds, fmt.Errorf("unexpected RANGE key %q", key)
+			}
+		}
+	case string:
+		if !strings.HasPrefix(v, "[") || !strings.HasSuffix(v, ")") {
+			return bounds, fmt.Errorf("invalid RANGE value %q", v)
+		}
+		parts := strings.Split(v[1:len(v)-1], ", ")
+		if len(parts) != 2 {
+			return bounds, fmt.Errorf("invalid RANGE value %q", v)
+		}
+		for i, p := range parts {
+			if p != "UNBOUNDED" && p != "NULL" {
+				bounds[i] = p
+			}
+		}
+	default:
+		return bounds, fmt.Errorf("RANGE column requires an object or string, got %T", val)
+	}
+	return bounds, nil
+}
+
+// convertJSONScalar converts a JSON value of a column of a basic type.
+func convertJSONScalar(val any, typ bigquery.FieldType) (protoreflect.Value, error) {
+	s, isString := val.(string)
+	switch typ {
+	case bigquery.StringFieldType, bigquery.GeographyFieldType:
+		if isString {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case bigquery.BytesFieldType:
+		if isString {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+	case bigquery.BooleanFieldType:
+		if b, ok := val.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+		if isString {
+			b, err := strconv.ParseBool(s)
+			return protoreflect.ValueOfBool(b), err
+		}
+	case bigquery.IntegerFieldType:
+		if n, ok := jsonNumberText(val); ok {
+			i, err := strconv.ParseInt(n, 10, 64)
+			if err != nil {
+				// Accept integral numbers written in exponent or decimal notation.
+				f, ferr := strconv.ParseFloat(n, 64)
+				if ferr != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+					return protoreflect.Value{}, fmt.Errorf("invalid INTEGER value %s", n)
+				}
+				i = int64(f)
+			}
+			return protoreflect.ValueOfInt64(i), nil
+		}
+	case bigquery.FloatFieldType:
+		if n, ok := jsonNumberText(val); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return protoreflect.Value{}, fmt.Errorf("invalid FLOAT value %s", n)
+			}
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		if n, ok := jsonNumberText(val); ok {
+			r, ok := new(big.Rat).SetString(n)
+			if !ok {
+				return protoreflect.Value{}, fmt.Errorf("invalid %s value %s", typ, n)
+			}
+			if typ == bigquery.NumericFieldType {
+				return protoreflect.ValueOfString(bigquery.NumericString(r)), nil
+			}
+			return protoreflect.ValueOfString(bigquery.BigNumericString(r)), nil
+		}
+	case bigquery.TimestampFieldType:
+		if isString {
+			t, err := parseJSONTimestamp(s)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfInt64(t.UnixMicro()), nil
+		}
+		if n, ok := jsonNumberText(val); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return protoreflect.Value{}, fmt.Errorf("invalid TIMESTAMP value %s", n)
+			}
+			return protoreflect.ValueOfInt64(int64(math.Round(f * 1e6))), nil
+		}
+	case bigquery.DateFieldType, bigquery.TimeFieldType, bigquery.DateTimeFieldType:
+		if isString {
+			return convertJSONCivil(s, typ)
+		}
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported column type %s", typ)
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, typ)
+}
+
+// jsonNumberText returns the text of a JSON number, of a Go number in a map
+// row, or of a string holding one.
+func jsonNumberText(val any) (string, bool) {
+	switch v := val.(type) {
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
+	case string:
+		return v, true
+	}
+	return "", false
+}
+
+// parseJSONTimestamp parses an RFC 3339 timestamp, or one in the BigQuery
+// canonical format, which is in UTC unless it has an offset. The only zone
+// name accepted is a " UTC" suffix, since time.Parse gives unknown zone
+// abbreviations a zero offset.
+func parseJSONTimestamp(s string) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+	}
+	if rest, ok := strings.CutSuffix(s, " UTC"); ok {
+		s, layouts = rest, layouts[2:]
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid TIMESTAMP value %q", s)
+}
+
+func convertJSONCivil(s string, typ bigquery.FieldType) (protoreflect.Value, error) {
+	var v any
+	var err error
+	switch typ {
+	case bigquery.DateFieldType:
+		v, err = civil.ParseDate(s)
+	case bigquery.TimeFieldType:
+		v, err = civil.ParseTime(s)
+	default:
+		v, err = civil.ParseDateTime(strings.Replace(s, " ", "T", 1))
+	}
+	if err != nil {
+		return protoreflect.Value{}, err
+	}
+	return temporalEncoder(typ)(v)
+}
@@ -0,0 +1,283 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+)
+
+func TestEncodeJSONRow(t *testing.T) {
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType, Required: true},
+		{Name: "count", Type: bigquery.IntegerFieldType},
+		{Name: "score", Type: bigquery.FloatFieldType},
+		{Name: "ok", Type: bigquery.BooleanFieldType},
+		{Name: "data", Type: bigquery.BytesFieldType},
+		{Name: "ts", Type: bigquery.TimestampFieldType},
+		{Name: "day", Type: bigquery.DateFieldType},
+		{Name: "clock", Type: bigquery.TimeFieldType},
+		{Name: "dt", Type: bigquery.DateTimeFieldType},
+		{Name: "price", Type: bigquery.NumericFieldType},
+		{Name: "doc", Type: bigquery.JSONFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "period", Type: bigquery.RangeFieldType, RangeElementType: &bigquery.RangeElementType{Type: bigquery.DateFieldType}},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "city", Type: bigquery.StringFieldType},
+		}},
+	}
+	desc, err := schemaDescriptor(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := `{
+		"NAME": "alice", "count": 1e3, "score": "NaN", "ok": "true", "data": "aGk=",
+		"ts": "2024-02-29T12:00:00.5Z", "day": "1970-01-11", "clock": "01:02:03",
+		"dt": "2024-02-29 12:00:00", "price": 12.25, "doc": {"a": [1, 2]},
+		"tags": ["x", "y"], "period": {"start": "1970-01-03", "end": null},
+		"address": {"city": "Paris"}
+	}`
+	for _, row := range []any{raw, json.RawMessage(raw)} {
+		b, err := encodeJSONRow(row, schema, desc)
+		if err != nil {
+			t.Fatalf("%T: %v", row, err)
+		}
+		got := decodeRow(t, desc, schema, b)
+		for name, want := range map[string]any{
+			"name":  "alice",
+			"count": int64(1000),
+			"ok":    true,
+			"ts":    time.Date(2024, 2, 29, 12, 0, 0, 5e8, time.UTC).UnixMicro(),
+			"day":   int32(10),
+			"clock": "01:02:03",
+			"dt":    "2024-02-29 12:00:00",
+			"price": "12.250000000",
+			"doc":   `{"a":[1,2]}`,
+		} {
+			if v, ok := got[name]; !ok || v.Interface() != want {
+				t.Errorf("%s: got %v, want %v", name, v, want)
+			}
+		}
+		if !math.IsNaN(got["score"].Float()) || string(got["data"].Bytes()) != "hi" || got["tags"].List().Len() != 2 {
+			t.Errorf("got score %v, data %v and tags %v", got["score"], got["data"], got["tags"])
+		}
+		period := got["period"].Message()
+		if start := period.Get(period.Descriptor().Fields().Get(0)); start.Int() != 2 {
+			t.Errorf("period: got %v", period)
+		}
+	}
+
+	// Maps are converted too, with a null for a NULL value.
+	b, err := encodeJSONRow(map[string]any{"name": "bob", "count": nil, "ts": 1.5}, schema, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeRow(t, desc, schema, b)
+	if _, ok := got["count"]; ok || got["ts"].Int() != 1500000 {
+		t.Errorf("got %v", got)
+	}
+
+	for _, row := range []any{
+		`[1]`,
+		`{"count": 1}`,
+		`{"name": "a", "unknown": 1}`,
+		`{"name": "a", "count": 1.5}`,
+		`{"name": "a", "day": "yesterday"}`,
+		`{"name": "a", "tags": "x"}`,
+		`{"name": "a", "Name": "b"}`,
+		42,
+	} {
+		if _, err := encodeJSONRow(row, schema, desc); err == nil {
+			t.Errorf("%v: got nil, want error", row)
+		}
+	}
+}
+
+func TestParseJSONTimestamp(t *testing.T) {
+	want := time.Date(2024, 2, 29, 12, 0, 0, 5e8, time.UTC)
+	for _, s := range []string{
+		"2024-02-29T12:00:00.5Z",
+		"2024-02-29T13:00:00.5+01:00",
+		"2024-02-29 12:00:00.5",
+		"2024-02-29 12:00:00.5 UTC",
+		"2024-02-29T12:00:00.5 UTC",
+		"2024-02-29 11:00:00.5-01:00",
+	} {
+		got, err := parseJSONTimestamp(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+		} else if !got.Equal(want) {
+			t.Errorf("%q: got %v, want %v", s, got, want)
+		}
+	}
+	for _, s := range []string{
+		"2024-02-29 12:00:00.5 PST",
+		"2024-02-29 12:00:00.5 XYZ",
+		"2024-02-29 12:00:00.5Z UTC",
+		"2024-02-29 12:00:00.5UTC",
+	} {
+		if got, err := parseJSONTimestamp(s); err == nil {
+			t.Errorf("%q: got %v, want error", s, got)
+		}
+	}
+}
+
+func TestJSONWriterSchemaUpdate(t *testing.T) {
+	ctx := context.Background()
+	updated := &storagepb.TableSchema{Fields: []*storagepb.TableFieldSchema{
+		{Name: "name", Type: storagepb.TableFieldSchema_STRING, Mode: storagepb.TableFieldSchema_NULLABLE},
+		{Name: "extra", Type: storagepb.TableFieldSchema_INT64, Mode: storagepb.TableFieldSchema_NULLABLE},
+	}}
+	testARC := &testAppendRowsClient{}
+	pool := &connectionPool{
+		ctx: ctx,
+		open: openTestArc(testARC, nil, func() (*storagepb.AppendRowsResponse, error) {
+			// The first append reports that a column was added.
+			resp := &storagepb.AppendRowsResponse{Response: &storagepb.AppendRowsResponse_AppendResult_{}}
+			if len(testARC.requests) == 1 {
+				resp.UpdatedSchema = updated
+			}
+			return resp, nil
+		}),
+		baseFlowController: newFlowController(0, 0),
+	}
+	if err := pool.activateRouter(newSimpleRouter(simplexConnectionMode)); err != nil {
+		t.Fatalf("activateRouter: %v", err)
+	}
+	ms := &ManagedStream{
+		id:             "foo",
+		ctx:            ctx,
+		streamSettings: defaultStreamSettings(),
+	}
+	if err := pool.addWriter(ms); err != nil {
+		t.Fatalf("addWriter: %v", err)
+	}
+	ms.streamSettings.streamID = "FOO"
+	w, err := newJSONWriter(ms, bigquery.Schema{{Name: "name", Type: bigquery.StringFieldType}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.curTemplate = newVersionedTemplate().revise(reviseProtoSchema(w.dp))
+
+	res, err := w.Append(ctx, []any{`{"name": "a"}`, `{"name": "b", "extra": 1}`})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if _, err := res.GetResult(ctx); err != nil {
+		t.Fatalf("GetResult: %v", err)
+	}
+	select {
+	case e := <-w.Errors():
+		if e.Index != 1 {
+			t.Errorf("got error for row %d, want 1: %v", e.Index, e)
+		}
+	default:
+		t.Fatal("no error reported for the row with an unknown column")
+	}
+	if n := len(testARC.requests[0].GetProtoRows().GetRows().GetSerializedRows()); n != 1 {
+		t.Errorf("first append sent %d rows, want 1", n)
+	}
+
+	// The updated schema is adopted once the result is processed.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(w.Schema()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("updated schema was not adopted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := w.Append(ctx, []any{map[string]any{"name": "b", "extra": 1}}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if len(testARC.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(testARC.requests))
+	}
+	dp := testARC.requests[1].GetProtoRows().GetWriterSchema().GetProtoDescriptor()
+	if len(dp.GetField()) != 2 {
+		t.Errorf("second append: got descriptor %v, want the updated one", dp)
+	}
+	msg := decodeRow(t, w.desc, w.Schema(), testARC.requests[1].GetProtoRows().GetRows().GetSerializedRows()[0])
+	if msg["extra"].Int() != 1 {
+		t.Errorf("second append: got row %v", msg)
+	}
+
+	// Like ManagedStream.Close, a normal close reports io.EOF.
+	if err := w.Close(); err != io.EOF {
+		t.Errorf("Close: %v", err)
+	}
+	if _, ok := <-w.Errors(); ok {
+		t.Error("Errors channel is open after Close")
+	}
+}
+
+func TestJSONWriterCloseWhileReporting(t *testing.T) {
+	ctx := context.Background()
+	pool := &connectionPool{
+		ctx:                ctx,
+		open:               openTestArc(&testAppendRowsClient{}, nil, nil),
+		baseFlowController: newFlowController(0, 0),
+	}
+	if err := pool.activateRouter(newSimpleRouter(simplexConnectionMode)); err != nil {
+		t.Fatalf("activateRouter: %v", err)
+	}
+	ms := &ManagedStream{
+		id:             "foo",
+		ctx:            ctx,
+		streamSettings: defaultStreamSettings(),
+	}
+	if err := pool.addWriter(ms); err != nil {
+		t.Fatalf("addWriter: %v", err)
+	}
+	schema := bigquery.Schema{{Name: "name", Type: bigquery.StringFieldType}}
+	w, err := newJSONWriter(ms, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the Errors channel, so that the next error blocks.
+	for i := 0; i < defaultJSONErrorBuffer; i++ {
+		if err := w.reportError(ctx, &JSONRowError{Index: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocked := &JSONRowError{Index: defaultJSONErrorBuffer}
+	errc := make(chan error, 1)
+	go func() { errc <- w.reportError(ctx, blocked) }()
+
+	// The blocked send doesn't hold the lock, so the schema can change.
+	time.Sleep(10 * time.Millisecond)
+	if err := w.setSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	// Close abandons the blocked send rather than panicking on it.
+	w.Close()
+	if err := <-errc; err != blocked {
+		t.Errorf("blocked report: got %v, want the row error", err)
+	}
+	n := 0
+	for range w.Errors() {
+		n++
+	}
+	if n != defaultJSONErrorBuffer {
+		t.Errorf("got %d errors, want %d", n, defaultJSONErrorBuffer)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"sync"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/bigquery/internal"
 	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
 	"github.com/googleapis/gax-go/v2"
@@ -82,6 +83,13 @@ type ManagedStream struct {
 	c           *Client
 	retry       *statelessRetryer
 
+	// tableSchema retains the destination table schema once it has been
+	// fetched to validate Arrow appends. tableSchemaRefetched reports whether
+	// it has been fetched again after a record failed validation, which is
+	// done at most once until the schema changes.
+	tableSchema          bigquery.Schema
+	tableSchemaRefetched bool
+
 	// writer state
 	mu     sync.Mutex
 	ctx    context.Context // used for stats/instrumentation, and to check the writer is live.
@@ -305,6 +313,12 @@ func (ms *ManagedStream) AppendRows(ctx context.Context, data [][]byte, opts ...
 	for _, opt := range opts {
 		opt(pw)
 	}
+	return ms.appendPendingWrite(ctx, pw)
+}
+
+// appendPendingWrite sends a pending write built by one of the append methods, and
+// waits until it is accepted by a connection.
+func (ms *ManagedStream) appendPendingWrite(ctx context.Context, pw *pendingWrite) (*AppendResult, error) {
 	// Post-request fixup after options are applied.
 	if pw.reqTmpl != nil {
 		if pw.reqTmpl.tmpl != nil {
@@ -254,6 +254,20 @@ func reviseProtoSchema(newSchema *descriptorpb.DescriptorProto) templateRevision
 	}
 }
 
+func reviseArrowSchema(serializedSchema []byte) templateRevisionF {
+	return func(m *storagepb.AppendRowsRequest) {
+		if m != nil {
+			m.Rows = &storagepb.AppendRowsRequest_ArrowRows{
+				ArrowRows: &storagepb.AppendRowsRequest_ArrowData{
+					WriterSchema: &storagepb.ArrowSchema{
+						SerializedSchema: serializedSchema,
+					},
+				},
+			}
+		}
+	}
+}
+
 func reviseMissingValueInterpretations(vi map[string]storagepb.AppendRowsRequest_MissingValueInterpretation) templateRevisionF {
 	return func(m *storagepb.AppendRowsRequest) {
 		if m != nil {
@@ -0,0 +1,555 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/internal/fields"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// StructWriter appends Go values of type T, a struct or a pointer to a
+// struct, to a ManagedStream, deriving the protocol buffer schema of the
+// stream from the struct type so that no descriptor or message needs to be
+// written by hand.
+//
+// Struct fields are mapped to columns using the same rules as
+// bigquery.InferSchema: the "bigquery" struct tag names columns or omits
+// fields, embedded structs are flattened, nested structs are RECORD columns,
+// slices are REPEATED columns, and the "nullable" option marks []byte and
+// struct pointer fields as NULLABLE. Invalid bigquery.Null* values and nil
+// pointers, []byte and *big.Rat values are written as NULL, which is an
+// error for REQUIRED columns.
+//
+// Values are encoded according to the type of their column: civil.Date,
+// civil.Time and civil.DateTime values as DATE, TIME and DATETIME, time.Time
+// values as TIMESTAMP, *big.Rat values as NUMERIC or BIGNUMERIC, and
+// bigquery.RangeValue values as RANGE. JSON columns accept strings, byte
+// slices, and any other value, which is marshaled with encoding/json, such as
+// structs tagged with the "json" option and maps.
+type StructWriter[T any] struct {
+	ms     *ManagedStream
+	schema bigquery.Schema
+	desc   protoreflect.MessageDescriptor
+	enc    *structEncoder
+}
+
+// NewStructWriter creates a ManagedStream with the given options, and returns
+// a StructWriter that appends values of type T to it.
+//
+// If schema is nil, it is inferred from T with bigquery.InferSchema.
+// Otherwise, it is the schema of the destination table, or a subset of it,
+// and every exported struct field must match a column by name, ignoring
+// case. A schema is required to write RANGE columns, whose element type
+// cannot be inferred, and BIGNUMERIC columns, since *big.Rat values are
+// inferred as NUMERIC. INTERVAL columns are not supported.
+//
+// The WithSchemaDescriptor option is set by NewStructWriter and must not be
+// passed.
+func NewStructWriter[T any](ctx context.Context, c *Client, schema bigquery.Schema, opts ...WriterOption) (*StructWriter[T], error) {
+	t := reflect.TypeFor[T]()
+	st := t
+	if st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("managedwriter: StructWriter requires a struct or pointer to struct type, got %s", t)
+	}
+	if schema == nil {
+		var err error
+		if schema, err = bigquery.InferSchema(reflect.Zero(st).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	desc, err := schemaDescriptor(schema)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := newStructEncoder(st, schema, desc)
+	if err != nil {
+		return nil, err
+	}
+	dp, err := adapt.NormalizeDescriptor(desc)
+	if err != nil {
+		return nil, fmt.Errorf("managedwriter: normalizing descriptor: %w", err)
+	}
+	ms, err := c.NewManagedStream(ctx, append(opts, WithSchemaDescriptor(dp))...)
+	if err != nil {
+		return nil, err
+	}
+	return &StructWriter[T]{ms: ms, schema: schema, desc: desc, enc: enc}, nil
+}
+
+// schemaDescriptor returns the message descriptor of rows of the schema.
+// TIME, DATETIME, NUMERIC and BIGNUMERIC values are sent in their string
+// representations.
+func schemaDescriptor(schema bigquery.Schema) (protoreflect.MessageDescriptor, error) {
+	ts, err := adapt.BQSchemaToStorageTableSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	var opts []adapt.ProtoConversionOption
+	for _, typ := range []storagepb.TableFieldSchema_Type{
+		storagepb.TableFieldSchema_TIME,
+		storagepb.TableFieldSchema_DATETIME,
+		storagepb.TableFieldSchema_NUMERIC,
+		storagepb.TableFieldSchema_BIGNUMERIC,
+	} {
+		opts = append(opts, adapt.WithProtoMapping(adapt.ProtoMapping{
+			FieldType: typ,
+			Type:      descriptorpb.FieldDescriptorProto_TYPE_STRING,
+		}))
+	}
+	d, err := adapt.StorageSchemaToProtoDescriptorWithOptions(ts, "root", opts...)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("managedwriter: got descriptor %T, want a message descriptor", d)
+	}
+	return md, nil
+}
+
+// Stream returns the ManagedStream the writer appends to.
+func (w *StructWriter[T]) Stream() *ManagedStream {
+	return w.ms
+}
+
+// Schema returns the schema of the rows written.
+func (w *StructWriter[T]) Schema() bigquery.Schema {
+	return w.schema
+}
+
+// Encode returns the serialized protocol buffer message of row.
+func (w *StructWriter[T]) Encode(row T) ([]byte, error) {
+	v := reflect.ValueOf(&row).Elem()
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, fmt.Errorf("managedwriter: cannot append a nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+	msg := dynamicpb.NewMessage(w.desc)
+	if err := w.enc.encode(msg, v); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// Append encodes rows and appends them to the stream with AppendRows.
+func (w *StructWriter[T]) Append(ctx context.Context, rows []T, opts ...AppendOption) (*AppendResult, error) {
+	data := make([][]byte, len(rows))
+	for i, row := range rows {
+		b, err := w.Encode(row)
+		if err != nil {
+			return nil, fmt.Errorf("managedwriter: encoding row %d: %w", i, err)
+		}
+		data[i] = b
+	}
+	return w.ms.AppendRows(ctx, data, opts...)
+}
+
+// Close closes the underlying ManagedStream.
+func (w *StructWriter[T]) Close() error {
+	return w.ms.Close()
+}
+
+// structFieldCache caches the fields of struct types, with the same tag
+// rules as the bigquery package.
+var structFieldCache = fields.NewCache(func(t reflect.StructTag) (string, bool, interface{}, error) {
+	name, keep, opts, err := fields.ParseStandardTag("bigquery", t)
+	return name, keep, opts, err
+}, nil, nil)
+
+// structEncoder sets the fields of a message from the fields of a struct.
+type structEncoder struct {
+	fields []fieldEncoder
+}
+
+type fieldEncoder struct {
+	name     string
+	index    []int
+	fd       protoreflect.FieldDescriptor
+	repeated bool
+	enc      valueEncoder
+}
+
+// valueEncoder converts a Go value to the value of a message field. It
+// returns false if the Go value is NULL.
+type valueEncoder func(v reflect.Value) (protoreflect.Value, bool, error)
+
+func newStructEncoder(t reflect.Type, schema bigquery.Schema, md protoreflect.MessageDescriptor) (*structEncoder, error) {
+	fl, err := structFieldCache.Fields(t)
+	if err != nil {
+		return nil, err
+	}
+	enc := &structEncoder{}
+	for _, f := range fl {
+		idx := -1
+		for i, fs := range schema {
+			if strings.EqualFold(fs.Name, f.Name) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("managedwriter: struct field %q of %s has no column in the schema", f.Name, t)
+		}
+		fs := schema[idx]
+		// The descriptor numbers fields in schema order, starting at 1.
+		fd := md.Fields().ByNumber(protoreflect.FieldNumber(idx + 1))
+		ft := f.Type
+		if fs.Repeated {
+			if ft.Kind() != reflect.Slice && ft.Kind() != reflect.Array {
+				return nil, fmt.Errorf("managedwriter: struct field %q of %s must be a slice for REPEATED column %q", f.Name, t, fs.Name)
+			}
+			ft = ft.Elem()
+		}
+		ve, err := newValueEncoder(ft, fs, fd, isJSONField(f))
+		if err != nil {
+			return nil, fmt.Errorf("managedwriter: struct field %q of %s: %w", f.Name, t, err)
+		}
+		enc.fields = append(enc.fields, fieldEncoder{
+			name:     fs.Name,
+			index:    f.Index,
+			fd:       fd,
+			repeated: fs.Repeated,
+			enc:      ve,
+		})
+	}
+	return enc, nil
+}
+
+func isJSONField(f fields.Field) bool {
+	opts, _ := f.ParsedTag.([]string)
+	for _, opt := range opts {
+		if opt == "json" {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *structEncoder) encode(msg protoreflect.Message, v reflect.Value) error {
+	for _, f := range e.fields {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// A nil embedded struct pointer holds no values.
+			continue
+		}
+		if !f.repeated {
+			pv, ok, err := f.enc(fv)
+			if err != nil {
+				return fmt.Errorf("column %q: %w", f.name, err)
+			}
+			if ok {
+				msg.Set(f.fd, pv)
+			} else if f.fd.Cardinality() == protoreflect.Required {
+				return fmt.Errorf("column %q is REQUIRED, but the value is NULL", f.name)
+			}
+			continue
+		}
+		if fv.Len() == 0 {
+			continue
+		}
+		list := msg.Mutable(f.fd).List()
+		for i := 0; i < fv.Len(); i++ {
+			pv, ok, err := f.enc(fv.Index(i))
+			if err != nil {
+				return fmt.Errorf("column %q: element %d: %w", f.name, i, err)
+			}
+			if !ok {
+				return fmt.Errorf("column %q: element %d is NULL", f.name, i)
+			}
+			list.Append(pv)
+		}
+	}
+	return nil
+}
+
+var (
+	typeOfByteSlice     = reflect.TypeFor[[]byte]()
+	typeOfTime          = reflect.TypeFor[time.Time]()
+	typeOfDate          = reflect.TypeFor[civil.Date]()
+	typeOfCivilTime     = reflect.TypeFor[civil.Time]()
+	typeOfDateTime      = reflect.TypeFor[civil.DateTime]()
+	typeOfRat           = reflect.TypeFor[*big.Rat]()
+	typeOfRangeValue    = reflect.TypeFor[bigquery.RangeValue]()
+	typeOfRangeValuePtr = reflect.TypeFor[*bigquery.RangeValue]()
+
+	// nullTypes maps the bigquery.Null* types to the name of their value
+	// field.
+	nullTypes = map[reflect.Type]string{
+		reflect.TypeFor[bigquery.NullInt64]():     "Int64",
+		reflect.TypeFor[bigquery.NullString]():    "StringVal",
+		reflect.TypeFor[bigquery.NullGeography](): "GeographyVal",
+		reflect.TypeFor[bigquery.NullJSON]():      "JSONVal",
+		reflect.TypeFor[bigquery.NullFloat64]():   "Float64",
+		reflect.TypeFor[bigquery.NullBool]():      "Bool",
+		reflect.TypeFor[bigquery.NullTimestamp](): "Timestamp",
+		reflect.TypeFor[bigquery.NullDate]():      "Date",
+		reflect.TypeFor[bigquery.NullTime]():      "Time",
+		reflect.TypeFor[bigquery.NullDateTime]():  "DateTime",
+	}
+)
+
+var epochDate = civil.Date{Year: 1970, Month: 1, Day: 1}
+
+// newValueEncoder returns the encoder of Go values of type t for a
+// non-repeated value of the column fs.
+func newValueEncoder(t reflect.Type, fs *bigquery.FieldSchema, fd protoreflect.FieldDescriptor, asJSON bool) (valueEncoder, error) {
+	if name, ok := nullTypes[t]; ok {
+		sf, _ := t.FieldByName(name)
+		inner, err := newValueEncoder(sf.Type, fs, fd, false)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			if !v.FieldByName("Valid").Bool() {
+				return protoreflect.Value{}, false, nil
+			}
+			return inner(v.FieldByIndex(sf.Index))
+		}, nil
+	}
+	if t.Kind() == reflect.Pointer && t != typeOfRat && fs.Type != bigquery.JSONFieldType {
+		inner, err := newValueEncoder(t.Elem(), fs, fd, asJSON)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			if v.IsNil() {
+				return protoreflect.Value{}, false, nil
+			}
+			return inner(v.Elem())
+		}, nil
+	}
+
+	mismatch := fmt.Errorf("cannot write %s to a %s column", t, fs.Type)
+	switch fs.Type {
+	case bigquery.StringFieldType, bigquery.GeographyFieldType:
+		if t.Kind() != reflect.String {
+			return nil, mismatch
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			return protoreflect.ValueOfString(v.String()), true, nil
+		}, nil
+
+	case bigquery.JSONFieldType:
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			switch {
+			case v.Kind() == reflect.String:
+				return protoreflect.ValueOfString(v.String()), true, nil
+			case v.Type() == typeOfByteSlice || v.Kind() == reflect.Pointer || v.Kind() == reflect.Map || v.Kind() == reflect.Slice:
+				if v.IsNil() {
+					return protoreflect.Value{}, false, nil
+				}
+				if v.Type() == typeOfByteSlice {
+					return protoreflect.ValueOfString(string(v.Bytes())), true, nil
+				}
+			}
+			b, err := json.Marshal(v.Interface())
+			if err != nil {
+				return protoreflect.Value{}, false, err
+			}
+			return protoreflect.ValueOfString(string(b)), true, nil
+		}, nil
+
+	case bigquery.BytesFieldType:
+		if t != typeOfByteSlice {
+			return nil, mismatch
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			if v.IsNil() {
+				return protoreflect.Value{}, false, nil
+			}
+			return protoreflect.ValueOfBytes(v.Bytes()), true, nil
+		}, nil
+
+	case bigquery.IntegerFieldType:
+		switch {
+		case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+			return func(v reflect.Value) (protoreflect.Value, bool, error) {
+				return protoreflect.ValueOfInt64(v.Int()), true, nil
+			}, nil
+		case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr:
+			return func(v reflect.Value) (protoreflect.Value, bool, error) {
+				if v.Uint() > math.MaxInt64 {
+					return protoreflect.Value{}, false, fmt.Errorf("value %d overflows INTEGER", v.Uint())
+				}
+				return protoreflect.ValueOfInt64(int64(v.Uint())), true, nil
+			}, nil
+		}
+		return nil, mismatch
+
+	case bigquery.FloatFieldType:
+		if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+			return nil, mismatch
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			return protoreflect.ValueOfFloat64(v.Float()), true, nil
+		}, nil
+
+	case bigquery.BooleanFieldType:
+		if t.Kind() != reflect.Bool {
+			return nil, mismatch
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			return protoreflect.ValueOfBool(v.Bool()), true, nil
+		}, nil
+
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		if t.Kind() == reflect.String {
+			return func(v reflect.Value) (protoreflect.Value, bool, error) {
+				return protoreflect.ValueOfString(v.String()), true, nil
+			}, nil
+		}
+		if t != typeOfRat {
+			return nil, mismatch
+		}
+		format := bigquery.NumericString
+		if fs.Type == bigquery.BigNumericFieldType {
+			format = bigquery.BigNumericString
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			if v.IsNil() {
+				return protoreflect.Value{}, false, nil
+			}
+			return protoreflect.ValueOfString(format(v.Interface().(*big.Rat))), true, nil
+		}, nil
+
+	case bigquery.TimestampFieldType, bigquery.DateFieldType, bigquery.TimeFieldType, bigquery.DateTimeFieldType:
+		conv := temporalEncoder(fs.Type)
+		want := map[bigquery.FieldType]reflect.Type{
+			bigquery.TimestampFieldType: typeOfTime,
+			bigquery.DateFieldType:      typeOfDate,
+			bigquery.TimeFieldType:      typeOfCivilTime,
+			bigquery.DateTimeFieldType:  typeOfDateTime,
+		}[fs.Type]
+		if t != want {
+			return nil, mismatch
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			pv, err := conv(v.Interface())
+			return pv, err == nil, err
+		}, nil
+
+	case bigquery.RecordFieldType:
+		if t.Kind() != reflect.Struct {
+			return nil, mismatch
+		}
+		sub, err := newStructEncoder(t, fs.Schema, fd.Message())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			msg := dynamicpb.NewMessage(fd.Message())
+			if err := sub.encode(msg, v); err != nil {
+				return protoreflect.Value{}, false, err
+			}
+			return protoreflect.ValueOfMessage(msg), true, nil
+		}, nil
+
+	case bigquery.RangeFieldType:
+		if t != typeOfRangeValue && t != typeOfRangeValuePtr {
+			return nil, mismatch
+		}
+		if fs.RangeElementType == nil {
+			return nil, fmt.Errorf("RANGE column %q has no element type", fs.Name)
+		}
+		conv := temporalEncoder(fs.RangeElementType.Type)
+		if conv == nil {
+			return nil, fmt.Errorf("unsupported RANGE element type %s", fs.RangeElementType.Type)
+		}
+		md := fd.Message()
+		return func(v reflect.Value) (protoreflect.Value, bool, error) {
+			if v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return protoreflect.Value{}, false, nil
+				}
+				v = v.Elem()
+			}
+			rv := v.Interface().(bigquery.RangeValue)
+			msg := dynamicpb.NewMessage(md)
+			for i, bound := range []bigquery.Value{rv.Start, rv.End} {
+				if bound == nil {
+					continue
+				}
+				pv, err := conv(bound)
+				if err != nil {
+					return protoreflect.Value{}, false, err
+				}
+				msg.Set(md.Fields().Get(i), pv)
+			}
+			return protoreflect.ValueOfMessage(msg), true, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported column type %s", fs.Type)
+}
+
+// temporalEncoder returns a function that encodes values of a TIMESTAMP,
+// DATE, TIME or DATETIME column, or nil for other types.
+func temporalEncoder(typ bigquery.FieldType) func(any) (protoreflect.Value, error) {
+	switch typ {
+	case bigquery.TimestampFieldType:
+		return func(v any) (protoreflect.Value, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return protoreflect.Value{}, fmt.Errorf("got %T, want time.Time", v)
+			}
+			return protoreflect.ValueOfInt64(t.UnixMicro()), nil
+		}
+	case bigquery.DateFieldType:
+		return func(v any) (protoreflect.Value, error) {
+			d, ok := v.(civil.Date)
+			if !ok {
+				return protoreflect.Value{}, fmt.Errorf("got %T, want civil.Date", v)
+			}
+			return protoreflect.ValueOfInt32(int32(d.DaysSince(epochDate))), nil
+		}
+	case bigquery.TimeFieldType:
+		return func(v any) (protoreflect.Value, error) {
+			t, ok := v.(civil.Time)
+			if !ok {
+				return protoreflect.Value{}, fmt.Errorf("got %T, want civil.Time", v)
+			}
+			return protoreflect.ValueOfString(bigquery.CivilTimeString(t)), nil
+		}
+	case bigquery.DateTimeFieldType:
+		return func(v any) (protoreflect.Value, error) {
+			dt, ok := v.(civil.DateTime)
+			if !ok {
+				return protoreflect.Value{}, fmt.Errorf("got %T, want civil.DateTime", v)
+			}
+			return protoreflect.ValueOfString(bigquery.CivilDateTimeString(dt)), nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// newTestStructWriter returns a StructWriter without a stream, which can
+// only encode rows.
+func newTestStructWriter[T any](t *testing.T, schema bigquery.Schema) *StructWriter[T] {
+	t.Helper()
+	st := reflect.TypeFor[T]()
+	if st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+	if schema == nil {
+		var err error
+		if schema, err = bigquery.InferSchema(reflect.Zero(st).Interface()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	desc, err := schemaDescriptor(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := newStructEncoder(st, schema, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &StructWriter[T]{schema: schema, desc: desc, enc: enc}
+}
+
+// decodeRow unmarshals an encoded row and returns its fields by column name.
+func decodeRow(t *testing.T, desc protoreflect.MessageDescriptor, schema bigquery.Schema, b []byte) map[string]protoreflect.Value {
+	t.Helper()
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]protoreflect.Value{}
+	for i, fs := range schema {
+		fd := desc.Fields().ByNumber(protoreflect.FieldNumber(i + 1))
+		if msg.Has(fd) {
+			got[fs.Name] = msg.Get(fd)
+		}
+	}
+	return got
+}
+
+type structWriterAddress struct {
+	City string
+	Zip  int
+}
+
+type structWriterDoc struct {
+	A int `json:"a"`
+}
+
+type structWriterBase struct {
+	ID int64 `bigquery:"id"`
+}
+
+type structWriterRow struct {
+	structWriterBase
+	Name     string
+	Count    uint16
+	Score    float64
+	OK       bool
+	Data     []byte `bigquery:",nullable"`
+	TS       time.Time
+	Day      civil.Date
+	Clock    civil.Time
+	DT       civil.DateTime
+	Price    *big.Rat
+	Label    bigquery.NullString
+	Rating   bigquery.NullInt64
+	Doc      *structWriterDoc `bigquery:",json,nullable"`
+	Tags     []string
+	Address  *structWriterAddress `bigquery:",nullable"`
+	Previous []structWriterAddress
+	Ignored  string `bigquery:"-"`
+}
+
+func TestStructWriterEncode(t *testing.T) {
+	w := newTestStructWriter[*structWriterRow](t, nil)
+	ts := time.Date(2024, 2, 29, 12, 34, 56, 789000, time.UTC)
+	row := &structWriterRow{
+		structWriterBase: structWriterBase{ID: 7},
+		Name:             "alice",
+		Count:            3,
+		Score:            1.5,
+		OK:               true,
+		Data:             []byte("hi"),
+		TS:               ts,
+		Day:              civil.Date{Year: 1970, Month: 1, Day: 11},
+		Clock:            civil.Time{Hour: 1, Minute: 2, Second: 3, Nanosecond: 4000},
+		DT:               civil.DateTime{Date: civil.Date{Year: 2024, Month: 2, Day: 29}, Time: civil.Time{Hour: 12}},
+		Price:            big.NewRat(49, 4),
+		Rating:           bigquery.NullInt64{Int64: 5, Valid: true},
+		Doc:              &structWriterDoc{A: 1},
+		Tags:             []string{"x", "y"},
+		Address:          &structWriterAddress{City: "Paris", Zip: 75001},
+		Previous:         []structWriterAddress{{City: "Lyon"}, {City: "Nice"}},
+	}
+	b, err := w.Encode(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeRow(t, w.desc, w.schema, b)
+
+	for name, want := range map[string]any{
+		"id":     int64(7),
+		"Name":   "alice",
+		"Count":  int64(3),
+		"Score":  1.5,
+		"OK":     true,
+		"TS":     ts.UnixMicro(),
+		"Day":    int32(10),
+		"Clock":  "01:02:03.000004",
+		"DT":     "2024-02-29 12:00:00",
+		"Price":  "12.250000000",
+		"Rating": int64(5),
+		"Doc":    `{"a":1}`,
+	} {
+		if v, ok := got[name]; !ok || v.Interface() != want {
+			t.Errorf("%s: got %v, want %v", name, v, want)
+		}
+	}
+	if string(got["Data"].Bytes()) != "hi" {
+		t.Errorf("Data: got %v", got["Data"])
+	}
+	if _, ok := got["Label"]; ok {
+		t.Error("Label: got a value for an invalid NullString")
+	}
+	if l := got["Tags"].List(); l.Len() != 2 || l.Get(1).String() != "y" {
+		t.Errorf("Tags: got %v", got["Tags"])
+	}
+	addr := got["Address"].Message()
+	if city := addr.Get(addr.Descriptor().Fields().ByNumber(1)); city.String() != "Paris" {
+		t.Errorf("Address.City: got %v", city)
+	}
+	if l := got["Previous"].List(); l.Len() != 2 {
+		t.Errorf("Previous: got %v", got["Previous"])
+	}
+
+	// A row with zero and nil values leaves nullable columns unset.
+	if _, err := w.Encode(&structWriterRow{}); err == nil {
+		t.Error("Encode of a nil REQUIRED NUMERIC: got nil, want error")
+	}
+	b, err = w.Encode(&structWriterRow{Price: new(big.Rat)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = decodeRow(t, w.desc, w.schema, b)
+	for _, name := range []string{"Data", "Doc", "Tags", "Address", "Rating"} {
+		if _, ok := got[name]; ok {
+			t.Errorf("%s: got a value, want NULL", name)
+		}
+	}
+	if _, err := w.Encode(nil); err == nil {
+		t.Error("Encode(nil): got nil, want error")
+	}
+}
+
+func TestStructWriterExplicitSchema(t *testing.T) {
+	type row struct {
+		Period bigquery.RangeValue
+		Window *bigquery.RangeValue
+		Big    *big.Rat
+		Geo    string
+		Extra  bigquery.NullJSON
+	}
+	schema := bigquery.Schema{
+		{Name: "big", Type: bigquery.BigNumericFieldType},
+		{Name: "period", Type: bigquery.RangeFieldType, RangeElementType: &bigquery.RangeElementType{Type: bigquery.DateFieldType}},
+		{Name: "window", Type: bigquery.RangeFieldType, RangeElementType: &bigquery.RangeElementType{Type: bigquery.TimestampFieldType}},
+		{Name: "geo", Type: bigquery.GeographyFieldType},
+		{Name: "extra", Type: bigquery.JSONFieldType},
+		{Name: "unused", Type: bigquery.StringFieldType},
+	}
+	w := newTestStructWriter[row](t, schema)
+	b, err := w.Encode(row{
+		Period: bigquery.RangeValue{Start: civil.Date{Year: 1970, Month: 1, Day: 3}},
+		Big:    big.NewRat(1, 3),
+		Geo:    "POINT(1 2)",
+		Extra:  bigquery.NullJSON{JSONVal: `[1]`, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeRow(t, w.desc, w.schema, b)
+	if s := got["big"].String(); s != bigquery.BigNumericString(big.NewRat(1, 3)) {
+		t.Errorf("big: got %q", s)
+	}
+	period := got["period"].Message()
+	fields := period.Descriptor().Fields()
+	if start := period.Get(fields.Get(0)); start.Int() != 2 || period.Has(fields.Get(1)) {
+		t.Errorf("period: got %v", period)
+	}
+	if _, ok := got["window"]; ok {
+		t.Error("window: got a value for a nil range")
+	}
+	if got["geo"].String() != "POINT(1 2)" || got["extra"].String() != "[1]" {
+		t.Errorf("got geo %v and extra %v", got["geo"], got["extra"])
+	}
+
+	if _, err := newTestStructWriter[row](t, schema).Encode(row{
+		Period: bigquery.RangeValue{End: "2024-01-01"},
+	}); err == nil {
+		t.Error("Encode of a range bound of the wrong type: got nil, want error")
+	}
+}
+
+func TestNewStructWriterErrors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewStructWriter[int](ctx, nil, nil); err == nil {
+		t.Error("non-struct type: got nil, want error")
+	}
+	type row struct {
+		Name  string
+		Count int
+	}
+	for _, schema := range []bigquery.Schema{
+		{{Name: "name", Type: bigquery.StringFieldType}},
+		{{Name: "name", Type: bigquery.StringFieldType}, {Name: "count", Type: bigquery.StringFieldType}},
+		{{Name: "name", Type: bigquery.StringFieldType}, {Name: "count", Type: bigquery.IntegerFieldType, Repeated: true}},
+	} {
+		if _, err := NewStructWriter[row](ctx, nil, schema); err == nil {
+			t.Errorf("schema %v: got nil, want error", schema)
+		}
+	}
+	type rangeRow struct {
+		Period bigquery.RangeValue
+	}
+	if _, err := NewStructWriter[rangeRow](ctx, nil, nil); err == nil {
+		t.Error("inferred RANGE column: got nil, want error")
+	}
+}
@@ -48,5 +48,17 @@
 //	if err != nil {
 //		// TODO: Handle error.
 //	}
-//	// TODO: iterate results.
+//	for {
+//		row, err := it.Next()
+//		if err == iterator.Done {
+//			break
+//		}
+//		if err != nil {
+//			// TODO: Handle error.
+//		}
+//		var v struct{ Foo int64 }
+//		if err := row.Decode(&v); err != nil {
+//			// TODO: Handle error.
+//		}
+//	}
 package query
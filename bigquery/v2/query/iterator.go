@@ -6,7 +6,7 @@
 //
 //     https://www.apache.org/licenses/LICENSE-2.0
 //
-// Unless required by applicable law_assets/v2_query_iterator.go
+// Unless required by applicable law or agreed to in writing, software
 // distributed under the License is distributed on an "AS IS" BASIS,
 // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 // See the License for the specific language governing permissions and
@@ -15,14 +15,102 @@
 package query
 
 import (
+	"context"
 	"errors"
+
+	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // RowIterator is an iterator over the results of a query.
 type RowIterator struct {
+	ctx context.Context
+	q   *Query
+
+	pageSize  *wrapperspb.UInt32Value
+	schema    *bigquerypb.TableSchema
+	totalRows uint64
+
+	rows      []*Row
+	pageToken string
+	last      bool // whether rows holds the last page
+	err       error
 }
 
-// Next returns the next row from the results.
+// Next returns the next row from the results. Its second return value is
+// iterator.Done if there are no more results. Once Next returns Done, all
+// subsequent calls will return Done.
 func (it *RowIterator) Next() (*Row, error) {
-	return nil, errors.New("not implemented")
+	for len(it.rows) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		if it.last {
+			return nil, iterator.Done
+		}
+		it.err = it.fetch()
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+// fetch reads the page of results at the iterator's page token with
+// jobs.getQueryResults.
+func (it *RowIterator) fetch() error {
+	ref := it.q.JobReference()
+	if ref == nil {
+		return errors.New("bigquery: query results have no more pages, or the query has no job")
+	}
+	res, err := it.q.h.c.GetQueryResults(it.ctx, &bigquerypb.GetQueryResultsRequest{
+		ProjectId:  ref.GetProjectId(),
+		JobId:      ref.GetJobId(),
+		Location:   ref.GetLocation().GetValue(),
+		PageToken:  it.pageToken,
+		MaxResults: it.pageSize,
+		FormatOptions: &bigquerypb.DataFormatOptions{
+			UseInt64Timestamp: true,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if res.GetSchema() != nil {
+		it.schema = res.GetSchema()
+	}
+	if res.GetTotalRows() != nil {
+		it.totalRows = res.GetTotalRows().GetValue()
+	}
+	return it.setPage(res.GetRows(), res.GetPageToken())
+}
+
+func (it *RowIterator) setPage(rows []*structpb.Struct, pageToken string) error {
+	r, err := convertRows(rows, it.schema.GetFields())
+	if err != nil {
+		return err
+	}
+	it.rows = r
+	it.pageToken = pageToken
+	it.last = pageToken == ""
+	return nil
+}
+
+// Schema returns the schema of the results. It is nil if no page of results
+// has been read.
+func (it *RowIterator) Schema() *bigquerypb.TableSchema {
+	return it.schema
+}
+
+// TotalRows returns the total number of rows of the results.
+func (it *RowIterator) TotalRows() uint64 {
+	return it.totalRows
+}
+
+// PageToken returns the token of the page of results that follows the page
+// being read, or "" if it is the last page. It can be passed to
+// [WithPageToken] to resume reading at the next page.
+func (it *RowIterator) PageToken() string {
+	return it.pageToken
 }
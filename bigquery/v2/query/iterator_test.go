@@ -0,0 +1,216 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
+	"cloud.google.com/go/bigquery/v2/apiv2_client"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeJobServer serves the results of a single query job, whose rows hold
+// the numbers from 0 to rows-1, in pages of pageSize rows.
+type fakeJobServer struct {
+	bigquerypb.UnimplementedJobServiceServer
+	rows     int
+	pageSize int
+
+	mu       sync.Mutex
+	requests []*bigquerypb.GetQueryResultsRequest
+}
+
+var fakeSchema = &bigquerypb.TableSchema{Fields: []*bigquerypb.TableFieldSchema{
+	{Name: "n", Type: "INTEGER"},
+	{Name: "s", Type: "STRING"},
+}}
+
+func fakeRow(n int) *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"f": structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+			structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{"v": structpb.NewStringValue(strconv.Itoa(n))}}),
+			structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{"v": structpb.NewStringValue(fmt.Sprintf("row %d", n))}}),
+		}}),
+	}}
+}
+
+// page returns the rows starting at the row given by the page token, and the
+// token of the next page.
+func (s *fakeJobServer) page(token string, maxResults *wrapperspb.UInt32Value) ([]*structpb.Struct, string) {
+	start, _ := strconv.Atoi(token)
+	size := s.pageSize
+	if maxResults != nil {
+		size = min(size, int(maxResults.GetValue()))
+	}
+	end := min(start+size, s.rows)
+	var rows []*structpb.Struct
+	for i := start; i < end; i++ {
+		rows = append(rows, fakeRow(i))
+	}
+	if end == s.rows {
+		return rows, ""
+	}
+	return rows, strconv.Itoa(end)
+}
+
+func (s *fakeJobServer) Query(_ context.Context, req *bigquerypb.PostQueryRequest) (*bigquerypb.QueryResponse, error) {
+	rows, token := s.page("", req.GetQueryRequest().GetMaxResults())
+	return &bigquerypb.QueryResponse{
+		JobReference: &bigquerypb.JobReference{ProjectId: req.GetProjectId(), JobId: "job"},
+		JobComplete:  wrapperspb.Bool(true),
+		Schema:       fakeSchema,
+		TotalRows:    wrapperspb.UInt64(uint64(s.rows)),
+		Rows:         rows,
+		PageToken:    token,
+	}, nil
+}
+
+func (s *fakeJobServer) GetQueryResults(_ context.Context, req *bigquerypb.GetQueryResultsRequest) (*bigquerypb.GetQueryResultsResponse, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+	res := &bigquerypb.GetQueryResultsResponse{
+		JobReference: &bigquerypb.JobReference{ProjectId: req.GetProjectId(), JobId: req.GetJobId()},
+		JobComplete:  wrapperspb.Bool(true),
+		Schema:       fakeSchema,
+		TotalRows:    wrapperspb.UInt64(uint64(s.rows)),
+	}
+	if req.GetMaxResults() == nil || req.GetMaxResults().GetValue() > 0 {
+		res.Rows, res.PageToken = s.page(req.GetPageToken(), req.GetMaxResults())
+	}
+	return res, nil
+}
+
+func newFakeHelper(t *testing.T, srv *fakeJobServer) *Helper {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gsrv := grpc.NewServer()
+	bigquerypb.RegisterJobServiceServer(gsrv, srv)
+	go gsrv.Serve(lis)
+	t.Cleanup(gsrv.Stop)
+
+	c, err := apiv2_client.NewClient(context.Background(),
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	h, err := NewHelper(c, "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func readAllNumbers(t *testing.T, it *RowIterator) []int64 {
+	t.Helper()
+	var got []int64
+	for {
+		row, err := it.Next()
+		if err == iterator.Done {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		n, err := row.GetInt64("n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, err := row.GetString("S"); err != nil || s != fmt.Sprintf("row %d", n) {
+			t.Errorf("column s: got %q, %v", s, err)
+		}
+		got = append(got, n)
+	}
+}
+
+func TestRowIteratorPaging(t *testing.T) {
+	ctx := context.Background()
+	srv := &fakeJobServer{rows: 25, pageSize: 10}
+	h := newFakeHelper(t, srv)
+
+	q, err := h.StartQuery(ctx, h.FromSQL("SELECT n, s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := q.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	got := readAllNumbers(t, it)
+	if len(got) != 25 {
+		t.Fatalf("got %d rows, want 25", len(got))
+	}
+	for i, n := range got {
+		if n != int64(i) {
+			t.Fatalf("row %d: got %d", i, n)
+		}
+	}
+	if it.TotalRows() != 25 || len(it.Schema().GetFields()) != 2 || q.Schema() == nil {
+		t.Errorf("got total rows %d and schema %v", it.TotalRows(), it.Schema())
+	}
+	// The first page was returned by jobs.query; the others were fetched.
+	srv.mu.Lock()
+	var tokens []string
+	for _, req := range srv.requests {
+		tokens = append(tokens, req.GetPageToken())
+	}
+	srv.mu.Unlock()
+	if fmt.Sprint(tokens) != "[10 20]" {
+		t.Errorf("got page tokens %q, want [10 20]", tokens)
+	}
+
+	// Reading can resume at a page token, with smaller pages.
+	it, err = q.Read(ctx, WithPageToken("20"), WithPageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAllNumbers(t, it); fmt.Sprint(got) != "[20 21 22 23 24]" {
+		t.Errorf("resumed read: got %v", got)
+	}
+}
+
+func TestRowIteratorAttachJob(t *testing.T) {
+	ctx := context.Background()
+	h := newFakeHelper(t, &fakeJobServer{rows: 3, pageSize: 2})
+
+	q, err := h.AttachJob(ctx, &bigquerypb.JobReference{JobId: "job"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := q.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAllNumbers(t, it); fmt.Sprint(got) != "[0 1 2]" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -19,6 +19,7 @@ type ReadOption func(*readState)
 
 type readState struct {
 	pageToken string
+	pageSize  uint32
 }
 
 // WithPageToken sets the page token for reading query results.
@@ -27,3 +28,11 @@ func WithPageToken(t string) ReadOption {
 		s.pageToken = t
 	}
 }
+
+// WithPageSize sets the maximum number of rows in each page of results read
+// from the service. By default, the service limits pages to 10 MB of data.
+func WithPageSize(n uint32) ReadOption {
+	return func(s *readState) {
+		s.pageSize = n
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
 	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
@@ -42,6 +43,15 @@ type Query struct {
 	err      error
 
 	cachedTotalRows uint64
+	schema          *bigquerypb.TableSchema
+	// firstPage holds the rows returned with the response that completed the
+	// query, if any, so that Read does not have to fetch them again.
+	firstPage *resultPage
+}
+
+type resultPage struct {
+	rows      []*structpb.Struct
+	pageToken string
 }
 
 // Create Query handler using jobs.query request and start background pooling job
@@ -85,10 +95,38 @@ func newQueryJobFromJobReference(ctx context.Context, h *Helper, jobRef *bigquer
 	return q
 }
 
-// Read returns a RowIterator for the query results.
+// Read waits for the query to complete and returns a RowIterator for the
+// query results. Rows returned with the response that completed the query
+// are read first, and the following pages are fetched with
+// jobs.getQueryResults.
 func (q *Query) Read(ctx context.Context, opts ...ReadOption) (*RowIterator, error) {
-	// TODO: proper setup iterator
-	return &RowIterator{}, nil
+	if err := q.Wait(ctx); err != nil {
+		return nil, err
+	}
+	state := &readState{}
+	for _, opt := range opts {
+		opt(state)
+	}
+	it := &RowIterator{
+		ctx:       ctx,
+		q:         q,
+		pageToken: state.pageToken,
+	}
+	if state.pageSize > 0 {
+		it.pageSize = wrapperspb.UInt32(state.pageSize)
+	}
+
+	q.mu.RLock()
+	it.schema = q.schema
+	it.totalRows = q.cachedTotalRows
+	first := q.firstPage
+	q.mu.RUnlock()
+	if first != nil && state.pageToken == "" && (state.pageSize == 0 || len(first.rows) <= int(state.pageSize)) {
+		if err := it.setPage(first.rows, first.pageToken); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
 }
 
 // Wait blocks until the query has completed. The provided context can be used to
@@ -231,6 +269,9 @@ type queryResponse interface {
 	GetJobComplete() *wrapperspb.BoolValue
 	GetJobReference() *bigquerypb.JobReference
 	GetTotalRows() *wrapperspb.UInt64Value
+	GetSchema() *bigquerypb.TableSchema
+	GetRows() []*structpb.Struct
+	GetPageToken() string
 }
 
 func (q *Query) consumeQueryResponse(res queryResponse) {
@@ -254,7 +295,19 @@ func (q *Query) consumeQueryResponse(res queryResponse) {
 		q.cachedTotalRows = res.GetTotalRows().GetValue()
 	}
 
-	// TODO: save schema, page token, total rows and parse rows
+	if res.GetSchema() != nil {
+		q.schema = res.GetSchema()
+	}
+
+	// Polls for completion request no rows, so only a response with rows, or
+	// one that completes a query without results, holds the first page.
+	empty := res.GetTotalRows() != nil && res.GetTotalRows().GetValue() == 0 && res.GetPageToken() == ""
+	if q.complete && q.firstPage == nil && (len(res.GetRows()) > 0 || empty) {
+		q.firstPage = &resultPage{
+			rows:      res.GetRows(),
+			pageToken: res.GetPageToken(),
+		}
+	}
 }
 
 // QueryID returns the auto-generated ID for the query.
@@ -284,7 +337,9 @@ func (q *Query) JobReference() *bigquerypb.JobReference {
 // Schema returns the schema of the query results.
 // This will be nil until the query has completed and the schema is available.
 func (q *Query) Schema() *bigquerypb.TableSchema {
-	return nil // TODO: fill schema
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.schema
 }
 
 // Complete returns true if the query job has finished execution.
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
+	"google.golang.org/api/iterator"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
@@ -49,7 +50,27 @@ func TestIntegration_RunQuery(t *testing.T) {
 				t.Fatalf("expected job to be complete")
 			}
 
-			// TODO: read data and assert row count
+			it, err := q.Read(ctx)
+			if err != nil {
+				t.Fatalf("Read() error: %v", err)
+			}
+			var rows []*Row
+			for {
+				row, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error: %v", err)
+				}
+				rows = append(rows, row)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("expected 1 row, got %d", len(rows))
+			}
+			if _, err := rows[0].GetTimestamp("foo"); err != nil {
+				t.Errorf("GetTimestamp() error: %v", err)
+			}
 		})
 	}
 }
@@ -163,7 +184,27 @@ func TestIntegration_InsertQueryJob(t *testing.T) {
 				t.Fatalf("expected job to be complete")
 			}
 
-			// TODO: read data and assert row count
+			it, err := q.Read(ctx)
+			if err != nil {
+				t.Fatalf("Read() error: %v", err)
+			}
+			var rows []*Row
+			for {
+				row, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error: %v", err)
+				}
+				rows = append(rows, row)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("expected 1 row, got %d", len(rows))
+			}
+			if _, err := rows[0].GetTimestamp("foo"); err != nil {
+				t.Errorf("GetTimestamp() error: %v", err)
+			}
 		})
 	}
 }
@@ -14,6 +14,303 @@
 
 package query
 
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
+	"cloud.google.com/go/civil"
+)
+
 // Row represents a single row in the query results.
+//
+// The values of the row's columns have the following Go types, or are nil
+// for NULL:
+//
+//	STRING, GEOGRAPHY, JSON, INTERVAL   string
+//	BYTES                               []byte
+//	INTEGER, INT64                      int64
+//	FLOAT, FLOAT64                      float64
+//	BOOLEAN, BOOL                       bool
+//	TIMESTAMP                           time.Time (in UTC)
+//	DATE                                civil.Date
+//	TIME                                civil.Time
+//	DATETIME                            civil.DateTime
+//	NUMERIC, BIGNUMERIC                 *big.Rat
+//	RANGE                               *RangeValue
+//	RECORD, STRUCT                      *Row
+//
+// The value of a REPEATED column is a []any of values of those types.
 type Row struct {
+	fields []*bigquerypb.TableFieldSchema
+	values []any
+}
+
+// Fields returns the schema of the row's columns.
+func (r *Row) Fields() []*bigquerypb.TableFieldSchema {
+	return r.fields
+}
+
+// Values returns the values of the row's columns, in the order of Fields.
+func (r *Row) Values() []any {
+	return r.values
+}
+
+// Len returns the number of columns of the row.
+func (r *Row) Len() int {
+	return len(r.values)
+}
+
+// index returns the index of the column with the given name. Column names
+// are case-insensitive.
+func (r *Row) index(name string) int {
+	for i, f := range r.fields {
+		if f.GetName() == name {
+			return i
+		}
+	}
+	for i, f := range r.fields {
+		if strings.EqualFold(f.GetName(), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Value returns the value of the column with the given name, and whether the
+// row has such a column.
+func (r *Row) Value(name string) (any, bool) {
+	i := r.index(name)
+	if i < 0 {
+		return nil, false
+	}
+	return r.values[i], true
+}
+
+// IsNull reports whether the column with the given name is NULL. It returns
+// false if the row has no such column.
+func (r *Row) IsNull(name string) bool {
+	v, ok := r.Value(name)
+	return ok && v == nil
+}
+
+// getValue returns the value of a column as a T, or an error if the row has
+// no such column, or it is NULL or of another type.
+func getValue[T any](r *Row, name string) (T, error) {
+	var zero T
+	v, ok := r.Value(name)
+	if !ok {
+		return zero, fmt.Errorf("bigquery: row has no column %q", name)
+	}
+	if v == nil {
+		return zero, fmt.Errorf("bigquery: column %q is NULL", name)
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("bigquery: column %q holds a %T, not a %T", name, v, zero)
+	}
+	return t, nil
+}
+
+// GetString returns the value of a STRING, GEOGRAPHY, JSON or INTERVAL
+// column.
+func (r *Row) GetString(name string) (string, error) { return getValue[string](r, name) }
+
+// GetBytes returns the value of a BYTES column.
+func (r *Row) GetBytes(name string) ([]byte, error) { return getValue[[]byte](r, name) }
+
+// GetInt64 returns the value of an INTEGER column.
+func (r *Row) GetInt64(name string) (int64, error) { return getValue[int64](r, name) }
+
+// GetFloat64 returns the value of a FLOAT column.
+func (r *Row) GetFloat64(name string) (float64, error) { return getValue[float64](r, name) }
+
+// GetBool returns the value of a BOOLEAN column.
+func (r *Row) GetBool(name string) (bool, error) { return getValue[bool](r, name) }
+
+// GetTimestamp returns the value of a TIMESTAMP column.
+func (r *Row) GetTimestamp(name string) (time.Time, error) { return getValue[time.Time](r, name) }
+
+// GetDate returns the value of a DATE column.
+func (r *Row) GetDate(name string) (civil.Date, error) { return getValue[civil.Date](r, name) }
+
+// GetTime returns the value of a TIME column.
+func (r *Row) GetTime(name string) (civil.Time, error) { return getValue[civil.Time](r, name) }
+
+// GetDateTime returns the value of a DATETIME column.
+func (r *Row) GetDateTime(name string) (civil.DateTime, error) {
+	return getValue[civil.DateTime](r, name)
+}
+
+// GetNumeric returns the value of a NUMERIC or BIGNUMERIC column.
+func (r *Row) GetNumeric(name string) (*big.Rat, error) { return getValue[*big.Rat](r, name) }
+
+// GetRange returns the value of a RANGE column.
+func (r *Row) GetRange(name string) (*RangeValue, error) { return getValue[*RangeValue](r, name) }
+
+// GetRecord returns the value of a RECORD column.
+func (r *Row) GetRecord(name string) (*Row, error) { return getValue[*Row](r, name) }
+
+// GetRepeated returns the values of a REPEATED column.
+func (r *Row) GetRepeated(name string) ([]any, error) { return getValue[[]any](r, name) }
+
+// Map returns the row as a map from column names to values. The values of
+// RECORD columns are maps as well.
+func (r *Row) Map() map[string]any {
+	m := make(map[string]any, len(r.values))
+	for i, f := range r.fields {
+		m[f.GetName()] = mapValue(r.values[i])
+	}
+	return m
+}
+
+// mapValue converts nested rows in v to maps.
+func mapValue(v any) any {
+	switch v := v.(type) {
+	case *Row:
+		return v.Map()
+	case []any:
+		vals := make([]any, len(v))
+		for i, e := range v {
+			vals[i] = mapValue(e)
+		}
+		return vals
+	default:
+		return v
+	}
+}
+
+// Decode stores the values of the row in dst, which must be a pointer to a
+// struct or to a map[string]any.
+//
+// Struct fields are matched to columns by the name in their "bigquery" tag,
+// or by their own name otherwise, ignoring case. Fields tagged "-", and
+// columns without a matching field, are skipped. RECORD columns are decoded
+// into nested structs, pointers to structs or maps, and REPEATED columns into
+// slices. Values may be stored in fields of any type they are assignable or,
+// for numbers, convertible to without overflow. NULL values can only be
+// stored in pointers, slices, maps and interfaces, which are set to nil.
+func (r *Row) Decode(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("bigquery: Decode requires a non-nil pointer, got %T", dst)
+	}
+	return decodeValue(v.Elem(), r)
+}
+
+// decodeRow stores r in the struct or map v.
+func decodeRow(v reflect.Value, r *Row) error {
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(r.values)))
+		}
+		for i, f := range r.fields {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(e, r.values[i]); err != nil {
+				return fmt.Errorf("bigquery: column %q: %w", f.GetName(), err)
+			}
+			v.SetMapIndex(reflect.ValueOf(f.GetName()).Convert(v.Type().Key()), e)
+		}
+		return nil
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := sf.Name
+			if tag, ok := sf.Tag.Lookup("bigquery"); ok {
+				if tag = strings.Split(tag, ",")[0]; tag == "-" {
+					continue
+				} else if tag != "" {
+					name = tag
+				}
+			}
+			j := r.index(name)
+			if j < 0 {
+				continue
+			}
+			if err := decodeValue(v.Field(i), r.values[j]); err != nil {
+				return fmt.Errorf("bigquery: column %q: %w", r.fields[j].GetName(), err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("cannot decode a record into %s", v.Type())
+	}
+}
+
+// decodeValue stores val in v.
+func decodeValue(v reflect.Value, val any) error {
+	if val == nil {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			v.SetZero()
+			return nil
+		}
+		return fmt.Errorf("cannot store NULL in %s", v.Type())
+	}
+	if v.Kind() == reflect.Interface {
+		val = mapValue(val)
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(v.Type()) {
+		v.Set(rv)
+		return nil
+	}
+	switch val := val.(type) {
+	case *Row:
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		return decodeRow(v, val)
+	case []any:
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot store a repeated value in %s", v.Type())
+		}
+		s := reflect.MakeSlice(v.Type(), len(val), len(val))
+		for i, e := range val {
+			if err := decodeValue(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeValue(v.Elem(), val)
+	}
+	switch {
+	case rv.CanInt() && v.CanInt():
+		if v.OverflowInt(rv.Int()) {
+			return fmt.Errorf("value %d overflows %s", rv.Int(), v.Type())
+		}
+		v.SetInt(rv.Int())
+	case rv.CanInt() && v.CanUint():
+		if rv.Int() < 0 || v.OverflowUint(uint64(rv.Int())) {
+			return fmt.Errorf("value %d overflows %s", rv.Int(), v.Type())
+		}
+		v.SetUint(uint64(rv.Int()))
+	case rv.CanFloat() && v.CanFloat():
+		if v.OverflowFloat(rv.Float()) {
+			return fmt.Errorf("value %g overflows %s", rv.Float(), v.Type())
+		}
+		v.SetFloat(rv.Float())
+	case rv.Kind() == v.Kind() && rv.CanConvert(v.Type()):
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("cannot store a %T in %s", val, v.Type())
+	}
+	return nil
 }
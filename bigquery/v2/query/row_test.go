@@ -0,0 +1,234 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
+	"cloud.google.com/go/civil"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fv builds a row in the f/v format from cell values.
+func fv(vals ...*structpb.Value) *structpb.Value {
+	cells := make([]*structpb.Value, len(vals))
+	for i, v := range vals {
+		cells[i] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{"v": v}})
+	}
+	return structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"f": structpb.NewListValue(&structpb.ListValue{Values: cells}),
+	}})
+}
+
+// repeated builds a repeated value from element values.
+func repeated(vals ...*structpb.Value) *structpb.Value {
+	elems := make([]*structpb.Value, len(vals))
+	for i, v := range vals {
+		elems[i] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{"v": v}})
+	}
+	return structpb.NewListValue(&structpb.ListValue{Values: elems})
+}
+
+var testFields = []*bigquerypb.TableFieldSchema{
+	{Name: "name", Type: "STRING"},
+	{Name: "count", Type: "INTEGER"},
+	{Name: "score", Type: "FLOAT"},
+	{Name: "ok", Type: "BOOLEAN"},
+	{Name: "data", Type: "BYTES"},
+	{Name: "ts", Type: "TIMESTAMP"},
+	{Name: "day", Type: "DATE"},
+	{Name: "clock", Type: "TIME"},
+	{Name: "dt", Type: "DATETIME"},
+	{Name: "price", Type: "NUMERIC"},
+	{Name: "period", Type: "RANGE", RangeElementType: &bigquerypb.TableFieldSchema_FieldElementType{Type: "DATE"}},
+	{Name: "tags", Type: "STRING", Mode: "REPEATED"},
+	{Name: "missing", Type: "STRING"},
+	{Name: "address", Type: "RECORD", Fields: []*bigquerypb.TableFieldSchema{
+		{Name: "city", Type: "STRING"},
+		{Name: "zip", Type: "INTEGER"},
+	}},
+	{Name: "points", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquerypb.TableFieldSchema{
+		{Name: "x", Type: "INTEGER"},
+	}},
+}
+
+func testRow(t *testing.T) *Row {
+	t.Helper()
+	s := structpb.NewStringValue
+	row, err := convertRow(fv(
+		s("alice"),
+		s("42"),
+		s("1.5"),
+		s("true"),
+		s("aGk="),
+		s("1700000000123456"),
+		s("2024-02-29"),
+		s("12:34:56.5"),
+		s("2024-02-29T12:34:56"),
+		s("12.25"),
+		s("[2024-01-01, UNBOUNDED)"),
+		repeated(s("a"), s("b")),
+		structpb.NewNullValue(),
+		fv(s("Paris"), s("75001")),
+		repeated(fv(s("1")), fv(s("2"))),
+	).GetStructValue(), testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestRowGetters(t *testing.T) {
+	row := testRow(t)
+	if row.Len() != len(testFields) {
+		t.Errorf("Len: got %d", row.Len())
+	}
+	check := func(name string, got any, err error, want any) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b *big.Rat) bool { return a.Cmp(b) == 0 })); diff != "" {
+			t.Errorf("%s: got(-),want(+):\n%s", name, diff)
+		}
+	}
+	v1, err := row.GetString("name")
+	check("name", v1, err, "alice")
+	v2, err := row.GetInt64("COUNT")
+	check("count", v2, err, int64(42))
+	v3, err := row.GetFloat64("score")
+	check("score", v3, err, 1.5)
+	v4, err := row.GetBool("ok")
+	check("ok", v4, err, true)
+	v5, err := row.GetBytes("data")
+	check("data", v5, err, []byte("hi"))
+	v6, err := row.GetTimestamp("ts")
+	check("ts", v6, err, time.UnixMicro(1700000000123456).UTC())
+	v7, err := row.GetDate("day")
+	check("day", v7, err, civil.Date{Year: 2024, Month: 2, Day: 29})
+	v8, err := row.GetTime("clock")
+	check("clock", v8, err, civil.Time{Hour: 12, Minute: 34, Second: 56, Nanosecond: 5e8})
+	v9, err := row.GetDateTime("dt")
+	check("dt", v9, err, civil.DateTime{Date: civil.Date{Year: 2024, Month: 2, Day: 29}, Time: civil.Time{Hour: 12, Minute: 34, Second: 56}})
+	v10, err := row.GetNumeric("price")
+	check("price", v10, err, big.NewRat(49, 4))
+	v11, err := row.GetRange("period")
+	check("period", v11, err, &RangeValue{Start: civil.Date{Year: 2024, Month: 1, Day: 1}})
+	v12, err := row.GetRepeated("tags")
+	check("tags", v12, err, []any{"a", "b"})
+	rec, err := row.GetRecord("address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v13, err := rec.GetInt64("zip")
+	check("address.zip", v13, err, int64(75001))
+
+	if !row.IsNull("missing") || row.IsNull("name") || row.IsNull("nonexistent") {
+		t.Error("IsNull: got wrong result")
+	}
+	for _, name := range []string{"missing", "nonexistent", "count"} {
+		if _, err := row.GetString(name); err == nil {
+			t.Errorf("GetString(%q): got nil, want error", name)
+		}
+	}
+}
+
+func TestRowDecode(t *testing.T) {
+	row := testRow(t)
+
+	type address struct {
+		City string
+		Zip  int32
+	}
+	type point struct {
+		X uint8
+	}
+	var s struct {
+		Name     string
+		Total    int `bigquery:"count"`
+		Score    float32
+		Ok       bool
+		Data     []byte
+		TS       time.Time
+		Day      civil.Date
+		Price    *big.Rat
+		Tags     []string
+		Missing  *string
+		Address  *address
+		Points   []point
+		Period   any
+		Ignored  string `bigquery:"-"`
+		unexport int
+	}
+	if err := row.Decode(&s); err != nil {
+		t.Fatalf("Decode struct: %v", err)
+	}
+	if s.Name != "alice" || s.Total != 42 || s.Score != 1.5 || !s.Ok || string(s.Data) != "hi" ||
+		s.Day.Day != 29 || s.Price.Cmp(big.NewRat(49, 4)) != 0 || s.Missing != nil ||
+		s.Address == nil || *s.Address != (address{"Paris", 75001}) ||
+		len(s.Points) != 2 || s.Points[1].X != 2 || len(s.Tags) != 2 || s.Tags[1] != "b" {
+		t.Errorf("Decode struct: got %+v", s)
+	}
+
+	var m map[string]any
+	if err := row.Decode(&m); err != nil {
+		t.Fatalf("Decode map: %v", err)
+	}
+	if diff := cmp.Diff(map[string]any{"city": "Paris", "zip": int64(75001)}, m["address"]); diff != "" {
+		t.Errorf("Decode map: address: got(-),want(+):\n%s", diff)
+	}
+	if diff := cmp.Diff([]any{map[string]any{"x": int64(1)}, map[string]any{"x": int64(2)}}, m["points"]); diff != "" {
+		t.Errorf("Decode map: points: got(-),want(+):\n%s", diff)
+	}
+
+	for _, dst := range []any{
+		&struct{ Missing string }{},
+		&struct{ Name int }{},
+		struct{}{},
+	} {
+		if err := row.Decode(dst); err == nil {
+			t.Errorf("Decode(%T): got nil, want error", dst)
+		}
+	}
+	var small struct{ Count int8 }
+	row2, _ := convertRow(fv(structpb.NewStringValue("300")).GetStructValue(), []*bigquerypb.TableFieldSchema{{Name: "count", Type: "INTEGER"}})
+	if err := row2.Decode(&small); err == nil {
+		t.Error("Decode of an overflowing value: got nil, want error")
+	}
+}
+
+func TestConvertRowErrors(t *testing.T) {
+	s := structpb.NewStringValue
+	for _, tc := range []struct {
+		desc   string
+		row    *structpb.Value
+		fields []*bigquerypb.TableFieldSchema
+	}{
+		{"too few values", fv(s("1")), []*bigquerypb.TableFieldSchema{{Name: "a", Type: "INTEGER"}, {Name: "b", Type: "INTEGER"}}},
+		{"bad integer", fv(s("x")), []*bigquerypb.TableFieldSchema{{Name: "a", Type: "INTEGER"}}},
+		{"unknown type", fv(s("x")), []*bigquerypb.TableFieldSchema{{Name: "a", Type: "FOO"}}},
+		{"bad range", fv(s("2024-01-01")), []*bigquerypb.TableFieldSchema{{Name: "a", Type: "RANGE", RangeElementType: &bigquerypb.TableFieldSchema_FieldElementType{Type: "DATE"}}}},
+		{"repeated not a list", fv(s("x")), []*bigquerypb.TableFieldSchema{{Name: "a", Type: "STRING", Mode: "REPEATED"}}},
+	} {
+		if _, err := convertRow(tc.row.GetStructValue(), tc.fields); err == nil {
+			t.Errorf("%s: got nil, want error", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery/v2/apiv2/bigquerypb"
+	"cloud.google.com/go/civil"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RangeValue is the value of a RANGE column.
+type RangeValue struct {
+	// Start is the inclusive start of the range, or nil if the range is
+	// unbounded at the start.
+	Start any
+	// End is the exclusive end of the range, or nil if the range is
+	// unbounded at the end.
+	End any
+}
+
+// convertRows converts rows in the f/v format of jobs.getQueryResults.
+func convertRows(rows []*structpb.Struct, fields []*bigquerypb.TableFieldSchema) ([]*Row, error) {
+	out := make([]*Row, len(rows))
+	for i, row := range rows {
+		r, err := convertRow(row, fields)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+func convertRow(row *structpb.Struct, fields []*bigquerypb.TableFieldSchema) (*Row, error) {
+	cells := row.GetFields()["f"].GetListValue().GetValues()
+	if len(cells) != len(fields) {
+		return nil, fmt.Errorf("bigquery: row has %d values, but the schema has %d fields", len(cells), len(fields))
+	}
+	values := make([]any, len(cells))
+	for i, cell := range cells {
+		v, err := convertValue(cell.GetStructValue().GetFields()["v"], fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("bigquery: field %q: %w", fields[i].GetName(), err)
+		}
+		values[i] = v
+	}
+	return &Row{fields: fields, values: values}, nil
+}
+
+func isNull(v *structpb.Value) bool {
+	if v == nil {
+		return true
+	}
+	_, ok := v.GetKind().(*structpb.Value_NullValue)
+	return ok
+}
+
+func convertValue(v *structpb.Value, field *bigquerypb.TableFieldSchema) (any, error) {
+	if isNull(v) {
+		return nil, nil
+	}
+	if field.GetMode() != "REPEATED" {
+		return convertSingleValue(v, field)
+	}
+	list := v.GetListValue()
+	if list == nil {
+		return nil, fmt.Errorf("repeated value is not a list: %v", v)
+	}
+	vals := make([]any, len(list.GetValues()))
+	for i, elem := range list.GetValues() {
+		val, err := convertSingleValue(elem.GetStructValue().GetFields()["v"], field)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+func convertSingleValue(v *structpb.Value, field *bigquerypb.TableFieldSchema) (any, error) {
+	if isNull(v) {
+		return nil, nil
+	}
+	if typ := field.GetType(); typ == "RECORD" || typ == "STRUCT" {
+		s := v.GetStructValue()
+		if s == nil {
+			return nil, fmt.Errorf("record value is not a struct: %v", v)
+		}
+		return convertRow(s, field.GetFields())
+	}
+	s, ok := v.GetKind().(*structpb.Value_StringValue)
+	if !ok {
+		return nil, fmt.Errorf("value is not a string: %v", v)
+	}
+	if field.GetType() == "RANGE" {
+		return convertRangeValue(s.StringValue, field.GetRangeElementType().GetType())
+	}
+	return convertBasicType(s.StringValue, field.GetType())
+}
+
+func convertBasicType(val, typ string) (any, error) {
+	switch typ {
+	case "STRING", "GEOGRAPHY", "JSON", "INTERVAL":
+		return val, nil
+	case "BYTES":
+		return base64.StdEncoding.DecodeString(val)
+	case "INTEGER", "INT64":
+		return strconv.ParseInt(val, 10, 64)
+	case "FLOAT", "FLOAT64":
+		return strconv.ParseFloat(val, 64)
+	case "BOOLEAN", "BOOL":
+		return strconv.ParseBool(val)
+	case "TIMESTAMP":
+		return parseTimestamp(val)
+	case "DATE":
+		return civil.ParseDate(val)
+	case "TIME":
+		return civil.ParseTime(val)
+	case "DATETIME":
+		return civil.ParseDateTime(val)
+	case "NUMERIC", "BIGNUMERIC":
+		r, ok := new(big.Rat).SetString(val)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value %q", typ, val)
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unrecognized type %q", typ)
+	}
+}
+
+// parseTimestamp parses a TIMESTAMP value, which is a number of microseconds
+// since the epoch when the results use int64 timestamps, and a
+// floating-point number of seconds otherwise.
+func parseTimestamp(val string) (time.Time, error) {
+	if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return time.UnixMicro(i).UTC(), nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid TIMESTAMP value %q", val)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1000).UTC(), nil
+}
+
+// convertRangeValue parses a RANGE value, whose format is "[start, end)".
+func convertRangeValue(val, elementType string) (*RangeValue, error) {
+	if !strings.HasPrefix(val, "[") || !strings.HasSuffix(val, ")") {
+		return nil, fmt.Errorf("invalid RANGE value %q", val)
+	}
+	parts := strings.Split(val[1:len(val)-1], ", ")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid RANGE value %q", val)
+	}
+	rv := &RangeValue{}
+	for i, part := range parts {
+		if part == "UNBOUNDED" || part == "NULL" {
+			continue
+		}
+		v, err := convertBasicType(part, elementType)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			rv.Start = v
+		} else {
+			rv.End = v
+		}
+	}
+	return rv, nil
+}
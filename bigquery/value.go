@@ -183,6 +183,19 @@ func setGeography(v reflect.Value, x interface{}) error {
 	return nil
 }
 
+func setGeographyValue(v reflect.Value, x interface{}) error {
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	g, err := ParseGeography(x.(string))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(g))
+	return nil
+}
+
 func setJSON(v reflect.Value, x interface{}) error {
 	if x == nil {
 		return errNoNulls
@@ -321,6 +334,9 @@ func determineSetFunc(ftype reflect.Type, stype FieldType) setFunc {
 				})
 			}
 		}
+		if ftype == typeOfGeography {
+			return setGeographyValue
+		}
 
 	case JSONFieldType:
 		if ftype.Kind() == reflect.String {
@@ -782,6 +798,11 @@ func toUploadValueReflect(v reflect.Value, fs *FieldSchema) interface{} {
 		})
 	case RangeFieldType:
 		return v.Interface()
+	case GeographyFieldType:
+		if g, ok := v.Interface().(*Geography); ok && g == nil {
+			return nil
+		}
+		fallthrough
 	default:
 		if !fs.Repeated || v.Len() > 0 {
 			return v.Interface()
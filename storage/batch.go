@@ -0,0 +1,403 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	raw "google.golang.org/api/storage/v1"
+)
+
+// maxBatchSize is the maximum number of operations in a batch request.
+const maxBatchSize = 100
+
+// errBatchCaptured is returned by the transport that captures the requests of
+// batched operations instead of sending them.
+var errBatchCaptured = errors.New("storage: request captured for batch")
+
+// Batch is a set of metadata operations that are sent to Cloud Storage in
+// batch requests of the JSON API, up to 100 operations per request. Create a
+// Batch with [Client.Batch], add operations to it, then call [Batch.Run].
+//
+// Each operation is performed independently: the operations of a batch are not
+// atomic and may be performed in any order. Operations are not retried; check
+// the results and add the operations that failed with a retryable error to a
+// new Batch.
+//
+// Batches are only supported by clients that use the JSON API.
+type Batch struct {
+	c   *Client
+	ops []*BatchResult
+	ran bool
+}
+
+// BatchResult is the result of an operation in a Batch. Its fields are set
+// when [Batch.Run] returns.
+type BatchResult struct {
+	// Attrs holds the attributes of the object after a successful Update,
+	// Restore or Move operation.
+	Attrs *ObjectAttrs
+
+	// Err is the error of the operation, or nil if it succeeded.
+	Err error
+
+	// capture makes the request of the operation with the given client.
+	capture func(ctx context.Context, c *Client) error
+	// isObject reports whether the response holds an object resource.
+	isObject bool
+	// formatErr converts the error of the operation.
+	formatErr func(error) error
+	req       *http.Request
+}
+
+// Batch returns a new, empty Batch of operations.
+func (c *Client) Batch() *Batch {
+	return &Batch{c: c}
+}
+
+func (b *Batch) add(r *BatchResult) *BatchResult {
+	b.ops = append(b.ops, r)
+	return r
+}
+
+// Delete adds the deletion of the object to the batch. See
+// [ObjectHandle.Delete].
+func (b *Batch) Delete(o *ObjectHandle) *BatchResult {
+	return b.add(&BatchResult{
+		capture: func(ctx context.Context, c *Client) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+			return c.tc.DeleteObject(ctx, o.bucket, o.object, o.gen, o.conds, batchStorageOpts(o.userProject)...)
+		},
+		formatErr: formatObjectErr,
+	})
+}
+
+// Update adds an update of the object's attributes to the batch. See
+// [ObjectHandle.Update].
+func (b *Batch) Update(o *ObjectHandle, uattrs ObjectAttrsToUpdate) *BatchResult {
+	return b.add(&BatchResult{
+		capture: func(ctx context.Context, c *Client) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+			_, err := c.tc.UpdateObject(ctx, &updateObjectParams{
+				bucket:            o.bucket,
+				object:            o.object,
+				uattrs:            &uattrs,
+				gen:               o.gen,
+				encryptionKey:     o.encryptionKey,
+				conds:             o.conds,
+				overrideRetention: o.overrideRetention,
+			}, batchStorageOpts(o.userProject)...)
+			return err
+		},
+		isObject:  true,
+		formatErr: formatObjectErr,
+	})
+}
+
+// Restore adds the restoration of a soft-deleted object to the batch. See
+// [ObjectHandle.Restore]. A nil opts is the same as the zero RestoreOptions.
+func (b *Batch) Restore(o *ObjectHandle, opts *RestoreOptions) *BatchResult {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	return b.add(&BatchResult{
+		capture: func(ctx context.Context, c *Client) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+			gen := o.gen
+			if o.gen == defaultGen {
+				gen = 0
+			}
+			_, err := c.tc.RestoreObject(ctx, &restoreObjectParams{
+				bucket:        o.bucket,
+				object:        o.object,
+				gen:           gen,
+				conds:         o.conds,
+				copySourceACL: opts.CopySourceACL,
+			}, batchStorageOpts(o.userProject)...)
+			return err
+		},
+		isObject:  true,
+		formatErr: formatObjectErr,
+	})
+}
+
+// Move adds the renaming of the object to the batch. See [ObjectHandle.Move].
+func (b *Batch) Move(o *ObjectHandle, destination MoveObjectDestination) *BatchResult {
+	return b.add(&BatchResult{
+		capture: func(ctx context.Context, c *Client) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+			_, err := c.tc.MoveObject(ctx, &moveObjectParams{
+				bucket:        o.bucket,
+				srcObject:     o.object,
+				dstObject:     destination.Object,
+				srcConds:      o.conds,
+				dstConds:      destination.Conditions,
+				encryptionKey: o.encryptionKey,
+			}, batchStorageOpts(o.userProject)...)
+			return err
+		},
+		isObject:  true,
+		formatErr: formatObjectErr,
+	})
+}
+
+// SetACL adds the setting of the role of an entity in the ACL to the batch.
+// See [ACLHandle.Set].
+func (b *Batch) SetACL(acl *ACLHandle, entity ACLEntity, role ACLRole) *BatchResult {
+	return b.add(&BatchResult{
+		capture: func(ctx context.Context, c *Client) error {
+			a := *acl
+			a.c, a.retry = c, &retryConfig{policy: RetryNever}
+			if a.object != "" {
+				return a.objectSet(ctx, entity, role, false)
+			}
+			if a.isDefault {
+				return a.objectSet(ctx, entity, role, true)
+			}
+			return a.bucketSet(ctx, entity, role)
+		},
+		formatErr: func(err error) error { return err },
+	})
+}
+
+// DeleteACL adds the deletion of an entity from the ACL to the batch. See
+// [ACLHandle.Delete].
+func (b *Batch) DeleteACL(acl *ACLHandle, entity ACLEntity) *BatchResult {
+	return b.add(&BatchResult{
+		capture: func(ctx context.Context, c *Client) error {
+			a := *acl
+			a.c, a.retry = c, &retryConfig{policy: RetryNever}
+			if a.object != "" {
+				return a.objectDelete(ctx, entity)
+			}
+			if a.isDefault {
+				return a.bucketDefaultDelete(ctx, entity)
+			}
+			return a.bucketDelete(ctx, entity)
+		},
+		formatErr: func(err error) error { return err },
+	})
+}
+
+// batchStorageOpts returns the options of a batched call, which is made once
+// to capture its request.
+func batchStorageOpts(userProject string) []storageOption {
+	return makeStorageOpts(false, &retryConfig{policy: RetryNever}, userProject)
+}
+
+// Run sends the operations of the batch to Cloud Storage and sets their
+// results. It returns an error if a batch request fails as a whole, in which
+// case the error is also the result of each of its operations. The errors of
+// individual operations are only reported in their results.
+//
+// A Batch can be run only once.
+func (b *Batch) Run(ctx context.Context) (err error) {
+	ctx, _ = startSpan(ctx, "Batch.Run")
+	defer func() { endSpan(ctx, err) }()
+
+	if b.ran {
+		return errors.New("storage: Batch.Run called more than once")
+	}
+	b.ran = true
+	hc, ok := b.c.tc.(*httpStorageClient)
+	if !ok {
+		err := fmt.Errorf("storage: Batch requires a client that uses the JSON API: %w", errMethodNotSupported)
+		for _, op := range b.ops {
+			op.Err = err
+		}
+		return err
+	}
+
+	// Capture the request of each operation.
+	capture := &captureTransport{}
+	rawService, err := raw.NewService(ctx, option.WithEndpoint(hc.raw.BasePath), option.WithHTTPClient(&http.Client{Transport: capture}))
+	if err != nil {
+		return fmt.Errorf("storage: creating batch service: %w", err)
+	}
+	captureTC := *hc
+	captureTC.raw = rawService
+	captureClient := &Client{tc: &captureTC}
+	var pending []*BatchResult
+	for _, op := range b.ops {
+		capture.req = nil
+		if err := op.capture(ctx, captureClient); !errors.Is(err, errBatchCaptured) {
+			if err == nil {
+				err = errors.New("storage: batched operation did not make a request")
+			}
+			op.Err = err
+			continue
+		}
+		op.req = capture.req
+		pending = append(pending, op)
+	}
+
+	u, err := url.Parse(hc.raw.BasePath)
+	if err != nil {
+		return err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "storage/v1/") + "batch/storage/v1"
+	for len(pending) > 0 {
+		n := min(len(pending), maxBatchSize)
+		if rerr := sendBatch(ctx, hc.hc, u.String(), pending[:n]); rerr != nil {
+			for _, op := range pending[:n] {
+				op.Err = rerr
+			}
+			if err == nil {
+				err = rerr
+			}
+		}
+		pending = pending[n:]
+	}
+	return err
+}
+
+// captureTransport records the request it is asked to send and fails it with
+// errBatchCaptured.
+type captureTransport struct {
+	req *http.Request
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	req = req.Clone(context.Background())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	t.req = req
+	return nil, errBatchCaptured
+}
+
+// sendBatch sends the requests of ops in a single batch request and sets
+// their results from the parts of the response.
+func sendBatch(ctx context.Context, hc *http.Client, batchURL string, ops []*BatchResult) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, op := range ops {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {fmt.Sprintf("<%d>", i)},
+		})
+		if err != nil {
+			return err
+		}
+		if err := op.req.Write(pw); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, batchURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	res, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return fmt.Errorf("storage: batch response has content type %q, want multipart", res.Header.Get("Content-Type"))
+	}
+
+	done := make([]bool, len(ops))
+	mr := multipart.NewReader(res.Body, params["boundary"])
+	for pos := 0; ; pos++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("storage: reading batch response: %w", err)
+		}
+		// Parts are matched to operations by Content-ID, and by position
+		// if the response has none.
+		i := pos
+		id := strings.TrimPrefix(strings.Trim(part.Header.Get("Content-Id"), "<>"), "response-")
+		if n, err := strconv.Atoi(id); err == nil {
+			i = n
+		}
+		if i < 0 || i >= len(ops) || done[i] {
+			return fmt.Errorf("storage: unexpected part %q in batch response", id)
+		}
+		done[i] = true
+		ops[i].setResult(part)
+	}
+	for i, op := range ops {
+		if !done[i] {
+			op.Err = errors.New("storage: batch response has no result for the operation")
+		}
+	}
+	return nil
+}
+
+// setResult sets the result of the operation from the HTTP response in a
+// part of a batch response.
+func (r *BatchResult) setResult(part io.Reader) {
+	res, err := http.ReadResponse(bufio.NewReader(part), r.req)
+	if err != nil {
+		r.Err = fmt.Errorf("storage: reading batch response: %w", err)
+		return
+	}
+	defer res.Body.Close()
+	if err := googleapi.CheckResponse(res); err != nil {
+		r.Err = r.formatErr(err)
+		return
+	}
+	if r.isObject {
+		var obj raw.Object
+		if err := json.NewDecoder(res.Body).Decode(&obj); err != nil {
+			r.Err = fmt.Errorf("storage: decoding batch response: %w", err)
+			return
+		}
+		r.Attrs = newObject(&obj)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	srv, clients := newFakeServerClients(t)
+	bkt := clients["http"].Bucket("bucket")
+
+	// More objects than fit in one batch request.
+	const n = 150
+	for i := range n {
+		srv.WriteObject("bucket", fmt.Sprintf("delete-%d", i), []byte("x"))
+	}
+	gen := srv.WriteObject("bucket", "update", []byte("x"))
+	srv.WriteObject("bucket", "move", []byte("x"))
+	srv.WriteObject("bucket", "acl", []byte("x"))
+
+	b := clients["http"].Batch()
+	var deletes []*storage.BatchResult
+	for i := range n {
+		deletes = append(deletes, b.Delete(bkt.Object(fmt.Sprintf("delete-%d", i))))
+	}
+	update := b.Update(bkt.Object("update").Generation(gen), storage.ObjectAttrsToUpdate{
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"k": "v"},
+	})
+	move := b.Move(bkt.Object("move"), storage.MoveObjectDestination{Object: "moved"})
+	setACL := b.SetACL(bkt.Object("acl").ACL(), storage.AllUsers, storage.RoleReader)
+	missing := b.Delete(bkt.Object("missing"))
+	failed := b.Update(bkt.Object("update").If(storage.Conditions{MetagenerationMatch: 100}), storage.ObjectAttrsToUpdate{ContentType: "x"})
+	invalid := b.Delete(bkt.Object(""))
+	if err := b.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for i, r := range deletes {
+		if r.Err != nil {
+			t.Errorf("delete %d: %v", i, r.Err)
+		}
+	}
+	it := bkt.Objects(ctx, &storage.Query{Prefix: "delete-"})
+	if _, err := it.Next(); err == nil {
+		t.Error("objects remain after the batch delete")
+	}
+	if update.Err != nil {
+		t.Errorf("update: %v", update.Err)
+	} else if update.Attrs.ContentType != "text/plain" || update.Attrs.Metadata["k"] != "v" {
+		t.Errorf("update: got attrs %+v", update.Attrs)
+	}
+	if move.Err != nil {
+		t.Errorf("move: %v", move.Err)
+	} else if move.Attrs.Name != "moved" {
+		t.Errorf("move: got name %q, want %q", move.Attrs.Name, "moved")
+	}
+	if setACL.Err != nil {
+		t.Errorf("set ACL: %v", setACL.Err)
+	}
+	rules, err := bkt.Object("acl").ACL().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Entity != storage.AllUsers || rules[0].Role != storage.RoleReader {
+		t.Errorf("ACL after set: got %+v", rules)
+	}
+	if !errors.Is(missing.Err, storage.ErrObjectNotExist) {
+		t.Errorf("deleting a missing object: got %v, want ErrObjectNotExist", missing.Err)
+	}
+	var e *googleapi.Error
+	if !errors.As(failed.Err, &e) || e.Code != http.StatusPreconditionFailed {
+		t.Errorf("failed precondition: got %v, want status %d", failed.Err, http.StatusPreconditionFailed)
+	}
+	if invalid.Err == nil || errors.As(invalid.Err, &e) {
+		t.Errorf("invalid object name: got %v, want a client-side error", invalid.Err)
+	}
+
+	// Deleting the ACL entry in a second batch.
+	b = clients["http"].Batch()
+	deleteACL := b.DeleteACL(bkt.Object("acl").ACL(), storage.AllUsers)
+	if err := b.Run(ctx); err != nil || deleteACL.Err != nil {
+		t.Fatalf("Run: %v, delete ACL: %v", err, deleteACL.Err)
+	}
+	if rules, err := bkt.Object("acl").ACL().List(ctx); err != nil || len(rules) != 0 {
+		t.Errorf("ACL after delete: got %+v, %v", rules, err)
+	}
+	if err := b.Run(ctx); err == nil {
+		t.Error("second Run: got nil, want error")
+	}
+}
+
+func TestBatchRestore(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	client := clients["http"]
+	bkt := client.Bucket("soft-delete")
+	if err := bkt.Create(ctx, "project", &storage.BucketAttrs{
+		SoftDeletePolicy: &storage.SoftDeletePolicy{RetentionDuration: 24 * time.Hour},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	obj := bkt.Object("obj")
+	w := obj.NewWriter(ctx)
+	if _, err := w.Write([]byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gen := w.Attrs().Generation
+	if err := obj.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	b := client.Batch()
+	restore := b.Restore(obj.Generation(gen), nil)
+	missing := b.Restore(bkt.Object("missing").Generation(gen), &storage.RestoreOptions{CopySourceACL: true})
+	if err := b.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if restore.Err != nil {
+		t.Fatalf("restore: %v", restore.Err)
+	}
+	if restore.Attrs.Name != "obj" || restore.Attrs.Generation == gen {
+		t.Errorf("restore: got attrs %+v", restore.Attrs)
+	}
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, err := io.ReadAll(r); err != nil || string(got) != "content" {
+		t.Errorf("restored object: got %q, %v, want %q", got, err, "content")
+	}
+	if !errors.Is(missing.Err, storage.ErrObjectNotExist) {
+		t.Errorf("restoring a missing object: got %v, want ErrObjectNotExist", missing.Err)
+	}
+}
+
+func TestBatchGRPC(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	b := clients["grpc"].Batch()
+	r := b.Delete(clients["grpc"].Bucket("bucket").Object("obj"))
+	if err := b.Run(ctx); err == nil || r.Err == nil {
+		t.Errorf("Run with a gRPC client: got %v and result %v, want errors", err, r.Err)
+	}
+}
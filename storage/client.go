@@ -88,6 +88,9 @@ type storageClient interface {
 
 	NewRangeReader(ctx context.Context, params *newRangeReaderParams, opts ...storageOption) (*Reader, error)
 	OpenWriter(params *openWriterParams, opts ...storageOption) (internalWriter, error)
+	// QueryWriteStatus returns the number of bytes persisted in a resumable
+	// upload session, or the resulting object if the upload is complete.
+	QueryWriteStatus(ctx context.Context, session *ResumableSession, encryptionKey []byte, opts ...storageOption) (int64, *ObjectAttrs, error)
 
 	// IAM methods.
 
@@ -296,6 +299,13 @@ type openWriterParams struct {
 	setSize func(int64)
 	// setTakeoverOffset callback for returning offset to start writing from to Writer.
 	setTakeoverOffset func(int64)
+	// session - resumable session to continue - see `Writer.session`.
+	// Optional.
+	session *ResumableSession
+	// setSession callback for reporting the resumable session - see
+	// `Writer.SessionFunc`.
+	// Optional.
+	setSession func(*ResumableSession)
 }
 
 type newMultiRangeDownloaderParams struct {
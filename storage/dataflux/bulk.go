@@ -0,0 +1,333 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// defaultBulkBatchSize is the number of objects listed and processed
+	// between checkpoints of a bulk operation.
+	defaultBulkBatchSize = 10000
+	// defaultBulkMaxAttempts is the number of attempts made to apply an
+	// operation to an object.
+	defaultBulkMaxAttempts = 5
+)
+
+// BulkOperation is an operation applied to each object listed by [RunBulk].
+// The bucket handle is configured with the retry options of the run. attrs
+// are the attributes of the object as listed.
+//
+// An operation should be idempotent, since the objects of a batch that was
+// interrupted are processed again when the run is resumed from a checkpoint.
+type BulkOperation func(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs) error
+
+// BulkInput contains options for running an operation on listed objects.
+type BulkInput struct {
+	// ListerInput selects the objects to operate on. Its BatchSize is the
+	// minimum number of objects listed and processed between checkpoints,
+	// and defaults to 10000. Batches hold at least a page of listing results.
+	ListerInput
+
+	// Workers is the number of objects operated on in parallel. Default
+	// value is 10x number of available CPU. Optional.
+	Workers int
+
+	// RateLimit is the maximum number of operations started per second.
+	// Default value is 0, which means no limit. Optional.
+	RateLimit float64
+
+	// MaxAttempts is the maximum number of attempts made to apply the
+	// operation to an object when it fails with a retryable error. Default
+	// value is 5. Optional.
+	MaxAttempts int
+
+	// Checkpoint, if set, resumes a run from a checkpoint reported by the
+	// Progress function of an earlier run with the same bucket and query.
+	// Optional.
+	Checkpoint *Checkpoint
+
+	// Progress, if set, is called after each batch of objects has been
+	// processed. Optional.
+	Progress func(BulkProgress)
+
+	// OnError, if set, is called for each object that the operation failed
+	// for. If it returns nil, the object is counted as failed and the run
+	// continues; otherwise the run stops and returns the error. If OnError is
+	// nil, the run stops at the first failure. Optional.
+	OnError func(attrs *storage.ObjectAttrs, err error) error
+}
+
+// BulkProgress describes the progress of a bulk operation.
+type BulkProgress struct {
+	// Succeeded is the number of objects the operation succeeded for.
+	Succeeded int64
+
+	// Failed is the number of objects the operation failed for.
+	Failed int64
+
+	// Checkpoint records the objects that remain to be processed. Pass it
+	// to a later call to RunBulk to resume the run.
+	Checkpoint *Checkpoint
+}
+
+// Checkpoint records the progress of a bulk operation: every object outside
+// its remaining ranges or page token has been processed. A Checkpoint can be
+// serialized as JSON.
+type Checkpoint struct {
+	// BucketName and Prefix are the bucket and query prefix of the run.
+	BucketName string `json:"bucketName"`
+	Prefix     string `json:"prefix,omitempty"`
+
+	// Ranges are the ranges of object names, relative to Prefix, that remain
+	// to be listed with work stealing.
+	Ranges []CheckpointRange `json:"ranges,omitempty"`
+
+	// PageToken is the token of the next page of a sequential listing.
+	PageToken string `json:"pageToken,omitempty"`
+
+	// Done reports whether all objects have been processed.
+	Done bool `json:"done,omitempty"`
+}
+
+// CheckpointRange is a range of object names. An empty End means the range is
+// unbounded.
+type CheckpointRange struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// RunBulk lists the objects selected by in and applies op to each of them. It
+// returns the progress of the run when all objects have been processed or the
+// run stops.
+//
+// If the run stops because of an error, the returned progress holds the
+// checkpoint of the last batch that was completely processed.
+func RunBulk(ctx context.Context, c *storage.Client, in *BulkInput, op BulkOperation) (*BulkProgress, error) {
+	lin := in.ListerInput
+	if lin.BatchSize == 0 {
+		lin.BatchSize = defaultBulkBatchSize
+	}
+	workers := in.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU() * 10
+	}
+	maxAttempts := in.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultBulkMaxAttempts
+	}
+	bucket := c.Bucket(in.BucketName).Retryer(storage.WithMaxAttempts(maxAttempts))
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if in.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(in.RateLimit), 1)
+	}
+
+	progress := &BulkProgress{Checkpoint: in.Checkpoint}
+	if cp := in.Checkpoint; cp != nil {
+		if cp.BucketName != in.BucketName || cp.Prefix != in.Query.Prefix {
+			return nil, fmt.Errorf("dataflux: checkpoint of bucket %q and prefix %q does not match bucket %q and prefix %q", cp.BucketName, cp.Prefix, in.BucketName, in.Query.Prefix)
+		}
+		if cp.Done {
+			return progress, nil
+		}
+	}
+	lister := NewLister(c, &lin)
+	defer lister.Close()
+	if in.Checkpoint != nil {
+		lister.restore(in.Checkpoint)
+	}
+
+	for {
+		objects, listErr := lister.NextBatch(ctx)
+		if listErr != nil && listErr != iterator.Done {
+			return progress, listErr
+		}
+		var succeeded, failed atomic.Int64
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(workers)
+		for _, attrs := range objects {
+			if err := limiter.Wait(gctx); err != nil {
+				break
+			}
+			g.Go(func() error {
+				err := op(gctx, bucket, attrs)
+				if err == nil {
+					succeeded.Add(1)
+					return nil
+				}
+				if in.OnError == nil {
+					return fmt.Errorf("dataflux: object %q: %w", attrs.Name, err)
+				}
+				if err := in.OnError(attrs, err); err != nil {
+					return err
+				}
+				failed.Add(1)
+				return nil
+			})
+		}
+		err := g.Wait()
+		progress.Succeeded += succeeded.Load()
+		progress.Failed += failed.Load()
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return progress, err
+		}
+		progress.Checkpoint = lister.checkpoint(listErr == iterator.Done)
+		if in.Progress != nil {
+			in.Progress(*progress)
+		}
+		if listErr == iterator.Done {
+			return progress, nil
+		}
+	}
+}
+
+// restore sets the remaining work of the Lister to that of a checkpoint.
+func (c *Lister) restore(cp *Checkpoint) {
+	if len(cp.Ranges) == 0 {
+		c.method = sequential
+		c.pageToken = cp.PageToken
+		c.ranges = nil
+		return
+	}
+	c.method = worksteal
+	c.ranges = make(chan *listRange, max(c.parallelism*2, len(cp.Ranges)+c.parallelism))
+	for _, r := range cp.Ranges {
+		c.ranges <- &listRange{startRange: r.Start, endRange: r.End}
+	}
+}
+
+// checkpoint returns a checkpoint of the remaining work of the Lister. It must
+// not be called while listing.
+func (c *Lister) checkpoint(done bool) *Checkpoint {
+	cp := &Checkpoint{
+		BucketName: c.bucket.BucketName(),
+		Prefix:     c.query.Prefix,
+		Done:       done,
+	}
+	if done {
+		return cp
+	}
+	if c.method == sequential {
+		cp.PageToken = c.pageToken
+		return cp
+	}
+	cp.Ranges = []CheckpointRange{}
+	for range len(c.ranges) {
+		r := <-c.ranges
+		cp.Ranges = append(cp.Ranges, CheckpointRange{Start: r.startRange, End: r.endRange})
+		c.ranges <- r
+	}
+	return cp
+}
+
+// DeleteOperation returns a BulkOperation that deletes the listed generation
+// of each object. Objects that no longer exist are ignored.
+func DeleteOperation() BulkOperation {
+	return func(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs) error {
+		err := bucket.Object(attrs.Name).Generation(attrs.Generation).Delete(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil
+		}
+		return err
+	}
+}
+
+// UpdateOperation returns a BulkOperation that updates the attributes of each
+// object, such as its metadata. The update is conditional on the object not
+// having been modified since it was listed.
+func UpdateOperation(uattrs storage.ObjectAttrsToUpdate) BulkOperation {
+	return func(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs) error {
+		o := bucket.Object(attrs.Name).Generation(attrs.Generation).If(storage.Conditions{MetagenerationMatch: attrs.Metageneration})
+		_, err := o.Update(ctx, uattrs)
+		return err
+	}
+}
+
+// CopyInput contains options for copying objects with [CopyOperation].
+type CopyInput struct {
+	// Destination is the bucket to copy objects to. The default is the bucket
+	// the objects are listed from. Copies to another bucket are retried
+	// according to the retry options of Destination. Optional.
+	Destination *storage.BucketHandle
+
+	// DestinationName returns the name of the copy of an object. The
+	// default is the name of the object. Optional.
+	DestinationName func(name string) string
+
+	// StorageClass, if set, is the storage class of the copies. Optional.
+	StorageClass string
+
+	// KMSKeyName, if set, is the Cloud KMS key that encrypts the copies.
+	// Optional.
+	KMSKeyName string
+}
+
+// CopyOperation returns a BulkOperation that copies the listed generation of
+// each object, rewriting it if needed. Copying an object onto itself with a
+// different storage class or KMS key changes the storage class or key of the
+// object; such a copy is conditional on the object not having been replaced
+// since it was listed.
+//
+// If StorageClass or KMSKeyName is set, the content type, encodings, cache
+// control and metadata of the copies are those of the listed attributes, so
+// the query of the run must select them.
+//
+// The copies must not be listed by the run: copying objects within a bucket
+// requires a DestinationName outside the listed prefix or range.
+func CopyOperation(in CopyInput) BulkOperation {
+	return func(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs) error {
+		dstBucket := bucket
+		if in.Destination != nil {
+			dstBucket = in.Destination
+		}
+		name := attrs.Name
+		if in.DestinationName != nil {
+			name = in.DestinationName(attrs.Name)
+		}
+		dst := dstBucket.Object(name)
+		if dstBucket.BucketName() == bucket.BucketName() && name == attrs.Name {
+			dst = dst.If(storage.Conditions{GenerationMatch: attrs.Generation})
+		}
+		copier := dst.CopierFrom(bucket.Object(attrs.Name).Generation(attrs.Generation))
+		if in.StorageClass != "" || in.KMSKeyName != "" {
+			// Attributes set on the copier replace those of the source.
+			copier.ObjectAttrs = storage.ObjectAttrs{
+				ContentType:        attrs.ContentType,
+				ContentLanguage:    attrs.ContentLanguage,
+				ContentEncoding:    attrs.ContentEncoding,
+				ContentDisposition: attrs.ContentDisposition,
+				CacheControl:       attrs.CacheControl,
+				Metadata:           attrs.Metadata,
+				StorageClass:       in.StorageClass,
+			}
+			copier.DestinationKMSKeyName = in.KMSKeyName
+		}
+		_, err := copier.Run(ctx)
+		return err
+	}
+}
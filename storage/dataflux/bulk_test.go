@@ -0,0 +1,221 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataflux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/storagetest"
+	"google.golang.org/api/iterator"
+)
+
+// newFakeClient returns a client of a fake server with the bucket "bucket"
+// holding n objects with the prefix "p/" and one object outside the prefix.
+func newFakeClient(t *testing.T, n int) (*storagetest.Server, *storage.Client) {
+	t.Helper()
+	srv := storagetest.NewTestServer(t, "bucket")
+	for i := range n {
+		srv.WriteObject("bucket", fmt.Sprintf("p/%04d", i), []byte("x"))
+	}
+	srv.WriteObject("bucket", "other", []byte("x"))
+	return srv, srv.TestClient(t)
+}
+
+func listNames(t *testing.T, bucket *storage.BucketHandle) []string {
+	t.Helper()
+	var names []string
+	it := bucket.Objects(context.Background(), nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, attrs.Name)
+	}
+}
+
+func TestRunBulkDelete(t *testing.T) {
+	ctx := context.Background()
+	// Listing pages hold up to 5000 objects, so there must be more objects
+	// than that for several batches.
+	_, client := newFakeClient(t, 6000)
+
+	var batches int
+	in := &BulkInput{
+		ListerInput: ListerInput{
+			BucketName:  "bucket",
+			Parallelism: 4,
+			BatchSize:   1000,
+			Query:       storage.Query{Prefix: "p/"},
+		},
+		Workers:  8,
+		Progress: func(BulkProgress) { batches++ },
+	}
+	progress, err := RunBulk(ctx, client, in, DeleteOperation())
+	if err != nil {
+		t.Fatalf("RunBulk: %v", err)
+	}
+	if progress.Succeeded != 6000 || progress.Failed != 0 || !progress.Checkpoint.Done {
+		t.Errorf("got progress %+v, want 6000 succeeded and done", progress)
+	}
+	if batches < 2 {
+		t.Errorf("got %d batches, want several", batches)
+	}
+	if got := listNames(t, client.Bucket("bucket")); !slices.Equal(got, []string{"other"}) {
+		t.Errorf("remaining objects: got %q, want only %q", got, "other")
+	}
+
+	// Resuming a completed run does nothing.
+	in.Checkpoint = progress.Checkpoint
+	if progress, err = RunBulk(ctx, client, in, DeleteOperation()); err != nil || progress.Succeeded != 0 {
+		t.Errorf("resuming a completed run: got %+v, %v", progress, err)
+	}
+}
+
+func TestRunBulkResume(t *testing.T) {
+	ctx := context.Background()
+	_, client := newFakeClient(t, 6000)
+
+	// The first run fails once most objects have been deleted.
+	var calls atomic.Int64
+	failing := func(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs) error {
+		if calls.Add(1) > 5500 {
+			return errors.New("injected failure")
+		}
+		return DeleteOperation()(ctx, bucket, attrs)
+	}
+	in := &BulkInput{
+		ListerInput: ListerInput{
+			BucketName:  "bucket",
+			Parallelism: 4,
+			BatchSize:   1000,
+			Query:       storage.Query{Prefix: "p/"},
+		},
+		Workers: 8,
+	}
+	progress, err := RunBulk(ctx, client, in, failing)
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("RunBulk: got %v, want the injected failure", err)
+	}
+	if progress.Checkpoint == nil || progress.Checkpoint.Done {
+		t.Fatalf("got checkpoint %+v, want an incomplete checkpoint", progress.Checkpoint)
+	}
+
+	// The checkpoint survives serialization and resumes the run.
+	b, err := json.Marshal(progress.Checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Checkpoint = &Checkpoint{}
+	if err := json.Unmarshal(b, in.Checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := RunBulk(ctx, client, in, DeleteOperation())
+	if err != nil {
+		t.Fatalf("resumed RunBulk: %v", err)
+	}
+	if !resumed.Checkpoint.Done || resumed.Succeeded >= 6000 {
+		t.Errorf("resumed run: got %+v, want a done run over the remaining objects", resumed)
+	}
+	if got := listNames(t, client.Bucket("bucket")); !slices.Equal(got, []string{"other"}) {
+		t.Errorf("remaining objects: got %q, want only %q", got, "other")
+	}
+
+	// A checkpoint of another prefix is rejected.
+	in.Query.Prefix = "q/"
+	if _, err := RunBulk(ctx, client, in, DeleteOperation()); err == nil {
+		t.Error("resuming with another prefix: got nil, want error")
+	}
+}
+
+func TestRunBulkOnError(t *testing.T) {
+	ctx := context.Background()
+	_, client := newFakeClient(t, 20)
+	var failed []string
+	in := &BulkInput{
+		ListerInput: ListerInput{BucketName: "bucket", Query: storage.Query{Prefix: "p/"}},
+		OnError: func(attrs *storage.ObjectAttrs, err error) error {
+			failed = append(failed, attrs.Name)
+			return nil
+		},
+		Workers: 1,
+	}
+	op := func(ctx context.Context, bucket *storage.BucketHandle, attrs *storage.ObjectAttrs) error {
+		if strings.HasSuffix(attrs.Name, "5") {
+			return errors.New("odd object")
+		}
+		return nil
+	}
+	progress, err := RunBulk(ctx, client, in, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(failed)
+	if progress.Succeeded != 18 || progress.Failed != 2 || !slices.Equal(failed, []string{"p/0005", "p/0015"}) {
+		t.Errorf("got progress %+v and failures %q", progress, failed)
+	}
+}
+
+func TestRunBulkUpdateAndCopy(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newFakeClient(t, 30)
+	srv.CreateBucket("dst")
+	in := &BulkInput{ListerInput: ListerInput{BucketName: "bucket", Query: storage.Query{Prefix: "p/"}}}
+
+	if _, err := RunBulk(ctx, client, in, UpdateOperation(storage.ObjectAttrsToUpdate{Metadata: map[string]string{"label": "x"}})); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	attrs, err := client.Bucket("bucket").Object("p/0007").Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Metadata["label"] != "x" {
+		t.Errorf("metadata after update: got %v", attrs.Metadata)
+	}
+
+	copyOp := CopyOperation(CopyInput{
+		Destination:     client.Bucket("dst"),
+		DestinationName: func(name string) string { return "copy-" + strings.TrimPrefix(name, "p/") },
+	})
+	progress, err := RunBulk(ctx, client, in, copyOp)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if progress.Succeeded != 30 {
+		t.Errorf("copy: got %d objects copied, want 30", progress.Succeeded)
+	}
+	names := listNames(t, client.Bucket("dst"))
+	if len(names) != 30 || names[0] != "copy-0000" {
+		t.Errorf("copies: got %q", names)
+	}
+	attrs, err = client.Bucket("dst").Object("copy-0007").Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Metadata["label"] != "x" {
+		t.Errorf("metadata of the copy: got %v", attrs.Metadata)
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// KeyWrapper wraps and unwraps the data keys of objects written with
+// client-side envelope encryption. See [ObjectHandle.EnvelopeEncryption].
+//
+// A KeyWrapper typically encrypts data keys with a key encryption key that
+// never leaves a key management service. For example, a KeyWrapper backed by
+// Cloud KMS calls the Encrypt and Decrypt methods of a symmetric CryptoKey.
+// [NewLocalKeyWrapper] returns a KeyWrapper that uses a key held in memory.
+//
+// A KeyWrapper must be safe for concurrent use.
+type KeyWrapper interface {
+	// WrapKey encrypts the data key of an object. The result is stored in the
+	// object's metadata.
+	WrapKey(ctx context.Context, key []byte) (wrapped []byte, err error)
+
+	// UnwrapKey decrypts a data key that was encrypted by WrapKey.
+	UnwrapKey(ctx context.Context, wrapped []byte) (key []byte, err error)
+}
+
+// NewLocalKeyWrapper returns a KeyWrapper that wraps data keys with AES-256-GCM
+// using kek, a 32-byte key encryption key.
+func NewLocalKeyWrapper(kek []byte) (KeyWrapper, error) {
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("storage: key encryption key must be 32 bytes, got %d", len(kek))
+	}
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	return &localKeyWrapper{aead: aead}, nil
+}
+
+type localKeyWrapper struct {
+	aead cipher.AEAD
+}
+
+func (w *localKeyWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize(), w.aead.NonceSize()+len(key)+w.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return w.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func (w *localKeyWrapper) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < w.aead.NonceSize() {
+		return nil, errors.New("storage: wrapped key is too short")
+	}
+	nonce, ct := wrapped[:w.aead.NonceSize()], wrapped[w.aead.NonceSize():]
+	key, err := w.aead.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return nil, fmt.Errorf("storage: unwrapping key: %w", err)
+	}
+	return key, nil
+}
+
+// EnvelopeEncryption returns a new ObjectHandle that encrypts the object's
+// content on the client before it is uploaded, and decrypts it when it is
+// read, so that the service never sees the content in plaintext.
+//
+// Each object written through the handle is encrypted with its own random
+// data key using AES-256-GCM. The data key is wrapped by kw and stored, with
+// the other parameters needed to decrypt the object, in the object's
+// metadata under keys that start with "envelope-"; updates to the object's
+// metadata must preserve them. The content is encrypted in segments of 64
+// KiB, each authenticated separately, so range reads only download and
+// decrypt the segments that overlap the range.
+//
+// Readers created by the handle decrypt transparently: their Attrs.Size and
+// Attrs.StartOffset refer to the plaintext, and reading an object that was
+// not written with envelope encryption returns an error. Opening a Reader
+// first fetches the object's attributes to unwrap its data key. The
+// ObjectAttrs returned by [ObjectHandle.Attrs] and [Writer.Attrs] describe the
+// stored ciphertext, which is 16 bytes longer per segment than the
+// plaintext.
+//
+// Writers created by the handle encrypt transparently. They cannot be used
+// with Append, resumable sessions, Content-Encoding "gzip", or checksums
+// provided by the caller through Writer.SendCRC32C or ObjectAttrs.MD5, since
+// those checksums would describe the plaintext. The service still computes
+// and verifies the checksums of the ciphertext.
+//
+// Envelope encryption is independent of [ObjectHandle.Key], which supplies
+// an encryption key to the service, and the two can be combined. Objects
+// written with envelope encryption can be copied or moved, but not
+// composed.
+func (o *ObjectHandle) EnvelopeEncryption(kw KeyWrapper) *ObjectHandle {
+	o2 := *o
+	o2.keyWrapper = kw
+	return &o2
+}
+
+const (
+	// Metadata keys of envelope-encrypted objects.
+	envelopeAlgorithmKey   = "envelope-algorithm"
+	envelopeWrappedKeyKey  = "envelope-wrapped-key"
+	envelopeNoncePrefixKey = "envelope-nonce-prefix"
+	envelopeSegmentSizeKey = "envelope-segment-size"
+
+	// envelopeAlgorithm identifies the segmented AEAD scheme: AES-256-GCM
+	// with a nonce made of a random 7-byte prefix, the 4-byte big-endian
+	// segment index and a byte that is 1 for the last segment and 0
+	// otherwise.
+	envelopeAlgorithm = "AES256_GCM_SEGMENTED"
+
+	envelopeKeySize         = 32
+	envelopeNoncePrefixSize = 7
+	envelopeTagSize         = 16
+	envelopeSegmentSize     = 64 << 10
+)
+
+// envelope holds the parameters needed to encrypt or decrypt an object.
+type envelope struct {
+	aead        cipher.AEAD
+	noncePrefix []byte
+	segmentSize int // size of an encrypted segment
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newEnvelope creates the parameters for a new object and returns them
+// along with the metadata that records them.
+func newEnvelope(ctx context.Context, kw KeyWrapper) (*envelope, map[string]string, error) {
+	key := make([]byte, envelopeKeySize)
+	prefix := make([]byte, envelopeNoncePrefixSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := kw.WrapKey(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage: wrapping data key: %w", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	md := map[string]string{
+		envelopeAlgorithmKey:   envelopeAlgorithm,
+		envelopeWrappedKeyKey:  base64.StdEncoding.EncodeToString(wrapped),
+		envelopeNoncePrefixKey: base64.StdEncoding.EncodeToString(prefix),
+		envelopeSegmentSizeKey: strconv.Itoa(envelopeSegmentSize),
+	}
+	return &envelope{aead: aead, noncePrefix: prefix, segmentSize: envelopeSegmentSize}, md, nil
+}
+
+// parseEnvelope reads the parameters of an object from its metadata.
+func parseEnvelope(ctx context.Context, kw KeyWrapper, md map[string]string) (*envelope, error) {
+	alg, ok := md[envelopeAlgorithmKey]
+	if !ok {
+		return nil, errors.New("storage: object was not written with envelope encryption")
+	}
+	if alg != envelopeAlgorithm {
+		return nil, fmt.Errorf("storage: unsupported envelope encryption algorithm %q", alg)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(md[envelopeWrappedKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid envelope wrapped key: %w", err)
+	}
+	prefix, err := base64.StdEncoding.DecodeString(md[envelopeNoncePrefixKey])
+	if err != nil || len(prefix) != envelopeNoncePrefixSize {
+		return nil, fmt.Errorf("storage: invalid envelope nonce prefix %q", md[envelopeNoncePrefixKey])
+	}
+	segSize, err := strconv.Atoi(md[envelopeSegmentSizeKey])
+	if err != nil || segSize <= envelopeTagSize {
+		return nil, fmt.Errorf("storage: invalid envelope segment size %q", md[envelopeSegmentSizeKey])
+	}
+	key, err := kw.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("storage: unwrapping data key: %w", err)
+	}
+	if len(key) != envelopeKeySize {
+		return nil, fmt.Errorf("storage: unwrapped data key has %d bytes, want %d", len(key), envelopeKeySize)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, noncePrefix: prefix, segmentSize: segSize}, nil
+}
+
+// plainSegmentSize returns the size of the plaintext of a full segment.
+func (e *envelope) plainSegmentSize() int {
+	return e.segmentSize - envelopeTagSize
+}
+
+func (e *envelope) nonce(seg int64, last bool) []byte {
+	nonce := make([]byte, envelopeNoncePrefixSize+5)
+	copy(nonce, e.noncePrefix)
+	binary.BigEndian.PutUint32(nonce[envelopeNoncePrefixSize:], uint32(seg))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// plaintextSize returns the size of the plaintext of an object whose
+// ciphertext has the given size. The ciphertext of an empty object is a
+// single empty segment.
+func (e *envelope) plaintextSize(size int64) (int64, error) {
+	segSize := int64(e.segmentSize)
+	segs := (size + segSize - 1) / segSize
+	if segs == 0 || size-(segs-1)*segSize < envelopeTagSize || segs > 1<<32 {
+		return 0, fmt.Errorf("storage: invalid size %d for an envelope-encrypted object", size)
+	}
+	return size - segs*envelopeTagSize, nil
+}
+
+// segmentEncrypter encrypts the data written to it in segments and passes
+// the ciphertext to out. Since the last segment is encrypted differently,
+// a full segment is only encrypted once more data is written, or on close.
+type segmentEncrypter struct {
+	env *envelope
+	out func([]byte) (int, error)
+	buf []byte // plaintext of the current segment
+	seg int64
+}
+
+func newSegmentEncrypter(env *envelope, out func([]byte) (int, error)) *segmentEncrypter {
+	return &segmentEncrypter{
+		env: env,
+		out: out,
+		buf: make([]byte, 0, env.segmentSize),
+	}
+}
+
+func (s *segmentEncrypter) Write(p []byte) (int, error) {
+	size := s.env.plainSegmentSize()
+	var n int
+	for len(p) > 0 {
+		if len(s.buf) == size {
+			if err := s.flush(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(s.buf[len(s.buf):size], p)
+		s.buf = s.buf[:len(s.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// close encrypts the last segment.
+func (s *segmentEncrypter) close() error {
+	return s.flush(true)
+}
+
+func (s *segmentEncrypter) flush(last bool) error {
+	if s.seg >= 1<<32 {
+		return errors.New("storage: object is too large for envelope encryption")
+	}
+	ct := s.env.aead.Seal(s.buf[:0], s.env.nonce(s.seg, last), s.buf, nil)
+	if _, err := s.out(ct); err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	s.seg++
+	return nil
+}
+
+// segmentDecrypter decrypts a range of an object from the ciphertext of the
+// segments that overlap it.
+type segmentDecrypter struct {
+	env     *envelope
+	r       io.ReadCloser // ciphertext, starting at the beginning of segment seg
+	seg     int64
+	lastSeg int64 // index of the object's last segment
+	lastLen int   // ciphertext size of the object's last segment
+	skip    int   // plaintext bytes to skip in the first segment
+	remain  int64 // plaintext bytes left to return
+	ct      []byte
+	pt      []byte // decrypted plaintext not yet returned
+}
+
+func (d *segmentDecrypter) Read(p []byte) (int, error) {
+	if d.remain <= 0 {
+		return 0, io.EOF
+	}
+	if len(d.pt) == 0 {
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pt[:min(int64(len(d.pt)), d.remain)])
+	d.pt = d.pt[n:]
+	d.remain -= int64(n)
+	return n, nil
+}
+
+// next reads and decrypts the next segment.
+func (d *segmentDecrypter) next() error {
+	last := d.seg == d.lastSeg
+	size := d.env.segmentSize
+	if last {
+		size = d.lastLen
+	}
+	if _, err := io.ReadFull(d.r, d.ct[:size]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	pt, err := d.env.aead.Open(d.ct[:0], d.env.nonce(d.seg, last), d.ct[:size], nil)
+	if err != nil {
+		return fmt.Errorf("storage: decrypting segment %d of envelope-encrypted object: %w", d.seg, err)
+	}
+	d.pt = pt[d.skip:]
+	d.skip = 0
+	d.seg++
+	return nil
+}
+
+func (d *segmentDecrypter) Close() error {
+	return d.r.Close()
+}
+
+// initEnvelope sets up envelope encryption for a Writer whose ObjectHandle
+// has a KeyWrapper, adding the envelope parameters to the object's metadata.
+func (w *Writer) initEnvelope() error {
+	if w.enc != nil {
+		return nil
+	}
+	switch {
+	case w.Append:
+		return errors.New("storage: envelope encryption cannot be used with Writer.Append")
+	case w.SessionFunc != nil || w.session != nil:
+		return errors.New("storage: envelope encryption cannot be used with resumable sessions")
+	case w.SendCRC32C || len(w.MD5) > 0:
+		return errors.New("storage: envelope encryption cannot be used with checksums provided by the caller")
+	case w.ContentEncoding == "gzip":
+		return errors.New(`storage: envelope encryption cannot be used with Content-Encoding "gzip"`)
+	}
+	env, md, err := newEnvelope(w.ctx, w.o.keyWrapper)
+	if err != nil {
+		return err
+	}
+	// The envelope parameters replace any user metadata with the same keys.
+	for k, v := range w.Metadata {
+		if _, ok := md[k]; !ok {
+			md[k] = v
+		}
+	}
+	w.Metadata = md
+	w.enc = newSegmentEncrypter(env, w.write)
+	return nil
+}
+
+// newEnvelopeRangeReader is NewRangeReader for a handle with a KeyWrapper.
+func (o *ObjectHandle) newEnvelopeRangeReader(ctx context.Context, offset, length int64, opts ...ReaderOption) (*Reader, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if offset < 0 && length >= 0 {
+		return nil, fmt.Errorf("storage: invalid offset %d < 0 requires negative length", offset)
+	}
+	plain := *o
+	plain.keyWrapper = nil
+	attrs, err := plain.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	env, err := parseEnvelope(ctx, o.keyWrapper, attrs.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	size, err := env.plaintextSize(attrs.Size)
+	if err != nil {
+		return nil, err
+	}
+	start := offset
+	if start < 0 {
+		start = max(0, size+offset)
+	}
+	if start > size {
+		return nil, fmt.Errorf("storage: offset %d is beyond the object size %d", offset, size)
+	}
+	end := size
+	if length >= 0 {
+		end = min(size, start+length)
+	}
+
+	// Read the segments that overlap [start, end) of the pinned generation.
+	plainSeg, segSize := int64(env.plainSegmentSize()), int64(env.segmentSize)
+	first := start / plainSeg
+	ctStart, ctLength := first*segSize, int64(-1)
+	switch {
+	case end == start:
+		ctStart, ctLength = 0, 0
+	case end < size:
+		ctLength = ((end-1)/plainSeg+1)*segSize - ctStart
+	}
+	plain.gen = attrs.Generation
+	plain.conds = nil
+	r, err := plain.NewRangeReader(ctx, ctStart, ctLength, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if r.Attrs.Decompressed {
+		r.Close()
+		return nil, errors.New("storage: envelope-encrypted object was decompressed by the service")
+	}
+	lastSeg := (attrs.Size - 1) / segSize
+	r.reader = &segmentDecrypter{
+		env:     env,
+		r:       r.reader,
+		seg:     first,
+		lastSeg: lastSeg,
+		lastLen: int(attrs.Size - lastSeg*segSize),
+		skip:    int(start - first*plainSeg),
+		remain:  end - start,
+		ct:      make([]byte, env.segmentSize),
+	}
+	r.Attrs.Size = size
+	r.Attrs.StartOffset = start
+	r.Attrs.CRC32C = 0
+	r.size = size
+	r.remain = end - start
+	return r, nil
+}
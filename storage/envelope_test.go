@@ -0,0 +1,200 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/storagetest"
+)
+
+// Tests that use the fake server, which lives in a package that imports
+// storage, are in the external test package.
+
+// newFakeServerClients starts a fake server with a bucket named "bucket" and
+// returns HTTP and gRPC clients of it.
+func newFakeServerClients(t *testing.T) (*storagetest.Server, map[string]*storage.Client) {
+	t.Helper()
+	srv := storagetest.NewTestServer(t, "bucket")
+	return srv, map[string]*storage.Client{"http": srv.TestClient(t), "grpc": srv.TestGRPCClient(t)}
+}
+
+func newTestKeyWrapper(t *testing.T) storage.KeyWrapper {
+	t.Helper()
+	kek := make([]byte, 32)
+	rand.Read(kek)
+	kw, err := storage.NewLocalKeyWrapper(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kw
+}
+
+func TestEnvelopeEncryptionRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	kw := newTestKeyWrapper(t)
+	const seg = 64<<10 - 16 // plaintext bytes per segment
+
+	for name, client := range clients {
+		for _, size := range []int{0, 1, seg - 1, seg, seg + 1, 3*seg + 100} {
+			t.Run(fmt.Sprintf("%s/%d", name, size), func(t *testing.T) {
+				data := make([]byte, size)
+				rand.Read(data)
+				obj := client.Bucket("bucket").Object(fmt.Sprintf("%s-%d", name, size)).EnvelopeEncryption(kw)
+
+				w := obj.NewWriter(ctx)
+				w.Metadata = map[string]string{"user": "value"}
+				// Write in uneven pieces to cross segment boundaries.
+				for p := data; len(p) > 0; {
+					n := min(len(p), 1000)
+					if _, err := w.Write(p[:n]); err != nil {
+						t.Fatal(err)
+					}
+					p = p[n:]
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if got := w.Attrs().Metadata["user"]; got != "value" {
+					t.Errorf("user metadata: got %q, want %q", got, "value")
+				}
+
+				// The stored object must not contain the plaintext.
+				stored, err := client.Bucket("bucket").Object(obj.ObjectName()).NewReader(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ct, err := io.ReadAll(stored)
+				stored.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if size >= 16 && bytes.Contains(ct, data) {
+					t.Error("stored object contains the plaintext")
+				}
+
+				for _, r := range []struct{ offset, length int64 }{
+					{0, -1},
+					{0, 0},
+					{1, -1},
+					{seg - 2, 4},
+					{seg, seg},
+					{int64(size) / 2, 10},
+					{-10, -1},
+					{int64(size), -1},
+				} {
+					if r.offset > int64(size) {
+						continue
+					}
+					start := r.offset
+					if start < 0 {
+						start = max(0, int64(size)+start)
+					}
+					end := int64(size)
+					if r.length >= 0 {
+						end = min(end, start+r.length)
+					}
+					if start > end {
+						continue
+					}
+					rd, err := obj.NewRangeReader(ctx, r.offset, r.length)
+					if err != nil {
+						t.Fatalf("NewRangeReader(%d, %d): %v", r.offset, r.length, err)
+					}
+					got, err := io.ReadAll(rd)
+					rd.Close()
+					if err != nil {
+						t.Fatalf("reading range (%d, %d): %v", r.offset, r.length, err)
+					}
+					if !bytes.Equal(got, data[start:end]) {
+						t.Errorf("range (%d, %d): got %d bytes, want %d bytes of plaintext", r.offset, r.length, len(got), end-start)
+					}
+					if rd.Attrs.Size != int64(size) || rd.Attrs.StartOffset != start {
+						t.Errorf("range (%d, %d): got size %d and start offset %d, want %d and %d", r.offset, r.length, rd.Attrs.Size, rd.Attrs.StartOffset, size, start)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEnvelopeEncryptionErrors(t *testing.T) {
+	ctx := context.Background()
+	srv, clients := newFakeServerClients(t)
+	client := clients["http"]
+	kw := newTestKeyWrapper(t)
+	bkt := client.Bucket("bucket")
+
+	w := bkt.Object("secret").EnvelopeEncryption(kw).NewWriter(ctx)
+	io.WriteString(w, strings.Repeat("secret ", 20000))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A different key encryption key cannot unwrap the data key.
+	if _, err := bkt.Object("secret").EnvelopeEncryption(newTestKeyWrapper(t)).NewReader(ctx); err == nil {
+		t.Error("reading with the wrong key: got nil, want error")
+	}
+
+	// Objects written without envelope encryption cannot be read with it.
+	srv.WriteObject("bucket", "plain", []byte("plain"))
+	if _, err := bkt.Object("plain").EnvelopeEncryption(kw).NewReader(ctx); err == nil {
+		t.Error("reading a plaintext object: got nil, want error")
+	}
+
+	// Tampering with the ciphertext is detected. Copying the metadata of the
+	// encrypted object to a modified copy of its content keeps the key valid.
+	attrs, err := bkt.Object("secret").Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := bkt.Object("secret").NewReader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, _ := io.ReadAll(r)
+	r.Close()
+	ct[len(ct)/2] ^= 1
+	tw := bkt.Object("tampered").NewWriter(ctx)
+	tw.Metadata = attrs.Metadata
+	tw.Write(ct)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = bkt.Object("tampered").EnvelopeEncryption(kw).NewReader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(r)
+	r.Close()
+	if err == nil {
+		t.Error("reading a tampered object: got nil, want error")
+	}
+
+	// Caller-provided checksums describe the plaintext and are rejected.
+	w = bkt.Object("checksummed").EnvelopeEncryption(kw).NewWriter(ctx)
+	w.SendCRC32C = true
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Error("writing with SendCRC32C: got nil, want error")
+	}
+}
@@ -15,12 +15,15 @@
 package storage_test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
+	"io/fs"
 	"log"
 	"mime/multipart"
 	"net/http"
@@ -103,6 +106,41 @@ func ExampleBucketHandle_Attrs() {
 	fmt.Println(attrs)
 }
 
+func ExampleBucketHandle_FS() {
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		// TODO: handle error.
+	}
+	// Serve the objects under "static/" over HTTP.
+	static, err := fs.Sub(client.Bucket("my-bucket").FS(ctx), "static")
+	if err != nil {
+		// TODO: handle error.
+	}
+	http.Handle("/", http.FileServer(http.FS(static)))
+}
+
+func ExampleObjectHandle_NewRandomAccessReader() {
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		// TODO: handle error.
+	}
+	r, err := client.Bucket("my-bucket").Object("archive.zip").NewRandomAccessReader(ctx)
+	if err != nil {
+		// TODO: handle error.
+	}
+	defer r.Close()
+	// Only the ranges of the object needed to list the archive are read.
+	zr, err := zip.NewReader(r, r.Size())
+	if err != nil {
+		// TODO: handle error.
+	}
+	for _, f := range zr.File {
+		fmt.Println(f.Name)
+	}
+}
+
 func ExampleBucketHandle_Update() {
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
@@ -464,6 +502,66 @@ func ExampleObjectHandle_NewWriterFromAppendableObject() {
 	}
 }
 
+func ExampleObjectHandle_NewWriterFromSession() {
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		// TODO: handle error.
+	}
+	obj := client.Bucket("my-bucket").Object("large-file")
+	f, err := os.Open("large-file")
+	if err != nil {
+		// TODO: handle error.
+	}
+	defer f.Close()
+
+	// Load the session saved by a previous run, if any.
+	var session *storage.ResumableSession
+	if b, err := os.ReadFile("large-file.session"); err == nil {
+		session = new(storage.ResumableSession)
+		if err := json.Unmarshal(b, session); err != nil {
+			// TODO: handle error.
+		}
+	}
+	saveSession := func(s *storage.ResumableSession) {
+		b, err := json.Marshal(s)
+		if err != nil {
+			// TODO: handle error.
+		}
+		if err := os.WriteFile("large-file.session", b, 0600); err != nil {
+			// TODO: handle error.
+		}
+	}
+
+	var w *storage.Writer
+	if session == nil {
+		w = obj.NewWriter(ctx)
+		w.SessionFunc = saveSession
+	} else {
+		var offset int64
+		w, offset, err = obj.NewWriterFromSession(ctx, session, &storage.ResumableWriterOpts{SessionFunc: saveSession})
+		if err != nil {
+			// TODO: handle error.
+		}
+		if w.Attrs() != nil {
+			// The upload had already completed.
+			os.Remove("large-file.session")
+			return
+		}
+		// Continue from the data the service has persisted.
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			// TODO: handle error.
+		}
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		// TODO: handle error.
+	}
+	if err := w.Close(); err != nil {
+		// TODO: handle error.
+	}
+	os.Remove("large-file.session")
+}
+
 func ExampleObjectHandle_OverrideUnlockedRetention() {
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
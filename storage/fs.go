@@ -0,0 +1,324 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/iterator"
+)
+
+// BucketFS is a read-only view of a bucket as an [fs.FS]. It also implements
+// [fs.ReadDirFS], [fs.ReadFileFS], [fs.StatFS] and [fs.GlobFS].
+//
+// Objects are files, and the "/"-delimited prefixes of object names are
+// directories. A directory exists if at least one object name starts with its
+// path followed by "/". Objects whose names are not valid [fs.ValidPath]
+// paths, such as names that end in "/", cannot be opened as files.
+//
+// Files returned by Open implement [io.ReaderAt] and [io.Seeker] through
+// a [RandomAccessReader], so a BucketFS can be used with [net/http.FS] and
+// archive/zip.
+type BucketFS struct {
+	ctx context.Context
+	b   *BucketHandle
+}
+
+// FS returns a read-only [fs.FS] view of the objects in the bucket. ctx is
+// used for all calls made through the returned BucketFS and the files it
+// opens. Use [fs.Sub] to view only the objects under a prefix.
+func (b *BucketHandle) FS(ctx context.Context) *BucketFS {
+	return &BucketFS{ctx: ctx, b: b}
+}
+
+var (
+	_ fs.ReadDirFS  = (*BucketFS)(nil)
+	_ fs.ReadFileFS = (*BucketFS)(nil)
+	_ fs.StatFS     = (*BucketFS)(nil)
+	_ fs.GlobFS     = (*BucketFS)(nil)
+)
+
+// Open opens the named file or directory.
+func (fsys *BucketFS) Open(name string) (fs.File, error) {
+	attrs, isDir, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		return &bucketDir{fsys: fsys, name: name}, nil
+	}
+	return &objectFile{
+		RandomAccessReader: newRandomAccessReader(fsys.ctx, fsys.b.Object(attrs.Name), attrs),
+	}, nil
+}
+
+// Stat returns a [fs.FileInfo] describing the named file or directory. For
+// files, the FileInfo's Sys method returns the object's *ObjectAttrs.
+func (fsys *BucketFS) Stat(name string) (fs.FileInfo, error) {
+	attrs, isDir, err := fsys.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		return dirInfo(path.Base(name)), nil
+	}
+	return objectInfo{attrs}, nil
+}
+
+// ReadFile reads the named file and returns its contents. Like a file
+// returned by Open, it reads the generation described by the object's
+// attributes, without decompressive transcoding.
+func (fsys *BucketFS) ReadFile(name string) ([]byte, error) {
+	attrs, isDir, err := fsys.stat("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	r, err := storedObject(fsys.b.Object(attrs.Name), attrs).NewReader(fsys.ctx)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: mapFSError(err)}
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ReadDir reads the named directory and returns a list of directory entries
+// sorted by filename.
+func (fsys *BucketFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := fsys.list(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if len(entries) == 0 && name != "." {
+		// The directory may not exist, or may be a file.
+		_, isDir, err := fsys.stat("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		if !isDir {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+	return entries, nil
+}
+
+// Glob returns the names of all files and directories matching pattern, using
+// the syntax of [path.Match]. Matches are found with a single listing of the
+// objects that share the pattern's literal prefix.
+func (fsys *BucketFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	metaIdx := strings.IndexAny(pattern, `*?[\`)
+	if metaIdx < 0 {
+		if _, err := fsys.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	depth := strings.Count(pattern, "/") + 1
+	matches := make(map[string]bool)
+	it := fsys.b.Objects(fsys.ctx, &Query{Prefix: pattern[:metaIdx], Projection: ProjectionNoACL})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Consider the file or directory at the same depth as the pattern.
+		parts := strings.SplitN(attrs.Name, "/", depth+1)
+		if len(parts) < depth {
+			continue
+		}
+		candidate := strings.Join(parts[:depth], "/")
+		if matches[candidate] || !fs.ValidPath(candidate) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, candidate); ok {
+			matches[candidate] = true
+		}
+	}
+
+	names := make([]string, 0, len(matches))
+	for name := range matches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// stat determines whether name is an object or a directory. It returns the
+// object attributes for objects.
+func (fsys *BucketFS) stat(op, name string) (attrs *ObjectAttrs, isDir bool, err error) {
+	if !fs.ValidPath(name) {
+		return nil, false, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil, true, nil
+	}
+	attrs, err = fsys.b.Object(name).Attrs(fsys.ctx)
+	if err == nil {
+		return attrs, false, nil
+	}
+	if !errors.Is(err, ErrObjectNotExist) {
+		return nil, false, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	// Check whether any object exists under name as a directory.
+	q := &Query{Prefix: name + "/", Projection: ProjectionNoACL}
+	if err := q.SetAttrSelection([]string{"Name"}); err != nil {
+		return nil, false, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	it := fsys.b.Objects(fsys.ctx, q)
+	it.PageInfo().MaxSize = 1
+	if _, err := it.Next(); err == iterator.Done {
+		return nil, false, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	} else if err != nil {
+		return nil, false, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil, true, nil
+}
+
+// list returns the sorted entries of the named directory.
+func (fsys *BucketFS) list(name string) ([]fs.DirEntry, error) {
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	it := fsys.b.Objects(fsys.ctx, &Query{Prefix: prefix, Delimiter: "/", Projection: ProjectionNoACL})
+	var entries []fs.DirEntry
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if attrs.Prefix != "" {
+			base := strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, prefix), "/")
+			if base == "" || !fs.ValidPath(base) {
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(dirInfo(base)))
+			continue
+		}
+		base := strings.TrimPrefix(attrs.Name, prefix)
+		if base == "" || !fs.ValidPath(base) {
+			// Skip directory placeholder objects.
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(objectInfo{attrs}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func mapFSError(err error) error {
+	if errors.Is(err, ErrObjectNotExist) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// objectFile is a file returned by BucketFS.Open for an object.
+type objectFile struct {
+	*RandomAccessReader
+}
+
+func (f *objectFile) Stat() (fs.FileInfo, error) {
+	return objectInfo{f.attrs}, nil
+}
+
+// bucketDir is a file returned by BucketFS.Open for a directory. It
+// implements fs.ReadDirFile.
+type bucketDir struct {
+	fsys    *BucketFS
+	name    string
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *bucketDir) Stat() (fs.FileInfo, error) {
+	return dirInfo(path.Base(d.name)), nil
+}
+
+func (d *bucketDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *bucketDir) Close() error {
+	return nil
+}
+
+func (d *bucketDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.list(d.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries = entries
+		d.listed = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// objectInfo implements fs.FileInfo for an object.
+type objectInfo struct {
+	attrs *ObjectAttrs
+}
+
+func (i objectInfo) Name() string       { return path.Base(i.attrs.Name) }
+func (i objectInfo) Size() int64        { return i.attrs.Size }
+func (i objectInfo) Mode() fs.FileMode  { return 0444 }
+func (i objectInfo) ModTime() time.Time { return i.attrs.Updated }
+func (i objectInfo) IsDir() bool        { return false }
+func (i objectInfo) Sys() any           { return i.attrs }
+
+// dirInfo implements fs.FileInfo for a directory with the given base name.
+type dirInfo string
+
+func (i dirInfo) Name() string       { return string(i) }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() any           { return nil }
@@ -0,0 +1,349 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// newFSTestClient returns a client for a fake server that serves the given
+// objects from the bucket "bucket" through the JSON API metadata and list
+// endpoints and the XML API download endpoint. Objects whose names end in
+// ".gz" have a "gzip" Content-Encoding and are decompressed unless the
+// request accepts gzip.
+func newFSTestClient(t *testing.T, objects map[string]string) *Client {
+	t.Helper()
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	resource := func(name string) map[string]string {
+		res := map[string]string{
+			"bucket":     "bucket",
+			"name":       name,
+			"size":       strconv.Itoa(len(objects[name])),
+			"generation": "7",
+			"updated":    updated.Format(time.RFC3339),
+		}
+		if strings.HasSuffix(name, ".gz") {
+			res["contentEncoding"] = "gzip"
+		}
+		return res
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if name, ok := strings.CutPrefix(r.URL.Path, "/bucket/"); ok {
+			// Download through the XML API.
+			content, ok := objects[name]
+			if !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("X-Goog-Generation", "7")
+			if strings.HasSuffix(name, ".gz") {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					zr, err := gzip.NewReader(strings.NewReader(content))
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					io.Copy(w, zr)
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+			http.ServeContent(w, r, name, updated, strings.NewReader(content))
+			return
+		}
+		_, rest, ok := strings.Cut(r.URL.Path, "/b/bucket/o")
+		if !ok {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if rest == "" {
+			// List objects.
+			q := r.URL.Query()
+			prefix, delim := q.Get("prefix"), q.Get("delimiter")
+			var items []map[string]string
+			var prefixes []string
+			seen := map[string]bool{}
+			for _, name := range names {
+				if !strings.HasPrefix(name, prefix) {
+					continue
+				}
+				if delim != "" {
+					if i := strings.Index(name[len(prefix):], delim); i >= 0 {
+						p := name[:len(prefix)+i+len(delim)]
+						if !seen[p] {
+							seen[p] = true
+							prefixes = append(prefixes, p)
+						}
+						continue
+					}
+				}
+				items = append(items, resource(name))
+			}
+			json.NewEncoder(w).Encode(map[string]any{"kind": "storage#objects", "items": items, "prefixes": prefixes})
+			return
+		}
+		name := strings.TrimPrefix(rest, "/")
+		if _, ok := objects[name]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(resource(name))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient(context.Background(), option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestBucketFS(t *testing.T) {
+	t.Parallel()
+	objects := map[string]string{
+		"a.txt":            "hello",
+		"dir/b.txt":        "world",
+		"dir/c.html":       "<p>c</p>",
+		"dir/sub/d.txt":    "deep",
+		"empty/":           "",
+		"other/e.txt":      strings.Repeat("e", 100),
+		"invalid//name":    "skipped",
+		"trailing/slash/":  "",
+		"trailing/f.txt":   "f",
+		"z/y/x/w/v/u.data": "nested",
+	}
+	fsys := newFSTestClient(t, objects).Bucket("bucket").FS(context.Background())
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/c.html", "dir/sub/d.txt", "other/e.txt", "trailing/f.txt", "z/y/x/w/v/u.data"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Stat", func(t *testing.T) {
+		fi, err := fsys.Stat("dir/b.txt")
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if fi.Name() != "b.txt" || fi.Size() != 5 || fi.IsDir() {
+			t.Errorf("Stat(dir/b.txt): got name %q, size %d, isDir %v", fi.Name(), fi.Size(), fi.IsDir())
+		}
+		if attrs, ok := fi.Sys().(*ObjectAttrs); !ok || attrs.Generation != 7 {
+			t.Errorf("Stat(dir/b.txt).Sys(): got %v, want *ObjectAttrs with generation 7", fi.Sys())
+		}
+
+		fi, err = fsys.Stat("dir/sub")
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if fi.Name() != "sub" || !fi.IsDir() {
+			t.Errorf("Stat(dir/sub): got name %q, isDir %v", fi.Name(), fi.IsDir())
+		}
+
+		if _, err := fsys.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(missing): got %v, want ErrNotExist", err)
+		}
+	})
+
+	t.Run("Glob", func(t *testing.T) {
+		for _, test := range []struct {
+			pattern string
+			want    []string
+		}{
+			{pattern: "*.txt", want: []string{"a.txt"}},
+			{pattern: "dir/*", want: []string{"dir/b.txt", "dir/c.html", "dir/sub"}},
+			{pattern: "*/*.txt", want: []string{"dir/b.txt", "other/e.txt", "trailing/f.txt"}},
+			{pattern: "d?r", want: []string{"dir"}},
+			{pattern: "dir/b.txt", want: []string{"dir/b.txt"}},
+			{pattern: "nothing*", want: []string{}},
+		} {
+			got, err := fsys.Glob(test.pattern)
+			if err != nil {
+				t.Errorf("Glob(%q): %v", test.pattern, err)
+				continue
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("Glob(%q): got %q, want %q", test.pattern, got, test.want)
+			}
+		}
+		if _, err := fsys.Glob("["); err == nil {
+			t.Error("Glob([): got nil error, want ErrBadPattern")
+		}
+	})
+}
+
+func TestBucketFSGzip(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, strings.Repeat("compressible ", 50))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stored := buf.String()
+	fsys := newFSTestClient(t, map[string]string{"logs/app.log.gz": stored}).Bucket("bucket").FS(context.Background())
+
+	// ReadFile, Open and Stat all see the object as stored.
+	if err := fstest.TestFS(fsys, "logs/app.log.gz"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fsys.ReadFile("logs/app.log.gz")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != stored {
+		t.Errorf("ReadFile: got %d bytes, want the %d stored bytes", len(got), len(stored))
+	}
+	fi, err := fsys.Stat("logs/app.log.gz")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if fi.Size() != int64(len(got)) {
+		t.Errorf("Stat: got size %d, want %d", fi.Size(), len(got))
+	}
+
+	if _, err := fsys.ReadFile("logs"); err == nil {
+		t.Error("ReadFile(logs): got nil error for a directory")
+	}
+	if _, err := fsys.ReadFile("missing.gz"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile(missing.gz): got %v, want ErrNotExist", err)
+	}
+}
+
+func TestRandomAccessReader(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"one.txt", "two.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, "contents of "+name)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := "0123456789abcdefghij"
+	client := newFSTestClient(t, map[string]string{"archive.zip": buf.String(), "data": data})
+
+	t.Run("zip", func(t *testing.T) {
+		r, err := client.Bucket("bucket").Object("archive.zip").NewRandomAccessReader(ctx)
+		if err != nil {
+			t.Fatalf("NewRandomAccessReader: %v", err)
+		}
+		defer r.Close()
+
+		zr, err := zip.NewReader(r, r.Size())
+		if err != nil {
+			t.Fatalf("zip.NewReader: %v", err)
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("Open(%q): %v", f.Name, err)
+			}
+			got, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("ReadAll(%q): %v", f.Name, err)
+			}
+			if want := "contents of " + f.Name; string(got) != want {
+				t.Errorf("%q: got %q, want %q", f.Name, got, want)
+			}
+		}
+	})
+
+	t.Run("ReadAt", func(t *testing.T) {
+		r, err := client.Bucket("bucket").Object("data").NewRandomAccessReader(ctx)
+		if err != nil {
+			t.Fatalf("NewRandomAccessReader: %v", err)
+		}
+		defer r.Close()
+
+		for _, test := range []struct {
+			off     int64
+			len     int
+			want    string
+			wantErr error
+		}{
+			{off: 0, len: 5, want: "01234"},
+			{off: 10, len: 3, want: "abc"},
+			{off: 15, len: 10, want: "fghij", wantErr: io.EOF},
+			{off: 20, len: 1, want: "", wantErr: io.EOF},
+		} {
+			p := make([]byte, test.len)
+			n, err := r.ReadAt(p, test.off)
+			if err != test.wantErr {
+				t.Errorf("ReadAt(%d, %d): got err %v, want %v", test.off, test.len, err, test.wantErr)
+			}
+			if got := string(p[:n]); got != test.want {
+				t.Errorf("ReadAt(%d, %d): got %q, want %q", test.off, test.len, got, test.want)
+			}
+		}
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		r, err := client.Bucket("bucket").Object("data").NewRandomAccessReader(ctx)
+		if err != nil {
+			t.Fatalf("NewRandomAccessReader: %v", err)
+		}
+		defer r.Close()
+
+		p := make([]byte, 4)
+		if _, err := io.ReadFull(r, p); err != nil || string(p) != "0123" {
+			t.Errorf("Read: got %q, %v; want %q", p, err, "0123")
+		}
+		if pos, err := r.Seek(-4, io.SeekEnd); err != nil || pos != 16 {
+			t.Errorf("Seek: got %d, %v; want 16", pos, err)
+		}
+		rest, err := io.ReadAll(r)
+		if err != nil || string(rest) != "ghij" {
+			t.Errorf("ReadAll after Seek: got %q, %v; want %q", rest, err, "ghij")
+		}
+		if _, err := r.Seek(-1, io.SeekStart); err == nil {
+			t.Error("Seek to negative position: got nil error")
+		}
+	})
+}
@@ -62,6 +62,9 @@ func (w *gRPCWriter) Write(p []byte) (n int, err error) {
 			!w.append {
 			w.fullObjectChecksum = crc32.Update(w.fullObjectChecksum, crc32cTable, p)
 		}
+		if w.sessions != nil {
+			w.sessions.write(p)
+		}
 		// write command successfully delivered to sender. We no longer own cmd.
 		break
 	}
@@ -167,6 +170,20 @@ func (c *grpcStorageClient) OpenWriter(params *openWriterParams, opts ...storage
 		return nil, errors.New("storage: no way to report offset for appendable takeover")
 	}
 
+	var (
+		uploadID           string
+		baseOffset         int64
+		fullObjectChecksum uint32
+	)
+	if rs := params.session; rs != nil {
+		if rs.UploadID == "" {
+			return nil, errors.New("storage: session has no upload ID; JSON API upload sessions cannot be resumed with a gRPC client")
+		}
+		uploadID = rs.UploadID
+		baseOffset = rs.PersistedSize
+		fullObjectChecksum = rs.CRC32C
+	}
+
 	w := &gRPCWriter{
 		preRunCtx: ctx,
 		c:         c,
@@ -177,12 +194,17 @@ func (c *grpcStorageClient) OpenWriter(params *openWriterParams, opts ...storage
 		conds:         params.conds,
 		spec:          spec,
 		encryptionKey: params.encryptionKey,
+		uploadID:      uploadID,
 
 		setError:          params.setError,
 		progress:          params.progress,
 		setObj:            params.setObj,
 		setSize:           params.setSize,
 		setTakeoverOffset: params.setTakeoverOffset,
+		sessions:          newSessionReporter(params, chunkSize),
+
+		fullObjectChecksum:     fullObjectChecksum,
+		omitFullObjectChecksum: params.session != nil && !params.session.HasCRC32C,
 
 		flushSupported:        params.append,
 		sendCRC32C:            params.sendCRC32C,
@@ -200,7 +222,7 @@ func (c *grpcStorageClient) OpenWriter(params *openWriterParams, opts ...storage
 		sendableUnits:    sendableUnits,
 		bufUnsentIdx:     0,
 		bufFlushedIdx:    -1, // Handle flushes to length 0
-		bufBaseOffset:    0,
+		bufBaseOffset:    baseOffset,
 
 		chunkRetryDeadline: chunkRetryDeadline,
 		abandonRetriesTime: time.Time{},
@@ -258,16 +280,23 @@ type gRPCWriter struct {
 	conds         *Conditions
 	spec          *storagepb.WriteObjectSpec
 	encryptionKey []byte
+	// uploadID is the ID of the resumable upload, once it is known. It is set
+	// initially when continuing a session.
+	uploadID string
 
 	setError          func(error)
 	progress          func(int64)
 	setObj            func(*ObjectAttrs)
 	setSize           func(int64)
 	setTakeoverOffset func(int64)
+	sessions          *sessionReporter
 
-	fullObjectChecksum    uint32
-	appendFinalCRC32C     uint32
-	sendAppendFinalCRC32C bool
+	fullObjectChecksum uint32
+	// omitFullObjectChecksum is set when continuing a session for which the
+	// checksum of the persisted data is unknown.
+	omitFullObjectChecksum bool
+	appendFinalCRC32C      uint32
+	sendAppendFinalCRC32C  bool
 
 	flushSupported        bool
 	sendCRC32C            bool
@@ -373,6 +402,9 @@ func (w *gRPCWriter) handleCompletion(c gRPCBidiWriteCompletion) {
 	}
 	w.setSize(c.flushOffset)
 	w.progress(c.flushOffset)
+	if w.sessions != nil && w.uploadID != "" && c.resource == nil {
+		w.sessions.report("", w.uploadID, c.flushOffset)
+	}
 }
 
 func (w *gRPCWriter) withCommandRetryDeadline(f func() error) error {
@@ -418,8 +450,9 @@ func (w *gRPCWriter) gatherFirstBuffer() error {
 			close(v.done)
 			break
 		case *gRPCWriterCommandClose:
-			// If we get here, data (if any) fits in w.buf, so we can force oneshot.
-			w.forceOneShot = true
+			// If we get here, data (if any) fits in w.buf, so we can force oneshot,
+			// unless we are continuing a resumable upload.
+			w.forceOneShot = w.uploadID == ""
 			w.currentCommand = cmd
 			// No need to start sending if v.err is not nil.
 			return v.err
@@ -1165,6 +1198,8 @@ type gRPCResumableBidiWriteBufferSender struct {
 
 	startWriteRequest *storagepb.StartResumableWriteRequest
 	upid              string
+	// onStart is called with the upload ID once the upload is started.
+	onStart func(upid string)
 
 	// Checksum related settings.
 	sendCRC32C          bool
@@ -1176,7 +1211,7 @@ type gRPCResumableBidiWriteBufferSender struct {
 }
 
 func (w *gRPCWriter) newGRPCResumableBidiWriteBufferSender() *gRPCResumableBidiWriteBufferSender {
-	return &gRPCResumableBidiWriteBufferSender{
+	s := &gRPCResumableBidiWriteBufferSender{
 		raw:    w.c.raw,
 		bucket: w.bucket,
 		startWriteRequest: &storagepb.StartResumableWriteRequest{
@@ -1184,6 +1219,13 @@ func (w *gRPCWriter) newGRPCResumableBidiWriteBufferSender() *gRPCResumableBidiW
 			CommonObjectRequestParams: toProtoCommonObjectRequestParams(w.encryptionKey),
 			ObjectChecksums:           toProtoChecksums(w.sendCRC32C, w.attrs),
 		},
+		upid: w.uploadID,
+		onStart: func(upid string) {
+			w.uploadID = upid
+			if w.sessions != nil {
+				w.sessions.report("", upid, 0)
+			}
+		},
 		sendCRC32C:          w.sendCRC32C,
 		disableAutoChecksum: w.disableAutoChecksum,
 		objectAttrs:         w.attrs,
@@ -1192,6 +1234,14 @@ func (w *gRPCWriter) newGRPCResumableBidiWriteBufferSender() *gRPCResumableBidiW
 			return &checksum
 		},
 	}
+	if w.uploadID != "" {
+		// Continue the existing upload.
+		s.startWriteRequest = nil
+	}
+	if w.omitFullObjectChecksum {
+		s.fullObjectChecksum = nil
+	}
+	return s
 }
 
 func (s *gRPCResumableBidiWriteBufferSender) err() error { return s.streamErr }
@@ -1209,6 +1259,7 @@ func (s *gRPCResumableBidiWriteBufferSender) connect(ctx context.Context, cs gRP
 		}
 		s.upid = upres.GetUploadId()
 		s.startWriteRequest = nil
+		s.onStart(s.upid)
 	} else {
 		q, err := s.raw.QueryWriteStatus(ctx, &storagepb.QueryWriteStatusRequest{UploadId: s.upid}, opts...)
 		if err != nil {
@@ -1306,6 +1357,34 @@ func (s *gRPCResumableBidiWriteBufferSender) connect(ctx context.Context, cs gRP
 	}()
 }
 
+func (c *grpcStorageClient) QueryWriteStatus(ctx context.Context, session *ResumableSession, encryptionKey []byte, opts ...storageOption) (int64, *ObjectAttrs, error) {
+	if session.UploadID == "" {
+		return 0, nil, errors.New("storage: session has no upload ID; JSON API upload sessions cannot be resumed with a gRPC client")
+	}
+	s := callSettings(c.settings, opts...)
+	if s.userProject != "" {
+		ctx = setUserProjectMetadata(ctx, s.userProject)
+	}
+	ctx = gRPCWriteRequestParams{bucket: session.Bucket}.apply(ctx)
+	req := &storagepb.QueryWriteStatusRequest{
+		UploadId:                  session.UploadID,
+		CommonObjectRequestParams: toProtoCommonObjectRequestParams(encryptionKey),
+	}
+	var res *storagepb.QueryWriteStatusResponse
+	err := run(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = c.raw.QueryWriteStatus(ctx, req, s.gax...)
+		return err
+	}, s.retry, s.idempotent, withOperation("QueryWriteStatus"), withBucket(session.Bucket), withObject(session.Object))
+	if err != nil {
+		return 0, nil, err
+	}
+	if r := res.GetResource(); r != nil {
+		return r.GetSize(), newObjectFromProto(r), nil
+	}
+	return res.GetPersistedSize(), nil, nil
+}
+
 type gRPCAppendBidiWriteBufferSender struct {
 	raw          *gapic.Client
 	bucket       string
@@ -17,6 +17,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"hash/crc32"
 	"io"
 	"sync"
 	"testing"
@@ -436,3 +437,76 @@ func TestGRPCWriter_Deadlock(t *testing.T) {
 	}
 	close(completions)
 }
+
+func TestGRPCWriterSessions(t *testing.T) {
+	chunkSize := gRPCChunkSize(0)
+	data := make([]byte, 3*chunkSize+100)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	for _, resumeAt := range []int64{0, int64(chunkSize)} {
+		var sessions []ResumableSession
+		params := &openWriterParams{
+			bucket:     "bucket",
+			attrs:      &ObjectAttrs{Name: "obj"},
+			setSession: func(s *ResumableSession) { sessions = append(sessions, *s) },
+		}
+		if resumeAt > 0 {
+			params.session = &ResumableSession{
+				UploadID:      "upid",
+				PersistedSize: resumeAt,
+				CRC32C:        crc32.Checksum(data[:resumeAt], crc32cTable),
+				HasCRC32C:     true,
+			}
+		}
+		mockSender := &mockSender{}
+		w := &gRPCWriter{
+			chunkSize:        chunkSize,
+			writeQuantum:     maxPerMessageWriteSize,
+			lastSegmentStart: chunkSize,
+			sendableUnits:    chunkSize/maxPerMessageWriteSize + 1,
+			bufFlushedIdx:    -1,
+			bufBaseOffset:    resumeAt,
+			uploadID:         "upid",
+			sessions:         newSessionReporter(params, chunkSize),
+			preRunCtx:        context.Background(),
+			writesChan:       make(chan gRPCWriterCommand, 1),
+			donec:            make(chan struct{}),
+			streamSender:     mockSender,
+			settings:         &settings{},
+		}
+		w.progress = func(int64) {}
+		w.setObj = func(*ObjectAttrs) {}
+		w.setSize = func(int64) {}
+
+		go func() {
+			w.writeLoop(context.Background())
+			close(w.donec)
+		}()
+		if _, err := w.Write(data[resumeAt:]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		mockSender.wg.Wait()
+
+		if reqs := filterDataRequests(mockSender.requests); len(reqs) == 0 || reqs[0].offset != resumeAt {
+			t.Errorf("resumeAt %d: first data request does not start at the persisted offset", resumeAt)
+		}
+		// The mock sender may deliver completions out of order, so only some
+		// chunk boundaries may be reported.
+		if len(sessions) == 0 || sessions[len(sessions)-1].PersistedSize != int64(len(data)) {
+			t.Errorf("resumeAt %d: got %d sessions, want last session at offset %d", resumeAt, len(sessions), len(data))
+		}
+		for _, s := range sessions {
+			if s.UploadID != "upid" || s.PersistedSize <= resumeAt || (s.PersistedSize-resumeAt)%int64(chunkSize) != 0 && s.PersistedSize != int64(len(data)) {
+				t.Errorf("resumeAt %d: unexpected session %+v", resumeAt, s)
+			}
+			if !s.HasCRC32C || s.CRC32C != crc32.Checksum(data[:s.PersistedSize], crc32cTable) {
+				t.Errorf("resumeAt %d: session at offset %d has wrong checksum", resumeAt, s.PersistedSize)
+			}
+		}
+	}
+}
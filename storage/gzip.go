@@ -0,0 +1,427 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Objects written with Writer.CompressGzip are stored as a sequence of gzip
+// members, each holding GzipMemberSize bytes of the uncompressed data except
+// the last one, followed by an index and a footer. The index and the footer
+// are empty gzip members whose extra field holds data, so the whole object is
+// a valid multi-member gzip file that decompresses to the uncompressed data:
+//
+//   - The index members hold, in their "GI" extra subfields, the uvarint
+//     uncompressed size of the object followed by the uvarint compressed
+//     length of each data member.
+//   - The footer member holds, in its "GF" extra subfield, the little-endian
+//     uint64 offset of the first index member. It has a fixed size, so it can
+//     be read from the end of the object.
+const (
+	// gzipMemberSizeKey is the metadata key that records the uncompressed
+	// size of the members of an object written with Writer.CompressGzip.
+	gzipMemberSizeKey = "gzip-member-size"
+
+	defaultGzipMemberSize = 1 << 20
+
+	gzipIndexID  = "GI"
+	gzipFooterID = "GF"
+
+	// gzipMaxExtraPayload is the largest payload of a single extra subfield.
+	gzipMaxExtraPayload = 0xffff - 4
+	// gzipFooterSize is the size of the footer member.
+	gzipFooterSize = 10 + 2 + 4 + 8 + 10
+)
+
+// ReadGzipMembers returns a new ObjectHandle whose Readers decompress objects
+// written with [Writer.CompressGzip] on the client. A range read downloads and
+// decompresses only the gzip members that overlap the range, rather than the
+// whole object that the service returns for ranges of gzip-encoded objects.
+//
+// Opening a Reader first fetches the object's attributes and index, which
+// takes two additional requests. The Reader's Attrs.Size and
+// Attrs.StartOffset refer to the uncompressed data, and Attrs.CRC32C is
+// zero, since the checksum stored by the service describes the compressed
+// data. The CRC-32 of each gzip member read in full is verified instead.
+// Objects that were not written with Writer.CompressGzip are read as usual.
+func (o *ObjectHandle) ReadGzipMembers(enable bool) *ObjectHandle {
+	o2 := *o
+	o2.readGzipMembers = enable
+	return &o2
+}
+
+// appendEmptyGzipMember appends to b a gzip member with no content whose
+// extra field holds a single subfield with the given ID and payload.
+func appendEmptyGzipMember(b []byte, id string, payload []byte) []byte {
+	b = append(b, 0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 255) // deflate, FEXTRA, unknown OS
+	b = binary.LittleEndian.AppendUint16(b, uint16(4+len(payload)))
+	b = append(b, id[0], id[1])
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(payload)))
+	b = append(b, payload...)
+	// An empty final deflate block, and the CRC-32 and size of no content.
+	return append(b, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+// parseEmptyGzipMember parses a member written by appendEmptyGzipMember at
+// the start of b, and returns its payload and the bytes that follow it.
+func parseEmptyGzipMember(b []byte, id string) (payload, rest []byte, err error) {
+	errCorrupt := fmt.Errorf("storage: corrupt gzip index member %q", id)
+	if len(b) < 12 || !bytes.Equal(b[:4], []byte{0x1f, 0x8b, 8, 4}) {
+		return nil, nil, errCorrupt
+	}
+	xlen := int(binary.LittleEndian.Uint16(b[10:]))
+	if xlen < 4 || len(b) < 12+xlen+10 {
+		return nil, nil, errCorrupt
+	}
+	extra := b[12 : 12+xlen]
+	if string(extra[:2]) != id || int(binary.LittleEndian.Uint16(extra[2:])) != xlen-4 {
+		return nil, nil, errCorrupt
+	}
+	if !bytes.Equal(b[12+xlen:12+xlen+10], []byte{3, 0, 0, 0, 0, 0, 0, 0, 0, 0}) {
+		return nil, nil, errCorrupt
+	}
+	return extra[4:], b[12+xlen+10:], nil
+}
+
+// countingWriter counts the bytes written to a write function.
+type countingWriter struct {
+	write func([]byte) (int, error)
+	n     int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// gzipMemberWriter compresses the data written to it into independent gzip
+// members, and writes them followed by the index and footer on close.
+type gzipMemberWriter struct {
+	dst        countingWriter
+	zw         *gzip.Writer
+	memberSize int
+	open       bool  // whether zw is compressing a member
+	n          int   // uncompressed bytes of the current member
+	start      int64 // compressed offset of the current member
+	size       int64 // uncompressed bytes written
+	lengths    []int64
+}
+
+func newGzipMemberWriter(memberSize int, out func([]byte) (int, error)) *gzipMemberWriter {
+	g := &gzipMemberWriter{dst: countingWriter{write: out}, memberSize: memberSize}
+	g.zw = gzip.NewWriter(&g.dst)
+	return g
+}
+
+func (g *gzipMemberWriter) Write(p []byte) (int, error) {
+	var total int
+	for len(p) > 0 {
+		if !g.open {
+			g.zw.Reset(&g.dst)
+			g.open, g.n, g.start = true, 0, g.dst.n
+		}
+		k := min(len(p), g.memberSize-g.n)
+		if _, err := g.zw.Write(p[:k]); err != nil {
+			return total, err
+		}
+		total += k
+		g.n += k
+		g.size += int64(k)
+		p = p[k:]
+		if g.n == g.memberSize {
+			if err := g.closeMember(); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+func (g *gzipMemberWriter) closeMember() error {
+	g.open = false
+	if err := g.zw.Close(); err != nil {
+		return err
+	}
+	g.lengths = append(g.lengths, g.dst.n-g.start)
+	return nil
+}
+
+// close writes the last member, the index and the footer.
+func (g *gzipMemberWriter) close() error {
+	if g.open {
+		if err := g.closeMember(); err != nil {
+			return err
+		}
+	}
+	indexOffset := g.dst.n
+	index := binary.AppendUvarint(nil, uint64(g.size))
+	for _, n := range g.lengths {
+		index = binary.AppendUvarint(index, uint64(n))
+	}
+	var b []byte
+	for len(index) > 0 {
+		n := min(len(index), gzipMaxExtraPayload)
+		b = appendEmptyGzipMember(b, gzipIndexID, index[:n])
+		index = index[n:]
+	}
+	b = appendEmptyGzipMember(b, gzipFooterID, binary.LittleEndian.AppendUint64(nil, uint64(indexOffset)))
+	_, err := g.dst.Write(b)
+	return err
+}
+
+// initGzip validates the Writer for client-side gzip compression and creates
+// its compressor. p is the data of the first Write, used to detect the
+// content type.
+func (w *Writer) initGzip(p []byte) error {
+	if w.gz != nil {
+		return nil
+	}
+	switch {
+	case w.GzipMemberSize < 0:
+		return fmt.Errorf("storage: invalid Writer.GzipMemberSize %d", w.GzipMemberSize)
+	case w.envelopeEncryption():
+		return errors.New("storage: Writer.CompressGzip cannot be used with envelope encryption")
+	case w.Append:
+		return errors.New("storage: Writer.CompressGzip cannot be used with Writer.Append")
+	case w.SessionFunc != nil || w.session != nil:
+		return errors.New("storage: Writer.CompressGzip cannot be used with resumable sessions")
+	case w.SendCRC32C || len(w.MD5) > 0:
+		return errors.New("storage: Writer.CompressGzip cannot be used with checksums provided by the caller")
+	case w.ContentEncoding != "":
+		return fmt.Errorf("storage: Writer.CompressGzip cannot be used with Content-Encoding %q", w.ContentEncoding)
+	}
+	memberSize := w.GzipMemberSize
+	if memberSize == 0 {
+		memberSize = defaultGzipMemberSize
+	}
+	// Sniff the content type from the uncompressed data, since the service
+	// would only see gzip data.
+	if w.ContentType == "" && !w.ForceEmptyContentType {
+		w.ContentType = http.DetectContentType(p)
+	}
+	w.ContentEncoding = "gzip"
+	md := map[string]string{gzipMemberSizeKey: strconv.Itoa(memberSize)}
+	for k, v := range w.Metadata {
+		if _, ok := md[k]; !ok {
+			md[k] = v
+		}
+	}
+	w.Metadata = md
+	w.gz = newGzipMemberWriter(memberSize, w.write)
+	return nil
+}
+
+// gzipIndex locates the members of an object written with Writer.CompressGzip.
+type gzipIndex struct {
+	memberSize int64
+	size       int64 // uncompressed size
+	// offsets holds the compressed offset of each member, followed by the
+	// offset of the index.
+	offsets []int64
+}
+
+// readGzipIndex reads the index of an object of the given compressed size
+// through o, which must read the stored data of a single generation.
+func readGzipIndex(ctx context.Context, o *ObjectHandle, size, memberSize int64) (*gzipIndex, error) {
+	if size < gzipFooterSize {
+		return nil, errors.New("storage: gzip object is too short for an index")
+	}
+	b, err := readObjectRange(ctx, o, size-gzipFooterSize, gzipFooterSize)
+	if err != nil {
+		return nil, err
+	}
+	payload, _, err := parseEmptyGzipMember(b, gzipFooterID)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 8 {
+		return nil, errors.New("storage: corrupt gzip footer")
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(payload))
+	if indexOffset < 0 || indexOffset > size-gzipFooterSize {
+		return nil, fmt.Errorf("storage: gzip index offset %d is out of range", indexOffset)
+	}
+	if b, err = readObjectRange(ctx, o, indexOffset, size-gzipFooterSize-indexOffset); err != nil {
+		return nil, err
+	}
+	var index []byte
+	for len(b) > 0 {
+		if payload, b, err = parseEmptyGzipMember(b, gzipIndexID); err != nil {
+			return nil, err
+		}
+		index = append(index, payload...)
+	}
+
+	errCorrupt := errors.New("storage: corrupt gzip index")
+	usize, n := binary.Uvarint(index)
+	if n <= 0 || usize > 1<<62 {
+		return nil, errCorrupt
+	}
+	index = index[n:]
+	idx := &gzipIndex{memberSize: memberSize, size: int64(usize)}
+	members := (idx.size + memberSize - 1) / memberSize
+	var off int64
+	for len(index) > 0 {
+		length, n := binary.Uvarint(index)
+		if n <= 0 || length > uint64(indexOffset-off) {
+			return nil, errCorrupt
+		}
+		idx.offsets = append(idx.offsets, off)
+		off += int64(length)
+		index = index[n:]
+	}
+	if int64(len(idx.offsets)) != members || off != indexOffset {
+		return nil, errCorrupt
+	}
+	idx.offsets = append(idx.offsets, off)
+	return idx, nil
+}
+
+// readObjectRange reads length bytes of the object of o starting at offset.
+func readObjectRange(ctx context.Context, o *ObjectHandle, offset, length int64) ([]byte, error) {
+	r, err := o.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) != length {
+		return nil, fmt.Errorf("storage: read %d bytes at offset %d, want %d", len(b), offset, length)
+	}
+	return b, nil
+}
+
+// gzipMemberReader decompresses consecutive gzip members, skipping the data
+// before the range read.
+type gzipMemberReader struct {
+	r      io.ReadCloser
+	zr     *gzip.Reader
+	skip   int64
+	remain int64
+}
+
+func (g *gzipMemberReader) Read(p []byte) (int, error) {
+	if g.remain <= 0 {
+		return 0, io.EOF
+	}
+	if g.zr == nil {
+		zr, err := gzip.NewReader(g.r)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(io.Discard, zr, g.skip); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		g.zr = zr
+	}
+	if int64(len(p)) > g.remain {
+		p = p[:g.remain]
+	}
+	n, err := g.zr.Read(p)
+	g.remain -= int64(n)
+	if err == io.EOF && g.remain > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (g *gzipMemberReader) Close() error {
+	return g.r.Close()
+}
+
+// newGzipRangeReader is NewRangeReader for a handle that reads gzip members.
+func (o *ObjectHandle) newGzipRangeReader(ctx context.Context, offset, length int64, opts ...ReaderOption) (*Reader, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if offset < 0 && length >= 0 {
+		return nil, fmt.Errorf("storage: invalid offset %d < 0 requires negative length", offset)
+	}
+	plain := *o
+	plain.readGzipMembers = false
+	attrs, err := plain.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memberSize, err := strconv.ParseInt(attrs.Metadata[gzipMemberSizeKey], 10, 64)
+	if err != nil || memberSize <= 0 || attrs.ContentEncoding != "gzip" {
+		// The object was not written with Writer.CompressGzip.
+		plain.gen = attrs.Generation
+		plain.conds = nil
+		return plain.NewRangeReader(ctx, offset, length, opts...)
+	}
+	plain.gen = attrs.Generation
+	plain.conds = nil
+	plain.readCompressed = true
+	idx, err := readGzipIndex(ctx, &plain, attrs.Size, memberSize)
+	if err != nil {
+		return nil, err
+	}
+	size := idx.size
+	start := offset
+	if start < 0 {
+		start = max(0, size+offset)
+	}
+	if start > size {
+		return nil, fmt.Errorf("storage: offset %d is beyond the object size %d", offset, size)
+	}
+	end := size
+	if length >= 0 {
+		end = min(size, start+length)
+	}
+
+	// Read the members that overlap [start, end).
+	first := start / memberSize
+	zStart, zLength := int64(0), int64(0)
+	if end > start {
+		zStart = idx.offsets[first]
+		zLength = idx.offsets[(end-1)/memberSize+1] - zStart
+	}
+	r, err := plain.NewRangeReader(ctx, zStart, zLength, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if r.Attrs.Decompressed {
+		r.Close()
+		return nil, errors.New("storage: gzip object was decompressed by the service")
+	}
+	r.reader = &gzipMemberReader{
+		r:      r.reader,
+		skip:   start - first*memberSize,
+		remain: end - start,
+	}
+	r.Attrs.Size = size
+	r.Attrs.StartOffset = start
+	r.Attrs.CRC32C = 0
+	r.size = size
+	r.remain = end - start
+	return r, nil
+}
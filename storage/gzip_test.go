@@ -0,0 +1,211 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+// compressibleData returns size bytes of text-like data.
+func compressibleData(size int) []byte {
+	rng := rand.New(rand.NewSource(int64(size)))
+	words := []string{"alpha ", "beta ", "gamma ", "delta\n"}
+	var b bytes.Buffer
+	for b.Len() < size {
+		b.WriteString(words[rng.Intn(len(words))])
+	}
+	return b.Bytes()[:size]
+}
+
+func TestCompressGzipRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	const member = 1000
+
+	for name, client := range clients {
+		for _, size := range []int{0, 1, member - 1, member, member + 1, 5*member + 500} {
+			t.Run(fmt.Sprintf("%s/%d", name, size), func(t *testing.T) {
+				data := compressibleData(size)
+				obj := client.Bucket("bucket").Object(fmt.Sprintf("%s-%d", name, size))
+
+				w := obj.NewWriter(ctx)
+				w.CompressGzip = true
+				w.GzipMemberSize = member
+				w.Metadata = map[string]string{"user": "value"}
+				// Write in uneven pieces to cross member boundaries.
+				for p := data; len(p) > 0; {
+					n := min(len(p), 700)
+					if _, err := w.Write(p[:n]); err != nil {
+						t.Fatal(err)
+					}
+					p = p[n:]
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				attrs := w.Attrs()
+				if attrs.ContentEncoding != "gzip" || attrs.Metadata["user"] != "value" || attrs.Metadata["gzip-member-size"] != "1000" {
+					t.Errorf("got content encoding %q and metadata %v", attrs.ContentEncoding, attrs.Metadata)
+				}
+
+				// The stored object is a valid gzip file of the data.
+				stored, err := obj.ReadCompressed(true).NewReader(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				zr, err := gzip.NewReader(stored)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(zr)
+				stored.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("gunzip of the stored object: got %d bytes, want %d bytes", len(got), size)
+				}
+
+				gzObj := obj.ReadGzipMembers(true)
+				for _, r := range []struct{ offset, length int64 }{
+					{0, -1},
+					{0, 0},
+					{1, -1},
+					{member - 2, 4},
+					{member, member},
+					{int64(size) / 2, 10},
+					{-10, -1},
+					{int64(size), -1},
+				} {
+					if r.offset > int64(size) {
+						continue
+					}
+					start := r.offset
+					if start < 0 {
+						start = max(0, int64(size)+start)
+					}
+					end := int64(size)
+					if r.length >= 0 {
+						end = min(end, start+r.length)
+					}
+					rd, err := gzObj.NewRangeReader(ctx, r.offset, r.length)
+					if err != nil {
+						t.Fatalf("NewRangeReader(%d, %d): %v", r.offset, r.length, err)
+					}
+					got, err := io.ReadAll(rd)
+					rd.Close()
+					if err != nil {
+						t.Fatalf("reading range (%d, %d): %v", r.offset, r.length, err)
+					}
+					if !bytes.Equal(got, data[start:end]) {
+						t.Errorf("range (%d, %d): got %d bytes, want %d bytes", r.offset, r.length, len(got), end-start)
+					}
+					if rd.Attrs.Size != int64(size) || rd.Attrs.StartOffset != start || rd.Remain() != 0 {
+						t.Errorf("range (%d, %d): got size %d, start offset %d and remain %d, want %d, %d and 0", r.offset, r.length, rd.Attrs.Size, rd.Attrs.StartOffset, rd.Remain(), size, start)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCompressGzipLargeIndex(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	obj := clients["http"].Bucket("bucket").Object("large-index")
+
+	// One-byte members need an index that spans several gzip members.
+	data := compressibleData(80000)
+	w := obj.NewWriter(ctx)
+	w.CompressGzip = true
+	w.GzipMemberSize = 1
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := obj.ReadGzipMembers(true).NewRangeReader(ctx, 70000, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[70000:70100]) {
+		t.Errorf("got %q, want %q", got, data[70000:70100])
+	}
+}
+
+func TestCompressGzipErrors(t *testing.T) {
+	ctx := context.Background()
+	srv, clients := newFakeServerClients(t)
+	bkt := clients["http"].Bucket("bucket")
+
+	// Objects written without CompressGzip are read as usual.
+	srv.WriteObject("bucket", "plain", []byte("plain data"))
+	r, err := bkt.Object("plain").ReadGzipMembers(true).NewRangeReader(ctx, 6, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(got) != "data" {
+		t.Errorf("reading a plain object: got %q, %v, want %q", got, err, "data")
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		setup func(w *storage.Writer)
+	}{
+		{"ContentEncoding", func(w *storage.Writer) { w.ContentEncoding = "gzip" }},
+		{"SendCRC32C", func(w *storage.Writer) { w.SendCRC32C = true }},
+		{"negative GzipMemberSize", func(w *storage.Writer) { w.GzipMemberSize = -1 }},
+	} {
+		w := bkt.Object("invalid").NewWriter(ctx)
+		w.CompressGzip = true
+		tc.setup(w)
+		if _, err := w.Write([]byte("x")); err == nil {
+			t.Errorf("writing with %s: got nil, want error", tc.desc)
+		}
+		w.Close()
+	}
+	w := bkt.Object("encrypted").EnvelopeEncryption(newTestKeyWrapper(t)).NewWriter(ctx)
+	w.CompressGzip = true
+	if err := w.Close(); err == nil {
+		t.Error("closing with envelope encryption: got nil, want error")
+	}
+
+	// A corrupt index is detected.
+	srv.WriteObject("bucket", "corrupt", []byte("not gzip data"))
+	cw := bkt.Object("corrupt").NewWriter(ctx)
+	cw.ContentEncoding = "gzip"
+	cw.Metadata = map[string]string{"gzip-member-size": "10"}
+	cw.Write(bytes.Repeat([]byte{0x1f}, 100))
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bkt.Object("corrupt").ReadGzipMembers(true).NewReader(ctx); err == nil {
+		t.Error("reading an object with a corrupt index: got nil, want error")
+	}
+}
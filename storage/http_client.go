@@ -1143,6 +1143,9 @@ func (c *httpStorageClient) OpenWriter(params *openWriterParams, opts ...storage
 	}
 
 	s := callSettings(c.settings, opts...)
+	if params.session != nil || params.setSession != nil {
+		return c.openResumableWriter(params, s)
+	}
 	errorf := params.setError
 	setObj := params.setObj
 	progress := params.progress
@@ -0,0 +1,403 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	raw "google.golang.org/api/storage/v1"
+)
+
+// httpResumableUpload uploads an object through a JSON API resumable upload
+// session that it manages directly, rather than through the generated
+// client, so that the session URI can be reported to Writer.SessionFunc and
+// the upload continued by NewWriterFromSession.
+//
+// Data is uploaded in chunks of chunkSize bytes. A chunk is retried until the
+// service has persisted all of it, so that the persisted size reported in a
+// session always falls on a chunk boundary unless the upload failed.
+type httpResumableUpload struct {
+	c         *httpStorageClient
+	s         *settings
+	params    *openWriterParams
+	chunkSize int
+	sessions  *sessionReporter
+
+	uri    string
+	offset int64 // number of bytes persisted by the service
+}
+
+// openResumableWriter opens a Writer that uploads through a resumable upload
+// session which is reported to, or continued from, params.
+func (c *httpStorageClient) openResumableWriter(params *openWriterParams, s *settings) (internalWriter, error) {
+	chunkSize := params.chunkSize
+	if r := chunkSize % googleapi.MinUploadChunkSize; r != 0 {
+		chunkSize += googleapi.MinUploadChunkSize - r
+	}
+	u := &httpResumableUpload{
+		c:         c,
+		s:         s,
+		params:    params,
+		chunkSize: chunkSize,
+		sessions:  newSessionReporter(params, chunkSize),
+	}
+	if params.session != nil {
+		u.uri = params.session.URI
+		u.offset = params.session.PersistedSize
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer close(params.donec)
+		obj, err := u.upload(pr)
+		if err != nil {
+			params.setError(err)
+			pr.CloseWithError(err)
+			return
+		}
+		params.setObj(newObject(obj))
+	}()
+	// Checksums are handled by the upload itself rather than the
+	// httpInternalWriter.
+	return &httpInternalWriter{
+		PipeWriter:       pw,
+		chunkSize:        chunkSize,
+		checksumDisabled: true,
+	}, nil
+}
+
+// upload reads the object's data from r and uploads it, returning the
+// resulting object.
+func (u *httpResumableUpload) upload(r io.Reader) (*raw.Object, error) {
+	ctx := u.params.ctx
+	buf := make([]byte, u.chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return nil, err
+		}
+		chunk := buf[:n]
+		u.sessions.write(chunk)
+
+		if u.uri == "" {
+			// Start the session once the first chunk is available, so that
+			// its content type can be detected.
+			if err := u.start(ctx, chunk); err != nil {
+				return nil, err
+			}
+			u.sessions.report(u.uri, "", 0)
+		}
+		obj, err := u.uploadChunk(ctx, chunk, final)
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			return obj, nil
+		}
+		u.params.progress(u.offset)
+		u.sessions.report(u.uri, "", u.offset)
+	}
+}
+
+// start creates the resumable upload session.
+func (u *httpResumableUpload) start(ctx context.Context, firstChunk []byte) error {
+	attrs := u.params.attrs
+	rawObj := attrs.toRawObject(u.params.bucket)
+	if u.params.sendCRC32C {
+		rawObj.Crc32c = encodeUint32(attrs.CRC32C)
+	}
+	if attrs.MD5 != nil {
+		rawObj.Md5Hash = base64.StdEncoding.EncodeToString(attrs.MD5)
+	}
+	body, err := json.Marshal(rawObj)
+	if err != nil {
+		return err
+	}
+
+	q := resumableUploadQuery{
+		"alt":         {"json"},
+		"prettyPrint": {"false"},
+		"uploadType":  {"resumable"},
+		"name":        {attrs.Name},
+		"projection":  {"full"},
+	}
+	if attrs.KMSKeyName != "" {
+		q.Set("kmsKeyName", attrs.KMSKeyName)
+	}
+	if attrs.PredefinedACL != "" {
+		q.Set("predefinedAcl", attrs.PredefinedACL)
+	}
+	if u.s.userProject != "" {
+		q.Set("userProject", u.s.userProject)
+	}
+	if err := applyConds("NewWriter", defaultGen, u.params.conds, q); err != nil {
+		return err
+	}
+	contentType := attrs.ContentType
+	if contentType == "" && !u.params.forceEmptyContentType {
+		contentType = http.DetectContentType(firstChunk)
+	}
+
+	urls := googleapi.ResolveRelative(u.c.raw.BasePath, "/upload/storage/v1/b/{bucket}/o") + "?" + url.Values(q).Encode()
+	return run(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, urls, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		googleapi.Expand(req.URL, map[string]string{"bucket": u.params.bucket})
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if contentType != "" {
+			req.Header.Set("X-Upload-Content-Type", contentType)
+		}
+		if err := u.setHeaders(ctx, req.Header); err != nil {
+			return err
+		}
+		res, err := u.c.hc.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if err := googleapi.CheckResponse(res); err != nil {
+			return err
+		}
+		u.uri = res.Header.Get("Location")
+		if u.uri == "" {
+			return errors.New("storage: resumable upload session response has no Location header")
+		}
+		return nil
+	}, u.s.retry, u.s.idempotent, withOperation("WriteObject"), withBucket(u.params.bucket), withObject(attrs.Name))
+}
+
+// uploadChunk uploads chunk, which starts at u.offset, retrying until all of
+// it has been persisted. If final is true, the chunk is the end of the object,
+// and uploadChunk returns the resulting object.
+func (u *httpResumableUpload) uploadChunk(ctx context.Context, chunk []byte, final bool) (*raw.Object, error) {
+	start := u.offset
+	end := start + int64(len(chunk))
+	var hash string
+	if final && u.sessions.crcs != nil && !u.params.sendCRC32C && u.params.attrs.MD5 == nil {
+		hash = "crc32c=" + encodeUint32(u.sessions.crcs.sum())
+	}
+
+	retry := u.s.retry
+	if retry == nil {
+		retry = defaultRetry
+	}
+	retry = retry.clone()
+	retry.maxRetryDuration = defaultWriteChunkRetryDeadline
+	if u.params.chunkRetryDeadline != 0 {
+		retry.maxRetryDuration = u.params.chunkRetryDeadline
+	}
+
+	var obj *raw.Object
+	queryFirst := false
+	// Uploads to a session are idempotent, so they are always retried
+	// according to the retry policy.
+	err := run(ctx, func(ctx context.Context) error {
+		if queryFirst {
+			// A previous attempt failed. Find out how much of the chunk the
+			// service persisted before continuing.
+			persisted, o, err := u.query(ctx)
+			if err != nil {
+				return err
+			}
+			if o != nil {
+				obj = o
+				return nil
+			}
+			u.offset = persisted
+			queryFirst = false
+		}
+		for {
+			if u.offset < start || u.offset > end {
+				return fmt.Errorf("storage: resumable upload persisted %d bytes, outside of the chunk at [%d, %d)", u.offset, start, end)
+			}
+			if u.offset == end && !final {
+				return nil
+			}
+			persisted, o, err := u.put(ctx, chunk[u.offset-start:], u.offset, final, hash)
+			if err != nil {
+				queryFirst = true
+				return err
+			}
+			if o != nil {
+				obj = o
+				return nil
+			}
+			if final && persisted == end {
+				return errors.New("storage: resumable upload was not finalized after the final chunk")
+			}
+			u.offset = persisted
+		}
+	}, retry, true, withOperation("WriteObject"), withBucket(u.params.bucket), withObject(u.params.attrs.Name))
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		u.offset = end
+	}
+	return obj, nil
+}
+
+// put uploads data at offset to the session. It returns the number of bytes
+// persisted, or the resulting object if the upload is complete.
+func (u *httpResumableUpload) put(ctx context.Context, data []byte, offset int64, final bool, hash string) (int64, *raw.Object, error) {
+	if u.params.chunkTransferTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.params.chunkTransferTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.uri, bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, err
+	}
+	total := "*"
+	if final {
+		total = strconv.FormatInt(offset+int64(len(data)), 10)
+		if hash != "" {
+			req.Header.Set("X-Goog-Hash", hash)
+		}
+	}
+	if len(data) == 0 {
+		req.Header.Set("Content-Range", "bytes */"+total)
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", offset, offset+int64(len(data))-1, total))
+	}
+	if err := u.setHeaders(ctx, req.Header); err != nil {
+		return 0, nil, err
+	}
+	return doResumableRequest(u.c.hc, req)
+}
+
+// query returns the number of bytes persisted in the session, or the
+// resulting object if the upload is complete.
+func (u *httpResumableUpload) query(ctx context.Context) (int64, *raw.Object, error) {
+	return queryResumableUpload(ctx, u.c.hc, u.uri, u.params.encryptionKey)
+}
+
+func (u *httpResumableUpload) setHeaders(ctx context.Context, h http.Header) error {
+	if err := setEncryptionHeaders(h, u.params.encryptionKey, false); err != nil {
+		return err
+	}
+	setClientHeader(h)
+	setHeadersFromCtx(ctx, h)
+	return nil
+}
+
+func (c *httpStorageClient) QueryWriteStatus(ctx context.Context, session *ResumableSession, encryptionKey []byte, opts ...storageOption) (int64, *ObjectAttrs, error) {
+	if session.URI == "" {
+		return 0, nil, errors.New("storage: session has no URI; gRPC upload sessions cannot be resumed with an HTTP client")
+	}
+	s := callSettings(c.settings, opts...)
+	var persisted int64
+	var obj *raw.Object
+	err := run(ctx, func(ctx context.Context) error {
+		var err error
+		persisted, obj, err = queryResumableUpload(ctx, c.hc, session.URI, encryptionKey)
+		return err
+	}, s.retry, s.idempotent, withOperation("QueryWriteStatus"), withBucket(session.Bucket), withObject(session.Object))
+	if err != nil {
+		return 0, nil, err
+	}
+	if obj != nil {
+		attrs := newObject(obj)
+		return attrs.Size, attrs, nil
+	}
+	return persisted, nil, nil
+}
+
+// queryResumableUpload asks the service for the status of the upload session
+// at uri.
+func queryResumableUpload(ctx context.Context, hc *http.Client, uri string, encryptionKey []byte) (int64, *raw.Object, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Range", "bytes */*")
+	if err := setEncryptionHeaders(req.Header, encryptionKey, false); err != nil {
+		return 0, nil, err
+	}
+	setClientHeader(req.Header)
+	setHeadersFromCtx(ctx, req.Header)
+	return doResumableRequest(hc, req)
+}
+
+// doResumableRequest sends a request to a resumable upload session and
+// interprets the response. A 308 response reports the number of bytes
+// persisted in its Range header; a 200 or 201 response contains the
+// resulting object.
+func doResumableRequest(hc *http.Client, req *http.Request) (int64, *raw.Object, error) {
+	res, err := hc.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusPermanentRedirect:
+		rng := res.Header.Get("Range")
+		if rng == "" {
+			return 0, nil, nil
+		}
+		last, ok := strings.CutPrefix(rng, "bytes=0-")
+		n, err := strconv.ParseInt(last, 10, 64)
+		if !ok || err != nil {
+			return 0, nil, fmt.Errorf("storage: invalid Range header %q in resumable upload response", rng)
+		}
+		return n + 1, nil, nil
+	case http.StatusOK, http.StatusCreated:
+		var obj raw.Object
+		if err := json.NewDecoder(res.Body).Decode(&obj); err != nil {
+			return 0, nil, err
+		}
+		return 0, &obj, nil
+	}
+	return 0, nil, googleapi.CheckResponse(res)
+}
+
+// resumableUploadQuery holds the query parameters of a request to start a
+// resumable upload. It has the methods that applyConds searches for by name.
+type resumableUploadQuery url.Values
+
+func (q resumableUploadQuery) Set(key, value string) {
+	url.Values(q).Set(key, value)
+}
+
+func (q resumableUploadQuery) IfGenerationMatch(gen int64) {
+	q.Set("ifGenerationMatch", strconv.FormatInt(gen, 10))
+}
+
+func (q resumableUploadQuery) IfGenerationNotMatch(gen int64) {
+	q.Set("ifGenerationNotMatch", strconv.FormatInt(gen, 10))
+}
+
+func (q resumableUploadQuery) IfMetagenerationMatch(metagen int64) {
+	q.Set("ifMetagenerationMatch", strconv.FormatInt(metagen, 10))
+}
+
+func (q resumableUploadQuery) IfMetagenerationNotMatch(metagen int64) {
+	q.Set("ifMetagenerationNotMatch", strconv.FormatInt(metagen, 10))
+}
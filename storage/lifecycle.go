@@ -0,0 +1,378 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// storageClassRank orders storage classes by at-rest price, cheapest first.
+// When several SetStorageClass actions apply to an object, the one to the
+// cheapest class is taken, and an object is only moved to a cheaper class.
+// The legacy classes rank with STANDARD.
+var storageClassRank = map[string]int{
+	"ARCHIVE":                      0,
+	"COLDLINE":                     1,
+	"NEARLINE":                     2,
+	"STANDARD":                     3,
+	"MULTI_REGIONAL":               3,
+	"REGIONAL":                     3,
+	"DURABLE_REDUCED_AVAILABILITY": 3,
+}
+
+// LifecycleEvaluator reports which lifecycle action applies to an object at a
+// given time, without contacting the service. It can be used to test a
+// lifecycle configuration before setting it on a bucket, or to report which
+// objects of a listing an action will apply to.
+//
+// The evaluation follows the documented behavior of Object Lifecycle
+// Management: a Delete action takes precedence over SetStorageClass actions,
+// and of several SetStorageClass actions the one to the storage class with the
+// lowest at-rest price is taken. A SetStorageClass action is only taken if it
+// moves the object to a known storage class with a lower price than its
+// current one; objects whose storage class is empty or unknown may be moved to
+// any known class. A Delete action is not taken while the object is retained
+// by a hold, its retention configuration or the retention policy of the
+// bucket. The service evaluates rules asynchronously, so an action may be
+// taken some time after it applies.
+type LifecycleEvaluator struct {
+	// Lifecycle is the lifecycle configuration of the bucket.
+	Lifecycle Lifecycle
+
+	// RetentionPolicy is the retention policy of the bucket. Optional.
+	RetentionPolicy *RetentionPolicy
+
+	// SoftDeletePolicy is the soft delete policy of the bucket. Optional.
+	SoftDeletePolicy *SoftDeletePolicy
+
+	// VersioningEnabled reports whether object versioning is enabled on the
+	// bucket. A Delete action on the live version of an object in such a
+	// bucket makes it noncurrent.
+	VersioningEnabled bool
+}
+
+// NewLifecycleEvaluator returns a LifecycleEvaluator for the configuration of
+// a bucket.
+func NewLifecycleEvaluator(attrs *BucketAttrs) *LifecycleEvaluator {
+	return &LifecycleEvaluator{
+		Lifecycle:         attrs.Lifecycle,
+		RetentionPolicy:   attrs.RetentionPolicy,
+		SoftDeletePolicy:  attrs.SoftDeletePolicy,
+		VersioningEnabled: attrs.VersioningEnabled,
+	}
+}
+
+// LifecycleResult is the result of evaluating a lifecycle configuration for an
+// object or multipart upload.
+type LifecycleResult struct {
+	// Action is the action that applies, or nil if no action applies.
+	Action *LifecycleAction
+
+	// Rule is the index in Lifecycle.Rules of the rule of Action, or -1 if no
+	// action applies.
+	Rule int
+
+	// Reasons describe the conditions of the rule that are met, followed by
+	// the reasons the action is blocked or its effect, if any.
+	Reasons []string
+
+	// Blocked reports whether Action is a Delete action that cannot be taken
+	// yet because the object is retained.
+	Blocked bool
+
+	// RetainedUntil is the time until which a blocked Delete action cannot be
+	// taken. It is zero if the object is retained by a hold, which must be
+	// released first.
+	RetainedUntil time.Time
+
+	// Noncurrent reports whether Action is a Delete action that makes the
+	// object noncurrent rather than deleting it, since the bucket has object
+	// versioning enabled.
+	Noncurrent bool
+
+	// SoftDeletedUntil is the time until which an object deleted by Action
+	// can be restored, if the bucket has a soft delete policy.
+	SoftDeletedUntil time.Time
+}
+
+// String returns a description of the result.
+func (r *LifecycleResult) String() string {
+	if r.Action == nil {
+		return "no action"
+	}
+	s := fmt.Sprintf("rule %d: %s", r.Rule, r.Action.Type)
+	if r.Action.Type == SetStorageClassAction {
+		s += " to " + r.Action.StorageClass
+	}
+	if len(r.Reasons) > 0 {
+		s += " (" + strings.Join(r.Reasons, "; ") + ")"
+	}
+	return s
+}
+
+// Evaluate returns the lifecycle action that applies to the object at the
+// time now. The object is live unless attrs.Deleted is set, in which case it
+// is a noncurrent version.
+//
+// NumNewerVersions conditions are evaluated as if attrs were the newest
+// version of the object. Use EvaluateVersions to evaluate the versions of an
+// object together.
+func (e *LifecycleEvaluator) Evaluate(attrs *ObjectAttrs, now time.Time) *LifecycleResult {
+	return e.evaluate(attrs, 0, now)
+}
+
+// EvaluateVersions returns the lifecycle actions that apply at the time now to
+// the objects of a listing made with Query.Versions set. The number of newer
+// versions of each object is that of the listed versions with the same name
+// and a greater generation. The results are in the order of versions.
+func (e *LifecycleEvaluator) EvaluateVersions(versions []*ObjectAttrs, now time.Time) []*LifecycleResult {
+	gens := map[string][]int64{}
+	for _, v := range versions {
+		gens[v.Name] = append(gens[v.Name], v.Generation)
+	}
+	results := make([]*LifecycleResult, len(versions))
+	for i, v := range versions {
+		var newer int64
+		for _, g := range gens[v.Name] {
+			if g > v.Generation {
+				newer++
+			}
+		}
+		results[i] = e.evaluate(v, newer, now)
+	}
+	return results
+}
+
+// EvaluateMultipartUpload returns the lifecycle action that applies at the
+// time now to an incomplete XML API multipart upload of the object name that
+// was initiated at the time created. Only AbortIncompleteMultipartUpload
+// actions apply to multipart uploads.
+func (e *LifecycleEvaluator) EvaluateMultipartUpload(name string, created time.Time, now time.Time) *LifecycleResult {
+	res := &LifecycleResult{Rule: -1}
+	for i, rule := range e.Lifecycle.Rules {
+		if rule.Action.Type != AbortIncompleteMPUAction {
+			continue
+		}
+		if reasons, ok := matchMultipartUpload(rule.Condition, name, created, now); ok {
+			res.Action = &LifecycleAction{Type: rule.Action.Type}
+			res.Rule = i
+			res.Reasons = reasons
+			break
+		}
+	}
+	return res
+}
+
+func (e *LifecycleEvaluator) evaluate(attrs *ObjectAttrs, newerVersions int64, now time.Time) *LifecycleResult {
+	res := &LifecycleResult{Rule: -1}
+	for i, rule := range e.Lifecycle.Rules {
+		switch rule.Action.Type {
+		case DeleteAction:
+			if res.Action != nil && res.Action.Type == DeleteAction {
+				continue
+			}
+		case SetStorageClassAction:
+			// Rules to unknown storage classes never apply, and rules to a
+			// class that is not cheaper than that of the object are not
+			// taken.
+			rank, ok := storageClassRank[rule.Action.StorageClass]
+			if !ok {
+				continue
+			}
+			if cur, ok := storageClassRank[attrs.StorageClass]; ok && rank >= cur {
+				continue
+			}
+			if res.Action != nil && (res.Action.Type == DeleteAction ||
+				storageClassRank[res.Action.StorageClass] <= rank) {
+				continue
+			}
+		default:
+			continue
+		}
+		reasons, ok := matchObject(rule.Condition, attrs, newerVersions, now)
+		if !ok {
+			continue
+		}
+		action := rule.Action
+		res.Action = &action
+		res.Rule = i
+		res.Reasons = reasons
+	}
+	if res.Action == nil || res.Action.Type != DeleteAction {
+		return res
+	}
+
+	if reason, until, retained := e.retention(attrs, now); retained {
+		res.Blocked = true
+		res.RetainedUntil = until
+		res.Reasons = append(res.Reasons, reason)
+		return res
+	}
+	if e.VersioningEnabled && attrs.Deleted.IsZero() {
+		res.Noncurrent = true
+		res.Reasons = append(res.Reasons, "versioning is enabled, so the object becomes noncurrent")
+		return res
+	}
+	if p := e.SoftDeletePolicy; p != nil && p.RetentionDuration > 0 {
+		res.SoftDeletedUntil = now.Add(p.RetentionDuration)
+		res.Reasons = append(res.Reasons, fmt.Sprintf("the deleted object can be restored until %s", res.SoftDeletedUntil.Format(time.RFC3339)))
+	}
+	return res
+}
+
+// retention reports whether the object cannot be deleted at the time now, and
+// if so why and until when.
+func (e *LifecycleEvaluator) retention(attrs *ObjectAttrs, now time.Time) (reason string, until time.Time, retained bool) {
+	if attrs.TemporaryHold {
+		return "the object is under a temporary hold", time.Time{}, true
+	}
+	if attrs.EventBasedHold {
+		return "the object is under an event-based hold", time.Time{}, true
+	}
+	if r := attrs.Retention; r != nil && r.RetainUntil.After(now) {
+		return fmt.Sprintf("the object is retained until %s by its retention configuration", r.RetainUntil.Format(time.RFC3339)), r.RetainUntil, true
+	}
+	until = attrs.RetentionExpirationTime
+	if p := e.RetentionPolicy; until.IsZero() && p != nil && p.RetentionPeriod > 0 {
+		until = attrs.Created.Add(p.RetentionPeriod)
+	}
+	if until.After(now) {
+		return fmt.Sprintf("the object is retained until %s by the bucket retention policy", until.Format(time.RFC3339)), until, true
+	}
+	return "", time.Time{}, false
+}
+
+// matchObject reports whether an object meets all the conditions of a rule,
+// and if so returns a description of each condition.
+func matchObject(c LifecycleCondition, attrs *ObjectAttrs, newerVersions int64, now time.Time) ([]string, bool) {
+	var reasons []string
+	live := attrs.Deleted.IsZero()
+
+	if c.AgeInDays > 0 || c.AllObjects {
+		if !olderThan(attrs.Created, c.AgeInDays, now) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("age is at least %d days", c.AgeInDays))
+	}
+	if !c.CreatedBefore.IsZero() {
+		if !attrs.Created.Before(midnightUTC(c.CreatedBefore)) {
+			return nil, false
+		}
+		reasons = append(reasons, "created before "+c.CreatedBefore.Format(rfc3339Date))
+	}
+	if !c.CustomTimeBefore.IsZero() {
+		if attrs.CustomTime.IsZero() || !attrs.CustomTime.Before(midnightUTC(c.CustomTimeBefore)) {
+			return nil, false
+		}
+		reasons = append(reasons, "custom time before "+c.CustomTimeBefore.Format(rfc3339Date))
+	}
+	if c.DaysSinceCustomTime > 0 {
+		if attrs.CustomTime.IsZero() || !olderThan(attrs.CustomTime, c.DaysSinceCustomTime, now) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("at least %d days since custom time", c.DaysSinceCustomTime))
+	}
+	if c.DaysSinceNoncurrentTime > 0 {
+		if live || !olderThan(attrs.Deleted, c.DaysSinceNoncurrentTime, now) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("noncurrent for at least %d days", c.DaysSinceNoncurrentTime))
+	}
+	switch c.Liveness {
+	case Live:
+		if !live {
+			return nil, false
+		}
+		reasons = append(reasons, "object is live")
+	case Archived:
+		if live {
+			return nil, false
+		}
+		reasons = append(reasons, "object is noncurrent")
+	}
+	reasons, ok := matchName(c, attrs.Name, reasons)
+	if !ok {
+		return nil, false
+	}
+	if len(c.MatchesStorageClasses) > 0 {
+		if !slices.Contains(c.MatchesStorageClasses, attrs.StorageClass) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("storage class is %s", attrs.StorageClass))
+	}
+	if !c.NoncurrentTimeBefore.IsZero() {
+		if live || !attrs.Deleted.Before(midnightUTC(c.NoncurrentTimeBefore)) {
+			return nil, false
+		}
+		reasons = append(reasons, "noncurrent before "+c.NoncurrentTimeBefore.Format(rfc3339Date))
+	}
+	if c.NumNewerVersions > 0 {
+		if newerVersions < c.NumNewerVersions {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("%d newer versions", newerVersions))
+	}
+	return reasons, true
+}
+
+// matchMultipartUpload reports whether a multipart upload meets all the
+// conditions of an AbortIncompleteMultipartUpload rule, and if so returns a
+// description of each condition.
+func matchMultipartUpload(c LifecycleCondition, name string, created, now time.Time) ([]string, bool) {
+	var reasons []string
+	if c.AgeInDays > 0 || c.AllObjects {
+		if !olderThan(created, c.AgeInDays, now) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("age is at least %d days", c.AgeInDays))
+	}
+	return matchName(c, name, reasons)
+}
+
+// matchName reports whether a name meets the prefix and suffix conditions of
+// a rule, and if so appends a description of each condition to reasons.
+func matchName(c LifecycleCondition, name string, reasons []string) ([]string, bool) {
+	if len(c.MatchesPrefix) > 0 {
+		i := slices.IndexFunc(c.MatchesPrefix, func(p string) bool { return strings.HasPrefix(name, p) })
+		if i < 0 {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("name has prefix %q", c.MatchesPrefix[i]))
+	}
+	if len(c.MatchesSuffix) > 0 {
+		i := slices.IndexFunc(c.MatchesSuffix, func(s string) bool { return strings.HasSuffix(name, s) })
+		if i < 0 {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("name has suffix %q", c.MatchesSuffix[i]))
+	}
+	return reasons, true
+}
+
+// olderThan reports whether at least days days have passed since t at the
+// time now.
+func olderThan(t time.Time, days int64, now time.Time) bool {
+	return !now.Before(t.Add(time.Duration(days) * 24 * time.Hour))
+}
+
+// midnightUTC returns midnight UTC of the date of t, which is how the date
+// conditions of lifecycle rules are interpreted.
+func midnightUTC(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
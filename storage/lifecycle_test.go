@@ -0,0 +1,261 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLifecycleEvaluate(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) time.Time { return now.Add(-time.Duration(n) * 24 * time.Hour) }
+	lifecycle := Lifecycle{Rules: []LifecycleRule{
+		{
+			Action:    LifecycleAction{Type: SetStorageClassAction, StorageClass: "NEARLINE"},
+			Condition: LifecycleCondition{AgeInDays: 30, MatchesStorageClasses: []string{"STANDARD"}},
+		},
+		{
+			Action:    LifecycleAction{Type: SetStorageClassAction, StorageClass: "COLDLINE"},
+			Condition: LifecycleCondition{AgeInDays: 90, MatchesStorageClasses: []string{"STANDARD", "NEARLINE"}},
+		},
+		{
+			Action:    LifecycleAction{Type: DeleteAction},
+			Condition: LifecycleCondition{AgeInDays: 365},
+		},
+		{
+			Action:    LifecycleAction{Type: DeleteAction},
+			Condition: LifecycleCondition{MatchesPrefix: []string{"tmp/"}, MatchesSuffix: []string{".log"}, AllObjects: true},
+		},
+		{
+			Action:    LifecycleAction{Type: DeleteAction},
+			Condition: LifecycleCondition{Liveness: Archived, DaysSinceNoncurrentTime: 7},
+		},
+		{
+			Action:    LifecycleAction{Type: DeleteAction},
+			Condition: LifecycleCondition{CustomTimeBefore: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			Action:    LifecycleAction{Type: AbortIncompleteMPUAction},
+			Condition: LifecycleCondition{AgeInDays: 7},
+		},
+	}}
+
+	for _, test := range []struct {
+		desc      string
+		eval      LifecycleEvaluator
+		attrs     ObjectAttrs
+		wantRule  int
+		wantCheck func(*LifecycleResult) bool
+	}{
+		{
+			desc:     "young object",
+			attrs:    ObjectAttrs{Name: "a", StorageClass: "STANDARD", Created: daysAgo(10)},
+			wantRule: -1,
+		},
+		{
+			desc:     "set storage class",
+			attrs:    ObjectAttrs{Name: "a", StorageClass: "STANDARD", Created: daysAgo(40)},
+			wantRule: 0,
+		},
+		{
+			desc:     "cheapest storage class wins",
+			attrs:    ObjectAttrs{Name: "a", StorageClass: "STANDARD", Created: daysAgo(100)},
+			wantRule: 1,
+		},
+		{
+			desc:     "storage class condition",
+			attrs:    ObjectAttrs{Name: "a", StorageClass: "COLDLINE", Created: daysAgo(100)},
+			wantRule: -1,
+		},
+		{
+			desc:     "delete takes precedence",
+			attrs:    ObjectAttrs{Name: "a", StorageClass: "STANDARD", Created: daysAgo(400)},
+			wantRule: 2,
+		},
+		{
+			desc:     "prefix and suffix",
+			attrs:    ObjectAttrs{Name: "tmp/x.log", Created: now},
+			wantRule: 3,
+		},
+		{
+			desc:     "suffix not matched",
+			attrs:    ObjectAttrs{Name: "tmp/x.txt", Created: now},
+			wantRule: -1,
+		},
+		{
+			desc:     "noncurrent",
+			attrs:    ObjectAttrs{Name: "a", Created: daysAgo(20), Deleted: daysAgo(8)},
+			wantRule: 4,
+		},
+		{
+			desc:     "recently noncurrent",
+			attrs:    ObjectAttrs{Name: "a", Created: daysAgo(20), Deleted: daysAgo(6)},
+			wantRule: -1,
+		},
+		{
+			desc:     "custom time",
+			attrs:    ObjectAttrs{Name: "a", Created: now, CustomTime: time.Date(2025, 12, 31, 23, 0, 0, 0, time.UTC)},
+			wantRule: 5,
+		},
+		{
+			desc:     "custom time on the date",
+			attrs:    ObjectAttrs{Name: "a", Created: now, CustomTime: time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)},
+			wantRule: -1,
+		},
+		{
+			desc:     "retention policy",
+			eval:     LifecycleEvaluator{RetentionPolicy: &RetentionPolicy{RetentionPeriod: 500 * 24 * time.Hour}},
+			attrs:    ObjectAttrs{Name: "a", Created: daysAgo(400)},
+			wantRule: 2,
+			wantCheck: func(r *LifecycleResult) bool {
+				return r.Blocked && r.RetainedUntil.Equal(daysAgo(400).Add(500*24*time.Hour))
+			},
+		},
+		{
+			desc:      "expired retention policy",
+			eval:      LifecycleEvaluator{RetentionPolicy: &RetentionPolicy{RetentionPeriod: 100 * 24 * time.Hour}},
+			attrs:     ObjectAttrs{Name: "a", Created: daysAgo(400)},
+			wantRule:  2,
+			wantCheck: func(r *LifecycleResult) bool { return !r.Blocked },
+		},
+		{
+			desc:      "hold",
+			attrs:     ObjectAttrs{Name: "a", Created: daysAgo(400), TemporaryHold: true},
+			wantRule:  2,
+			wantCheck: func(r *LifecycleResult) bool { return r.Blocked && r.RetainedUntil.IsZero() },
+		},
+		{
+			desc:      "object retention",
+			attrs:     ObjectAttrs{Name: "a", Created: daysAgo(400), Retention: &ObjectRetention{Mode: "Locked", RetainUntil: now.Add(time.Hour)}},
+			wantRule:  2,
+			wantCheck: func(r *LifecycleResult) bool { return r.Blocked && r.RetainedUntil.Equal(now.Add(time.Hour)) },
+		},
+		{
+			desc:      "versioning",
+			eval:      LifecycleEvaluator{VersioningEnabled: true},
+			attrs:     ObjectAttrs{Name: "a", Created: daysAgo(400)},
+			wantRule:  2,
+			wantCheck: func(r *LifecycleResult) bool { return r.Noncurrent && r.SoftDeletedUntil.IsZero() },
+		},
+		{
+			desc:      "soft delete",
+			eval:      LifecycleEvaluator{SoftDeletePolicy: &SoftDeletePolicy{RetentionDuration: 7 * 24 * time.Hour}},
+			attrs:     ObjectAttrs{Name: "a", Created: daysAgo(400)},
+			wantRule:  2,
+			wantCheck: func(r *LifecycleResult) bool { return r.SoftDeletedUntil.Equal(now.Add(7 * 24 * time.Hour)) },
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			e := test.eval
+			e.Lifecycle = lifecycle
+			got := e.Evaluate(&test.attrs, now)
+			if got.Rule != test.wantRule {
+				t.Fatalf("got %v, want rule %d", got, test.wantRule)
+			}
+			if test.wantRule >= 0 && (*got.Action != lifecycle.Rules[test.wantRule].Action || len(got.Reasons) == 0) {
+				t.Errorf("got %v, want the action of rule %d with reasons", got, test.wantRule)
+			}
+			if test.wantCheck != nil && !test.wantCheck(got) {
+				t.Errorf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestLifecycleEvaluateStorageClasses(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	setClass := func(class string) LifecycleRule {
+		return LifecycleRule{
+			Action:    LifecycleAction{Type: SetStorageClassAction, StorageClass: class},
+			Condition: LifecycleCondition{AgeInDays: 1},
+		}
+	}
+	for _, test := range []struct {
+		desc     string
+		rules    []LifecycleRule
+		class    string
+		wantRule int
+	}{
+		{"unknown target class", []LifecycleRule{setClass("NEARLINE"), setClass("COLDLNE")}, "STANDARD", 0},
+		{"legacy target class", []LifecycleRule{setClass("REGIONAL"), setClass("NEARLINE")}, "STANDARD", 1},
+		{"legacy object class", []LifecycleRule{setClass("NEARLINE")}, "MULTI_REGIONAL", 0},
+		{"warmer target class", []LifecycleRule{setClass("NEARLINE")}, "COLDLINE", -1},
+		{"same target class", []LifecycleRule{setClass("STANDARD")}, "REGIONAL", -1},
+		{"colder target class", []LifecycleRule{setClass("STANDARD"), setClass("ARCHIVE")}, "COLDLINE", 1},
+		{"unknown object class", []LifecycleRule{setClass("STANDARD")}, "", 0},
+	} {
+		e := LifecycleEvaluator{Lifecycle: Lifecycle{Rules: test.rules}}
+		got := e.Evaluate(&ObjectAttrs{Name: "a", StorageClass: test.class, Created: now.Add(-48 * time.Hour)}, now)
+		if got.Rule != test.wantRule {
+			t.Errorf("%s: got %v, want rule %d", test.desc, got, test.wantRule)
+		}
+	}
+}
+
+func TestLifecycleEvaluateVersions(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	e := NewLifecycleEvaluator(&BucketAttrs{
+		VersioningEnabled: true,
+		Lifecycle: Lifecycle{Rules: []LifecycleRule{{
+			Action:    LifecycleAction{Type: DeleteAction},
+			Condition: LifecycleCondition{NumNewerVersions: 2},
+		}}},
+	})
+	versions := []*ObjectAttrs{
+		{Name: "a", Generation: 1, Deleted: now},
+		{Name: "a", Generation: 2, Deleted: now},
+		{Name: "a", Generation: 3},
+		{Name: "b", Generation: 1, Deleted: now},
+		{Name: "b", Generation: 2},
+	}
+	var deleted []int64
+	for i, r := range e.EvaluateVersions(versions, now) {
+		if r.Action != nil {
+			if r.Noncurrent {
+				t.Errorf("version %d: got Noncurrent for a noncurrent version", i)
+			}
+			deleted = append(deleted, versions[i].Generation)
+		}
+	}
+	if len(deleted) != 1 || deleted[0] != 1 {
+		t.Errorf("got deleted generations %v, want [1]", deleted)
+	}
+	if r := e.Evaluate(versions[0], now); r.Action != nil {
+		t.Errorf("Evaluate of a single version: got %v, want no action", r)
+	}
+}
+
+func TestLifecycleEvaluateMultipartUpload(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	e := &LifecycleEvaluator{Lifecycle: Lifecycle{Rules: []LifecycleRule{
+		{Action: LifecycleAction{Type: DeleteAction}, Condition: LifecycleCondition{AllObjects: true}},
+		{Action: LifecycleAction{Type: AbortIncompleteMPUAction}, Condition: LifecycleCondition{AgeInDays: 7, MatchesPrefix: []string{"uploads/"}}},
+	}}}
+	r := e.EvaluateMultipartUpload("uploads/x", now.Add(-8*24*time.Hour), now)
+	if r.Rule != 1 || r.Action.Type != AbortIncompleteMPUAction {
+		t.Errorf("got %v, want rule 1", r)
+	}
+	if !strings.Contains(r.String(), "uploads/") {
+		t.Errorf("String: got %q, want the matched prefix", r.String())
+	}
+	if r := e.EvaluateMultipartUpload("uploads/x", now.Add(-6*24*time.Hour), now); r.Action != nil {
+		t.Errorf("recent upload: got %v, want no action", r)
+	}
+	if r := e.EvaluateMultipartUpload("other", now.Add(-8*24*time.Hour), now); r.Action != nil {
+		t.Errorf("other prefix: got %v, want no action", r)
+	}
+}
@@ -23,6 +23,8 @@ type lruCache[K comparable, V any] struct {
 	entries   map[K]*list.Element
 	evictList *list.List
 	limit     int
+	// onEvict, if set, is called for each entry removed from the cache.
+	onEvict func(K, V)
 }
 
 type cacheEntry[K comparable, V any] struct {
@@ -95,4 +97,7 @@ func (c *lruCache[K, V]) removeElement(elem *list.Element) {
 	c.evictList.Remove(elem)
 	kv := elem.Value.(*cacheEntry[K, V])
 	delete(c.entries, kv.key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
 }
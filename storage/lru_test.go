@@ -14,7 +14,10 @@
 
 package storage
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestLRUCache(t *testing.T) {
 	c := newLRUCache[string, int](3)
@@ -50,3 +53,20 @@ func TestLRUCache(t *testing.T) {
 		t.Errorf("expected b3 to be evicted manually")
 	}
 }
+
+func TestLRUCacheOnEvict(t *testing.T) {
+	c := newLRUCache[string, int](2)
+	var evicted []string
+	c.onEvict = func(k string, v int) { evicted = append(evicted, k) }
+
+	c.put("b1", 1)
+	c.put("b2", 2)
+	c.put("b1", 10) // Updating a value does not evict it.
+	c.put("b3", 3)
+	c.evict("b1")
+	c.removeOldest()
+
+	if got, want := fmt.Sprint(evicted), "[b2 b1 b3]"; got != want {
+		t.Errorf("evicted: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,565 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// MinMultipartPartSize is the minimum size of each part of a multipart
+	// upload but the last.
+	MinMultipartPartSize = 5 << 20
+
+	// MaxMultipartParts is the maximum number of parts of a multipart upload.
+	MaxMultipartParts = 10000
+
+	defaultMultipartPartSize    = 16 << 20
+	defaultMultipartConcurrency = 4
+)
+
+// MultipartUploader uploads an object with an XML API multipart upload: the
+// content is split into parts that are uploaded concurrently, and the object
+// is created atomically from the parts when all of them have been uploaded.
+// Unlike parallel composite uploads, multipart uploads do not create temporary
+// objects and do not need permission to compose objects.
+//
+// Create a MultipartUploader with [ObjectHandle.MultipartUploader], set its
+// fields, then call [MultipartUploader.Upload], or use
+// [MultipartUploader.Initiate] to upload the parts yourself.
+//
+// The resulting object has a CRC32C checksum but no MD5 hash. Multipart
+// uploads are only supported by clients that use the JSON API. Customer-
+// supplied encryption keys are not supported.
+type MultipartUploader struct {
+	// ObjectAttrs are optional attributes of the new object. The content
+	// type, content encoding, content disposition, content language, cache
+	// control, metadata, storage class and KMS key name are used; the other
+	// fields are ignored.
+	ObjectAttrs
+
+	// PartSize is the size of each part but the last. It defaults to 16 MiB
+	// and must be at least MinMultipartPartSize. Upload holds up to
+	// Concurrency+1 parts in memory.
+	PartSize int64
+
+	// Concurrency is the maximum number of parts uploaded concurrently. It
+	// defaults to 4.
+	Concurrency int
+
+	o *ObjectHandle
+}
+
+// MultipartUploader returns a MultipartUploader for the object. The
+// GenerationMatch, DoesNotExist and MetagenerationMatch conditions of the
+// handle apply to the completion of the upload.
+func (o *ObjectHandle) MultipartUploader() *MultipartUploader {
+	return &MultipartUploader{o: o}
+}
+
+// MultipartUpload is an incomplete multipart upload of an object.
+type MultipartUpload struct {
+	o        *ObjectHandle
+	uploadID string
+}
+
+// MultipartUpload returns a handle for the incomplete multipart upload of the
+// object with the given upload ID, for example to continue an upload made by
+// another process or to abort it.
+func (o *ObjectHandle) MultipartUpload(uploadID string) *MultipartUpload {
+	return &MultipartUpload{o: o, uploadID: uploadID}
+}
+
+// UploadID returns the ID of the upload.
+func (m *MultipartUpload) UploadID() string {
+	return m.uploadID
+}
+
+// MultipartPart is an uploaded part of a multipart upload.
+type MultipartPart struct {
+	// PartNumber is the number of the part, between 1 and MaxMultipartParts.
+	// Parts are assembled in order of their numbers.
+	PartNumber int
+
+	// ETag is the entity tag of the part, which is quoted hex MD5 hash of its
+	// content.
+	ETag string
+
+	// Size is the size of the part in bytes.
+	Size int64
+
+	// LastModified is the time the part was uploaded. It is only set by
+	// ListParts.
+	LastModified time.Time
+}
+
+// Upload reads r until EOF, uploads its content in parts and completes the
+// upload. Each part is sent with its MD5 and CRC32C checksums and is retried
+// according to the retry configuration of the object handle; the CRC32C
+// checksum of the object is verified once the upload is complete. If the
+// upload fails, it is aborted.
+func (u *MultipartUploader) Upload(ctx context.Context, r io.Reader) (attrs *ObjectAttrs, err error) {
+	ctx, _ = startSpanWithBucket(ctx, u.o.c, u.o.bucket, "MultipartUploader.Upload")
+	defer func() { endSpan(ctx, err) }()
+
+	partSize := u.PartSize
+	if partSize == 0 {
+		partSize = defaultMultipartPartSize
+	}
+	if partSize < MinMultipartPartSize {
+		return nil, fmt.Errorf("storage: multipart upload part size %d is less than the minimum of %d bytes", partSize, MinMultipartPartSize)
+	}
+	concurrency := u.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultMultipartConcurrency
+	}
+	m, err := u.Initiate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			// The upload is aborted even if ctx is done.
+			m.Abort(context.WithoutCancel(ctx))
+		}
+	}()
+
+	var (
+		mu    sync.Mutex
+		parts []*MultipartPart
+	)
+	crc := crc32.New(crc32cTable)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for n := 1; gctx.Err() == nil; n++ {
+		buf := make([]byte, partSize)
+		k, rerr := io.ReadFull(r, buf)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			g.Wait()
+			return nil, rerr
+		}
+		if k == 0 && n > 1 {
+			break
+		}
+		if n > MaxMultipartParts {
+			g.Wait()
+			return nil, fmt.Errorf("storage: multipart upload has more than %d parts of %d bytes", MaxMultipartParts, partSize)
+		}
+		buf = buf[:k]
+		crc.Write(buf)
+		g.Go(func() error {
+			p, err := m.UploadPart(gctx, n, buf)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			parts = append(parts, p)
+			mu.Unlock()
+			return nil
+		})
+		if rerr != nil {
+			break
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if attrs, err = m.Complete(ctx, parts); err != nil {
+		return nil, err
+	}
+	if got, want := attrs.CRC32C, crc.Sum32(); got != want {
+		return attrs, fmt.Errorf("storage: multipart upload: CRC32C checksum of the object %d does not match the checksum of its content %d", got, want)
+	}
+	return attrs, nil
+}
+
+// Initiate starts a multipart upload of the object.
+func (u *MultipartUploader) Initiate(ctx context.Context) (m *MultipartUpload, err error) {
+	ctx, _ = startSpanWithBucket(ctx, u.o.c, u.o.bucket, "MultipartUploader.Initiate")
+	defer func() { endSpan(ctx, err) }()
+
+	if err := u.o.validate(); err != nil {
+		return nil, err
+	}
+	if u.o.encryptionKey != nil {
+		return nil, errors.New("storage: multipart uploads do not support customer-supplied encryption keys")
+	}
+	if u.o.conds != nil {
+		if err := u.o.conds.validate("MultipartUploader.Initiate"); err != nil {
+			return nil, err
+		}
+		if c := u.o.conds; c.GenerationNotMatch != 0 || c.MetagenerationNotMatch != 0 {
+			return nil, errors.New("storage: multipart uploads only support the GenerationMatch, DoesNotExist and MetagenerationMatch conditions")
+		}
+	}
+	h := http.Header{}
+	for k, v := range map[string]string{
+		"Content-Type":                   u.ContentType,
+		"Content-Encoding":               u.ContentEncoding,
+		"Content-Disposition":            u.ContentDisposition,
+		"Content-Language":               u.ContentLanguage,
+		"Cache-Control":                  u.CacheControl,
+		"X-Goog-Storage-Class":           u.StorageClass,
+		"X-Goog-Encryption-Kms-Key-Name": u.KMSKeyName,
+	} {
+		if v != "" {
+			h.Set(k, v)
+		}
+	}
+	for k, v := range u.Metadata {
+		h.Set("X-Goog-Meta-"+k, v)
+	}
+	var res struct {
+		UploadID string `xml:"UploadId"`
+	}
+	// Retrying an initiation at worst leaves an empty upload behind.
+	err = xmlCall(ctx, u.o, "InitiateMultipartUpload", http.MethodPost, url.Values{"uploads": {""}}, h, nil, true, func(r *http.Response) error {
+		return xml.NewDecoder(r.Body).Decode(&res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.UploadID == "" {
+		return nil, errors.New("storage: multipart upload initiation returned no upload ID")
+	}
+	return u.o.MultipartUpload(res.UploadID), nil
+}
+
+// UploadPart uploads a part of the upload, replacing any part with the same
+// number. The part is sent with its MD5 and CRC32C checksums.
+func (m *MultipartUpload) UploadPart(ctx context.Context, partNumber int, data []byte) (p *MultipartPart, err error) {
+	ctx, _ = startSpanWithBucket(ctx, m.o.c, m.o.bucket, "MultipartUpload.UploadPart")
+	defer func() { endSpan(ctx, err) }()
+
+	if partNumber < 1 || partNumber > MaxMultipartParts {
+		return nil, fmt.Errorf("storage: part number %d is not between 1 and %d", partNumber, MaxMultipartParts)
+	}
+	sum := md5.Sum(data)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(data, crc32cTable))
+	h := http.Header{}
+	h.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	h.Set("X-Goog-Hash", "crc32c="+base64.StdEncoding.EncodeToString(crc[:]))
+	q := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {m.uploadID},
+	}
+	var etag string
+	err = xmlCall(ctx, m.o, "UploadPart", http.MethodPut, q, h, data, true, func(r *http.Response) error {
+		etag = r.Header.Get("ETag")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if got, want := strings.Trim(etag, `"`), hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("storage: part %d: ETag %q does not match the MD5 hash of the part %q", partNumber, etag, want)
+	}
+	return &MultipartPart{PartNumber: partNumber, ETag: etag, Size: int64(len(data))}, nil
+}
+
+type xmlPart struct {
+	PartNumber   int
+	ETag         string
+	Size         int64  `xml:",omitempty"`
+	LastModified string `xml:",omitempty"`
+}
+
+// ListParts returns the parts of the upload that have been uploaded, in order
+// of their numbers.
+func (m *MultipartUpload) ListParts(ctx context.Context) (parts []*MultipartPart, err error) {
+	ctx, _ = startSpanWithBucket(ctx, m.o.c, m.o.bucket, "MultipartUpload.ListParts")
+	defer func() { endSpan(ctx, err) }()
+
+	marker := "0"
+	for {
+		var res struct {
+			NextPartNumberMarker string
+			IsTruncated          bool
+			Parts                []xmlPart `xml:"Part"`
+		}
+		q := url.Values{"uploadId": {m.uploadID}, "part-number-marker": {marker}}
+		err := xmlCall(ctx, m.o, "ListParts", http.MethodGet, q, nil, nil, true, func(r *http.Response) error {
+			return xml.NewDecoder(r.Body).Decode(&res)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range res.Parts {
+			t, _ := time.Parse(time.RFC3339Nano, p.LastModified)
+			parts = append(parts, &MultipartPart{PartNumber: p.PartNumber, ETag: p.ETag, Size: p.Size, LastModified: t})
+		}
+		if !res.IsTruncated || res.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+// Complete creates the object from the given parts, which may be in any
+// order, and returns its attributes. Parts that have been uploaded but are not
+// given are discarded.
+func (m *MultipartUpload) Complete(ctx context.Context, parts []*MultipartPart) (attrs *ObjectAttrs, err error) {
+	ctx, _ = startSpanWithBucket(ctx, m.o.c, m.o.bucket, "MultipartUpload.Complete")
+	defer func() { endSpan(ctx, err) }()
+
+	if len(parts) == 0 {
+		return nil, errors.New("storage: a multipart upload must be completed with at least one part")
+	}
+	parts = slices.Clone(parts)
+	slices.SortFunc(parts, func(a, b *MultipartPart) int { return a.PartNumber - b.PartNumber })
+	req := struct {
+		XMLName xml.Name  `xml:"CompleteMultipartUpload"`
+		Parts   []xmlPart `xml:"Part"`
+	}{}
+	for _, p := range parts {
+		req.Parts = append(req.Parts, xmlPart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	h := http.Header{}
+	// Completing an upload twice fails, so it is only retried if the
+	// conditions of the handle ensure that the first attempt did not succeed.
+	isIdempotent := false
+	if c := m.o.conds; c != nil {
+		if c.GenerationMatch != 0 || c.DoesNotExist {
+			h.Set("X-Goog-If-Generation-Match", strconv.FormatInt(c.GenerationMatch, 10))
+			isIdempotent = true
+		}
+		if c.MetagenerationMatch != 0 {
+			h.Set("X-Goog-If-Metageneration-Match", strconv.FormatInt(c.MetagenerationMatch, 10))
+		}
+	}
+	var gen int64
+	err = xmlCall(ctx, m.o, "CompleteMultipartUpload", http.MethodPost, url.Values{"uploadId": {m.uploadID}}, h, body, isIdempotent, func(r *http.Response) error {
+		gen, _ = strconv.ParseInt(r.Header.Get("X-Goog-Generation"), 10, 64)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := *m.o
+	o.conds = nil
+	if gen != 0 {
+		o.gen = gen
+	}
+	return o.Attrs(ctx)
+}
+
+// Abort aborts the upload and discards its parts.
+func (m *MultipartUpload) Abort(ctx context.Context) (err error) {
+	ctx, _ = startSpanWithBucket(ctx, m.o.c, m.o.bucket, "MultipartUpload.Abort")
+	defer func() { endSpan(ctx, err) }()
+
+	return xmlCall(ctx, m.o, "AbortMultipartUpload", http.MethodDelete, url.Values{"uploadId": {m.uploadID}}, nil, nil, true, nil)
+}
+
+// MultipartUploadAttrs describes an incomplete multipart upload.
+type MultipartUploadAttrs struct {
+	// Name is the name of the object being uploaded.
+	Name string
+
+	// UploadID is the ID of the upload.
+	UploadID string
+
+	// StorageClass is the storage class of the object being uploaded.
+	StorageClass string
+
+	// Initiated is the time the upload was initiated.
+	Initiated time.Time
+}
+
+// MultipartUploads returns an iterator over the incomplete multipart uploads
+// of objects in the bucket whose names begin with prefix, in order of object
+// name. Use [ObjectHandle.MultipartUpload] or [BucketHandle.AbortMultipartUpload]
+// to abort them.
+func (b *BucketHandle) MultipartUploads(ctx context.Context, prefix string) *MultipartUploadIterator {
+	it := &MultipartUploadIterator{}
+	fetch := func(pageSize int, pageToken string) (string, error) {
+		q := url.Values{"uploads": {""}}
+		if prefix != "" {
+			q.Set("prefix", prefix)
+		}
+		if pageSize > 0 {
+			q.Set("max-uploads", strconv.Itoa(pageSize))
+		}
+		if pageToken != "" {
+			key, id, _ := strings.Cut(pageToken, "\x00")
+			q.Set("key-marker", key)
+			q.Set("upload-id-marker", id)
+		}
+		var res struct {
+			NextKeyMarker      string
+			NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+			IsTruncated        bool
+			Uploads            []struct {
+				Key          string
+				UploadID     string `xml:"UploadId"`
+				StorageClass string
+				Initiated    string
+			} `xml:"Upload"`
+		}
+		// The bucket is addressed with an object handle without a name.
+		o := &ObjectHandle{c: b.c, bucket: b.name, userProject: b.userProject, retry: b.retry}
+		err := xmlCall(ctx, o, "ListMultipartUploads", http.MethodGet, q, nil, nil, true, func(r *http.Response) error {
+			return xml.NewDecoder(r.Body).Decode(&res)
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, u := range res.Uploads {
+			t, _ := time.Parse(time.RFC3339Nano, u.Initiated)
+			it.items = append(it.items, &MultipartUploadAttrs{Name: u.Key, UploadID: u.UploadID, StorageClass: u.StorageClass, Initiated: t})
+		}
+		if !res.IsTruncated {
+			return "", nil
+		}
+		return res.NextKeyMarker + "\x00" + res.NextUploadIDMarker, nil
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		fetch,
+		func() int { return len(it.items) },
+		func() any { b := it.items; it.items = nil; return b })
+	return it
+}
+
+// AbortMultipartUpload aborts the incomplete multipart upload of the named
+// object with the given upload ID.
+func (b *BucketHandle) AbortMultipartUpload(ctx context.Context, name, uploadID string) error {
+	return b.Object(name).MultipartUpload(uploadID).Abort(ctx)
+}
+
+// MultipartUploadIterator is an iterator over incomplete multipart uploads.
+type MultipartUploadIterator struct {
+	pageInfo *iterator.PageInfo
+	nextFunc func() error
+	items    []*MultipartUploadAttrs
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator package for details.
+func (it *MultipartUploadIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next result. Its second return value is iterator.Done if
+// there are no more results. Once Next returns iterator.Done, all subsequent
+// calls will return iterator.Done.
+func (it *MultipartUploadIterator) Next() (*MultipartUploadAttrs, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return item, nil
+}
+
+// xmlCall sends an XML API request for the object of o, or for its bucket if
+// o has no object name, with the retry configuration of o. If the request
+// succeeds, handle, if not nil, is called with the response.
+func xmlCall(ctx context.Context, o *ObjectHandle, op, method string, query url.Values, header http.Header, body []byte, isIdempotent bool, handle func(*http.Response) error) error {
+	hc, ok := o.c.tc.(*httpStorageClient)
+	if !ok {
+		return fmt.Errorf("storage: %s requires a client that uses the JSON API: %w", op, errMethodNotSupported)
+	}
+	s := callSettings(hc.settings, makeStorageOpts(isIdempotent, o.retry, o.userProject)...)
+	path := "/" + o.bucket
+	rawPath := path
+	if o.object != "" {
+		path += "/" + o.object
+		rawPath += "/" + url.PathEscape(o.object)
+	}
+	u := &url.URL{
+		Scheme:   hc.scheme,
+		Host:     hc.xmlHost,
+		Path:     path,
+		RawPath:  rawPath,
+		RawQuery: query.Encode(),
+	}
+	return run(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if s.userProject != "" {
+			req.Header.Set("X-Goog-User-Project", s.userProject)
+		}
+		setHeadersFromCtx(ctx, req.Header)
+		res, err := hc.hc.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if err := checkXMLResponse(res); err != nil {
+			return err
+		}
+		if handle == nil {
+			return nil
+		}
+		return handle(res)
+	}, s.retry, s.idempotent, withOperation(op), withBucket(o.bucket), withObject(o.object))
+}
+
+// checkXMLResponse returns a *googleapi.Error for an unsuccessful XML API
+// response, whose body is an XML error document.
+func checkXMLResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	var xe struct {
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal(body, &xe) != nil {
+		// The body may be a JSON API error instead.
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return googleapi.CheckResponse(res)
+	}
+	return &googleapi.Error{
+		Code:    res.StatusCode,
+		Message: strings.TrimSpace(xe.Code + ": " + xe.Message),
+		Header:  res.Header,
+		Body:    string(body),
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+func TestMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	bkt := clients["http"].Bucket("bucket")
+
+	data := make([]byte, 3*storage.MinMultipartPartSize+1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	u := bkt.Object("big").If(storage.Conditions{DoesNotExist: true}).MultipartUploader()
+	u.PartSize = storage.MinMultipartPartSize
+	u.Concurrency = 2
+	u.ContentType = "application/x-test"
+	u.Metadata = map[string]string{"k": "v"}
+	attrs, err := u.Upload(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if attrs.Size != int64(len(data)) || attrs.ContentType != "application/x-test" || attrs.Metadata["k"] != "v" {
+		t.Errorf("got attrs %+v", attrs)
+	}
+	if want := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)); attrs.CRC32C != want || len(attrs.MD5) != 0 {
+		t.Errorf("got CRC32C %d and MD5 %x, want CRC32C %d and no MD5", attrs.CRC32C, attrs.MD5, want)
+	}
+	r, err := bkt.Object("big").NewReader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read back %d bytes, %v; want the uploaded content", len(got), err)
+	}
+
+	// The object now exists, so the condition fails and the upload is
+	// aborted.
+	if _, err := u.Upload(ctx, bytes.NewReader(data[:10])); err == nil {
+		t.Error("Upload of an existing object with DoesNotExist: got nil, want error")
+	}
+	if ups := listMultipartUploads(t, bkt, ""); len(ups) != 0 {
+		t.Errorf("got %d incomplete uploads after a failed upload, want none", len(ups))
+	}
+
+	// An empty object.
+	attrs, err = bkt.Object("empty").MultipartUploader().Upload(ctx, bytes.NewReader(nil))
+	if err != nil || attrs.Size != 0 {
+		t.Errorf("empty upload: got %+v, %v", attrs, err)
+	}
+
+	// A failing reader aborts the upload.
+	failing := io.MultiReader(bytes.NewReader(data[:storage.MinMultipartPartSize+1]), &errReader{errors.New("read failed")})
+	u = bkt.Object("failed").MultipartUploader()
+	u.PartSize = storage.MinMultipartPartSize
+	if _, err := u.Upload(ctx, failing); err == nil || err.Error() != "read failed" {
+		t.Errorf("Upload with a failing reader: got %v, want the read error", err)
+	}
+	if ups := listMultipartUploads(t, bkt, ""); len(ups) != 0 {
+		t.Errorf("got %d incomplete uploads after a failed upload, want none", len(ups))
+	}
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func listMultipartUploads(t *testing.T, bkt *storage.BucketHandle, prefix string) []*storage.MultipartUploadAttrs {
+	t.Helper()
+	var ups []*storage.MultipartUploadAttrs
+	it := bkt.MultipartUploads(context.Background(), prefix)
+	it.PageInfo().MaxSize = 2
+	for {
+		u, err := it.Next()
+		if err == iterator.Done {
+			return ups
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ups = append(ups, u)
+	}
+}
+
+func TestMultipartUploadParts(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	bkt := clients["http"].Bucket("bucket")
+
+	var ids []string
+	for _, name := range []string{"a/1", "a/2", "a/3", "b"} {
+		m, err := bkt.Object(name).MultipartUploader().Initiate(ctx)
+		if err != nil {
+			t.Fatalf("Initiate: %v", err)
+		}
+		ids = append(ids, m.UploadID())
+	}
+	ups := listMultipartUploads(t, bkt, "a/")
+	if len(ups) != 3 || ups[0].Name != "a/1" || ups[2].UploadID != ids[2] || ups[0].Initiated.IsZero() || ups[0].StorageClass == "" {
+		t.Fatalf("got uploads %+v", ups)
+	}
+	if err := bkt.AbortMultipartUpload(ctx, "b", ids[3]); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if err := bkt.AbortMultipartUpload(ctx, "b", ids[3]); err == nil {
+		t.Error("aborting twice: got nil, want error")
+	}
+
+	// Continue the upload of a/1 with a new handle, uploading the parts out
+	// of order and replacing one.
+	m := bkt.Object("a/1").MultipartUpload(ids[0])
+	part1 := bytes.Repeat([]byte("1"), storage.MinMultipartPartSize)
+	part2 := []byte("tail")
+	var parts []*storage.MultipartPart
+	for _, p := range []struct {
+		n    int
+		data []byte
+	}{{2, []byte("old")}, {2, part2}, {1, part1}} {
+		part, err := m.UploadPart(ctx, p.n, p.data)
+		if err != nil {
+			t.Fatalf("UploadPart %d: %v", p.n, err)
+		}
+		parts = append(parts, part)
+	}
+	listed, err := m.ListParts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].PartNumber != 1 || listed[1].Size != 4 || listed[1].ETag != parts[1].ETag || listed[0].LastModified.IsZero() {
+		t.Errorf("got parts %+v", listed)
+	}
+	// The stale part 2 is rejected.
+	if _, err := m.Complete(ctx, parts[:1]); err == nil {
+		t.Error("Complete with a stale part: got nil, want error")
+	}
+	attrs, err := m.Complete(ctx, parts[1:])
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if attrs.Size != int64(len(part1)+len(part2)) {
+		t.Errorf("got size %d", attrs.Size)
+	}
+	if ups := listMultipartUploads(t, bkt, ""); len(ups) != 2 {
+		t.Errorf("got %d incomplete uploads, want 2", len(ups))
+	}
+
+	// A part smaller than the minimum size must be the last one.
+	m = bkt.Object("a/2").MultipartUpload(ids[1])
+	small1, err := m.UploadPart(ctx, 1, []byte("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	small2, err := m.UploadPart(ctx, 2, []byte("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Complete(ctx, []*storage.MultipartPart{small2, small1}); err == nil {
+		t.Error("Complete with a small first part: got nil, want error")
+	}
+	if _, err := m.UploadPart(ctx, 0, nil); err == nil {
+		t.Error("UploadPart 0: got nil, want error")
+	}
+}
+
+func TestMultipartUploadGRPC(t *testing.T) {
+	_, clients := newFakeServerClients(t)
+	_, err := clients["grpc"].Bucket("bucket").Object("obj").MultipartUploader().Initiate(context.Background())
+	if err == nil {
+		t.Error("Initiate with a gRPC client: got nil, want error")
+	}
+}
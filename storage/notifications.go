@@ -16,9 +16,13 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	raw "google.golang.org/api/storage/v1"
 )
@@ -167,3 +171,125 @@ func (b *BucketHandle) DeleteNotification(ctx context.Context, id string) (err e
 	opts := makeStorageOpts(true, b.retry, b.userProject)
 	return b.c.tc.DeleteNotification(ctx, b.name, id, opts...)
 }
+
+// Attributes of the Cloud PubSub messages sent for notifications. See
+// https://cloud.google.com/storage/docs/pubsub-notifications#attributes.
+const (
+	notificationConfigAttr      = "notificationConfig"
+	eventTypeAttr               = "eventType"
+	payloadFormatAttr           = "payloadFormat"
+	bucketIDAttr                = "bucketId"
+	objectIDAttr                = "objectId"
+	objectGenerationAttr        = "objectGeneration"
+	eventTimeAttr               = "eventTime"
+	overwroteGenerationAttr     = "overwroteGeneration"
+	overwrittenByGenerationAttr = "overwrittenByGeneration"
+)
+
+// NotificationEvent is an object change described by a Cloud PubSub message
+// sent for a Notification.
+type NotificationEvent struct {
+	// EventType is the type of the event: ObjectFinalizeEvent,
+	// ObjectMetadataUpdateEvent, ObjectDeleteEvent or ObjectArchiveEvent.
+	EventType string
+
+	// NotificationID is the ID of the notification that sent the message.
+	NotificationID string
+
+	// PayloadFormat is the format of the message payload: JSONPayload or
+	// NoPayload.
+	PayloadFormat string
+
+	// Bucket and Object are the names of the bucket and object that changed.
+	Bucket string
+	Object string
+
+	// Generation is the generation of the object that changed. It is zero
+	// if the message does not report a generation.
+	Generation int64
+
+	// EventTime is the time the event occurred.
+	EventTime time.Time
+
+	// OverwroteGeneration is the generation of the object that a finalized
+	// object replaced, or zero if it did not replace an object.
+	OverwroteGeneration int64
+
+	// OverwrittenByGeneration is the generation of the object that replaced
+	// an archived or deleted object, or zero if it was not replaced.
+	OverwrittenByGeneration int64
+
+	// Attrs are the attributes of the object reported by a JSONPayload, or
+	// nil if the message has no payload.
+	Attrs *ObjectAttrs
+
+	// CustomAttributes are the custom attributes of the notification.
+	CustomAttributes map[string]string
+}
+
+// ParseNotificationEvent parses the attributes and data of a Cloud PubSub
+// message sent for a Notification.
+func ParseNotificationEvent(attrs map[string]string, data []byte) (*NotificationEvent, error) {
+	e := &NotificationEvent{
+		EventType:     attrs[eventTypeAttr],
+		PayloadFormat: attrs[payloadFormatAttr],
+		Bucket:        attrs[bucketIDAttr],
+		Object:        attrs[objectIDAttr],
+	}
+	if e.EventType == "" || e.Bucket == "" || e.Object == "" {
+		return nil, fmt.Errorf("storage: notification message lacks the %q, %q or %q attribute", eventTypeAttr, bucketIDAttr, objectIDAttr)
+	}
+	if c := attrs[notificationConfigAttr]; c != "" {
+		e.NotificationID = c[strings.LastIndex(c, "/")+1:]
+	}
+	var err error
+	for attr, p := range map[string]*int64{
+		objectGenerationAttr:        &e.Generation,
+		overwroteGenerationAttr:     &e.OverwroteGeneration,
+		overwrittenByGenerationAttr: &e.OverwrittenByGeneration,
+	} {
+		if v := attrs[attr]; v != "" {
+			if *p, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return nil, fmt.Errorf("storage: notification attribute %q: %w", attr, err)
+			}
+		}
+	}
+	if v := attrs[eventTimeAttr]; v != "" {
+		if e.EventTime, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("storage: notification attribute %q: %w", eventTimeAttr, err)
+		}
+	}
+	for k, v := range attrs {
+		switch k {
+		case notificationConfigAttr, eventTypeAttr, payloadFormatAttr, bucketIDAttr, objectIDAttr,
+			objectGenerationAttr, eventTimeAttr, overwroteGenerationAttr, overwrittenByGenerationAttr:
+		default:
+			if e.CustomAttributes == nil {
+				e.CustomAttributes = map[string]string{}
+			}
+			e.CustomAttributes[k] = v
+		}
+	}
+
+	if e.PayloadFormat == JSONPayload && len(data) > 0 {
+		var o raw.Object
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, fmt.Errorf("storage: notification payload: %w", err)
+		}
+		e.Attrs = newObject(&o)
+		if e.Generation == 0 {
+			e.Generation = e.Attrs.Generation
+		}
+	}
+	return e, nil
+}
+
+// ObjectHandle returns a handle for the generation of the object that the
+// event describes, using the client c.
+func (e *NotificationEvent) ObjectHandle(c *Client) *ObjectHandle {
+	o := c.Bucket(e.Bucket).Object(e.Object)
+	if e.Generation != 0 {
+		o = o.Generation(e.Generation)
+	}
+	return o
+}
@@ -17,6 +17,7 @@ package storage
 import (
 	"context"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/internal/testutil"
 	raw "google.golang.org/api/storage/v1"
@@ -96,3 +97,80 @@ func TestAddNotificationsErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestParseNotificationEvent(t *testing.T) {
+	attrs := map[string]string{
+		"notificationConfig":  "projects/_/buckets/my-bucket/notificationConfigs/7",
+		"eventType":           ObjectFinalizeEvent,
+		"payloadFormat":       JSONPayload,
+		"bucketId":            "my-bucket",
+		"objectId":            "dir/obj",
+		"objectGeneration":    "1700000000000002",
+		"eventTime":           "2026-03-01T10:00:00.5Z",
+		"overwroteGeneration": "1700000000000001",
+		"custom":              "value",
+	}
+	data := []byte(`{
+		"kind": "storage#object",
+		"bucket": "my-bucket",
+		"name": "dir/obj",
+		"generation": "1700000000000002",
+		"metageneration": "1",
+		"contentType": "text/plain",
+		"size": "11",
+		"md5Hash": "XrY7u+Ae7tCTyyK7j1rNww==",
+		"crc32c": "yZRlqg==",
+		"storageClass": "STANDARD",
+		"timeCreated": "2026-03-01T10:00:00.000Z",
+		"metadata": {"k": "v"}
+	}`)
+	e, err := ParseNotificationEvent(attrs, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.EventType != ObjectFinalizeEvent || e.NotificationID != "7" || e.Bucket != "my-bucket" || e.Object != "dir/obj" {
+		t.Errorf("got event %+v", e)
+	}
+	if e.Generation != 1700000000000002 || e.OverwroteGeneration != 1700000000000001 || e.OverwrittenByGeneration != 0 {
+		t.Errorf("got generations %d, %d, %d", e.Generation, e.OverwroteGeneration, e.OverwrittenByGeneration)
+	}
+	if want := time.Date(2026, 3, 1, 10, 0, 0, 5e8, time.UTC); !e.EventTime.Equal(want) {
+		t.Errorf("got event time %v, want %v", e.EventTime, want)
+	}
+	if len(e.CustomAttributes) != 1 || e.CustomAttributes["custom"] != "value" {
+		t.Errorf("got custom attributes %v", e.CustomAttributes)
+	}
+	a := e.Attrs
+	if a == nil || a.Name != "dir/obj" || a.Size != 11 || a.ContentType != "text/plain" || a.CRC32C != 0xc99465aa || a.Metadata["k"] != "v" || len(a.MD5) != 16 {
+		t.Errorf("got attrs %+v", a)
+	}
+
+	o := e.ObjectHandle(&Client{})
+	if o.BucketName() != "my-bucket" || o.ObjectName() != "dir/obj" || o.gen != 1700000000000002 {
+		t.Errorf("got handle for %s/%s#%d", o.BucketName(), o.ObjectName(), o.gen)
+	}
+
+	// Messages without a payload.
+	delete(attrs, "objectGeneration")
+	attrs["payloadFormat"] = NoPayload
+	attrs["eventType"] = ObjectDeleteEvent
+	if e, err = ParseNotificationEvent(attrs, nil); err != nil {
+		t.Fatal(err)
+	}
+	if e.Attrs != nil || e.Generation != 0 || e.ObjectHandle(&Client{}).gen != -1 {
+		t.Errorf("got event %+v without a payload", e)
+	}
+
+	for _, bad := range []map[string]string{
+		{"eventType": ObjectDeleteEvent, "bucketId": "b"},
+		{"eventType": ObjectDeleteEvent, "bucketId": "b", "objectId": "o", "objectGeneration": "x"},
+		{"eventType": ObjectDeleteEvent, "bucketId": "b", "objectId": "o", "eventTime": "yesterday"},
+	} {
+		if _, err := ParseNotificationEvent(bad, nil); err == nil {
+			t.Errorf("%v: got nil, want error", bad)
+		}
+	}
+	if _, err := ParseNotificationEvent(map[string]string{"eventType": ObjectDeleteEvent, "bucketId": "b", "objectId": "o", "payloadFormat": JSONPayload}, []byte("{")); err == nil {
+		t.Error("bad payload: got nil, want error")
+	}
+}
@@ -46,6 +46,12 @@ func init() {
 	storageinternal.WithDirectConnectivityEnforced = withDirectConnectivityEnforced
 	storageinternal.WithOtelMetrics = withOtelMetrics
 	storageinternal.WithOtelDebugMetrics = withOtelDebugMetrics
+
+	// used by the transfermanager package
+	storageinternal.IsGRPCClient = func(c *Client) bool {
+		_, ok := c.tc.(*grpcStorageClient)
+		return ok
+	}
 }
 
 // getDynamicReadReqIncreaseRateFromEnv returns the value set in the env variable.
@@ -0,0 +1,436 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	// readCacheBlockSize is the granularity at which object data is cached.
+	readCacheBlockSize = 1 << 20
+	// defaultReadCacheAttrsLimit is the number of object generations whose
+	// attributes are kept in a ReadCache.
+	defaultReadCacheAttrsLimit = 10000
+)
+
+// ReadCache is a local read-through cache of object data. Use
+// [ObjectHandle.ReadCache] to read objects through it.
+//
+// Data is cached in blocks keyed by bucket, object and generation. Since the
+// content of a generation never changes, cached data never needs to be
+// invalidated. When the cache holds more than its maximum size, the least
+// recently used blocks are evicted.
+//
+// A ReadCache is safe for concurrent use by multiple goroutines and may be
+// shared by handles of different clients.
+type ReadCache struct {
+	maxBytes int64
+	dir      string // the directory holding the blocks, or "" to keep them in memory
+
+	mu        sync.Mutex
+	blocks    *lruCache[readCacheKey, readCacheBlock]
+	attrs     *lruCache[readCacheObject, *ObjectAttrs]
+	size      int64
+	hitBytes  int64
+	missBytes int64
+	closed    bool
+}
+
+// ReadCacheStats describes the use of a ReadCache.
+type ReadCacheStats struct {
+	// Size is the number of bytes of object data currently in the cache.
+	Size int64
+
+	// HitBytes is the number of bytes read from the cache.
+	HitBytes int64
+
+	// MissBytes is the number of bytes read from Cloud Storage because they
+	// were not in the cache.
+	MissBytes int64
+}
+
+type readCacheObject struct {
+	bucket, object string
+	gen            int64
+}
+
+type readCacheKey struct {
+	readCacheObject
+	block int64
+}
+
+type readCacheBlock struct {
+	size int64
+	data []byte // nil for blocks stored on disk
+}
+
+// NewReadCache returns a ReadCache that keeps up to maxBytes bytes of object
+// data in memory.
+func NewReadCache(maxBytes int64) *ReadCache {
+	return newReadCache(maxBytes, "")
+}
+
+// NewDiskReadCache returns a ReadCache that keeps up to maxBytes bytes of
+// object data in files in a new directory created within dir. If dir is the
+// empty string, the default directory for temporary files is used.
+//
+// Call Close to remove the files when the cache is no longer needed.
+func NewDiskReadCache(dir string, maxBytes int64) (*ReadCache, error) {
+	d, err := os.MkdirTemp(dir, "storage-read-cache-")
+	if err != nil {
+		return nil, fmt.Errorf("storage: creating read cache directory: %w", err)
+	}
+	return newReadCache(maxBytes, d), nil
+}
+
+func newReadCache(maxBytes int64, dir string) *ReadCache {
+	c := &ReadCache{
+		maxBytes: maxBytes,
+		dir:      dir,
+		blocks:   newLRUCache[readCacheKey, readCacheBlock](math.MaxInt),
+		attrs:    newLRUCache[readCacheObject, *ObjectAttrs](defaultReadCacheAttrsLimit),
+	}
+	c.blocks.onEvict = func(k readCacheKey, b readCacheBlock) {
+		c.size -= b.size
+		if c.dir != "" {
+			os.Remove(c.blockPath(k))
+		}
+	}
+	return c
+}
+
+// Stats returns statistics about the use of the cache.
+func (c *ReadCache) Stats() ReadCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ReadCacheStats{Size: c.size, HitBytes: c.hitBytes, MissBytes: c.missBytes}
+}
+
+// Close empties the cache and removes the files of a cache created with
+// NewDiskReadCache. Handles can still read through a closed cache, but no
+// data is added to it.
+func (c *ReadCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.blocks.onEvict = nil
+	c.blocks = newLRUCache[readCacheKey, readCacheBlock](math.MaxInt)
+	c.attrs = newLRUCache[readCacheObject, *ObjectAttrs](defaultReadCacheAttrsLimit)
+	c.size = 0
+	if c.dir != "" {
+		return os.RemoveAll(c.dir)
+	}
+	return nil
+}
+
+func (c *ReadCache) blockPath(k readCacheKey) string {
+	h := sha256.Sum256([]byte(k.bucket + "\x00" + k.object + "\x00" + strconv.FormatInt(k.gen, 10) + "\x00" + strconv.FormatInt(k.block, 10)))
+	return filepath.Join(c.dir, hex.EncodeToString(h[:]))
+}
+
+func (c *ReadCache) getAttrs(obj readCacheObject) (*ObjectAttrs, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attrs.get(obj)
+}
+
+func (c *ReadCache) putAttrs(attrs *ObjectAttrs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.attrs.put(readCacheObject{attrs.Bucket, attrs.Name, attrs.Generation}, attrs)
+	}
+}
+
+// contains reports whether the block is cached.
+func (c *ReadCache) contains(k readCacheKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.blocks.entries[k]
+	return ok
+}
+
+// getBlock returns the data of a cached block.
+func (c *ReadCache) getBlock(k readCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	b, ok := c.blocks.get(k)
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data := b.data
+	if c.dir != "" {
+		var err error
+		data, err = os.ReadFile(c.blockPath(k))
+		if err != nil || int64(len(data)) != b.size {
+			// The file was removed by a concurrent eviction or Close.
+			c.mu.Lock()
+			c.blocks.evict(k)
+			c.mu.Unlock()
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// putBlock adds a block to the cache, evicting the least recently used blocks
+// to make room for it. Blocks that cannot be written to disk are not cached.
+func (c *ReadCache) putBlock(k readCacheKey, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+	if c.dir != "" {
+		// Write the block to a temporary file first so that a concurrent read
+		// never sees a partial block.
+		f, err := os.CreateTemp(c.dir, "tmp-")
+		if err != nil {
+			return
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), c.blockPath(k))
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return
+		}
+		data = nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if _, ok := c.blocks.entries[k]; ok {
+		return
+	}
+	c.blocks.put(k, readCacheBlock{size: size, data: data})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.blocks.removeOldest()
+	}
+}
+
+func (c *ReadCache) record(hit bool, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.hitBytes += n
+	} else {
+		c.missBytes += n
+	}
+}
+
+// ReadCache returns a new ObjectHandle whose readers read object data through
+// the given cache. Data that is not in the cache is read from Cloud Storage
+// and added to it.
+//
+// Reads of a handle that does not specify a generation make a metadata request
+// to look up the object's current generation before reading. Use
+// [ObjectHandle.Generation] to pin the generation and avoid the request.
+//
+// The cache is bypassed for handles with preconditions or a customer-supplied
+// encryption key, for handles that read soft-deleted objects or compressed
+// data, and for objects with a Content-Encoding of "gzip".
+//
+// The attributes of a Reader served from the cache are those of the object
+// when its generation was first read through the cache. In particular, its
+// Metageneration and metadata may be out of date.
+func (o *ObjectHandle) ReadCache(c *ReadCache) *ObjectHandle {
+	o2 := *o
+	o2.readCache = c
+	return &o2
+}
+
+// useReadCache reports whether the reads of o go through its ReadCache.
+func (o *ObjectHandle) useReadCache() bool {
+	return o.readCache != nil && o.conds == nil && o.encryptionKey == nil && !o.readCompressed && !o.softDeleted
+}
+
+// newCachedRangeReader returns a reader of the given range of o that serves
+// cached blocks from o.readCache and reads the other blocks from Cloud
+// Storage.
+func (o *ObjectHandle) newCachedRangeReader(ctx context.Context, offset, length int64, opts ...ReaderOption) (*Reader, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if offset < 0 && length >= 0 {
+		return nil, fmt.Errorf("storage: invalid offset %d < 0 requires negative length", offset)
+	}
+	c := o.readCache
+	uncached := *o
+	uncached.readCache = nil
+
+	attrs, ok := (*ObjectAttrs)(nil), false
+	if o.gen >= 0 {
+		attrs, ok = c.getAttrs(readCacheObject{o.bucket, o.object, o.gen})
+	}
+	if !ok {
+		var err error
+		if attrs, err = uncached.Attrs(ctx); err != nil {
+			return nil, err
+		}
+		c.putAttrs(attrs)
+	}
+	uncached.gen = attrs.Generation
+
+	size := attrs.Size
+	start := offset
+	if start < 0 {
+		start = max(0, size+offset)
+	}
+	if attrs.ContentEncoding == "gzip" || start > size {
+		// Let the service transcode the object or report the invalid range.
+		return uncached.NewRangeReader(ctx, offset, length, opts...)
+	}
+	end := size
+	if length >= 0 {
+		end = min(size, start+length)
+	}
+
+	metadata := attrs.Metadata
+	return &Reader{
+		Attrs: ReaderObjectAttrs{
+			Size:            size,
+			StartOffset:     start,
+			ContentType:     attrs.ContentType,
+			ContentEncoding: attrs.ContentEncoding,
+			CacheControl:    attrs.CacheControl,
+			LastModified:    attrs.Updated,
+			Generation:      attrs.Generation,
+			Metageneration:  attrs.Metageneration,
+			CRC32C:          attrs.CRC32C,
+		},
+		objectMetadata: &metadata,
+		size:           size,
+		remain:         end - start,
+		reader: &cachedReader{
+			ctx:  ctx,
+			c:    c,
+			o:    &uncached,
+			opts: opts,
+			obj:  readCacheObject{o.bucket, o.object, attrs.Generation},
+			size: size,
+			pos:  start,
+			end:  end,
+		},
+		ctx:    ctx,
+		bucket: o.bucket,
+		object: o.object,
+	}, nil
+}
+
+// cachedReader reads the range [pos, end) of an object generation block by
+// block. Consecutive blocks missing from the cache are read from Cloud
+// Storage with a single request and added to the cache.
+type cachedReader struct {
+	ctx  context.Context
+	c    *ReadCache
+	o    *ObjectHandle // the uncached handle, pinned to the generation
+	opts []ReaderOption
+	obj  readCacheObject
+	size int64
+
+	pos, end int64
+	buf      []byte // the unread part of the current block
+
+	net       *Reader // the reader of missing blocks, if any
+	netBlocks int64   // the number of blocks left to read from net
+}
+
+func (r *cachedReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.pos >= r.end {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.pos += int64(n)
+	return n, nil
+}
+
+// fill sets r.buf to the part of the block containing r.pos that is within
+// the range being read.
+func (r *cachedReader) fill() error {
+	block := r.pos / readCacheBlockSize
+	blockStart := block * readCacheBlockSize
+	k := readCacheKey{r.obj, block}
+	hit := false
+	var data []byte
+	if r.net == nil {
+		data, hit = r.c.getBlock(k)
+	}
+	if !hit {
+		if r.net == nil {
+			// Read this block and the missing blocks that follow it.
+			last, lastInRange := block, (r.end-1)/readCacheBlockSize
+			for last < lastInRange && !r.c.contains(readCacheKey{r.obj, last + 1}) {
+				last++
+			}
+			netEnd := min(r.size, (last+1)*readCacheBlockSize)
+			net, err := r.o.NewRangeReader(r.ctx, blockStart, netEnd-blockStart, r.opts...)
+			if err != nil {
+				return err
+			}
+			r.net, r.netBlocks = net, last-block+1
+		}
+		data = make([]byte, min(readCacheBlockSize, r.size-blockStart))
+		if _, err := io.ReadFull(r.net, data); err != nil {
+			return err
+		}
+		if r.netBlocks--; r.netBlocks == 0 {
+			err := r.net.Close()
+			r.net = nil
+			if err != nil {
+				return err
+			}
+		}
+		r.c.putBlock(k, data)
+	}
+	r.buf = data[r.pos-blockStart : min(int64(len(data)), r.end-blockStart)]
+	r.c.record(hit, int64(len(r.buf)))
+	return nil
+}
+
+func (r *cachedReader) Close() error {
+	if r.net == nil {
+		return nil
+	}
+	err := r.net.Close()
+	r.net = nil
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+const readCacheBlockSize = 1 << 20
+
+func readRange(ctx context.Context, t *testing.T, o *storage.ObjectHandle, offset, length int64) []byte {
+	t.Helper()
+	r, err := o.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		t.Fatalf("NewRangeReader(%d, %d): %v", offset, length, err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading range (%d, %d): %v", offset, length, err)
+	}
+	return got
+}
+
+func TestReadCache(t *testing.T) {
+	ctx := context.Background()
+	_, clients := newFakeServerClients(t)
+	for name, client := range clients {
+		for _, disk := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/disk=%t", name, disk), func(t *testing.T) {
+				cache := storage.NewReadCache(1 << 30)
+				if disk {
+					var err error
+					if cache, err = storage.NewDiskReadCache(t.TempDir(), 1<<30); err != nil {
+						t.Fatal(err)
+					}
+				}
+				defer cache.Close()
+
+				data := make([]byte, 2*readCacheBlockSize+1000)
+				rand.Read(data)
+				obj := client.Bucket("bucket").Object(fmt.Sprintf("%s-%t", name, disk))
+				w := obj.NewWriter(ctx)
+				w.Write(data)
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				gen := w.Attrs().Generation
+				cached := obj.Generation(gen).ReadCache(cache)
+
+				// Read part of the middle block, then the whole object, which
+				// reads only the missing blocks.
+				size := int64(len(data))
+				if got := readRange(ctx, t, cached, readCacheBlockSize+10, 100); !bytes.Equal(got, data[readCacheBlockSize+10:readCacheBlockSize+110]) {
+					t.Error("range of the middle block: wrong data")
+				}
+				if got := readRange(ctx, t, obj.ReadCache(cache), 0, -1); !bytes.Equal(got, data) {
+					t.Error("whole object: wrong data")
+				}
+				stats := cache.Stats()
+				if want := (storage.ReadCacheStats{Size: size, HitBytes: readCacheBlockSize, MissBytes: size + 100 - readCacheBlockSize}); stats != want {
+					t.Errorf("stats: got %+v, want %+v", stats, want)
+				}
+
+				// Reads of the generation are served from the cache after the
+				// object is deleted.
+				if err := obj.Delete(ctx); err != nil {
+					t.Fatal(err)
+				}
+				for _, r := range []struct{ offset, length, start, end int64 }{
+					{0, -1, 0, size},
+					{5, 10, 5, 15},
+					{readCacheBlockSize - 1, 2, readCacheBlockSize - 1, readCacheBlockSize + 1},
+					{-100, -1, size - 100, size},
+					{size, -1, size, size},
+				} {
+					if got := readRange(ctx, t, cached, r.offset, r.length); !bytes.Equal(got, data[r.start:r.end]) {
+						t.Errorf("range (%d, %d) after delete: wrong data", r.offset, r.length)
+					}
+				}
+				rd, err := cached.NewReader(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rd.Close()
+				if rd.Attrs.Generation != gen || rd.Attrs.Size != size {
+					t.Errorf("reader attrs: got generation %d and size %d, want %d and %d", rd.Attrs.Generation, rd.Attrs.Size, gen, size)
+				}
+
+				// Reads of the latest generation look it up.
+				if _, err := obj.ReadCache(cache).NewReader(ctx); !errors.Is(err, storage.ErrObjectNotExist) {
+					t.Errorf("reading the latest generation after delete: got %v, want ErrObjectNotExist", err)
+				}
+			})
+		}
+	}
+}
+
+func TestReadCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	srv, clients := newFakeServerClients(t)
+	dir := t.TempDir()
+	cache, err := storage.NewDiskReadCache(dir, 2*readCacheBlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bkt := clients["http"].Bucket("bucket")
+
+	data := make([]byte, 2*readCacheBlockSize)
+	rand.Read(data)
+	gen1 := srv.WriteObject("bucket", "one", data)
+	gen2 := srv.WriteObject("bucket", "two", data[:readCacheBlockSize])
+	one := bkt.Object("one").Generation(gen1).ReadCache(cache)
+	two := bkt.Object("two").Generation(gen2).ReadCache(cache)
+
+	readRange(ctx, t, one, 0, -1)
+	readRange(ctx, t, two, 0, -1)
+	if got := cache.Stats().Size; got != 2*readCacheBlockSize {
+		t.Errorf("size: got %d, want %d", got, 2*readCacheBlockSize)
+	}
+
+	// Reading "two" evicted the first block of "one", which is read again.
+	if err := bkt.Object("one").Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(ctx, t, one, readCacheBlockSize, -1); !bytes.Equal(got, data[readCacheBlockSize:]) {
+		t.Error("second block of one: wrong data")
+	}
+	if r, err := one.NewRangeReader(ctx, 0, 1); err == nil {
+		if _, err = io.ReadAll(r); err == nil {
+			t.Error("reading the evicted block of a deleted object: got nil, want error")
+		}
+		r.Close()
+	}
+
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Close left %d entries in the cache directory", len(entries))
+	}
+}
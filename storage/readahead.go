@@ -0,0 +1,285 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	defaultReadaheadChunkSize = 8 << 20
+	defaultReadaheadWindow    = 8
+)
+
+// ReadaheadOption is an option for NewReadaheadReader.
+type ReadaheadOption interface {
+	applyReadahead(*readaheadConfig)
+}
+
+type readaheadConfig struct {
+	chunkSize int64
+	window    int
+	mrdOpts   []MRDOption
+}
+
+type readaheadChunkSize int64
+
+func (s readaheadChunkSize) applyReadahead(c *readaheadConfig) { c.chunkSize = int64(s) }
+
+// WithReadaheadChunkSize returns a ReadaheadOption that sets the size of each
+// range requested by a ReadaheadReader. The default is 8 MiB.
+func WithReadaheadChunkSize(size int64) ReadaheadOption {
+	return readaheadChunkSize(size)
+}
+
+type readaheadWindow int
+
+func (w readaheadWindow) applyReadahead(c *readaheadConfig) { c.window = int(w) }
+
+// WithReadaheadWindow returns a ReadaheadOption that sets the number of ranges
+// that a ReadaheadReader requests ahead of the data being read. The default
+// is 8. A ReadaheadReader buffers up to window times the chunk size.
+func WithReadaheadWindow(window int) ReadaheadOption {
+	return readaheadWindow(window)
+}
+
+type readaheadMRDOptions []MRDOption
+
+func (o readaheadMRDOptions) applyReadahead(c *readaheadConfig) {
+	c.mrdOpts = append(c.mrdOpts, o...)
+}
+
+// WithReadaheadMRDOptions returns a ReadaheadOption that sets options of the
+// MultiRangeDownloader used by a ReadaheadReader, such as
+// [WithMaxConnections].
+func WithReadaheadMRDOptions(opts ...MRDOption) ReadaheadOption {
+	return readaheadMRDOptions(opts)
+}
+
+// ReadaheadReader reads a range of an object sequentially while prefetching
+// the ranges that follow in parallel through a [MultiRangeDownloader], so that
+// sequential reads of large objects are not limited by the throughput of a
+// single stream.
+//
+// The data of each range is validated against the CRC32C checksums sent by
+// the service, unless disabled with [WithDisableMRDReadChecksum]. When the
+// whole object is read, its CRC32C checksum is validated as well.
+type ReadaheadReader struct {
+	// Attrs are the attributes of the object. StartOffset is the offset of
+	// the first byte read.
+	Attrs ReaderObjectAttrs
+
+	ctx       context.Context
+	mrd       *MultiRangeDownloader
+	chunkSize int64
+	window    int
+
+	pos    int64 // offset of the next byte to read
+	next   int64 // offset of the next chunk to request
+	end    int64 // offset of the end of the read
+	chunks []*readaheadChunk
+	free   []*bytes.Buffer
+	err    error
+
+	crc      uint32
+	wantCRC  uint32
+	checkCRC bool
+}
+
+// readaheadChunk is a range of the object being downloaded.
+type readaheadChunk struct {
+	buf    *bytes.Buffer
+	length int64
+	done   chan struct{}
+	n      int64
+	err    error
+}
+
+// NewReadaheadReader creates a ReadaheadReader that reads length bytes of the
+// object starting at offset. As with NewRangeReader, a negative offset is
+// the number of bytes from the end of the object to read, and a negative
+// length reads to the end of the object.
+//
+// Like [ObjectHandle.NewMultiRangeDownloader], it must be called on a gRPC
+// client created using [NewGRPCClient]. The caller must call Close on the
+// returned reader when done reading.
+func (o *ObjectHandle) NewReadaheadReader(ctx context.Context, offset, length int64, opts ...ReadaheadOption) (*ReadaheadReader, error) {
+	cfg := readaheadConfig{chunkSize: defaultReadaheadChunkSize, window: defaultReadaheadWindow}
+	for _, opt := range opts {
+		opt.applyReadahead(&cfg)
+	}
+	if cfg.chunkSize <= 0 || cfg.window <= 0 {
+		return nil, fmt.Errorf("storage: invalid readahead chunk size %d or window %d", cfg.chunkSize, cfg.window)
+	}
+	mrd, err := o.NewMultiRangeDownloader(ctx, cfg.mrdOpts...)
+	if err != nil {
+		return nil, err
+	}
+	size := mrd.Attrs.Size
+	if offset < 0 {
+		offset = max(size+offset, 0)
+	}
+	if offset > size {
+		mrd.Close()
+		return nil, fmt.Errorf("storage: readahead offset %d is beyond the end of the object of %d bytes", offset, size)
+	}
+	end := size
+	if length >= 0 {
+		end = min(offset+length, size)
+	}
+	var params newMultiRangeDownloaderParams
+	for _, opt := range cfg.mrdOpts {
+		opt.apply(&params)
+	}
+
+	r := &ReadaheadReader{
+		Attrs:     mrd.Attrs,
+		ctx:       ctx,
+		mrd:       mrd,
+		chunkSize: cfg.chunkSize,
+		window:    cfg.window,
+		pos:       offset,
+		next:      offset,
+		end:       end,
+		wantCRC:   mrd.Attrs.CRC32C,
+		checkCRC:  offset == 0 && end == size && !params.disableMRDReadChecksum && !mrd.Attrs.Decompressed,
+	}
+	r.Attrs.StartOffset = offset
+	r.fill()
+	return r, nil
+}
+
+// fill requests chunks until the window is full or the end of the read is
+// reached.
+func (r *ReadaheadReader) fill() {
+	for len(r.chunks) < r.window && r.next < r.end {
+		c := &readaheadChunk{
+			length: min(r.chunkSize, r.end-r.next),
+			done:   make(chan struct{}),
+		}
+		if n := len(r.free); n > 0 {
+			c.buf = r.free[n-1]
+			r.free = r.free[:n-1]
+		} else {
+			c.buf = bytes.NewBuffer(make([]byte, 0, c.length))
+		}
+		r.mrd.Add(c.buf, r.next, c.length, func(_, n int64, err error) {
+			c.n, c.err = n, err
+			close(c.done)
+		})
+		r.chunks = append(r.chunks, c)
+		r.next += c.length
+	}
+}
+
+// head returns the chunk at the current offset once it has been downloaded,
+// or nil at the end of the read.
+func (r *ReadaheadReader) head() (*readaheadChunk, error) {
+	for {
+		if r.err != nil {
+			return nil, r.err
+		}
+		if len(r.chunks) == 0 {
+			if r.checkCRC && r.crc != r.wantCRC {
+				r.err = fmt.Errorf("storage: bad CRC on read: got %d, want %d", r.crc, r.wantCRC)
+				return nil, r.err
+			}
+			return nil, nil
+		}
+		c := r.chunks[0]
+		select {
+		case <-c.done:
+		case <-r.ctx.Done():
+			r.err = r.ctx.Err()
+			return nil, r.err
+		}
+		if c.err == nil && c.n != c.length {
+			c.err = io.ErrUnexpectedEOF
+		}
+		if c.err != nil {
+			r.err = c.err
+			return nil, r.err
+		}
+		if c.buf.Len() > 0 {
+			return c, nil
+		}
+		// The chunk has been read entirely; request the next one.
+		c.buf.Reset()
+		r.free = append(r.free, c.buf)
+		r.chunks = r.chunks[1:]
+		r.fill()
+	}
+}
+
+// Read implements io.Reader.
+func (r *ReadaheadReader) Read(p []byte) (int, error) {
+	c, err := r.head()
+	if err != nil {
+		return 0, err
+	}
+	if c == nil {
+		return 0, io.EOF
+	}
+	n, _ := c.buf.Read(p)
+	r.pos += int64(n)
+	if r.checkCRC {
+		r.crc = crc32.Update(r.crc, crc32cTable, p[:n])
+	}
+	return n, nil
+}
+
+// WriteTo implements io.WriterTo.
+func (r *ReadaheadReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		c, err := r.head()
+		if err != nil {
+			return total, err
+		}
+		if c == nil {
+			return total, nil
+		}
+		b := c.buf.Bytes()
+		n, err := w.Write(b)
+		if r.checkCRC {
+			r.crc = crc32.Update(r.crc, crc32cTable, b[:n])
+		}
+		c.buf.Next(n)
+		r.pos += int64(n)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Remain returns the number of bytes left to read.
+func (r *ReadaheadReader) Remain() int64 {
+	return r.end - r.pos
+}
+
+// Close closes the reader and the MultiRangeDownloader it uses. Ranges that
+// are still being downloaded are canceled.
+func (r *ReadaheadReader) Close() error {
+	if r.err == nil {
+		r.err = errors.New("storage: ReadaheadReader is closed")
+	}
+	return r.mrd.Close()
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/experimental"
+)
+
+func TestReadaheadReader(t *testing.T) {
+	ctx := context.Background()
+	srv, clients := newFakeServerClients(t)
+	data := make([]byte, 1<<20+123)
+	rand.New(rand.NewSource(1)).Read(data)
+	srv.WriteObject("bucket", "obj", data)
+	client, err := srv.NewGRPCClient(ctx, experimental.WithGRPCBidiReads())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	obj := client.Bucket("bucket").Object("obj")
+	opts := []storage.ReadaheadOption{
+		storage.WithReadaheadChunkSize(64 << 10),
+		storage.WithReadaheadWindow(4),
+		storage.WithReadaheadMRDOptions(storage.WithMaxConnections(2)),
+	}
+
+	for _, test := range []struct {
+		desc           string
+		offset, length int64
+		want           []byte
+	}{
+		{"whole object", 0, -1, data},
+		{"range", 1000, 200000, data[1000:201000]},
+		{"suffix", -5000, -1, data[len(data)-5000:]},
+		{"past the end", int64(len(data)) - 10, 100, data[len(data)-10:]},
+		{"empty", int64(len(data)), -1, nil},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			// Read in small pieces.
+			r, err := obj.NewReadaheadReader(ctx, test.offset, test.length, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Remain() != int64(len(test.want)) {
+				t.Errorf("Remain: got %d, want %d", r.Remain(), len(test.want))
+			}
+			var got []byte
+			buf := make([]byte, 1000)
+			for {
+				n, err := r.Read(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			if !bytes.Equal(got, test.want) || r.Remain() != 0 {
+				t.Errorf("Read: got %d bytes with %d remaining, want %d bytes", len(got), r.Remain(), len(test.want))
+			}
+
+			// Read with WriteTo.
+			r, err = obj.NewReadaheadReader(ctx, test.offset, test.length, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w bytes.Buffer
+			if _, err := io.Copy(&w, r); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			r.Close()
+			if !bytes.Equal(w.Bytes(), test.want) {
+				t.Errorf("WriteTo: got %d bytes, want %d", w.Len(), len(test.want))
+			}
+		})
+	}
+
+	// Closing before the end cancels the pending ranges.
+	r, err := obj.NewReadaheadReader(ctx, 0, -1, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if _, err := r.Read(make([]byte, 100)); err == nil {
+		t.Error("Read after Close: got nil, want error")
+	}
+
+	if _, err := obj.NewReadaheadReader(ctx, int64(len(data))+1, -1); err == nil {
+		t.Error("offset past the end: got nil, want error")
+	}
+	if _, err := clients["http"].Bucket("bucket").Object("obj").NewReadaheadReader(ctx, 0, -1); err == nil {
+		t.Error("HTTP client: got nil, want error")
+	}
+}
@@ -117,6 +117,16 @@ func (o *ObjectHandle) NewReader(ctx context.Context, opts ...ReaderOption) (*Re
 // operations, which all use JSON. JSON will become the default in a future
 // release.
 func (o *ObjectHandle) NewRangeReader(ctx context.Context, offset, length int64, opts ...ReaderOption) (r *Reader, err error) {
+	if o.keyWrapper != nil {
+		return o.newEnvelopeRangeReader(ctx, offset, length, opts...)
+	}
+	if o.readGzipMembers {
+		return o.newGzipRangeReader(ctx, offset, length, opts...)
+	}
+	if o.useReadCache() {
+		return o.newCachedRangeReader(ctx, offset, length, opts...)
+	}
+
 	// This span covers the life of the reader. It is closed via the context
 	// in Reader.Close.
 	ctx, _ = startSpanWithBucket(ctx, o.c, o.bucket, "Object.Reader")
@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// RandomAccessReader reads an object at arbitrary offsets. It implements
+// [io.Reader], [io.ReaderAt], [io.Seeker] and [io.Closer], so it can be used
+// with packages such as archive/zip or net/http that require random access
+// to their input.
+//
+// A RandomAccessReader reads a single generation of the object, which is
+// determined when it is created. Objects with a "gzip" Content-Encoding are
+// read as stored, without decompressive transcoding, so that offsets are
+// consistent with the object size; see [ObjectHandle.ReadCompressed].
+//
+// ReadAt may be called concurrently; each call is served by its own range
+// read. Read and Seek share an offset and must not be called concurrently.
+type RandomAccessReader struct {
+	ctx   context.Context
+	o     *ObjectHandle
+	attrs *ObjectAttrs
+
+	mu     sync.Mutex
+	offset int64   // offset of the next Read
+	r      *Reader // range reader positioned at offset, if any
+	closed bool
+}
+
+// NewRandomAccessReader creates a new RandomAccessReader for the object. It
+// fetches the object's attributes to determine its size and generation; ctx
+// is used for that call and for all subsequent reads.
+//
+// If the ObjectHandle does not specify a generation, the generation that is
+// live when NewRandomAccessReader is called is used for all reads.
+//
+// The caller must call Close on the returned RandomAccessReader when done
+// reading.
+func (o *ObjectHandle) NewRandomAccessReader(ctx context.Context) (*RandomAccessReader, error) {
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newRandomAccessReader(ctx, o, attrs), nil
+}
+
+// newRandomAccessReader creates a RandomAccessReader for the generation of the
+// object described by attrs.
+func newRandomAccessReader(ctx context.Context, o *ObjectHandle, attrs *ObjectAttrs) *RandomAccessReader {
+	return &RandomAccessReader{
+		ctx:   ctx,
+		o:     storedObject(o, attrs),
+		attrs: attrs,
+	}
+}
+
+// storedObject returns a handle that reads the generation of the object
+// described by attrs as stored, so that the bytes read match attrs.Size.
+func storedObject(o *ObjectHandle, attrs *ObjectAttrs) *ObjectHandle {
+	return o.Generation(attrs.Generation).ReadCompressed(attrs.ContentEncoding == "gzip")
+}
+
+// Attrs returns the attributes of the object being read.
+func (r *RandomAccessReader) Attrs() *ObjectAttrs {
+	return r.attrs
+}
+
+// Size returns the size of the object in bytes.
+func (r *RandomAccessReader) Size() int64 {
+	return r.attrs.Size
+}
+
+// ReadAt reads len(p) bytes of the object starting at offset off. It
+// implements [io.ReaderAt]. It returns io.EOF if fewer than len(p) bytes are
+// available.
+func (r *RandomAccessReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("storage: RandomAccessReader.ReadAt: negative offset")
+	}
+	if off >= r.attrs.Size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	length := min(int64(len(p)), r.attrs.Size-off)
+	rr, err := r.o.NewRangeReader(r.ctx, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer rr.Close()
+
+	n, err := io.ReadFull(rr, p[:length])
+	if err != nil {
+		return n, err
+	}
+	if int64(n) < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read reads from the current offset. It implements [io.Reader]. Sequential
+// calls to Read are served by a single range read from the current offset to
+// the end of the object, which is reopened after a Seek.
+func (r *RandomAccessReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, errors.New("storage: RandomAccessReader used after Close")
+	}
+	if r.offset >= r.attrs.Size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.r == nil {
+		rr, err := r.o.NewRangeReader(r.ctx, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.r = rr
+	}
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.attrs.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read. It implements [io.Seeker]. Seeking
+// does not make any calls to the service.
+func (r *RandomAccessReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.attrs.Size + offset
+	default:
+		return 0, fmt.Errorf("storage: RandomAccessReader.Seek: invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, errors.New("storage: RandomAccessReader.Seek: negative position")
+	}
+	if abs != r.offset && r.r != nil {
+		r.r.Close()
+		r.r = nil
+	}
+	r.offset = abs
+	return abs, nil
+}
+
+// Close closes the RandomAccessReader, releasing any open range read.
+func (r *RandomAccessReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.r != nil {
+		err := r.r.Close()
+		r.r = nil
+		return err
+	}
+	return nil
+}
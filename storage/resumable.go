@@ -0,0 +1,295 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ResumableSession is the state of a resumable upload. It is reported through
+// [Writer.SessionFunc] while an upload is in progress, and can be serialized
+// (for example with encoding/json) and passed to
+// [ObjectHandle.NewWriterFromSession] to finish the upload with a new Writer,
+// even in another process.
+//
+// The object's attributes and preconditions are fixed when the session is
+// created and are not part of the ResumableSession.
+type ResumableSession struct {
+	// Bucket is the name of the bucket the object is uploaded to.
+	Bucket string `json:"bucket"`
+
+	// Object is the name of the object being uploaded.
+	Object string `json:"object"`
+
+	// URI is the session URI of an upload made with the JSON API. It is empty
+	// for uploads made with gRPC.
+	//
+	// The session URI authorizes uploads to the session without further
+	// authentication, so it should be stored securely.
+	URI string `json:"uri,omitempty"`
+
+	// UploadID is the upload ID of an upload made with gRPC. It is empty for
+	// uploads made with the JSON API.
+	UploadID string `json:"uploadId,omitempty"`
+
+	// PersistedSize is the number of bytes of the object that the service had
+	// persisted when the session was reported.
+	PersistedSize int64 `json:"persistedSize"`
+
+	// CRC32C is the CRC32C checksum of the first PersistedSize bytes of the
+	// object. It lets a resumed Writer continue the automatic full-object
+	// checksum. It is only meaningful if HasCRC32C is true.
+	CRC32C uint32 `json:"crc32c,omitempty"`
+
+	// HasCRC32C reports whether CRC32C is set. It is false if automatic
+	// checksums are disabled for the upload, or if the service persisted a
+	// prefix of the object for which no checksum was recorded.
+	HasCRC32C bool `json:"hasCrc32c,omitempty"`
+}
+
+// ResumableWriterOpts provides options to set on a Writer initialized by
+// [ObjectHandle.NewWriterFromSession]. Writer options must be set via this
+// struct rather than being modified on the returned Writer.
+type ResumableWriterOpts struct {
+	// ChunkSize: See Writer.ChunkSize. It must not be zero, since a resumed
+	// upload is always made in chunks; the default is 16MiB.
+	ChunkSize int
+	// ChunkRetryDeadline: See Writer.ChunkRetryDeadline.
+	ChunkRetryDeadline time.Duration
+	// ChunkTransferTimeout: See Writer.ChunkTransferTimeout.
+	ChunkTransferTimeout time.Duration
+	// DisableAutoChecksum: See Writer.DisableAutoChecksum.
+	DisableAutoChecksum bool
+	// ProgressFunc: See Writer.ProgressFunc.
+	ProgressFunc func(int64)
+	// SessionFunc: See Writer.SessionFunc.
+	SessionFunc func(*ResumableSession)
+}
+
+func (opts *ResumableWriterOpts) apply(w *Writer) {
+	if opts == nil {
+		return
+	}
+	w.ChunkSize = opts.ChunkSize
+	w.ChunkRetryDeadline = opts.ChunkRetryDeadline
+	w.ChunkTransferTimeout = opts.ChunkTransferTimeout
+	w.DisableAutoChecksum = opts.DisableAutoChecksum
+	w.ProgressFunc = opts.ProgressFunc
+	w.SessionFunc = opts.SessionFunc
+}
+
+// NewWriterFromSession opens a new Writer that continues the resumable upload
+// described by session, which was reported by [Writer.SessionFunc]. It queries
+// the service for the number of bytes of the object that have been persisted,
+// and returns the Writer along with that offset. The caller must write the
+// rest of the object's content, starting at the returned offset, and then
+// call Close to finalize the object.
+//
+// If the upload has already been completed, the returned Writer is closed,
+// the offset is the size of the object, and the Writer's Attrs method returns
+// the attributes of the object that was written.
+//
+// The session must have been created for this object by a client using the
+// same API (JSON or gRPC) as the client of this ObjectHandle, and with the
+// same customer-supplied encryption key, if any. Writer fields such as
+// ChunkSize can be set only by setting the equivalent field in opts;
+// attributes set on the returned Writer are not honored, since they were
+// fixed when the session was created.
+//
+// Sessions expire one week after they are created.
+func (o *ObjectHandle) NewWriterFromSession(ctx context.Context, session *ResumableSession, opts *ResumableWriterOpts) (*Writer, int64, error) {
+	ctx, _ = startSpanWithBucket(ctx, o.c, o.bucket, "Object.WriterFromSession")
+	if err := o.validate(); err != nil {
+		return nil, 0, err
+	}
+	if o.keyWrapper != nil {
+		return nil, 0, errors.New("storage: NewWriterFromSession: envelope encryption cannot be used with resumable sessions")
+	}
+	if session == nil {
+		return nil, 0, errors.New("storage: NewWriterFromSession: session is nil")
+	}
+	if session.Bucket != o.bucket || session.Object != o.object {
+		return nil, 0, fmt.Errorf("storage: NewWriterFromSession: session is for object %q in bucket %q", session.Object, session.Bucket)
+	}
+	if (session.URI == "") == (session.UploadID == "") {
+		return nil, 0, errors.New("storage: NewWriterFromSession: exactly one of session URI and upload ID must be set")
+	}
+
+	w := &Writer{
+		ctx:         ctx,
+		o:           o,
+		donec:       make(chan struct{}),
+		ObjectAttrs: ObjectAttrs{Name: o.object},
+		ChunkSize:   googleapi.DefaultUploadChunkSize,
+	}
+	opts.apply(w)
+	if w.ChunkSize == 0 {
+		w.ChunkSize = googleapi.DefaultUploadChunkSize
+	}
+
+	s := *session
+	persisted, obj, err := o.c.tc.QueryWriteStatus(ctx, &s, o.encryptionKey, makeStorageOpts(true, o.retry, o.userProject)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if obj != nil {
+		w.obj = obj
+		w.markClosed(nil)
+		return w, obj.Size, nil
+	}
+	if persisted != s.PersistedSize {
+		// The checksum of the persisted prefix is unknown.
+		s.PersistedSize = persisted
+		s.CRC32C = 0
+		s.HasCRC32C = false
+	}
+	w.session = &s
+	return w, persisted, nil
+}
+
+// uploadChecksums records the CRC32C checksum of the data written to a
+// resumable upload at each chunk boundary, so that the checksum of the
+// persisted prefix of the object is known when the service reports how much
+// of it has been persisted. Boundaries are multiples of the chunk size from
+// the offset at which the Writer started.
+type uploadChecksums struct {
+	mu        sync.Mutex
+	chunkSize int64
+	written   int64  // offset of the end of the data written so far
+	crc       uint32 // checksum of the data written so far
+	next      int64  // offset of the next chunk boundary
+	points    []crcPoint
+}
+
+type crcPoint struct {
+	offset int64
+	crc    uint32
+}
+
+func newUploadChecksums(offset int64, crc uint32, chunkSize int) *uploadChecksums {
+	return &uploadChecksums{
+		chunkSize: int64(chunkSize),
+		written:   offset,
+		crc:       crc,
+		next:      offset + int64(chunkSize),
+		points:    []crcPoint{{offset, crc}},
+	}
+}
+
+// write adds p, the next data written to the upload, to the checksums.
+func (c *uploadChecksums) write(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(p) > 0 {
+		n := min(int64(len(p)), c.next-c.written)
+		c.crc = crc32.Update(c.crc, crc32cTable, p[:n])
+		c.written += n
+		p = p[n:]
+		if c.written == c.next {
+			c.points = append(c.points, crcPoint{c.written, c.crc})
+			c.next += c.chunkSize
+		}
+	}
+}
+
+// sum returns the checksum of all the data written so far.
+func (c *uploadChecksums) sum() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.crc
+}
+
+// at returns the checksum of the data up to offset, if it was recorded.
+// Since persisted offsets only increase, checksums recorded for earlier
+// offsets are discarded.
+func (c *uploadChecksums) at(offset int64) (uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset == c.written {
+		return c.crc, true
+	}
+	i := 0
+	for i < len(c.points) && c.points[i].offset < offset {
+		i++
+	}
+	c.points = c.points[i:]
+	if len(c.points) > 0 && c.points[0].offset == offset {
+		return c.points[0].crc, true
+	}
+	return 0, false
+}
+
+// sessionReporter builds the ResumableSession values reported to
+// Writer.SessionFunc.
+type sessionReporter struct {
+	bucket, object string
+	crcs           *uploadChecksums // nil if checksums are not tracked
+	setSession     func(*ResumableSession)
+}
+
+// newSessionReporter returns a sessionReporter for the upload described by
+// params, or nil if the upload does not report sessions. chunkSize is the
+// transport's chunk size, which determines where checksums are recorded.
+func newSessionReporter(params *openWriterParams, chunkSize int) *sessionReporter {
+	if params.setSession == nil && params.session == nil {
+		return nil
+	}
+	r := &sessionReporter{
+		bucket:     params.bucket,
+		object:     params.attrs.Name,
+		setSession: params.setSession,
+	}
+	if s := params.session; s == nil {
+		if !params.disableAutoChecksum {
+			r.crcs = newUploadChecksums(0, 0, chunkSize)
+		}
+	} else if s.HasCRC32C && !params.disableAutoChecksum {
+		r.crcs = newUploadChecksums(s.PersistedSize, s.CRC32C, chunkSize)
+	}
+	return r
+}
+
+// write records data written to the upload.
+func (r *sessionReporter) write(p []byte) {
+	if r.crcs != nil {
+		r.crcs.write(p)
+	}
+}
+
+// report calls the session callback, if any, with the state of the session
+// identified by uri or uploadID, of which persisted bytes have been persisted.
+func (r *sessionReporter) report(uri, uploadID string, persisted int64) {
+	if r.setSession == nil {
+		return
+	}
+	s := &ResumableSession{
+		Bucket:        r.bucket,
+		Object:        r.object,
+		URI:           uri,
+		UploadID:      uploadID,
+		PersistedSize: persisted,
+	}
+	if r.crcs != nil {
+		s.CRC32C, s.HasCRC32C = r.crcs.at(persisted)
+	}
+	r.setSession(s)
+}
@@ -0,0 +1,344 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// fakeResumableServer implements the JSON API resumable upload protocol for
+// a single bucket.
+type fakeResumableServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	sessions map[string]*fakeSession
+	// failPartial makes the first upload to each chunk boundary persist only
+	// half of the data and fail with a 503.
+	failPartial bool
+	failed      map[int64]bool
+}
+
+type fakeSession struct {
+	name        string
+	contentType string
+	data        []byte
+	done        bool
+}
+
+func newFakeResumableServer(t *testing.T) *fakeResumableServer {
+	t.Helper()
+	s := &fakeResumableServer{sessions: map[string]*fakeSession{}, failed: map[int64]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeResumableServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		q := r.URL.Query()
+		if q.Get("uploadType") != "resumable" {
+			http.Error(w, "unexpected upload type", http.StatusBadRequest)
+			return
+		}
+		id := strconv.Itoa(len(s.sessions))
+		s.sessions[id] = &fakeSession{name: q.Get("name"), contentType: r.Header.Get("X-Upload-Content-Type")}
+		w.Header().Set("Location", s.URL+"/session/"+id)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/session/"):
+		sess, ok := s.sessions[strings.TrimPrefix(r.URL.Path, "/session/")]
+		if !ok {
+			http.Error(w, "no such session", http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var start, end int64
+		var total string
+		rng := r.Header.Get("Content-Range")
+		if n, _ := fmt.Sscanf(rng, "bytes %d-%d/%s", &start, &end, &total); n != 3 {
+			start, end = int64(len(sess.data)), int64(len(sess.data))-1
+			total = strings.TrimPrefix(rng, "bytes */")
+		}
+		if sess.done {
+			s.writeObject(w, sess)
+			return
+		}
+		if len(body) > 0 {
+			if start > int64(len(sess.data)) || end+1-start != int64(len(body)) {
+				http.Error(w, "bad range "+rng, http.StatusBadRequest)
+				return
+			}
+			body = body[int64(len(sess.data))-start:]
+			if s.failPartial && start%googleapi.MinUploadChunkSize == 0 && !s.failed[start] && len(body) > 1 {
+				s.failed[start] = true
+				sess.data = append(sess.data, body[:len(body)/2]...)
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+				return
+			}
+			sess.data = append(sess.data, body...)
+		}
+		if total != "*" && strconv.Itoa(len(sess.data)) == total {
+			if h := r.Header.Get("X-Goog-Hash"); h != "" && h != "crc32c="+encodeUint32(crc32.Checksum(sess.data, crc32cTable)) {
+				http.Error(w, "checksum mismatch", http.StatusBadRequest)
+				return
+			}
+			sess.done = true
+			s.writeObject(w, sess)
+			return
+		}
+		if len(sess.data) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(sess.data)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (s *fakeResumableServer) writeObject(w http.ResponseWriter, sess *fakeSession) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"bucket":      "bucket",
+		"name":        sess.name,
+		"size":        strconv.Itoa(len(sess.data)),
+		"contentType": sess.contentType,
+		"crc32c":      encodeUint32(crc32.Checksum(sess.data, crc32cTable)),
+		"generation":  "1",
+	})
+}
+
+func (s *fakeResumableServer) client(t *testing.T) *Client {
+	t.Helper()
+	client, err := NewClient(context.Background(), option.WithEndpoint(s.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestResumableSessionHTTP(t *testing.T) {
+	t.Parallel()
+	chunk := googleapi.MinUploadChunkSize
+	data := make([]byte, 3*chunk+100)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	wantSessions := func(uri string, offsets ...int64) []ResumableSession {
+		var ss []ResumableSession
+		for _, off := range offsets {
+			ss = append(ss, ResumableSession{
+				Bucket:        "bucket",
+				Object:        "obj",
+				URI:           uri,
+				PersistedSize: off,
+				CRC32C:        crc32.Checksum(data[:off], crc32cTable),
+				HasCRC32C:     true,
+			})
+		}
+		return ss
+	}
+
+	for _, failPartial := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failPartial=%v", failPartial), func(t *testing.T) {
+			t.Parallel()
+			srv := newFakeResumableServer(t)
+			srv.failPartial = failPartial
+			obj := srv.client(t).Bucket("bucket").Object("obj").Retryer(WithBackoff(gax.Backoff{Initial: time.Millisecond}))
+
+			var sessions []ResumableSession
+			w := obj.NewWriter(context.Background())
+			w.ChunkSize = chunk
+			w.ContentType = "application/octet-stream"
+			w.SessionFunc = func(s *ResumableSession) { sessions = append(sessions, *s) }
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			uri := srv.URL + "/session/0"
+			want := wantSessions(uri, 0, int64(chunk), int64(2*chunk), int64(3*chunk))
+			if diff := cmp.Diff(want, sessions); diff != "" {
+				t.Errorf("sessions: got(-),want(+):\n%s", diff)
+			}
+			if got := srv.sessions["0"].data; !bytes.Equal(got, data) {
+				t.Errorf("uploaded %d bytes, want %d bytes of data", len(got), len(data))
+			}
+			if attrs := w.Attrs(); attrs == nil || attrs.Size != int64(len(data)) || attrs.ContentType != "application/octet-stream" {
+				t.Errorf("Attrs: got %+v", attrs)
+			}
+		})
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		t.Parallel()
+		srv := newFakeResumableServer(t)
+		obj := srv.client(t).Bucket("bucket").Object("obj")
+
+		// Upload two chunks, then abandon the upload.
+		var last ResumableSession
+		ctx, cancel := context.WithCancel(context.Background())
+		w := obj.NewWriter(ctx)
+		w.ChunkSize = chunk
+		w.SessionFunc = func(s *ResumableSession) { last = *s }
+		if _, err := w.Write(data[:2*chunk+10]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		// Wait until the second chunk is persisted.
+		for {
+			srv.mu.Lock()
+			n := len(srv.sessions["0"].data)
+			srv.mu.Unlock()
+			if n == 2*chunk {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		w.Close()
+
+		// Round-trip the session through JSON, as a new process would.
+		b, err := json.Marshal(last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var session ResumableSession
+		if err := json.Unmarshal(b, &session); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(wantSessions(srv.URL+"/session/0", int64(2*chunk))[0], session); diff != "" {
+			t.Fatalf("last session: got(-),want(+):\n%s", diff)
+		}
+
+		var progress []int64
+		w, off, err := obj.NewWriterFromSession(context.Background(), &session, &ResumableWriterOpts{
+			ChunkSize:    chunk,
+			ProgressFunc: func(n int64) { progress = append(progress, n) },
+		})
+		if err != nil {
+			t.Fatalf("NewWriterFromSession: %v", err)
+		}
+		if off != int64(2*chunk) {
+			t.Errorf("offset: got %d, want %d", off, 2*chunk)
+		}
+		if _, err := w.Write(data[off:]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if got := srv.sessions["0"].data; !bytes.Equal(got, data) {
+			t.Errorf("uploaded %d bytes, want %d bytes of data", len(got), len(data))
+		}
+		if want := []int64{int64(3 * chunk)}; !cmp.Equal(progress, want) {
+			t.Errorf("progress: got %v, want %v", progress, want)
+		}
+
+		// Resuming a completed upload returns a closed Writer.
+		w, off, err = obj.NewWriterFromSession(context.Background(), &session, nil)
+		if err != nil {
+			t.Fatalf("NewWriterFromSession: %v", err)
+		}
+		if off != int64(len(data)) {
+			t.Errorf("offset of completed upload: got %d, want %d", off, len(data))
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+		if attrs := w.Attrs(); attrs == nil || attrs.Size != int64(len(data)) {
+			t.Errorf("Attrs: got %+v", attrs)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		obj := newFakeResumableServer(t).client(t).Bucket("bucket").Object("obj")
+		for _, s := range []*ResumableSession{
+			nil,
+			{Bucket: "bucket", Object: "other", URI: "uri"},
+			{Bucket: "bucket", Object: "obj"},
+			{Bucket: "bucket", Object: "obj", URI: "uri", UploadID: "id"},
+			{Bucket: "bucket", Object: "obj", UploadID: "id"},
+		} {
+			if _, _, err := obj.NewWriterFromSession(context.Background(), s, nil); err == nil {
+				t.Errorf("NewWriterFromSession(%+v): got nil error", s)
+			}
+		}
+
+		w := obj.NewWriter(context.Background())
+		w.ChunkSize = 0
+		w.SessionFunc = func(*ResumableSession) {}
+		if _, err := w.Write([]byte("x")); err == nil {
+			t.Error("Write with SessionFunc and zero ChunkSize: got nil error")
+		}
+	})
+}
+
+func TestUploadChecksums(t *testing.T) {
+	t.Parallel()
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	sum := func(b []byte) uint32 { return crc32.Checksum(b, crc32cTable) }
+
+	// Write data after the first three bytes in uneven pieces. Checksums are
+	// recorded every 10 bytes from offset 3.
+	c := newUploadChecksums(3, sum(data[:3]), 10)
+	for _, p := range [][2]int{{3, 7}, {7, 22}, {22, 23}, {23, 43}} {
+		c.write(data[p[0]:p[1]])
+	}
+
+	for _, test := range []struct {
+		offset int64
+		ok     bool
+	}{
+		{offset: 3, ok: true},
+		{offset: 13, ok: true},
+		{offset: 20, ok: false},
+		{offset: 23, ok: true},
+		{offset: 13, ok: false}, // discarded
+		{offset: 43, ok: true},
+	} {
+		got, ok := c.at(test.offset)
+		if ok != test.ok {
+			t.Errorf("at(%d): got ok %v, want %v", test.offset, ok, test.ok)
+			continue
+		}
+		if ok && got != sum(data[:test.offset]) {
+			t.Errorf("at(%d): got %d, want %d", test.offset, got, sum(data[:test.offset]))
+		}
+	}
+	if got, want := c.sum(), sum(data); got != want {
+		t.Errorf("sum: got %d, want %d", got, want)
+	}
+}
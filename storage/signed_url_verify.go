@@ -0,0 +1,562 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithmRSA  = "GOOG4-RSA-SHA256"
+	algorithmHMAC = "GOOG4-HMAC-SHA256"
+)
+
+// signedURLV4Params are the query parameters used to sign a URL with the V4
+// signing scheme.
+var signedURLV4Params = []string{"X-Goog-Algorithm", "X-Goog-Credential", "X-Goog-Date", "X-Goog-Expires", "X-Goog-SignedHeaders", "X-Goog-Signature"}
+
+// VerifySignedURLOptions are the options for [VerifySignedURL].
+type VerifySignedURLOptions struct {
+	// GoogleAccessID is the expected authorizer of the signed URL. If empty,
+	// any authorizer is accepted and reported in the SignedURLInfo.
+	// Optional.
+	GoogleAccessID string
+
+	// PublicKey verifies URLs signed with a service account key
+	// (GOOG4-RSA-SHA256). It is a PEM-encoded X.509 certificate, as published
+	// for service accounts at
+	// https://www.googleapis.com/service_accounts/v1/metadata/x509/{email},
+	// or a PEM-encoded RSA public or private key.
+	// Exactly one of PublicKey or HMACSecret must be set.
+	PublicKey []byte
+
+	// HMACSecret verifies URLs signed with an HMAC key (GOOG4-HMAC-SHA256).
+	// It is the base64-encoded secret of the HMAC key.
+	// Exactly one of PublicKey or HMACSecret must be set.
+	HMACSecret string
+
+	// Method is the HTTP method of the request using the signed URL. If
+	// empty, each method that signed URLs can be used with is tried, and the
+	// one the URL was signed for is reported in the SignedURLInfo.
+	// Optional.
+	Method string
+
+	// Headers are the headers of the request using the signed URL. They must
+	// include every header the URL was signed with, except for the host,
+	// which is taken from the URL.
+	// Optional.
+	Headers http.Header
+
+	// Now is the time at which the signed URL is used. Defaults to the
+	// current time.
+	// Optional.
+	Now time.Time
+}
+
+// SignedURLInfo describes the request authorized by a signed URL.
+type SignedURLInfo struct {
+	// GoogleAccessID is the authorizer of the signed URL.
+	GoogleAccessID string
+
+	// Method is the HTTP method the URL was signed for.
+	Method string
+
+	// Signed is the time at which the URL was signed.
+	Signed time.Time
+
+	// Expires is the time at which the URL expires.
+	Expires time.Time
+
+	// Headers are the names of the headers the URL was signed with, in
+	// lowercase and including "host". A request must send the same values for
+	// these headers.
+	Headers []string
+
+	// QueryParameters are the query parameters the URL was signed with, other
+	// than the ones used for signing.
+	QueryParameters url.Values
+}
+
+// VerifySignedURL checks that signedURL was signed with the V4 signing scheme
+// by the key given in opts, and that it is valid at opts.Now for a request
+// with opts.Method and opts.Headers. It returns the description of the
+// request the URL authorizes.
+//
+// VerifySignedURL can be used to check that the URLs created by [SignedURL]
+// or [BucketHandle.SignedURL] conform to a policy, such as a maximum expiry,
+// or by a fake server to honor signed URLs.
+func VerifySignedURL(signedURL string, opts *VerifySignedURLOptions) (*SignedURLInfo, error) {
+	if opts == nil {
+		return nil, errors.New("storage: missing required VerifySignedURLOptions")
+	}
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid signed URL: %w", err)
+	}
+	query := u.Query()
+	for _, k := range signedURLV4Params {
+		if query.Get(k) == "" {
+			return nil, fmt.Errorf("storage: signed URL is missing the %s query parameter", k)
+		}
+	}
+	v, err := newSignatureVerifier(query.Get("X-Goog-Algorithm"), query.Get("X-Goog-Credential"), query.Get("X-Goog-Date"), opts.GoogleAccessID, opts.PublicKey, opts.HMACSecret)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(query.Get("X-Goog-Signature"))
+	if err != nil {
+		return nil, errors.New("storage: signed URL has an invalid X-Goog-Signature")
+	}
+	expiresSeconds, err := strconv.Atoi(query.Get("X-Goog-Expires"))
+	if err != nil || expiresSeconds < 1 || expiresSeconds > 604800 {
+		return nil, fmt.Errorf("storage: signed URL has an invalid X-Goog-Expires %q", query.Get("X-Goog-Expires"))
+	}
+	info := &SignedURLInfo{
+		GoogleAccessID:  v.accessID,
+		Signed:          v.signed,
+		Expires:         v.signed.Add(time.Duration(expiresSeconds) * time.Second),
+		Headers:         strings.Split(query.Get("X-Goog-SignedHeaders"), ";"),
+		QueryParameters: url.Values{},
+	}
+	for k, vs := range query {
+		info.QueryParameters[k] = vs
+	}
+	for _, k := range signedURLV4Params {
+		delete(info.QueryParameters, k)
+	}
+
+	// Rebuild the canonical request, as in signedURLV4, for each candidate
+	// method.
+	query.Del("X-Goog-Signature")
+	canonicalQuery := strings.Replace(query.Encode(), "+", "%20", -1)
+	canonicalPath := "/" + pathEncodeV4(strings.TrimPrefix(u.Path, "/"))
+	var headerLines []string
+	payload := "UNSIGNED-PAYLOAD"
+	for _, name := range info.Headers {
+		var value string
+		if name == "host" {
+			value = u.Hostname()
+		} else {
+			values, ok := opts.Headers[http.CanonicalHeaderKey(name)]
+			if !ok {
+				return nil, fmt.Errorf("storage: signed URL requires the %q header", name)
+			}
+			value = strings.Join(values, ",")
+		}
+		line := strings.Join(strings.Fields(name+":"+strings.TrimSpace(value)), " ")
+		headerLines = append(headerLines, line)
+		if name == "x-goog-content-sha256" {
+			payload = strings.SplitN(line, ":", 2)[1]
+		}
+	}
+	canonicalHeaders := strings.Join(headerLines, "\n")
+
+	methods := []string{strings.ToUpper(opts.Method)}
+	if opts.Method == "" {
+		methods = methods[:0]
+		for m := range signedURLMethods {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+	}
+	for _, method := range methods {
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "%s\n%s\n%s\n", method, canonicalPath, canonicalQuery)
+		fmt.Fprintf(buf, "%s\n\n", canonicalHeaders)
+		fmt.Fprintf(buf, "%s\n%s", query.Get("X-Goog-SignedHeaders"), payload)
+		sum := sha256.Sum256(buf.Bytes())
+		stringToSign := fmt.Sprintf("%s\n%s\n%s\n%s", v.algorithm, query.Get("X-Goog-Date"), v.scope, hex.EncodeToString(sum[:]))
+		if v.verify([]byte(stringToSign), signature) == nil {
+			info.Method = method
+			break
+		}
+	}
+	if info.Method == "" {
+		return nil, errors.New("storage: signed URL signature does not match")
+	}
+	if err := checkValidity(info.Signed, info.Expires, opts.Now); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// VerifyPostPolicyV4Options are the options for [VerifyPostPolicyV4].
+type VerifyPostPolicyV4Options struct {
+	// GoogleAccessID is the expected authorizer of the policy. If empty, any
+	// authorizer is accepted and reported in the PostPolicyV4Info.
+	// Optional.
+	GoogleAccessID string
+
+	// PublicKey verifies policies signed with a service account key
+	// (GOOG4-RSA-SHA256). See [VerifySignedURLOptions.PublicKey] for the
+	// accepted formats.
+	// Exactly one of PublicKey or HMACSecret must be set.
+	PublicKey []byte
+
+	// HMACSecret verifies policies signed with an HMAC key
+	// (GOOG4-HMAC-SHA256). It is the base64-encoded secret of the HMAC key.
+	// Exactly one of PublicKey or HMACSecret must be set.
+	HMACSecret string
+
+	// Now is the time at which the form is posted. Defaults to the current
+	// time.
+	// Optional.
+	Now time.Time
+}
+
+// PostPolicyV4Info describes the uploads authorized by a signed post policy.
+type PostPolicyV4Info struct {
+	// GoogleAccessID is the authorizer of the policy.
+	GoogleAccessID string
+
+	// Signed is the time at which the policy was signed.
+	Signed time.Time
+
+	// Expires is the time at which the policy expires.
+	Expires time.Time
+
+	// Bucket is the bucket the policy allows uploading to, or empty if the
+	// policy has no bucket condition.
+	Bucket string
+
+	// Fields are the form fields whose values are set by the policy,
+	// including "key", the name of the object.
+	Fields map[string]string
+
+	// StartsWith are the form fields whose values must start with a prefix,
+	// keyed by field name. Of several starts-with conditions on a field, the
+	// one with the longest prefix is kept.
+	StartsWith map[string]string
+
+	// MinContentLength and MaxContentLength are the range of sizes the
+	// uploaded file must be in, which is the intersection of the ranges of
+	// the policy. MaxContentLength is -1 if the policy does not restrict the
+	// size.
+	MinContentLength, MaxContentLength int64
+}
+
+// VerifyPostPolicyV4 checks that a form posted to upload an object carries a
+// post policy signed by the key given in opts, that the policy is valid at
+// opts.Now, and that the form fields satisfy its conditions. Fields holds the
+// form fields other than the file, such as the Fields of a [PostPolicyV4]
+// created by [GenerateSignedPostPolicyV4]; field names are case-insensitive,
+// and fields whose names differ only in case are an error. Every condition
+// of the policy must hold, so a policy with conditions on the same field that
+// cannot all hold is an error.
+// It returns the description of the uploads the policy authorizes. The size
+// of the uploaded file is not checked.
+func VerifyPostPolicyV4(fields map[string]string, opts *VerifyPostPolicyV4Options) (*PostPolicyV4Info, error) {
+	if opts == nil {
+		return nil, errors.New("storage: missing required VerifyPostPolicyV4Options")
+	}
+	form := make(map[string]string, len(fields))
+	for k, v := range fields {
+		lk := strings.ToLower(k)
+		if _, ok := form[lk]; ok {
+			return nil, fmt.Errorf("storage: post policy form has more than one %s field", lk)
+		}
+		form[lk] = v
+	}
+	for _, k := range []string{"policy", "x-goog-signature", "x-goog-algorithm", "x-goog-credential", "x-goog-date"} {
+		if form[k] == "" {
+			return nil, fmt.Errorf("storage: post policy form is missing the %s field", k)
+		}
+	}
+	v, err := newSignatureVerifier(form["x-goog-algorithm"], form["x-goog-credential"], form["x-goog-date"], opts.GoogleAccessID, opts.PublicKey, opts.HMACSecret)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(form["x-goog-signature"])
+	if err != nil {
+		return nil, errors.New("storage: post policy has an invalid x-goog-signature")
+	}
+	if err := v.verify([]byte(form["policy"]), signature); err != nil {
+		return nil, errors.New("storage: post policy signature does not match")
+	}
+
+	policyJSON, err := base64.StdEncoding.DecodeString(form["policy"])
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid post policy: %w", err)
+	}
+	var policy struct {
+		Conditions []json.RawMessage `json:"conditions"`
+		Expiration time.Time         `json:"expiration"`
+	}
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, fmt.Errorf("storage: invalid post policy: %w", err)
+	}
+	info := &PostPolicyV4Info{
+		GoogleAccessID:   v.accessID,
+		Signed:           v.signed,
+		Expires:          policy.Expiration,
+		Fields:           map[string]string{},
+		StartsWith:       map[string]string{},
+		MaxContentLength: -1,
+	}
+	for _, raw := range policy.Conditions {
+		if err := info.addCondition(raw); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkValidity(info.Signed, info.Expires, opts.Now); err != nil {
+		return nil, err
+	}
+
+	// Every field of the form must be allowed by a condition.
+	for k, val := range form {
+		switch {
+		case k == "policy" || k == "x-goog-signature" || k == "file" || strings.HasPrefix(k, "x-ignore-"):
+			continue
+		case k == "bucket":
+			if info.Bucket != "" && val != info.Bucket {
+				return nil, fmt.Errorf("storage: post policy requires the bucket %q, got %q", info.Bucket, val)
+			}
+			continue
+		}
+		want, isSet := info.Fields[k]
+		prefix, hasPrefix := info.StartsWith[k]
+		if !isSet && !hasPrefix {
+			return nil, fmt.Errorf("storage: post policy does not allow the field %q", k)
+		}
+		if isSet && val != want {
+			return nil, fmt.Errorf("storage: post policy requires the field %q to be %q, got %q", k, want, val)
+		}
+		if hasPrefix && !strings.HasPrefix(val, prefix) {
+			return nil, fmt.Errorf("storage: post policy requires the field %q to start with %q, got %q", k, prefix, val)
+		}
+	}
+	for k, want := range info.Fields {
+		if _, ok := form[k]; !ok {
+			return nil, fmt.Errorf("storage: post policy requires the field %q to be %q", k, want)
+		}
+	}
+	return info, nil
+}
+
+// addCondition adds a condition of a post policy, either of the form
+// {"field": "value"} or ["operator", ...], to the info.
+func (info *PostPolicyV4Info) addCondition(raw json.RawMessage) error {
+	var obj map[string]string
+	if json.Unmarshal(raw, &obj) == nil {
+		for k, val := range obj {
+			if err := info.setField(strings.ToLower(k), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var arr []json.RawMessage
+	if err := json.Unmarshal(raw, &arr); err != nil || len(arr) != 3 {
+		return fmt.Errorf("storage: invalid post policy condition %s", raw)
+	}
+	var op string
+	if err := json.Unmarshal(arr[0], &op); err != nil {
+		return fmt.Errorf("storage: invalid post policy condition %s", raw)
+	}
+	if op == "content-length-range" {
+		var lo, hi int64
+		if json.Unmarshal(arr[1], &lo) != nil || json.Unmarshal(arr[2], &hi) != nil {
+			return fmt.Errorf("storage: invalid post policy condition %s", raw)
+		}
+		// Every range must hold, so the size must be in their intersection.
+		info.MinContentLength = max(info.MinContentLength, lo)
+		if info.MaxContentLength < 0 || hi < info.MaxContentLength {
+			info.MaxContentLength = hi
+		}
+		return nil
+	}
+	var field, val string
+	if json.Unmarshal(arr[1], &field) != nil || json.Unmarshal(arr[2], &val) != nil {
+		return fmt.Errorf("storage: invalid post policy condition %s", raw)
+	}
+	field = strings.ToLower(strings.TrimPrefix(field, "$"))
+	switch op {
+	case "eq":
+		return info.setField(field, val)
+	case "starts-with":
+		// Of two prefixes that can both hold, the longer implies the other.
+		if prev, ok := info.StartsWith[field]; ok {
+			switch {
+			case strings.HasPrefix(val, prev):
+			case strings.HasPrefix(prev, val):
+				val = prev
+			default:
+				return fmt.Errorf("storage: post policy has conflicting starts-with conditions on the field %q", field)
+			}
+		}
+		info.StartsWith[field] = val
+	default:
+		return fmt.Errorf("storage: unsupported post policy condition %s", raw)
+	}
+	return nil
+}
+
+// setField records that the policy requires the field to have the value val.
+// It returns an error if an earlier condition requires a different value.
+func (info *PostPolicyV4Info) setField(field, val string) error {
+	prev, ok := info.Fields[field]
+	if field == "bucket" {
+		prev, ok = info.Bucket, info.Bucket != ""
+	}
+	if ok && prev != val {
+		return fmt.Errorf("storage: post policy has conflicting conditions on the field %q", field)
+	}
+	if field == "bucket" {
+		info.Bucket = val
+	} else {
+		info.Fields[field] = val
+	}
+	return nil
+}
+
+// signatureVerifier verifies V4 signatures made by a single key.
+type signatureVerifier struct {
+	algorithm string
+	accessID  string
+	scope     string // date/auto/storage/goog4_request
+	signed    time.Time
+	verify    func(data, signature []byte) error
+}
+
+// newSignatureVerifier checks the signing parameters of a signed URL or post
+// policy, and returns a verifier for the signature made with them.
+func newSignatureVerifier(algorithm, credential, date, wantAccessID string, publicKey []byte, hmacSecret string) (*signatureVerifier, error) {
+	if (publicKey == nil) == (hmacSecret == "") {
+		return nil, errors.New("storage: exactly one of PublicKey or HMACSecret must be set")
+	}
+	signed, err := time.Parse(iso8601, date)
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid signing date %q", date)
+	}
+	// The credential is {accessID}/{date}/auto/storage/goog4_request.
+	parts := strings.Split(credential, "/")
+	if len(parts) < 5 || parts[len(parts)-4] != signed.Format(yearMonthDay) || strings.Join(parts[len(parts)-3:], "/") != "auto/storage/goog4_request" {
+		return nil, fmt.Errorf("storage: invalid credential %q", credential)
+	}
+	v := &signatureVerifier{
+		algorithm: algorithm,
+		accessID:  strings.Join(parts[:len(parts)-4], "/"),
+		scope:     strings.Join(parts[len(parts)-4:], "/"),
+		signed:    signed,
+	}
+	if wantAccessID != "" && v.accessID != wantAccessID {
+		return nil, fmt.Errorf("storage: signed by %q, want %q", v.accessID, wantAccessID)
+	}
+
+	switch algorithm {
+	case algorithmRSA:
+		if publicKey == nil {
+			return nil, fmt.Errorf("storage: a PublicKey is required to verify a %s signature", algorithm)
+		}
+		key, err := parsePublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		v.verify = func(data, signature []byte) error {
+			sum := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature)
+		}
+	case algorithmHMAC:
+		if hmacSecret == "" {
+			return nil, fmt.Errorf("storage: an HMACSecret is required to verify a %s signature", algorithm)
+		}
+		key := []byte("GOOG4" + hmacSecret)
+		for _, s := range parts[len(parts)-4:] {
+			key = hmacSHA256(key, []byte(s))
+		}
+		v.verify = func(data, signature []byte) error {
+			if !hmac.Equal(hmacSHA256(key, data), signature) {
+				return errors.New("signature mismatch")
+			}
+			return nil
+		}
+	default:
+		return nil, fmt.Errorf("storage: unsupported signing algorithm %q", algorithm)
+	}
+	return v, nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// parsePublicKey parses a PEM-encoded X.509 certificate or RSA public or
+// private key.
+func parsePublicKey(key []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("storage: PublicKey is not PEM-encoded")
+	}
+	var pub any
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			pub = cert.PublicKey
+		}
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		var priv *rsa.PrivateKey
+		if priv, err = parseKey(key); err == nil {
+			pub = &priv.PublicKey
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid PublicKey: %w", err)
+	}
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("storage: PublicKey is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// checkValidity checks that now, or the current time if now is zero, is
+// within the validity period of a signature.
+func checkValidity(signed, expires, now time.Time) error {
+	if now.IsZero() {
+		now = utcNow()
+	}
+	if now.Before(signed) {
+		return fmt.Errorf("storage: signature is not valid before %v", signed)
+	}
+	if !now.Before(expires) {
+		return fmt.Errorf("storage: signature expired at %v", expires)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVerifySignedURLConformance(t *testing.T) {
+	googleAccessID, privateKey, testFiles := parseFiles(t)
+
+	for _, testFile := range testFiles {
+		for _, tc := range testFile.SigningV4Tests {
+			t.Run(tc.Description, func(t *testing.T) {
+				now := time.Unix(tc.Timestamp.Seconds, 0).UTC()
+				headers := http.Header{}
+				for k, v := range tc.Headers {
+					headers.Add(k, v)
+				}
+				info, err := VerifySignedURL(tc.ExpectedUrl, &VerifySignedURLOptions{
+					GoogleAccessID: googleAccessID,
+					PublicKey:      []byte(privateKey),
+					Headers:        headers,
+					Now:            now,
+				})
+				if err != nil {
+					t.Fatalf("VerifySignedURL: %v", err)
+				}
+				if info.Method != tc.Method || !info.Expires.Equal(now.Add(time.Duration(tc.Expiration)*time.Second)) {
+					t.Errorf("got method %q expiring at %v, want %q expiring in %ds", info.Method, info.Expires, tc.Method, tc.Expiration)
+				}
+				for k, v := range tc.QueryParameters {
+					if got := info.QueryParameters.Get(k); got != v {
+						t.Errorf("query parameter %q: got %q, want %q", k, got, v)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	oldUTCNow := utcNow
+	defer func() {
+		utcNow = oldUTCNow
+	}()
+	signed := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	utcNow = func() time.Time { return signed }
+
+	signedURL, err := SignedURL("bucket", "dir/an object", &SignedURLOptions{
+		GoogleAccessID:  "signer@example.iam.gserviceaccount.com",
+		PrivateKey:      dummyKey("rsa"),
+		Method:          "GET",
+		Expires:         signed.Add(15 * time.Minute),
+		Scheme:          SigningSchemeV4,
+		Headers:         []string{"x-goog-meta-a: b  c", "X-Goog-Meta-A:d"},
+		QueryParameters: url.Values{"generation": {"7"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := http.Header{"X-Goog-Meta-A": {"b c", "d"}}
+	opts := func(f func(*VerifySignedURLOptions)) *VerifySignedURLOptions {
+		o := &VerifySignedURLOptions{
+			PublicKey: dummyKey("rsa"),
+			Headers:   headers,
+			Now:       signed.Add(time.Minute),
+		}
+		if f != nil {
+			f(o)
+		}
+		return o
+	}
+
+	info, err := VerifySignedURL(signedURL, opts(nil))
+	if err != nil {
+		t.Fatalf("VerifySignedURL: %v", err)
+	}
+	want := &SignedURLInfo{
+		GoogleAccessID:  "signer@example.iam.gserviceaccount.com",
+		Method:          "GET",
+		Signed:          signed,
+		Expires:         signed.Add(15 * time.Minute),
+		Headers:         []string{"host", "x-goog-meta-a"},
+		QueryParameters: url.Values{"generation": {"7"}},
+	}
+	if diff := cmp.Diff(want, info); diff != "" {
+		t.Errorf("SignedURLInfo: got(-),want(+):\n%s", diff)
+	}
+
+	for _, test := range []struct {
+		desc string
+		url  string
+		opts *VerifySignedURLOptions
+	}{
+		{"wrong method", signedURL, opts(func(o *VerifySignedURLOptions) { o.Method = "PUT" })},
+		{"wrong header", signedURL, opts(func(o *VerifySignedURLOptions) { o.Headers = http.Header{"X-Goog-Meta-A": {"b"}} })},
+		{"missing header", signedURL, opts(func(o *VerifySignedURLOptions) { o.Headers = nil })},
+		{"expired", signedURL, opts(func(o *VerifySignedURLOptions) { o.Now = signed.Add(15 * time.Minute) })},
+		{"not yet valid", signedURL, opts(func(o *VerifySignedURLOptions) { o.Now = signed.Add(-time.Second) })},
+		{"wrong signer", signedURL, opts(func(o *VerifySignedURLOptions) { o.GoogleAccessID = "other@example.com" })},
+		{"HMAC secret for an RSA signature", signedURL, opts(func(o *VerifySignedURLOptions) { o.PublicKey, o.HMACSecret = nil, "secret" })},
+		{"other object", strings.Replace(signedURL, "an%20object", "another", 1), opts(nil)},
+		{"other query", strings.Replace(signedURL, "generation=7", "generation=8", 1), opts(nil)},
+		{"longer expiry", strings.Replace(signedURL, "X-Goog-Expires=900", "X-Goog-Expires=901", 1), opts(nil)},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := VerifySignedURL(test.url, test.opts); err == nil {
+				t.Error("got nil, want error")
+			}
+		})
+	}
+}
+
+func TestVerifySignedURLHMAC(t *testing.T) {
+	// Sign a URL with an HMAC key as described in
+	// https://cloud.google.com/storage/docs/access-control/signing-urls-manually.
+	const secret = "c2VjcmV0"
+	credential := "GOOGTS7C7FUP3AIRVJTE2BCD/20260304/auto/storage/goog4_request"
+	query := "X-Goog-Algorithm=GOOG4-HMAC-SHA256&X-Goog-Credential=" + url.QueryEscape(credential) +
+		"&X-Goog-Date=20260304T050607Z&X-Goog-Expires=60&X-Goog-SignedHeaders=host"
+	canonical := "HEAD\n/bucket/obj\n" + query + "\nhost:storage.googleapis.com\n\nhost\nUNSIGNED-PAYLOAD"
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := "GOOG4-HMAC-SHA256\n20260304T050607Z\n20260304/auto/storage/goog4_request\n" + hex.EncodeToString(sum[:])
+	key := []byte("GOOG4" + secret)
+	for _, s := range []string{"20260304", "auto", "storage", "goog4_request", stringToSign} {
+		key = hmacSHA256(key, []byte(s))
+	}
+	signedURL := "https://storage.googleapis.com/bucket/obj?" + query + "&X-Goog-Signature=" + hex.EncodeToString(key)
+
+	now := time.Date(2026, 3, 4, 5, 6, 30, 0, time.UTC)
+	info, err := VerifySignedURL(signedURL, &VerifySignedURLOptions{HMACSecret: secret, Now: now})
+	if err != nil {
+		t.Fatalf("VerifySignedURL: %v", err)
+	}
+	if info.Method != "HEAD" || info.GoogleAccessID != "GOOGTS7C7FUP3AIRVJTE2BCD" || info.Expires.Sub(info.Signed) != time.Minute {
+		t.Errorf("got %+v", info)
+	}
+	if _, err := VerifySignedURL(signedURL, &VerifySignedURLOptions{HMACSecret: "c2VjcmV1", Now: now}); err == nil {
+		t.Error("wrong secret: got nil, want error")
+	}
+	if _, err := VerifySignedURL(signedURL, &VerifySignedURLOptions{PublicKey: dummyKey("rsa"), Now: now}); err == nil {
+		t.Error("public key for an HMAC signature: got nil, want error")
+	}
+}
+
+func TestVerifyPostPolicyV4(t *testing.T) {
+	oldUTCNow := utcNow
+	defer func() {
+		utcNow = oldUTCNow
+	}()
+	signed := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	utcNow = func() time.Time { return signed }
+
+	policy, err := GenerateSignedPostPolicyV4("bucket", "obj", &PostPolicyV4Options{
+		GoogleAccessID: "signer@example.iam.gserviceaccount.com",
+		PrivateKey:     dummyKey("rsa"),
+		Expires:        signed.Add(time.Hour),
+		Fields: &PolicyV4Fields{
+			ContentType: "text/plain",
+			Metadata:    map[string]string{"x-goog-meta-a": "b"},
+		},
+		Conditions: []PostPolicyV4Condition{
+			ConditionStartsWith("$x-goog-meta-tag", "abc"),
+			ConditionContentLengthRange(10, 1000),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := func(extra map[string]string) map[string]string {
+		f := map[string]string{"X-Goog-Meta-Tag": "abcdef", "x-ignore-me": "x", "bucket": "bucket"}
+		for k, v := range policy.Fields {
+			f[k] = v
+		}
+		for k, v := range extra {
+			if v == "" {
+				delete(f, k)
+			} else {
+				f[k] = v
+			}
+		}
+		return f
+	}
+	opts := &VerifyPostPolicyV4Options{PublicKey: dummyKey("rsa"), Now: signed.Add(time.Minute)}
+
+	info, err := VerifyPostPolicyV4(form(nil), opts)
+	if err != nil {
+		t.Fatalf("VerifyPostPolicyV4: %v", err)
+	}
+	want := &PostPolicyV4Info{
+		GoogleAccessID: "signer@example.iam.gserviceaccount.com",
+		Signed:         signed,
+		Expires:        signed.Add(time.Hour),
+		Bucket:         "bucket",
+		Fields: map[string]string{
+			"content-type":      "text/plain",
+			"key":               "obj",
+			"x-goog-algorithm":  "GOOG4-RSA-SHA256",
+			"x-goog-credential": "signer@example.iam.gserviceaccount.com/20260304/auto/storage/goog4_request",
+			"x-goog-date":       "20260304T050607Z",
+			"x-goog-meta-a":     "b",
+		},
+		StartsWith:       map[string]string{"x-goog-meta-tag": "abc"},
+		MinContentLength: 10,
+		MaxContentLength: 1000,
+	}
+	if diff := cmp.Diff(want, info); diff != "" {
+		t.Errorf("PostPolicyV4Info: got(-),want(+):\n%s", diff)
+	}
+
+	for _, test := range []struct {
+		desc  string
+		extra map[string]string
+		now   time.Time
+	}{
+		{desc: "other key", extra: map[string]string{"key": "other"}},
+		{desc: "other bucket", extra: map[string]string{"bucket": "other"}},
+		{desc: "missing field", extra: map[string]string{"content-type": ""}},
+		{desc: "extra field", extra: map[string]string{"cache-control": "no-cache"}},
+		{desc: "wrong prefix", extra: map[string]string{"X-Goog-Meta-Tag": "xyz"}},
+		{desc: "duplicate field", extra: map[string]string{"Content-Type": "text/plain"}},
+		{desc: "tampered policy", extra: map[string]string{"policy": base64.StdEncoding.EncodeToString([]byte(`{"conditions":[],"expiration":"2030-01-01T00:00:00Z"}`))}},
+		{desc: "expired", now: signed.Add(time.Hour)},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			o := *opts
+			if !test.now.IsZero() {
+				o.Now = test.now
+			}
+			if _, err := VerifyPostPolicyV4(form(test.extra), &o); err == nil {
+				t.Error("got nil, want error")
+			}
+		})
+	}
+
+	// A policy signed with an HMAC key.
+	const secret = "c2VjcmV0"
+	credential := "GOOGTS7C7FUP3AIRVJTE2BCD/20260304/auto/storage/goog4_request"
+	b64 := base64.StdEncoding.EncodeToString([]byte(`{"conditions":[{"key":"obj"},["starts-with","$content-type","image/"],` +
+		`{"x-goog-algorithm":"GOOG4-HMAC-SHA256"},{"x-goog-credential":"` + credential + `"},{"x-goog-date":"20260304T050607Z"}],` +
+		`"expiration":"2026-03-04T06:00:00Z"}`))
+	key := []byte("GOOG4" + secret)
+	for _, s := range []string{"20260304", "auto", "storage", "goog4_request", b64} {
+		key = hmacSHA256(key, []byte(s))
+	}
+	fields := map[string]string{
+		"key":               "obj",
+		"content-type":      "image/png",
+		"policy":            b64,
+		"x-goog-algorithm":  "GOOG4-HMAC-SHA256",
+		"x-goog-credential": credential,
+		"x-goog-date":       "20260304T050607Z",
+		"x-goog-signature":  fmt.Sprintf("%x", key),
+	}
+	info, err = VerifyPostPolicyV4(fields, &VerifyPostPolicyV4Options{HMACSecret: secret, Now: signed})
+	if err != nil {
+		t.Fatalf("VerifyPostPolicyV4 with HMAC: %v", err)
+	}
+	if info.StartsWith["content-type"] != "image/" || info.MaxContentLength != -1 || info.Bucket != "" {
+		t.Errorf("VerifyPostPolicyV4 with HMAC: got %+v", info)
+	}
+	if _, err := VerifyPostPolicyV4(fields, &VerifyPostPolicyV4Options{HMACSecret: "c2VjcmV1", Now: signed}); err == nil {
+		t.Error("wrong HMAC secret: got nil, want error")
+	}
+}
+
+func TestVerifyPostPolicyV4RepeatedConditions(t *testing.T) {
+	signed := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	const secret = "c2VjcmV0"
+	credential := "GOOGTS7C7FUP3AIRVJTE2BCD/20260304/auto/storage/goog4_request"
+	// form returns the fields of a form with the given fields and a policy
+	// with the given conditions, signed with an HMAC key.
+	form := func(conditions string, fields map[string]string) map[string]string {
+		b64 := base64.StdEncoding.EncodeToString([]byte(`{"conditions":[` + conditions + `,` +
+			`{"x-goog-algorithm":"GOOG4-HMAC-SHA256"},{"x-goog-credential":"` + credential + `"},{"x-goog-date":"20260304T050607Z"}],` +
+			`"expiration":"2026-03-04T06:00:00Z"}`))
+		key := []byte("GOOG4" + secret)
+		for _, s := range []string{"20260304", "auto", "storage", "goog4_request", b64} {
+			key = hmacSHA256(key, []byte(s))
+		}
+		f := map[string]string{
+			"policy":            b64,
+			"x-goog-algorithm":  "GOOG4-HMAC-SHA256",
+			"x-goog-credential": credential,
+			"x-goog-date":       "20260304T050607Z",
+			"x-goog-signature":  fmt.Sprintf("%x", key),
+		}
+		for k, v := range fields {
+			f[k] = v
+		}
+		return f
+	}
+	opts := &VerifyPostPolicyV4Options{HMACSecret: secret, Now: signed}
+
+	// Conditions that can all hold are combined.
+	info, err := VerifyPostPolicyV4(form(`{"key":"obj"},["eq","$key","obj"],`+
+		`["starts-with","$content-type","image/"],["starts-with","$content-type","image/p"],["starts-with","$content-type",""],`+
+		`["content-length-range",10,1000],["content-length-range",0,500]`,
+		map[string]string{"key": "obj", "content-type": "image/png"}), opts)
+	if err != nil {
+		t.Fatalf("VerifyPostPolicyV4: %v", err)
+	}
+	if got, want := info.StartsWith["content-type"], "image/p"; got != want {
+		t.Errorf("starts-with content-type: got %q, want %q", got, want)
+	}
+	if info.MinContentLength != 10 || info.MaxContentLength != 500 {
+		t.Errorf("content length range: got [%d, %d], want [10, 500]", info.MinContentLength, info.MaxContentLength)
+	}
+	if _, err := VerifyPostPolicyV4(form(`["starts-with","$content-type","image/"],["starts-with","$content-type","image/p"]`,
+		map[string]string{"content-type": "image/jpeg"}), opts); err == nil {
+		t.Error("value without the longer prefix: got nil, want error")
+	}
+	if _, err := VerifyPostPolicyV4(form(`{"key":"obj"},["starts-with","$key","tmp/"]`,
+		map[string]string{"key": "obj"}), opts); err == nil {
+		t.Error("value without the prefix of a field set by eq: got nil, want error")
+	}
+
+	// Conditions that cannot all hold are an error.
+	for _, conditions := range []string{
+		`{"key":"obj"},["eq","$key","other"]`,
+		`["eq","$Content-Type","a"],{"content-type":"b"}`,
+		`{"bucket":"b1"},{"bucket":"b2"}`,
+		`["starts-with","$content-type","image/"],["starts-with","$content-type","text/"]`,
+	} {
+		if _, err := VerifyPostPolicyV4(form(conditions, nil), opts); err == nil {
+			t.Errorf("%s: got nil, want error", conditions)
+		}
+	}
+}
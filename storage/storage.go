@@ -1003,6 +1003,9 @@ type ObjectHandle struct {
 	overrideRetention *bool
 	softDeleted       bool
 	readHandle        ReadHandle
+	keyWrapper        KeyWrapper // for client-side envelope encryption
+	readGzipMembers   bool       // for client-side decompression of gzip members
+	readCache         *ReadCache
 }
 
 // ReadHandle returns a new ObjectHandle that uses the ReadHandle to open the objects.
@@ -1317,6 +1320,9 @@ func (o *ObjectHandle) NewWriter(ctx context.Context) *Writer {
 // This feature is in preview and is not yet available for general use.
 func (o *ObjectHandle) NewWriterFromAppendableObject(ctx context.Context, opts *AppendableWriterOpts) (*Writer, int64, error) {
 	ctx, _ = startSpanWithBucket(ctx, o.c, o.bucket, "Object.WriterFromAppendableObject")
+	if o.keyWrapper != nil {
+		return nil, 0, errors.New("storage: envelope encryption cannot be used with appendable objects")
+	}
 	if o.gen < 0 {
 		return nil, 0, errors.New("storage: ObjectHandle.Generation must be set to use NewWriterFromAppendableObject")
 	}
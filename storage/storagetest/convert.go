@@ -0,0 +1,346 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	raw "google.golang.org/api/storage/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// This file converts between the JSON API representation of resources and
+// the storagepb representation in which the fake stores them.
+
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(field, s string) (*timestamppb.Timestamp, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q", field, s)
+	}
+	return timestamppb.New(t), nil
+}
+
+func encodeCRC32C(c uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], c)
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// toJSONObject returns the JSON API representation of an object. baseURL is
+// the URL of the HTTP server.
+func toJSONObject(o *storagepb.Object, baseURL string) *raw.Object {
+	bucket := bucketID(o.Bucket)
+	r := &raw.Object{
+		Kind:                    "storage#object",
+		Id:                      fmt.Sprintf("%s/%s/%d", bucket, o.Name, o.Generation),
+		SelfLink:                fmt.Sprintf("%s/storage/v1/b/%s/o/%s", baseURL, bucket, url.PathEscape(o.Name)),
+		MediaLink:               fmt.Sprintf("%s/download/storage/v1/b/%s/o/%s?generation=%d&alt=media", baseURL, bucket, url.PathEscape(o.Name), o.Generation),
+		Bucket:                  bucket,
+		Name:                    o.Name,
+		Generation:              o.Generation,
+		Metageneration:          o.Metageneration,
+		Etag:                    o.Etag,
+		Size:                    uint64(o.Size),
+		StorageClass:            o.StorageClass,
+		ContentType:             o.ContentType,
+		ContentEncoding:         o.ContentEncoding,
+		ContentDisposition:      o.ContentDisposition,
+		ContentLanguage:         o.ContentLanguage,
+		CacheControl:            o.CacheControl,
+		Metadata:                o.Metadata,
+		ComponentCount:          int64(o.ComponentCount),
+		KmsKeyName:              o.KmsKey,
+		EventBasedHold:          o.GetEventBasedHold(),
+		TemporaryHold:           o.TemporaryHold,
+		TimeCreated:             formatTime(o.CreateTime),
+		Updated:                 formatTime(o.UpdateTime),
+		TimeDeleted:             formatTime(o.DeleteTime),
+		TimeFinalized:           formatTime(o.FinalizeTime),
+		TimeStorageClassUpdated: formatTime(o.UpdateStorageClassTime),
+		CustomTime:              formatTime(o.CustomTime),
+		RetentionExpirationTime: formatTime(o.RetentionExpireTime),
+		SoftDeleteTime:          formatTime(o.SoftDeleteTime),
+		HardDeleteTime:          formatTime(o.HardDeleteTime),
+	}
+	if c := o.GetChecksums(); c != nil {
+		if c.Crc32C != nil {
+			r.Crc32c = encodeCRC32C(c.GetCrc32C())
+		}
+		if len(c.Md5Hash) > 0 {
+			r.Md5Hash = base64.StdEncoding.EncodeToString(c.Md5Hash)
+		}
+	}
+	for _, a := range o.Acl {
+		r.Acl = append(r.Acl, toJSONObjectACL(o, a))
+	}
+	return r
+}
+
+func toJSONObjectACL(o *storagepb.Object, a *storagepb.ObjectAccessControl) *raw.ObjectAccessControl {
+	return &raw.ObjectAccessControl{
+		Kind:       "storage#objectAccessControl",
+		Bucket:     bucketID(o.Bucket),
+		Object:     o.Name,
+		Generation: o.Generation,
+		Entity:     a.Entity,
+		Role:       a.Role,
+	}
+}
+
+// fromJSONObject returns the writable fields of an object from its JSON API
+// representation, along with the checksums it declares.
+func fromJSONObject(bucket string, r *raw.Object) (*storagepb.Object, *storagepb.ObjectChecksums, error) {
+	o := &storagepb.Object{
+		Bucket:             bucketResource(bucket),
+		Name:               r.Name,
+		StorageClass:       r.StorageClass,
+		ContentType:        r.ContentType,
+		ContentEncoding:    r.ContentEncoding,
+		ContentDisposition: r.ContentDisposition,
+		ContentLanguage:    r.ContentLanguage,
+		CacheControl:       r.CacheControl,
+		Metadata:           r.Metadata,
+		KmsKey:             r.KmsKeyName,
+		TemporaryHold:      r.TemporaryHold,
+	}
+	if r.EventBasedHold {
+		o.EventBasedHold = proto.Bool(true)
+	}
+	var err error
+	if o.CustomTime, err = parseTime("customTime", r.CustomTime); err != nil {
+		return nil, nil, err
+	}
+	var sums *storagepb.ObjectChecksums
+	if r.Crc32c != "" {
+		b, err := base64.StdEncoding.DecodeString(r.Crc32c)
+		if err != nil || len(b) != 4 {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "invalid crc32c %q", r.Crc32c)
+		}
+		sums = &storagepb.ObjectChecksums{Crc32C: proto.Uint32(binary.BigEndian.Uint32(b))}
+	}
+	if r.Md5Hash != "" {
+		b, err := base64.StdEncoding.DecodeString(r.Md5Hash)
+		if err != nil {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "invalid md5Hash %q", r.Md5Hash)
+		}
+		if sums == nil {
+			sums = &storagepb.ObjectChecksums{}
+		}
+		sums.Md5Hash = b
+	}
+	return o, sums, nil
+}
+
+// objectPatchFields maps the JSON names of the fields of an object that can
+// be patched to their storagepb names. Metadata is handled separately.
+var objectPatchFields = map[string]string{
+	"cacheControl":       "cache_control",
+	"contentDisposition": "content_disposition",
+	"contentEncoding":    "content_encoding",
+	"contentLanguage":    "content_language",
+	"contentType":        "content_type",
+	"customTime":         "custom_time",
+	"eventBasedHold":     "event_based_hold",
+	"temporaryHold":      "temporary_hold",
+}
+
+// bucketPatchFields is like objectPatchFields, for buckets. Labels are
+// handled separately.
+var bucketPatchFields = map[string]string{
+	"billing":               "billing",
+	"defaultEventBasedHold": "default_event_based_hold",
+	"retentionPolicy":       "retention_policy",
+	"rpo":                   "rpo",
+	"softDeletePolicy":      "soft_delete_policy",
+	"storageClass":          "storage_class",
+	"versioning":            "versioning",
+}
+
+// dropNullEntries deletes the entries of m that are null in the JSON object
+// v, since the JSON decoder stores them as empty strings.
+func dropNullEntries(v json.RawMessage, m map[string]string) {
+	var entries map[string]*string
+	if json.Unmarshal(v, &entries) != nil {
+		return
+	}
+	for k, e := range entries {
+		if e == nil {
+			delete(m, k)
+		}
+	}
+}
+
+// patchPaths returns the field mask paths set by a JSON patch request body,
+// given the body's top-level fields, the mapping of patchable JSON fields to
+// storagepb fields, and the name of a map field whose entries are patched
+// individually. Fields that are not patchable in this fake are ignored.
+func patchPaths(fields map[string]json.RawMessage, names map[string]string, mapField, mapPath string) ([]string, error) {
+	var paths []string
+	for k, v := range fields {
+		if p, ok := names[k]; ok {
+			paths = append(paths, p)
+			continue
+		}
+		if k != mapField {
+			continue
+		}
+		if string(v) == "null" {
+			paths = append(paths, mapPath)
+			continue
+		}
+		var entries map[string]*string
+		if err := json.Unmarshal(v, &entries); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", mapField, err)
+		}
+		for key := range entries {
+			paths = append(paths, mapPath+"."+key)
+		}
+	}
+	return paths, nil
+}
+
+// fromJSONObjectPatch returns the object and field mask paths for a JSON
+// API patch request body.
+func fromJSONObjectPatch(bucket string, body []byte) (*storagepb.Object, []string, error) {
+	var fields map[string]json.RawMessage
+	var r raw.Object
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	o, _, err := fromJSONObject(bucket, &r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := fields["eventBasedHold"]; ok {
+		o.EventBasedHold = proto.Bool(r.EventBasedHold)
+	}
+	dropNullEntries(fields["metadata"], o.Metadata)
+	paths, err := patchPaths(fields, objectPatchFields, "metadata", "metadata")
+	return o, paths, err
+}
+
+// toJSONBucket returns the JSON API representation of a bucket.
+func toJSONBucket(b *storagepb.Bucket, baseURL string) *raw.Bucket {
+	r := &raw.Bucket{
+		Kind:                  "storage#bucket",
+		Id:                    b.BucketId,
+		SelfLink:              fmt.Sprintf("%s/storage/v1/b/%s", baseURL, b.BucketId),
+		Name:                  b.BucketId,
+		Metageneration:        b.Metageneration,
+		Etag:                  b.Etag,
+		Location:              b.Location,
+		LocationType:          b.LocationType,
+		StorageClass:          b.StorageClass,
+		Rpo:                   b.Rpo,
+		Labels:                b.Labels,
+		DefaultEventBasedHold: b.DefaultEventBasedHold,
+		TimeCreated:           formatTime(b.CreateTime),
+		Updated:               formatTime(b.UpdateTime),
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(b.Project, "projects/"), 10, 64); err == nil {
+		r.ProjectNumber = n
+	}
+	if v := b.Versioning; v != nil {
+		r.Versioning = &raw.BucketVersioning{Enabled: v.Enabled}
+	}
+	if bl := b.Billing; bl != nil {
+		r.Billing = &raw.BucketBilling{RequesterPays: bl.RequesterPays}
+	}
+	if rp := b.RetentionPolicy; rp != nil {
+		r.RetentionPolicy = &raw.BucketRetentionPolicy{
+			EffectiveTime:   formatTime(rp.EffectiveTime),
+			IsLocked:        rp.IsLocked,
+			RetentionPeriod: int64(rp.GetRetentionDuration().AsDuration() / time.Second),
+		}
+	}
+	if sdp := b.SoftDeletePolicy; sdp != nil {
+		r.SoftDeletePolicy = &raw.BucketSoftDeletePolicy{
+			EffectiveTime:            formatTime(sdp.EffectiveTime),
+			RetentionDurationSeconds: int64(sdp.GetRetentionDuration().AsDuration() / time.Second),
+		}
+	}
+	return r
+}
+
+// fromJSONBucket returns the writable fields of a bucket from its JSON API
+// representation.
+func fromJSONBucket(r *raw.Bucket) *storagepb.Bucket {
+	b := &storagepb.Bucket{
+		Location:              r.Location,
+		StorageClass:          r.StorageClass,
+		Rpo:                   r.Rpo,
+		Labels:                r.Labels,
+		DefaultEventBasedHold: r.DefaultEventBasedHold,
+	}
+	if v := r.Versioning; v != nil {
+		b.Versioning = &storagepb.Bucket_Versioning{Enabled: v.Enabled}
+	}
+	if bl := r.Billing; bl != nil {
+		b.Billing = &storagepb.Bucket_Billing{RequesterPays: bl.RequesterPays}
+	}
+	if rp := r.RetentionPolicy; rp != nil {
+		b.RetentionPolicy = &storagepb.Bucket_RetentionPolicy{
+			RetentionDuration: durationpb.New(time.Duration(rp.RetentionPeriod) * time.Second),
+		}
+	}
+	if sdp := r.SoftDeletePolicy; sdp != nil {
+		b.SoftDeletePolicy = &storagepb.Bucket_SoftDeletePolicy{
+			RetentionDuration: durationpb.New(time.Duration(sdp.RetentionDurationSeconds) * time.Second),
+		}
+	}
+	return b
+}
+
+// fromJSONBucketPatch returns the bucket and field mask paths for a JSON API
+// patch request body.
+func fromJSONBucketPatch(body []byte) (*storagepb.Bucket, []string, error) {
+	var fields map[string]json.RawMessage
+	var r raw.Bucket
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	paths, err := patchPaths(fields, bucketPatchFields, "labels", "labels")
+	b := fromJSONBucket(&r)
+	dropNullEntries(fields["labels"], b.Labels)
+	return b, paths, err
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest_test
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/storagetest"
+)
+
+func ExampleNewServer() {
+	ctx := context.Background()
+	// Start a fake server running locally.
+	srv, err := storagetest.NewServer()
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer srv.Close()
+	// Populate the server directly.
+	srv.CreateBucket("my-bucket")
+	srv.WriteObject("my-bucket", "greeting.txt", []byte("hello"))
+
+	// Create a client that uses the JSON and XML APIs of the server.
+	client, err := storage.NewClient(ctx, srv.ClientOptions()...)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer client.Close()
+	r, err := client.Bucket("my-bucket").Object("greeting.txt").NewReader(ctx)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	_ = data // TODO: Use the data.
+}
+
+func ExampleServer_NewGRPCClient() {
+	ctx := context.Background()
+	srv, err := storagetest.NewServer()
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer srv.Close()
+	// Create a client that uses the gRPC API of the server. It shares its
+	// buckets and objects with clients of the JSON API.
+	client, err := srv.NewGRPCClient(ctx)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer client.Close()
+	_ = client // TODO: Use the client.
+}
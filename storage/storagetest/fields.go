@@ -0,0 +1,148 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"strings"
+
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// updatableObjectFields are the top-level fields of an object that can be
+// set by clients when it is written or updated.
+var updatableObjectFields = []string{
+	"acl",
+	"cache_control",
+	"content_disposition",
+	"content_encoding",
+	"content_language",
+	"content_type",
+	"contexts",
+	"custom_time",
+	"event_based_hold",
+	"metadata",
+	"retention",
+	"temporary_hold",
+}
+
+// updatableBucketFields are the top-level fields of a bucket that can be
+// updated by clients.
+var updatableBucketFields = []string{
+	"acl",
+	"autoclass",
+	"billing",
+	"cors",
+	"default_event_based_hold",
+	"default_object_acl",
+	"encryption",
+	"iam_config",
+	"labels",
+	"lifecycle",
+	"logging",
+	"retention_policy",
+	"rpo",
+	"soft_delete_policy",
+	"storage_class",
+	"versioning",
+	"website",
+}
+
+// hasObjectMetadata reports whether any of the user-settable metadata of an
+// object is set in resource.
+func hasObjectMetadata(resource *storagepb.Object) bool {
+	if resource == nil {
+		return false
+	}
+	m := resource.ProtoReflect()
+	for _, field := range updatableObjectFields {
+		if m.Has(m.Descriptor().Fields().ByName(protoreflect.Name(field))) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPath reports whether paths names field or one of its subfields.
+func hasPath(paths []string, field string) bool {
+	for _, p := range paths {
+		if p == field || strings.HasPrefix(p, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMask copies the fields named by the field mask paths from src to dst.
+// Each path must start with one of the allowed top-level fields. A path may
+// name a map entry, as in "metadata.key"; the entry is deleted if it is not
+// set in src. Values are shared between src and dst.
+func applyMask(dst, src proto.Message, paths []string, allowed []string) error {
+	if len(paths) == 0 {
+		return status.Error(codes.InvalidArgument, "update mask is required")
+	}
+	for _, p := range paths {
+		top, _, _ := strings.Cut(p, ".")
+		if !contains(allowed, top) {
+			return status.Errorf(codes.InvalidArgument, "field %q cannot be updated", p)
+		}
+		if err := copyField(dst.ProtoReflect(), src.ProtoReflect(), strings.Split(p, ".")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyField copies the field named by path from src to dst, clearing it in
+// dst if it is not set in src.
+func copyField(dst, src protoreflect.Message, path []string) error {
+	fd := dst.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil {
+		return status.Errorf(codes.InvalidArgument, "unknown field %q", strings.Join(path, "."))
+	}
+	rest := path[1:]
+	switch {
+	case len(rest) == 0:
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		} else {
+			dst.Clear(fd)
+		}
+	case fd.IsMap():
+		key := protoreflect.ValueOfString(strings.Join(rest, ".")).MapKey()
+		if m := src.Get(fd).Map(); m.Has(key) {
+			dst.Mutable(fd).Map().Set(key, m.Get(key))
+		} else if dst.Has(fd) {
+			dst.Mutable(fd).Map().Clear(key)
+		}
+	case fd.Message() != nil && !fd.IsList():
+		return copyField(dst.Mutable(fd).Message(), src.Get(fd).Message(), rest)
+	default:
+		return status.Errorf(codes.InvalidArgument, "invalid field path %q", strings.Join(path, "."))
+	}
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// globMatcher matches object names against a match glob, as used when
+// listing objects. See
+// https://cloud.google.com/storage/docs/json_api/v1/objects/list#list-object-glob.
+type globMatcher struct {
+	re *regexp.Regexp
+}
+
+// compileGlob compiles a match glob:
+//   - "*" matches any sequence of characters other than "/".
+//   - "**" matches any sequence of characters, including "/". "**/" also
+//     matches the empty string, so "a/**/b" matches "a/b".
+//   - "?" matches any single character other than "/".
+//   - "[abc]" and "[a-z]" match a character in the set, and "[!abc]" a
+//     character not in the set.
+//   - "{a,b}" matches any of the comma-separated literals.
+func compileGlob(glob string) (*globMatcher, error) {
+	invalid := func() error {
+		return status.Errorf(codes.InvalidArgument, "invalid match glob %q", glob)
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, invalid()
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			end := strings.IndexByte(glob[i+1:], '}')
+			if end < 0 {
+				return nil, invalid()
+			}
+			alts := strings.Split(glob[i+1:i+1+end], ",")
+			for j, a := range alts {
+				alts[j] = regexp.QuoteMeta(a)
+			}
+			sb.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, invalid()
+	}
+	return &globMatcher{re: re}, nil
+}
+
+func (g *globMatcher) match(name string) bool {
+	return g.re.MatchString(name)
+}
@@ -0,0 +1,1099 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	raw "google.golang.org/api/storage/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// maxBatchSize is the maximum number of requests in a batch request.
+const maxBatchSize = 100
+
+// httpHandler serves the JSON and XML APIs by translating requests into
+// calls to the gRPC service.
+type httpHandler struct {
+	svc *service
+}
+
+// httpStatus maps gRPC codes to the HTTP status codes used by the JSON API.
+var httpStatus = map[codes.Code]int{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.OutOfRange:         http.StatusRequestedRangeNotSatisfiable,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+}
+
+// writeError writes err as a JSON API error response.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, ok := httpStatus[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": st.Message(),
+			"errors":  []map[string]string{{"message": st.Message()}},
+		},
+	})
+}
+
+// writeJSON writes v as a successful JSON API response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// pathSegments splits the escaped path of u after prefix into unescaped
+// segments, so that object names may contain escaped slashes.
+func pathSegments(u *url.URL, prefix string) ([]string, error) {
+	p := strings.Trim(strings.TrimPrefix(u.EscapedPath(), prefix), "/")
+	if p == "" {
+		return nil, nil
+	}
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		var err error
+		if segs[i], err = url.PathUnescape(s); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid path %q", u.EscapedPath())
+		}
+	}
+	return segs, nil
+}
+
+// queryInt returns the value of an integer query parameter, or nil if it is
+// not set.
+func queryInt(q url.Values, name string) (*int64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q", name, v)
+	}
+	return &n, nil
+}
+
+// queryConds returns the preconditions in the query parameters. For the
+// source object of a copy, kind is "Source".
+func queryConds(q url.Values, kind string) (preconditions, error) {
+	var p preconditions
+	var err error
+	for _, f := range []struct {
+		name string
+		dst  **int64
+	}{
+		{"if" + kind + "GenerationMatch", &p.ifGenerationMatch},
+		{"if" + kind + "GenerationNotMatch", &p.ifGenerationNotMatch},
+		{"if" + kind + "MetagenerationMatch", &p.ifMetagenerationMatch},
+		{"if" + kind + "MetagenerationNotMatch", &p.ifMetagenerationNotMatch},
+	} {
+		if *f.dst, err = queryInt(q, f.name); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch p := r.URL.Path; {
+	case strings.HasPrefix(p, "/storage/v1/"):
+		err = h.serveJSON(w, r, "/storage/v1/")
+	case strings.HasPrefix(p, "/download/storage/v1/"):
+		err = h.serveJSON(w, r, "/download/storage/v1/")
+	case strings.HasPrefix(p, "/upload/storage/v1/"):
+		err = h.serveUpload(w, r)
+	case p == "/batch/storage/v1":
+		err = h.serveBatch(w, r)
+	default:
+		err = h.serveXML(w, r)
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// serveJSON serves the JSON API, other than uploads.
+func (h *httpHandler) serveJSON(w http.ResponseWriter, r *http.Request, prefix string) error {
+	segs, err := pathSegments(r.URL, prefix)
+	if err != nil {
+		return err
+	}
+	if len(segs) == 0 || segs[0] != "b" {
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	switch {
+	case len(segs) == 1:
+		return h.serveBuckets(w, r)
+	case len(segs) == 2:
+		return h.serveBucket(w, r, segs[1])
+	case len(segs) == 3 && segs[2] == "lockRetentionPolicy" && r.Method == http.MethodPost:
+		return h.lockRetentionPolicy(w, r, segs[1])
+	case len(segs) == 3 && segs[2] == "o" && r.Method == http.MethodGet:
+		return h.listObjects(w, r, segs[1])
+	case len(segs) == 4 && segs[2] == "o":
+		return h.serveObject(w, r, segs[1], segs[3])
+	case len(segs) == 5 && segs[2] == "o" && segs[4] == "acl" && r.Method == http.MethodGet:
+		return h.listObjectACL(w, r, segs[1], segs[3])
+	case len(segs) == 6 && segs[2] == "o" && segs[4] == "acl":
+		return h.serveObjectACL(w, r, segs[1], segs[3], segs[5])
+	case len(segs) == 5 && segs[2] == "o" && segs[4] == "compose" && r.Method == http.MethodPost:
+		return h.composeObject(w, r, segs[1], segs[3])
+	case len(segs) == 9 && segs[2] == "o" && (segs[4] == "rewriteTo" || segs[4] == "copyTo") && segs[5] == "b" && segs[7] == "o" && r.Method == http.MethodPost:
+		return h.rewriteObject(w, r, segs[1], segs[3], segs[6], segs[8], segs[4] == "rewriteTo")
+	case len(segs) == 7 && segs[2] == "o" && segs[4] == "moveTo" && segs[5] == "o" && r.Method == http.MethodPost:
+		return h.moveObject(w, r, segs[1], segs[3], segs[6])
+	case len(segs) == 5 && segs[2] == "o" && segs[4] == "restore" && r.Method == http.MethodPost:
+		return h.restoreObject(w, r, segs[1], segs[3])
+	}
+	return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+}
+
+func (h *httpHandler) listObjectACL(w http.ResponseWriter, r *http.Request, bucket, name string) error {
+	gen, err := queryInt(r.URL.Query(), "generation")
+	if err != nil {
+		return err
+	}
+	o, err := h.svc.GetObject(r.Context(), &storagepb.GetObjectRequest{
+		Bucket:     bucketResource(bucket),
+		Object:     name,
+		Generation: derefInt(gen),
+	})
+	if err != nil {
+		return err
+	}
+	out := &raw.ObjectAccessControls{Kind: "storage#objectAccessControls", Items: []*raw.ObjectAccessControl{}}
+	for _, a := range o.Acl {
+		out.Items = append(out.Items, toJSONObjectACL(o, a))
+	}
+	writeJSON(w, out)
+	return nil
+}
+
+// serveObjectACL gets, sets or deletes the ACL entry of an entity by updating
+// the ACL of the object.
+func (h *httpHandler) serveObjectACL(w http.ResponseWriter, r *http.Request, bucket, name, entity string) error {
+	gen, err := queryInt(r.URL.Query(), "generation")
+	if err != nil {
+		return err
+	}
+	o, err := h.svc.GetObject(r.Context(), &storagepb.GetObjectRequest{
+		Bucket:     bucketResource(bucket),
+		Object:     name,
+		Generation: derefInt(gen),
+	})
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(o.Acl, func(a *storagepb.ObjectAccessControl) bool { return a.Entity == entity })
+	var a *storagepb.ObjectAccessControl
+	switch r.Method {
+	case http.MethodGet:
+		if i < 0 {
+			return status.Errorf(codes.NotFound, "ACL entry for %q not found", entity)
+		}
+		writeJSON(w, toJSONObjectACL(o, o.Acl[i]))
+		return nil
+	case http.MethodPut, http.MethodPatch:
+		var in raw.ObjectAccessControl
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid ACL entry: %v", err)
+		}
+		a = &storagepb.ObjectAccessControl{Entity: entity, Role: in.Role}
+		if i < 0 {
+			o.Acl = append(o.Acl, a)
+		} else {
+			o.Acl[i] = a
+		}
+	case http.MethodDelete:
+		if i < 0 {
+			return status.Errorf(codes.NotFound, "ACL entry for %q not found", entity)
+		}
+		o.Acl = slices.Delete(o.Acl, i, i+1)
+	default:
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	res, err := h.svc.UpdateObject(r.Context(), &storagepb.UpdateObjectRequest{
+		Object:                o,
+		IfMetagenerationMatch: proto.Int64(o.Metageneration),
+		UpdateMask:            &fieldmaskpb.FieldMask{Paths: []string{"acl"}},
+	})
+	if err != nil {
+		return err
+	}
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	writeJSON(w, toJSONObjectACL(res, a))
+	return nil
+}
+
+// serveBatch serves a batch request of the JSON API by serving each of the
+// HTTP requests in its parts.
+func (h *httpHandler) serveBatch(w http.ResponseWriter, r *http.Request) error {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		return status.Errorf(codes.InvalidArgument, "batch request has content type %q, want multipart/mixed", r.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for n := 0; ; n++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "reading batch request: %v", err)
+		}
+		if n == maxBatchSize {
+			return status.Errorf(codes.InvalidArgument, "batch request has more than %d parts", maxBatchSize)
+		}
+		req, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "reading batch request part: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req.WithContext(r.Context()))
+		hdr := textproto.MIMEHeader{"Content-Type": {"application/http"}}
+		if id := part.Header.Get("Content-Id"); id != "" {
+			hdr.Set("Content-Id", "<response-"+strings.Trim(id, "<>")+">")
+		}
+		pw, err := mw.CreatePart(hdr)
+		if err != nil {
+			return err
+		}
+		if err := rec.Result().Write(pw); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	_, err = w.Write(body.Bytes())
+	return err
+}
+
+func (h *httpHandler) serveBuckets(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	project := "projects/" + q.Get("project")
+	switch r.Method {
+	case http.MethodGet:
+		pageSize, err := queryInt(q, "maxResults")
+		if err != nil {
+			return err
+		}
+		res, err := h.svc.ListBuckets(r.Context(), &storagepb.ListBucketsRequest{
+			Parent:    project,
+			Prefix:    q.Get("prefix"),
+			PageSize:  int32(derefInt(pageSize)),
+			PageToken: q.Get("pageToken"),
+		})
+		if err != nil {
+			return err
+		}
+		out := &raw.Buckets{Kind: "storage#buckets", NextPageToken: res.NextPageToken, Items: []*raw.Bucket{}}
+		for _, b := range res.Buckets {
+			out.Items = append(out.Items, toJSONBucket(b, h.svc.baseURL))
+		}
+		writeJSON(w, out)
+		return nil
+
+	case http.MethodPost:
+		var rb raw.Bucket
+		if err := json.NewDecoder(r.Body).Decode(&rb); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+		}
+		b := fromJSONBucket(&rb)
+		b.Project = project
+		res, err := h.svc.CreateBucket(r.Context(), &storagepb.CreateBucketRequest{
+			Parent:   globalProjectResource,
+			Bucket:   b,
+			BucketId: rb.Name,
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, toJSONBucket(res, h.svc.baseURL))
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+}
+
+func (h *httpHandler) serveBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	q := r.URL.Query()
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+	name := bucketResource(bucket)
+	var res *storagepb.Bucket
+	switch r.Method {
+	case http.MethodGet:
+		res, err = h.svc.GetBucket(r.Context(), &storagepb.GetBucketRequest{
+			Name:                     name,
+			IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+			IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+		})
+	case http.MethodPatch, http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		b, paths, err := fromJSONBucketPatch(body)
+		if err != nil {
+			return err
+		}
+		b.Name = name
+		res, err = h.svc.UpdateBucket(r.Context(), &storagepb.UpdateBucketRequest{
+			Bucket:                   b,
+			IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+			IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+			UpdateMask:               &fieldmaskpb.FieldMask{Paths: paths},
+		})
+		if err != nil {
+			return err
+		}
+	case http.MethodDelete:
+		if _, err := h.svc.DeleteBucket(r.Context(), &storagepb.DeleteBucketRequest{
+			Name:                     name,
+			IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+			IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+		}); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONBucket(res, h.svc.baseURL))
+	return nil
+}
+
+func (h *httpHandler) lockRetentionPolicy(w http.ResponseWriter, r *http.Request, bucket string) error {
+	metagen, err := queryInt(r.URL.Query(), "ifMetagenerationMatch")
+	if err != nil {
+		return err
+	}
+	res, err := h.svc.LockBucketRetentionPolicy(r.Context(), &storagepb.LockBucketRetentionPolicyRequest{
+		Bucket:                bucketResource(bucket),
+		IfMetagenerationMatch: derefInt(metagen),
+	})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONBucket(res, h.svc.baseURL))
+	return nil
+}
+
+func (h *httpHandler) listObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	q := r.URL.Query()
+	pageSize, err := queryInt(q, "maxResults")
+	if err != nil {
+		return err
+	}
+	res, err := h.svc.ListObjects(r.Context(), &storagepb.ListObjectsRequest{
+		Parent:                   bucketResource(bucket),
+		PageSize:                 int32(derefInt(pageSize)),
+		PageToken:                q.Get("pageToken"),
+		Delimiter:                q.Get("delimiter"),
+		IncludeTrailingDelimiter: q.Get("includeTrailingDelimiter") == "true",
+		Prefix:                   q.Get("prefix"),
+		Versions:                 q.Get("versions") == "true",
+		LexicographicStart:       q.Get("startOffset"),
+		LexicographicEnd:         q.Get("endOffset"),
+		SoftDeleted:              q.Get("softDeleted") == "true",
+		MatchGlob:                q.Get("matchGlob"),
+	})
+	if err != nil {
+		return err
+	}
+	out := &raw.Objects{
+		Kind:          "storage#objects",
+		NextPageToken: res.NextPageToken,
+		Prefixes:      res.Prefixes,
+		Items:         []*raw.Object{},
+	}
+	for _, o := range res.Objects {
+		out.Items = append(out.Items, toJSONObject(o, h.svc.baseURL))
+	}
+	writeJSON(w, out)
+	return nil
+}
+
+func (h *httpHandler) serveObject(w http.ResponseWriter, r *http.Request, bucket, name string) error {
+	q := r.URL.Query()
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+	gen, err := queryInt(q, "generation")
+	if err != nil {
+		return err
+	}
+	var res *storagepb.Object
+	switch r.Method {
+	case http.MethodGet:
+		if q.Get("alt") == "media" {
+			attrs, data, err := h.svc.readObject(bucket, name, derefInt(gen), conds)
+			if err != nil {
+				return err
+			}
+			return serveMedia(w, r, attrs, data)
+		}
+		res, err = h.svc.GetObject(r.Context(), &storagepb.GetObjectRequest{
+			Bucket:                   bucketResource(bucket),
+			Object:                   name,
+			Generation:               derefInt(gen),
+			SoftDeleted:              proto.Bool(q.Get("softDeleted") == "true"),
+			IfGenerationMatch:        conds.ifGenerationMatch,
+			IfGenerationNotMatch:     conds.ifGenerationNotMatch,
+			IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+			IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+		})
+	case http.MethodPatch, http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		o, paths, err := fromJSONObjectPatch(bucket, body)
+		if err != nil {
+			return err
+		}
+		o.Name = name
+		o.Generation = derefInt(gen)
+		res, err = h.svc.UpdateObject(r.Context(), &storagepb.UpdateObjectRequest{
+			Object:                   o,
+			IfGenerationMatch:        conds.ifGenerationMatch,
+			IfGenerationNotMatch:     conds.ifGenerationNotMatch,
+			IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+			IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+			UpdateMask:               &fieldmaskpb.FieldMask{Paths: paths},
+		})
+		if err != nil {
+			return err
+		}
+	case http.MethodDelete:
+		if _, err := h.svc.DeleteObject(r.Context(), &storagepb.DeleteObjectRequest{
+			Bucket:                   bucketResource(bucket),
+			Object:                   name,
+			Generation:               derefInt(gen),
+			IfGenerationMatch:        conds.ifGenerationMatch,
+			IfGenerationNotMatch:     conds.ifGenerationNotMatch,
+			IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+			IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+		}); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONObject(res, h.svc.baseURL))
+	return nil
+}
+
+func (h *httpHandler) composeObject(w http.ResponseWriter, r *http.Request, bucket, name string) error {
+	q := r.URL.Query()
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+	var req raw.ComposeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	dst := &raw.Object{}
+	if req.Destination != nil {
+		dst = req.Destination
+	}
+	dst.Name = name
+	dest, sums, err := fromJSONObject(bucket, dst)
+	if err != nil {
+		return err
+	}
+	creq := &storagepb.ComposeObjectRequest{
+		Destination:           dest,
+		IfGenerationMatch:     conds.ifGenerationMatch,
+		IfMetagenerationMatch: conds.ifMetagenerationMatch,
+		KmsKey:                q.Get("kmsKeyName"),
+		ObjectChecksums:       sums,
+	}
+	for _, src := range req.SourceObjects {
+		so := &storagepb.ComposeObjectRequest_SourceObject{Name: src.Name, Generation: src.Generation}
+		if pc := src.ObjectPreconditions; pc != nil {
+			so.ObjectPreconditions = &storagepb.ComposeObjectRequest_SourceObject_ObjectPreconditions{
+				IfGenerationMatch: proto.Int64(pc.IfGenerationMatch),
+			}
+		}
+		creq.SourceObjects = append(creq.SourceObjects, so)
+	}
+	res, err := h.svc.ComposeObject(r.Context(), creq)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONObject(res, h.svc.baseURL))
+	return nil
+}
+
+func (h *httpHandler) rewriteObject(w http.ResponseWriter, r *http.Request, srcBucket, srcName, dstBucket, dstName string, rewrite bool) error {
+	q := r.URL.Query()
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+	srcConds, err := queryConds(q, "Source")
+	if err != nil {
+		return err
+	}
+	srcGen, err := queryInt(q, "sourceGeneration")
+	if err != nil {
+		return err
+	}
+	var dst raw.Object
+	if err := json.NewDecoder(r.Body).Decode(&dst); err != nil && !errors.Is(err, io.EOF) {
+		return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	dst.Name = dstName
+	dest, _, err := fromJSONObject(dstBucket, &dst)
+	if err != nil {
+		return err
+	}
+	res, err := h.svc.RewriteObject(r.Context(), &storagepb.RewriteObjectRequest{
+		DestinationName:                dstName,
+		DestinationBucket:              bucketResource(dstBucket),
+		DestinationKmsKey:              q.Get("destinationKmsKeyName"),
+		Destination:                    dest,
+		SourceBucket:                   bucketResource(srcBucket),
+		SourceObject:                   srcName,
+		SourceGeneration:               derefInt(srcGen),
+		IfGenerationMatch:              conds.ifGenerationMatch,
+		IfGenerationNotMatch:           conds.ifGenerationNotMatch,
+		IfMetagenerationMatch:          conds.ifMetagenerationMatch,
+		IfMetagenerationNotMatch:       conds.ifMetagenerationNotMatch,
+		IfSourceGenerationMatch:        srcConds.ifGenerationMatch,
+		IfSourceGenerationNotMatch:     srcConds.ifGenerationNotMatch,
+		IfSourceMetagenerationMatch:    srcConds.ifMetagenerationMatch,
+		IfSourceMetagenerationNotMatch: srcConds.ifMetagenerationNotMatch,
+	})
+	if err != nil {
+		return err
+	}
+	obj := toJSONObject(res.Resource, h.svc.baseURL)
+	if !rewrite {
+		writeJSON(w, obj)
+		return nil
+	}
+	writeJSON(w, &raw.RewriteResponse{
+		Kind:                "storage#rewriteResponse",
+		TotalBytesRewritten: res.TotalBytesRewritten,
+		ObjectSize:          res.ObjectSize,
+		Done:                res.Done,
+		Resource:            obj,
+	})
+	return nil
+}
+
+func (h *httpHandler) moveObject(w http.ResponseWriter, r *http.Request, bucket, srcName, dstName string) error {
+	q := r.URL.Query()
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+	srcConds, err := queryConds(q, "Source")
+	if err != nil {
+		return err
+	}
+	res, err := h.svc.MoveObject(r.Context(), &storagepb.MoveObjectRequest{
+		Bucket:                         bucketResource(bucket),
+		SourceObject:                   srcName,
+		DestinationObject:              dstName,
+		IfGenerationMatch:              conds.ifGenerationMatch,
+		IfGenerationNotMatch:           conds.ifGenerationNotMatch,
+		IfMetagenerationMatch:          conds.ifMetagenerationMatch,
+		IfMetagenerationNotMatch:       conds.ifMetagenerationNotMatch,
+		IfSourceGenerationMatch:        srcConds.ifGenerationMatch,
+		IfSourceGenerationNotMatch:     srcConds.ifGenerationNotMatch,
+		IfSourceMetagenerationMatch:    srcConds.ifMetagenerationMatch,
+		IfSourceMetagenerationNotMatch: srcConds.ifMetagenerationNotMatch,
+	})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONObject(res, h.svc.baseURL))
+	return nil
+}
+
+func (h *httpHandler) restoreObject(w http.ResponseWriter, r *http.Request, bucket, name string) error {
+	q := r.URL.Query()
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+	gen, err := queryInt(q, "generation")
+	if err != nil {
+		return err
+	}
+	res, err := h.svc.RestoreObject(r.Context(), &storagepb.RestoreObjectRequest{
+		Bucket:                   bucketResource(bucket),
+		Object:                   name,
+		Generation:               derefInt(gen),
+		CopySourceAcl:            proto.Bool(q.Get("copySourceAcl") == "true"),
+		IfGenerationMatch:        conds.ifGenerationMatch,
+		IfGenerationNotMatch:     conds.ifGenerationNotMatch,
+		IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+		IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+	})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONObject(res, h.svc.baseURL))
+	return nil
+}
+
+// serveUpload serves the JSON API upload endpoint, for simple, multipart and
+// resumable uploads.
+func (h *httpHandler) serveUpload(w http.ResponseWriter, r *http.Request) error {
+	segs, err := pathSegments(r.URL, "/upload/storage/v1/")
+	if err != nil {
+		return err
+	}
+	if len(segs) != 3 || segs[0] != "b" || segs[2] != "o" {
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	bucket := segs[1]
+	q := r.URL.Query()
+	if id := q.Get("upload_id"); id != "" {
+		return h.serveUploadSession(w, r, id)
+	}
+	if r.Method != http.MethodPost {
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	conds, err := queryConds(q, "")
+	if err != nil {
+		return err
+	}
+
+	var (
+		meta        raw.Object
+		contentType string
+		data        []byte
+	)
+	switch q.Get("uploadType") {
+	case "media":
+		contentType = r.Header.Get("Content-Type")
+		if data, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+	case "multipart":
+		if meta, contentType, data, err = readMultipart(r); err != nil {
+			return err
+		}
+	case "resumable":
+		if err := json.NewDecoder(r.Body).Decode(&meta); err != nil && !errors.Is(err, io.EOF) {
+			return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+		}
+		contentType = r.Header.Get("X-Upload-Content-Type")
+	default:
+		return status.Errorf(codes.InvalidArgument, "invalid uploadType %q", q.Get("uploadType"))
+	}
+	if n := q.Get("name"); n != "" {
+		meta.Name = n
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = contentType
+	}
+	if k := q.Get("kmsKeyName"); k != "" {
+		meta.KmsKeyName = k
+	}
+	resource, sums, err := fromJSONObject(bucket, &meta)
+	if err != nil {
+		return err
+	}
+	spec := &storagepb.WriteObjectSpec{
+		Resource:                 resource,
+		IfGenerationMatch:        conds.ifGenerationMatch,
+		IfGenerationNotMatch:     conds.ifGenerationNotMatch,
+		IfMetagenerationMatch:    conds.ifMetagenerationMatch,
+		IfMetagenerationNotMatch: conds.ifMetagenerationNotMatch,
+	}
+	if q.Get("uploadType") == "resumable" {
+		id, err := h.svc.startUpload(spec, sums)
+		if err != nil {
+			return err
+		}
+		v := url.Values{"uploadType": {"resumable"}, "upload_id": {id}}
+		w.Header().Set("Location", fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", h.svc.baseURL, url.PathEscape(bucket), v.Encode()))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	if resource.GetName() == "" {
+		return status.Error(codes.InvalidArgument, "object name is required")
+	}
+	res, err := h.svc.insertObject(spec, data, sums)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, toJSONObject(res, h.svc.baseURL))
+	return nil
+}
+
+// readMultipart reads a multipart/related upload, which consists of the
+// object's metadata followed by its content.
+func readMultipart(r *http.Request) (meta raw.Object, contentType string, data []byte, err error) {
+	mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		return meta, "", nil, status.Errorf(codes.InvalidArgument, "invalid multipart Content-Type %q", r.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		return meta, "", nil, status.Errorf(codes.InvalidArgument, "reading metadata part: %v", err)
+	}
+	if err := json.NewDecoder(part).Decode(&meta); err != nil {
+		return meta, "", nil, status.Errorf(codes.InvalidArgument, "invalid metadata part: %v", err)
+	}
+	part, err = mr.NextPart()
+	if err != nil {
+		return meta, "", nil, status.Errorf(codes.InvalidArgument, "reading media part: %v", err)
+	}
+	if data, err = io.ReadAll(part); err != nil {
+		return meta, "", nil, err
+	}
+	return meta, part.Header.Get("Content-Type"), data, nil
+}
+
+// serveUploadSession serves requests to the session URI of a resumable
+// upload.
+func (h *httpHandler) serveUploadSession(w http.ResponseWriter, r *http.Request, id string) error {
+	if r.Method == http.MethodDelete {
+		if err := h.svc.cancelUpload(id); err != nil {
+			return err
+		}
+		// The service responds to a cancellation with the non-standard status
+		// 499.
+		w.WriteHeader(499)
+		return nil
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		return status.Errorf(codes.Unimplemented, "storagetest: %s %s is not supported", r.Method, r.URL.Path)
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var (
+		persisted int64
+		obj       *storagepb.Object
+	)
+	offset, total, err := parseContentRange(r.Header.Get("Content-Range"), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		// A status query.
+		persisted, obj, err = h.svc.uploadStatus(id)
+	} else {
+		finish := total >= 0 && offset+int64(len(data)) == total
+		var sums *storagepb.ObjectChecksums
+		if finish {
+			if sums, err = parseHashHeader(r.Header.Get("X-Goog-Hash")); err != nil {
+				return err
+			}
+		}
+		persisted, obj, err = h.svc.writeUpload(id, offset, data, finish, sums)
+	}
+	if err != nil {
+		return err
+	}
+	if obj != nil {
+		writeJSON(w, toJSONObject(obj, h.svc.baseURL))
+		return nil
+	}
+	if persisted > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", persisted-1))
+	}
+	// Clients that cannot handle 308 responses ask for 200 instead.
+	if r.Header.Get("X-GUploader-No-308") == "yes" {
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+	return nil
+}
+
+// parseContentRange parses the Content-Range header of a resumable upload
+// request with n bytes of data. It returns the offset of the data, or -1 for
+// a status query, and the total size of the object, or -1 if it is not yet
+// known. A missing header means that the request holds the whole object.
+func parseContentRange(cr string, n int64) (offset, total int64, err error) {
+	if cr == "" {
+		return 0, n, nil
+	}
+	invalid := status.Errorf(codes.InvalidArgument, "invalid Content-Range %q", cr)
+	rng, ok := strings.CutPrefix(cr, "bytes ")
+	if !ok {
+		return 0, 0, invalid
+	}
+	rng, size, ok := strings.Cut(rng, "/")
+	if !ok {
+		return 0, 0, invalid
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, invalid
+		}
+	}
+	if rng == "*" {
+		if total < 0 {
+			return -1, -1, nil
+		}
+		// An empty final request, which completes the upload at its size.
+		return total, total, nil
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+	if offset, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, invalid
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end-offset+1 != n {
+		return 0, 0, invalid
+	}
+	return offset, total, nil
+}
+
+// parseHashHeader parses an X-Goog-Hash header, such as
+// "crc32c=n03x6A==,md5=Ojk9c3dhfxgoKVVHYwFbHQ==".
+func parseHashHeader(h string) (*storagepb.ObjectChecksums, error) {
+	if h == "" {
+		return nil, nil
+	}
+	sums := &storagepb.ObjectChecksums{}
+	for _, kv := range strings.Split(h, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid X-Goog-Hash %q", h)
+		}
+		switch k {
+		case "crc32c":
+			if len(b) != 4 {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid X-Goog-Hash %q", h)
+			}
+			sums.Crc32C = proto.Uint32(binary.BigEndian.Uint32(b))
+		case "md5":
+			sums.Md5Hash = b
+		}
+	}
+	return sums, nil
+}
+
+// serveXML serves object reads and multipart uploads with the XML API.
+func (h *httpHandler) serveXML(w http.ResponseWriter, r *http.Request) error {
+	segs, err := pathSegments(r.URL, "/")
+	if err != nil {
+		return err
+	}
+	if len(segs) == 0 {
+		return status.Errorf(codes.Unimplemented, "storagetest: XML API %s %s is not supported", r.Method, r.URL.Path)
+	}
+	bucket, name := segs[0], strings.Join(segs[1:], "/")
+	if ok, err := h.serveMultipart(w, r, bucket, name); ok {
+		return err
+	}
+	if name == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return status.Errorf(codes.Unimplemented, "storagetest: XML API %s %s is not supported", r.Method, r.URL.Path)
+	}
+	gen, err := queryInt(r.URL.Query(), "generation")
+	if err != nil {
+		return err
+	}
+	var conds preconditions
+	for _, f := range []struct {
+		header string
+		dst    **int64
+	}{
+		{"X-Goog-If-Generation-Match", &conds.ifGenerationMatch},
+		{"X-Goog-If-Metageneration-Match", &conds.ifMetagenerationMatch},
+	} {
+		if v := r.Header.Get(f.header); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid %s %q", f.header, v)
+			}
+			*f.dst = &n
+		}
+	}
+	attrs, data, err := h.svc.readObject(bucket, name, derefInt(gen), conds)
+	if err != nil {
+		return err
+	}
+	return serveMedia(w, r, attrs, data)
+}
+
+// serveMedia writes the content of an object, or the range of it named by
+// the request's Range header, with the headers that describe the object.
+func serveMedia(w http.ResponseWriter, r *http.Request, attrs *storagepb.Object, data []byte) error {
+	size := int64(len(data))
+	start, end := int64(0), size
+	partial := false
+	if rng := r.Header.Get("Range"); rng != "" {
+		var err error
+		if start, end, err = parseRange(rng, size); err != nil {
+			return err
+		}
+		partial = true
+	}
+
+	hdr := w.Header()
+	hdr.Set("X-Goog-Generation", strconv.FormatInt(attrs.Generation, 10))
+	hdr.Set("X-Goog-Metageneration", strconv.FormatInt(attrs.Metageneration, 10))
+	hdr.Set("X-Goog-Stored-Content-Length", strconv.FormatInt(size, 10))
+	hdr.Set("Last-Modified", attrs.GetUpdateTime().AsTime().UTC().Format(http.TimeFormat))
+	hdr.Set("ETag", attrs.Etag)
+	for k, v := range map[string]string{
+		"Content-Type":        attrs.ContentType,
+		"Content-Encoding":    attrs.ContentEncoding,
+		"Content-Disposition": attrs.ContentDisposition,
+		"Content-Language":    attrs.ContentLanguage,
+		"Cache-Control":       attrs.CacheControl,
+	} {
+		if v != "" {
+			hdr.Set(k, v)
+		}
+	}
+	if hdr.Get("Content-Type") == "" {
+		hdr.Set("Content-Type", "application/octet-stream")
+	}
+	for k, v := range attrs.Metadata {
+		hdr["X-Goog-Meta-"+k] = []string{v}
+	}
+	if !partial {
+		if c := attrs.GetChecksums(); c != nil {
+			hashes := []string{}
+			if c.Crc32C != nil {
+				hashes = append(hashes, "crc32c="+encodeCRC32C(c.GetCrc32C()))
+			}
+			if len(c.Md5Hash) > 0 {
+				hashes = append(hashes, "md5="+base64.StdEncoding.EncodeToString(c.Md5Hash))
+			}
+			hdr.Set("X-Goog-Hash", strings.Join(hashes, ","))
+		}
+	}
+	hdr.Set("Content-Length", strconv.FormatInt(end-start, 10))
+	if partial {
+		hdr.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	if r.Method != http.MethodHead {
+		w.Write(data[start:end])
+	}
+	return nil
+}
+
+// parseRange parses the Range header of a read of an object of the given
+// size, in one of the forms "bytes=N-", "bytes=N-M" or "bytes=-N", and
+// returns the bounds [start, end) of the read.
+func parseRange(rng string, size int64) (start, end int64, err error) {
+	invalid := status.Errorf(codes.InvalidArgument, "invalid Range %q", rng)
+	spec, ok := strings.CutPrefix(rng, "bytes=")
+	if !ok {
+		return 0, 0, invalid
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return 0, 0, invalid
+		}
+		return readRange(size, -n, 0)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, invalid
+	}
+	if start >= size && size > 0 {
+		return 0, 0, status.Errorf(codes.OutOfRange, "range %q is not satisfiable for an object of size %d", rng, size)
+	}
+	var length int64
+	if last != "" {
+		end, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, invalid
+		}
+		length = end - start + 1
+	}
+	return readRange(size, start, length)
+}
+
+func derefInt(p *int64) int64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
@@ -0,0 +1,416 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// minPartSize is the minimum size of each part of a multipart upload but
+	// the last.
+	minPartSize = 5 << 20
+	// maxPartNumber is the maximum part number of a multipart upload.
+	maxPartNumber = 10000
+	// defaultMaxParts is the maximum number of parts or uploads in a page of
+	// a listing.
+	defaultMaxParts = 1000
+	xmlNamespace    = "http://s3.amazonaws.com/doc/2006-03-01/"
+)
+
+// multipartUpload is an incomplete XML API multipart upload.
+type multipartUpload struct {
+	id        string
+	attrs     *storagepb.Object // the attributes of the object to create
+	initiated time.Time
+	parts     map[int]*uploadPart
+}
+
+type uploadPart struct {
+	data     []byte
+	etag     string
+	modified time.Time
+}
+
+// partETag returns the ETag of a part, the quoted hex MD5 hash of its data.
+func partETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// multipartUploads returns the incomplete multipart uploads of a bucket in
+// name and upload ID order.
+func (s *service) multipartUploads(bucketName string) ([]*multipartUpload, error) {
+	if _, err := s.bucket(bucketResource(bucketName)); err != nil {
+		return nil, err
+	}
+	var ups []*multipartUpload
+	for _, u := range s.mpus {
+		if u.attrs.Bucket == bucketName {
+			ups = append(ups, u)
+		}
+	}
+	sort.Slice(ups, func(i, j int) bool {
+		if ups[i].attrs.Name != ups[j].attrs.Name {
+			return ups[i].attrs.Name < ups[j].attrs.Name
+		}
+		return ups[i].id < ups[j].id
+	})
+	return ups, nil
+}
+
+// multipartUpload returns the multipart upload with the given ID of an
+// object.
+func (s *service) multipartUpload(bucketName, name, id string) (*multipartUpload, error) {
+	u, ok := s.mpus[id]
+	if !ok || u.attrs.Bucket != bucketName || u.attrs.Name != name {
+		return nil, status.Errorf(codes.NotFound, "multipart upload %q of %q not found", id, name)
+	}
+	return u, nil
+}
+
+// serveMultipart serves the requests of XML API multipart uploads. It
+// reports false if r is not such a request.
+func (h *httpHandler) serveMultipart(w http.ResponseWriter, r *http.Request, bucket, name string) (bool, error) {
+	q := r.URL.Query()
+	_, uploads := q["uploads"]
+	id := q.Get("uploadId")
+	switch {
+	case name == "" && uploads && r.Method == http.MethodGet:
+		return true, h.listMultipartUploads(w, r, bucket)
+	case name == "":
+		return false, nil
+	case uploads && r.Method == http.MethodPost:
+		return true, h.initiateMultipartUpload(w, r, bucket, name)
+	case id == "":
+		return false, nil
+	case r.Method == http.MethodPut:
+		return true, h.uploadPart(w, r, bucket, name, id)
+	case r.Method == http.MethodGet:
+		return true, h.listParts(w, r, bucket, name, id)
+	case r.Method == http.MethodPost:
+		return true, h.completeMultipartUpload(w, r, bucket, name, id)
+	case r.Method == http.MethodDelete:
+		return true, h.abortMultipartUpload(w, bucket, name, id)
+	}
+	return false, nil
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (h *httpHandler) initiateMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, name string) error {
+	attrs := &storagepb.Object{
+		Bucket:             bucket,
+		Name:               name,
+		ContentType:        r.Header.Get("Content-Type"),
+		ContentEncoding:    r.Header.Get("Content-Encoding"),
+		ContentDisposition: r.Header.Get("Content-Disposition"),
+		ContentLanguage:    r.Header.Get("Content-Language"),
+		CacheControl:       r.Header.Get("Cache-Control"),
+		StorageClass:       r.Header.Get("X-Goog-Storage-Class"),
+		KmsKey:             r.Header.Get("X-Goog-Encryption-Kms-Key-Name"),
+	}
+	for k, v := range r.Header {
+		if key, ok := strings.CutPrefix(k, "X-Goog-Meta-"); ok {
+			if attrs.Metadata == nil {
+				attrs.Metadata = map[string]string{}
+			}
+			attrs.Metadata[strings.ToLower(key)] = v[0]
+		}
+	}
+
+	s := h.svc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.bucket(bucketResource(bucket)); err != nil {
+		return err
+	}
+	s.nextID++
+	u := &multipartUpload{
+		id:        "mpu-" + strconv.Itoa(s.nextID),
+		attrs:     attrs,
+		initiated: s.now(),
+		parts:     map[int]*uploadPart{},
+	}
+	s.mpus[u.id] = u
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string
+		Key      string
+		UploadID string `xml:"UploadId"`
+	}{Xmlns: xmlNamespace, Bucket: bucket, Key: name, UploadID: u.id})
+	return nil
+}
+
+func (h *httpHandler) uploadPart(w http.ResponseWriter, r *http.Request, bucket, name, id string) error {
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return status.Errorf(codes.InvalidArgument, "invalid part number %q", r.URL.Query().Get("partNumber"))
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	sums, err := parseHashHeader(r.Header.Get("X-Goog-Hash"))
+	if err != nil {
+		return err
+	}
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		if sums == nil {
+			sums = &storagepb.ObjectChecksums{}
+		}
+		if sums.Md5Hash, err = base64.StdEncoding.DecodeString(v); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid Content-MD5 %q", v)
+		}
+	}
+	if err := validateChecksums(data, sums); err != nil {
+		return err
+	}
+
+	s := h.svc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.multipartUpload(bucket, name, id)
+	if err != nil {
+		return err
+	}
+	p := &uploadPart{data: data, etag: partETag(data), modified: s.now()}
+	u.parts[n] = p
+	w.Header().Set("ETag", p.etag)
+	return nil
+}
+
+type xmlPart struct {
+	PartNumber   int
+	LastModified string `xml:",omitempty"`
+	ETag         string
+	Size         int64 `xml:",omitempty"`
+}
+
+func (h *httpHandler) listParts(w http.ResponseWriter, r *http.Request, bucket, name, id string) error {
+	q := r.URL.Query()
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+	maxParts, _ := strconv.Atoi(q.Get("max-parts"))
+	if maxParts <= 0 || maxParts > defaultMaxParts {
+		maxParts = defaultMaxParts
+	}
+
+	s := h.svc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.multipartUpload(bucket, name, id)
+	if err != nil {
+		return err
+	}
+	var numbers []int
+	for n := range u.parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	res := struct {
+		XMLName              xml.Name `xml:"ListPartsResult"`
+		Xmlns                string   `xml:"xmlns,attr"`
+		Bucket               string
+		Key                  string
+		UploadID             string `xml:"UploadId"`
+		PartNumberMarker     int
+		NextPartNumberMarker int
+		MaxParts             int
+		IsTruncated          bool
+		Parts                []xmlPart `xml:"Part"`
+	}{Xmlns: xmlNamespace, Bucket: bucket, Key: name, UploadID: id, PartNumberMarker: marker, MaxParts: maxParts}
+	if len(numbers) > maxParts {
+		numbers = numbers[:maxParts]
+		res.IsTruncated = true
+		res.NextPartNumberMarker = numbers[len(numbers)-1]
+	}
+	for _, n := range numbers {
+		p := u.parts[n]
+		res.Parts = append(res.Parts, xmlPart{
+			PartNumber:   n,
+			LastModified: p.modified.UTC().Format(time.RFC3339Nano),
+			ETag:         p.etag,
+			Size:         int64(len(p.data)),
+		})
+	}
+	writeXML(w, res)
+	return nil
+}
+
+func (h *httpHandler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, name, id string) error {
+	var req struct {
+		Parts []xmlPart `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid CompleteMultipartUpload request: %v", err)
+	}
+	if len(req.Parts) == 0 {
+		return status.Error(codes.InvalidArgument, "a multipart upload must be completed with at least one part")
+	}
+	var conds preconditions
+	if v := r.Header.Get("X-Goog-If-Generation-Match"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid X-Goog-If-Generation-Match %q", v)
+		}
+		conds.ifGenerationMatch = &n
+	}
+
+	s := h.svc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.multipartUpload(bucket, name, id)
+	if err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	for i, rp := range req.Parts {
+		if i > 0 && rp.PartNumber <= req.Parts[i-1].PartNumber {
+			return status.Error(codes.InvalidArgument, "the parts of a multipart upload must be in ascending order")
+		}
+		p, ok := u.parts[rp.PartNumber]
+		if !ok || p.etag != rp.ETag {
+			return status.Errorf(codes.InvalidArgument, "part %d was not uploaded or has a different ETag", rp.PartNumber)
+		}
+		if i < len(req.Parts)-1 && len(p.data) < minPartSize {
+			return status.Errorf(codes.InvalidArgument, "part %d is smaller than the minimum part size of %d bytes", rp.PartNumber, minPartSize)
+		}
+		data.Write(p.data)
+	}
+	b, err := s.bucket(bucketResource(bucket))
+	if err != nil {
+		return err
+	}
+	attrs := newObjectAttrs(u.attrs)
+	// Like composite objects, objects made of parts have no MD5 hash.
+	attrs.ComponentCount = int32(len(req.Parts))
+	o, err := s.create(b, attrs, data.Bytes(), conds, true)
+	if err != nil {
+		return err
+	}
+	delete(s.mpus, id)
+
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.attrs.Generation, 10))
+	w.Header().Set("X-Goog-Metageneration", strconv.FormatInt(o.attrs.Metageneration, 10))
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string
+		Bucket   string
+		Key      string
+		ETag     string
+	}{
+		Xmlns:    xmlNamespace,
+		Location: h.svc.baseURL + "/" + bucket + "/" + url.PathEscape(name),
+		Bucket:   bucket,
+		Key:      name,
+		ETag:     o.attrs.Etag,
+	})
+	return nil
+}
+
+func (h *httpHandler) abortMultipartUpload(w http.ResponseWriter, bucket, name, id string) error {
+	s := h.svc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.multipartUpload(bucket, name, id); err != nil {
+		return err
+	}
+	delete(s.mpus, id)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *httpHandler) listMultipartUploads(w http.ResponseWriter, r *http.Request, bucket string) error {
+	q := r.URL.Query()
+	prefix, keyMarker, idMarker := q.Get("prefix"), q.Get("key-marker"), q.Get("upload-id-marker")
+	maxUploads, _ := strconv.Atoi(q.Get("max-uploads"))
+	if maxUploads <= 0 || maxUploads > defaultMaxParts {
+		maxUploads = defaultMaxParts
+	}
+
+	s := h.svc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ups, err := s.multipartUploads(bucket)
+	if err != nil {
+		return err
+	}
+	type xmlUpload struct {
+		Key          string
+		UploadID     string `xml:"UploadId"`
+		StorageClass string
+		Initiated    string
+	}
+	res := struct {
+		XMLName            xml.Name `xml:"ListMultipartUploadsResult"`
+		Xmlns              string   `xml:"xmlns,attr"`
+		Bucket             string
+		KeyMarker          string
+		UploadIDMarker     string `xml:"UploadIdMarker"`
+		NextKeyMarker      string `xml:",omitempty"`
+		NextUploadIDMarker string `xml:"NextUploadIdMarker,omitempty"`
+		Prefix             string
+		MaxUploads         int
+		IsTruncated        bool
+		Uploads            []xmlUpload `xml:"Upload"`
+	}{Xmlns: xmlNamespace, Bucket: bucket, KeyMarker: keyMarker, UploadIDMarker: idMarker, Prefix: prefix, MaxUploads: maxUploads}
+	for _, u := range ups {
+		name := u.attrs.Name
+		if !strings.HasPrefix(name, prefix) || name < keyMarker || (name == keyMarker && u.id <= idMarker) {
+			continue
+		}
+		if len(res.Uploads) == maxUploads {
+			last := res.Uploads[len(res.Uploads)-1]
+			res.IsTruncated = true
+			res.NextKeyMarker, res.NextUploadIDMarker = last.Key, last.UploadID
+			break
+		}
+		storageClass := u.attrs.StorageClass
+		if storageClass == "" {
+			storageClass = s.buckets[bucketID(bucket)].attrs.StorageClass
+		}
+		res.Uploads = append(res.Uploads, xmlUpload{
+			Key:          name,
+			UploadID:     u.id,
+			StorageClass: storageClass,
+			Initiated:    u.initiated.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	writeXML(w, res)
+	return nil
+}
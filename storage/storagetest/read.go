@@ -0,0 +1,175 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxReadChunk is the maximum amount of object data in a read response, as
+// for the service.
+const maxReadChunk = 2 << 20
+
+// readRange returns the bounds [start, end) of a read of length bytes at
+// offset in an object of the given size. A negative offset is relative to
+// the end of the object, and a length of zero reads to the end.
+func readRange(size, offset, length int64) (start, end int64, err error) {
+	if length < 0 {
+		return 0, 0, status.Errorf(codes.OutOfRange, "negative read length %d", length)
+	}
+	start = offset
+	if offset < 0 {
+		start = max(0, size+offset)
+	}
+	if start > size {
+		return 0, 0, status.Errorf(codes.OutOfRange, "read offset %d is beyond the object size %d", offset, size)
+	}
+	end = size
+	if length > 0 {
+		end = min(size, start+length)
+	}
+	return start, end, nil
+}
+
+func checksummedData(data []byte) *storagepb.ChecksummedData {
+	return &storagepb.ChecksummedData{
+		Content: data,
+		Crc32C:  proto.Uint32(crc32.Checksum(data, crc32cTable)),
+	}
+}
+
+// ReadObject streams the content of an object.
+func (s *service) ReadObject(req *storagepb.ReadObjectRequest, stream storagepb.Storage_ReadObjectServer) error {
+	attrs, data, err := s.readObject(req.GetBucket(), req.GetObject(), req.GetGeneration(), preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	})
+	if err != nil {
+		return err
+	}
+	start, end, err := readRange(attrs.Size, req.GetReadOffset(), req.GetReadLimit())
+	if err != nil {
+		return err
+	}
+	res := &storagepb.ReadObjectResponse{
+		Metadata:        attrs,
+		ObjectChecksums: attrs.Checksums,
+	}
+	if start > 0 || end < attrs.Size {
+		res.ContentRange = &storagepb.ContentRange{Start: start, End: end, CompleteLength: attrs.Size}
+	}
+	// The first response carries the metadata, even for an empty read.
+	for first := true; first || start < end; first = false {
+		n := min(end-start, maxReadChunk)
+		if n > 0 {
+			res.ChecksummedData = checksummedData(data[start : start+n])
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+		start += n
+		res = &storagepb.ReadObjectResponse{}
+	}
+	return nil
+}
+
+// BidiReadObject serves ranges of an object's content as they are
+// requested on the stream.
+func (s *service) BidiReadObject(stream storagepb.Storage_BidiReadObjectServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	spec := req.GetReadObjectSpec()
+	if spec == nil {
+		return status.Error(codes.InvalidArgument, "the first message must include a read_object_spec")
+	}
+	attrs, data, err := s.readObject(spec.GetBucket(), spec.GetObject(), spec.GetGeneration(), preconditions{
+		ifGenerationMatch:        spec.IfGenerationMatch,
+		ifGenerationNotMatch:     spec.IfGenerationNotMatch,
+		ifMetagenerationMatch:    spec.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: spec.IfMetagenerationNotMatch,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The first response carries the metadata and a read handle, which this
+	// fake accepts but does not use.
+	first := &storagepb.BidiReadObjectResponse{
+		Metadata:   attrs,
+		ReadHandle: &storagepb.BidiReadHandle{Handle: []byte(attrs.Name)},
+	}
+	for {
+		if err := sendRanges(stream, first, data, req.GetReadRanges()); err != nil {
+			return err
+		}
+		first = nil
+		req, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sendRanges sends the requested ranges of data on the stream. If first is
+// not nil, it is sent first, with the beginning of the first range.
+func sendRanges(stream storagepb.Storage_BidiReadObjectServer, first *storagepb.BidiReadObjectResponse, data []byte, ranges []*storagepb.ReadRange) error {
+	res := first
+	if res == nil && len(ranges) == 0 {
+		return nil
+	}
+	if res == nil {
+		res = &storagepb.BidiReadObjectResponse{}
+	}
+	for _, rr := range ranges {
+		start, end, err := readRange(int64(len(data)), rr.GetReadOffset(), rr.GetReadLength())
+		if err != nil {
+			return err
+		}
+		for {
+			n := min(end-start, maxReadChunk)
+			res.ObjectDataRanges = append(res.ObjectDataRanges, &storagepb.ObjectRangeData{
+				ChecksummedData: checksummedData(data[start : start+n]),
+				ReadRange:       &storagepb.ReadRange{ReadOffset: start, ReadLength: n, ReadId: rr.GetReadId()},
+				RangeEnd:        start+n == end,
+			})
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+			res = &storagepb.BidiReadObjectResponse{}
+			start += n
+			if start == end {
+				break
+			}
+		}
+	}
+	if first != nil && len(ranges) == 0 {
+		return stream.Send(first)
+	}
+	return nil
+}
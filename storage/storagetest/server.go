@@ -0,0 +1,208 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storagetest provides a fake Cloud Storage service for testing. It
+// keeps all buckets and objects in memory, and serves both the JSON and XML
+// APIs over HTTP and the gRPC API, so that a real [storage.Client] created
+// with either [storage.NewClient] or [storage.NewGRPCClient] can be used
+// against it without credentials or network access.
+//
+// The fake implements a simplified form of the service, suitable for unit
+// tests. It supports buckets, objects and their generations, versioning,
+// preconditions, holds and retention policies, soft delete and restore,
+// compose, rewrite and move, simple, multipart, resumable and appendable
+// uploads, XML API multipart uploads, ranged reads, listing with prefixes,
+// delimiters, offsets and match globs, and JSON API batch requests. It
+// stores object ACLs but does not enforce them, and it does not implement
+// IAM, notifications, HMAC keys, listing of soft-deleted objects or
+// customer-supplied encryption keys, and it ignores most bucket
+// configuration, such as lifecycle rules. It may behave differently from
+// the actual service in ways in which the service is unspecified.
+//
+// The HTTP server serves the XML API at the root of its URL, so buckets named
+// "storage", "upload" or "batch" cannot be read with the XML API.
+//
+// This package is EXPERIMENTAL and is subject to change without notice.
+//
+// See the example for usage.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/internal/testutil"
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Server is a fake Cloud Storage server. It listens for HTTP requests at URL
+// and for gRPC requests at Addr; both share the same buckets and objects.
+type Server struct {
+	// URL is the base URL of the HTTP server, for example
+	// "http://127.0.0.1:1234".
+	URL string
+	// Addr is the address that the gRPC server is listening on.
+	Addr string
+
+	srv  *testutil.Server
+	hsrv *httptest.Server
+	svc  *service
+}
+
+// NewServer creates a new fake server running in the current process. It
+// listens on system-chosen ports on the local loopback interface.
+func NewServer() (*Server, error) {
+	srv, err := testutil.NewServer()
+	if err != nil {
+		return nil, err
+	}
+	svc := newService()
+	storagepb.RegisterStorageServer(srv.Gsrv, svc)
+	srv.Start()
+
+	hsrv := httptest.NewServer(&httpHandler{svc: svc})
+	svc.baseURL = hsrv.URL
+	return &Server{
+		URL:  hsrv.URL,
+		Addr: srv.Addr,
+		srv:  srv,
+		hsrv: hsrv,
+		svc:  svc,
+	}, nil
+}
+
+// NewTestServer creates a new fake server for the test or benchmark tb, with
+// a bucket for each of the given names, and closes it when tb and its
+// subtests complete. It calls tb.Fatal if the server cannot be started.
+func NewTestServer(tb testing.TB, buckets ...string) *Server {
+	tb.Helper()
+	s, err := NewServer()
+	if err != nil {
+		tb.Fatalf("storagetest.NewServer: %v", err)
+	}
+	tb.Cleanup(s.Close)
+	for _, b := range buckets {
+		s.CreateBucket(b)
+	}
+	return s
+}
+
+// TestClient is like NewClient, but closes the client when tb and its
+// subtests complete, and calls tb.Fatal if the client cannot be created.
+func (s *Server) TestClient(tb testing.TB, opts ...option.ClientOption) *storage.Client {
+	tb.Helper()
+	c, err := s.NewClient(context.Background(), opts...)
+	if err != nil {
+		tb.Fatalf("storagetest.Server.NewClient: %v", err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestGRPCClient is like NewGRPCClient, but closes the client when tb and its
+// subtests complete, and calls tb.Fatal if the client cannot be created.
+func (s *Server) TestGRPCClient(tb testing.TB, opts ...option.ClientOption) *storage.Client {
+	tb.Helper()
+	c, err := s.NewGRPCClient(context.Background(), opts...)
+	if err != nil {
+		tb.Fatalf("storagetest.Server.NewGRPCClient: %v", err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.hsrv.Close()
+	s.srv.Close()
+}
+
+// SetTimeNowFunc registers f as a function to be used instead of time.Now
+// for this server, for example to control object creation times and
+// retention expirations.
+func (s *Server) SetTimeNowFunc(f func() time.Time) {
+	s.svc.mu.Lock()
+	defer s.svc.mu.Unlock()
+	s.svc.timeNow = f
+}
+
+// ClientOptions returns the options that connect a client created with
+// [storage.NewClient] to the server.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.URL + "/storage/v1/"),
+		option.WithoutAuthentication(),
+	}
+}
+
+// GRPCClientOptions returns the options that connect a client created with
+// [storage.NewGRPCClient] to the server.
+func (s *Server) GRPCClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.Addr),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithoutAuthentication(),
+		storage.WithDisabledClientMetrics(),
+	}
+}
+
+// NewClient creates a client that uses the JSON and XML APIs of the server.
+// The given options are applied after the options that connect it to the
+// server.
+func (s *Server) NewClient(ctx context.Context, opts ...option.ClientOption) (*storage.Client, error) {
+	return storage.NewClient(ctx, append(s.ClientOptions(), opts...)...)
+}
+
+// NewGRPCClient creates a client that uses the gRPC API of the server. The
+// given options are applied after the options that connect it to the server.
+func (s *Server) NewGRPCClient(ctx context.Context, opts ...option.ClientOption) (*storage.Client, error) {
+	return storage.NewGRPCClient(ctx, append(s.GRPCClientOptions(), opts...)...)
+}
+
+// CreateBucket creates a bucket with the given name in project "project",
+// with default attributes.
+//
+// CreateBucket panics if there is an error, which is appropriate for testing.
+func (s *Server) CreateBucket(name string) {
+	_, err := s.svc.CreateBucket(context.Background(), &storagepb.CreateBucketRequest{
+		Parent:   "projects/_",
+		Bucket:   &storagepb.Bucket{Project: "projects/project"},
+		BucketId: name,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("storagetest.Server.CreateBucket: %v", err))
+	}
+}
+
+// WriteObject writes data to the named object in bucket, which must already
+// exist, and returns the generation of the new object.
+//
+// WriteObject panics if there is an error, which is appropriate for testing.
+func (s *Server) WriteObject(bucket, object string, data []byte) int64 {
+	spec := &storagepb.WriteObjectSpec{
+		Resource: &storagepb.Object{Bucket: bucketResource(bucket), Name: object},
+	}
+	obj, err := s.svc.insertObject(spec, data, nil)
+	if err != nil {
+		panic(fmt.Sprintf("storagetest.Server.WriteObject: %v", err))
+	}
+	return obj.Generation
+}
@@ -0,0 +1,643 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/experimental"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// forEachClient runs f as a subtest with a new server and a client for each
+// of the APIs that the server supports.
+func forEachClient(t *testing.T, f func(t *testing.T, srv *Server, client *storage.Client)) {
+	for _, tc := range []struct {
+		name      string
+		newClient func(*testing.T, *Server) *storage.Client
+	}{
+		{"http", func(t *testing.T, s *Server) *storage.Client { return s.TestClient(t) }},
+		{"json-reads", func(t *testing.T, s *Server) *storage.Client { return s.TestClient(t, storage.WithJSONReads()) }},
+		{"grpc", func(t *testing.T, s *Server) *storage.Client { return s.TestGRPCClient(t) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := NewTestServer(t)
+			f(t, srv, tc.newClient(t, srv))
+		})
+	}
+}
+
+func write(ctx context.Context, t *testing.T, obj *storage.ObjectHandle, data string, attrs storage.ObjectAttrs) *storage.ObjectAttrs {
+	t.Helper()
+	w := obj.NewWriter(ctx)
+	w.ObjectAttrs = attrs
+	w.Name = obj.ObjectName()
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("writing %q: %v", obj.ObjectName(), err)
+	}
+	return w.Attrs()
+}
+
+func read(ctx context.Context, t *testing.T, obj *storage.ObjectHandle, offset, length int64) string {
+	t.Helper()
+	r, err := obj.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		t.Fatalf("reading %q: %v", obj.ObjectName(), err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %q: %v", obj.ObjectName(), err)
+	}
+	return string(b)
+}
+
+func isPreconditionFailed(err error) bool {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code == http.StatusPreconditionFailed
+	}
+	return status.Code(err) == codes.FailedPrecondition
+}
+
+func TestBuckets(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		for _, name := range []string{"b2", "b1", "other"} {
+			if err := client.Bucket(name).Create(ctx, "project", &storage.BucketAttrs{Labels: map[string]string{"k": "v"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := client.Bucket("b1").Create(ctx, "project", nil); err == nil {
+			t.Error("creating an existing bucket: got nil, want error")
+		}
+
+		var names []string
+		it := client.Buckets(ctx, "project")
+		it.Prefix = "b"
+		for {
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, attrs.Name)
+		}
+		if want := []string{"b1", "b2"}; !cmp.Equal(names, want) {
+			t.Errorf("bucket names: got %q, want %q", names, want)
+		}
+
+		attrs, err := client.Bucket("b1").Update(ctx, storage.BucketAttrsToUpdate{
+			VersioningEnabled: true,
+			StorageClass:      "NEARLINE",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !attrs.VersioningEnabled || attrs.StorageClass != "NEARLINE" || attrs.MetaGeneration != 2 {
+			t.Errorf("updated attrs: got versioning %t, class %q, metageneration %d", attrs.VersioningEnabled, attrs.StorageClass, attrs.MetaGeneration)
+		}
+		var uattrs storage.BucketAttrsToUpdate
+		uattrs.DeleteLabel("k")
+		uattrs.SetLabel("k2", "v2")
+		attrs, err = client.Bucket("b1").If(storage.BucketConditions{MetagenerationMatch: 2}).Update(ctx, uattrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]string{"k2": "v2"}; !cmp.Equal(attrs.Labels, want) {
+			t.Errorf("labels: got %v, want %v", attrs.Labels, want)
+		}
+		if _, err := client.Bucket("b1").If(storage.BucketConditions{MetagenerationMatch: 2}).Update(ctx, uattrs); !isPreconditionFailed(err) {
+			t.Errorf("update with stale metageneration: got %v, want precondition failure", err)
+		}
+
+		srv.WriteObject("b2", "o", []byte("x"))
+		if err := client.Bucket("b2").Delete(ctx); err == nil {
+			t.Error("deleting a non-empty bucket: got nil, want error")
+		}
+		if err := client.Bucket("b1").Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Bucket("b1").Attrs(ctx); !errors.Is(err, storage.ErrBucketNotExist) {
+			t.Errorf("attrs of deleted bucket: got %v, want ErrBucketNotExist", err)
+		}
+	})
+}
+
+func TestWriteRead(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		srv.CreateBucket("bucket")
+		obj := client.Bucket("bucket").Object("dir/file.txt")
+		attrs := write(ctx, t, obj, "hello, world", storage.ObjectAttrs{
+			ContentType: "text/plain",
+			Metadata:    map[string]string{"key": "value"},
+		})
+		if attrs.Size != 12 || attrs.ContentType != "text/plain" || attrs.Generation == 0 || attrs.CRC32C == 0 {
+			t.Errorf("written attrs: got size %d, content type %q, generation %d, crc32c %d", attrs.Size, attrs.ContentType, attrs.Generation, attrs.CRC32C)
+		}
+
+		for _, tc := range []struct {
+			offset, length int64
+			want           string
+		}{
+			{0, -1, "hello, world"},
+			{7, -1, "world"},
+			{0, 5, "hello"},
+			{7, 100, "world"},
+			{-5, -1, "world"},
+		} {
+			if got := read(ctx, t, obj, tc.offset, tc.length); got != tc.want {
+				t.Errorf("read(%d, %d): got %q, want %q", tc.offset, tc.length, got, tc.want)
+			}
+		}
+
+		got, err := obj.Attrs(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Generation != attrs.Generation || got.Metadata["key"] != "value" || got.Bucket != "bucket" {
+			t.Errorf("attrs: got generation %d, metadata %v, bucket %q", got.Generation, got.Metadata, got.Bucket)
+		}
+
+		if _, err := client.Bucket("bucket").Object("missing").NewReader(ctx); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("reading a missing object: got %v, want ErrObjectNotExist", err)
+		}
+	})
+}
+
+func TestLargeWrite(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		srv.CreateBucket("bucket")
+		obj := client.Bucket("bucket").Object("large")
+		data := bytes.Repeat([]byte("0123456789abcdef"), 3<<16) // 3 MiB
+		w := obj.NewWriter(ctx)
+		w.ChunkSize = 256 << 10
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(ctx, t, obj, 0, -1); got != string(data) {
+			t.Errorf("read %d bytes, want %d bytes of the written data", len(got), len(data))
+		}
+		if got, want := read(ctx, t, obj, 1<<20+3, 16), string(data[1<<20+3:1<<20+19]); got != want {
+			t.Errorf("ranged read: got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestPreconditionsAndGenerations(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		srv.CreateBucket("bucket")
+		b := client.Bucket("bucket")
+		if _, err := b.Update(ctx, storage.BucketAttrsToUpdate{VersioningEnabled: true}); err != nil {
+			t.Fatal(err)
+		}
+		obj := b.Object("o")
+		gen1 := write(ctx, t, obj.If(storage.Conditions{DoesNotExist: true}), "one", storage.ObjectAttrs{}).Generation
+
+		w := obj.If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+		io.WriteString(w, "again")
+		if err := w.Close(); !isPreconditionFailed(err) {
+			t.Errorf("write with DoesNotExist: got %v, want precondition failure", err)
+		}
+
+		gen2 := write(ctx, t, obj.If(storage.Conditions{GenerationMatch: gen1}), "two", storage.ObjectAttrs{}).Generation
+		if gen2 <= gen1 {
+			t.Errorf("generations: got %d after %d, want increasing", gen2, gen1)
+		}
+		if got := read(ctx, t, obj.Generation(gen1), 0, -1); got != "one" {
+			t.Errorf("read of generation %d: got %q, want %q", gen1, got, "one")
+		}
+		if got := read(ctx, t, obj, 0, -1); got != "two" {
+			t.Errorf("read of live generation: got %q, want %q", got, "two")
+		}
+
+		if _, err := obj.If(storage.Conditions{GenerationMatch: gen1}).Update(ctx, storage.ObjectAttrsToUpdate{ContentType: "a/b"}); !isPreconditionFailed(err) {
+			t.Errorf("update with stale generation: got %v, want precondition failure", err)
+		}
+		attrs, err := obj.If(storage.Conditions{MetagenerationMatch: 1}).Update(ctx, storage.ObjectAttrsToUpdate{
+			ContentType: "a/b",
+			Metadata:    map[string]string{"k": "v"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attrs.ContentType != "a/b" || attrs.Metageneration != 2 || attrs.Metadata["k"] != "v" {
+			t.Errorf("updated attrs: got content type %q, metageneration %d, metadata %v", attrs.ContentType, attrs.Metageneration, attrs.Metadata)
+		}
+
+		if err := obj.Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := obj.Attrs(ctx); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("attrs of deleted object: got %v, want ErrObjectNotExist", err)
+		}
+		var gens []int64
+		it := b.Objects(ctx, &storage.Query{Versions: true})
+		for {
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			gens = append(gens, attrs.Generation)
+		}
+		if want := []int64{gen1, gen2}; !cmp.Equal(gens, want) {
+			t.Errorf("listed generations: got %v, want %v", gens, want)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		srv.CreateBucket("bucket")
+		for _, name := range []string{"a.txt", "b.csv", "dir/c.txt", "dir/sub/d.txt", "dir/e.csv", "z/"} {
+			srv.WriteObject("bucket", name, []byte(name))
+		}
+		for _, tc := range []struct {
+			name  string
+			query *storage.Query
+			want  []string
+		}{
+			{"all", nil, []string{"a.txt", "b.csv", "dir/c.txt", "dir/e.csv", "dir/sub/d.txt", "z/"}},
+			{"prefix", &storage.Query{Prefix: "dir/"}, []string{"dir/c.txt", "dir/e.csv", "dir/sub/d.txt"}},
+			{"delimiter", &storage.Query{Delimiter: "/"}, []string{"a.txt", "b.csv", "dir/", "z/"}},
+			{"prefix and delimiter", &storage.Query{Prefix: "dir/", Delimiter: "/"}, []string{"dir/c.txt", "dir/e.csv", "dir/sub/"}},
+			{"offsets", &storage.Query{StartOffset: "b", EndOffset: "dir/d"}, []string{"b.csv", "dir/c.txt"}},
+			{"glob", &storage.Query{MatchGlob: "**/*.txt"}, []string{"a.txt", "dir/c.txt", "dir/sub/d.txt"}},
+			{"glob with star", &storage.Query{MatchGlob: "dir/*.{csv,txt}"}, []string{"dir/c.txt", "dir/e.csv"}},
+		} {
+			var got []string
+			it := client.Bucket("bucket").Objects(ctx, tc.query)
+			it.PageInfo().MaxSize = 2
+			for {
+				attrs, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", tc.name, err)
+				}
+				if attrs.Prefix != "" {
+					got = append(got, attrs.Prefix)
+				} else {
+					got = append(got, attrs.Name)
+				}
+			}
+			sort.Strings(got)
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestComposeCopyMove(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		srv.CreateBucket("bucket")
+		srv.CreateBucket("other")
+		b := client.Bucket("bucket")
+		write(ctx, t, b.Object("a"), "foo", storage.ObjectAttrs{Metadata: map[string]string{"src": "a"}})
+		write(ctx, t, b.Object("b"), "bar", storage.ObjectAttrs{})
+
+		c := b.Object("c").ComposerFrom(b.Object("a"), b.Object("b"), b.Object("a"))
+		c.ContentType = "text/plain"
+		attrs, err := c.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attrs.ComponentCount != 3 || attrs.ContentType != "text/plain" {
+			t.Errorf("composed attrs: got component count %d, content type %q", attrs.ComponentCount, attrs.ContentType)
+		}
+		if got := read(ctx, t, b.Object("c"), 0, -1); got != "foobarfoo" {
+			t.Errorf("composed content: got %q, want %q", got, "foobarfoo")
+		}
+
+		attrs, err = client.Bucket("other").Object("copy").CopierFrom(b.Object("a")).Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attrs.Bucket != "other" || attrs.Metadata["src"] != "a" {
+			t.Errorf("copied attrs: got bucket %q, metadata %v", attrs.Bucket, attrs.Metadata)
+		}
+		if got := read(ctx, t, client.Bucket("other").Object("copy"), 0, -1); got != "foo" {
+			t.Errorf("copied content: got %q, want %q", got, "foo")
+		}
+
+		if _, err := b.Object("b").Move(ctx, storage.MoveObjectDestination{Object: "moved"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Object("b").Attrs(ctx); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("attrs of moved object: got %v, want ErrObjectNotExist", err)
+		}
+		if got := read(ctx, t, b.Object("moved"), 0, -1); got != "bar" {
+			t.Errorf("moved content: got %q, want %q", got, "bar")
+		}
+	})
+}
+
+func TestHoldsAndRetention(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		srv.SetTimeNowFunc(func() time.Time { return now })
+		srv.CreateBucket("bucket")
+		b := client.Bucket("bucket")
+		obj := b.Object("held")
+		write(ctx, t, obj, "x", storage.ObjectAttrs{TemporaryHold: true})
+		if err := obj.Delete(ctx); err == nil {
+			t.Error("deleting an object under hold: got nil, want error")
+		}
+		if _, err := obj.Update(ctx, storage.ObjectAttrsToUpdate{TemporaryHold: false}); err != nil {
+			t.Fatal(err)
+		}
+		if err := obj.Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := b.Update(ctx, storage.BucketAttrsToUpdate{RetentionPolicy: &storage.RetentionPolicy{RetentionPeriod: time.Hour}}); err != nil {
+			t.Fatal(err)
+		}
+		attrs := write(ctx, t, b.Object("retained"), "x", storage.ObjectAttrs{})
+		if want := now.Add(time.Hour); !attrs.RetentionExpirationTime.Equal(want) {
+			t.Errorf("retention expiration: got %v, want %v", attrs.RetentionExpirationTime, want)
+		}
+		if err := b.Object("retained").Delete(ctx); err == nil {
+			t.Error("deleting a retained object: got nil, want error")
+		}
+		now = now.Add(2 * time.Hour)
+		if err := b.Object("retained").Delete(ctx); err != nil {
+			t.Errorf("deleting an object after its retention expired: %v", err)
+		}
+	})
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		srv.SetTimeNowFunc(func() time.Time { return now })
+		b := client.Bucket("soft-delete")
+		if err := b.Create(ctx, "project", &storage.BucketAttrs{
+			SoftDeletePolicy: &storage.SoftDeletePolicy{RetentionDuration: 24 * time.Hour},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		obj := b.Object("obj")
+		gen := write(ctx, t, obj, "content", storage.ObjectAttrs{ContentType: "text/plain"}).Generation
+		if err := obj.Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		attrs, err := obj.Generation(gen).SoftDeleted().Attrs(ctx)
+		if err != nil {
+			t.Fatalf("soft-deleted attrs: %v", err)
+		}
+		if !attrs.SoftDeleteTime.Equal(now) || !attrs.HardDeleteTime.Equal(now.Add(24*time.Hour)) {
+			t.Errorf("soft-deleted attrs: got delete times %v and %v", attrs.SoftDeleteTime, attrs.HardDeleteTime)
+		}
+		restored, err := obj.Generation(gen).Restore(ctx, &storage.RestoreOptions{})
+		if err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if restored.Generation == gen || restored.ContentType != "text/plain" || !restored.SoftDeleteTime.IsZero() {
+			t.Errorf("restored attrs: got %+v", restored)
+		}
+		if got := read(ctx, t, obj, 0, -1); got != "content" {
+			t.Errorf("restored content: got %q, want %q", got, "content")
+		}
+		if _, err := obj.Generation(gen).Restore(ctx, &storage.RestoreOptions{}); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("restoring twice: got %v, want ErrObjectNotExist", err)
+		}
+
+		// Overwritten generations are soft-deleted too, until their hard
+		// delete time.
+		write(ctx, t, obj, "new content", storage.ObjectAttrs{})
+		now = now.Add(25 * time.Hour)
+		if _, err := obj.Generation(restored.Generation).Restore(ctx, &storage.RestoreOptions{}); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("restoring after the hard delete time: got %v, want ErrObjectNotExist", err)
+		}
+
+		// Without a soft delete policy, deleted objects are gone.
+		srv.CreateBucket("bucket")
+		obj = client.Bucket("bucket").Object("obj")
+		gen = write(ctx, t, obj, "x", storage.ObjectAttrs{}).Generation
+		if err := obj.Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := obj.Generation(gen).Restore(ctx, &storage.RestoreOptions{}); !errors.Is(err, storage.ErrObjectNotExist) {
+			t.Errorf("restoring without a soft delete policy: got %v, want ErrObjectNotExist", err)
+		}
+	})
+}
+
+func TestResumableUploadSession(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *Server, client *storage.Client) {
+		ctx := context.Background()
+		srv.CreateBucket("bucket")
+		obj := client.Bucket("bucket").Object("resumed")
+		data := bytes.Repeat([]byte("0123456789abcdef"), 40<<10) // 640 KiB
+		const chunkSize = 256 << 10
+
+		var session *storage.ResumableSession
+		cctx, cancel := context.WithCancel(ctx)
+		w := obj.NewWriter(cctx)
+		w.ChunkSize = chunkSize
+		w.SessionFunc = func(s *storage.ResumableSession) {
+			if s.PersistedSize > 0 && session == nil {
+				session = s
+				cancel()
+			}
+		}
+		w.Write(data)
+		if err := w.Close(); err == nil {
+			t.Fatal("closing a canceled writer: got nil, want error")
+		}
+		if session == nil {
+			t.Fatal("no session was reported")
+		}
+
+		w, off, err := obj.NewWriterFromSession(ctx, session, &storage.ResumableWriterOpts{ChunkSize: chunkSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if off != session.PersistedSize {
+			t.Errorf("resumed offset: got %d, want %d", off, session.PersistedSize)
+		}
+		if _, err := w.Write(data[off:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(ctx, t, obj, 0, -1); got != string(data) {
+			t.Errorf("read %d bytes, want %d", len(got), len(data))
+		}
+	})
+}
+
+func TestAppendableWrite(t *testing.T) {
+	ctx := context.Background()
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.CreateBucket("bucket")
+	client, err := srv.NewGRPCClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	obj := client.Bucket("bucket").Object("appendable")
+	w := obj.NewWriter(ctx)
+	w.Append = true
+	w.FinalizeOnClose = false
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attrs.Finalized.IsZero() {
+		t.Errorf("object was finalized at %v, want unfinalized", attrs.Finalized)
+	}
+
+	tw, off, err := obj.Generation(attrs.Generation).NewWriterFromAppendableObject(ctx, &storage.AppendableWriterOpts{FinalizeOnClose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off != 5 {
+		t.Errorf("takeover offset: got %d, want 5", off)
+	}
+	if _, err := io.WriteString(tw, ", world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(ctx, t, obj, 0, -1); got != "hello, world" {
+		t.Errorf("content: got %q, want %q", got, "hello, world")
+	}
+	attrs, err = obj.Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Finalized.IsZero() {
+		t.Error("object is not finalized")
+	}
+}
+
+func TestMultiRangeDownloader(t *testing.T) {
+	ctx := context.Background()
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.CreateBucket("bucket")
+	data := bytes.Repeat([]byte("0123456789"), 1<<19)
+	srv.WriteObject("bucket", "obj", data)
+	client, err := srv.NewGRPCClient(ctx, experimental.WithGRPCBidiReads())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	mrd, err := client.Bucket("bucket").Object("obj").NewMultiRangeDownloader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := [][2]int64{{0, 10}, {5, 3 << 20}, {int64(len(data)) - 4, 4}}
+	bufs := make([]bytes.Buffer, len(ranges))
+	for i, r := range ranges {
+		mrd.Add(&bufs[i], r[0], r[1], func(int64, int64, error) {})
+	}
+	mrd.Wait()
+	if err := mrd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range ranges {
+		if want := data[r[0] : r[0]+r[1]]; !bytes.Equal(bufs[i].Bytes(), want) {
+			t.Errorf("range %v: got %d bytes, want %d", r, bufs[i].Len(), len(want))
+		}
+	}
+}
+
+func TestXMLRead(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.CreateBucket("bucket")
+	gen := srv.WriteObject("bucket", "a/b c", []byte("hello"))
+
+	req, err := http.NewRequest("GET", srv.URL+"/bucket/a/b%20c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Range", "bytes=1-3")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusPartialContent || string(body) != "ell" {
+		t.Errorf("got status %d and body %q, want %d and %q", res.StatusCode, body, http.StatusPartialContent, "ell")
+	}
+	if got, want := res.Header.Get("Content-Range"), "bytes 1-3/5"; got != want {
+		t.Errorf("Content-Range: got %q, want %q", got, want)
+	}
+	if got, want := res.Header.Get("X-Goog-Generation"), strconv.FormatInt(gen, 10); got != want {
+		t.Errorf("X-Goog-Generation: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,981 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/testutil"
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	bucketPrefix          = "projects/_/buckets/"
+	defaultStorageClass   = "STANDARD"
+	defaultLocation       = "US"
+	defaultListPageSize   = 1000
+	maxComposeComponents  = 32
+	globalProjectResource = "projects/_"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// service holds the state of the fake server. It implements the gRPC API;
+// the HTTP handlers translate JSON API requests into calls to the same
+// methods.
+type service struct {
+	storagepb.UnimplementedStorageServer
+
+	baseURL string // URL of the HTTP server, for session URIs
+
+	mu      sync.Mutex
+	timeNow func() time.Time
+	buckets map[string]*bucket          // by bucket ID
+	uploads map[string]*upload          // by upload ID
+	mpus    map[string]*multipartUpload // XML API multipart uploads, by upload ID
+	lastGen int64
+	nextID  int
+}
+
+type bucket struct {
+	attrs *storagepb.Bucket
+	// objects holds the generations of each object, oldest first. The last
+	// generation is the live one, unless it has been deleted.
+	objects map[string][]*object
+	// softDeleted holds the soft-deleted generations of each object, oldest
+	// first. Generations are only soft-deleted if the bucket has a soft
+	// delete policy.
+	softDeleted map[string][]*object
+}
+
+type object struct {
+	attrs *storagepb.Object
+	data  []byte
+}
+
+func newService() *service {
+	return &service{
+		timeNow: time.Now,
+		buckets: map[string]*bucket{},
+		uploads: map[string]*upload{},
+		mpus:    map[string]*multipartUpload{},
+	}
+}
+
+func (s *service) now() time.Time {
+	return s.timeNow()
+}
+
+// nextGeneration returns a new object generation. Like the service's, it is
+// based on the current time in microseconds, and it is strictly increasing.
+func (s *service) nextGeneration(now time.Time) int64 {
+	s.lastGen = max(now.UnixMicro(), s.lastGen+1)
+	return s.lastGen
+}
+
+// bucketResource returns the gRPC resource name of the bucket.
+func bucketResource(name string) string {
+	return bucketPrefix + name
+}
+
+// bucketID returns the ID of a bucket from its resource name, or the name
+// itself if it is already an ID.
+func bucketID(name string) string {
+	return strings.TrimPrefix(name, bucketPrefix)
+}
+
+// bucket returns the named bucket. The caller must hold s.mu.
+func (s *service) bucket(name string) (*bucket, error) {
+	b, ok := s.buckets[bucketID(name)]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "bucket %q not found", bucketID(name))
+	}
+	return b, nil
+}
+
+// live returns the live generation of the named object, or nil.
+func (b *bucket) live(name string) *object {
+	gens := b.objects[name]
+	if n := len(gens); n > 0 && gens[n-1].attrs.DeleteTime == nil {
+		return gens[n-1]
+	}
+	return nil
+}
+
+// object returns the given generation of the named object, or the live
+// generation if gen is zero.
+func (b *bucket) object(name string, gen int64) (*object, error) {
+	if gen == 0 {
+		if o := b.live(name); o != nil {
+			return o, nil
+		}
+	} else {
+		for _, o := range b.objects[name] {
+			if o.attrs.Generation == gen {
+				return o, nil
+			}
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "object %q not found in bucket %q", name, bucketID(b.attrs.Name))
+}
+
+// put makes o the live generation of its object. The previous live
+// generation becomes noncurrent if versioning is enabled, and is removed
+// otherwise.
+func (b *bucket) put(o *object, now time.Time) {
+	name := o.attrs.Name
+	gens := b.objects[name]
+	if live := b.live(name); live != nil {
+		if b.attrs.GetVersioning().GetEnabled() {
+			live.attrs.DeleteTime = timestamppb.New(now)
+		} else {
+			gens = gens[:len(gens)-1]
+			b.softDelete(live, now)
+		}
+	}
+	b.objects[name] = append(gens, o)
+}
+
+// remove deletes the given generation of the named object, or the live
+// generation if gen is zero. A live generation that is deleted without
+// naming its generation becomes noncurrent if versioning is enabled. It
+// returns the generation that was removed from the bucket, or nil.
+func (b *bucket) remove(name string, gen int64, now time.Time) *object {
+	gens := b.objects[name]
+	var removed *object
+	for i, o := range gens {
+		if (gen == 0 && i == len(gens)-1) || o.attrs.Generation == gen {
+			if gen == 0 && b.attrs.GetVersioning().GetEnabled() {
+				o.attrs.DeleteTime = timestamppb.New(now)
+				return nil
+			}
+			removed = o
+			gens = append(gens[:i:i], gens[i+1:]...)
+			break
+		}
+	}
+	if len(gens) == 0 {
+		delete(b.objects, name)
+	} else {
+		b.objects[name] = gens
+	}
+	return removed
+}
+
+// softDelete keeps a generation that was removed from the bucket as a
+// soft-deleted generation, if the bucket has a soft delete policy.
+func (b *bucket) softDelete(o *object, now time.Time) {
+	d := b.attrs.GetSoftDeletePolicy().GetRetentionDuration().AsDuration()
+	if o == nil || d <= 0 {
+		return
+	}
+	o.attrs.SoftDeleteTime = timestamppb.New(now)
+	o.attrs.HardDeleteTime = timestamppb.New(now.Add(d))
+	b.softDeleted[o.attrs.Name] = append(b.softDeleted[o.attrs.Name], o)
+}
+
+// softDeletedObject returns the given soft-deleted generation of the named
+// object. Generations whose hard delete time has passed are dropped.
+func (b *bucket) softDeletedObject(name string, gen int64, now time.Time) (*object, error) {
+	gens := slices.DeleteFunc(b.softDeleted[name], func(o *object) bool {
+		return !now.Before(o.attrs.HardDeleteTime.AsTime())
+	})
+	if len(gens) == 0 {
+		delete(b.softDeleted, name)
+	} else {
+		b.softDeleted[name] = gens
+	}
+	for _, o := range gens {
+		if o.attrs.Generation == gen {
+			return o, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "soft-deleted object %q with generation %d not found in bucket %q", name, gen, bucketID(b.attrs.Name))
+}
+
+// preconditions are the generation and metageneration preconditions of a
+// request. A nil field is not checked.
+type preconditions struct {
+	ifGenerationMatch        *int64
+	ifGenerationNotMatch     *int64
+	ifMetagenerationMatch    *int64
+	ifMetagenerationNotMatch *int64
+}
+
+// check checks the preconditions against attrs, which is nil if the object
+// does not exist.
+func (p preconditions) check(attrs *storagepb.Object) error {
+	var gen, metagen int64
+	if attrs != nil {
+		gen, metagen = attrs.Generation, attrs.Metageneration
+	}
+	switch {
+	case p.ifGenerationMatch != nil && *p.ifGenerationMatch != gen,
+		p.ifGenerationNotMatch != nil && *p.ifGenerationNotMatch == gen,
+		p.ifMetagenerationMatch != nil && (attrs == nil || *p.ifMetagenerationMatch != metagen),
+		p.ifMetagenerationNotMatch != nil && attrs != nil && *p.ifMetagenerationNotMatch == metagen:
+		return status.Error(codes.FailedPrecondition, "at least one of the pre-conditions you specified did not hold")
+	}
+	return nil
+}
+
+// checkBucket checks the metageneration preconditions against a bucket.
+func (p preconditions) checkBucket(attrs *storagepb.Bucket) error {
+	return preconditions{
+		ifMetagenerationMatch:    p.ifMetagenerationMatch,
+		ifMetagenerationNotMatch: p.ifMetagenerationNotMatch,
+	}.check(&storagepb.Object{Metageneration: attrs.Metageneration})
+}
+
+// checkMutable returns an error if o may not be deleted or replaced because
+// of a hold or a retention policy.
+func checkMutable(o *object, now time.Time) error {
+	if o.attrs.TemporaryHold || o.attrs.GetEventBasedHold() {
+		return status.Errorf(codes.PermissionDenied, "object %q is under active hold", o.attrs.Name)
+	}
+	if t := o.attrs.RetentionExpireTime; t != nil && now.Before(t.AsTime()) {
+		return status.Errorf(codes.PermissionDenied, "object %q is subject to bucket's retention policy and cannot be deleted or overwritten until %v", o.attrs.Name, t.AsTime())
+	}
+	return nil
+}
+
+// etag returns an entity tag for the given generation and metageneration.
+func etag(gen, metagen int64) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%d/%d", gen, metagen)))
+}
+
+// checksums returns the checksums of data.
+func checksums(data []byte) *storagepb.ObjectChecksums {
+	sum := md5.Sum(data)
+	return &storagepb.ObjectChecksums{
+		Crc32C:  proto.Uint32(crc32.Checksum(data, crc32cTable)),
+		Md5Hash: sum[:],
+	}
+}
+
+// validateChecksums returns an error if the checksums provided by the client
+// do not match data.
+func validateChecksums(data []byte, want *storagepb.ObjectChecksums) error {
+	if want == nil {
+		return nil
+	}
+	got := checksums(data)
+	if want.Crc32C != nil && want.GetCrc32C() != got.GetCrc32C() {
+		return status.Errorf(codes.InvalidArgument, "provided CRC32C %d does not match the data's CRC32C %d", want.GetCrc32C(), got.GetCrc32C())
+	}
+	if len(want.Md5Hash) > 0 && string(want.Md5Hash) != string(got.Md5Hash) {
+		return status.Error(codes.InvalidArgument, "provided MD5 hash does not match the data's MD5 hash")
+	}
+	return nil
+}
+
+// newObjectAttrs returns the attributes of a new generation of an object,
+// based on the writable fields of resource.
+func newObjectAttrs(resource *storagepb.Object) *storagepb.Object {
+	resource = proto.Clone(resource).(*storagepb.Object)
+	attrs := &storagepb.Object{}
+	for _, path := range updatableObjectFields {
+		copyField(attrs.ProtoReflect(), resource.ProtoReflect(), []string{path})
+	}
+	attrs.Name = resource.GetName()
+	attrs.StorageClass = resource.GetStorageClass()
+	attrs.KmsKey = resource.GetKmsKey()
+	return attrs
+}
+
+// create stores a new generation of an object with the given attributes and
+// content, after checking conds against the live generation. If finalize is
+// false, the object is created as an unfinalized appendable object. The
+// caller must hold s.mu.
+func (s *service) create(b *bucket, attrs *storagepb.Object, data []byte, conds preconditions, finalize bool) (*object, error) {
+	if attrs.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is required")
+	}
+	now := s.now()
+	live := b.live(attrs.Name)
+	var liveAttrs *storagepb.Object
+	if live != nil {
+		liveAttrs = live.attrs
+	}
+	if err := conds.check(liveAttrs); err != nil {
+		return nil, err
+	}
+	if live != nil {
+		if err := checkMutable(live, now); err != nil {
+			return nil, err
+		}
+	}
+
+	attrs.Bucket = b.attrs.Name
+	attrs.Generation = s.nextGeneration(now)
+	attrs.Metageneration = 1
+	attrs.Etag = etag(attrs.Generation, attrs.Metageneration)
+	attrs.Size = int64(len(data))
+	attrs.Checksums = checksums(data)
+	if attrs.ComponentCount > 0 {
+		// Composite objects have no MD5 hash.
+		attrs.Checksums.Md5Hash = nil
+	}
+	if attrs.StorageClass == "" {
+		attrs.StorageClass = b.attrs.StorageClass
+	}
+	ts := timestamppb.New(now)
+	attrs.CreateTime = ts
+	attrs.UpdateTime = ts
+	attrs.UpdateStorageClassTime = ts
+	if finalize {
+		attrs.FinalizeTime = ts
+	}
+	attrs.DeleteTime = nil
+	if b.attrs.DefaultEventBasedHold && attrs.EventBasedHold == nil {
+		attrs.EventBasedHold = proto.Bool(true)
+	}
+	if d := b.attrs.GetRetentionPolicy().GetRetentionDuration(); d != nil {
+		attrs.RetentionExpireTime = timestamppb.New(now.Add(d.AsDuration()))
+	}
+
+	o := &object{attrs: attrs, data: data}
+	b.put(o, now)
+	return o, nil
+}
+
+// insertObject creates an object from a complete upload.
+func (s *service) insertObject(spec *storagepb.WriteObjectSpec, data []byte, sums *storagepb.ObjectChecksums) (*storagepb.Object, error) {
+	if err := validateChecksums(data, sums); err != nil {
+		return nil, err
+	}
+	if spec.ObjectSize != nil && spec.GetObjectSize() != int64(len(data)) {
+		return nil, status.Errorf(codes.InvalidArgument, "upload has %d bytes, but the object size was declared as %d", len(data), spec.GetObjectSize())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(spec.GetResource().GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.create(b, newObjectAttrs(spec.GetResource()), data, specPreconditions(spec), true)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(o.attrs).(*storagepb.Object), nil
+}
+
+func specPreconditions(spec *storagepb.WriteObjectSpec) preconditions {
+	return preconditions{
+		ifGenerationMatch:        spec.IfGenerationMatch,
+		ifGenerationNotMatch:     spec.IfGenerationNotMatch,
+		ifMetagenerationMatch:    spec.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: spec.IfMetagenerationNotMatch,
+	}
+}
+
+// readObject returns a snapshot of the given generation of an object, or
+// the live generation if gen is zero, after checking conds.
+func (s *service) readObject(bucketName, name string, gen int64, conds preconditions) (*storagepb.Object, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(bucketName)
+	if err != nil {
+		return nil, nil, err
+	}
+	o, err := b.object(name, gen)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conds.check(o.attrs); err != nil {
+		return nil, nil, err
+	}
+	// The data of an object is never modified in place, so it can be shared.
+	return proto.Clone(o.attrs).(*storagepb.Object), o.data, nil
+}
+
+// CreateBucket creates a bucket.
+func (s *service) CreateBucket(_ context.Context, req *storagepb.CreateBucketRequest) (*storagepb.Bucket, error) {
+	id := req.GetBucketId()
+	if id == "" || strings.Contains(id, "/") {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid bucket name %q", id)
+	}
+	attrs := proto.Clone(req.GetBucket()).(*storagepb.Bucket)
+	if attrs == nil {
+		attrs = &storagepb.Bucket{}
+	}
+	if attrs.Project == "" || attrs.Project == globalProjectResource {
+		attrs.Project = req.GetParent()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[id]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "bucket %q already exists", id)
+	}
+	now := timestamppb.New(s.now())
+	attrs.Name = bucketResource(id)
+	attrs.BucketId = id
+	attrs.Metageneration = 1
+	attrs.Etag = etag(0, attrs.Metageneration)
+	attrs.CreateTime = now
+	attrs.UpdateTime = now
+	if attrs.Location == "" {
+		attrs.Location = defaultLocation
+	}
+	attrs.Location = strings.ToUpper(attrs.Location)
+	if attrs.LocationType == "" {
+		attrs.LocationType = "multi-region"
+	}
+	if attrs.StorageClass == "" {
+		attrs.StorageClass = defaultStorageClass
+	}
+	if rp := attrs.RetentionPolicy; rp != nil {
+		rp.EffectiveTime = now
+		rp.IsLocked = false
+	}
+	if sdp := attrs.SoftDeletePolicy; sdp != nil {
+		sdp.EffectiveTime = now
+	}
+	s.buckets[id] = &bucket{attrs: attrs, objects: map[string][]*object{}, softDeleted: map[string][]*object{}}
+	return proto.Clone(attrs).(*storagepb.Bucket), nil
+}
+
+// GetBucket returns the attributes of a bucket.
+func (s *service) GetBucket(_ context.Context, req *storagepb.GetBucketRequest) (*storagepb.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	conds := preconditions{ifMetagenerationMatch: req.IfMetagenerationMatch, ifMetagenerationNotMatch: req.IfMetagenerationNotMatch}
+	if err := conds.checkBucket(b.attrs); err != nil {
+		return nil, err
+	}
+	return proto.Clone(b.attrs).(*storagepb.Bucket), nil
+}
+
+// ListBuckets lists the buckets of a project in name order.
+func (s *service) ListBuckets(_ context.Context, req *storagepb.ListBucketsRequest) (*storagepb.ListBucketsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buckets []*storagepb.Bucket
+	for id, b := range s.buckets {
+		if b.attrs.Project == req.GetParent() && strings.HasPrefix(id, req.GetPrefix()) {
+			buckets = append(buckets, b.attrs)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	from, to, nextToken, err := testutil.PageBounds(int(req.GetPageSize()), req.GetPageToken(), len(buckets))
+	if err != nil {
+		return nil, err
+	}
+	res := &storagepb.ListBucketsResponse{NextPageToken: nextToken}
+	for _, b := range buckets[from:to] {
+		res.Buckets = append(res.Buckets, proto.Clone(b).(*storagepb.Bucket))
+	}
+	return res, nil
+}
+
+// UpdateBucket updates the fields of a bucket named by the update mask.
+func (s *service) UpdateBucket(_ context.Context, req *storagepb.UpdateBucketRequest) (*storagepb.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetBucket().GetName())
+	if err != nil {
+		return nil, err
+	}
+	conds := preconditions{ifMetagenerationMatch: req.IfMetagenerationMatch, ifMetagenerationNotMatch: req.IfMetagenerationNotMatch}
+	if err := conds.checkBucket(b.attrs); err != nil {
+		return nil, err
+	}
+	if b.attrs.GetRetentionPolicy().GetIsLocked() && hasPath(req.GetUpdateMask().GetPaths(), "retention_policy") {
+		return nil, status.Error(codes.FailedPrecondition, "the bucket's retention policy is locked")
+	}
+	attrs := proto.Clone(b.attrs).(*storagepb.Bucket)
+	if err := applyMask(attrs, req.GetBucket(), req.GetUpdateMask().GetPaths(), updatableBucketFields); err != nil {
+		return nil, err
+	}
+	now := timestamppb.New(s.now())
+	if rp := attrs.RetentionPolicy; rp != nil && hasPath(req.GetUpdateMask().GetPaths(), "retention_policy") {
+		rp.EffectiveTime = now
+		rp.IsLocked = false
+	}
+	if sdp := attrs.SoftDeletePolicy; sdp != nil && hasPath(req.GetUpdateMask().GetPaths(), "soft_delete_policy") {
+		sdp.EffectiveTime = now
+	}
+	attrs.Metageneration++
+	attrs.Etag = etag(0, attrs.Metageneration)
+	attrs.UpdateTime = now
+	b.attrs = attrs
+	return proto.Clone(attrs).(*storagepb.Bucket), nil
+}
+
+// DeleteBucket deletes an empty bucket.
+func (s *service) DeleteBucket(_ context.Context, req *storagepb.DeleteBucketRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	conds := preconditions{ifMetagenerationMatch: req.IfMetagenerationMatch, ifMetagenerationNotMatch: req.IfMetagenerationNotMatch}
+	if err := conds.checkBucket(b.attrs); err != nil {
+		return nil, err
+	}
+	if len(b.objects) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "bucket %q is not empty", bucketID(req.GetName()))
+	}
+	delete(s.buckets, bucketID(req.GetName()))
+	return &emptypb.Empty{}, nil
+}
+
+// LockBucketRetentionPolicy locks the retention policy of a bucket.
+func (s *service) LockBucketRetentionPolicy(_ context.Context, req *storagepb.LockBucketRetentionPolicyRequest) (*storagepb.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	if b.attrs.Metageneration != req.GetIfMetagenerationMatch() {
+		return nil, status.Error(codes.FailedPrecondition, "at least one of the pre-conditions you specified did not hold")
+	}
+	if b.attrs.RetentionPolicy == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "bucket %q has no retention policy", bucketID(req.GetBucket()))
+	}
+	attrs := proto.Clone(b.attrs).(*storagepb.Bucket)
+	attrs.RetentionPolicy.IsLocked = true
+	attrs.Metageneration++
+	attrs.Etag = etag(0, attrs.Metageneration)
+	attrs.UpdateTime = timestamppb.New(s.now())
+	b.attrs = attrs
+	return proto.Clone(attrs).(*storagepb.Bucket), nil
+}
+
+// GetObject returns the attributes of an object, or of a soft-deleted
+// generation of it.
+func (s *service) GetObject(_ context.Context, req *storagepb.GetObjectRequest) (*storagepb.Object, error) {
+	conds := preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	}
+	if req.GetSoftDeleted() {
+		if req.GetGeneration() == 0 {
+			return nil, status.Error(codes.InvalidArgument, "a generation is required to get a soft-deleted object")
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		b, err := s.bucket(req.GetBucket())
+		if err != nil {
+			return nil, err
+		}
+		o, err := b.softDeletedObject(req.GetObject(), req.GetGeneration(), s.now())
+		if err != nil {
+			return nil, err
+		}
+		if err := conds.check(o.attrs); err != nil {
+			return nil, err
+		}
+		return proto.Clone(o.attrs).(*storagepb.Object), nil
+	}
+	attrs, _, err := s.readObject(req.GetBucket(), req.GetObject(), req.GetGeneration(), conds)
+	return attrs, err
+}
+
+// UpdateObject updates the fields of an object named by the update mask.
+func (s *service) UpdateObject(_ context.Context, req *storagepb.UpdateObjectRequest) (*storagepb.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetObject().GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := b.object(req.GetObject().GetName(), req.GetObject().GetGeneration())
+	if err != nil {
+		return nil, err
+	}
+	conds := preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	}
+	if err := conds.check(o.attrs); err != nil {
+		return nil, err
+	}
+	attrs := proto.Clone(o.attrs).(*storagepb.Object)
+	if err := applyMask(attrs, req.GetObject(), req.GetUpdateMask().GetPaths(), updatableObjectFields); err != nil {
+		return nil, err
+	}
+	attrs.Metageneration++
+	attrs.Etag = etag(attrs.Generation, attrs.Metageneration)
+	attrs.UpdateTime = timestamppb.New(s.now())
+	o.attrs = attrs
+	return proto.Clone(attrs).(*storagepb.Object), nil
+}
+
+// DeleteObject deletes an object or one of its generations.
+func (s *service) DeleteObject(_ context.Context, req *storagepb.DeleteObjectRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := b.object(req.GetObject(), req.GetGeneration())
+	if err != nil {
+		return nil, err
+	}
+	conds := preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	}
+	if err := conds.check(o.attrs); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := checkMutable(o, now); err != nil {
+		return nil, err
+	}
+	b.softDelete(b.remove(req.GetObject(), req.GetGeneration(), now), now)
+	return &emptypb.Empty{}, nil
+}
+
+// RestoreObject restores a soft-deleted generation of an object as a new
+// live generation.
+func (s *service) RestoreObject(_ context.Context, req *storagepb.RestoreObjectRequest) (*storagepb.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := b.softDeletedObject(req.GetObject(), req.GetGeneration(), s.now())
+	if err != nil {
+		return nil, err
+	}
+	attrs := newObjectAttrs(o.attrs)
+	attrs.ComponentCount = o.attrs.ComponentCount
+	if !req.GetCopySourceAcl() {
+		attrs.Acl = nil
+	}
+	res, err := s.create(b, attrs, o.data, preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	name := req.GetObject()
+	if gens := slices.DeleteFunc(b.softDeleted[name], func(g *object) bool { return g == o }); len(gens) == 0 {
+		delete(b.softDeleted, name)
+	} else {
+		b.softDeleted[name] = gens
+	}
+	return proto.Clone(res.attrs).(*storagepb.Object), nil
+}
+
+// ListObjects lists the objects in a bucket in name order, and generation
+// order for the generations of an object.
+func (s *service) ListObjects(_ context.Context, req *storagepb.ListObjectsRequest) (*storagepb.ListObjectsResponse, error) {
+	if req.GetSoftDeleted() {
+		return nil, status.Error(codes.Unimplemented, "storagetest: listing soft-deleted objects is not supported")
+	}
+	var glob *globMatcher
+	if req.GetMatchGlob() != "" {
+		var err error
+		if glob, err = compileGlob(req.GetMatchGlob()); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(b.objects))
+	for name := range b.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Each entry is either an object or a prefix.
+	type entry struct {
+		attrs  *storagepb.Object
+		prefix string
+	}
+	var entries []entry
+	prefix, delim := req.GetPrefix(), req.GetDelimiter()
+	seenPrefixes := map[string]bool{}
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) ||
+			name < req.GetLexicographicStart() ||
+			(req.GetLexicographicEnd() != "" && name >= req.GetLexicographicEnd()) ||
+			(glob != nil && !glob.match(name)) {
+			continue
+		}
+		if delim != "" {
+			rest := name[len(prefix):]
+			if i := strings.Index(rest, delim); i >= 0 {
+				p := prefix + rest[:i+len(delim)]
+				if !seenPrefixes[p] {
+					seenPrefixes[p] = true
+					entries = append(entries, entry{prefix: p})
+				}
+				if !req.GetIncludeTrailingDelimiter() || p != name {
+					continue
+				}
+			}
+		}
+		for _, o := range b.objects[name] {
+			if req.GetVersions() || o.attrs.DeleteTime == nil {
+				entries = append(entries, entry{attrs: o.attrs})
+			}
+		}
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = defaultListPageSize
+	}
+	// Like those of the real service, page tokens hold the position of the
+	// last entry returned rather than an index, so that listing continues
+	// correctly when objects are created or deleted between pages.
+	from := 0
+	if tok := req.GetPageToken(); tok != "" {
+		name, gen, err := parseListToken(tok)
+		if err != nil {
+			return nil, err
+		}
+		from = sort.Search(len(entries), func(i int) bool {
+			e := entries[i]
+			if e.attrs == nil {
+				return e.prefix > name
+			}
+			return e.attrs.GetName() > name || (e.attrs.GetName() == name && e.attrs.GetGeneration() > gen)
+		})
+	}
+	to := min(from+pageSize, len(entries))
+	res := &storagepb.ListObjectsResponse{}
+	if to < len(entries) {
+		if last := entries[to-1]; last.attrs == nil {
+			res.NextPageToken = listToken(last.prefix, 0)
+		} else {
+			res.NextPageToken = listToken(last.attrs.GetName(), last.attrs.GetGeneration())
+		}
+	}
+	for _, e := range entries[from:to] {
+		if e.attrs != nil {
+			res.Objects = append(res.Objects, proto.Clone(e.attrs).(*storagepb.Object))
+		} else {
+			res.Prefixes = append(res.Prefixes, e.prefix)
+		}
+	}
+	return res, nil
+}
+
+// listToken returns the page token of a listing continuing after the
+// generation of an object, or after a prefix if gen is 0.
+func listToken(name string, gen int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(gen, 10) + ":" + name))
+}
+
+// parseListToken returns the object name and generation of a page token
+// returned by listToken.
+func parseListToken(tok string) (string, int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(tok)
+	if err == nil {
+		g, name, ok := strings.Cut(string(b), ":")
+		if ok {
+			if gen, err := strconv.ParseInt(g, 10, 64); err == nil {
+				return name, gen, nil
+			}
+		}
+	}
+	return "", 0, status.Errorf(codes.InvalidArgument, "invalid page token %q", tok)
+}
+
+// ComposeObject concatenates objects in a bucket into a new object.
+func (s *service) ComposeObject(_ context.Context, req *storagepb.ComposeObjectRequest) (*storagepb.Object, error) {
+	srcs := req.GetSourceObjects()
+	if len(srcs) == 0 || len(srcs) > maxComposeComponents {
+		return nil, status.Errorf(codes.InvalidArgument, "compose requires between 1 and %d source objects, got %d", maxComposeComponents, len(srcs))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetDestination().GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	var components int32
+	for _, src := range srcs {
+		o, err := b.object(src.GetName(), src.GetGeneration())
+		if err != nil {
+			return nil, err
+		}
+		if pc := src.GetObjectPreconditions(); pc != nil && pc.IfGenerationMatch != nil && pc.GetIfGenerationMatch() != o.attrs.Generation {
+			return nil, status.Errorf(codes.FailedPrecondition, "source object %q does not match its generation precondition", src.GetName())
+		}
+		data = append(data, o.data...)
+		components += max(1, o.attrs.ComponentCount)
+	}
+	if err := validateChecksums(data, req.GetObjectChecksums()); err != nil {
+		return nil, err
+	}
+	attrs := newObjectAttrs(req.GetDestination())
+	attrs.ComponentCount = components
+	if req.GetKmsKey() != "" {
+		attrs.KmsKey = req.GetKmsKey()
+	}
+	o, err := s.create(b, attrs, data, preconditions{
+		ifGenerationMatch:     req.IfGenerationMatch,
+		ifMetagenerationMatch: req.IfMetagenerationMatch,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDeleteSourceObjects() {
+		now := s.now()
+		for _, src := range srcs {
+			if src.GetName() != attrs.Name {
+				b.softDelete(b.remove(src.GetName(), src.GetGeneration(), now), now)
+			}
+		}
+	}
+	return proto.Clone(o.attrs).(*storagepb.Object), nil
+}
+
+// RewriteObject copies an object, possibly to another bucket. The whole
+// object is always copied in a single call.
+func (s *service) RewriteObject(_ context.Context, req *storagepb.RewriteObjectRequest) (*storagepb.RewriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, err := s.bucket(req.GetSourceBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := src.object(req.GetSourceObject(), req.GetSourceGeneration())
+	if err != nil {
+		return nil, err
+	}
+	srcConds := preconditions{
+		ifGenerationMatch:        req.IfSourceGenerationMatch,
+		ifGenerationNotMatch:     req.IfSourceGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfSourceMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfSourceMetagenerationNotMatch,
+	}
+	if err := srcConds.check(o.attrs); err != nil {
+		return nil, err
+	}
+	if err := validateChecksums(o.data, req.GetObjectChecksums()); err != nil {
+		return nil, err
+	}
+	dst, err := s.bucket(req.GetDestinationBucket())
+	if err != nil {
+		return nil, err
+	}
+
+	// Metadata is copied from the source unless the request specifies the
+	// destination's metadata.
+	resource := req.GetDestination()
+	if !hasObjectMetadata(resource) {
+		resource = o.attrs
+	}
+	attrs := newObjectAttrs(resource)
+	attrs.Name = req.GetDestinationName()
+	if sc := req.GetDestination().GetStorageClass(); sc != "" {
+		attrs.StorageClass = sc
+	}
+	attrs.KmsKey = req.GetDestinationKmsKey()
+	attrs.ComponentCount = o.attrs.ComponentCount
+	res, err := s.create(dst, attrs, o.data, preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.RewriteResponse{
+		TotalBytesRewritten: res.attrs.Size,
+		ObjectSize:          res.attrs.Size,
+		Done:                true,
+		Resource:            proto.Clone(res.attrs).(*storagepb.Object),
+	}, nil
+}
+
+// MoveObject renames an object within a bucket.
+func (s *service) MoveObject(_ context.Context, req *storagepb.MoveObjectRequest) (*storagepb.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := b.object(req.GetSourceObject(), 0)
+	if err != nil {
+		return nil, err
+	}
+	srcConds := preconditions{
+		ifGenerationMatch:        req.IfSourceGenerationMatch,
+		ifGenerationNotMatch:     req.IfSourceGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfSourceMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfSourceMetagenerationNotMatch,
+	}
+	if err := srcConds.check(o.attrs); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := checkMutable(o, now); err != nil {
+		return nil, err
+	}
+	attrs := newObjectAttrs(o.attrs)
+	attrs.Name = req.GetDestinationObject()
+	attrs.ComponentCount = o.attrs.ComponentCount
+	res, err := s.create(b, attrs, o.data, preconditions{
+		ifGenerationMatch:        req.IfGenerationMatch,
+		ifGenerationNotMatch:     req.IfGenerationNotMatch,
+		ifMetagenerationMatch:    req.IfMetagenerationMatch,
+		ifMetagenerationNotMatch: req.IfMetagenerationNotMatch,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	b.remove(req.GetSourceObject(), o.attrs.Generation, now)
+	return proto.Clone(res.attrs).(*storagepb.Object), nil
+}
@@ -0,0 +1,339 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"cloud.google.com/go/storage/internal/apiv2/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// upload is a resumable upload, made with either the JSON or the gRPC API.
+type upload struct {
+	spec *storagepb.WriteObjectSpec
+	sums *storagepb.ObjectChecksums // checksums declared when the upload started
+	data []byte
+	obj  *storagepb.Object // the object, once the upload is complete
+}
+
+// startUpload starts a resumable upload and returns its ID.
+func (s *service) startUpload(spec *storagepb.WriteObjectSpec, sums *storagepb.ObjectChecksums) (string, error) {
+	if spec.GetResource().GetName() == "" {
+		return "", status.Error(codes.InvalidArgument, "object name is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.bucket(spec.GetResource().GetBucket()); err != nil {
+		return "", err
+	}
+	s.nextID++
+	id := "upload-" + strconv.Itoa(s.nextID)
+	s.uploads[id] = &upload{spec: proto.Clone(spec).(*storagepb.WriteObjectSpec), sums: sums}
+	return id, nil
+}
+
+// writeUpload adds data at offset to a resumable upload and returns the
+// number of bytes persisted. Data before the persisted size is ignored, so
+// that clients may resend data after a failure. If finish is true, the
+// upload is completed and the object is returned; sums are the checksums of
+// the complete object, if known.
+func (s *service) writeUpload(id string, offset int64, data []byte, finish bool, sums *storagepb.ObjectChecksums) (int64, *storagepb.Object, error) {
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	if !ok {
+		s.mu.Unlock()
+		return 0, nil, status.Errorf(codes.NotFound, "upload %q not found", id)
+	}
+	if u.obj != nil {
+		defer s.mu.Unlock()
+		return u.obj.Size, proto.Clone(u.obj).(*storagepb.Object), nil
+	}
+	persisted := int64(len(u.data))
+	if offset > persisted {
+		s.mu.Unlock()
+		return 0, nil, status.Errorf(codes.OutOfRange, "write offset %d is beyond the persisted size %d", offset, persisted)
+	}
+	if skip := persisted - offset; skip < int64(len(data)) {
+		u.data = append(u.data, data[skip:]...)
+	}
+	persisted = int64(len(u.data))
+	if !finish {
+		s.mu.Unlock()
+		return persisted, nil, nil
+	}
+	if sums == nil {
+		sums = u.sums
+	}
+	spec, content := u.spec, u.data
+	s.mu.Unlock()
+
+	obj, err := s.insertObject(spec, content, sums)
+	if err != nil {
+		return 0, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u.obj = obj
+	return obj.Size, proto.Clone(obj).(*storagepb.Object), nil
+}
+
+// uploadStatus returns the number of bytes persisted by a resumable upload,
+// and the object if the upload is complete.
+func (s *service) uploadStatus(id string) (int64, *storagepb.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[id]
+	if !ok {
+		return 0, nil, status.Errorf(codes.NotFound, "upload %q not found", id)
+	}
+	if u.obj != nil {
+		return u.obj.Size, proto.Clone(u.obj).(*storagepb.Object), nil
+	}
+	return int64(len(u.data)), nil, nil
+}
+
+// cancelUpload cancels a resumable upload.
+func (s *service) cancelUpload(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.uploads[id]; !ok {
+		return status.Errorf(codes.NotFound, "upload %q not found", id)
+	}
+	delete(s.uploads, id)
+	return nil
+}
+
+// StartResumableWrite starts a resumable upload.
+func (s *service) StartResumableWrite(_ context.Context, req *storagepb.StartResumableWriteRequest) (*storagepb.StartResumableWriteResponse, error) {
+	id, err := s.startUpload(req.GetWriteObjectSpec(), req.GetObjectChecksums())
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.StartResumableWriteResponse{UploadId: id}, nil
+}
+
+// QueryWriteStatus returns the state of a resumable upload.
+func (s *service) QueryWriteStatus(_ context.Context, req *storagepb.QueryWriteStatusRequest) (*storagepb.QueryWriteStatusResponse, error) {
+	persisted, obj, err := s.uploadStatus(req.GetUploadId())
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		return &storagepb.QueryWriteStatusResponse{WriteStatus: &storagepb.QueryWriteStatusResponse_Resource{Resource: obj}}, nil
+	}
+	return &storagepb.QueryWriteStatusResponse{WriteStatus: &storagepb.QueryWriteStatusResponse_PersistedSize{PersistedSize: persisted}}, nil
+}
+
+// createAppendable creates an unfinalized appendable object.
+func (s *service) createAppendable(spec *storagepb.WriteObjectSpec) (*storagepb.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(spec.GetResource().GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.create(b, newObjectAttrs(spec.GetResource()), nil, specPreconditions(spec), false)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(o.attrs).(*storagepb.Object), nil
+}
+
+// appendObject appends data at offset to an unfinalized appendable object,
+// and finalizes it if finish is true. Data before the current size of the
+// object is ignored. It returns the updated object.
+func (s *service) appendObject(spec *storagepb.AppendObjectSpec, offset int64, data []byte, finish bool, sums *storagepb.ObjectChecksums) (*storagepb.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.bucket(spec.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	o, err := b.object(spec.GetObject(), spec.GetGeneration())
+	if err != nil {
+		return nil, err
+	}
+	conds := preconditions{ifMetagenerationMatch: spec.IfMetagenerationMatch, ifMetagenerationNotMatch: spec.IfMetagenerationNotMatch}
+	if err := conds.check(o.attrs); err != nil {
+		return nil, err
+	}
+	if o.attrs.FinalizeTime != nil {
+		if len(data) == 0 && offset == o.attrs.Size {
+			return proto.Clone(o.attrs).(*storagepb.Object), nil
+		}
+		return nil, status.Errorf(codes.FailedPrecondition, "object %q is already finalized", o.attrs.Name)
+	}
+	size := int64(len(o.data))
+	if offset > size {
+		return nil, status.Errorf(codes.OutOfRange, "write offset %d is beyond the object size %d", offset, size)
+	}
+	if skip := size - offset; skip < int64(len(data)) {
+		// Copy the data, since snapshots returned by readObject may share it.
+		o.data = append(o.data[:size:size], data[skip:]...)
+		attrs := proto.Clone(o.attrs).(*storagepb.Object)
+		attrs.Size = int64(len(o.data))
+		attrs.Checksums = checksums(o.data)
+		attrs.UpdateTime = timestamppb.New(s.now())
+		o.attrs = attrs
+	}
+	if finish {
+		if err := validateChecksums(o.data, sums); err != nil {
+			return nil, err
+		}
+		attrs := proto.Clone(o.attrs).(*storagepb.Object)
+		attrs.FinalizeTime = timestamppb.New(s.now())
+		o.attrs = attrs
+	}
+	return proto.Clone(o.attrs).(*storagepb.Object), nil
+}
+
+// bidiWrite is the state of a BidiWriteObject stream.
+type bidiWrite struct {
+	uploadID string                      // for resumable uploads
+	spec     *storagepb.WriteObjectSpec  // for one-shot uploads
+	append   *storagepb.AppendObjectSpec // for appendable objects
+	data     []byte                      // content of one-shot uploads
+	obj      *storagepb.Object           // latest state of appendable objects
+}
+
+// BidiWriteObject writes an object with a one-shot or resumable upload, or
+// writes to an appendable object.
+func (s *service) BidiWriteObject(stream storagepb.Storage_BidiWriteObjectServer) error {
+	var w *bidiWrite
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		first := w == nil
+		if first {
+			if w, err = s.startBidiWrite(req); err != nil {
+				return err
+			}
+		}
+		cd := req.GetChecksummedData()
+		if cd != nil && cd.Crc32C != nil && crc32.Checksum(cd.GetContent(), crc32cTable) != cd.GetCrc32C() {
+			return status.Error(codes.InvalidArgument, "checksum of the data does not match the provided CRC32C")
+		}
+		res, err := s.bidiWrite(w, req, first)
+		if err != nil {
+			return err
+		}
+		if res != nil {
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+		if req.GetFinishWrite() {
+			return nil
+		}
+	}
+}
+
+// startBidiWrite sets up the state of a BidiWriteObject stream from its first
+// message.
+func (s *service) startBidiWrite(req *storagepb.BidiWriteObjectRequest) (*bidiWrite, error) {
+	switch {
+	case req.GetUploadId() != "":
+		if _, _, err := s.uploadStatus(req.GetUploadId()); err != nil {
+			return nil, err
+		}
+		return &bidiWrite{uploadID: req.GetUploadId()}, nil
+	case req.GetWriteObjectSpec().GetAppendable():
+		obj, err := s.createAppendable(req.GetWriteObjectSpec())
+		if err != nil {
+			return nil, err
+		}
+		return &bidiWrite{
+			append: &storagepb.AppendObjectSpec{Bucket: obj.Bucket, Object: obj.Name, Generation: obj.Generation},
+			obj:    obj,
+		}, nil
+	case req.GetWriteObjectSpec() != nil:
+		spec := req.GetWriteObjectSpec()
+		if spec.GetResource().GetName() == "" {
+			return nil, status.Error(codes.InvalidArgument, "object name is required")
+		}
+		return &bidiWrite{spec: spec}, nil
+	case req.GetAppendObjectSpec() != nil:
+		return &bidiWrite{append: req.GetAppendObjectSpec()}, nil
+	}
+	return nil, status.Error(codes.InvalidArgument, "the first message must include an upload ID, a write object spec or an append object spec")
+}
+
+// bidiWrite handles a message of a BidiWriteObject stream, and returns the
+// response to send, if any.
+func (s *service) bidiWrite(w *bidiWrite, req *storagepb.BidiWriteObjectRequest, first bool) (*storagepb.BidiWriteObjectResponse, error) {
+	content := req.GetChecksummedData().GetContent()
+	finish := req.GetFinishWrite()
+	switch {
+	case w.append != nil:
+		obj, err := s.appendObject(w.append, req.GetWriteOffset(), content, finish, req.GetObjectChecksums())
+		if err != nil {
+			return nil, err
+		}
+		w.obj = obj
+		// Respond to state lookups, and to the first message of a takeover,
+		// which carries no data.
+		takeover := first && req.GetAppendObjectSpec() != nil && len(content) == 0 && !req.GetFlush()
+		if !finish && !req.GetStateLookup() && !takeover {
+			return nil, nil
+		}
+		return &storagepb.BidiWriteObjectResponse{
+			WriteStatus: &storagepb.BidiWriteObjectResponse_Resource{Resource: obj},
+			WriteHandle: &storagepb.BidiWriteHandle{Handle: []byte(obj.Name)},
+		}, nil
+
+	case w.uploadID != "":
+		persisted, obj, err := s.writeUpload(w.uploadID, req.GetWriteOffset(), content, finish, req.GetObjectChecksums())
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			return &storagepb.BidiWriteObjectResponse{WriteStatus: &storagepb.BidiWriteObjectResponse_Resource{Resource: obj}}, nil
+		}
+		if req.GetStateLookup() {
+			return &storagepb.BidiWriteObjectResponse{WriteStatus: &storagepb.BidiWriteObjectResponse_PersistedSize{PersistedSize: persisted}}, nil
+		}
+		return nil, nil
+
+	default:
+		if off := req.GetWriteOffset(); off != int64(len(w.data)) {
+			return nil, status.Errorf(codes.InvalidArgument, "write offset %d does not match the %d bytes written", off, len(w.data))
+		}
+		w.data = append(w.data, content...)
+		if finish {
+			obj, err := s.insertObject(w.spec, w.data, req.GetObjectChecksums())
+			if err != nil {
+				return nil, err
+			}
+			return &storagepb.BidiWriteObjectResponse{WriteStatus: &storagepb.BidiWriteObjectResponse_Resource{Resource: obj}}, nil
+		}
+		if req.GetStateLookup() {
+			return &storagepb.BidiWriteObjectResponse{WriteStatus: &storagepb.BidiWriteObjectResponse_PersistedSize{PersistedSize: int64(len(w.data))}}, nil
+		}
+		return nil, nil
+	}
+}
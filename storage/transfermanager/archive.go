@@ -0,0 +1,645 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// paxAttrsKey is the PAX record of a tar entry that holds the attributes
+	// of the object it was exported from.
+	paxAttrsKey = "GCS.attrs"
+
+	// zipAttrsExtraID is the ID of the extra field of a zip entry that holds
+	// the attributes of the object it was exported from.
+	zipAttrsExtraID = 0x4347 // "GC"
+)
+
+// ArchiveFormat is the format of an archive read by [ImportArchive] or written
+// by [ExportArchive].
+type ArchiveFormat int
+
+const (
+	// ArchiveTar is an uncompressed POSIX tar archive. Wrap the reader or
+	// writer with [compress/gzip] for a compressed archive.
+	ArchiveTar ArchiveFormat = iota
+
+	// ArchiveZip is a zip archive. Entries are compressed with Deflate,
+	// except for objects with a gzip content encoding which are stored as is.
+	ArchiveZip
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case ArchiveTar:
+		return "tar"
+	case ArchiveZip:
+		return "zip"
+	}
+	return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+}
+
+// ExportArchiveInput is the input for an [ExportArchive].
+type ExportArchiveInput struct {
+	// Bucket is the bucket in GCS to export objects from. Required.
+	Bucket string
+
+	// Prefix is the object name prefix to export, treated as a directory: the
+	// object "{Prefix}/a/file" is written to the archive as "a/file". If
+	// Prefix is empty, the whole bucket is exported. Optional.
+	Prefix string
+
+	// MatchGlob restricts the export to objects whose full name matches the
+	// glob. See [storage.Query.MatchGlob] for the syntax. Optional.
+	MatchGlob string
+
+	// Format is the format of the archive. Defaults to [ArchiveTar].
+	Format ArchiveFormat
+}
+
+// ExportArchiveOutput provides the output of an [ExportArchive].
+type ExportArchiveOutput struct {
+	// Objects is the number of objects written to the archive.
+	Objects int
+
+	// Bytes is the total size of the objects written to the archive.
+	Bytes int64
+}
+
+// ExportArchive writes the objects under input.Prefix to w as an archive
+// without staging them on local disk. The archive entries are in object name
+// order, and preserve the modification time of each object as well as its
+// content type, content encoding, content language, content disposition,
+// cache control and custom metadata, which [ImportArchive] restores.
+//
+// Objects are downloaded in pieces of the part size set by [WithPartSize] by
+// up to the number of workers set by [WithWorkers] at a time, while the
+// pieces are written to w in order. At most the number of workers times the
+// part size is held in memory. If the part size is smaller than 1, objects
+// are downloaded whole. Objects with a gzip content encoding are written
+// compressed, and each object is checked against its CRC32C checksum.
+//
+// ExportArchive stops at the first error, in which case the archive written
+// to w is incomplete. The [WithCallbacks] and [SkipIfExists] options are not
+// supported.
+func ExportArchive(ctx context.Context, c *storage.Client, w io.Writer, input *ExportArchiveInput, opts ...Option) (*ExportArchiveOutput, error) {
+	config := initTransferManagerConfig(opts...)
+	if config.skipIfExists || config.asynchronous {
+		return nil, errors.New("transfermanager: SkipIfExists and WithCallbacks cannot be used with ExportArchive")
+	}
+	var aw archiveWriter
+	switch input.Format {
+	case ArchiveTar:
+		aw = &tarArchiveWriter{tw: tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = &zipArchiveWriter{zw: zip.NewWriter(w)}
+	default:
+		return nil, fmt.Errorf("transfermanager: invalid ArchiveFormat %d", input.Format)
+	}
+
+	e := &exporter{
+		client: c,
+		config: config,
+		input:  input,
+		prefix: dirPrefix(input.Prefix),
+		output: &ExportArchiveOutput{},
+	}
+	workers := max(config.numWorkers, 1)
+	// slots limits the number of pieces that are downloaded or waiting to be
+	// written, and chunks holds them in archive order.
+	slots := make(chan struct{}, workers)
+	chunks := make(chan *exportChunk, workers)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		defer close(chunks)
+		return e.list(gctx, g, chunks, slots)
+	})
+	g.Go(func() error {
+		return e.write(gctx, aw, chunks, slots)
+	})
+	if err := g.Wait(); err != nil {
+		return e.output, err
+	}
+	if err := aw.Close(); err != nil {
+		return e.output, fmt.Errorf("transfermanager: ExportArchive failed to finish the %v archive: %w", input.Format, err)
+	}
+	return e.output, nil
+}
+
+type exporter struct {
+	client *storage.Client
+	config *transferManagerConfig
+	input  *ExportArchiveInput
+	prefix string
+	output *ExportArchiveOutput
+}
+
+// exportChunk is a piece of an object being exported.
+type exportChunk struct {
+	attrs  *storage.ObjectAttrs
+	offset int64
+	length int64
+
+	done chan struct{} // closed once data or err is set
+	data []byte
+	err  error
+}
+
+// list lists the objects to export and starts downloading their pieces,
+// sending them to chunks in order.
+func (e *exporter) list(ctx context.Context, g *errgroup.Group, chunks chan<- *exportChunk, slots chan struct{}) error {
+	query := &storage.Query{Prefix: e.prefix, MatchGlob: e.input.MatchGlob}
+	if err := query.SetAttrSelection([]string{
+		"Name", "Size", "CRC32C", "Generation", "Updated", "ContentType", "ContentEncoding",
+		"ContentLanguage", "ContentDisposition", "CacheControl", "Metadata",
+	}); err != nil {
+		return fmt.Errorf("transfermanager: ExportArchive query.SetAttrSelection: %w", err)
+	}
+	it := e.client.Bucket(e.input.Bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("transfermanager: ExportArchive failed to list objects: %w", err)
+		}
+		// Skip folder placeholder objects.
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		for offset := int64(0); ; {
+			length := attrs.Size - offset
+			if e.config.partSize > 0 {
+				length = min(length, e.config.partSize)
+			}
+			c := &exportChunk{attrs: attrs, offset: offset, length: length, done: make(chan struct{})}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			g.Go(func() error { return e.download(ctx, c) })
+			select {
+			case chunks <- c:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			offset += length
+			if offset >= attrs.Size {
+				break
+			}
+		}
+	}
+}
+
+// download reads the data of a piece of an object.
+func (e *exporter) download(ctx context.Context, c *exportChunk) error {
+	defer close(c.done)
+	if c.length == 0 {
+		return nil
+	}
+	if e.config.perOperationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.perOperationTimeout)
+		defer cancel()
+	}
+	method := downloadMany
+	if c.length < c.attrs.Size {
+		method = downloadSharded
+	}
+	ctx = setUsageMetricHeader(ctx, method)
+
+	o := e.client.Bucket(e.input.Bucket).Object(c.attrs.Name).Generation(c.attrs.Generation).ReadCompressed(true)
+	r, err := o.NewRangeReader(ctx, c.offset, c.length)
+	if err != nil {
+		c.err = fmt.Errorf("transfermanager: ExportArchive failed to read %q: %w", c.attrs.Name, err)
+		return c.err
+	}
+	defer r.Close()
+	data := make([]byte, c.length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		c.err = fmt.Errorf("transfermanager: ExportArchive failed to read %q: %w", c.attrs.Name, err)
+		return c.err
+	}
+	c.data = data
+	return nil
+}
+
+// write writes the pieces received from chunks to the archive as they are
+// downloaded.
+func (e *exporter) write(ctx context.Context, aw archiveWriter, chunks <-chan *exportChunk, slots <-chan struct{}) error {
+	var (
+		w   io.Writer
+		crc uint32
+	)
+	for c := range chunks {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if c.err != nil {
+			return c.err
+		}
+		if c.offset == 0 {
+			var err error
+			if w, err = aw.create(strings.TrimPrefix(c.attrs.Name, e.prefix), c.attrs); err != nil {
+				return fmt.Errorf("transfermanager: ExportArchive failed to add %q: %w", c.attrs.Name, err)
+			}
+			crc = 0
+		}
+		if _, err := w.Write(c.data); err != nil {
+			return fmt.Errorf("transfermanager: ExportArchive failed to write %q: %w", c.attrs.Name, err)
+		}
+		crc = crc32.Update(crc, crc32.MakeTable(crc32.Castagnoli), c.data)
+		c.data = nil
+		<-slots
+
+		if c.offset+c.length == c.attrs.Size {
+			if crc != c.attrs.CRC32C {
+				return fmt.Errorf("transfermanager: ExportArchive read %q with CRC32C %d, want %d", c.attrs.Name, crc, c.attrs.CRC32C)
+			}
+			e.output.Objects++
+			e.output.Bytes += c.attrs.Size
+		}
+	}
+	return nil
+}
+
+// archiveAttrs are the object attributes preserved in an archive, encoded as
+// JSON.
+type archiveAttrs struct {
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	ContentLanguage    string            `json:"contentLanguage,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// encodeArchiveAttrs returns the preserved attributes of an object, or nil if
+// there are none.
+func encodeArchiveAttrs(attrs *storage.ObjectAttrs) ([]byte, error) {
+	a := archiveAttrs{
+		ContentType:        attrs.ContentType,
+		ContentEncoding:    attrs.ContentEncoding,
+		ContentLanguage:    attrs.ContentLanguage,
+		ContentDisposition: attrs.ContentDisposition,
+		CacheControl:       attrs.CacheControl,
+		Metadata:           attrs.Metadata,
+	}
+	if a.ContentType == "" && a.ContentEncoding == "" && a.ContentLanguage == "" &&
+		a.ContentDisposition == "" && a.CacheControl == "" && len(a.Metadata) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// decodeArchiveAttrs returns the attributes of the object to create for an
+// archive entry. Entries without preserved attributes, such as those of
+// archives not created by ExportArchive, have their modification time
+// recorded as [Sync] does.
+func decodeArchiveAttrs(data []byte, modTime time.Time) (*storage.ObjectAttrs, error) {
+	if data == nil {
+		attrs := &storage.ObjectAttrs{}
+		if !modTime.IsZero() {
+			attrs.Metadata = map[string]string{mtimeMetadataKey: strconv.FormatInt(modTime.Unix(), 10)}
+		}
+		return attrs, nil
+	}
+	var a archiveAttrs
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("invalid object attributes: %w", err)
+	}
+	return &storage.ObjectAttrs{
+		ContentType:        a.ContentType,
+		ContentEncoding:    a.ContentEncoding,
+		ContentLanguage:    a.ContentLanguage,
+		ContentDisposition: a.ContentDisposition,
+		CacheControl:       a.CacheControl,
+		Metadata:           a.Metadata,
+	}, nil
+}
+
+type archiveWriter interface {
+	// create adds an entry for an object to the archive, returning the
+	// writer for its data.
+	create(name string, attrs *storage.ObjectAttrs) (io.Writer, error)
+	Close() error
+}
+
+type tarArchiveWriter struct {
+	tw *tar.Writer
+}
+
+func (a *tarArchiveWriter) create(name string, attrs *storage.ObjectAttrs) (io.Writer, error) {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     attrs.Size,
+		Mode:     0o644,
+		ModTime:  attrs.Updated.Truncate(time.Second),
+	}
+	rec, err := encodeArchiveAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		hdr.PAXRecords = map[string]string{paxAttrsKey: string(rec)}
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return a.tw, nil
+}
+
+func (a *tarArchiveWriter) Close() error {
+	return a.tw.Close()
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiveWriter) create(name string, attrs *storage.ObjectAttrs) (io.Writer, error) {
+	fh := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: attrs.Updated,
+	}
+	if attrs.ContentEncoding == "gzip" {
+		fh.Method = zip.Store
+	}
+	rec, err := encodeArchiveAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		// The zip writer adds its own extra fields, which must fit in the
+		// same 64 KiB.
+		if len(rec) > 0xff00 {
+			return nil, fmt.Errorf("object attributes of %d bytes are too large for a zip archive", len(rec))
+		}
+		fh.Extra = binary.LittleEndian.AppendUint16(nil, zipAttrsExtraID)
+		fh.Extra = binary.LittleEndian.AppendUint16(fh.Extra, uint16(len(rec)))
+		fh.Extra = append(fh.Extra, rec...)
+	}
+	return a.zw.CreateHeader(fh)
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+// zipExtraField returns the data of the extra field with the given ID, or nil
+// if there is none.
+func zipExtraField(extra []byte, id uint16) []byte {
+	for len(extra) >= 4 {
+		fieldID := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			return nil
+		}
+		if fieldID == id {
+			return extra[:size]
+		}
+		extra = extra[size:]
+	}
+	return nil
+}
+
+// ImportArchiveInput is the input for an [ImportArchive].
+type ImportArchiveInput struct {
+	// Bucket is the bucket in GCS to create objects in. Required.
+	Bucket string
+
+	// Prefix is the object name prefix that corresponds to the root of the
+	// archive, treated as a directory: the entry "a/file" is uploaded to the
+	// object "{Prefix}/a/file", or "a/file" if Prefix is empty. Optional.
+	Prefix string
+
+	// Format is the format of the archive. Defaults to [ArchiveTar].
+	Format ArchiveFormat
+}
+
+// ImportArchiveOutput provides the output of an [ImportArchive].
+type ImportArchiveOutput struct {
+	// Objects is the number of objects created.
+	Objects int
+
+	// Bytes is the total size of the objects created.
+	Bytes int64
+
+	// Skipped is the number of entries that were not uploaded because the
+	// object already existed and the [SkipIfExists] option was set.
+	Skipped int
+}
+
+// ImportArchive extracts an archive read from r into objects, without staging
+// it on local disk. Only regular files are extracted; a leading "./" in entry
+// names is removed. The attributes preserved by [ExportArchive] are restored.
+// For other archives, the modification time of each entry is recorded in the
+// object metadata as [Sync] does, so that a later Sync to a local directory
+// restores it.
+//
+// Entries are uploaded by up to the number of workers set by [WithWorkers] at
+// a time. Since a tar archive can only be read sequentially, tar entries no
+// larger than the part size set by [WithPartSize] are read into memory so
+// that they can be uploaded in parallel, and larger entries are uploaded
+// while they are read. A zip archive has its index at the end, so for
+// [ArchiveZip], r must implement [io.ReaderAt] and have a Size or Stat
+// method, as [bytes.Reader], [io.SectionReader] and [os.File] do; zip entries
+// are read and uploaded in parallel.
+//
+// ImportArchive stops at the first error; the objects already created are
+// not deleted. The [WithCallbacks] option is not supported.
+func ImportArchive(ctx context.Context, c *storage.Client, r io.Reader, input *ImportArchiveInput, opts ...Option) (*ImportArchiveOutput, error) {
+	config := initTransferManagerConfig(opts...)
+	if config.asynchronous {
+		return nil, errors.New("transfermanager: WithCallbacks cannot be used with ImportArchive")
+	}
+	im := &importer{
+		client: c,
+		config: config,
+		input:  input,
+		prefix: dirPrefix(input.Prefix),
+		output: &ImportArchiveOutput{},
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(config.numWorkers, 1))
+	var err error
+	switch input.Format {
+	case ArchiveTar:
+		err = im.importTar(gctx, g, r)
+	case ArchiveZip:
+		err = im.importZip(gctx, g, r)
+	default:
+		return nil, fmt.Errorf("transfermanager: invalid ArchiveFormat %d", input.Format)
+	}
+	if werr := g.Wait(); werr != nil {
+		// An upload error causes the archive reading to stop with a context
+		// error, so report the upload error.
+		err = werr
+	}
+	if err != nil {
+		return im.output, err
+	}
+	return im.output, nil
+}
+
+type importer struct {
+	client *storage.Client
+	config *transferManagerConfig
+	input  *ImportArchiveInput
+	prefix string
+
+	mu     sync.Mutex
+	output *ImportArchiveOutput
+}
+
+func (im *importer) importTar(ctx context.Context, g *errgroup.Group, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("transfermanager: ImportArchive failed to read the tar archive: %w", err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		var rec []byte
+		if v, ok := hdr.PAXRecords[paxAttrsKey]; ok {
+			rec = []byte(v)
+		}
+		in, err := im.newInput(ctx, hdr.Name, rec, hdr.ModTime)
+		if err != nil {
+			return err
+		}
+		if in == nil {
+			continue
+		}
+		if im.config.partSize < 1 || hdr.Size > im.config.partSize {
+			in.Source = tr
+			if err := im.upload(in); err != nil {
+				return err
+			}
+			continue
+		}
+		data := make([]byte, hdr.Size)
+		if _, err := io.ReadFull(tr, data); err != nil {
+			return fmt.Errorf("transfermanager: ImportArchive failed to read %q: %w", hdr.Name, err)
+		}
+		in.Source = bytes.NewReader(data)
+		g.Go(func() error { return im.upload(in) })
+	}
+}
+
+func (im *importer) importZip(ctx context.Context, g *errgroup.Group, r io.Reader) error {
+	ra, isReaderAt := r.(io.ReaderAt)
+	size := sourceSize(r)
+	if s, ok := r.(interface{ Size() int64 }); ok {
+		size = s.Size()
+	}
+	if !isReaderAt || size < 0 {
+		return errors.New("transfermanager: ImportArchive of a zip archive requires an io.ReaderAt with a Size or Stat method")
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("transfermanager: ImportArchive failed to read the zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		in, err := im.newInput(ctx, f.Name, zipExtraField(f.Extra, zipAttrsExtraID), f.Modified)
+		if err != nil {
+			return err
+		}
+		if in == nil {
+			continue
+		}
+		g.Go(func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("transfermanager: ImportArchive failed to read %q: %w", f.Name, err)
+			}
+			defer rc.Close()
+			in.Source = rc
+			return im.upload(in)
+		})
+	}
+	return nil
+}
+
+// newInput returns the upload input for an archive entry, or nil if the
+// entry should be skipped.
+func (im *importer) newInput(ctx context.Context, name string, rec []byte, modTime time.Time) (*UploadObjectInput, error) {
+	name = strings.TrimPrefix(name, "./")
+	if name == "" || strings.HasSuffix(name, "/") {
+		return nil, nil
+	}
+	attrs, err := decodeArchiveAttrs(rec, modTime)
+	if err != nil {
+		return nil, fmt.Errorf("transfermanager: ImportArchive failed to read %q: %w", name, err)
+	}
+	return &UploadObjectInput{
+		Bucket:      im.input.Bucket,
+		Object:      im.prefix + name,
+		ObjectAttrs: attrs,
+		ctx:         ctx,
+	}, nil
+}
+
+func (im *importer) upload(in *UploadObjectInput) error {
+	out := in.upload(im.client, im.config)
+	if out.Err != nil {
+		return fmt.Errorf("transfermanager: ImportArchive failed to upload %q: %w", in.Object, out.Err)
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if out.Attrs == nil {
+		im.output.Skipped++
+		return nil
+	}
+	im.output.Objects++
+	im.output.Bytes += out.Attrs.Size
+	return nil
+}
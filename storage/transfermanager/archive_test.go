@@ -0,0 +1,200 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/storagetest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+)
+
+type archiveTestObject struct {
+	Content     string
+	ContentType string
+	Metadata    map[string]string
+}
+
+// readArchiveTestObjects returns the objects under prefix, keyed by the name
+// relative to prefix.
+func readArchiveTestObjects(t *testing.T, client *storage.Client, prefix string) map[string]archiveTestObject {
+	t.Helper()
+	ctx := context.Background()
+	objs := map[string]archiveTestObject{}
+	it := client.Bucket("bucket").Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := client.Bucket("bucket").Object(attrs.Name).ReadCompressed(true).NewReader(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs[strings.TrimPrefix(attrs.Name, prefix)] = archiveTestObject{
+			Content:     string(content),
+			ContentType: attrs.ContentType,
+			Metadata:    attrs.Metadata,
+		}
+	}
+}
+
+func TestExportImportArchive(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := storagetest.NewTestServer(t, "bucket").TestClient(t)
+
+	large := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(large)
+	want := map[string]archiveTestObject{
+		"a.txt":         {Content: "hello", ContentType: "text/plain", Metadata: map[string]string{"k": "v"}},
+		"dir/large.bin": {Content: string(large), ContentType: "application/octet-stream"},
+		"dir/empty":     {Content: "", ContentType: "application/x-empty"},
+	}
+	for name, o := range want {
+		w := client.Bucket("bucket").Object("export/" + name).NewWriter(ctx)
+		w.ContentType = o.ContentType
+		w.Metadata = o.Metadata
+		io.WriteString(w, o.Content)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"other.txt", "export-not-under-prefix"} {
+		w := client.Bucket("bucket").Object(name).NewWriter(ctx)
+		io.WriteString(w, "other")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveZip} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := []Option{WithWorkers(3), WithPartSize(1024)}
+			out, err := ExportArchive(ctx, client, &buf, &ExportArchiveInput{Bucket: "bucket", Prefix: "export", Format: format}, opts...)
+			if err != nil {
+				t.Fatalf("ExportArchive: %v", err)
+			}
+			if out.Objects != 3 || out.Bytes != int64(len(large)+5) {
+				t.Errorf("ExportArchive: got %+v", out)
+			}
+
+			prefix := "import-" + format.String() + "/"
+			in, err := ImportArchive(ctx, client, bytes.NewReader(buf.Bytes()), &ImportArchiveInput{Bucket: "bucket", Prefix: prefix, Format: format}, opts...)
+			if err != nil {
+				t.Fatalf("ImportArchive: %v", err)
+			}
+			if in.Objects != out.Objects || in.Bytes != out.Bytes {
+				t.Errorf("ImportArchive: got %+v, want %+v", in, out)
+			}
+			if diff := cmp.Diff(want, readArchiveTestObjects(t, client, prefix)); diff != "" {
+				t.Errorf("imported objects: got(-),want(+):\n%s", diff)
+			}
+
+			// Importing again skips the existing objects.
+			in, err = ImportArchive(ctx, client, bytes.NewReader(buf.Bytes()), &ImportArchiveInput{Bucket: "bucket", Prefix: prefix, Format: format}, SkipIfExists())
+			if err != nil {
+				t.Fatalf("ImportArchive with SkipIfExists: %v", err)
+			}
+			if in.Objects != 0 || in.Skipped != 3 {
+				t.Errorf("ImportArchive with SkipIfExists: got %+v", in)
+			}
+		})
+	}
+
+	// MatchGlob restricts the export.
+	var buf bytes.Buffer
+	out, err := ExportArchive(ctx, client, &buf, &ExportArchiveInput{Bucket: "bucket", Prefix: "export/", MatchGlob: "**/*.bin"})
+	if err != nil {
+		t.Fatalf("ExportArchive with MatchGlob: %v", err)
+	}
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if out.Objects != 1 || err != nil || hdr.Name != "dir/large.bin" || hdr.Size != int64(len(large)) {
+		t.Errorf("ExportArchive with MatchGlob: got %+v and first entry %+v, %v", out, hdr, err)
+	}
+}
+
+func TestImportArchiveForeign(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := storagetest.NewTestServer(t, "bucket").TestClient(t)
+
+	mtime := time.Unix(1700000000, 0)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range []struct {
+		name    string
+		typ     byte
+		content string
+	}{
+		{"./", tar.TypeDir, ""},
+		{"./sub/", tar.TypeDir, ""},
+		{"./sub/file", tar.TypeReg, "content"},
+		{"./link", tar.TypeSymlink, ""},
+		{"top", tar.TypeReg, "top"},
+	} {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Size: int64(len(e.content)), Mode: 0o644, ModTime: mtime, Linkname: "top"}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, e.content)
+	}
+	tw.Close()
+
+	out, err := ImportArchive(ctx, client, &buf, &ImportArchiveInput{Bucket: "bucket"}, WithPartSize(4))
+	if err != nil {
+		t.Fatalf("ImportArchive: %v", err)
+	}
+	if out.Objects != 2 {
+		t.Errorf("got %+v, want 2 objects", out)
+	}
+	md := map[string]string{mtimeMetadataKey: strconv.FormatInt(mtime.Unix(), 10)}
+	want := map[string]archiveTestObject{
+		"sub/file": {Content: "content", ContentType: "text/plain; charset=utf-8", Metadata: md},
+		"top":      {Content: "top", ContentType: "text/plain; charset=utf-8", Metadata: md},
+	}
+	got := readArchiveTestObjects(t, client, "")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("imported objects: got(-),want(+):\n%s", diff)
+	}
+
+	// A zip archive cannot be read sequentially.
+	if _, err := ImportArchive(ctx, client, strings.NewReader("not a zip"), &ImportArchiveInput{Bucket: "bucket", Format: ArchiveZip}); err == nil {
+		t.Error("ImportArchive of an invalid zip archive: got nil, want error")
+	}
+	if _, err := ImportArchive(ctx, client, io.MultiReader(), &ImportArchiveInput{Bucket: "bucket", Format: ArchiveZip}); err == nil {
+		t.Error("ImportArchive of a zip archive without io.ReaderAt: got nil, want error")
+	}
+}
@@ -13,8 +13,9 @@
 // limitations under the License.
 
 /*
-Package transfermanager provides an easy way to parallelize downloads in Google
-Cloud Storage.
+Package transfermanager provides an easy way to parallelize downloads and
+uploads in Google Cloud Storage, to synchronize a local directory with a
+bucket prefix, and to stream a bucket prefix to and from a tar or zip archive.
 
 More information about Google Cloud Storage is available at
 https://cloud.google.com/storage/docs.
@@ -929,6 +929,8 @@ const (
 	usageMetricKey  = "gccl-gcs-cmd"
 	downloadMany    = "tm.download_many"
 	downloadSharded = "tm.download_sharded"
+	uploadMany      = "tm.upload_many"
+	uploadSharded   = "tm.upload_sharded"
 )
 
 // Sets invocation ID headers on the context which will be propagated as
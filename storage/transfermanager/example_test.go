@@ -129,3 +129,73 @@ func ExampleDownloader_asynchronous() {
 
 	// Repeat if desired.
 }
+
+func ExampleUploader_UploadDirectory() {
+	ctx := context.Background()
+	// Pass in any client opts or set retry policy here.
+	client, err := storage.NewClient(ctx) // can also use NewGRPCClient
+	if err != nil {
+		// handle error
+	}
+
+	// Create Uploader with desired options, including number of workers,
+	// part size, per operation timeout, etc.
+	u, err := transfermanager.NewUploader(client, transfermanager.WithWorkers(16))
+	if err != nil {
+		// handle error
+	}
+
+	// Create upload input. Every file under the local directory is uploaded
+	// to an object named "artifacts/{relative path}".
+	in := &transfermanager.UploadDirectoryInput{
+		Bucket:         "mybucket",
+		LocalDirectory: "/path/to/localdir",
+		Prefix:         "artifacts",
+	}
+
+	// Add to Uploader.
+	if err := u.UploadDirectory(ctx, in); err != nil {
+		// handle error
+	}
+
+	// Wait for all uploads to complete.
+	results, err := u.WaitAndClose()
+	if err != nil {
+		// handle error
+	}
+
+	// Iterate through completed uploads and process results.
+	for _, out := range results {
+		if out.Err != nil {
+			log.Printf("upload of %v failed with error %v", out.Object, out.Err)
+		} else {
+			log.Printf("upload of %v succeeded", out.Object)
+		}
+	}
+}
+
+func ExampleSync() {
+	ctx := context.Background()
+	// Pass in any client opts or set retry policy here.
+	client, err := storage.NewClient(ctx) // can also use NewGRPCClient
+	if err != nil {
+		// handle error
+	}
+
+	// Make the objects under "backups/" match the local directory, deleting
+	// objects whose local files were removed.
+	out, err := transfermanager.Sync(ctx, client, &transfermanager.SyncInput{
+		Bucket:         "mybucket",
+		Prefix:         "backups",
+		LocalDirectory: "/path/to/localdir",
+		Direction:      transfermanager.SyncToBucket,
+		DeleteExtra:    true,
+		OnAction: func(a *transfermanager.SyncAction) {
+			log.Printf("%v %v (%v): %v", a.Type, a.Object, a.Reason, a.Err)
+		},
+	}, transfermanager.WithWorkers(16))
+	if err != nil {
+		// handle error
+	}
+	log.Printf("%d actions taken, %d files unchanged", len(out.Actions), out.Unchanged)
+}
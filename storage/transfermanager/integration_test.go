@@ -1164,6 +1164,82 @@ func TestIntegration_DownloadShard(t *testing.T) {
 // errorIs is equivalent to errors.Is, except that it additionally will return
 // true if err and targetErr are googleapi.Errors with identical error codes,
 // or if both errors have the same gRPC status code.
+// Uploads a local directory synchronously and verifies the object contents.
+// The objects are deleted afterwards so that they do not interfere with the
+// download tests that share the bucket.
+func TestIntegration_UploadDirectory(t *testing.T) {
+	multiTransportTest(context.Background(), t, func(t *testing.T, ctx context.Context, c *storage.Client, tb downloadTestBucket) {
+		localDir := t.TempDir()
+		prefix := "upload-" + uidSpace.New()
+		contentHashes := make(map[string]uint32)
+
+		files := []string{"file", "dir/file", "dir/nested/file"}
+		for i, name := range files {
+			p := filepath.Join(localDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(p), fs.ModeDir|fs.ModePerm); err != nil {
+				t.Fatalf("os.MkdirAll: %v", err)
+			}
+			// Make the last file large enough to be sharded.
+			size := int64(minObjectSize)
+			if i == len(files)-1 {
+				size = maxObjectSize * 3
+			}
+			b := make([]byte, size)
+			if _, err := crand.Read(b); err != nil {
+				t.Fatalf("rand.Read: %v", err)
+			}
+			if err := os.WriteFile(p, b, fs.ModePerm); err != nil {
+				t.Fatalf("os.WriteFile: %v", err)
+			}
+			contentHashes[prefix+"/"+name] = crc32c(b)
+		}
+		t.Cleanup(func() {
+			for object := range contentHashes {
+				c.Bucket(tb.bucket).Object(object).Delete(ctx)
+			}
+		})
+
+		u, err := NewUploader(c, WithWorkers(4), WithPartSize(maxObjectSize))
+		if err != nil {
+			t.Fatalf("NewUploader: %v", err)
+		}
+		if err := u.UploadDirectory(ctx, &UploadDirectoryInput{
+			Bucket:         tb.bucket,
+			LocalDirectory: localDir,
+			Prefix:         prefix,
+		}); err != nil {
+			t.Fatalf("u.UploadDirectory: %v", err)
+		}
+
+		results, err := u.WaitAndClose()
+		if err != nil {
+			t.Fatalf("u.WaitAndClose: %v", err)
+		}
+		if len(results) != len(files) {
+			t.Errorf("expected to receive %d results, got %d results", len(files), len(results))
+		}
+
+		for _, got := range results {
+			if got.Err != nil {
+				t.Errorf("result.Err: %v", got.Err)
+				continue
+			}
+			r, err := c.Bucket(tb.bucket).Object(got.Object).NewReader(ctx)
+			if err != nil {
+				t.Fatalf("NewReader(%q): %v", got.Object, err)
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatalf("io.ReadAll: %v", err)
+			}
+			if wantCRC, gotCRC := contentHashes[got.Object], crc32c(b); gotCRC != wantCRC {
+				t.Errorf("object(%q): content crc32c does not match; got: %v, expected: %v", got.Object, gotCRC, wantCRC)
+			}
+		}
+	})
+}
+
 func errorIs(err error, targetErr error) bool {
 	if errors.Is(err, targetErr) {
 		return true
@@ -26,7 +26,8 @@ type Option interface {
 
 // WithCallbacks returns a TransferManagerOption that allows the use of callbacks
 // to process the results. If this option is set, then results will not be returned
-// by [Downloader.WaitAndClose] and must be processed through the callback.
+// by [Downloader.WaitAndClose] or [Uploader.WaitAndClose] and must be processed
+// through the callback.
 func WithCallbacks() Option {
 	return &withCallbacks{}
 }
@@ -73,12 +74,16 @@ func (wpt withPerOpTimeout) apply(tm *transferManagerConfig) {
 // of the shards to transfer; that is, if the object is larger than partSize,
 // it will be uploaded or downloaded in concurrent pieces of size partSize.
 //
-// The default is 32 MiB for downloads.
+// The default is 32 MiB for both downloads and uploads.
 //
 // To turn off sharding, set partSize to 0.
 //
 // Note that files that support decompressive transcoding will be downloaded in
 // a single piece regardless of the partSize set here.
+//
+// Uploads are sharded through a parallel composite upload, which is only
+// supported by gRPC clients; see [storage.Writer.EnableParallelUpload]. Part
+// sizes smaller than 5 MiB are increased to 5 MiB for uploads.
 func WithPartSize(partSize int64) Option {
 	return &withPartSize{partSize: partSize}
 }
@@ -92,9 +97,11 @@ func (wps withPartSize) apply(tm *transferManagerConfig) {
 }
 
 // SkipIfExists returns a TransferManagerOption that will not download files
-// that already exist in the local directory.
+// that already exist in the local directory, nor upload objects that already
+// exist in the bucket.
 //
-// By default, if a file already exists the operation will abort and return an error.
+// By default, if a file already exists the download will abort and return an
+// error, and an upload will overwrite any existing object.
 func SkipIfExists() Option {
 	return &skipIfExists{}
 }
@@ -124,7 +131,8 @@ type transferManagerConfig struct {
 	asynchronous bool
 
 	// If true, files that already exist in the local directory will not be
-	// downloaded.
+	// downloaded, and objects that already exist in the bucket will not be
+	// uploaded.
 	skipIfExists bool
 }
 
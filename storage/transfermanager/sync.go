@@ -0,0 +1,600 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+// mtimeMetadataKey is the custom metadata key used to store the modification
+// time of the local file an object was uploaded from, in seconds since the
+// Unix epoch. It is the same key used by gsutil and gcloud rsync.
+const mtimeMetadataKey = "goog-reserved-file-mtime"
+
+// SyncDirection specifies which side of a [Sync] is the source.
+type SyncDirection int
+
+const (
+	// SyncToBucket makes the objects under the bucket prefix match the local
+	// directory.
+	SyncToBucket SyncDirection = iota
+
+	// SyncToLocal makes the local directory match the objects under the
+	// bucket prefix.
+	SyncToLocal
+)
+
+// SyncActionType is the type of change made by a [Sync].
+type SyncActionType int
+
+const (
+	// SyncUpload uploads a local file to an object.
+	SyncUpload SyncActionType = iota + 1
+
+	// SyncDownload downloads an object to a local file.
+	SyncDownload
+
+	// SyncDeleteObject deletes an object that has no matching local file.
+	SyncDeleteObject
+
+	// SyncDeleteFile deletes a local file that has no matching object.
+	SyncDeleteFile
+)
+
+func (t SyncActionType) String() string {
+	switch t {
+	case SyncUpload:
+		return "upload"
+	case SyncDownload:
+		return "download"
+	case SyncDeleteObject:
+		return "delete object"
+	case SyncDeleteFile:
+		return "delete file"
+	}
+	return fmt.Sprintf("SyncActionType(%d)", int(t))
+}
+
+// SyncInput is the input for a [Sync].
+type SyncInput struct {
+	// Bucket is the bucket in GCS to synchronize. Required.
+	Bucket string
+
+	// Prefix is the object name prefix that corresponds to LocalDirectory.
+	// A local file at relative path "a/file" corresponds to the object
+	// "{Prefix}/a/file", or "a/file" if Prefix is empty. Optional.
+	Prefix string
+
+	// LocalDirectory is the local directory to synchronize. Relative paths are
+	// allowed. When syncing to local, the directory will be created if it
+	// does not already exist. Required.
+	LocalDirectory string
+
+	// Direction specifies whether the bucket or the local directory is
+	// updated. Defaults to [SyncToBucket].
+	Direction SyncDirection
+
+	// DeleteExtra deletes files or objects in the destination that have no
+	// counterpart in the source. Optional.
+	DeleteExtra bool
+
+	// DryRun computes and reports the actions that would be taken, without
+	// transferring or deleting anything. Optional.
+	DryRun bool
+
+	// OnAction will run after every action completes, or once per action that
+	// would be taken if DryRun is set. Calls are serialized. Optional.
+	OnAction func(*SyncAction)
+}
+
+// SyncAction describes a single change made (or, for a dry run, that would be
+// made) by a [Sync].
+type SyncAction struct {
+	Type      SyncActionType
+	Object    string // full object name
+	LocalPath string // path of the local file
+	Reason    string // why the action is needed, such as "checksum differs"
+	Err       error  // error occurring while performing the action
+}
+
+// SyncOutput provides the output of a [Sync].
+type SyncOutput struct {
+	// Actions are the actions taken, or that would be taken for a dry run.
+	// They are not guaranteed to be in any order.
+	Actions []SyncAction
+
+	// Unchanged is the number of files that were already in sync.
+	Unchanged int
+}
+
+// Sync makes the objects under input.Prefix match the files in
+// input.LocalDirectory, or the reverse, depending on input.Direction. It
+// blocks until all transfers and deletions have completed.
+//
+// A file and an object are considered the same if they have the same size and
+// either the same modification time, as recorded in the object's
+// "goog-reserved-file-mtime" metadata, or the same CRC32C (or MD5, if the
+// object has no CRC32C) checksum. Uploaded objects have their metadata set
+// accordingly, and downloaded files have their modification time restored
+// from it.
+//
+// Transfers are performed by a [Downloader] or [Uploader] configured with opts.
+// Results are reported through input.OnAction, so the [WithCallbacks] option
+// has no effect. The [SkipIfExists] option is not supported.
+//
+// Sync returns an error wrapping all errors encountered by individual actions;
+// these errors are also returned in the respective SyncAction.
+func Sync(ctx context.Context, c *storage.Client, input *SyncInput, opts ...Option) (*SyncOutput, error) {
+	config := initTransferManagerConfig(opts...)
+	if config.skipIfExists {
+		return nil, errors.New("transfermanager: SkipIfExists cannot be used with Sync")
+	}
+	if input.Direction != SyncToBucket && input.Direction != SyncToLocal {
+		return nil, fmt.Errorf("transfermanager: invalid SyncDirection %d", input.Direction)
+	}
+
+	s := &syncer{
+		client: c,
+		config: config,
+		opts:   append(opts, WithCallbacks()),
+		input:  input,
+		output: &SyncOutput{},
+	}
+	if err := s.plan(ctx); err != nil {
+		return nil, err
+	}
+	if input.DryRun {
+		for i := range s.planned {
+			s.report(s.planned[i])
+		}
+		return s.output, nil
+	}
+	if err := s.run(ctx); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, a := range s.output.Actions {
+		if a.Err != nil {
+			errs = append(errs, fmt.Errorf("%v %q: %w", a.Type, a.Object, a.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return s.output, fmt.Errorf("transfermanager: at least one error encountered during sync:\n%w", errors.Join(errs...))
+	}
+	return s.output, nil
+}
+
+type syncer struct {
+	client *storage.Client
+	config *transferManagerConfig
+	opts   []Option
+	input  *SyncInput
+
+	planned  []SyncAction
+	objects  map[string]*storage.ObjectAttrs // keyed by full object name
+	localFis map[string]fs.FileInfo          // keyed by local path
+
+	mu     sync.Mutex
+	output *SyncOutput
+}
+
+// dirPrefix returns the prefix used to list the objects under prefix treated
+// as a directory.
+func dirPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// plan lists both sides of the sync and computes the actions needed.
+func (s *syncer) plan(ctx context.Context) error {
+	prefix := dirPrefix(s.input.Prefix)
+	s.objects = make(map[string]*storage.ObjectAttrs)
+	s.localFis = make(map[string]fs.FileInfo)
+
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Size", "CRC32C", "MD5", "Metadata", "Generation"}); err != nil {
+		return fmt.Errorf("transfermanager: Sync query.SetAttrSelection: %w", err)
+	}
+	remote := make(map[string]*storage.ObjectAttrs) // keyed by relative slash-separated path
+	it := s.client.Bucket(s.input.Bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("transfermanager: Sync failed to list objects: %w", err)
+		}
+		// Skip folder placeholder objects.
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		remote[strings.TrimPrefix(attrs.Name, prefix)] = attrs
+		s.objects[attrs.Name] = attrs
+	}
+
+	local := make(map[string]fs.FileInfo) // keyed by relative slash-separated path
+	if err := filepath.WalkDir(s.input.LocalDirectory, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == s.input.LocalDirectory && errors.Is(err, fs.ErrNotExist) && s.input.Direction == SyncToLocal {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.input.LocalDirectory, filePath)
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		local[filepath.ToSlash(rel)] = fi
+		s.localFis[filePath] = fi
+		return nil
+	}); err != nil {
+		return fmt.Errorf("transfermanager: local directory walkthrough failed: %w", err)
+	}
+
+	src, dst := keys(remote), keys(local)
+	copyType, deleteType := SyncDownload, SyncDeleteFile
+	if s.input.Direction == SyncToBucket {
+		src, dst = dst, src
+		copyType, deleteType = SyncUpload, SyncDeleteObject
+	}
+
+	for rel := range src {
+		if !dst[rel] {
+			s.planned = append(s.planned, s.newAction(copyType, prefix, rel, "not in destination"))
+			continue
+		}
+		a := s.newAction(copyType, prefix, rel, "")
+		reason, err := s.compare(ctx, a.LocalPath, local[rel], remote[rel])
+		if err != nil {
+			return fmt.Errorf("transfermanager: Sync failed to compare %q: %w", a.LocalPath, err)
+		}
+		if reason == "" {
+			s.output.Unchanged++
+			continue
+		}
+		a.Reason = reason
+		s.planned = append(s.planned, a)
+	}
+	if s.input.DeleteExtra {
+		for rel := range dst {
+			if !src[rel] {
+				s.planned = append(s.planned, s.newAction(deleteType, prefix, rel, "not in source"))
+			}
+		}
+	}
+
+	// Order actions by name to make dry runs easier to read.
+	sort.Slice(s.planned, func(i, j int) bool {
+		if s.planned[i].Object != s.planned[j].Object {
+			return s.planned[i].Object < s.planned[j].Object
+		}
+		return s.planned[i].Type < s.planned[j].Type
+	})
+	return nil
+}
+
+func keys[V any](m map[string]V) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+	return set
+}
+
+func (s *syncer) newAction(t SyncActionType, prefix, rel, reason string) SyncAction {
+	return SyncAction{
+		Type:      t,
+		Object:    prefix + rel,
+		LocalPath: filepath.Join(s.input.LocalDirectory, filepath.FromSlash(rel)),
+		Reason:    reason,
+	}
+}
+
+// compare returns a non-empty reason if the local file and the object differ.
+func (s *syncer) compare(ctx context.Context, localPath string, fi fs.FileInfo, attrs *storage.ObjectAttrs) (string, error) {
+	if fi.Size() != attrs.Size {
+		return "size differs", nil
+	}
+	if mtime, ok := objectMtime(attrs); ok && mtime == fi.ModTime().Unix() {
+		return "", nil
+	}
+	if attrs.CRC32C != 0 || len(attrs.MD5) == 0 {
+		got, err := fileCRC32C(ctx, localPath, fi.Size(), s.config.partSize, s.config.numWorkers)
+		if err != nil {
+			return "", err
+		}
+		if got != attrs.CRC32C {
+			return "checksum differs", nil
+		}
+		return "", nil
+	}
+	got, err := fileMD5(localPath)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(got, attrs.MD5) {
+		return "checksum differs", nil
+	}
+	return "", nil
+}
+
+// run performs the planned actions.
+func (s *syncer) run(ctx context.Context) error {
+	var transfers, deletes []SyncAction
+	for _, a := range s.planned {
+		if a.Type == SyncUpload || a.Type == SyncDownload {
+			transfers = append(transfers, a)
+		} else {
+			deletes = append(deletes, a)
+		}
+	}
+
+	if len(transfers) > 0 {
+		var err error
+		if s.input.Direction == SyncToBucket {
+			err = s.upload(ctx, transfers)
+		} else {
+			err = s.download(ctx, transfers)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	g := errgroup.Group{}
+	g.SetLimit(max(s.config.numWorkers, 1))
+	for _, a := range deletes {
+		g.Go(func() error {
+			if a.Type == SyncDeleteObject {
+				o := s.client.Bucket(s.input.Bucket).Object(a.Object)
+				o = o.If(storage.Conditions{GenerationMatch: s.objects[a.Object].Generation})
+				a.Err = o.Delete(ctx)
+			} else {
+				a.Err = os.Remove(a.LocalPath)
+			}
+			s.report(a)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func (s *syncer) upload(ctx context.Context, actions []SyncAction) error {
+	u, err := NewUploader(s.client, s.opts...)
+	if err != nil {
+		return err
+	}
+	for _, a := range actions {
+		conds := storage.Conditions{DoesNotExist: true}
+		if attrs, ok := s.objects[a.Object]; ok {
+			conds = storage.Conditions{GenerationMatch: attrs.Generation}
+		}
+		in := &UploadObjectInput{
+			Bucket:     s.input.Bucket,
+			Object:     a.Object,
+			Conditions: &conds,
+			ObjectAttrs: &storage.ObjectAttrs{
+				Metadata: map[string]string{
+					mtimeMetadataKey: strconv.FormatInt(s.localFis[a.LocalPath].ModTime().Unix(), 10),
+				},
+			},
+			Callback: func(out *UploadOutput) {
+				a.Err = out.Err
+				s.report(a)
+			},
+			localPath: a.LocalPath,
+		}
+		// The input has no Source, so it is queued directly rather than
+		// through UploadObject; as for UploadDirectory, the worker opens the
+		// file when its upload starts.
+		in.ctx = ctx
+		u.addInput(in)
+	}
+	// Errors are reported through the individual actions.
+	u.WaitAndClose()
+	return nil
+}
+
+func (s *syncer) download(ctx context.Context, actions []SyncAction) error {
+	d, err := NewDownloader(s.client, s.opts...)
+	if err != nil {
+		return err
+	}
+	for _, a := range actions {
+		// Prevent directory traversal attacks.
+		isUnder, err := isSubPath(s.input.LocalDirectory, a.LocalPath)
+		if err != nil {
+			d.WaitAndClose()
+			return fmt.Errorf("transfermanager: Sync failed to verify path: %w", err)
+		}
+		if !isUnder {
+			a.Err = fmt.Errorf("skipping download of object with unsafe path %q", a.Object)
+			s.report(a)
+			continue
+		}
+
+		dir := filepath.Dir(a.LocalPath)
+		if err := os.MkdirAll(dir, fs.ModeDir|fs.ModePerm); err != nil {
+			a.Err = err
+			s.report(a)
+			continue
+		}
+		// Download to a temporary file so that the existing file is only
+		// replaced once the download succeeds.
+		f, err := os.CreateTemp(dir, ".gcs-sync-*")
+		if err != nil {
+			a.Err = err
+			s.report(a)
+			continue
+		}
+
+		attrs := s.objects[a.Object]
+		gen := attrs.Generation
+		in := &DownloadObjectInput{
+			Bucket:      s.input.Bucket,
+			Object:      a.Object,
+			Destination: f,
+			Generation:  &gen,
+			Callback: func(out *DownloadOutput) {
+				a.Err = finishDownload(f, a.LocalPath, attrs, out.Err)
+				s.report(a)
+			},
+		}
+		if err := d.DownloadObject(ctx, in); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			d.WaitAndClose()
+			return err
+		}
+	}
+	// Errors are reported through the individual actions.
+	d.WaitAndClose()
+	return nil
+}
+
+// finishDownload closes the temporary file f and, if the download succeeded,
+// moves it to dst and sets its modification time from the object metadata.
+func finishDownload(f *os.File, dst string, attrs *storage.ObjectAttrs, downloadErr error) error {
+	err := f.Close()
+	if downloadErr != nil || err != nil {
+		os.Remove(f.Name())
+		if downloadErr != nil {
+			return downloadErr
+		}
+		return fmt.Errorf("closing file(%q): %w", f.Name(), err)
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if mtime, ok := objectMtime(attrs); ok {
+		t := time.Unix(mtime, 0)
+		if err := os.Chtimes(dst, t, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report records a completed action and runs the OnAction callback.
+func (s *syncer) report(a SyncAction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.output.Actions = append(s.output.Actions, a)
+	if s.input.OnAction != nil {
+		s.input.OnAction(&a)
+	}
+}
+
+// objectMtime returns the local modification time recorded in the object's
+// metadata, in seconds since the Unix epoch.
+func objectMtime(attrs *storage.ObjectAttrs) (int64, bool) {
+	v, ok := attrs.Metadata[mtimeMetadataKey]
+	if !ok {
+		return 0, false
+	}
+	mtime, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return mtime, true
+}
+
+// fileCRC32C computes the CRC32C checksum of a local file. Files larger than
+// partSize are checksummed in concurrent pieces that are then joined.
+func fileCRC32C(ctx context.Context, name string, size, partSize int64, workers int) (uint32, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if partSize < 1 || size <= partSize {
+		h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+		if _, err := io.Copy(h, f); err != nil {
+			return 0, err
+		}
+		return h.Sum32(), nil
+	}
+
+	numPieces := int((size + partSize - 1) / partSize)
+	pieces := make([]crc32cPiece, numPieces)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(workers, 1))
+	for i := range pieces {
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			offset := int64(i) * partSize
+			length := min(partSize, size-offset)
+			h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+			n, err := io.Copy(h, io.NewSectionReader(f, offset, length))
+			if err != nil {
+				return err
+			}
+			pieces[i] = crc32cPiece{sum: h.Sum32(), length: n}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	return joinCRC32C(pieces[0].sum, pieces[1:]), nil
+}
+
+// fileMD5 computes the MD5 hash of a local file.
+func fileMD5(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+)
+
+type syncTestAction struct {
+	Type   SyncActionType
+	Object string
+	Reason string
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+	mtime := time.Unix(1700000000, 0)
+
+	for _, test := range []struct {
+		desc        string
+		local       map[string]string
+		remote      map[string]string
+		direction   SyncDirection
+		deleteExtra bool
+		want        []syncTestAction
+		wantLocal   map[string]string
+		wantRemote  map[string]string
+	}{
+		{
+			desc:      "to bucket",
+			local:     map[string]string{"new": "new", "same": "same", "changed": "local", "resized": "longer"},
+			remote:    map[string]string{"dir/same": "same", "dir/changed": "remot", "dir/resized": "short", "dir/extra": "extra"},
+			direction: SyncToBucket,
+			want: []syncTestAction{
+				{Type: SyncUpload, Object: "dir/changed", Reason: "checksum differs"},
+				{Type: SyncUpload, Object: "dir/new", Reason: "not in destination"},
+				{Type: SyncUpload, Object: "dir/resized", Reason: "size differs"},
+			},
+			wantRemote: map[string]string{"dir/new": "new", "dir/same": "same", "dir/changed": "local", "dir/resized": "longer", "dir/extra": "extra"},
+		},
+		{
+			desc:        "to bucket with delete",
+			local:       map[string]string{"same": "same"},
+			remote:      map[string]string{"dir/same": "same", "dir/extra": "extra", "dir/sub/extra": "extra"},
+			direction:   SyncToBucket,
+			deleteExtra: true,
+			want: []syncTestAction{
+				{Type: SyncDeleteObject, Object: "dir/extra", Reason: "not in source"},
+				{Type: SyncDeleteObject, Object: "dir/sub/extra", Reason: "not in source"},
+			},
+			wantRemote: map[string]string{"dir/same": "same"},
+		},
+		{
+			desc:        "to local",
+			local:       map[string]string{"same": "same", "changed": "local", "extra": "extra"},
+			remote:      map[string]string{"dir/same": "same", "dir/changed": "remot", "dir/sub/new": "new"},
+			direction:   SyncToLocal,
+			deleteExtra: true,
+			want: []syncTestAction{
+				{Type: SyncDownload, Object: "dir/changed", Reason: "checksum differs"},
+				{Type: SyncDeleteFile, Object: "dir/extra", Reason: "not in source"},
+				{Type: SyncDownload, Object: "dir/sub/new", Reason: "not in destination"},
+			},
+			wantLocal: map[string]string{"same": "same", "changed": "remot", "sub/new": "new"},
+		},
+	} {
+		for _, dryRun := range []bool{true, false} {
+			name := test.desc
+			if dryRun {
+				name += " dry run"
+			}
+			t.Run(name, func(t *testing.T) {
+				ctx := context.Background()
+				dir := t.TempDir()
+				for name, content := range test.local {
+					p := filepath.Join(dir, filepath.FromSlash(name))
+					if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				srv := newFakeServer(t)
+				for name, content := range test.remote {
+					srv.put(name, content, nil)
+				}
+				client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+				if err != nil {
+					t.Fatalf("storage.NewClient: %v", err)
+				}
+				defer client.Close()
+
+				var reported []syncTestAction
+				out, err := Sync(ctx, client, &SyncInput{
+					Bucket:         "bucket",
+					Prefix:         "dir",
+					LocalDirectory: dir,
+					Direction:      test.direction,
+					DeleteExtra:    test.deleteExtra,
+					DryRun:         dryRun,
+					OnAction: func(a *SyncAction) {
+						reported = append(reported, syncTestAction{Type: a.Type, Object: a.Object, Reason: a.Reason})
+					},
+				}, WithWorkers(2))
+				if err != nil {
+					t.Fatalf("Sync: %v", err)
+				}
+
+				var got []syncTestAction
+				for _, a := range out.Actions {
+					got = append(got, syncTestAction{Type: a.Type, Object: a.Object, Reason: a.Reason})
+				}
+				sortActions(got)
+				sortActions(reported)
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("Actions: got(-),want(+):\n%s", diff)
+				}
+				if diff := cmp.Diff(test.want, reported); diff != "" {
+					t.Errorf("OnAction: got(-),want(+):\n%s", diff)
+				}
+				if dryRun {
+					return
+				}
+
+				if test.wantRemote != nil {
+					gotRemote := map[string]string{}
+					for name := range srv.objects {
+						gotRemote[name], _ = srv.object(name)
+					}
+					if diff := cmp.Diff(test.wantRemote, gotRemote); diff != "" {
+						t.Errorf("remote objects: got(-),want(+):\n%s", diff)
+					}
+				}
+				if test.wantLocal != nil {
+					gotLocal := map[string]string{}
+					filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+						if err != nil || d.IsDir() {
+							return err
+						}
+						b, err := os.ReadFile(p)
+						rel, _ := filepath.Rel(dir, p)
+						gotLocal[filepath.ToSlash(rel)] = string(b)
+						return err
+					})
+					if diff := cmp.Diff(test.wantLocal, gotLocal); diff != "" {
+						t.Errorf("local files: got(-),want(+):\n%s", diff)
+					}
+				}
+			})
+		}
+	}
+
+	// Objects with a matching modification time are not checksummed.
+	t.Run("mtime", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+		p := filepath.Join(dir, "file")
+		if err := os.WriteFile(p, []byte("local"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		srv := newFakeServer(t)
+		srv.put("file", "remot", map[string]string{mtimeMetadataKey: strconv.FormatInt(mtime.Unix(), 10)})
+		client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+		if err != nil {
+			t.Fatalf("storage.NewClient: %v", err)
+		}
+		defer client.Close()
+
+		out, err := Sync(ctx, client, &SyncInput{Bucket: "bucket", LocalDirectory: dir, DryRun: true})
+		if err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		if len(out.Actions) != 0 || out.Unchanged != 1 {
+			t.Errorf("got %d actions and %d unchanged, want 0 actions and 1 unchanged", len(out.Actions), out.Unchanged)
+		}
+	})
+}
+
+func sortActions(as []syncTestAction) {
+	sort.Slice(as, func(i, j int) bool { return as[i].Object < as[j].Object })
+}
+
+func TestSyncSkipIfExists(t *testing.T) {
+	t.Parallel()
+	if _, err := Sync(context.Background(), nil, &SyncInput{}, SkipIfExists()); err == nil {
+		t.Error("Sync with SkipIfExists: got nil error, want error")
+	}
+}
+
+func TestFileCRC32C(t *testing.T) {
+	t.Parallel()
+	content := []byte("The quick brown fox jumps over the lazy dog")
+	p := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := crc32c(content)
+	for _, partSize := range []int64{0, 1, 5, 10, int64(len(content)), 100} {
+		got, err := fileCRC32C(context.Background(), p, int64(len(content)), partSize, 3)
+		if err != nil {
+			t.Fatalf("fileCRC32C(partSize=%d): %v", partSize, err)
+		}
+		if got != want {
+			t.Errorf("fileCRC32C(partSize=%d): got %d, want %d", partSize, got, want)
+		}
+	}
+}
@@ -0,0 +1,565 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	storageinternal "cloud.google.com/go/storage/internal"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Uploader manages a set of parallelized uploads.
+type Uploader struct {
+	client              *storage.Client
+	config              *transferManagerConfig
+	inputs              []UploadObjectInput
+	results             []UploadOutput
+	errors              []error
+	inputsMu            sync.Mutex
+	resultsMu           sync.Mutex
+	errorsMu            sync.Mutex
+	work                chan *UploadObjectInput // Piece of work to be executed.
+	doneReceivingInputs chan bool               // Indicates to finish up work; expecting no more inputs.
+	workers             *sync.WaitGroup         // Keeps track of the workers that are currently running.
+	uploadsInProgress   *sync.WaitGroup         // Keeps track of objects (and directories) that have not yet produced a result.
+}
+
+// UploadObject queues the upload of a single object. This will initiate the
+// upload but is non-blocking; call Uploader.WaitAndClose or use the callback to
+// process the result. UploadObject is thread-safe and can be called
+// simultaneously from different goroutines.
+// The upload may not start immediately if all workers are busy, so a deadline
+// set on the ctx may time out before the upload even starts. To set a timeout
+// that starts with the upload, use the [WithPerOpTimeout()] option.
+//
+// If the [SkipIfExists] option is set, the object is only written if it does
+// not already exist in the bucket. Uploads that are skipped for this reason
+// complete with a nil Err and nil Attrs.
+func (u *Uploader) UploadObject(ctx context.Context, input *UploadObjectInput) error {
+	if u.closed() {
+		return errors.New("transfermanager: Uploader used after WaitAndClose was called")
+	}
+	if err := u.validateObjectInput(input); err != nil {
+		return err
+	}
+
+	input.ctx = ctx
+	u.addInput(input)
+	return nil
+}
+
+// UploadDirectory queues the upload of every regular file under a local
+// directory. This will initiate the upload but is non-blocking; call
+// Uploader.WaitAndClose or use the callback to process the result.
+// UploadDirectory is thread-safe and can be called simultaneously from
+// different goroutines.
+// Objects are named by joining input.Prefix with the path of the file relative
+// to input.LocalDirectory, using forward slashes as separators. Symbolic links
+// and other irregular files are not uploaded. Files are opened when their
+// upload starts, so do not modify the directory until the upload has completed.
+//
+// If the [SkipIfExists] option is set, files whose object name already exists
+// in the bucket are not uploaded and are not included in the results.
+func (u *Uploader) UploadDirectory(ctx context.Context, input *UploadDirectoryInput) error {
+	if u.closed() {
+		return errors.New("transfermanager: Uploader used after WaitAndClose was called")
+	}
+	if err := u.validateDirectoryInput(input); err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	if u.config.skipIfExists {
+		query := &storage.Query{Prefix: input.Prefix}
+		if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+			return fmt.Errorf("transfermanager: UploadDirectory query.SetAttrSelection: %w", err)
+		}
+		it := u.client.Bucket(input.Bucket).Objects(ctx, query)
+		for {
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("transfermanager: UploadDirectory failed to list objects: %w", err)
+			}
+			existing[attrs.Name] = true
+		}
+	}
+
+	inputs := []UploadObjectInput{}
+	if err := filepath.WalkDir(input.LocalDirectory, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(input.LocalDirectory, filePath)
+		if err != nil {
+			return err
+		}
+		object := path.Join(input.Prefix, filepath.ToSlash(rel))
+		if existing[object] {
+			return nil
+		}
+
+		inputs = append(inputs, UploadObjectInput{
+			Bucket:    input.Bucket,
+			Object:    object,
+			Callback:  input.OnObjectUpload,
+			ctx:       ctx,
+			localPath: filePath,
+			directory: true,
+		})
+		return nil
+	}); err != nil {
+		return fmt.Errorf("transfermanager: local directory walkthrough failed: %w", err)
+	}
+
+	// The channel is buffered to exactly the number of objects, so sending
+	// outputs never blocks a worker.
+	outs := make(chan UploadOutput, len(inputs))
+	for i := range inputs {
+		inputs[i].directoryObjectOutputs = outs
+	}
+
+	if u.config.asynchronous {
+		u.uploadsInProgress.Add(1)
+		go u.gatherObjectOutputs(input, outs, len(inputs))
+	}
+	u.addNewInputs(inputs)
+	return nil
+}
+
+// WaitAndClose waits for all outstanding uploads to complete and closes the
+// Uploader. Adding new uploads after this has been called will cause an error.
+//
+// WaitAndClose returns all the results of the uploads and an error wrapping
+// all errors that were encountered by the Uploader when uploading objects.
+// These errors are also returned in the respective UploadOutput for the
+// failing upload. The results are not guaranteed to be in any order.
+// Results will be empty if using the [WithCallbacks] option. WaitAndClose will
+// wait for all callbacks to finish.
+func (u *Uploader) WaitAndClose() ([]UploadOutput, error) {
+	errMsg := "transfermanager: at least one error encountered uploading objects:"
+	select {
+	case <-u.doneReceivingInputs: // this allows users to call WaitAndClose various times
+		var err error
+		if len(u.errors) > 0 {
+			err = fmt.Errorf("%s\n%w", errMsg, errors.Join(u.errors...))
+		}
+		return u.results, err
+	default:
+		u.uploadsInProgress.Wait()
+		u.doneReceivingInputs <- true
+		u.workers.Wait()
+		close(u.doneReceivingInputs)
+
+		if len(u.errors) > 0 {
+			return u.results, fmt.Errorf("%s\n%w", errMsg, errors.Join(u.errors...))
+		}
+		return u.results, nil
+	}
+}
+
+// sendInputsToWorkChan polls the inputs slice until u.doneReceivingInputs.
+// It will send all items in inputs to the u.work chan.
+// Once it receives from u.doneReceivingInputs, it drains the remaining items
+// in the inputs (sending them to u.work) and then closes the u.work chan.
+func (u *Uploader) sendInputsToWorkChan() {
+	for {
+		select {
+		case <-u.doneReceivingInputs:
+			u.drainInput()
+			close(u.work)
+			return
+		default:
+			u.drainInput()
+		}
+	}
+}
+
+// drainInput consumes everything in the inputs slice and sends it to the work chan.
+// It will block if there are not enough workers to consume every input, until all
+// inputs are received on the work chan(ie. they're dispatched to an available worker).
+func (u *Uploader) drainInput() {
+	for {
+		u.inputsMu.Lock()
+		if len(u.inputs) < 1 {
+			u.inputsMu.Unlock()
+			return
+		}
+		input := u.inputs[0]
+		u.inputs = u.inputs[1:]
+		u.inputsMu.Unlock()
+		u.work <- &input
+	}
+}
+
+func (u *Uploader) addInput(input *UploadObjectInput) {
+	u.uploadsInProgress.Add(1)
+	u.inputsMu.Lock()
+	u.inputs = append(u.inputs, *input)
+	u.inputsMu.Unlock()
+}
+
+// addNewInputs adds a slice of inputs to the uploader.
+func (u *Uploader) addNewInputs(inputs []UploadObjectInput) {
+	u.uploadsInProgress.Add(len(inputs))
+
+	u.inputsMu.Lock()
+	u.inputs = append(u.inputs, inputs...)
+	u.inputsMu.Unlock()
+}
+
+func (u *Uploader) addResult(input *UploadObjectInput, result *UploadOutput) {
+	copiedResult := *result // make a copy so that callbacks do not affect the result
+
+	if input.directory && u.config.asynchronous {
+		input.directoryObjectOutputs <- copiedResult
+	}
+
+	if input.Callback != nil && (u.config.asynchronous || input.directory) {
+		input.Callback(result)
+	}
+	if !u.config.asynchronous {
+		u.resultsMu.Lock()
+		u.results = append(u.results, copiedResult)
+		u.resultsMu.Unlock()
+	}
+
+	// Track all errors that occurred.
+	if result.Err != nil {
+		u.error(fmt.Errorf("uploading %q to bucket %q: %w", input.Object, input.Bucket, result.Err))
+	}
+	u.uploadsInProgress.Done()
+}
+
+func (u *Uploader) error(err error) {
+	u.errorsMu.Lock()
+	u.errors = append(u.errors, err)
+	u.errorsMu.Unlock()
+}
+
+// uploadWorker continuously processes uploads until the work channel is closed.
+func (u *Uploader) uploadWorker() {
+	for {
+		input, ok := <-u.work
+		if !ok {
+			break // no more work; exit
+		}
+
+		out := input.upload(u.client, u.config)
+		u.addResult(input, out)
+	}
+	u.workers.Done()
+}
+
+// gatherObjectOutputs receives from the given channel exactly numObjects times.
+// It will execute the callback once all object outputs are received.
+// It does not do any verification on the outputs nor does it cancel other
+// objects on error.
+func (u *Uploader) gatherObjectOutputs(in *UploadDirectoryInput, gatherOuts <-chan UploadOutput, numObjects int) {
+	outs := make([]UploadOutput, 0, numObjects)
+	for i := 0; i < numObjects; i++ {
+		obj := <-gatherOuts
+		outs = append(outs, obj)
+	}
+
+	// All objects have been gathered; execute the callback.
+	in.Callback(outs)
+	u.uploadsInProgress.Done()
+}
+
+func (u *Uploader) validateObjectInput(in *UploadObjectInput) error {
+	if u.config.asynchronous && in.Callback == nil {
+		return errors.New("transfermanager: input.Callback must not be nil when the WithCallbacks option is set")
+	}
+	if !u.config.asynchronous && in.Callback != nil {
+		return errors.New("transfermanager: input.Callback must be nil unless the WithCallbacks option is set")
+	}
+	if in.Source == nil {
+		return errors.New("transfermanager: input.Source must not be nil")
+	}
+	return nil
+}
+
+func (u *Uploader) validateDirectoryInput(in *UploadDirectoryInput) error {
+	if u.config.asynchronous && in.Callback == nil {
+		return errors.New("transfermanager: input.Callback must not be nil when the WithCallbacks option is set")
+	}
+	if !u.config.asynchronous && in.Callback != nil {
+		return errors.New("transfermanager: input.Callback must be nil unless the WithCallbacks option is set")
+	}
+	return nil
+}
+
+func (u *Uploader) closed() bool {
+	select {
+	case <-u.doneReceivingInputs:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewUploader creates a new Uploader to add operations to.
+// Choice of transport, etc is configured on the client that's passed in.
+// The returned Uploader can be shared across goroutines to initiate uploads.
+func NewUploader(c *storage.Client, opts ...Option) (*Uploader, error) {
+	u := &Uploader{
+		client:              c,
+		config:              initTransferManagerConfig(opts...),
+		inputs:              []UploadObjectInput{},
+		results:             []UploadOutput{},
+		errors:              []error{},
+		work:                make(chan *UploadObjectInput),
+		doneReceivingInputs: make(chan bool),
+		workers:             &sync.WaitGroup{},
+		uploadsInProgress:   &sync.WaitGroup{},
+	}
+
+	// Start a polling routine to send work through.
+	go u.sendInputsToWorkChan()
+
+	// Start workers.
+	for i := 0; i < u.config.numWorkers; i++ {
+		u.workers.Add(1)
+		go u.uploadWorker()
+	}
+
+	return u, nil
+}
+
+// UploadObjectInput is the input for a single object to upload.
+type UploadObjectInput struct {
+	// Bucket is the bucket in GCS to upload to. Required.
+	Bucket string
+
+	// Object is the name of the object to create in GCS. Required.
+	Object string
+
+	// Source is the Reader from which the Uploader will read the object data,
+	// such as an [os.File] file handle. Required.
+	//
+	// If the client uses gRPC and Source has a Stat method (as [os.File]
+	// does) and reports a size larger than the part size set by
+	// [WithPartSize], the object is uploaded in parts using a parallel
+	// composite upload. See [storage.Writer.EnableParallelUpload] for details.
+	Source io.Reader
+
+	// ObjectAttrs are the attributes to set on the new object, such as the
+	// content type or custom metadata. The Bucket and Name fields are ignored.
+	// Optional.
+	ObjectAttrs *storage.ObjectAttrs
+
+	// Conditions constrains the upload to act on a specific
+	// generation/metageneration of the object.
+	// Optional.
+	Conditions *storage.Conditions
+
+	// EncryptionKey will be used to encrypt the object's contents.
+	// The encryption key must be a 32-byte AES-256 key.
+	// See https://cloud.google.com/storage/docs/encryption for details.
+	// Optional.
+	EncryptionKey []byte
+
+	// Callback will be run once the object is finished uploading. It must be
+	// set if and only if the [WithCallbacks] option is set; otherwise, it must
+	// not be set.
+	// A worker will be used to execute the callback; therefore, it should not
+	// be a long-running function. WaitAndClose will wait for all callbacks to
+	// finish.
+	Callback func(*UploadOutput)
+
+	ctx                    context.Context
+	localPath              string // file to open as the Source; set by UploadDirectory
+	directory              bool   // input was queued by calling UploadDirectory
+	directoryObjectOutputs chan<- UploadOutput
+}
+
+// upload writes the input's source to the object. If the configured
+// partSize is smaller than the source and the client uses gRPC, a parallel
+// composite upload is used.
+func (in *UploadObjectInput) upload(client *storage.Client, config *transferManagerConfig) (out *UploadOutput) {
+	out = &UploadOutput{Bucket: in.Bucket, Object: in.Object}
+
+	src := in.Source
+	if in.localPath != "" {
+		f, err := os.Open(in.localPath)
+		if err != nil {
+			out.Err = err
+			return
+		}
+		defer f.Close()
+		src = f
+	}
+	size := sourceSize(src)
+
+	ctx, cancel := context.WithCancel(in.ctx)
+	defer cancel()
+	if config.perOperationTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.perOperationTimeout)
+		defer cancel()
+	}
+
+	sharded := supportsParallelUpload(client) && in.shouldShard(size, config.partSize)
+	method := uploadMany
+	if sharded {
+		method = uploadSharded
+	}
+	ctx = setUsageMetricHeader(ctx, method)
+
+	o := in.setOptionsOnObject(client, config.skipIfExists)
+	w := o.NewWriter(ctx)
+	if in.ObjectAttrs != nil {
+		w.ObjectAttrs = *in.ObjectAttrs
+		w.ObjectAttrs.Bucket = in.Bucket
+		w.ObjectAttrs.Name = in.Object
+	}
+	if sharded {
+		w.EnableParallelUpload = true
+		w.ParallelUploadConfig.PartSize = int(config.partSize)
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		// Cancel the context so that the object is not finalized.
+		cancel()
+		w.Close()
+		out.Err = err
+		return
+	}
+	if err := w.Close(); err != nil {
+		if config.skipIfExists && in.Conditions == nil && isPreconditionFailure(err) {
+			// The object already exists; skip it.
+			return
+		}
+		out.Err = err
+		return
+	}
+
+	out.Attrs = w.Attrs()
+	return
+}
+
+// supportsParallelUpload reports whether the client can make parallel
+// composite uploads, which are only supported by gRPC clients.
+func supportsParallelUpload(client *storage.Client) bool {
+	isGRPC, ok := storageinternal.IsGRPCClient.(func(*storage.Client) bool)
+	return ok && isGRPC(client)
+}
+
+// shouldShard reports whether an object of the given size should be uploaded
+// with a parallel composite upload. Parallel composite uploads do not support
+// MD5 checksums or customer-supplied encryption keys.
+func (in *UploadObjectInput) shouldShard(size, partSize int64) bool {
+	if partSize < 1 || size <= partSize {
+		return false
+	}
+	if len(in.EncryptionKey) > 0 {
+		return false
+	}
+	if in.ObjectAttrs != nil && len(in.ObjectAttrs.MD5) > 0 {
+		return false
+	}
+	return true
+}
+
+func (in *UploadObjectInput) setOptionsOnObject(client *storage.Client, skipIfExists bool) *storage.ObjectHandle {
+	o := client.Bucket(in.Bucket).Object(in.Object)
+	if in.Conditions != nil {
+		o = o.If(*in.Conditions)
+	} else if skipIfExists {
+		o = o.If(storage.Conditions{DoesNotExist: true})
+	}
+	if len(in.EncryptionKey) > 0 {
+		o = o.Key(in.EncryptionKey)
+	}
+	return o
+}
+
+// UploadDirectoryInput is the input for a directory to upload.
+type UploadDirectoryInput struct {
+	// Bucket is the bucket in GCS to upload to. Required.
+	Bucket string
+
+	// LocalDirectory specifies the directory to upload. Relative paths are
+	// allowed. The directory structure and contents must not be modified while
+	// the upload is in progress. Required.
+	LocalDirectory string
+
+	// Prefix is prepended to the path of each file, relative to
+	// LocalDirectory, to form the object name. For example, if LocalDirectory
+	// contains a file "a/file" and Prefix is set to "mydirectory/", the file
+	// will be uploaded to "mydirectory/a/file". Optional.
+	Prefix string
+
+	// Callback will run after all the files in the directory are finished
+	// uploading.
+	// It must be set if and only if the [WithCallbacks] option is set.
+	// WaitAndClose will wait for all callbacks to finish.
+	Callback func([]UploadOutput)
+
+	// OnObjectUpload will run after every finished object upload. Optional.
+	OnObjectUpload func(*UploadOutput)
+}
+
+// UploadOutput provides output for a single object upload. If the upload was
+// successful, Attrs will be populated.
+type UploadOutput struct {
+	Bucket string
+	Object string
+	Err    error                // error occurring during upload
+	Attrs  *storage.ObjectAttrs // attributes of the uploaded object, if successful
+}
+
+// sourceSize returns the size of r if it can be determined through a Stat
+// method, or -1 otherwise.
+func sourceSize(r io.Reader) int64 {
+	s, ok := r.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return -1
+	}
+	fi, err := s.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return -1
+	}
+	return fi.Size()
+}
+
+// isPreconditionFailure reports whether err is a precondition failure from
+// either the JSON or the gRPC API.
+func isPreconditionFailure(err error) bool {
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		return e.Code == http.StatusPreconditionFailed
+	}
+	return status.Code(err) == codes.FailedPrecondition
+}
@@ -0,0 +1,452 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUploaderWaitAndClose(t *testing.T) {
+	t.Parallel()
+	u, err := NewUploader(nil)
+	if err != nil {
+		t.Fatalf("NewUploader: %v", err)
+	}
+
+	if _, err := u.WaitAndClose(); err != nil {
+		t.Fatalf("WaitAndClose: %v", err)
+	}
+
+	expectedErr := "transfermanager: Uploader used after WaitAndClose was called"
+	err = u.UploadObject(context.Background(), &UploadObjectInput{Source: strings.NewReader("")})
+	if err == nil {
+		t.Fatalf("u.UploadObject err was nil, should be %q", expectedErr)
+	}
+	if !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("expected err %q, got: %v", expectedErr, err.Error())
+	}
+}
+
+func TestUploaderValidateInput(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		desc          string
+		withCallbacks bool
+		input         *UploadObjectInput
+		expectedErr   string
+	}{
+		{
+			desc:          "cannot use callbacks without the option",
+			withCallbacks: false,
+			input:         &UploadObjectInput{Source: strings.NewReader(""), Callback: func(*UploadOutput) {}},
+			expectedErr:   "transfermanager: input.Callback must be nil unless the WithCallbacks option is set",
+		},
+		{
+			desc:          "must provide callback when option is set",
+			withCallbacks: true,
+			input:         &UploadObjectInput{Source: strings.NewReader("")},
+			expectedErr:   "transfermanager: input.Callback must not be nil when the WithCallbacks option is set",
+		},
+		{
+			desc:        "must provide source",
+			input:       &UploadObjectInput{},
+			expectedErr: "transfermanager: input.Source must not be nil",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var opts []Option
+			if test.withCallbacks {
+				opts = append(opts, WithCallbacks())
+			}
+			u, err := NewUploader(nil, opts...)
+			if err != nil {
+				t.Fatalf("NewUploader: %v", err)
+			}
+
+			err = u.UploadObject(context.Background(), test.input)
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected err %q, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestShouldShard(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		desc     string
+		input    UploadObjectInput
+		size     int64
+		partSize int64
+		want     bool
+	}{
+		{
+			desc:     "unknown size",
+			size:     -1,
+			partSize: 10,
+			want:     false,
+		},
+		{
+			desc:     "smaller than partSize",
+			size:     10,
+			partSize: 10,
+			want:     false,
+		},
+		{
+			desc:     "larger than partSize",
+			size:     11,
+			partSize: 10,
+			want:     true,
+		},
+		{
+			desc:     "sharding turned off",
+			size:     11,
+			partSize: 0,
+			want:     false,
+		},
+		{
+			desc:     "encryption key",
+			input:    UploadObjectInput{EncryptionKey: []byte("key")},
+			size:     11,
+			partSize: 10,
+			want:     false,
+		},
+		{
+			desc:     "MD5",
+			input:    UploadObjectInput{ObjectAttrs: &storage.ObjectAttrs{MD5: []byte("md5")}},
+			size:     11,
+			partSize: 10,
+			want:     false,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := test.input.shouldShard(test.size, test.partSize); got != test.want {
+				t.Errorf("shouldShard(%d, %d): got %v, want %v", test.size, test.partSize, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSupportsParallelUpload(t *testing.T) {
+	ctx := context.Background()
+	httpClient, err := storage.NewClient(ctx, option.WithEndpoint("http://localhost:0"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("storage.NewClient: %v", err)
+	}
+	defer httpClient.Close()
+	grpcClient, err := storage.NewGRPCClient(ctx, option.WithEndpoint("localhost:0"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("storage.NewGRPCClient: %v", err)
+	}
+	defer grpcClient.Close()
+
+	if supportsParallelUpload(httpClient) {
+		t.Error("HTTP client: got true, want false")
+	}
+	if !supportsParallelUpload(grpcClient) {
+		t.Error("gRPC client: got false, want true")
+	}
+}
+
+func TestSourceSize(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	fp := filepath.Join(dir, "file")
+	if err := os.WriteFile(fp, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if got, want := sourceSize(f), int64(5); got != want {
+		t.Errorf("sourceSize(file): got %d, want %d", got, want)
+	}
+	if got, want := sourceSize(strings.NewReader("hello")), int64(-1); got != want {
+		t.Errorf("sourceSize(reader): got %d, want %d", got, want)
+	}
+}
+
+func TestIsPreconditionFailure(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &googleapi.Error{Code: http.StatusPreconditionFailed}, want: true},
+		{err: fmt.Errorf("wrapped: %w", &googleapi.Error{Code: http.StatusPreconditionFailed}), want: true},
+		{err: &googleapi.Error{Code: http.StatusNotFound}, want: false},
+		{err: status.Error(codes.FailedPrecondition, "exists"), want: true},
+		{err: status.Error(codes.NotFound, "missing"), want: false},
+		{err: errors.New("other"), want: false},
+	} {
+		if got := isPreconditionFailure(test.err); got != test.want {
+			t.Errorf("isPreconditionFailure(%v): got %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestUploadDirectory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"a":          "content a",
+		"sub/b":      "content b",
+		"sub/deep/c": "content c",
+		"existing":   "do not upload",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := newFakeServer(t, "prefix/existing")
+	client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("storage.NewClient: %v", err)
+	}
+	defer client.Close()
+
+	u, err := NewUploader(client, WithWorkers(2), SkipIfExists())
+	if err != nil {
+		t.Fatalf("NewUploader: %v", err)
+	}
+	var mu sync.Mutex
+	var perObject []string
+	if err := u.UploadDirectory(ctx, &UploadDirectoryInput{
+		Bucket:         "bucket",
+		LocalDirectory: dir,
+		Prefix:         "prefix",
+		OnObjectUpload: func(out *UploadOutput) {
+			mu.Lock()
+			perObject = append(perObject, out.Object)
+			mu.Unlock()
+		},
+	}); err != nil {
+		t.Fatalf("UploadDirectory: %v", err)
+	}
+	results, err := u.WaitAndClose()
+	if err != nil {
+		t.Fatalf("WaitAndClose: %v", err)
+	}
+
+	var gotNames []string
+	for _, r := range results {
+		if r.Attrs == nil {
+			t.Errorf("%q: Attrs not populated", r.Object)
+		}
+		gotNames = append(gotNames, r.Object)
+	}
+	sort.Strings(gotNames)
+	sort.Strings(perObject)
+	wantNames := []string{"prefix/a", "prefix/sub/b", "prefix/sub/deep/c"}
+	if diff := cmp.Diff(wantNames, gotNames); diff != "" {
+		t.Errorf("uploaded objects: got(-),want(+):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantNames, perObject); diff != "" {
+		t.Errorf("OnObjectUpload objects: got(-),want(+):\n%s", diff)
+	}
+	for _, name := range wantNames {
+		want := files[strings.TrimPrefix(name, "prefix/")]
+		if got, _ := srv.object(name); got != want {
+			t.Errorf("%q: got content %q, want %q", name, got, want)
+		}
+	}
+}
+
+type fakeObject struct {
+	content    string
+	metadata   map[string]string
+	generation int64
+}
+
+// fakeServer is a minimal in-memory implementation of the JSON API list,
+// multipart upload and delete calls and of XML API reads for a single bucket.
+type fakeServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string]*fakeObject
+	nextGen int64
+}
+
+// newFakeServer starts a fakeServer. The given objects are created empty.
+func newFakeServer(t *testing.T, existing ...string) *fakeServer {
+	s := &fakeServer{objects: map[string]*fakeObject{}, nextGen: 1}
+	for _, name := range existing {
+		s.put(name, "", nil)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/o"):
+		var items []map[string]any
+		for name, o := range s.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, o.resource(name))
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"kind": "storage#objects", "items": items})
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/"):
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		metaPart, err := mr.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var meta struct {
+			Name     string            `json:"name"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		if err := json.NewDecoder(metaPart).Decode(&meta); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mediaPart, err := mr.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, err := io.ReadAll(mediaPart)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !s.checkGeneration(w, r, meta.Name) {
+			return
+		}
+		o := s.put(meta.Name, string(content), meta.Metadata)
+		json.NewEncoder(w).Encode(o.resource(meta.Name))
+	case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/b/bucket/o/"):
+		_, name, _ := strings.Cut(r.URL.Path, "/b/bucket/o/")
+		if _, ok := s.objects[name]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if !s.checkGeneration(w, r, name) {
+			return
+		}
+		delete(s.objects, name)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/bucket/"):
+		name := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		o, ok := s.objects[name]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Goog-Generation", fmt.Sprint(o.generation))
+		w.Header().Set("X-Goog-Metageneration", "1")
+		w.Header().Set("X-Goog-Hash", "crc32c="+o.crc32c())
+		w.Header().Set("Content-Length", fmt.Sprint(len(o.content)))
+		io.WriteString(w, o.content)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+// checkGeneration enforces the ifGenerationMatch precondition, if any.
+// s.mu must be held.
+func (s *fakeServer) checkGeneration(w http.ResponseWriter, r *http.Request, name string) bool {
+	want := r.URL.Query().Get("ifGenerationMatch")
+	if want == "" {
+		return true
+	}
+	var got int64
+	if o, ok := s.objects[name]; ok {
+		got = o.generation
+	}
+	if want != fmt.Sprint(got) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// put creates or replaces an object. s.mu must be held if the server is running.
+func (s *fakeServer) put(name, content string, metadata map[string]string) *fakeObject {
+	o := &fakeObject{content: content, metadata: metadata, generation: s.nextGen}
+	s.nextGen++
+	s.objects[name] = o
+	return o
+}
+
+func (s *fakeServer) object(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[name]
+	if !ok {
+		return "", false
+	}
+	return o.content, true
+}
+
+func (o *fakeObject) crc32c() string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], crc32c([]byte(o.content)))
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+func (o *fakeObject) resource(name string) map[string]any {
+	return map[string]any{
+		"bucket":     "bucket",
+		"name":       name,
+		"size":       fmt.Sprint(len(o.content)),
+		"generation": fmt.Sprint(o.generation),
+		"crc32c":     o.crc32c(),
+		"metadata":   o.metadata,
+	}
+}
@@ -146,6 +146,31 @@ type Writer struct {
 	// is provided, then gax.DetermineContentType is called to sniff the type.
 	ForceEmptyContentType bool
 
+	// CompressGzip, if true, compresses the data written with gzip on the
+	// client and sets ContentEncoding to "gzip", so that the object is stored
+	// compressed and served decompressed by the service.
+	//
+	// The data is compressed in independent gzip members of GzipMemberSize
+	// bytes of uncompressed data, followed by an index of the members, and
+	// the member size is recorded in the object's metadata under the key
+	// "gzip-member-size". The object remains a valid gzip file, and readers
+	// created with [ObjectHandle.ReadGzipMembers] use the index to decompress
+	// only the members that overlap a range.
+	//
+	// If ContentType is empty, it is detected from the uncompressed data of
+	// the first Write. CompressGzip cannot be used with Append, resumable
+	// sessions, envelope encryption, a ContentEncoding set by the caller, or
+	// checksums provided by the caller through SendCRC32C or ObjectAttrs.MD5.
+	// The ObjectAttrs returned by Attrs describe the compressed object.
+	//
+	// CompressGzip must be set before the first call to Write.
+	CompressGzip bool
+
+	// GzipMemberSize is the number of bytes of uncompressed data in each gzip
+	// member when CompressGzip is true. Smaller members make range reads
+	// cheaper, at the expense of the compression ratio. The default is 1 MiB.
+	GzipMemberSize int
+
 	// Append is a parameter to indicate whether the writer should use appendable
 	// object semantics for the new object generation. Appendable objects are
 	// visible on the first Write() call, and can be appended to until they are
@@ -185,6 +210,22 @@ type Writer struct {
 	// ProgressFunc should return quickly without blocking.
 	ProgressFunc func(int64)
 
+	// SessionFunc, if not nil, is called with the state of the upload's
+	// resumable session when the session is created and each time the service
+	// reports that more of the object has been persisted. If the Writer fails
+	// or the process exits before the upload completes, the last session
+	// reported can be passed to [ObjectHandle.NewWriterFromSession] to resume
+	// the upload from the persisted offset instead of starting over.
+	//
+	// SessionFunc requires a non-zero ChunkSize and cannot be used with Append
+	// or EnableParallelUpload. With gRPC, objects small enough to be uploaded
+	// in a single request are not uploaded with a resumable session, and
+	// SessionFunc is not called.
+	//
+	// SessionFunc must be set before the first Write call, and should return
+	// quickly without blocking.
+	SessionFunc func(*ResumableSession)
+
 	// EnableParallelUpload enables the parallel upload feature.
 	// This feature splits a large object into multiple parts and uploads them in
 	// parallel. Supported exclusively for gRPC clients. If used with a JSON
@@ -245,6 +286,17 @@ type Writer struct {
 	err               error
 	setTakeoverOffset func(int64)
 
+	// session is the resumable session to continue, for Writers created by
+	// NewWriterFromSession.
+	session *ResumableSession
+
+	// enc encrypts the data written, if the ObjectHandle uses envelope
+	// encryption.
+	enc *segmentEncrypter
+
+	// gz compresses the data written, if CompressGzip is true.
+	gz *gzipMemberWriter
+
 	// bytesWritten is the cumulative bytes written for request size metric.
 	bytesWritten int64
 }
@@ -265,6 +317,12 @@ func (w *Writer) wrapWriteError(n int, err error) (int, error) {
 	return n, err
 }
 
+// envelopeEncryption reports whether w encrypts the data written with
+// client-side envelope encryption.
+func (w *Writer) envelopeEncryption() bool {
+	return w.o != nil && w.o.keyWrapper != nil
+}
+
 func (w *Writer) isGRPCClient() bool {
 	_, ok := w.o.c.tc.(*grpcStorageClient)
 	return ok
@@ -284,6 +342,9 @@ func (w *Writer) getOrInitPCU() (*pcuState, error) {
 			w.EnableParallelUpload = false
 			return nil, nil
 		}
+		if w.SessionFunc != nil || w.session != nil {
+			return nil, errors.New("storage: resumable sessions cannot be used with EnableParallelUpload")
+		}
 		if err := w.initPCU(w.ctx); err != nil {
 			return nil, err
 		}
@@ -301,6 +362,32 @@ func (w *Writer) getOrInitPCU() (*pcuState, error) {
 // Writes will be retried on transient errors from the server, unless
 // Writer.ChunkSize has been set to zero.
 func (w *Writer) Write(p []byte) (int, error) {
+	if !w.envelopeEncryption() && !w.CompressGzip {
+		return w.write(p)
+	}
+	w.mu.Lock()
+	werr, closed := w.err, w.closed
+	w.mu.Unlock()
+	if werr != nil {
+		return 0, werr
+	}
+	if closed {
+		return 0, fmt.Errorf("storage: Writer is closed")
+	}
+	if w.CompressGzip {
+		if err := w.initGzip(p); err != nil {
+			return 0, err
+		}
+		return w.gz.Write(p)
+	}
+	if err := w.initEnvelope(); err != nil {
+		return 0, err
+	}
+	return w.enc.Write(p)
+}
+
+// write writes p, which has been compressed or encrypted if necessary.
+func (w *Writer) write(p []byte) (int, error) {
 	w.mu.Lock()
 	werr, closed, pcu := w.err, w.closed, w.pcu
 	w.mu.Unlock()
@@ -399,6 +486,22 @@ func (w *Writer) Close() error {
 		return werr
 	}
 
+	if w.CompressGzip {
+		if err := w.initGzip(nil); err != nil {
+			return w.markClosed(err)
+		}
+		if err := w.gz.close(); err != nil {
+			return w.markClosed(err)
+		}
+	} else if w.envelopeEncryption() {
+		if err := w.initEnvelope(); err != nil {
+			return w.markClosed(err)
+		}
+		if err := w.enc.close(); err != nil {
+			return w.markClosed(err)
+		}
+	}
+
 	if pcu != nil || (!w.opened && w.EnableParallelUpload) {
 		var err error
 		if pcu, err = w.getOrInitPCU(); err != nil {
@@ -489,6 +592,8 @@ func (w *Writer) openWriter() (err error) {
 		},
 		setTakeoverOffset:     w.setTakeoverOffset,
 		forceEmptyContentType: w.ForceEmptyContentType,
+		session:               w.session,
+		setSession:            w.SessionFunc,
 	}
 	if err := w.ctx.Err(); err != nil {
 		return err // short-circuit
@@ -553,6 +658,14 @@ func (w *Writer) validateWriteAttrs() error {
 	if w.ChunkSize < 0 {
 		return errors.New("storage: Writer.ChunkSize must be non-negative")
 	}
+	if w.SessionFunc != nil || w.session != nil {
+		if w.ChunkSize == 0 {
+			return errors.New("storage: resumable sessions require a non-zero Writer.ChunkSize")
+		}
+		if w.Append {
+			return errors.New("storage: resumable sessions cannot be used with Writer.Append")
+		}
+	}
 	return nil
 }
 